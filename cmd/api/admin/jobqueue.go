@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// GetJobQueue reports the job queues' depths and the jobs workers are
+// running, those whose leases expire first.
+func GetJobQueue(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.Q == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "redis not configured", nil)
+			return
+		}
+		limit := 50
+		if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 200 {
+			limit = v
+		}
+		s, err := jobs.Inspect(c.Request.Context(), a.Q, limit)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "redis_error", "failed to inspect job queues", nil)
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// ListDeadJobs pages through the dead-letter queue, newest first.
+func ListDeadJobs(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.Q == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "redis not configured", nil)
+			return
+		}
+		limit, offset := 50, 0
+		if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 200 {
+			limit = v
+		}
+		if v, err := strconv.Atoi(c.Query("offset")); err == nil && v > 0 {
+			offset = v
+		}
+		out, total, err := jobs.ListDead(c.Request.Context(), a.Q, offset, limit)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "redis_error", "failed to list dead jobs", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"jobs": out, "total": total})
+	}
+}
+
+// RetryDeadJob puts a dead-lettered job back on its queue with its
+// attempts reset.
+func RetryDeadJob(a *apppkg.App) gin.HandlerFunc {
+	return deadJobAction(a, "dead_job_retried", jobs.RetryDead)
+}
+
+// DeleteDeadJob discards a dead-lettered job.
+func DeleteDeadJob(a *apppkg.App) gin.HandlerFunc {
+	return deadJobAction(a, "dead_job_deleted", jobs.DeleteDead)
+}
+
+func deadJobAction(a *apppkg.App, action string, fn func(context.Context, redis.Cmdable, string) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.Q == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "redis not configured", nil)
+			return
+		}
+		id := c.Param("id")
+		if err := fn(c.Request.Context(), a.Q, id); errors.Is(err, jobs.ErrNotDead) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "dead job not found", nil)
+			return
+		} else if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "redis_error", "failed to update dead job", nil)
+			return
+		}
+		if a.DB != nil {
+			if err := audit.RecordDiff(c.Request.Context(), a.DB, authpkg.Actor(c), "job", id, action, nil); err != nil {
+				log.Error().Err(err).Msg("audit dead job")
+			}
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestDeadJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	if err := jobs.Enqueue(ctx, rdb, "e1", jobs.TypeSendEmail, jobs.Email{To: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	l, _ := jobs.Reserve(ctx, rdb, jobs.Queues(), time.Minute)
+	if err := jobs.Bury(ctx, rdb, l, "invalid To address"); err != nil {
+		t.Fatal(err)
+	}
+
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, nil, nil, nil, nil)
+	a.Q = rdb
+	a.R.GET("/admin/jobs/:id", GetJobRun(a))
+	a.R.GET("/admin/jobs/queue", GetJobQueue(a))
+	a.R.GET("/admin/jobs/dead", ListDeadJobs(a))
+	a.R.POST("/admin/jobs/dead/:id/retry", RetryDeadJob(a))
+	a.R.DELETE("/admin/jobs/dead/:id", DeleteDeadJob(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/jobs/dead", nil))
+	var list struct {
+		Jobs  []jobs.DeadJob `json:"jobs"`
+		Total int            `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK || list.Total != 1 || list.Jobs[0].JobID != "e1" {
+		t.Fatalf("list: %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/jobs/dead/"+l.Token+"/retry", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("retry: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/jobs/dead/"+l.Token, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected the retried job gone from the dead-letter queue, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/jobs/queue", nil))
+	var s jobs.Stats
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil || rr.Code != http.StatusOK || s.Queues[0].Pending != 1 || s.Dead != 0 {
+		t.Fatalf("queue: %d %s", rr.Code, rr.Body.String())
+	}
+
+	mr.SetError("down")
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/jobs/dead/x/retry", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected a redis error, got %d", rr.Code)
+	}
+}
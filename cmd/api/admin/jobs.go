@@ -0,0 +1,152 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// JobRun is one run of a worker maintenance job.
+type JobRun struct {
+	ID          string          `json:"id"`
+	Job         string          `json:"job"`
+	Status      string          `json:"status"`
+	Trigger     string          `json:"trigger"`
+	Params      json.RawMessage `json:"params"`
+	RequestedBy *string         `json:"requested_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	Summary     json.RawMessage `json:"summary,omitempty"`
+	Error       *string         `json:"error,omitempty"`
+}
+
+// runnableJobs are the jobs an admin may start on demand.
+var runnableJobs = map[string]bool{jobs.TypeReconcileAttachments: true}
+
+// jobRunCols selects a JobRun up to its summary; callers append the summary
+// expression and error.
+const jobRunCols = `id::text, job, status, trigger, params, requested_by::text, created_at, started_at, finished_at, `
+
+func scanJobRun(row pgx.Row) (JobRun, error) {
+	var r JobRun
+	var params, summary []byte
+	if err := row.Scan(&r.ID, &r.Job, &r.Status, &r.Trigger, &params, &r.RequestedBy, &r.CreatedAt, &r.StartedAt, &r.FinishedAt, &summary, &r.Error); err != nil {
+		return r, err
+	}
+	r.Params = params
+	if len(summary) > 0 {
+		r.Summary = summary
+	}
+	return r, nil
+}
+
+// ListJobRuns returns recent job runs, newest first, optionally for one job.
+// Summaries omit their issue lists; GetJobRun returns them.
+func ListJobRuns(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := []JobRun{}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"runs": out})
+			return
+		}
+		limit := 50
+		if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 && v <= 200 {
+			limit = v
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+jobRunCols+`summary - 'issues', error
+            from job_runs where ($1 = '' or job = $1) order by created_at desc limit $2`, c.Query("job"), limit)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list job runs", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			r, err := scanJobRun(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list job runs", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		if err := rows.Err(); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list job runs", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"runs": out})
+	}
+}
+
+// GetJobRun returns one job run with its full summary.
+func GetJobRun(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil || a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "job run not found", nil)
+			return
+		}
+		r, err := scanJobRun(a.DB.QueryRow(c.Request.Context(), `select `+jobRunCols+`summary, error from job_runs where id=$1`, c.Param("id")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "job run not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load job run", nil)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// RunJob queues a run of the job named by :id. The run is recorded as
+// queued and the worker picks it up through the outbox.
+func RunJob(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job := c.Param("id")
+		if !runnableJobs[job] {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "unknown job", nil)
+			return
+		}
+		var in struct {
+			Repair bool `json:"repair"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+				return
+			}
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database not configured", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		params, _ := json.Marshal(in)
+		var r JobRun
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			r, err = scanJobRun(tx.QueryRow(ctx, `insert into job_runs (job, trigger, params, requested_by)
+                values ($1, 'manual', $2::jsonb, $3) returning `+jobRunCols+`summary, error`,
+				job, string(params), authpkg.Actor(c).DBID()))
+			if err != nil {
+				return err
+			}
+			return outbox.AddJob(ctx, tx, job+":"+r.ID, r.ID, job, jobs.ReconcileAttachments{RunID: r.ID, Repair: in.Repair})
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to queue job", nil)
+			return
+		}
+		c.JSON(http.StatusAccepted, r)
+	}
+}
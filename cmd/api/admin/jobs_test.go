@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestRunJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var queued []byte
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "11111111-1111-1111-1111-111111111111"
+				*dest[1].(*string) = args[0].(string)
+				*dest[2].(*string) = "queued"
+				*dest[3].(*string) = "manual"
+				*dest[4].(*[]byte) = []byte(args[1].(string))
+				*dest[6].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "outbox") {
+				queued = []byte(args[2].(string))
+			}
+			return pgconn.NewCommandTag("INSERT 0 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/admin/jobs/:id/run", RunJob(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/jobs/send_email/run", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a job that cannot be run on demand, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/jobs/"+jobs.TypeReconcileAttachments+"/run", strings.NewReader(`{"repair":true}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var run JobRun
+	if err := json.Unmarshal(rr.Body.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "queued" || string(run.Params) != `{"repair":true}` {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	job, err := jobs.Decode(queued)
+	if err != nil {
+		t.Fatalf("decode queued job: %v", err)
+	}
+	var payload jobs.ReconcileAttachments
+	if err := json.Unmarshal(job.Data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != jobs.TypeReconcileAttachments || payload.RunID != run.ID || !payload.Repair {
+		t.Fatalf("unexpected queued job %+v %+v", job, payload)
+	}
+}
+
+func TestGetJobRunNotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &testutil.MockDB{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/admin/jobs/:id", GetJobRun(a))
+	for _, id := range []string{"not-a-uuid", "11111111-1111-1111-1111-111111111111"} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/jobs/"+id, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", id, rr.Code)
+		}
+	}
+}
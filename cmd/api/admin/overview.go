@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// activeWindow is how recently an agent must have acted to count as active.
+const activeWindow = 24 * time.Hour
+
+// QueueCount is the number of open tickets in a queue. QueueID is empty for
+// tickets without a queue.
+type QueueCount struct {
+	QueueID string `json:"queue_id"`
+	Name    string `json:"name"`
+	Open    int    `json:"open"`
+}
+
+// Overview is the admin landing page summary.
+type Overview struct {
+	OpenByQueue      []QueueCount      `json:"open_by_queue"`
+	OpenTotal        int               `json:"open_total"`
+	SLAAtRisk        int               `json:"sla_at_risk"`
+	SLABreached      int               `json:"sla_breached"`
+	Unassigned       int               `json:"unassigned"`
+	JobQueueDepth    map[string]int64  `json:"job_queue_depth"`
+	EmailFailures24h int               `json:"email_failures_24h"`
+	ActiveAgents     int               `json:"active_agents"`
+	GeneratedAt      time.Time         `json:"generated_at"`
+	Errors           map[string]string `json:"errors,omitempty"`
+}
+
+// GetOverview aggregates the admin dashboard figures in one call. Sections
+// that fail are reported under errors while the rest are still returned.
+func GetOverview(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		out := Overview{OpenByQueue: []QueueCount{}, JobQueueDepth: map[string]int64{}, GeneratedAt: time.Now().UTC()}
+		errs := map[string]string{}
+		if a.Q != nil {
+			for _, q := range append(jobs.Queues(), jobs.QueueDelayed) {
+				var n int64
+				var err error
+				if q == jobs.QueueDelayed {
+					n, err = a.Q.ZCard(ctx, q).Result()
+				} else {
+					n, err = a.Q.LLen(ctx, q).Result()
+				}
+				if err != nil {
+					errs["job_queue_depth"] = err.Error()
+					break
+				}
+				out.JobQueueDepth[q] = n
+			}
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		for _, s := range []struct {
+			name string
+			fn   func(context.Context, apppkg.DB, *Overview) error
+		}{
+			{"open_by_queue", openByQueue},
+			{"sla", slaCounts},
+			{"unassigned", unassigned},
+			{"email_failures_24h", emailFailures},
+			{"active_agents", activeAgents},
+		} {
+			if err := s.fn(ctx, a.DB, &out); err != nil {
+				errs[s.name] = err.Error()
+			}
+		}
+		if len(errs) > 0 {
+			out.Errors = errs
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+func openByQueue(ctx context.Context, db apppkg.DB, o *Overview) error {
+	rows, err := db.Query(ctx, `select coalesce(q.id::text, ''), coalesce(q.name, 'Unqueued'), count(*)
+        from tickets t left join queues q on q.id = t.queue_id
+        where t.status not in ('Resolved','Closed')
+        group by q.id, q.name order by count(*) desc`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var qc QueueCount
+		if err := rows.Scan(&qc.QueueID, &qc.Name, &qc.Open); err != nil {
+			return err
+		}
+		o.OpenByQueue = append(o.OpenByQueue, qc)
+		o.OpenTotal += qc.Open
+	}
+	return rows.Err()
+}
+
+func slaCounts(ctx context.Context, db apppkg.DB, o *Overview) error {
+	return db.QueryRow(ctx, `select count(*) filter (where `+ticketspkg.AtRiskFilter+` and not (`+ticketspkg.BreachedFilter+`)),
+        count(*) filter (where `+ticketspkg.BreachedFilter+`)
+        from tickets t
+        join ticket_sla_clocks sc on sc.ticket_id = t.id
+        join sla_policy_versions sp on sp.id = sc.policy_version_id`).Scan(&o.SLAAtRisk, &o.SLABreached)
+}
+
+func unassigned(ctx context.Context, db apppkg.DB, o *Overview) error {
+	return db.QueryRow(ctx, `select count(*) from tickets where assignee_id is null and status not in ('Resolved','Closed')`).Scan(&o.Unassigned)
+}
+
+func emailFailures(ctx context.Context, db apppkg.DB, o *Overview) error {
+	return db.QueryRow(ctx, `select count(*) from email_outbound where status = 'failed' and created_at > now() - interval '24 hours'`).Scan(&o.EmailFailures24h)
+}
+
+// activeAgents counts active agent accounts that recorded a ticket event or
+// comment within activeWindow.
+func activeAgents(ctx context.Context, db apppkg.DB, o *Overview) error {
+	since := time.Now().Add(-activeWindow)
+	return db.QueryRow(ctx, `select count(distinct u.id)
+        from users u
+        join user_roles ur on ur.user_id = u.id
+        join roles r on r.id = ur.role_id and r.name = 'agent'
+        where u.active and (
+            exists (select 1 from ticket_events e where e.actor_id = u.id and e.created_at > $1)
+            or exists (select 1 from ticket_comments tc where tc.author_id = u.id and tc.created_at > $1))`, since).Scan(&o.ActiveAgents)
+}
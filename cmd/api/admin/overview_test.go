@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestGetOverview(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	rdb.RPush(ctx, jobs.Queue, "a", "b")
+	rdb.RPush(ctx, jobs.QueueBulk, "c")
+	rdb.ZAdd(ctx, jobs.QueueDelayed, redis.Z{Score: 1, Member: "d"})
+
+	queues := [][]any{{"q1", "Service Desk", 4}, {"", "Unqueued", 2}}
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(queues) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = queues[i-1][0].(string)
+					*dest[1].(*string) = queues[i-1][1].(string)
+					*dest[2].(*int) = queues[i-1][2].(int)
+					return nil
+				},
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "sla_policy_versions"):
+					*dest[0].(*int) = 3
+					*dest[1].(*int) = 1
+				case strings.Contains(sql, "assignee_id is null"):
+					*dest[0].(*int) = 5
+				case strings.Contains(sql, "email_outbound"):
+					return errors.New("relation does not exist")
+				case strings.Contains(sql, "user_roles"):
+					*dest[0].(*int) = 7
+				}
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, rdb)
+	a.R.GET("/admin/overview", authpkg.Middleware(a), GetOverview(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/overview", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var got Overview
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if got.OpenTotal != 6 || len(got.OpenByQueue) != 2 || got.OpenByQueue[1].Name != "Unqueued" {
+		t.Fatalf("open by queue: %+v", got)
+	}
+	if got.SLAAtRisk != 3 || got.SLABreached != 1 || got.Unassigned != 5 || got.ActiveAgents != 7 {
+		t.Fatalf("unexpected counts: %+v", got)
+	}
+	if got.JobQueueDepth[jobs.Queue] != 2 || got.JobQueueDepth[jobs.QueueBulk] != 1 || got.JobQueueDepth[jobs.QueueDelayed] != 1 {
+		t.Fatalf("queue depth: %v", got.JobQueueDepth)
+	}
+	if got.Errors["email_failures_24h"] == "" || len(got.Errors) != 1 {
+		t.Fatalf("expected email section error only, got %v", got.Errors)
+	}
+}
@@ -0,0 +1,166 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/cron"
+)
+
+// JobSchedule is when one of the worker's recurring tasks runs and how its
+// last run went.
+type JobSchedule struct {
+	Name           string     `json:"name"`
+	Cron           string     `json:"cron"`
+	Timezone       string     `json:"timezone"`
+	Enabled        bool       `json:"enabled"`
+	Description    string     `json:"description"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	LastFinishedAt *time.Time `json:"last_finished_at,omitempty"`
+	LastDurationMS *int64     `json:"last_duration_ms,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+const jobScheduleCols = `name, cron, timezone, enabled, description, next_run_at, last_run_at, last_finished_at, last_duration_ms, last_error, updated_at`
+
+func scanJobSchedule(row pgx.Row) (JobSchedule, error) {
+	var s JobSchedule
+	err := row.Scan(&s.Name, &s.Cron, &s.Timezone, &s.Enabled, &s.Description, &s.NextRunAt, &s.LastRunAt, &s.LastFinishedAt, &s.LastDurationMS, &s.LastError, &s.UpdatedAt)
+	return s, err
+}
+
+// ListJobSchedules returns the worker's recurring tasks by name.
+func ListJobSchedules(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := []JobSchedule{}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"schedules": out})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+jobScheduleCols+` from job_schedules order by name`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list schedules", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s, err := scanJobSchedule(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list schedules", nil)
+				return
+			}
+			out = append(out, s)
+		}
+		if err := rows.Err(); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list schedules", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"schedules": out})
+	}
+}
+
+// UpdateJobSchedule changes a task's cron expression, time zone or whether
+// it runs. Its next run is recomputed from the new settings.
+func UpdateJobSchedule(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Cron     *string `json:"cron"`
+			Timezone *string `json:"timezone"`
+			Enabled  *bool   `json:"enabled"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database not configured", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		cur, err := scanJobSchedule(a.DB.QueryRow(ctx, `select `+jobScheduleCols+` from job_schedules where name=$1`, c.Param("name")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "schedule not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load schedule", nil)
+			return
+		}
+		next := cur
+		if in.Cron != nil {
+			next.Cron = *in.Cron
+		}
+		if in.Timezone != nil {
+			next.Timezone = *in.Timezone
+		}
+		if in.Enabled != nil {
+			next.Enabled = *in.Enabled
+		}
+		sched, err := cron.Parse(next.Cron)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_cron", err.Error(), map[string]string{"cron": "invalid"})
+			return
+		}
+		loc, err := time.LoadLocation(next.Timezone)
+		if err != nil || next.Timezone == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_timezone", "unknown time zone", map[string]string{"timezone": "invalid"})
+			return
+		}
+		at := sched.Next(time.Now().In(loc))
+		if at.IsZero() {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_cron", "the expression never fires", map[string]string{"cron": "invalid"})
+			return
+		}
+		next.NextRunAt = &at
+		s, err := scanJobSchedule(a.DB.QueryRow(ctx, `update job_schedules set cron=$2, timezone=$3, enabled=$4, next_run_at=$5,
+            updated_by=$6, updated_at=now() where name=$1 returning `+jobScheduleCols,
+			cur.Name, next.Cron, next.Timezone, next.Enabled, next.NextRunAt, authpkg.Actor(c).DBID()))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update schedule", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "job_schedule", s.Name, "job_schedule_updated", map[string]any{
+			"before": map[string]any{"cron": cur.Cron, "timezone": cur.Timezone, "enabled": cur.Enabled},
+			"after":  map[string]any{"cron": s.Cron, "timezone": s.Timezone, "enabled": s.Enabled},
+		}); err != nil {
+			log.Error().Err(err).Msg("audit job schedule update")
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// RunJobSchedule makes an enabled task due now, so the worker queues it
+// within its next scheduling pass.
+func RunJobSchedule(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database not configured", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		s, err := scanJobSchedule(a.DB.QueryRow(ctx, `update job_schedules set next_run_at=now() where name=$1 and enabled
+            returning `+jobScheduleCols, c.Param("name")))
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "schedule not found or disabled", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to run schedule", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "job_schedule", s.Name, "job_schedule_run", nil); err != nil {
+			log.Error().Err(err).Msg("audit job schedule run")
+		}
+		c.JSON(http.StatusAccepted, s)
+	}
+}
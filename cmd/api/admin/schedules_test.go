@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestUpdateJobSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var updated []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != "audit_export" {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string) = "audit_export"
+				*dest[1].(*string) = "0 3 * * *"
+				*dest[2].(*string) = "UTC"
+				*dest[3].(*bool) = true
+				if strings.HasPrefix(sql, "update") {
+					updated = args
+					*dest[1].(*string) = args[1].(string)
+					*dest[2].(*string) = args[2].(string)
+					*dest[3].(*bool) = args[3].(bool)
+					*dest[5].(**time.Time) = args[4].(*time.Time)
+				}
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PATCH("/admin/schedules/:name", UpdateJobSchedule(a))
+
+	for body, code := range map[string]int{
+		`{"cron":"61 * * * *"}`:    http.StatusBadRequest,
+		`{"cron":"0 0 30 2 *"}`:    http.StatusBadRequest,
+		`{"timezone":"Mars/Base"}`: http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/admin/schedules/audit_export", strings.NewReader(body)))
+		if rr.Code != code {
+			t.Fatalf("%s: expected %d, got %d", body, code, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/admin/schedules/missing", strings.NewReader(`{"enabled":false}`)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	if updated != nil {
+		t.Fatalf("rejected changes were saved: %v", updated)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/admin/schedules/audit_export", strings.NewReader(`{"cron":"30 1 * * 0","timezone":"Europe/Berlin"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var s JobSchedule
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	if s.Cron != "30 1 * * 0" || !s.Enabled || s.NextRunAt == nil {
+		t.Fatalf("unexpected schedule %+v", s)
+	}
+	if at := s.NextRunAt.In(berlin); at.Weekday() != time.Sunday || at.Hour() != 1 || at.Minute() != 30 {
+		t.Fatalf("next run = %v", at)
+	}
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// errReindexRunning aborts queueing while another reindex is unfinished.
+var errReindexRunning = errors.New("reindex in progress")
+
+// Reindex queues a rebuild of the search indexes. mode is "incremental"
+// (the default), which only rebuilds indexes that are missing or invalid,
+// or "full". The worker records progress on the returned job run, which
+// GET /admin/jobs/:id reports. Only one reindex runs at a time; runs left
+// unfinished for six hours, e.g. by a worker crash, no longer count.
+func Reindex(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := struct {
+			Mode string `json:"mode"`
+		}{Mode: jobs.SearchReindexIncremental}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+				return
+			}
+		}
+		if in.Mode != jobs.SearchReindexFull && in.Mode != jobs.SearchReindexIncremental {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"mode": "must be full or incremental"})
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database not configured", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		params, _ := json.Marshal(in)
+		var r JobRun
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var busy bool
+			if err := tx.QueryRow(ctx, `select exists(select 1 from job_runs where job = $1 and status in ('queued', 'running')
+                and created_at > now() - interval '6 hours')`, jobs.TypeSearchReindex).Scan(&busy); err != nil {
+				return err
+			}
+			if busy {
+				return errReindexRunning
+			}
+			var err error
+			r, err = scanJobRun(tx.QueryRow(ctx, `insert into job_runs (job, trigger, params, requested_by)
+                values ($1, 'manual', $2::jsonb, $3) returning `+jobRunCols+`summary, error`,
+				jobs.TypeSearchReindex, string(params), authpkg.Actor(c).DBID()))
+			if err != nil {
+				return err
+			}
+			return outbox.AddJob(ctx, tx, jobs.TypeSearchReindex+":"+r.ID, r.ID, jobs.TypeSearchReindex, jobs.SearchReindex{RunID: r.ID, Mode: in.Mode})
+		})
+		if errors.Is(err, errReindexRunning) {
+			apppkg.AbortError(c, http.StatusConflict, "reindex_in_progress", "a search reindex is already queued or running", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to queue reindex", nil)
+			return
+		}
+		c.JSON(http.StatusAccepted, r)
+	}
+}
@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestReindex(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	busy := true
+	var queued []byte
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "select exists") {
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*bool) = busy
+					return nil
+				}}
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "11111111-1111-1111-1111-111111111111"
+				*dest[1].(*string) = args[0].(string)
+				*dest[2].(*string) = "queued"
+				*dest[3].(*string) = "manual"
+				*dest[4].(*[]byte) = []byte(args[1].(string))
+				*dest[6].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "outbox") {
+				queued = []byte(args[2].(string))
+			}
+			return pgconn.NewCommandTag("INSERT 0 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/admin/search/reindex", Reindex(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/search/reindex", strings.NewReader(`{"mode":"partial"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown mode, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/search/reindex", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 while a reindex runs, got %d", rr.Code)
+	}
+
+	busy = false
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/search/reindex", strings.NewReader(`{"mode":"full"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var run JobRun
+	if err := json.Unmarshal(rr.Body.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+	job, err := jobs.Decode(queued)
+	if err != nil {
+		t.Fatalf("decode queued job: %v", err)
+	}
+	var payload jobs.SearchReindex
+	if err := json.Unmarshal(job.Data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != jobs.TypeSearchReindex || payload.RunID != run.ID || payload.Mode != jobs.SearchReindexFull {
+		t.Fatalf("unexpected queued job %+v %+v", job, payload)
+	}
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// ProvisionStorage creates the active object store buckets, the default
+// and any data class's own, if they are missing and applies the configured
+// versioning, lifecycle and encryption, as the API does at startup. It is
+// for buckets configured at runtime in the storage settings, or created or
+// changed by hand since. The filesystem store needs no provisioning.
+func ProvisionStorage(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		store, bucket := a.ResolveStore(ctx)
+		if store == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "object store not configured", nil)
+			return
+		}
+		mw, ok := store.(*apppkg.MinioWrapper)
+		if !ok {
+			apppkg.AbortError(c, http.StatusConflict, "not_s3", "the object store is not S3-compatible", nil)
+			return
+		}
+		oc, cancel := a.ObjCtx(ctx)
+		defer cancel()
+		out, err := s3.ProvisionLayout(oc, mw.Client, a.Cfg.Storage, bucket, a.Cfg.StoragePolicy)
+		if err != nil {
+			log.Error().Err(err).Msg("provision buckets")
+			apppkg.AbortError(c, http.StatusBadGateway, "provision_failed", err.Error(), nil)
+			return
+		}
+		if a.DB != nil {
+			if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "storage", bucket, "storage_provisioned", map[string]any{"buckets": out}); err != nil {
+				log.Error().Err(err).Msg("audit storage provision")
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"buckets": out})
+	}
+}
@@ -0,0 +1,31 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+func TestProvisionStorageUnsupported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		store apppkg.ObjectStore
+		want  int
+	}{
+		{nil, http.StatusServiceUnavailable},
+		{&apppkg.FsObjectStore{Base: t.TempDir()}, http.StatusConflict},
+	}
+	for _, tc := range cases {
+		a := apppkg.NewApp(apppkg.Config{Env: "test", MinIOBucket: "attachments"}, nil, nil, tc.store, nil)
+		a.R.POST("/admin/storage/provision", ProvisionStorage(a))
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/storage/provision", nil))
+		if rr.Code != tc.want {
+			t.Fatalf("store %T: expected %d, got %d: %s", tc.store, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Package announcements serves admin-managed banners, such as
+// planned-maintenance notices, to agents and the portal while they are
+// active. Clients fetch the current banners or keep a stream open to get
+// them as they change.
+package announcements
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// Severities and audiences an announcement may have.
+var (
+	Severities = []string{"info", "warning", "critical"}
+	Audiences  = []string{"all", "staff", "requesters"}
+)
+
+const (
+	// PollInterval is how often Stream checks for changed announcements.
+	PollInterval = 5 * time.Second
+	heartbeat    = 25 * time.Second
+)
+
+// Announcement is a banner shown to its audience between StartsAt and
+// EndsAt, or indefinitely without EndsAt.
+type Announcement struct {
+	ID       string     `json:"id"`
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Severity string     `json:"severity"`
+	Audience string     `json:"audience"`
+	StartsAt time.Time  `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	// State is scheduled, active or ended.
+	State     string    `json:"state"`
+	CreatedBy *string   `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// state is the SQL for Announcement.State.
+const state = `case when starts_at > now() then 'scheduled' when ends_at <= now() then 'ended' else 'active' end`
+
+const cols = `id::text, title, body, severity, audience, starts_at, ends_at, ` + state + `, created_by::text, created_at, updated_at`
+
+// activeFor matches the announcements in force now for an audience ($1).
+const activeFor = `starts_at <= now() and (ends_at is null or ends_at > now()) and audience in ('all', $1)`
+
+func scan(row pgx.Row) (Announcement, error) {
+	var n Announcement
+	err := row.Scan(&n.ID, &n.Title, &n.Body, &n.Severity, &n.Audience, &n.StartsAt, &n.EndsAt, &n.State, &n.CreatedBy, &n.CreatedAt, &n.UpdatedAt)
+	return n, err
+}
+
+// audience is the audience the caller belongs to.
+func audience(c *gin.Context) string {
+	if authpkg.IsStaff(c) {
+		return "staff"
+	}
+	return "requesters"
+}
+
+func active(c *gin.Context, db apppkg.DB) ([]Announcement, error) {
+	rows, err := db.Query(c.Request.Context(), `select `+cols+` from announcements where `+activeFor+`
+        order by case severity when 'critical' then 0 when 'warning' then 1 else 2 end, starts_at desc`, audience(c))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Announcement{}
+	for rows.Next() {
+		n, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
+
+// List returns the announcements in force for the caller, most severe
+// first.
+func List(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out, err := active(c, a.DB)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list announcements", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Stream pushes the caller's announcements using Server-Sent Events: once
+// on connect and again whenever they change, including when one starts or
+// ends. Heartbeat comments keep idle connections open.
+func Stream(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		c.Writer.Header().Set("Connection", "keep-alive")
+		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+
+		flusher, ok := c.Writer.(http.Flusher)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx := c.Request.Context()
+
+		var last []byte
+		send := func() {
+			out, err := active(c, a.DB)
+			if err != nil {
+				// Keep the connection; clients show the last list.
+				return
+			}
+			b, _ := json.Marshal(out)
+			if last != nil && string(b) == string(last) {
+				return
+			}
+			last = b
+			fmt.Fprintf(c.Writer, "event: announcements\ndata: %s\n\n", b)
+			flusher.Flush()
+		}
+
+		send()
+		poll := time.NewTicker(PollInterval)
+		heart := time.NewTicker(heartbeat)
+		defer poll.Stop()
+		defer heart.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				send()
+			case <-heart.C:
+				fmt.Fprint(c.Writer, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// AdminList returns every announcement, newest start first; state=
+// filters by scheduled, active or ended. Requires admin.
+func AdminList(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := c.Query("state")
+		if filter != "" && !slices.Contains([]string{"scheduled", "active", "ended"}, filter) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "state must be scheduled, active or ended", nil)
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+cols+` from announcements
+            where $1 = '' or `+state+` = $1 order by starts_at desc limit 200`, filter)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list announcements", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Announcement{}
+		for rows.Next() {
+			n, err := scan(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list announcements", nil)
+				return
+			}
+			out = append(out, n)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// input is the writable part of an announcement. Updates start from the
+// stored announcement so only the fields present in the body change.
+type input struct {
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Severity string     `json:"severity"`
+	Audience string     `json:"audience"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// check normalizes in and returns the problems with it by field.
+func (in *input) check() map[string]string {
+	errs := map[string]string{}
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		errs["title"] = "required"
+	}
+	if !slices.Contains(Severities, in.Severity) {
+		errs["severity"] = "must be info, warning or critical"
+	}
+	if !slices.Contains(Audiences, in.Audience) {
+		errs["audience"] = "must be all, staff or requesters"
+	}
+	if in.StartsAt == nil {
+		errs["starts_at"] = "required"
+	} else if in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		errs["ends_at"] = "must be after starts_at"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func auditFields(n Announcement) map[string]any {
+	return map[string]any{"title": n.Title, "body": n.Body, "severity": n.Severity, "audience": n.Audience, "starts_at": n.StartsAt, "ends_at": n.EndsAt}
+}
+
+// Create adds an announcement, starting now unless starts_at says
+// otherwise. Requires admin.
+func Create(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		in := input{Severity: "info", Audience: "all", StartsAt: &now}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var n Announcement
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			n, err = scan(tx.QueryRow(ctx, `insert into announcements (title, body, severity, audience, starts_at, ends_at, created_by)
+                values ($1, $2, $3, $4, $5, $6, $7) returning `+cols, in.Title, in.Body, in.Severity, in.Audience, *in.StartsAt, in.EndsAt, act.DBID()))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "announcement", n.ID, "announcement_created", auditFields(n))
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create announcement", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, n)
+	}
+}
+
+// Update changes an announcement; only the fields present change and a
+// null ends_at keeps it up until removed. Ending an announcement early is
+// an update of ends_at. Requires admin.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "announcement not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, n Announcement
+		var errs map[string]string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			if before, err = scan(tx.QueryRow(ctx, `select `+cols+` from announcements where id = $1 for update`, c.Param("id"))); err != nil {
+				return err
+			}
+			in := input{Title: before.Title, Body: before.Body, Severity: before.Severity, Audience: before.Audience, StartsAt: &before.StartsAt, EndsAt: before.EndsAt}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			n, err = scan(tx.QueryRow(ctx, `update announcements set title = $2, body = $3, severity = $4, audience = $5,
+                starts_at = $6, ends_at = $7, updated_at = now() where id = $1 returning `+cols,
+				before.ID, in.Title, in.Body, in.Severity, in.Audience, *in.StartsAt, in.EndsAt))
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "announcement", n.ID, "announcement_updated", audit.Diff(auditFields(before), auditFields(n)))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "announcement not found", nil)
+			return
+		}
+		if errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update announcement", nil)
+			return
+		}
+		c.JSON(http.StatusOK, n)
+	}
+}
+
+// Delete removes an announcement. Requires admin.
+func Delete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "announcement not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			n, err := scan(tx.QueryRow(ctx, `delete from announcements where id = $1 returning `+cols, c.Param("id")))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "announcement", n.ID, "announcement_deleted", auditFields(n))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "announcement not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete announcement", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
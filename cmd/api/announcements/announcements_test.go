@@ -0,0 +1,65 @@
+package announcements
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestInputCheck(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	before := start.Add(-time.Hour)
+	in := input{Title: " ", Severity: "urgent", Audience: "everyone", StartsAt: &start, EndsAt: &before}
+	errs := in.check()
+	for _, field := range []string{"title", "severity", "audience", "ends_at"} {
+		if errs[field] == "" {
+			t.Fatalf("missing %s error: %v", field, errs)
+		}
+	}
+	in = input{Title: " Maintenance ", Severity: "warning", Audience: "staff", StartsAt: &start}
+	if errs := in.check(); errs != nil {
+		t.Fatalf("valid input rejected: %v", errs)
+	}
+	if in.Title != "Maintenance" {
+		t.Fatalf("title not trimmed: %q", in.Title)
+	}
+}
+
+func TestListAudience(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		roles []string
+		want  string
+	}{
+		{[]string{"agent"}, "staff"},
+		{[]string{"requester"}, "requesters"},
+	} {
+		var got any
+		db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			got = args[0]
+			return &testutil.MockRows{}, nil
+		}}
+		a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+		r := gin.New()
+		r.GET("/announcements", func(c *gin.Context) {
+			c.Set("user", authpkg.AuthUser{ID: "u1", Roles: tc.roles})
+		}, List(a))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/announcements", nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != "[]" {
+			t.Fatalf("%v: %d %s", tc.roles, rr.Code, rr.Body.String())
+		}
+		if got != tc.want {
+			t.Fatalf("%v: audience = %v, want %s", tc.roles, got, tc.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/url"
 	"os"
@@ -14,6 +15,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mark3748/helpdesk-go/internal/s3"
 	"github.com/minio/minio-go/v7"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
@@ -37,6 +39,11 @@ type Config struct {
 	MinIOSecret         string
 	MinIOBucket         string
 	MinIOUseSSL         bool
+	// StoragePolicy is the bucket setup POST /admin/storage/provision applies.
+	StoragePolicy s3.Policy
+	// Storage places each data class in a bucket of its own or under a
+	// prefix of MinIOBucket; nil is s3.DefaultLayout.
+	Storage s3.Layout
 	// Testing helpers
 	TestBypassAuth bool
 	// Local auth
@@ -51,6 +58,43 @@ type Config struct {
 	RateLimitBurst  int
 	// Timeouts (used by modular handlers where applicable)
 	ObjectStoreTimeoutMS int
+	// AttachmentMaxBytes caps ticket attachment uploads; 0 means no limit.
+	AttachmentMaxBytes int64
+	// RateLimits are the per-minute request limits by route group (login,
+	// tickets, attachments); groups without a limit are absent.
+	RateLimits map[string]int
+	// WebAuthn relying party for passkey login under local auth; passkeys
+	// are disabled while WebAuthnRPID is empty.
+	WebAuthnRPID        string
+	WebAuthnRPName      string
+	WebAuthnOrigins     []string
+	WebAuthnAttestation string
+	// Reply suggestions: "openai", "local" or empty to disable.
+	SuggestionsProvider string
+	SuggestionsBaseURL  string
+	SuggestionsAPIKey   string
+	SuggestionsModel    string
+	// Comment translation: "deepl", "libretranslate" or empty to disable.
+	TranslationProvider string
+	TranslationURL      string
+	TranslationAPIKey   string
+	// PhoneIntakeToken authenticates the phone intake webhook; empty
+	// disables it.
+	PhoneIntakeToken string
+	// CategorizeMinConfidence is the worker's auto-categorization threshold,
+	// reported by the rule tester; 0 means the default.
+	CategorizeMinConfidence float64
+	// PublicURL is the external address used in emailed links.
+	PublicURL string
+	// UnverifiedPolicy is "allow", "flag" or "hold": what happens to tickets
+	// from unverified requesters in queues without their own policy.
+	UnverifiedPolicy string
+	// ReadReceipts lists the enabled read receipt sources, "portal" and
+	// "email".
+	ReadReceipts []string
+	// CSATThrottleDays is the minimum number of days between CSAT surveys
+	// sent to the same requester; 0 surveys every resolution.
+	CSATThrottleDays int
 }
 
 // GetEnv returns the environment variable value or default.
@@ -105,6 +149,23 @@ type DB interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
+// InTx runs fn in a transaction on db and commits when fn succeeds. Test
+// doubles that return a nil Tx from Begin have fn run directly against db.
+func InTx(ctx context.Context, db DB, fn func(DB) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fn(db)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 // ObjectStore wraps the subset of MinIO we need for tests.
 type ObjectStore interface {
 	PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (minio.UploadInfo, error)
@@ -135,6 +196,10 @@ func (f *FsObjectStore) PutObject(ctx context.Context, bucketName, objectName st
 	if !strings.HasPrefix(clean, dir+string(os.PathSeparator)) && clean != dir {
 		return minio.UploadInfo{}, os.ErrPermission
 	}
+	// Keys may have prefixes, such as exports/.
+	if err := os.MkdirAll(filepath.Dir(clean), 0o755); err != nil {
+		return minio.UploadInfo{}, err
+	}
 	tmp := clean + ".tmp"
 	out, err := os.Create(tmp)
 	if err != nil {
@@ -199,17 +264,66 @@ func (f *FsObjectStore) StatObject(ctx context.Context, bucketName, objectName s
 	return minio.ObjectInfo{Key: objectName, Size: fi.Size()}, nil
 }
 
+// OpenObject opens a stored object for reading. Only MinIO and filesystem
+// stores support reads; other stores return an error.
+func OpenObject(ctx context.Context, store ObjectStore, bucketName, objectName string) (io.ReadCloser, error) {
+	switch s := store.(type) {
+	case *MinioWrapper:
+		return s.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	case *FsObjectStore:
+		dir := filepath.Clean(s.Base)
+		if bucketName != "" {
+			dir = filepath.Join(dir, bucketName)
+		}
+		clean := filepath.Clean(filepath.Join(dir, objectName))
+		if !strings.HasPrefix(clean, dir+string(os.PathSeparator)) {
+			return nil, os.ErrPermission
+		}
+		return os.Open(clean)
+	}
+	return nil, fmt.Errorf("object store does not support reads")
+}
+
 // MinioWrapper adapts the minio.Client to our ObjectStore interface.
 type MinioWrapper struct {
 	*minio.Client
+	// Encryption maps buckets to the s3.Location.Encryption requested on
+	// every upload to them (see s3.Layout.Encryption).
+	Encryption map[string]string
+}
+
+// PutObject uploads an object, requesting the bucket's encryption unless
+// opts already carries one.
+func (m *MinioWrapper) PutObject(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	if opts.ServerSideEncryption == nil {
+		opts.ServerSideEncryption = s3.ServerSide(m.Encryption[bucketName])
+	}
+	return m.Client.PutObject(ctx, bucketName, objectName, reader, objectSize, opts)
 }
 
 func (m *MinioWrapper) PresignedPutObject(ctx context.Context, bucketName, objectName string, expiry time.Duration, contentType string) (*url.URL, error) {
+	var params url.Values
+	if contentType != "" {
+		// Include Content-Type in the signature headers
+		params = url.Values{"Content-Type": []string{contentType}}
+	}
+	// The uploader must send UploadHeaders' encryption headers as signed.
+	return m.Client.PresignHeader(ctx, "PUT", bucketName, objectName, expiry, params, s3.EncryptionHeaders(m.Encryption[bucketName]))
+}
+
+// UploadHeaders returns the headers a client must send with a presigned
+// upload to bucket in store: its Content-Type and any encryption headers.
+func UploadHeaders(store ObjectStore, bucket, contentType string) map[string]string {
+	out := map[string]string{}
 	if contentType != "" {
-		// Use Presign to include Content-Type in the signature headers
-		return m.Client.Presign(ctx, "PUT", bucketName, objectName, expiry, url.Values{"Content-Type": []string{contentType}})
+		out["Content-Type"] = contentType
+	}
+	if mw, ok := store.(*MinioWrapper); ok {
+		for k, v := range s3.EncryptionHeaders(mw.Encryption[bucket]) {
+			out[k] = v[0]
+		}
 	}
-	return m.Client.PresignedPutObject(ctx, bucketName, objectName, expiry)
+	return out
 }
 
 // App wires dependencies and the Gin router.
@@ -271,3 +385,11 @@ func (a *App) ResolveStore(ctx context.Context) (ObjectStore, string) {
 	// Not dynamic
 	return a.M, a.Cfg.MinIOBucket
 }
+
+// ResolveClass returns the effective ObjectStore and the location of a data
+// class. Classes without a bucket of their own use the bucket ResolveStore
+// returns, so they follow a bucket set in the storage settings.
+func (a *App) ResolveClass(ctx context.Context, c s3.Class) (ObjectStore, s3.Location) {
+	store, bucket := a.ResolveStore(ctx)
+	return store, a.Cfg.Storage.Location(c, bucket)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/url"
 	"strings"
 	"sync"
@@ -20,6 +21,10 @@ import (
 type DynamicObjectStore struct {
 	DB       DB
 	Fallback ObjectStore
+	// Encryption is requested on uploads as in MinioWrapper.Encryption. A
+	// bucket from the settings takes DefaultBucket's entry.
+	Encryption    map[string]string
+	DefaultBucket string
 
 	mu sync.RWMutex
 	// ...
@@ -44,7 +49,7 @@ func (d *DynamicObjectStore) getClient(ctx context.Context) (ObjectStore, string
 	// Note: using captured values is safe; state may have changed but this is just an optimization
 	if time.Since(last) < 5*time.Second {
 		if client != nil {
-			return &MinioWrapper{client}, bucket, nil
+			return d.wrap(client, bucket), bucket, nil
 		}
 		// Return fallback during negative cache window
 		return d.Fallback, "", nil
@@ -56,7 +61,7 @@ func (d *DynamicObjectStore) getClient(ctx context.Context) (ObjectStore, string
 	// Double check with write lock (re-read actual values to avoid race)
 	if time.Since(d.lastChecked) < 5*time.Second {
 		if d.cached != nil {
-			return &MinioWrapper{d.cached}, d.cachedBucket, nil
+			return d.wrap(d.cached, d.cachedBucket), d.cachedBucket, nil
 		}
 		return d.Fallback, "", nil
 	}
@@ -136,7 +141,7 @@ func (d *DynamicObjectStore) getClient(ctx context.Context) (ObjectStore, string
 	if d.cached != nil && d.cachedCfg == cfgStr {
 		d.lastChecked = time.Now()
 		d.cachedBucket = bucket
-		return &MinioWrapper{d.cached}, bucket, nil
+		return d.wrap(d.cached, bucket), bucket, nil
 	}
 
 	mc, err := minio.New(endpoint, &minio.Options{
@@ -159,7 +164,17 @@ func (d *DynamicObjectStore) getClient(ctx context.Context) (ObjectStore, string
 	d.cachedCfg = cfgStr
 	d.lastChecked = time.Now()
 
-	return &MinioWrapper{mc}, bucket, nil
+	return d.wrap(mc, bucket), bucket, nil
+}
+
+// wrap returns mc as the store for bucket, the bucket from the settings.
+func (d *DynamicObjectStore) wrap(mc *minio.Client, bucket string) *MinioWrapper {
+	enc := d.Encryption
+	if e, ok := d.Encryption[d.DefaultBucket]; ok && bucket != d.DefaultBucket {
+		enc = maps.Clone(d.Encryption)
+		enc[bucket] = e
+	}
+	return &MinioWrapper{Client: mc, Encryption: enc}
 }
 
 // DynamicObjectStore may override the caller-provided bucket name with a bucket
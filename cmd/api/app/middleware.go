@@ -8,16 +8,19 @@ import (
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"golang.org/x/time/rate"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
 )
 
 // RequestID assigns a UUID to each request and stores it in the context and response headers.
+// Jobs queued while handling the request carry it as their trace ID.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := uuid.New().String()
 		c.Set("request_id", id)
 		c.Writer.Header().Set("X-Request-ID", id)
 		logger := log.With().Str("request_id", id).Logger()
-		ctx := logger.WithContext(c.Request.Context())
+		ctx := jobs.WithTraceID(logger.WithContext(c.Request.Context()), id)
 		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
@@ -90,14 +90,13 @@ func PresignAssetUpload(a *app.App) gin.HandlerFunc {
 		if store != nil {
 			if mw, ok := store.(*app.MinioWrapper); ok {
 				// Build presigned PUT URL via s3 service helper
-				svc := s3svc.Service{Client: mw.Client, Bucket: bucket, MaxTTL: time.Minute}
+				svc := s3svc.Service{Client: mw.Client, Bucket: bucket, MaxTTL: time.Minute, Encryption: mw.Encryption[bucket]}
 				url, err := svc.PresignPut(c.Request.Context(), objectKey, "application/octet-stream", time.Minute)
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create presigned URL"})
 					return
 				}
-				headers := map[string]string{}
-				c.JSON(http.StatusCreated, gin.H{"upload_url": url, "headers": headers, "attachment_id": objectKey})
+				c.JSON(http.StatusCreated, gin.H{"upload_url": url, "headers": app.UploadHeaders(store, bucket, ""), "attachment_id": objectKey})
 				return
 			}
 		}
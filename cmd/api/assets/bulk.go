@@ -28,6 +28,7 @@ type BulkOperation struct {
 	SuccessCount   int                    `json:"success_count" db:"success_count"`
 	ErrorCount     int                    `json:"error_count" db:"error_count"`
 	Errors         []BulkError            `json:"errors" db:"errors"`
+	Conflicts      []ImportConflict       `json:"conflicts" db:"conflicts"`
 	Results        map[string]interface{} `json:"results" db:"results"`
 	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
 	StartedAt      *time.Time             `json:"started_at" db:"started_at"`
@@ -71,6 +72,8 @@ type ImportPreview struct {
 	Columns          []string                 `json:"columns"`
 	SampleData       []map[string]interface{} `json:"sample_data"`
 	ValidationErrors []BulkError              `json:"validation_errors"`
+	ConflictRows     int                      `json:"conflict_rows"`
+	Conflicts        []ImportConflict         `json:"conflicts"`
 	Suggestions      []string                 `json:"suggestions"`
 }
 
@@ -85,8 +88,9 @@ type ExportRequest struct {
 
 // CSV Import Functions
 
-// PreviewImport analyzes a CSV file and provides a preview
-func (s *Service) PreviewImport(ctx context.Context, file multipart.File, filename string) (*ImportPreview, error) {
+// PreviewImport analyzes a CSV file and provides a preview. Conflicts show
+// what importing with the duplicates strategy would do to each duplicate.
+func (s *Service) PreviewImport(ctx context.Context, file multipart.File, filename string, duplicates string) (*ImportPreview, error) {
 	reader := csv.NewReader(file)
 	reader.FieldsPerRecord = -1 // Allow variable number of fields
 
@@ -100,8 +104,10 @@ func (s *Service) PreviewImport(ctx context.Context, file multipart.File, filena
 		Columns:          headers,
 		SampleData:       make([]map[string]interface{}, 0),
 		ValidationErrors: make([]BulkError, 0),
+		Conflicts:        make([]ImportConflict, 0),
 		Suggestions:      make([]string, 0),
 	}
+	seen := newImportSeen()
 
 	// Validate headers
 	requiredColumns := []string{"asset_tag", "name"}
@@ -162,6 +168,17 @@ func (s *Service) PreviewImport(ctx context.Context, file multipart.File, filena
 			preview.ErrorRows++
 		} else {
 			preview.ValidRows++
+			conflict, err := s.detectDuplicate(ctx, seen, rowNum, importRequest(rowData))
+			if err != nil {
+				return nil, err
+			}
+			if conflict != nil {
+				if conflict.ExistingAssetID != nil {
+					conflict.Resolution = duplicateResolution(duplicates)
+				}
+				preview.Conflicts = append(preview.Conflicts, *conflict)
+				preview.ConflictRows++
+			}
 		}
 
 		// Add to sample data
@@ -224,10 +241,18 @@ func (s *Service) processImport(ctx context.Context, operationID uuid.UUID, file
 		return
 	}
 
+	strategy, _ := operation.Parameters["duplicates"].(string)
+	if strategy == "" {
+		strategy = DuplicateSkip
+	}
+
 	rowNum := 1
 	successCount := 0
 	errorCount := 0
 	errors := make([]BulkError, 0)
+	conflicts := make([]ImportConflict, 0)
+	resolved := map[string]int{}
+	seen := newImportSeen()
 
 	// Start transaction for batch processing
 	tx, err := s.db.Begin(ctx)
@@ -260,13 +285,34 @@ func (s *Service) processImport(ctx context.Context, operationID uuid.UUID, file
 			}
 		}
 
-		// Validate and create asset
-		asset, rowErrors := s.createAssetFromImportRow(ctx, tx, rowData, operation.RequestedBy)
-		if len(rowErrors) > 0 {
+		// Validate, then resolve duplicates or create the asset
+		if rowErrors := s.validateImportRow(rowData, rowNum); len(rowErrors) > 0 {
 			errors = append(errors, rowErrors...)
 			errorCount++
-		} else if asset != nil {
-			successCount++
+		} else if conflict, err := s.detectDuplicate(ctx, seen, rowNum, importRequest(rowData)); err != nil {
+			errors = append(errors, BulkError{Row: rowNum, Message: err.Error()})
+			errorCount++
+		} else if conflict != nil {
+			if err := s.resolveDuplicate(ctx, conflict, importRequest(rowData), strategy, operation); err != nil {
+				errors = append(errors, BulkError{Row: rowNum, Message: err.Error(), Data: rowData})
+				errorCount++
+			} else if conflict.Resolution == "updated" || conflict.Resolution == "merged" {
+				successCount++
+			}
+			resolved[conflict.Resolution]++
+			conflicts = append(conflicts, *conflict)
+		} else {
+			asset, rowErrors := s.createAssetFromImportRow(ctx, tx, rowData, operation.RequestedBy)
+			if len(rowErrors) > 0 {
+				for i := range rowErrors {
+					rowErrors[i].Row = rowNum
+				}
+				errors = append(errors, rowErrors...)
+				errorCount++
+			} else if asset != nil {
+				successCount++
+				resolved["created"]++
+			}
 		}
 
 		// Update progress periodically
@@ -301,11 +347,17 @@ func (s *Service) processImport(ctx context.Context, operationID uuid.UUID, file
 	operation.SuccessCount = successCount
 	operation.ErrorCount = errorCount
 	operation.Errors = errors
+	operation.Conflicts = conflicts
 	operation.Progress = 100
 	operation.Results = map[string]interface{}{
-		"imported_assets": successCount,
-		"failed_rows":     errorCount,
-		"total_rows":      rowNum - 1,
+		"imported_assets":    successCount,
+		"created_assets":     resolved["created"],
+		"updated_assets":     resolved["updated"],
+		"merged_assets":      resolved["merged"],
+		"skipped_duplicates": resolved["skipped"],
+		"conflict_rows":      len(conflicts),
+		"failed_rows":        errorCount,
+		"total_rows":         rowNum - 1,
 	}
 
 	s.updateBulkOperation(ctx, operation)
@@ -354,6 +406,7 @@ func (s *Service) BulkUpdateAssets(ctx context.Context, req BulkUpdateRequest, u
 
 		// Build update request
 		updateReq := s.buildUpdateRequestFromMap(req.Updates)
+		updateReq.StatusReason = req.Notes
 
 		// Update asset
 		_, err = s.UpdateAsset(ctx, assetID, updateReq, updatedBy)
@@ -574,7 +627,7 @@ func (s *Service) validateImportRow(rowData map[string]interface{}, rowNum int)
 
 	// Validate status if provided
 	if status, ok := rowData["status"].(string); ok && status != "" {
-		validStatuses := []string{"active", "inactive", "maintenance", "retired", "disposed"}
+		validStatuses := []string{"ordered", "received", "active", "inactive", "maintenance", "retired", "disposed"}
 		valid := false
 		for _, validStatus := range validStatuses {
 			if strings.EqualFold(status, validStatus) {
@@ -597,6 +650,23 @@ func (s *Service) validateImportRow(rowData map[string]interface{}, rowNum int)
 func (s *Service) createAssetFromImportRow(ctx context.Context, _ pgx.Tx, rowData map[string]interface{}, createdBy uuid.UUID) (*Asset, []BulkError) {
 	var errors []BulkError
 
+	req := importRequest(rowData)
+
+	// Create asset (simplified version using tx)
+	asset, err := s.CreateAsset(ctx, req, createdBy)
+	if err != nil {
+		errors = append(errors, BulkError{
+			Message: fmt.Sprintf("Failed to create asset: %v", err),
+			Data:    rowData,
+		})
+		return nil, errors
+	}
+
+	return asset, errors
+}
+
+// importRequest maps a validated import row to a create request.
+func importRequest(rowData map[string]interface{}) CreateAssetRequest {
 	// Map row data to create request
 	req := CreateAssetRequest{
 		AssetTag: rowData["asset_tag"].(string),
@@ -636,17 +706,7 @@ func (s *Service) createAssetFromImportRow(ctx context.Context, _ pgx.Tx, rowDat
 		}
 	}
 
-	// Create asset (simplified version using tx)
-	asset, err := s.CreateAsset(ctx, req, createdBy)
-	if err != nil {
-		errors = append(errors, BulkError{
-			Message: fmt.Sprintf("Failed to create asset: %v", err),
-			Data:    rowData,
-		})
-		return nil, errors
-	}
-
-	return asset, errors
+	return req
 }
 
 func (s *Service) generateImportSuggestions(preview *ImportPreview) []string {
@@ -817,19 +877,20 @@ func (s *Service) getAssetFieldValue(asset Asset, fieldName string) string {
 func (s *Service) saveBulkOperation(ctx context.Context, operation *BulkOperation) error {
 	parametersJSON, _ := json.Marshal(operation.Parameters)
 	errorsJSON, _ := json.Marshal(operation.Errors)
+	conflictsJSON := conflictsJSON(operation.Conflicts)
 	resultsJSON, _ := json.Marshal(operation.Results)
 
 	query := `
 		INSERT INTO asset_bulk_operations (
 			id, type, status, requested_by, parameters, progress, total_items,
-			processed_items, success_count, error_count, errors, results, created_at, started_at, completed_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+			processed_items, success_count, error_count, errors, conflicts, results, created_at, started_at, completed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
 
 	_, err := s.db.Exec(ctx, query,
 		operation.ID, operation.Type, operation.Status, operation.RequestedBy,
 		parametersJSON, operation.Progress, operation.TotalItems,
 		operation.ProcessedItems, operation.SuccessCount, operation.ErrorCount,
-		errorsJSON, resultsJSON, operation.CreatedAt, operation.StartedAt, operation.CompletedAt)
+		errorsJSON, conflictsJSON, resultsJSON, operation.CreatedAt, operation.StartedAt, operation.CompletedAt)
 
 	return err
 }
@@ -837,17 +898,18 @@ func (s *Service) saveBulkOperation(ctx context.Context, operation *BulkOperatio
 func (s *Service) updateBulkOperation(ctx context.Context, operation *BulkOperation) error {
 	parametersJSON, _ := json.Marshal(operation.Parameters)
 	errorsJSON, _ := json.Marshal(operation.Errors)
+	conflictsJSON := conflictsJSON(operation.Conflicts)
 	resultsJSON, _ := json.Marshal(operation.Results)
 
 	query := `
 		UPDATE asset_bulk_operations 
 		SET status = $1, parameters = $2, progress = $3, processed_items = $4,
-		    success_count = $5, error_count = $6, errors = $7, results = $8, completed_at = $9
-		WHERE id = $10`
+		    success_count = $5, error_count = $6, errors = $7, conflicts = $8, results = $9, completed_at = $10
+		WHERE id = $11`
 
 	_, err := s.db.Exec(ctx, query,
 		operation.Status, parametersJSON, operation.Progress, operation.ProcessedItems,
-		operation.SuccessCount, operation.ErrorCount, errorsJSON, resultsJSON,
+		operation.SuccessCount, operation.ErrorCount, errorsJSON, conflictsJSON, resultsJSON,
 		operation.CompletedAt, operation.ID)
 
 	return err
@@ -855,11 +917,11 @@ func (s *Service) updateBulkOperation(ctx context.Context, operation *BulkOperat
 
 func (s *Service) getBulkOperation(ctx context.Context, operationID uuid.UUID) (*BulkOperation, error) {
 	operation := &BulkOperation{}
-	var parametersJSON, errorsJSON, resultsJSON []byte
+	var parametersJSON, errorsJSON, conflictsJSON, resultsJSON []byte
 
 	query := `
 		SELECT id, type, status, requested_by, parameters, progress, total_items,
-		       processed_items, success_count, error_count, errors, results,
+		       processed_items, success_count, error_count, errors, conflicts, results,
 		       created_at, started_at, completed_at
 		FROM asset_bulk_operations WHERE id = $1`
 
@@ -867,7 +929,7 @@ func (s *Service) getBulkOperation(ctx context.Context, operationID uuid.UUID) (
 		&operation.ID, &operation.Type, &operation.Status, &operation.RequestedBy,
 		&parametersJSON, &operation.Progress, &operation.TotalItems,
 		&operation.ProcessedItems, &operation.SuccessCount, &operation.ErrorCount,
-		&errorsJSON, &resultsJSON, &operation.CreatedAt, &operation.StartedAt, &operation.CompletedAt)
+		&errorsJSON, &conflictsJSON, &resultsJSON, &operation.CreatedAt, &operation.StartedAt, &operation.CompletedAt)
 
 	if err != nil {
 		return nil, err
@@ -880,6 +942,9 @@ func (s *Service) getBulkOperation(ctx context.Context, operationID uuid.UUID) (
 	if len(errorsJSON) > 0 {
 		_ = json.Unmarshal(errorsJSON, &operation.Errors)
 	}
+	if len(conflictsJSON) > 0 {
+		_ = json.Unmarshal(conflictsJSON, &operation.Conflicts)
+	}
 	if len(resultsJSON) > 0 {
 		_ = json.Unmarshal(resultsJSON, &operation.Results)
 	}
@@ -0,0 +1,457 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+)
+
+// Consumable is a stockroom item tracked by quantity, such as toner or
+// cables, rather than as serialized assets.
+type Consumable struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	SKU               *string   `json:"sku"`
+	Unit              string    `json:"unit"`
+	Location          *string   `json:"location"`
+	Quantity          int       `json:"quantity"`
+	LowStockThreshold *int      `json:"low_stock_threshold"`
+	LowStock          bool      `json:"low_stock"`
+	RestockTeamID     *string   `json:"restock_team_id"`
+	RestockTicketID   *string   `json:"restock_ticket_id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+const consumableCols = `id::text, name, sku, unit, location, quantity, low_stock_threshold,
+        restock_team_id::text, restock_ticket_id::text, created_at, updated_at`
+
+func scanConsumable(row pgx.Row) (Consumable, error) {
+	var k Consumable
+	err := row.Scan(&k.ID, &k.Name, &k.SKU, &k.Unit, &k.Location, &k.Quantity, &k.LowStockThreshold,
+		&k.RestockTeamID, &k.RestockTicketID, &k.CreatedAt, &k.UpdatedAt)
+	k.LowStock = k.LowStockThreshold != nil && k.Quantity <= *k.LowStockThreshold
+	return k, err
+}
+
+// Consumable transaction kinds. Issues take stock out, returns and
+// restocks put it back and adjustments set it to a counted level.
+const (
+	TxIssue   = "issue"
+	TxReturn  = "return"
+	TxRestock = "restock"
+	TxAdjust  = "adjust"
+)
+
+// ConsumableTransaction is one movement of stock.
+type ConsumableTransaction struct {
+	ID           string    `json:"id"`
+	ConsumableID string    `json:"consumable_id"`
+	Kind         string    `json:"kind"`
+	Change       int       `json:"change"`
+	Balance      int       `json:"balance"`
+	TicketID     *string   `json:"ticket_id"`
+	UserID       *string   `json:"user_id"`
+	ActorID      *string   `json:"actor_id"`
+	Note         *string   `json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+const consumableTxCols = `id::text, consumable_id::text, kind, change, balance, ticket_id::text, user_id::text,
+        actor_id::text, note, created_at`
+
+func scanConsumableTx(row pgx.Row) (ConsumableTransaction, error) {
+	var t ConsumableTransaction
+	err := row.Scan(&t.ID, &t.ConsumableID, &t.Kind, &t.Change, &t.Balance, &t.TicketID, &t.UserID, &t.ActorID, &t.Note, &t.CreatedAt)
+	return t, err
+}
+
+// consumableFKFields maps foreign key constraints to the request fields
+// that name them.
+var consumableFKFields = map[string]string{
+	"consumables_restock_team_id_fkey":           "restock_team_id",
+	"consumable_transactions_ticket_id_fkey":     "ticket_id",
+	"consumable_transactions_user_id_fkey":       "user_id",
+	"consumable_transactions_consumable_id_fkey": "id",
+}
+
+// consumableError writes the response for constraint violations and
+// reports whether err was one.
+func consumableError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	if !errors.As(err, &pge) {
+		return false
+	}
+	switch pge.Code {
+	case "23505":
+		app.AbortError(c, http.StatusConflict, "duplicate", "a consumable with this name or SKU exists", nil)
+	case "23503", "22P02":
+		field := consumableFKFields[pge.ConstraintName]
+		if field == "" {
+			field = "id"
+		}
+		app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{field: "not_found"})
+	default:
+		return false
+	}
+	return true
+}
+
+// ListConsumables handles GET /consumables. low_stock=true lists only items
+// at or below their threshold.
+func ListConsumables(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+consumableCols+` from consumables
+            where not $1 or quantity <= low_stock_threshold order by name`, c.Query("low_stock") == "true")
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list consumables", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Consumable{}
+		for rows.Next() {
+			k, err := scanConsumable(rows)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list consumables", nil)
+				return
+			}
+			out = append(out, k)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateConsumable handles POST /consumables. An opening quantity is
+// recorded as a restock.
+func CreateConsumable(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name              string  `json:"name"`
+			SKU               *string `json:"sku"`
+			Unit              string  `json:"unit"`
+			Location          *string `json:"location"`
+			Quantity          int     `json:"quantity"`
+			LowStockThreshold *int    `json:"low_stock_threshold"`
+			RestockTeamID     *string `json:"restock_team_id"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		errs := map[string]string{}
+		if in.Name = strings.TrimSpace(in.Name); in.Name == "" {
+			errs["name"] = "required"
+		}
+		if in.Quantity < 0 {
+			errs["quantity"] = "must not be negative"
+		}
+		if in.LowStockThreshold != nil && *in.LowStockThreshold < 0 {
+			errs["low_stock_threshold"] = "must not be negative"
+		}
+		if len(errs) > 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if in.Unit = strings.TrimSpace(in.Unit); in.Unit == "" {
+			in.Unit = "each"
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var k Consumable
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var err error
+			k, err = scanConsumable(tx.QueryRow(ctx, `insert into consumables (name, sku, unit, location, quantity, low_stock_threshold, restock_team_id)
+                values ($1, nullif(btrim($2), ''), $3, nullif(btrim($4), ''), $5, $6, $7::uuid) returning `+consumableCols,
+				in.Name, in.SKU, in.Unit, in.Location, in.Quantity, in.LowStockThreshold, in.RestockTeamID))
+			if err != nil {
+				return err
+			}
+			if k.Quantity > 0 {
+				if _, err := tx.Exec(ctx, `insert into consumable_transactions (consumable_id, kind, change, balance, actor_id, note)
+                    values ($1, 'restock', $2, $2, $3, 'Opening stock')`, k.ID, k.Quantity, act.DBID()); err != nil {
+					return err
+				}
+			}
+			return audit.RecordDiff(ctx, tx, act, "consumable", k.ID, "consumable_created", map[string]any{
+				"name": k.Name, "quantity": k.Quantity, "low_stock_threshold": k.LowStockThreshold})
+		})
+		if consumableError(c, err) {
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create consumable", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, k)
+	}
+}
+
+// UpdateConsumable handles PATCH /consumables/:id. Only the fields present
+// change; null clears the optional ones. Quantity changes go through
+// transactions.
+func UpdateConsumable(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if _, ok := in["quantity"]; ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"quantity": "record a transaction to change stock"})
+			return
+		}
+		var name, unit *string
+		for field, dst := range map[string]**string{"name": &name, "unit": &unit} {
+			if raw, ok := in[field]; ok && (json.Unmarshal(raw, dst) != nil || *dst == nil || strings.TrimSpace(**dst) == "") {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{field: "must be a non-empty string"})
+				return
+			}
+		}
+		// Nullable fields change when present, even to null.
+		var sku, location, team *string
+		var threshold *int
+		set := map[string]bool{}
+		for field, dst := range map[string]any{"sku": &sku, "location": &location, "restock_team_id": &team, "low_stock_threshold": &threshold} {
+			raw, ok := in[field]
+			if !ok {
+				continue
+			}
+			if json.Unmarshal(raw, dst) != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{field: "invalid value"})
+				return
+			}
+			set[field] = true
+		}
+		if threshold != nil && *threshold < 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"low_stock_threshold": "must not be negative"})
+			return
+		}
+		ctx := c.Request.Context()
+		var k Consumable
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var err error
+			k, err = scanConsumable(tx.QueryRow(ctx, `update consumables set
+                name = coalesce(btrim($2), name), unit = coalesce(btrim($3), unit),
+                sku = case when $4 then nullif(btrim($5), '') else sku end,
+                location = case when $6 then nullif(btrim($7), '') else location end,
+                restock_team_id = case when $8 then $9::uuid else restock_team_id end,
+                low_stock_threshold = case when $10 then $11::int else low_stock_threshold end,
+                updated_at = now()
+                where id::text = $1 returning `+consumableCols, c.Param("id"), name, unit,
+				set["sku"], sku, set["location"], location, set["restock_team_id"], team, set["low_stock_threshold"], threshold))
+			if err != nil {
+				return err
+			}
+			changed := map[string]any{}
+			for field := range in {
+				changed[field] = json.RawMessage(in[field])
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "consumable", k.ID, "consumable_updated", changed)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "consumable not found", nil)
+			return
+		}
+		if consumableError(c, err) {
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update consumable", nil)
+			return
+		}
+		c.JSON(http.StatusOK, k)
+	}
+}
+
+// errInsufficientStock is returned for an issue of more than is in stock.
+var errInsufficientStock = errors.New("not enough in stock")
+
+// RecordConsumableTransaction handles POST /consumables/:id/transactions.
+// An issue or return may name the ticket it was for and the user who took
+// or brought back the items. When stock ends at or below the item's
+// threshold a restock ticket is opened, unless one is still open.
+func RecordConsumableTransaction(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Kind     string  `json:"kind"`
+			Quantity int     `json:"quantity"`
+			TicketID *string `json:"ticket_id"`
+			UserID   *string `json:"user_id"`
+			Note     string  `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		switch {
+		case in.Kind != TxIssue && in.Kind != TxReturn && in.Kind != TxRestock && in.Kind != TxAdjust:
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"kind": "must be issue, return, restock or adjust"})
+			return
+		case in.Kind == TxAdjust && in.Quantity < 0, in.Kind != TxAdjust && in.Quantity < 1:
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"quantity": "must be positive, or the counted stock for adjust"})
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var t ConsumableTransaction
+		var k Consumable
+		var restock *restockTicket
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var restockOpen bool
+			row := tx.QueryRow(ctx, `select `+consumableCols+`, exists(select 1 from tickets rt where rt.id = consumables.restock_ticket_id
+                    and rt.status not in ('Resolved', 'Closed'))
+                from consumables where id::text = $1 for update`, c.Param("id"))
+			err := row.Scan(&k.ID, &k.Name, &k.SKU, &k.Unit, &k.Location, &k.Quantity, &k.LowStockThreshold,
+				&k.RestockTeamID, &k.RestockTicketID, &k.CreatedAt, &k.UpdatedAt, &restockOpen)
+			if err != nil {
+				return err
+			}
+			change := in.Quantity
+			switch in.Kind {
+			case TxIssue:
+				change = -in.Quantity
+			case TxAdjust:
+				change = in.Quantity - k.Quantity
+			}
+			if k.Quantity+change < 0 {
+				return errInsufficientStock
+			}
+			k.Quantity += change
+			k.LowStock = k.LowStockThreshold != nil && k.Quantity <= *k.LowStockThreshold
+			if _, err := tx.Exec(ctx, `update consumables set quantity = $2, updated_at = now() where id::text = $1`, k.ID, k.Quantity); err != nil {
+				return err
+			}
+			t, err = scanConsumableTx(tx.QueryRow(ctx, `insert into consumable_transactions
+                    (consumable_id, kind, change, balance, ticket_id, user_id, actor_id, note)
+                values ($1, $2, $3, $4, $5::uuid, $6::uuid, $7, nullif(btrim($8), '')) returning `+consumableTxCols,
+				k.ID, in.Kind, change, k.Quantity, in.TicketID, in.UserID, act.DBID(), in.Note))
+			if err != nil {
+				return err
+			}
+			if err := audit.RecordDiff(ctx, tx, act, "consumable", k.ID, "consumable_"+in.Kind, map[string]any{
+				"change": change, "balance": k.Quantity, "ticket_id": in.TicketID, "user_id": in.UserID}); err != nil {
+				return err
+			}
+			if k.LowStock && change < 0 && !restockOpen {
+				if restock, err = openRestockTicket(ctx, tx, act, k); err != nil {
+					return err
+				}
+				if restock != nil {
+					k.RestockTicketID = &restock.ID
+				}
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.AbortError(c, http.StatusNotFound, "not_found", "consumable not found", nil)
+			return
+		case errors.Is(err, errInsufficientStock):
+			app.AbortError(c, http.StatusConflict, "insufficient_stock", fmt.Sprintf("only %d %s in stock", k.Quantity, k.Unit), nil)
+			return
+		case consumableError(c, err):
+			return
+		case err != nil:
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to record transaction", nil)
+			return
+		}
+		if restock != nil {
+			eventspkg.Emit(ctx, a.DB, restockActor, restock.ID, "ticket_created", map[string]any{"id": restock.ID})
+		}
+		c.JSON(http.StatusCreated, gin.H{"transaction": t, "consumable": k, "restock_ticket": restock})
+	}
+}
+
+// restockActor attributes restock tickets to the stockroom.
+var restockActor = actor.System("stockroom")
+
+// restockTicket is a ticket opened for low stock.
+type restockTicket struct {
+	ID     string `json:"id"`
+	Number string `json:"number"`
+}
+
+// openRestockTicket opens a ticket to restock k for its restock team, with
+// the staff member whose transaction ran the stock low as requester, and
+// links it to k. It returns nil when the actor has no email to file the
+// ticket under.
+func openRestockTicket(ctx context.Context, tx app.DB, act actor.Actor, k Consumable) (*restockTicket, error) {
+	var r restockTicket
+	err := tx.QueryRow(ctx, `with req as (
+            insert into requesters (email, name)
+            select lower(u.email), u.display_name from users u where u.id = $1 and u.email is not null
+            on conflict (email) do update set email = excluded.email
+            returning id
+        )
+        insert into tickets (number, title, description, requester_id, team_id, priority, status, source)
+        select 'HD-'||nextval('ticket_seq'), $2, $3, req.id, $4::uuid, 3, 'New', 'stockroom' from req
+        returning id::text, number`, act.DBID(),
+		fmt.Sprintf("Restock: %s", k.Name),
+		fmt.Sprintf("%s is down to %d %s, at or below its low-stock threshold of %d. Reorder it and record the delivery as a restock.",
+			k.Name, k.Quantity, k.Unit, *k.LowStockThreshold),
+		k.RestockTeamID).Scan(&r.ID, &r.Number)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Warn().Str("consumable", k.ID).Msg("low stock but no requester for a restock ticket")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `insert into ticket_status_history (ticket_id, to_status) values ($1, 'New')`, r.ID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `update consumables set restock_ticket_id = $2 where id::text = $1`, k.ID, r.ID); err != nil {
+		return nil, err
+	}
+	if err := audit.RecordDiff(ctx, tx, restockActor, "consumable", k.ID, "restock_ticket_opened", map[string]any{
+		"ticket_id": r.ID, "number": r.Number, "quantity": k.Quantity}); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListConsumableTransactions handles GET /consumables/:id/transactions and
+// GET /consumables/transactions, which filters by ticket_id or user_id
+// across items. Newest first, at most 200.
+func ListConsumableTransactions(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ticketID, userID := c.Param("id"), c.Query("ticket_id"), c.Query("user_id")
+		if id == "" && ticketID == "" && userID == "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_id": "ticket_id or user_id is required"})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+consumableTxCols+` from consumable_transactions
+            where ($1 = '' or consumable_id::text = $1) and ($2 = '' or ticket_id::text = $2) and ($3 = '' or user_id::text = $3)
+            order by created_at desc, id limit 200`, id, ticketID, userID)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list transactions", nil)
+			return
+		}
+		defer rows.Close()
+		out := []ConsumableTransaction{}
+		for rows.Next() {
+			t, err := scanConsumableTx(rows)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list transactions", nil)
+				return
+			}
+			out = append(out, t)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
@@ -0,0 +1,107 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestRecordConsumableTransaction(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stock, threshold := 5, 2
+	restockOpen := false
+	var stored []int
+	var restockTitle string
+	var audited []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "for update"):
+					*dest[0].(*string) = "c1"
+					*dest[1].(*string) = "Toner 26A"
+					*dest[3].(*string) = "cartridges"
+					*dest[5].(*int) = stock
+					*dest[6].(**int) = &threshold
+					*dest[11].(*bool) = restockOpen
+				case strings.Contains(sql, "insert into consumable_transactions"):
+					*dest[0].(*string) = "tx1"
+					*dest[2].(*string) = args[1].(string)
+					*dest[3].(*int) = args[2].(int)
+					*dest[4].(*int) = args[3].(int)
+					*dest[9].(*time.Time) = time.Now()
+				case strings.Contains(sql, "insert into tickets"):
+					restockTitle = args[1].(string)
+					*dest[0].(*string) = "t-restock"
+					*dest[1].(*string) = "HD-77"
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "set quantity"):
+				stored = append(stored, args[1].(int))
+			case strings.Contains(sql, "audit_events"):
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := app.NewApp(app.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/consumables/:id/transactions", RecordConsumableTransaction(a))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/consumables/c1/transactions", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := post(`{"kind":"lend","quantity":1}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown kind, got %d", rr.Code)
+	}
+	if rr := post(`{"kind":"issue","quantity":6}`); rr.Code != http.StatusConflict || len(stored) != 0 {
+		t.Fatalf("expected 409 insufficient_stock, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// Issuing three leaves two, at the threshold: a restock ticket opens.
+	rr := post(`{"kind":"issue","quantity":3,"ticket_id":"6f1c1f9e-5a55-4a43-9d0e-1d2f4c7b8a90"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Transaction   ConsumableTransaction `json:"transaction"`
+		Consumable    Consumable            `json:"consumable"`
+		RestockTicket *restockTicket        `json:"restock_ticket"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Transaction.Change != -3 || out.Transaction.Balance != 2 || !out.Consumable.LowStock || stored[0] != 2 {
+		t.Fatalf("unexpected issue %s", rr.Body.String())
+	}
+	if out.RestockTicket == nil || out.RestockTicket.Number != "HD-77" || restockTitle != "Restock: Toner 26A" {
+		t.Fatalf("expected a restock ticket, got %+v %q", out.RestockTicket, restockTitle)
+	}
+	if strings.Join(audited, ",") != "consumable_issue,restock_ticket_opened" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+
+	// While it is open no second ticket is opened, and a stocktake sets
+	// the counted level.
+	restockOpen, restockTitle = true, ""
+	rr = post(`{"kind":"adjust","quantity":1}`)
+	if rr.Code != http.StatusCreated || restockTitle != "" || stored[1] != 1 || !strings.Contains(rr.Body.String(), `"change":-4`) {
+		t.Fatalf("unexpected adjustment %d %s", rr.Code, rr.Body.String())
+	}
+}
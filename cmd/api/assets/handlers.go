@@ -1,6 +1,7 @@
 package assets
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 
 	"github.com/mark3748/helpdesk-go/cmd/api/app"
 	"github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
 )
 
 // ListAssets handles GET /assets
@@ -69,6 +71,9 @@ func CreateAsset(a *app.App) gin.HandlerFunc {
 				c.JSON(http.StatusConflict, gin.H{"error": "asset tag already exists"})
 				return
 			}
+			if respondCustomFieldsError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -140,12 +145,35 @@ func UpdateAsset(a *app.App) gin.HandlerFunc {
 		}
 
 		service := NewService(a.DB)
+		if req.Status != nil {
+			current, err := service.GetAsset(c.Request.Context(), id)
+			if err != nil {
+				if err.Error() == "asset not found" {
+					c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if *req.Status != current.Status {
+				if err := service.ValidateStatusTransition(current.Status, *req.Status, authUser.Roles); err != nil {
+					respondTransitionError(c, err)
+					return
+				}
+			}
+		}
 		asset, err := service.UpdateAsset(c.Request.Context(), id, req, uuid.MustParse(authUser.ID))
 		if err != nil {
 			if err.Error() == "asset not found" {
 				c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
 				return
 			}
+			if respondTransitionError(c, err) {
+				return
+			}
+			if respondCustomFieldsError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -154,6 +182,49 @@ func UpdateAsset(a *app.App) gin.HandlerFunc {
 	}
 }
 
+// ListLifecycleRules handles GET /assets/lifecycle
+func ListLifecycleRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"rules":            GetLifecycleRules(),
+			"disposal_methods": DisposalMethods,
+		})
+	}
+}
+
+// respondTransitionError writes err if it is a *TransitionError and
+// reports whether it did.
+func respondTransitionError(c *gin.Context, err error) bool {
+	var te *TransitionError
+	if !errors.As(err, &te) {
+		return false
+	}
+	status := http.StatusBadRequest
+	switch te.Code {
+	case "forbidden":
+		status = http.StatusForbidden
+	case "invalid_transition":
+		status = http.StatusConflict
+	}
+	c.JSON(status, te)
+	return true
+}
+
+// respondCustomFieldsError writes err if it rejects custom fields or names
+// a missing category, and reports whether it did.
+func respondCustomFieldsError(c *gin.Context, err error) bool {
+	var errs customfields.Errors
+	if errors.As(err, &errs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid custom fields", "code": "invalid_custom_fields", "fields": errs})
+		return true
+	}
+	if err.Error() == "category not found" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category not found", "code": "invalid_field", "field": "category_id"})
+		return true
+	}
+	return false
+}
+
 // DeleteAsset handles DELETE /assets/:id
 func DeleteAsset(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -278,6 +349,9 @@ func CreateCategory(a *app.App) gin.HandlerFunc {
 				c.JSON(http.StatusConflict, gin.H{"error": "category name already exists"})
 				return
 			}
+			if respondSchemaError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -286,6 +360,59 @@ func CreateCategory(a *app.App) gin.HandlerFunc {
 	}
 }
 
+// UpdateCategory handles PATCH /asset-categories/:id
+func UpdateCategory(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database not available"})
+			return
+		}
+
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category ID"})
+			return
+		}
+
+		var req UpdateCategoryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		service := NewService(a.DB)
+		category, err := service.UpdateCategory(c.Request.Context(), id, req)
+		if err != nil {
+			if err.Error() == "category not found" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+				return
+			}
+			if err.Error() == "category already exists" {
+				c.JSON(http.StatusConflict, gin.H{"error": "category name already exists"})
+				return
+			}
+			if respondSchemaError(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+// respondSchemaError writes err if it rejects a category's custom field
+// schema and reports whether it did.
+func respondSchemaError(c *gin.Context, err error) bool {
+	var errs customfields.Errors
+	if !errors.As(err, &errs) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid custom field schema", "code": "invalid_custom_field_schema", "fields": errs})
+	return true
+}
+
 // GetCategory handles GET /asset-categories/:id
 func GetCategory(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
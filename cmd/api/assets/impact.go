@@ -0,0 +1,152 @@
+package assets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxImpactDownstream caps the downstream assets listed on a ticket; the
+// count in Impact.TotalDownstream is not capped.
+const maxImpactDownstream = 50
+
+// Impact is what a failure of an asset takes down with it, as shown on the
+// tickets linked to it.
+type Impact struct {
+	AssetID              uuid.UUID         `json:"asset_id"`
+	AssetTag             string            `json:"asset_tag"`
+	Name                 string            `json:"name"`
+	Status               AssetStatus       `json:"status"`
+	RiskLevel            string            `json:"risk_level"`
+	SinglePointOfFailure bool              `json:"is_single_point_of_failure"`
+	DirectDependents     int               `json:"direct_dependents"`
+	TotalDownstream      int               `json:"total_downstream_assets"`
+	DownstreamAssets     []DownstreamAsset `json:"downstream_assets"`
+	Services             []AffectedService `json:"services"`
+}
+
+// DownstreamAsset is an asset depending, directly or through others, on the
+// analysed asset. Depth 1 depends on it directly.
+type DownstreamAsset struct {
+	ID       uuid.UUID   `json:"id"`
+	AssetTag string      `json:"asset_tag"`
+	Name     string      `json:"name"`
+	Status   AssetStatus `json:"status"`
+	Depth    int         `json:"depth"`
+}
+
+// AffectedService is a business service that fails with an asset: it is
+// mapped to the asset itself (depth 0) or to an asset depending on it.
+// ViaAssetID is the nearest such mapped asset.
+type AffectedService struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Criticality   string    `json:"criticality"`
+	ViaAssetID    uuid.UUID `json:"via_asset_id"`
+	Depth         int       `json:"depth"`
+	InMaintenance bool      `json:"in_maintenance"`
+}
+
+// GetImpact runs GetAssetImpactAnalysis for an asset and lists the assets
+// downstream of it through dependency relationships, nearest first, and
+// the business services that fail with it.
+func (s *Service) GetImpact(ctx context.Context, assetID uuid.UUID) (*Impact, error) {
+	impact := &Impact{AssetID: assetID, DownstreamAssets: []DownstreamAsset{}}
+	err := s.db.QueryRow(ctx, `SELECT asset_tag, name, status FROM assets WHERE id = $1`, assetID).
+		Scan(&impact.AssetTag, &impact.Name, &impact.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("asset not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get asset: %w", err)
+	}
+
+	analysis, err := s.GetAssetImpactAnalysis(ctx, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyse impact: %w", err)
+	}
+	impact.DirectDependents, _ = (*analysis)["direct_dependents"].(int)
+	impact.TotalDownstream, _ = (*analysis)["total_downstream_assets"].(int)
+	impact.SinglePointOfFailure, _ = (*analysis)["is_single_point_of_failure"].(bool)
+	impact.RiskLevel, _ = (*analysis)["risk_level"].(string)
+
+	rows, err := s.db.Query(ctx, `
+		WITH RECURSIVE downstream AS (
+			SELECT child_asset_id AS id, 1 AS depth, ARRAY[parent_asset_id, child_asset_id] AS path
+			FROM asset_relationships
+			WHERE parent_asset_id = $1 AND relationship_type = 'dependency'
+			UNION ALL
+			SELECT ar.child_asset_id, d.depth + 1, d.path || ar.child_asset_id
+			FROM asset_relationships ar
+			JOIN downstream d ON ar.parent_asset_id = d.id
+			WHERE ar.relationship_type = 'dependency' AND d.depth < $2
+			  AND NOT ar.child_asset_id = ANY(d.path)
+		)
+		SELECT a.id, a.asset_tag, a.name, a.status, min(d.depth) AS depth
+		FROM downstream d
+		JOIN assets a ON a.id = d.id
+		GROUP BY a.id, a.asset_tag, a.name, a.status
+		ORDER BY depth, a.asset_tag
+		LIMIT $3`, assetID, maxCriticalPathDepth, maxImpactDownstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list downstream assets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d DownstreamAsset
+		if err := rows.Scan(&d.ID, &d.AssetTag, &d.Name, &d.Status, &d.Depth); err != nil {
+			return nil, fmt.Errorf("failed to scan downstream asset: %w", err)
+		}
+		impact.DownstreamAssets = append(impact.DownstreamAssets, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if impact.Services, err = s.AffectedServices(ctx, assetID); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+// AffectedServices returns the business services mapped to an asset or to
+// any asset downstream of it, nearest first. InMaintenance reports a
+// service inside one of its maintenance windows now.
+func (s *Service) AffectedServices(ctx context.Context, assetID uuid.UUID) ([]AffectedService, error) {
+	rows, err := s.db.Query(ctx, `
+		WITH RECURSIVE downstream AS (
+			SELECT $1::uuid AS id, 0 AS depth, ARRAY[$1::uuid] AS path
+			UNION ALL
+			SELECT ar.child_asset_id, d.depth + 1, d.path || ar.child_asset_id
+			FROM asset_relationships ar
+			JOIN downstream d ON ar.parent_asset_id = d.id
+			WHERE ar.relationship_type = 'dependency' AND d.depth < $2
+			  AND NOT ar.child_asset_id = ANY(d.path)
+		), nearest AS (
+			SELECT DISTINCT ON (bs.id) bs.id, bs.name, bs.criticality, d.id AS via, d.depth
+			FROM downstream d
+			JOIN business_service_assets bsa ON bsa.asset_id = d.id
+			JOIN business_services bs ON bs.id = bsa.service_id
+			ORDER BY bs.id, d.depth
+		)
+		SELECT n.id, n.name, n.criticality, n.via, n.depth,
+		       EXISTS (SELECT 1 FROM service_maintenance_windows w
+		               WHERE w.service_id = n.id AND now() >= w.starts_at AND now() < w.ends_at)
+		FROM nearest n
+		ORDER BY n.depth, n.name`, assetID, maxCriticalPathDepth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list affected services: %w", err)
+	}
+	defer rows.Close()
+	out := []AffectedService{}
+	for rows.Next() {
+		var a AffectedService
+		if err := rows.Scan(&a.ID, &a.Name, &a.Criticality, &a.ViaAssetID, &a.Depth, &a.InMaintenance); err != nil {
+			return nil, fmt.Errorf("failed to scan affected service: %w", err)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
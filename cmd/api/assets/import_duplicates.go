@@ -0,0 +1,238 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Duplicate strategies for imports. A row matching an existing asset by
+// asset tag or serial number is left alone (skip), overwrites the asset's
+// fields with the row's non-empty values (update), or only fills the
+// asset's empty fields (merge).
+const (
+	DuplicateSkip   = "skip"
+	DuplicateUpdate = "update"
+	DuplicateMerge  = "merge"
+)
+
+// DuplicateStrategies lists the accepted duplicate strategies.
+var DuplicateStrategies = []string{DuplicateSkip, DuplicateUpdate, DuplicateMerge}
+
+// ImportConflict reports an import row that duplicates an existing asset or
+// an earlier row of the same file. Resolution says what the import did:
+// skipped, updated, merged, or unresolved when the row matched more than
+// one asset or repeated an earlier row.
+type ImportConflict struct {
+	Row              int        `json:"row"`
+	AssetTag         string     `json:"asset_tag"`
+	SerialNumber     string     `json:"serial_number,omitempty"`
+	MatchedBy        string     `json:"matched_by"` // "asset_tag", "serial_number", "both", "file"
+	ExistingAssetID  *uuid.UUID `json:"existing_asset_id,omitempty"`
+	ExistingAssetTag string     `json:"existing_asset_tag,omitempty"`
+	Resolution       string     `json:"resolution"`
+	Message          string     `json:"message,omitempty"`
+}
+
+// importCandidate is an existing asset sharing a row's tag or serial.
+type importCandidate struct {
+	ID           uuid.UUID
+	AssetTag     string
+	SerialNumber *string
+}
+
+// importSeen remembers the tags and serial numbers of earlier rows of an
+// import, keyed case-insensitively, with the row that used them.
+type importSeen struct {
+	tags    map[string]int
+	serials map[string]int
+}
+
+func newImportSeen() *importSeen {
+	return &importSeen{tags: map[string]int{}, serials: map[string]int{}}
+}
+
+// repeat reports the earlier row a row's tag or serial repeats, if any, and
+// remembers the row otherwise.
+func (s *importSeen) repeat(row int, tag, serial string) (int, string) {
+	tag, serial = strings.ToLower(tag), strings.ToLower(serial)
+	if prev, ok := s.tags[tag]; ok {
+		return prev, "asset tag"
+	}
+	if prev, ok := s.serials[serial]; serial != "" && ok {
+		return prev, "serial number"
+	}
+	s.tags[tag] = row
+	if serial != "" {
+		s.serials[serial] = row
+	}
+	return 0, ""
+}
+
+// findImportCandidates returns the existing assets with the row's asset
+// tag or serial number. Serial numbers compare without case or padding.
+func (s *Service) findImportCandidates(ctx context.Context, tag, serial string) ([]importCandidate, error) {
+	rows, err := s.db.Query(ctx, `
+		SELECT id, asset_tag, serial_number FROM assets
+		WHERE asset_tag = $1 OR ($2 <> '' AND lower(btrim(serial_number)) = lower($2))
+		ORDER BY created_at
+		LIMIT 5`, tag, serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	var out []importCandidate
+	for rows.Next() {
+		var c importCandidate
+		if err := rows.Scan(&c.ID, &c.AssetTag, &c.SerialNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// classifyDuplicate describes how a row with tag and serial matches
+// candidates. It returns nil when nothing matches. A conflict without
+// ExistingAssetID matched several assets and cannot be resolved.
+func classifyDuplicate(row int, tag, serial string, candidates []importCandidate) *ImportConflict {
+	if len(candidates) == 0 {
+		return nil
+	}
+	conflict := &ImportConflict{Row: row, AssetTag: tag, SerialNumber: serial}
+	if len(candidates) > 1 {
+		tags := make([]string, len(candidates))
+		for i, c := range candidates {
+			tags[i] = c.AssetTag
+		}
+		conflict.MatchedBy = "both"
+		conflict.Resolution = "unresolved"
+		conflict.Message = fmt.Sprintf("asset tag and serial number match different assets (%s)", strings.Join(tags, ", "))
+		return conflict
+	}
+	c := candidates[0]
+	byTag := c.AssetTag == tag
+	bySerial := serial != "" && c.SerialNumber != nil && strings.EqualFold(strings.TrimSpace(*c.SerialNumber), serial)
+	switch {
+	case byTag && bySerial:
+		conflict.MatchedBy = "both"
+	case byTag:
+		conflict.MatchedBy = "asset_tag"
+	default:
+		conflict.MatchedBy = "serial_number"
+	}
+	conflict.ExistingAssetID = &c.ID
+	conflict.ExistingAssetTag = c.AssetTag
+	return conflict
+}
+
+// detectDuplicate reports how a row duplicates an earlier row of the
+// import or an existing asset, or nil when it is new.
+func (s *Service) detectDuplicate(ctx context.Context, seen *importSeen, row int, req CreateAssetRequest) (*ImportConflict, error) {
+	serial := ""
+	if req.SerialNumber != nil {
+		serial = strings.TrimSpace(*req.SerialNumber)
+	}
+	if prev, what := seen.repeat(row, req.AssetTag, serial); prev != 0 {
+		return &ImportConflict{
+			Row: row, AssetTag: req.AssetTag, SerialNumber: serial,
+			MatchedBy: "file", Resolution: "unresolved",
+			Message: fmt.Sprintf("repeats the %s of row %d", what, prev),
+		}, nil
+	}
+	candidates, err := s.findImportCandidates(ctx, req.AssetTag, serial)
+	if err != nil {
+		return nil, err
+	}
+	return classifyDuplicate(row, req.AssetTag, serial, candidates), nil
+}
+
+// resolveDuplicate applies strategy to a row duplicating an existing asset
+// and records the outcome in conflict.Resolution. Unresolvable conflicts
+// are left as they are.
+func (s *Service) resolveDuplicate(ctx context.Context, conflict *ImportConflict, req CreateAssetRequest, strategy string, operation *BulkOperation) error {
+	if conflict.ExistingAssetID == nil {
+		return nil
+	}
+	if strategy != DuplicateUpdate && strategy != DuplicateMerge {
+		conflict.Resolution = "skipped"
+		return nil
+	}
+	existing, err := s.GetAsset(ctx, *conflict.ExistingAssetID)
+	if err != nil {
+		conflict.Resolution = "unresolved"
+		return fmt.Errorf("failed to load duplicate %s: %w", conflict.ExistingAssetTag, err)
+	}
+	update := duplicateUpdate(existing, req, strategy)
+	if update.Status != nil {
+		reason := fmt.Sprintf("asset import %s", operation.ID)
+		update.StatusReason = &reason
+	}
+	if _, err := s.UpdateAsset(ctx, existing.ID, update, operation.RequestedBy); err != nil {
+		conflict.Resolution = "unresolved"
+		return fmt.Errorf("failed to %s duplicate %s: %w", strategy, conflict.ExistingAssetTag, err)
+	}
+	conflict.Resolution = duplicateResolution(strategy)
+	return nil
+}
+
+// duplicateResolution is the resolution strategy gives a resolvable
+// conflict.
+func duplicateResolution(strategy string) string {
+	switch strategy {
+	case DuplicateUpdate:
+		return "updated"
+	case DuplicateMerge:
+		return "merged"
+	}
+	return "skipped"
+}
+
+func conflictsJSON(conflicts []ImportConflict) []byte {
+	if conflicts == nil {
+		conflicts = []ImportConflict{}
+	}
+	b, _ := json.Marshal(conflicts)
+	return b
+}
+
+// duplicateUpdate builds the update applying a row to the existing asset
+// it duplicates. Update takes every value the row has; merge takes only
+// values for fields the asset leaves empty and never changes its status.
+// Neither changes the asset tag.
+func duplicateUpdate(existing *Asset, row CreateAssetRequest, strategy string) UpdateAssetRequest {
+	var req UpdateAssetRequest
+	take := func(current *string) bool {
+		return strategy == DuplicateUpdate || current == nil || strings.TrimSpace(*current) == ""
+	}
+	if row.Name != "" && strategy == DuplicateUpdate {
+		req.Name = &row.Name
+	}
+	if row.Description != nil && take(existing.Description) {
+		req.Description = row.Description
+	}
+	if row.SerialNumber != nil && take(existing.SerialNumber) {
+		req.SerialNumber = row.SerialNumber
+	}
+	if row.Model != nil && take(existing.Model) {
+		req.Model = row.Model
+	}
+	if row.Manufacturer != nil && take(existing.Manufacturer) {
+		req.Manufacturer = row.Manufacturer
+	}
+	if row.Location != nil && take(existing.Location) {
+		req.Location = row.Location
+	}
+	if row.PurchasePrice != nil && (strategy == DuplicateUpdate || existing.PurchasePrice == nil) {
+		req.PurchasePrice = row.PurchasePrice
+	}
+	if strategy == DuplicateUpdate && row.Status != "" && row.Status != existing.Status {
+		status := row.Status
+		req.Status = &status
+	}
+	return req
+}
@@ -0,0 +1,79 @@
+package assets
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestClassifyDuplicate(t *testing.T) {
+	serial := " sn-1 "
+	a := importCandidate{ID: uuid.New(), AssetTag: "A-1", SerialNumber: &serial}
+	b := importCandidate{ID: uuid.New(), AssetTag: "A-2"}
+
+	if c := classifyDuplicate(2, "A-9", "SN-9", nil); c != nil {
+		t.Fatalf("no candidates gave %+v", c)
+	}
+	cases := []struct {
+		tag, serial string
+		candidates  []importCandidate
+		matchedBy   string
+		existing    bool
+	}{
+		{"A-1", "SN-1", []importCandidate{a}, "both", true},
+		{"A-1", "", []importCandidate{a}, "asset_tag", true},
+		{"A-7", "SN-1", []importCandidate{a}, "serial_number", true},
+		{"A-2", "SN-1", []importCandidate{a, b}, "both", false},
+	}
+	for _, tc := range cases {
+		c := classifyDuplicate(3, tc.tag, tc.serial, tc.candidates)
+		if c == nil || c.MatchedBy != tc.matchedBy || (c.ExistingAssetID != nil) != tc.existing {
+			t.Fatalf("%s/%s: got %+v", tc.tag, tc.serial, c)
+		}
+		if !tc.existing && c.Resolution != "unresolved" {
+			t.Fatalf("ambiguous match resolution = %q", c.Resolution)
+		}
+	}
+}
+
+func TestImportSeenRepeat(t *testing.T) {
+	seen := newImportSeen()
+	if prev, _ := seen.repeat(1, "A-1", "SN-1"); prev != 0 {
+		t.Fatalf("first row repeated row %d", prev)
+	}
+	if prev, what := seen.repeat(2, "a-1", ""); prev != 1 || what != "asset tag" {
+		t.Fatalf("tag repeat = %d %q", prev, what)
+	}
+	if prev, what := seen.repeat(3, "A-3", "sn-1"); prev != 1 || what != "serial number" {
+		t.Fatalf("serial repeat = %d %q", prev, what)
+	}
+	if prev, _ := seen.repeat(4, "A-4", ""); prev != 0 {
+		t.Fatalf("blank serial repeated row %d", prev)
+	}
+}
+
+func TestDuplicateUpdate(t *testing.T) {
+	str := func(s string) *string { return &s }
+	price := 900.0
+	existing := &Asset{Name: "Old", Status: AssetStatusActive, Model: str("T14"), Location: str("")}
+	row := CreateAssetRequest{
+		Name: "New", Status: AssetStatusMaintenance, Model: str("T16"), Location: str("HQ"),
+		Manufacturer: str("Lenovo"), PurchasePrice: &price,
+	}
+
+	merged := duplicateUpdate(existing, row, DuplicateMerge)
+	if merged.Name != nil || merged.Model != nil || merged.Status != nil {
+		t.Fatalf("merge overwrote set fields: %+v", merged)
+	}
+	if merged.Location == nil || *merged.Location != "HQ" || merged.Manufacturer == nil || merged.PurchasePrice == nil {
+		t.Fatalf("merge did not fill empty fields: %+v", merged)
+	}
+
+	updated := duplicateUpdate(existing, row, DuplicateUpdate)
+	if updated.Name == nil || *updated.Name != "New" || *updated.Model != "T16" {
+		t.Fatalf("update did not overwrite: %+v", updated)
+	}
+	if updated.Status == nil || *updated.Status != AssetStatusMaintenance {
+		t.Fatalf("update status = %v", updated.Status)
+	}
+}
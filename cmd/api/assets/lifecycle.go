@@ -4,20 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-// AssetLifecycleRule represents a rule for asset lifecycle transitions
+// AssetLifecycleRule represents a rule for asset lifecycle transitions.
+// RequiredFields must be set on the asset, or in the update making the
+// transition, for the transition to go through.
 type AssetLifecycleRule struct {
 	FromStatus       AssetStatus   `json:"from_status"`
 	ToStatus         AssetStatus   `json:"to_status"`
 	RequiredRole     string        `json:"required_role,omitempty"`
 	RequiresApproval bool          `json:"requires_approval,omitempty"`
+	RequiredFields   []string      `json:"required_fields,omitempty"`
 	AutoTriggers     []AutoTrigger `json:"auto_triggers,omitempty"`
 }
 
+// TransitionError rejects an asset status change. Code is
+// invalid_transition, forbidden, missing_field or invalid_field; Field
+// names the request field at fault, if any.
+type TransitionError struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"error"`
+}
+
+func (e *TransitionError) Error() string { return e.Message }
+
 // AutoTrigger represents conditions that automatically trigger status changes
 type AutoTrigger struct {
 	Type      string        `json:"type"` // "time_based", "condition_based", "maintenance_due"
@@ -41,9 +57,15 @@ type AssetWorkflow struct {
 	CompletedAt *time.Time             `json:"completed_at" db:"completed_at"`
 }
 
-// GetLifecycleRules returns the defined lifecycle rules
+// GetLifecycleRules returns the defined lifecycle rules. Every status change
+// also needs a reason.
 func GetLifecycleRules() []AssetLifecycleRule {
+	disposal := []string{"disposal_method", "disposed_on"}
 	return []AssetLifecycleRule{
+		// Procurement
+		{FromStatus: AssetStatusOrdered, ToStatus: AssetStatusReceived, RequiredRole: "manager", RequiredFields: []string{"serial_number"}},
+		{FromStatus: AssetStatusReceived, ToStatus: AssetStatusActive, RequiredRole: "manager", RequiredFields: []string{"location"}},
+
 		// Active transitions
 		{FromStatus: AssetStatusActive, ToStatus: AssetStatusMaintenance, RequiredRole: "manager"},
 		{FromStatus: AssetStatusActive, ToStatus: AssetStatusRetired, RequiredRole: "admin", RequiresApproval: true},
@@ -56,62 +78,128 @@ func GetLifecycleRules() []AssetLifecycleRule {
 		// Inactive transitions
 		{FromStatus: AssetStatusInactive, ToStatus: AssetStatusActive, RequiredRole: "manager"},
 		{FromStatus: AssetStatusInactive, ToStatus: AssetStatusRetired, RequiredRole: "admin"},
-		{FromStatus: AssetStatusInactive, ToStatus: AssetStatusDisposed, RequiredRole: "admin", RequiresApproval: true},
+		{FromStatus: AssetStatusInactive, ToStatus: AssetStatusDisposed, RequiredRole: "admin", RequiresApproval: true, RequiredFields: disposal},
 
 		// Retired transitions
-		{FromStatus: AssetStatusRetired, ToStatus: AssetStatusDisposed, RequiredRole: "admin", RequiresApproval: true},
+		{FromStatus: AssetStatusRetired, ToStatus: AssetStatusDisposed, RequiredRole: "admin", RequiresApproval: true, RequiredFields: disposal},
 		{FromStatus: AssetStatusRetired, ToStatus: AssetStatusActive, RequiredRole: "admin"}, // Reactivation
 	}
 }
 
+// lifecycleRule returns the rule for moving from one status to another.
+func lifecycleRule(from, to AssetStatus) (AssetLifecycleRule, bool) {
+	for _, rule := range GetLifecycleRules() {
+		if rule.FromStatus == from && rule.ToStatus == to {
+			return rule, true
+		}
+	}
+	return AssetLifecycleRule{}, false
+}
+
 // ValidateStatusTransition checks if a status transition is allowed
 func (s *Service) ValidateStatusTransition(from, to AssetStatus, userRoles []string) error {
-	rules := GetLifecycleRules()
-
-	for _, rule := range rules {
-		if rule.FromStatus == from && rule.ToStatus == to {
-			// Check role requirement
-			if rule.RequiredRole != "" {
-				hasRole := false
-				for _, role := range userRoles {
-					if role == rule.RequiredRole || role == "admin" { // Admin can override
-						hasRole = true
-						break
-					}
-				}
-				if !hasRole {
-					return fmt.Errorf("insufficient permissions: requires %s role", rule.RequiredRole)
-				}
+	rule, ok := lifecycleRule(from, to)
+	if !ok {
+		return &TransitionError{Code: "invalid_transition", Field: "status", Message: fmt.Sprintf("invalid status transition from %s to %s", from, to)}
+	}
+	if rule.RequiredRole != "" {
+		for _, role := range userRoles {
+			if role == rule.RequiredRole || role == "admin" { // Admin can override
+				return nil
 			}
+		}
+		return &TransitionError{Code: "forbidden", Field: "status", Message: fmt.Sprintf("insufficient permissions: requires %s role", rule.RequiredRole)}
+	}
+	return nil
+}
 
-			return nil // Valid transition
+// checkTransition validates moving asset to req.Status: the transition must
+// exist, carry a reason and supply the rule's required fields, taking
+// fields from req before the asset.
+func checkTransition(asset *Asset, req UpdateAssetRequest, now time.Time) error {
+	rule, ok := lifecycleRule(asset.Status, *req.Status)
+	if !ok {
+		return &TransitionError{Code: "invalid_transition", Field: "status", Message: fmt.Sprintf("invalid status transition from %s to %s", asset.Status, *req.Status)}
+	}
+	if req.StatusReason == nil || strings.TrimSpace(*req.StatusReason) == "" {
+		return &TransitionError{Code: "missing_field", Field: "status_reason", Message: "a reason is required to change an asset's status"}
+	}
+	for _, field := range rule.RequiredFields {
+		var set bool
+		switch field {
+		case "serial_number":
+			set = nonBlank(req.SerialNumber) || nonBlank(asset.SerialNumber)
+		case "location":
+			set = nonBlank(req.Location) || nonBlank(asset.Location)
+		case "disposal_method":
+			set = req.DisposalMethod != nil
+		case "disposed_on":
+			set = req.DisposedOn != nil
 		}
+		if !set {
+			return &TransitionError{Code: "missing_field", Field: field, Message: fmt.Sprintf("%s is required to move an asset from %s to %s", field, asset.Status, *req.Status)}
+		}
+	}
+	if *req.Status != AssetStatusDisposed {
+		if req.DisposalMethod != nil || req.DisposedOn != nil {
+			return &TransitionError{Code: "invalid_field", Field: "disposal_method", Message: "disposal details can only be given when disposing of an asset"}
+		}
+		return nil
+	}
+	if !slices.Contains(DisposalMethods, *req.DisposalMethod) {
+		return &TransitionError{Code: "invalid_field", Field: "disposal_method", Message: "disposal_method must be one of " + strings.Join(DisposalMethods, ", ")}
 	}
+	if req.DisposedOn.After(now) {
+		return &TransitionError{Code: "invalid_field", Field: "disposed_on", Message: "disposed_on cannot be in the future"}
+	}
+	return nil
+}
+
+func nonBlank(s *string) bool {
+	return s != nil && strings.TrimSpace(*s) != ""
+}
 
-	return fmt.Errorf("invalid status transition from %s to %s", from, to)
+// StatusChangeRequest asks for a status change that needs approval. The
+// comments are the transition's reason.
+type StatusChangeRequest struct {
+	ToStatus       AssetStatus `json:"to_status" binding:"required"`
+	Comments       *string     `json:"comments"`
+	DisposalMethod *string     `json:"disposal_method"`
+	DisposedOn     *time.Time  `json:"disposed_on"`
 }
 
 // RequestStatusChange creates a workflow for status changes that require approval
-func (s *Service) RequestStatusChange(ctx context.Context, assetID uuid.UUID, toStatus AssetStatus, requestedBy uuid.UUID, comments *string) (*AssetWorkflow, error) {
+func (s *Service) RequestStatusChange(ctx context.Context, assetID uuid.UUID, req StatusChangeRequest, requestedBy uuid.UUID) (*AssetWorkflow, error) {
 	// Get current asset status
 	asset, err := s.GetAsset(ctx, assetID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if approval is required
-	rules := GetLifecycleRules()
-	requiresApproval := false
+	rule, ok := lifecycleRule(asset.Status, req.ToStatus)
+	if ok && !rule.RequiresApproval {
+		return nil, fmt.Errorf("status change does not require workflow approval")
+	}
 
-	for _, rule := range rules {
-		if rule.FromStatus == asset.Status && rule.ToStatus == toStatus {
-			requiresApproval = rule.RequiresApproval
-			break
-		}
+	// Reject requests that could not be carried out once approved
+	update := UpdateAssetRequest{
+		Status:         &req.ToStatus,
+		StatusReason:   req.Comments,
+		DisposalMethod: req.DisposalMethod,
+		DisposedOn:     req.DisposedOn,
+	}
+	if err := checkTransition(asset, update, time.Now()); err != nil {
+		return nil, err
 	}
 
-	if !requiresApproval {
-		return nil, fmt.Errorf("status change does not require workflow approval")
+	requestData := map[string]interface{}{
+		"from_status": asset.Status,
+		"to_status":   req.ToStatus,
+		"reason":      *req.Comments,
+	}
+	if req.DisposalMethod != nil {
+		requestData["disposal_method"] = *req.DisposalMethod
+		requestData["disposed_on"] = req.DisposedOn.Format(time.DateOnly)
 	}
 
 	// Create workflow request
@@ -121,13 +209,10 @@ func (s *Service) RequestStatusChange(ctx context.Context, assetID uuid.UUID, to
 		Type:        "status_change",
 		Status:      "pending",
 		RequestedBy: requestedBy,
-		RequestData: map[string]interface{}{
-			"from_status": asset.Status,
-			"to_status":   toStatus,
-		},
-		Comments:  comments,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		RequestData: requestData,
+		Comments:    req.Comments,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
 	// Save workflow
@@ -212,9 +297,22 @@ func (s *Service) executeWorkflow(ctx context.Context, workflow *AssetWorkflow)
 func (s *Service) executeStatusChangeWorkflow(ctx context.Context, workflow *AssetWorkflow) error {
 	toStatus := workflow.RequestData["to_status"].(string)
 
-	// Update asset status
+	// Update asset status, giving the requester's reason
+	reason, _ := workflow.RequestData["reason"].(string)
+	if reason == "" {
+		reason = fmt.Sprintf("approved status change workflow %s", workflow.ID)
+	}
 	updateReq := UpdateAssetRequest{
-		Status: (*AssetStatus)(&toStatus),
+		Status:       (*AssetStatus)(&toStatus),
+		StatusReason: &reason,
+	}
+	if method, ok := workflow.RequestData["disposal_method"].(string); ok {
+		updateReq.DisposalMethod = &method
+	}
+	if on, ok := workflow.RequestData["disposed_on"].(string); ok {
+		if d, err := time.Parse(time.DateOnly, on); err == nil {
+			updateReq.DisposedOn = &d
+		}
 	}
 
 	actorID := workflow.RequestedBy
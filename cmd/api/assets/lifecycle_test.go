@@ -0,0 +1,116 @@
+package assets
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckTransition(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	day := func(d time.Time) *time.Time { return &d }
+	status := func(s AssetStatus) *AssetStatus { return &s }
+
+	cases := []struct {
+		name  string
+		asset Asset
+		req   UpdateAssetRequest
+		code  string
+		field string
+	}{
+		{
+			name:  "receive with serial on request",
+			asset: Asset{Status: AssetStatusOrdered},
+			req:   UpdateAssetRequest{Status: status(AssetStatusReceived), StatusReason: str("delivered"), SerialNumber: str("SN1")},
+		},
+		{
+			name:  "receive without serial",
+			asset: Asset{Status: AssetStatusOrdered},
+			req:   UpdateAssetRequest{Status: status(AssetStatusReceived), StatusReason: str("delivered")},
+			code:  "missing_field", field: "serial_number",
+		},
+		{
+			name:  "activate uses stored location",
+			asset: Asset{Status: AssetStatusReceived, Location: str("HQ-2")},
+			req:   UpdateAssetRequest{Status: status(AssetStatusActive), StatusReason: str("deployed")},
+		},
+		{
+			name:  "skipping ahead",
+			asset: Asset{Status: AssetStatusOrdered},
+			req:   UpdateAssetRequest{Status: status(AssetStatusActive), StatusReason: str("rush")},
+			code:  "invalid_transition", field: "status",
+		},
+		{
+			name:  "no way back from disposed",
+			asset: Asset{Status: AssetStatusDisposed},
+			req:   UpdateAssetRequest{Status: status(AssetStatusActive), StatusReason: str("found it")},
+			code:  "invalid_transition", field: "status",
+		},
+		{
+			name:  "blank reason",
+			asset: Asset{Status: AssetStatusActive},
+			req:   UpdateAssetRequest{Status: status(AssetStatusMaintenance), StatusReason: str("  ")},
+			code:  "missing_field", field: "status_reason",
+		},
+		{
+			name:  "dispose",
+			asset: Asset{Status: AssetStatusRetired},
+			req:   UpdateAssetRequest{Status: status(AssetStatusDisposed), StatusReason: str("end of life"), DisposalMethod: str("recycled"), DisposedOn: day(now.AddDate(0, 0, -1))},
+		},
+		{
+			name:  "dispose without date",
+			asset: Asset{Status: AssetStatusRetired},
+			req:   UpdateAssetRequest{Status: status(AssetStatusDisposed), StatusReason: str("end of life"), DisposalMethod: str("recycled")},
+			code:  "missing_field", field: "disposed_on",
+		},
+		{
+			name:  "dispose with unknown method",
+			asset: Asset{Status: AssetStatusRetired},
+			req:   UpdateAssetRequest{Status: status(AssetStatusDisposed), StatusReason: str("end of life"), DisposalMethod: str("lost"), DisposedOn: day(now)},
+			code:  "invalid_field", field: "disposal_method",
+		},
+		{
+			name:  "dispose in the future",
+			asset: Asset{Status: AssetStatusRetired},
+			req:   UpdateAssetRequest{Status: status(AssetStatusDisposed), StatusReason: str("end of life"), DisposalMethod: str("resold"), DisposedOn: day(now.AddDate(0, 0, 2))},
+			code:  "invalid_field", field: "disposed_on",
+		},
+		{
+			name:  "disposal details on another transition",
+			asset: Asset{Status: AssetStatusActive},
+			req:   UpdateAssetRequest{Status: status(AssetStatusMaintenance), StatusReason: str("repair"), DisposalMethod: str("recycled")},
+			code:  "invalid_field", field: "disposal_method",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTransition(&tc.asset, tc.req, now)
+			if tc.code == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var te *TransitionError
+			if !errors.As(err, &te) {
+				t.Fatalf("expected TransitionError, got %v", err)
+			}
+			if te.Code != tc.code || te.Field != tc.field {
+				t.Fatalf("got %s/%s, want %s/%s", te.Code, te.Field, tc.code, tc.field)
+			}
+		})
+	}
+}
+
+func TestValidateStatusTransitionRoles(t *testing.T) {
+	s := &Service{}
+	if err := s.ValidateStatusTransition(AssetStatusRetired, AssetStatusDisposed, []string{"manager"}); err == nil {
+		t.Fatal("manager should not dispose of assets")
+	} else if te := err.(*TransitionError); te.Code != "forbidden" {
+		t.Fatalf("code = %s", te.Code)
+	}
+	if err := s.ValidateStatusTransition(AssetStatusOrdered, AssetStatusReceived, []string{"manager"}); err != nil {
+		t.Fatalf("manager receives assets: %v", err)
+	}
+}
@@ -14,7 +14,7 @@ const maxCriticalPathDepth = 10
 
 // RelationshipRequest represents a request to create or update an asset relationship
 type RelationshipRequest struct {
-	ParentAssetID    uuid.UUID        `json:"parent_asset_id" binding:"required"`
+	ParentAssetID    uuid.UUID        `json:"parent_asset_id"` // taken from the path
 	ChildAssetID     uuid.UUID        `json:"child_asset_id" binding:"required"`
 	RelationshipType RelationshipType `json:"relationship_type" binding:"required"`
 	Notes            *string          `json:"notes"`
@@ -38,8 +38,21 @@ type RelationshipGraph struct {
 	Related      []AssetRelationship          `json:"related"`
 }
 
+// Valid reports whether t is a known relationship type.
+func (t RelationshipType) Valid() bool {
+	switch t {
+	case RelationshipComponent, RelationshipDependency, RelationshipRelated, RelationshipUpgrade:
+		return true
+	}
+	return false
+}
+
 // CreateRelationship creates a new asset relationship
 func (s *Service) CreateRelationship(ctx context.Context, req RelationshipRequest, createdBy uuid.UUID) (*AssetRelationship, error) {
+	if !req.RelationshipType.Valid() {
+		return nil, fmt.Errorf("invalid relationship type")
+	}
+
 	// Validate assets exist
 	if err := s.validateAssetsExist(ctx, req.ParentAssetID, req.ChildAssetID); err != nil {
 		return nil, err
@@ -206,15 +219,48 @@ func (s *Service) GetAssetsByRelationship(ctx context.Context, assetID uuid.UUID
 	return assets, nil
 }
 
-// DeleteRelationship removes an asset relationship
-func (s *Service) DeleteRelationship(ctx context.Context, relationshipID uuid.UUID, deletedBy uuid.UUID) error {
+// ListRelationships returns the relationships of an asset. Direction
+// "parent" lists the relationships naming its parents, "child" those naming
+// its children and "both" all of them; an empty relType matches any type.
+func (s *Service) ListRelationships(ctx context.Context, assetID uuid.UUID, relType RelationshipType, direction string) ([]AssetRelationship, error) {
+	var dirs []string
+	switch direction {
+	case "parent":
+		dirs = []string{"child"}
+	case "child":
+		dirs = []string{"parent"}
+	case "both":
+		dirs = []string{"child", "parent"}
+	default:
+		return nil, fmt.Errorf("invalid direction: %s", direction)
+	}
+
+	relationships := []AssetRelationship{}
+	for _, dir := range dirs {
+		rels, err := s.getAssetRelationships(ctx, assetID, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query relationships: %w", err)
+		}
+		for _, rel := range rels {
+			if relType == "" || rel.RelationshipType == relType {
+				relationships = append(relationships, rel)
+			}
+		}
+	}
+	return relationships, nil
+}
+
+// DeleteRelationship removes a relationship of the given asset, on either
+// side of it.
+func (s *Service) DeleteRelationship(ctx context.Context, assetID, relationshipID uuid.UUID, deletedBy uuid.UUID) error {
 	// Get relationship details before deletion
 	var parentID, childID uuid.UUID
 	var relationshipType RelationshipType
 
 	err := s.db.QueryRow(ctx, `
 		SELECT parent_asset_id, child_asset_id, relationship_type 
-		FROM asset_relationships WHERE id = $1`, relationshipID).
+		FROM asset_relationships
+		WHERE id = $1 AND (parent_asset_id = $2 OR child_asset_id = $2)`, relationshipID, assetID).
 		Scan(&parentID, &childID, &relationshipType)
 
 	if err != nil {
@@ -0,0 +1,37 @@
+package assets
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRelationshipTypeValid(t *testing.T) {
+	for _, typ := range []RelationshipType{RelationshipComponent, RelationshipDependency, RelationshipRelated, RelationshipUpgrade} {
+		if !typ.Valid() {
+			t.Errorf("%q should be valid", typ)
+		}
+	}
+	for _, typ := range []RelationshipType{"", "Dependency", "parent"} {
+		if typ.Valid() {
+			t.Errorf("%q should be invalid", typ)
+		}
+	}
+}
+
+func TestRelationshipErrorStatus(t *testing.T) {
+	cases := map[string]int{
+		"invalid relationship type":                           http.StatusBadRequest,
+		"cannot create relationship between asset and itself": http.StatusBadRequest,
+		"one or both assets do not exist":                     http.StatusNotFound,
+		"relationship not found":                              http.StatusNotFound,
+		"relationship already exists":                         http.StatusConflict,
+		"circular dependency detected":                        http.StatusConflict,
+		"failed to create relationship: boom":                 http.StatusInternalServerError,
+	}
+	for msg, want := range cases {
+		if got := relationshipErrorStatus(errors.New(msg)); got != want {
+			t.Errorf("%q: status %d, want %d", msg, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -11,8 +12,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
 )
 
 // Service provides asset management operations
@@ -42,13 +45,20 @@ func (s *Service) CreateCategory(ctx context.Context, req CreateCategoryRequest)
 	}
 
 	customFieldsJSON, _ := json.Marshal(category.CustomFields)
+	schema, err := customfields.Parse(customFieldsJSON)
+	if err != nil {
+		return nil, customfields.Errors{{Field: "custom_fields", Message: err.Error()}}
+	}
+	if err := schema.Check(); err != nil {
+		return nil, err
+	}
 
 	query := `
 		INSERT INTO asset_categories (id, name, description, parent_id, custom_fields, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id, created_at, updated_at`
 
-	err := s.db.QueryRow(ctx, query, category.ID, category.Name, category.Description,
+	err = s.db.QueryRow(ctx, query, category.ID, category.Name, category.Description,
 		category.ParentID, customFieldsJSON, category.CreatedAt, category.UpdatedAt).
 		Scan(&category.ID, &category.CreatedAt, &category.UpdatedAt)
 
@@ -86,6 +96,57 @@ func (s *Service) GetCategory(ctx context.Context, id uuid.UUID) (*AssetCategory
 	return category, nil
 }
 
+// UpdateCategory updates a category. A new custom field schema applies to
+// assets as they are next created or edited; existing values are not
+// rechecked.
+func (s *Service) UpdateCategory(ctx context.Context, id uuid.UUID, req UpdateCategoryRequest) (*AssetCategory, error) {
+	category, err := s.GetCategory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		category.Name = *req.Name
+	}
+	if req.Description != nil {
+		category.Description = req.Description
+	}
+	if req.ParentID != nil {
+		category.ParentID = req.ParentID
+	}
+	if req.CustomFields != nil {
+		category.CustomFields = req.CustomFields
+	}
+	if category.CustomFields == nil {
+		category.CustomFields = make(map[string]interface{})
+	}
+
+	customFieldsJSON, _ := json.Marshal(category.CustomFields)
+	schema, err := customfields.Parse(customFieldsJSON)
+	if err != nil {
+		return nil, customfields.Errors{{Field: "custom_fields", Message: err.Error()}}
+	}
+	if err := schema.Check(); err != nil {
+		return nil, err
+	}
+
+	err = s.db.QueryRow(ctx, `
+		UPDATE asset_categories
+		SET name = $1, description = $2, parent_id = $3, custom_fields = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING updated_at`,
+		category.Name, category.Description, category.ParentID, customFieldsJSON, id).Scan(&category.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("category already exists")
+		}
+		return nil, fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return category, nil
+}
+
 // ListCategories retrieves all asset categories
 func (s *Service) ListCategories(ctx context.Context) ([]AssetCategory, error) {
 	query := `
@@ -149,6 +210,9 @@ func (s *Service) CreateAsset(ctx context.Context, req CreateAssetRequest, creat
 	if asset.CustomFields == nil {
 		asset.CustomFields = make(map[string]interface{})
 	}
+	if err := s.validateCustomFields(ctx, asset.CategoryID, asset.CustomFields); err != nil {
+		return nil, err
+	}
 
 	customFieldsJSON, _ := json.Marshal(asset.CustomFields)
 
@@ -191,6 +255,7 @@ func (s *Service) GetAsset(ctx context.Context, id uuid.UUID) (*Asset, error) {
 			a.id, a.asset_tag, a.name, a.description, a.category_id, a.status, a.condition,
 			a.purchase_price, a.purchase_date, a.warranty_expiry, a.depreciation_rate, a.current_value,
 			a.serial_number, a.model, a.manufacturer, a.location, a.assigned_to_user_id, a.assigned_at,
+			a.received_at, a.disposal_method, a.disposed_on,
 			a.custom_fields, a.created_by, a.created_at, a.updated_at,
 			c.id, c.name, c.description,
 			u.id, u.email, u.display_name,
@@ -213,6 +278,27 @@ func (s *Service) GetAsset(ctx context.Context, id uuid.UUID) (*Asset, error) {
 	return asset, nil
 }
 
+// validateCustomFields checks an asset's custom field values against the
+// schema of its category. Assets without a category take any values.
+func (s *Service) validateCustomFields(ctx context.Context, categoryID *uuid.UUID, values map[string]interface{}) error {
+	if categoryID == nil {
+		return nil
+	}
+	var raw []byte
+	err := s.db.QueryRow(ctx, `SELECT custom_fields FROM asset_categories WHERE id = $1`, *categoryID).Scan(&raw)
+	if err == pgx.ErrNoRows {
+		return fmt.Errorf("category not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load category schema: %w", err)
+	}
+	schema, err := customfields.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("failed to load category schema: %w", err)
+	}
+	return schema.Validate(values)
+}
+
 // UpdateAsset updates an existing asset
 func (s *Service) UpdateAsset(ctx context.Context, id uuid.UUID, req UpdateAssetRequest, updatedBy uuid.UUID) (*Asset, error) {
 	// Get current asset for comparison
@@ -221,6 +307,20 @@ func (s *Service) UpdateAsset(ctx context.Context, id uuid.UUID, req UpdateAsset
 		return nil, err
 	}
 
+	// Check custom fields against the schema of the category the asset ends up in
+	if req.CustomFields != nil || req.CategoryID != nil {
+		categoryID, values := currentAsset.CategoryID, currentAsset.CustomFields
+		if req.CategoryID != nil {
+			categoryID = req.CategoryID
+		}
+		if req.CustomFields != nil {
+			values = req.CustomFields
+		}
+		if err := s.validateCustomFields(ctx, categoryID, values); err != nil {
+			return nil, err
+		}
+	}
+
 	// Build update query dynamically
 	setParts := []string{"updated_at = NOW()"}
 	args := []interface{}{}
@@ -253,12 +353,29 @@ func (s *Service) UpdateAsset(ctx context.Context, id uuid.UUID, req UpdateAsset
 		newValues["category_id"] = req.CategoryID
 	}
 
-	if req.Status != nil && *req.Status != currentAsset.Status {
+	statusChanged := req.Status != nil && *req.Status != currentAsset.Status
+	if statusChanged {
+		if err := checkTransition(currentAsset, req, time.Now()); err != nil {
+			return nil, err
+		}
 		setParts = append(setParts, fmt.Sprintf("status = $%d", argIndex))
 		args = append(args, *req.Status)
 		argIndex++
 		oldValues["status"] = currentAsset.Status
 		newValues["status"] = *req.Status
+
+		switch *req.Status {
+		case AssetStatusReceived:
+			setParts = append(setParts, "received_at = NOW()")
+		case AssetStatusDisposed:
+			setParts = append(setParts, fmt.Sprintf("disposal_method = $%d, disposed_on = $%d", argIndex, argIndex+1))
+			args = append(args, *req.DisposalMethod, req.DisposedOn.Format(time.DateOnly))
+			argIndex += 2
+			newValues["disposal_method"] = *req.DisposalMethod
+			newValues["disposed_on"] = req.DisposedOn.Format(time.DateOnly)
+		}
+	} else if req.DisposalMethod != nil || req.DisposedOn != nil {
+		return nil, &TransitionError{Code: "invalid_field", Field: "disposal_method", Message: "disposal details can only be given when disposing of an asset"}
 	}
 
 	if req.Condition != nil {
@@ -328,10 +445,16 @@ func (s *Service) UpdateAsset(ctx context.Context, id uuid.UUID, req UpdateAsset
 	// Record history if there were changes
 	if len(oldValues) > 0 {
 		action := ActionUpdated
-		if req.Status != nil && *req.Status != currentAsset.Status {
+		var notes *string
+		if statusChanged {
 			action = ActionStatusChanged
+			if *req.Status == AssetStatusDisposed {
+				action = ActionDisposed
+			}
+			notes = req.StatusReason
+			_ = s.createAuditEntry(ctx, id, updatedBy, "status_changed", "status", currentAsset.Status, *req.Status, *req.StatusReason)
 		}
-		_ = s.recordHistory(ctx, id, action, &updatedBy, oldValues, newValues, nil)
+		_ = s.recordHistory(ctx, id, action, &updatedBy, oldValues, newValues, notes)
 	}
 
 	return s.GetAsset(ctx, id)
@@ -426,6 +549,7 @@ func (s *Service) ListAssets(ctx context.Context, filters AssetSearchFilters) (*
 			a.id, a.asset_tag, a.name, a.description, a.category_id, a.status, a.condition,
 			a.purchase_price, a.purchase_date, a.warranty_expiry, a.depreciation_rate, a.current_value,
 			a.serial_number, a.model, a.manufacturer, a.location, a.assigned_to_user_id, a.assigned_at,
+			a.received_at, a.disposal_method, a.disposed_on,
 			a.custom_fields, a.created_by, a.created_at, a.updated_at,
 			c.id, c.name, c.description,
 			u.id, u.email, u.display_name,
@@ -584,7 +708,8 @@ func (s *Service) scanAssetWithJoins(row pgx.Row) (*Asset, error) {
 		&asset.Status, &asset.Condition, &asset.PurchasePrice, &asset.PurchaseDate,
 		&asset.WarrantyExpiry, &asset.DepreciationRate, &asset.CurrentValue,
 		&asset.SerialNumber, &asset.Model, &asset.Manufacturer, &asset.Location,
-		&asset.AssignedToUserID, &asset.AssignedAt, &customFieldsJSON,
+		&asset.AssignedToUserID, &asset.AssignedAt,
+		&asset.ReceivedAt, &asset.DisposalMethod, &asset.DisposedOn, &customFieldsJSON,
 		&asset.CreatedBy, &asset.CreatedAt, &asset.UpdatedAt,
 		&categoryID, &categoryName, &categoryDescription,
 		&assignedUserID, &assignedUserEmail, &assignedUserDisplayName,
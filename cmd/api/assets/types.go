@@ -10,6 +10,8 @@ import (
 type AssetStatus string
 
 const (
+	AssetStatusOrdered     AssetStatus = "ordered"
+	AssetStatusReceived    AssetStatus = "received"
 	AssetStatusActive      AssetStatus = "active"
 	AssetStatusInactive    AssetStatus = "inactive"
 	AssetStatusMaintenance AssetStatus = "maintenance"
@@ -17,6 +19,9 @@ const (
 	AssetStatusDisposed    AssetStatus = "disposed"
 )
 
+// DisposalMethods lists how a disposed asset can have left the inventory.
+var DisposalMethods = []string{"recycled", "resold", "donated", "destroyed", "returned_to_vendor"}
+
 // AssetCondition represents the physical condition of an asset
 type AssetCondition string
 
@@ -98,6 +103,11 @@ type Asset struct {
 	AssignedToUserID *uuid.UUID `json:"assigned_to_user_id" db:"assigned_to_user_id"`
 	AssignedAt       *time.Time `json:"assigned_at" db:"assigned_at"`
 
+	// Lifecycle
+	ReceivedAt     *time.Time `json:"received_at" db:"received_at"`
+	DisposalMethod *string    `json:"disposal_method" db:"disposal_method"`
+	DisposedOn     *time.Time `json:"disposed_on" db:"disposed_on"`
+
 	// Custom fields for flexibility
 	CustomFields map[string]interface{} `json:"custom_fields" db:"custom_fields"`
 
@@ -202,6 +212,13 @@ type UpdateAssetRequest struct {
 	Manufacturer     *string                `json:"manufacturer"`
 	Location         *string                `json:"location"`
 	CustomFields     map[string]interface{} `json:"custom_fields"`
+
+	// StatusReason explains a status change and is required with one.
+	StatusReason *string `json:"status_reason"`
+	// DisposalMethod and DisposedOn are required when disposing of an asset
+	// and rejected otherwise.
+	DisposalMethod *string    `json:"disposal_method"`
+	DisposedOn     *time.Time `json:"disposed_on"`
 }
 
 // AssignAssetRequest represents a request to assign an asset
@@ -0,0 +1,89 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// Warranty is an asset's warranty as last looked up from its manufacturer.
+type Warranty struct {
+	AssetID      string     `json:"asset_id"`
+	SerialNumber *string    `json:"serial_number"`
+	Vendor       *string    `json:"vendor"`
+	Status       *string    `json:"status"`
+	Coverage     *string    `json:"coverage"`
+	ExpiresOn    *string    `json:"expires_on"`
+	CheckedAt    *time.Time `json:"checked_at"`
+	Error        *string    `json:"error"`
+}
+
+// GetWarranty handles GET /assets/:id/warranty
+func GetWarranty(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var w Warranty
+		var expires *time.Time
+		err := a.DB.QueryRow(c.Request.Context(), `select id::text, serial_number, warranty_vendor, warranty_status, warranty_coverage,
+                warranty_expiry, warranty_checked_at, warranty_error
+            from assets where id::text = $1`, c.Param("id")).Scan(&w.AssetID, &w.SerialNumber, &w.Vendor, &w.Status, &w.Coverage,
+			&expires, &w.CheckedAt, &w.Error)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load warranty", nil)
+			return
+		}
+		if expires != nil {
+			d := expires.Format(time.DateOnly)
+			w.ExpiresOn = &d
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// RefreshWarranty handles POST /assets/:id/warranty/refresh. It queues a
+// lookup for the worker; repeats within a minute share one lookup.
+func RefreshWarranty(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var id string
+		var serial *string
+		err := a.DB.QueryRow(ctx, `select id::text, serial_number from assets where id::text = $1`, c.Param("id")).Scan(&id, &serial)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load asset", nil)
+			return
+		}
+		if serial == nil || *serial == "" {
+			app.AbortError(c, http.StatusConflict, "no_serial_number", "the asset has no serial number to look up", nil)
+			return
+		}
+		err = app.InTx(ctx, a.DB, func(tx app.DB) error {
+			key := fmt.Sprintf("%s:%s:%d", jobs.TypeWarrantyLookup, id, time.Now().Unix()/60)
+			if err := outbox.AddJob(ctx, tx, key, "", jobs.TypeWarrantyLookup, jobs.WarrantyLookup{AssetID: id}); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "asset", id, "warranty_refresh_requested", map[string]any{"serial_number": *serial})
+		})
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to queue warranty lookup", nil)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"asset_id": id, "status": "queued"})
+	}
+}
@@ -2,6 +2,7 @@ package assets
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -36,20 +37,19 @@ func RequestStatusChange(a *app.App) gin.HandlerFunc {
 			return
 		}
 
-		var req struct {
-			ToStatus AssetStatus `json:"to_status" binding:"required"`
-			Comments *string     `json:"comments"`
-		}
-
+		var req StatusChangeRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
 		service := NewService(a.DB.(*pgxpool.Pool))
-		workflow, err := service.RequestStatusChange(c.Request.Context(), assetID, req.ToStatus, uuid.MustParse(authUser.ID), req.Comments)
+		workflow, err := service.RequestStatusChange(c.Request.Context(), assetID, req, uuid.MustParse(authUser.ID))
 
 		if err != nil {
+			if respondTransitionError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -309,7 +309,7 @@ func CreateRelationship(a *app.App) gin.HandlerFunc {
 		relationship, err := service.CreateRelationship(c.Request.Context(), req, uuid.MustParse(authUser.ID))
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(relationshipErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
 
@@ -350,6 +350,122 @@ func GetRelationshipGraph(a *app.App) gin.HandlerFunc {
 	}
 }
 
+// ListRelationships handles GET /assets/:id/relationships
+func ListRelationships(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database not available"})
+			return
+		}
+
+		assetID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset ID"})
+			return
+		}
+
+		direction := c.DefaultQuery("direction", "both")
+		if direction != "parent" && direction != "child" && direction != "both" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be parent, child or both"})
+			return
+		}
+		relType := RelationshipType(c.Query("type"))
+		if relType != "" && !relType.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid relationship type"})
+			return
+		}
+
+		service := NewService(a.DB.(*pgxpool.Pool))
+		relationships, err := service.ListRelationships(c.Request.Context(), assetID, relType, direction)
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, relationships)
+	}
+}
+
+// DeleteRelationship handles DELETE /assets/:id/relationships/:relationshipID
+func DeleteRelationship(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database not available"})
+			return
+		}
+
+		u, ok := c.Get("user")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		authUser, ok := u.(auth.AuthUser)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		assetID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset ID"})
+			return
+		}
+		relationshipID, err := uuid.Parse(c.Param("relationshipID"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid relationship ID"})
+			return
+		}
+
+		service := NewService(a.DB.(*pgxpool.Pool))
+		err = service.DeleteRelationship(c.Request.Context(), assetID, relationshipID, uuid.MustParse(authUser.ID))
+
+		if err != nil {
+			c.JSON(relationshipErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// FindCircularDependencies handles GET /assets/relationships/cycles
+func FindCircularDependencies(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database not available"})
+			return
+		}
+
+		service := NewService(a.DB.(*pgxpool.Pool))
+		cycles, err := service.FindCircularDependencies(c.Request.Context())
+
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if cycles == nil {
+			cycles = [][]uuid.UUID{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"cycles": cycles})
+	}
+}
+
+// relationshipErrorStatus maps the relationship service's validation
+// errors to HTTP statuses.
+func relationshipErrorStatus(err error) int {
+	switch err.Error() {
+	case "invalid relationship type", "cannot create relationship between asset and itself":
+		return http.StatusBadRequest
+	case "one or both assets do not exist", "relationship not found":
+		return http.StatusNotFound
+	case "relationship already exists", "circular dependency detected":
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // GetAssetImpactAnalysis handles GET /assets/:id/impact-analysis
 func GetAssetImpactAnalysis(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -465,8 +581,14 @@ func ImportAssetsPreview(a *app.App) gin.HandlerFunc {
 		}
 		defer file.Close()
 
+		duplicates := c.DefaultPostForm("duplicates", DuplicateSkip)
+		if !slices.Contains(DuplicateStrategies, duplicates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duplicates must be skip, update or merge"})
+			return
+		}
+
 		service := NewService(a.DB.(*pgxpool.Pool))
-		preview, err := service.PreviewImport(c.Request.Context(), file, header.Filename)
+		preview, err := service.PreviewImport(c.Request.Context(), file, header.Filename, duplicates)
 
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -507,6 +629,11 @@ func ImportAssets(a *app.App) gin.HandlerFunc {
 		if skipValidation := c.PostForm("skip_validation"); skipValidation == "true" {
 			options["skip_validation"] = true
 		}
+		options["duplicates"] = c.DefaultPostForm("duplicates", DuplicateSkip)
+		if !slices.Contains(DuplicateStrategies, options["duplicates"].(string)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duplicates must be skip, update or merge"})
+			return
+		}
 
 		service := NewService(a.DB.(*pgxpool.Pool))
 		operation, err := service.ImportAssets(c.Request.Context(), file, uuid.MustParse(authUser.ID), options)
@@ -18,6 +18,7 @@ import (
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
 	s3svc "github.com/mark3748/helpdesk-go/internal/s3"
 	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
 )
 
 func List(a *app.App) gin.HandlerFunc {
@@ -26,8 +27,10 @@ func List(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusOK, []any{})
 			return
 		}
-		const q = `select id::text, filename, bytes from attachments where ticket_id=$1 order by created_at asc`
-		rows, err := a.DB.Query(c.Request.Context(), q, c.Param("id"))
+		staff := authpkg.IsStaff(c)
+		const q = `select id::text, filename, bytes, is_internal, coalesce(external_url, '') from attachments
+			where ticket_id=$1 and (not is_internal or $2) order by created_at asc`
+		rows, err := a.DB.Query(c.Request.Context(), q, c.Param("id"), staff)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -37,11 +40,16 @@ func List(a *app.App) gin.HandlerFunc {
 			ID       string `json:"id"`
 			Filename string `json:"filename"`
 			Bytes    int64  `json:"bytes"`
+			// IsInternal is only reported to staff, who alone see such attachments.
+			IsInternal bool `json:"is_internal,omitempty"`
+			// ExternalURL is set for files kept elsewhere, such as call
+			// recordings held by a telephony provider.
+			ExternalURL string `json:"external_url,omitempty"`
 		}
 		var out []att
 		for rows.Next() {
 			var a1 att
-			if err := rows.Scan(&a1.ID, &a1.Filename, &a1.Bytes); err != nil {
+			if err := rows.Scan(&a1.ID, &a1.Filename, &a1.Bytes, &a1.IsInternal, &a1.ExternalURL); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -54,35 +62,11 @@ func List(a *app.App) gin.HandlerFunc {
 func Upload(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		metrics.AttachmentsUploadedTotal.Inc()
-		store, bucket := a.ResolveStore(c.Request.Context())
+		store, _ := a.ResolveStore(c.Request.Context())
 		if a.DB == nil || store == nil {
 			c.JSON(http.StatusCreated, gin.H{"id": "temp"})
 			return
 		}
-		f, header, err := c.Request.FormFile("file")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
-			return
-		}
-		defer f.Close()
-		safeName := sanitizeFilename(header.Filename)
-		if safeName == "" {
-			safeName = "file"
-		}
-		key := uuid.New().String() + "-" + safeName
-		size := header.Size
-		ct := header.Header.Get("Content-Type")
-		if ct == "" {
-			ct = mime.TypeByExtension(filepath.Ext(header.Filename))
-		}
-		oc, cancel := a.ObjCtx(c.Request.Context())
-		defer cancel()
-		if _, err := store.PutObject(oc, bucket, key, f, size, minio.PutObjectOptions{ContentType: ct}); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-		const q = `insert into attachments (ticket_id, uploader_id, object_key, filename, bytes, mime) values ($1, $2, $3, $4, $5, $6) returning id::text`
-		var id string
 		// Use current authenticated user's ID as uploader
 		var uploader string
 		if v, ok := c.Get("user"); ok {
@@ -94,15 +78,73 @@ func Upload(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
 			return
 		}
-		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("id"), uploader, key, header.Filename, size, ct).Scan(&id); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		id, ok := Save(c, a, c.Param("id"), uploader)
+		if !ok {
 			return
 		}
-		eventspkg.Emit(c.Request.Context(), a.DB, c.Param("id"), "ticket_updated", map[string]any{"id": c.Param("id")})
+		eventspkg.Emit(c.Request.Context(), a.DB, authpkg.Actor(c), c.Param("id"), "ticket_updated", map[string]any{"id": c.Param("id")})
 		c.JSON(http.StatusCreated, gin.H{"id": id})
 	}
 }
 
+// Save stores the request's multipart "file" as an attachment of ticketID
+// uploaded by uploaderID, or by nobody when it is nil, as for chat visitors.
+// It writes the error response itself and reports false on failure.
+func Save(c *gin.Context, a *app.App, ticketID string, uploaderID any) (string, bool) {
+	store, bucket := a.ResolveStore(c.Request.Context())
+	if store == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "object store not configured"})
+		return "", false
+	}
+	f, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return "", false
+	}
+	defer f.Close()
+	if tooLarge(a, header.Size) {
+		abortTooLarge(c, a)
+		return "", false
+	}
+	safeName := sanitizeFilename(header.Filename)
+	if safeName == "" {
+		safeName = "file"
+	}
+	key := uuid.New().String() + "-" + safeName
+	size := header.Size
+	ct := header.Header.Get("Content-Type")
+	if ct == "" {
+		ct = mime.TypeByExtension(filepath.Ext(header.Filename))
+	}
+	sniffed, err := sniff(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read file"})
+		return "", false
+	}
+	ct, ok := checkType(ct, sniffed)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content does not match declared type", "detected": baseType(sniffed)})
+		return "", false
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	oc, cancel := a.ObjCtx(c.Request.Context())
+	defer cancel()
+	if _, err := store.PutObject(oc, bucket, key, f, size, minio.PutObjectOptions{ContentType: ct}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	const q = `insert into attachments (ticket_id, uploader_id, object_key, filename, bytes, mime) values ($1, $2, $3, $4, $5, $6) returning id::text`
+	var id string
+	if err := a.DB.QueryRow(c.Request.Context(), q, ticketID, uploaderID, key, header.Filename, size, ct).Scan(&id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return id, true
+}
+
 func Get(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		store, bucket := a.ResolveStore(c.Request.Context())
@@ -110,12 +152,15 @@ func Get(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusOK, gin.H{"id": c.Param("attID")})
 			return
 		}
-		const q = `select object_key, filename, mime from attachments where id=$1 and ticket_id=$2`
-		var key, fn, mt string
-		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("attID"), c.Param("id")).Scan(&key, &fn, &mt); err != nil {
+		var key, fn, mt, external string
+		if err := a.DB.QueryRow(c.Request.Context(), visibleQuery, c.Param("attID"), c.Param("id"), authpkg.IsStaff(c)).Scan(&key, &fn, &mt, &external); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if external != "" {
+			c.Redirect(http.StatusFound, external)
+			return
+		}
 
 		if store == nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "object store not configured"})
@@ -128,7 +173,7 @@ func Get(a *app.App) gin.HandlerFunc {
 		if mw, ok := store.(*app.MinioWrapper); ok {
 			// Use internal S3 helper for consistent TTL
 			svc := s3svc.Service{Client: mw.Client, Bucket: bucket, MaxTTL: time.Minute}
-			u, err := svc.PresignGet(c.Request.Context(), key, sanitizeFilename(fn), time.Minute)
+			u, err := svc.PresignDownload(c.Request.Context(), key, sanitizeFilename(fn), servedType(mt, fn), time.Minute)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -151,12 +196,7 @@ func Get(a *app.App) gin.HandlerFunc {
 				c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 				return
 			}
-			if mt != "" {
-				c.Writer.Header().Set("Content-Type", mt)
-			} else {
-				c.Writer.Header().Set("Content-Type", mime.TypeByExtension(filepath.Ext(fn)))
-			}
-			c.Writer.Header().Set("Content-Disposition", "attachment; filename=\""+strings.ReplaceAll(fn, "\"", "")+"\"")
+			setDownloadHeaders(c.Writer.Header(), mt, fn)
 			_, _ = c.Writer.Write(f)
 			return
 		}
@@ -166,6 +206,11 @@ func Get(a *app.App) gin.HandlerFunc {
 	}
 }
 
+// visibleQuery loads an attachment of a ticket, hiding internal attachments
+// unless $3, whether the caller is staff, is true.
+const visibleQuery = `select object_key, filename, coalesce(mime, ''), coalesce(external_url, '') from attachments
+	where id=$1 and ticket_id=$2 and (not is_internal or $3)`
+
 func PresignUpload(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		store, bucket := a.ResolveStore(c.Request.Context())
@@ -202,7 +247,7 @@ func PresignUpload(a *app.App) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"url": u.String(), "object_key": key, "content_type": req.ContentType})
+		c.JSON(http.StatusOK, gin.H{"url": u.String(), "object_key": key, "content_type": req.ContentType, "headers": app.UploadHeaders(store, bucket, req.ContentType)})
 	}
 }
 
@@ -223,24 +268,36 @@ func PresignDownload(a *app.App) gin.HandlerFunc {
 			return
 		}
 
-		const q = `select object_key, filename, mime from attachments where id=$1 and ticket_id=$2`
-		var key, fn, mt string
-		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("attID"), c.Param("id")).Scan(&key, &fn, &mt); err != nil {
+		var key, fn, mt, external string
+		if err := a.DB.QueryRow(c.Request.Context(), visibleQuery, c.Param("attID"), c.Param("id"), authpkg.IsStaff(c)).Scan(&key, &fn, &mt, &external); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		if external != "" {
+			c.JSON(http.StatusOK, gin.H{"url": external, "content_type": servedType(mt, fn)})
+			return
+		}
 		svc := s3svc.Service{Client: mw.Client, Bucket: bucket, MaxTTL: time.Minute}
 		oc, cancel := a.ObjCtx(c.Request.Context())
 		defer cancel()
-		u, err := svc.PresignGet(oc, key, sanitizeFilename(fn), time.Minute)
+		u, err := svc.PresignDownload(oc, key, sanitizeFilename(fn), servedType(mt, fn), time.Minute)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"url": u, "content_type": mt})
+		c.JSON(http.StatusOK, gin.H{"url": u, "content_type": servedType(mt, fn)})
 	}
 }
 
+// tooLarge reports whether an upload of n bytes exceeds AttachmentMaxBytes.
+func tooLarge(a *app.App, n int64) bool {
+	return a.Cfg.AttachmentMaxBytes > 0 && n > a.Cfg.AttachmentMaxBytes
+}
+
+func abortTooLarge(c *gin.Context, a *app.App) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "attachment too large", "max_bytes": a.Cfg.AttachmentMaxBytes})
+}
+
 // sanitizeFilename removes path separators and dot segments and restricts to a
 // conservative character set, preserving the extension when possible.
 func sanitizeFilename(name string) string {
@@ -271,7 +328,11 @@ func Delete(a *app.App) gin.HandlerFunc {
 		}
 		// Remove object first when possible
 		var key string
-		_ = a.DB.QueryRow(c.Request.Context(), `select object_key from attachments where id=$1 and ticket_id=$2`, c.Param("attID"), c.Param("id")).Scan(&key)
+		var fn, mt, external string
+		if err := a.DB.QueryRow(c.Request.Context(), visibleQuery, c.Param("attID"), c.Param("id"), authpkg.IsStaff(c)).Scan(&key, &fn, &mt, &external); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 
 		store, bucket := a.ResolveStore(c.Request.Context())
 		if key != "" && store != nil {
@@ -305,16 +366,16 @@ func Presign(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if tooLarge(a, in.Bytes) {
+			abortTooLarge(c, a)
+			return
+		}
 		objectKey := uuid.New().String()
 
 		// Try using interface PresignedPutObject first
 		u, err := store.PresignedPutObject(c.Request.Context(), bucket, objectKey, time.Minute, in.Mime)
 		if err == nil && u != nil {
-			headers := map[string]string{}
-			if in.Mime != "" {
-				headers["Content-Type"] = in.Mime
-			}
-			c.JSON(http.StatusCreated, gin.H{"upload_url": u.String(), "headers": headers, "attachment_id": objectKey})
+			c.JSON(http.StatusCreated, gin.H{"upload_url": u.String(), "headers": app.UploadHeaders(store, bucket, in.Mime), "attachment_id": objectKey})
 			return
 		}
 
@@ -351,11 +412,19 @@ func UploadObject(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid object key"})
 			return
 		}
-		data, err := io.ReadAll(c.Request.Body)
+		body := io.Reader(c.Request.Body)
+		if a.Cfg.AttachmentMaxBytes > 0 {
+			body = io.LimitReader(body, a.Cfg.AttachmentMaxBytes+1)
+		}
+		data, err := io.ReadAll(body)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "read body"})
 			return
 		}
+		if tooLarge(a, int64(len(data))) {
+			abortTooLarge(c, a)
+			return
+		}
 		ct := c.GetHeader("Content-Type")
 		if ct == "" {
 			ct = "application/octet-stream"
@@ -377,6 +446,11 @@ func Finalize(a *app.App) gin.HandlerFunc {
 		Filename     string `json:"filename" binding:"required"`
 		Bytes        int64  `json:"bytes" binding:"required"`
 		Mime         string `json:"mime"`
+		// CommentID ties the attachment to a comment, whose visibility it
+		// takes. IsInternal hides it from requesters regardless; only staff
+		// may set it.
+		CommentID  string `json:"comment_id"`
+		IsInternal bool   `json:"is_internal"`
 	}
 	return func(c *gin.Context) {
 		metrics.AttachmentsUploadedTotal.Inc()
@@ -402,6 +476,17 @@ func Finalize(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment_id"})
 			return
 		}
+		var commentID *string
+		if in.CommentID != "" {
+			if _, err := uuid.Parse(in.CommentID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment_id"})
+				return
+			}
+			commentID = &in.CommentID
+		}
+		if !authpkg.IsStaff(c) {
+			in.IsInternal = false
+		}
 		var size int64
 
 		// Use StatObject interface method
@@ -418,12 +503,30 @@ func Finalize(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "upload incomplete"})
 			return
 		}
-		if _, err := a.DB.Exec(c.Request.Context(), `insert into attachments (id, ticket_id, uploader_id, object_key, filename, bytes, mime) values ($1,$2,$3,$4,$5,$6,$7)`,
-			in.AttachmentID, ticketID, au.ID, in.AttachmentID, in.Filename, in.Bytes, in.Mime); err != nil {
+		// A presigned URL does not bound the upload, so check what arrived.
+		if tooLarge(a, size) {
+			_ = store.RemoveObject(oc, bucket, in.AttachmentID, minio.RemoveObjectOptions{})
+			abortTooLarge(c, a)
+			return
+		}
+		// Nor does it bound the content type, so compare the declared type
+		// with what the content looks like.
+		if sniffed, err := sniffObject(oc, store, bucket, in.AttachmentID); err != nil {
+			log.Warn().Err(err).Str("object_key", in.AttachmentID).Msg("sniff attachment type")
+		} else if mt, ok := checkType(in.Mime, sniffed); ok {
+			in.Mime = mt
+		} else {
+			_ = store.RemoveObject(oc, bucket, in.AttachmentID, minio.RemoveObjectOptions{})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "content does not match declared type", "detected": baseType(sniffed)})
+			return
+		}
+		if _, err := a.DB.Exec(c.Request.Context(), `insert into attachments (id, ticket_id, uploader_id, object_key, filename, bytes, mime, comment_id, is_internal)
+			values ($1,$2,$3,$4,$5,$6,$7,$8,$9 or coalesce((select tc.is_internal from ticket_comments tc where tc.id=$8 and tc.ticket_id=$2), false))`,
+			in.AttachmentID, ticketID, au.ID, in.AttachmentID, in.Filename, in.Bytes, in.Mime, commentID, in.IsInternal); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		eventspkg.Emit(c.Request.Context(), a.DB, ticketID, "ticket_updated", map[string]any{"id": ticketID})
+		eventspkg.Emit(c.Request.Context(), a.DB, authpkg.Actor(c), ticketID, "ticket_updated", map[string]any{"id": ticketID})
 		c.JSON(http.StatusCreated, gin.H{"id": in.AttachmentID})
 	}
 }
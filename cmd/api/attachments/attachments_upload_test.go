@@ -46,3 +46,21 @@ func TestUploadObject_Filesystem_Success(t *testing.T) {
 		t.Fatalf("expected 200, got %d body=%s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestUploadObject_TooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	cfg := apppkg.Config{Env: "test", TestBypassAuth: true, MinIOBucket: "attachments", ObjectStoreTimeoutMS: 500, AttachmentMaxBytes: 4}
+	a := apppkg.NewApp(cfg, nil, nil, &apppkg.FsObjectStore{Base: dir}, nil)
+
+	key := "123e4567-e89b-12d3-a456-426614174000"
+	h := UploadObject(a)
+	rr := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rr)
+	c.Request = httptest.NewRequest(http.MethodPut, "/attachments/upload/"+key, bytes.NewReader([]byte("hello")))
+	c.Params = gin.Params{{Key: "objectKey", Value: key}}
+	h(c)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d body=%s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,136 @@
+package attachments
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// activeTypes are rendered by browsers as documents that can run script.
+// Attachments of these types are only ever served as opaque downloads.
+var activeTypes = map[string]bool{
+	"text/html":                true,
+	"application/xhtml+xml":    true,
+	"image/svg+xml":            true,
+	"text/xml":                 true,
+	"application/xml":          true,
+	"text/xsl":                 true,
+	"text/javascript":          true,
+	"application/javascript":   true,
+	"application/x-javascript": true,
+}
+
+// typeAliases maps non-standard MIME types that clients commonly declare to
+// the names http.DetectContentType uses.
+var typeAliases = map[string]string{
+	"image/jpg":                    "image/jpeg",
+	"image/pjpeg":                  "image/jpeg",
+	"image/vnd.microsoft.icon":     "image/x-icon",
+	"audio/mp3":                    "audio/mpeg",
+	"application/x-zip-compressed": "application/zip",
+	"application/x-pdf":            "application/pdf",
+}
+
+// textTypes are non-text/* types whose content sniffs as plain text.
+var textTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-yaml":     true,
+	"application/yaml":       true,
+	"application/x-sh":       true,
+	"application/rtf":        true,
+	"image/svg+xml":          true,
+}
+
+// baseType returns the lowercased type of a MIME type without parameters,
+// or "" when mt does not parse.
+func baseType(mt string) string {
+	t, _, err := mime.ParseMediaType(mt)
+	if err != nil {
+		return ""
+	}
+	if alias, ok := typeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// checkType reconciles the MIME type declared for an upload with the one
+// sniffed from its content and returns the type to store. It reports false
+// when the content is plainly something else, such as HTML declared as an
+// image. Content the sniffer cannot identify is taken at its word.
+func checkType(declared, sniffed string) (string, bool) {
+	s := baseType(sniffed)
+	if strings.TrimSpace(declared) == "" {
+		return s, true
+	}
+	d := baseType(declared)
+	switch {
+	case d == "":
+		return "", false
+	case d == s, s == "application/octet-stream", d == "application/octet-stream":
+		return d, true
+	case s == "text/plain":
+		return d, (strings.HasPrefix(d, "text/") && d != "text/html") || textTypes[d] ||
+			strings.HasSuffix(d, "+json") || strings.HasSuffix(d, "+xml")
+	case s == "text/xml":
+		return d, d == "application/xml" || strings.HasSuffix(d, "+xml")
+	case s == "application/zip":
+		// Office documents, jars and other zip containers.
+		return d, strings.HasPrefix(d, "application/") && !activeTypes[d]
+	}
+	for _, family := range []string{"image/", "audio/", "video/"} {
+		if strings.HasPrefix(s, family) && strings.HasPrefix(d, family) && !activeTypes[d] {
+			return d, true
+		}
+	}
+	return d, false
+}
+
+// sniffObject detects the type of a stored object from its first bytes.
+func sniffObject(ctx context.Context, store app.ObjectStore, bucket, key string) (string, error) {
+	r, err := app.OpenObject(ctx, store, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	return sniff(r)
+}
+
+func sniff(r io.Reader) (string, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// servedType is the Content-Type an attachment is downloaded with. Types a
+// browser would render as an active document, and unknown types, are served
+// as application/octet-stream.
+func servedType(mt, filename string) string {
+	t := baseType(mt)
+	if t == "" {
+		t = baseType(mime.TypeByExtension(filepath.Ext(filename)))
+	}
+	if t == "" || activeTypes[t] {
+		return "application/octet-stream"
+	}
+	return t
+}
+
+// setDownloadHeaders marks a response as an attachment that browsers must
+// neither sniff nor render.
+func setDownloadHeaders(h http.Header, mt, filename string) {
+	h.Set("Content-Type", servedType(mt, filename))
+	h.Set("Content-Disposition", "attachment; filename=\""+sanitizeFilename(filename)+"\"")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Security-Policy", "default-src 'none'; sandbox")
+}
@@ -0,0 +1,134 @@
+package attachments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCheckType(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n"
+	for _, tc := range []struct {
+		declared, content, want string
+		ok                      bool
+	}{
+		{"image/png", png, "image/png", true},
+		{"image/jpg", "\xff\xd8\xff", "image/jpeg", true},
+		{"", "<html><script>alert(1)</script>", "text/html", true},
+		{"image/png", "<html><script>alert(1)</script>", "image/png", false},
+		{"text/plain", "<!DOCTYPE html><p>hi", "text/plain", false},
+		{"image/svg+xml", `<svg xmlns="http://www.w3.org/2000/svg"/>`, "image/svg+xml", true},
+		{"image/svg+xml", png, "image/svg+xml", false},
+		{"text/csv; charset=utf-8", "a,b\n1,2\n", "text/csv", true},
+		{"application/json", `{"a":1}`, "application/json", true},
+		{"application/pdf", "hello", "application/pdf", false},
+		{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "PK\x03\x04", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", true},
+		{"application/msword", "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "application/msword", true},
+		{"application/octet-stream", png, "application/octet-stream", true},
+		{"not a type", png, "", false},
+	} {
+		got, ok := checkType(tc.declared, http.DetectContentType([]byte(tc.content)))
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("checkType(%q, %q) = %q, %v; want %q, %v", tc.declared, tc.content, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestServedType(t *testing.T) {
+	for mt, want := range map[string]string{
+		"image/png":                "image/png",
+		"text/html; charset=utf-8": "application/octet-stream",
+		"image/svg+xml":            "application/octet-stream",
+		"application/xhtml+xml":    "application/octet-stream",
+		"":                         "application/octet-stream",
+		"text/plain":               "text/plain",
+	} {
+		if got := servedType(mt, "file"); got != want {
+			t.Errorf("servedType(%q) = %q, want %q", mt, got, want)
+		}
+	}
+	if got := servedType("", "page.html"); got != "application/octet-stream" {
+		t.Errorf("expected an untyped HTML file to be served opaquely, got %q", got)
+	}
+}
+
+func TestFinalizeRejectsMismatchedContent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	key := "123e4567-e89b-12d3-a456-426614174000"
+	body := "<html><script>alert(document.cookie)</script></html>"
+	if err := os.MkdirAll(filepath.Join(dir, "attachments"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var inserted []any
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		if strings.Contains(sql, "insert into attachments") {
+			inserted = args
+		}
+		return pgconn.CommandTag{}, nil
+	}}
+	cfg := apppkg.Config{Env: "test", MinIOBucket: "attachments", ObjectStoreTimeoutMS: 500}
+	a := apppkg.NewApp(cfg, db, nil, &apppkg.FsObjectStore{Base: dir}, nil)
+	a.R.POST("/tickets/:id/attachments", func(c *gin.Context) { c.Set("user", authpkg.AuthUser{ID: "u1", Roles: []string{"requester"}}) }, Finalize(a))
+	finalize := func(mime string) int {
+		if err := os.WriteFile(filepath.Join(dir, "attachments", key), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/t1/attachments", strings.NewReader(
+			`{"attachment_id":"`+key+`","filename":"cat.png","bytes":`+strconv.Itoa(len(body))+`,"mime":"`+mime+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := finalize("image/png"); code != http.StatusBadRequest || inserted != nil {
+		t.Fatalf("expected HTML declared as PNG to be rejected, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "attachments", key)); !os.IsNotExist(err) {
+		t.Fatal("expected the rejected upload to be removed")
+	}
+	if code := finalize(""); code != http.StatusCreated || inserted[6] != "text/html" {
+		t.Fatalf("expected an undeclared upload to take its sniffed type, got %d %v", code, inserted)
+	}
+}
+
+func TestDownloadHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "attachments"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "attachments", "k1"), []byte("<svg onload=alert(1)>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db := &testutil.MockDB{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+			*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = "k1", `x".svg`, "image/svg+xml"
+			return nil
+		}}
+	}}
+	cfg := apppkg.Config{Env: "test", MinIOBucket: "attachments", ObjectStoreTimeoutMS: 500}
+	a := apppkg.NewApp(cfg, db, nil, &apppkg.FsObjectStore{Base: dir}, nil)
+	a.R.GET("/tickets/:id/attachments/:attID", func(c *gin.Context) { c.Set("user", authpkg.AuthUser{Roles: []string{"agent"}}) }, Get(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets/t1/attachments/a1", nil))
+	h := rr.Header()
+	if rr.Code != http.StatusOK || h.Get("Content-Type") != "application/octet-stream" || h.Get("X-Content-Type-Options") != "nosniff" ||
+		!strings.HasPrefix(h.Get("Content-Disposition"), "attachment; ") || strings.Count(h.Get("Content-Disposition"), `"`) != 2 ||
+		!strings.Contains(h.Get("Content-Security-Policy"), "sandbox") {
+		t.Fatalf("unexpected download %d %v", rr.Code, h)
+	}
+}
@@ -0,0 +1,84 @@
+package attachments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestInternalAttachmentsStaffOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "attachments"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "attachments", "k1"), []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var listArgs []any
+	deleted := 0
+	// One internal attachment, k1, which the fake database hides unless the
+	// staff argument is true.
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			listArgs = args
+			return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if !strings.Contains(sql, "not is_internal or $3") {
+				t.Fatalf("expected internal attachments to be filtered: %s", sql)
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[2] != true {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = "k1", "notes.txt", "text/plain"
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			deleted++
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	for _, tc := range []struct {
+		roles []string
+		staff bool
+		want  int
+	}{{[]string{"agent"}, true, http.StatusOK}, {[]string{"requester"}, false, http.StatusNotFound}} {
+		cfg := apppkg.Config{Env: "test", MinIOBucket: "attachments", ObjectStoreTimeoutMS: 500}
+		a := apppkg.NewApp(cfg, db, nil, &apppkg.FsObjectStore{Base: dir}, nil)
+		setUser := func(c *gin.Context) { c.Set("user", authpkg.AuthUser{ID: "u1", Roles: tc.roles}) }
+		a.R.GET("/tickets/:id/attachments", setUser, List(a))
+		a.R.GET("/tickets/:id/attachments/:attID", setUser, Get(a))
+		a.R.DELETE("/tickets/:id/attachments/:attID", setUser, Delete(a))
+
+		a.R.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tickets/t1/attachments", nil))
+		if listArgs[1] != tc.staff {
+			t.Fatalf("%v: expected internal attachments listed = %v", tc.roles, tc.staff)
+		}
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets/t1/attachments/a1", nil))
+		if rr.Code != tc.want {
+			t.Fatalf("%v: expected download %d, got %d", tc.roles, tc.want, rr.Code)
+		}
+		deleted = 0
+		rr = httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/tickets/t1/attachments/a1", nil))
+		if rr.Code != tc.want || (deleted == 1) != tc.staff {
+			t.Fatalf("%v: expected delete %d, got %d with %d deletes", tc.roles, tc.want, rr.Code, deleted)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// Access is a row of ticket_access_log: one read of a sensitive ticket.
+type Access struct {
+	ID        string    `json:"id"`
+	TicketID  string    `json:"ticket_id"`
+	ActorType string    `json:"actor_type"`
+	ActorID   *string   `json:"actor_id"`
+	Email     string    `json:"email,omitempty"`
+	Resource  string    `json:"resource"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// RecordAccess logs successful reads of a sensitive ticket, named by :id, to
+// ticket_access_log once the handler has run. resource says what was read,
+// such as "ticket" or "attachment". Reads of other tickets cost one no-op
+// insert. Failures are logged and never fail the request.
+func RecordAccess(a *apppkg.App, resource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if a.DB == nil || c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		id := c.Param("id")
+		if _, err := uuid.Parse(id); err != nil {
+			return
+		}
+		act := authpkg.Actor(c)
+		var email string
+		if v, ok := c.Get("user"); ok {
+			if u, ok := v.(authpkg.AuthUser); ok {
+				email = u.Email
+			}
+		}
+		if _, err := a.DB.Exec(c.Request.Context(), `insert into ticket_access_log (ticket_id, actor_type, actor_id, email, resource, ip, user_agent)
+            select id, $2, $3, nullif($4,''), $5, nullif($6,''), nullif($7,'') from tickets where id = $1 and sensitive`,
+			id, act.Type, act.DBID(), email, resource, c.ClientIP(), c.Request.UserAgent()); err != nil {
+			log.Error().Err(err).Str("ticket_id", id).Msg("record ticket access")
+		}
+	}
+}
+
+// AccessLog queries ticket_access_log, newest first. Filters: ticket_id (or
+// the :id path parameter), actor_id, resource, before (RFC 3339) and limit.
+func AccessLog(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var where []string
+		var args []any
+		filters := map[string]string{"ticket_id": c.Query("ticket_id"), "actor_id": c.Query("actor_id"), "resource": c.Query("resource")}
+		if id := c.Param("id"); id != "" {
+			filters["ticket_id"] = id
+		}
+		for _, f := range []string{"ticket_id", "actor_id", "resource"} {
+			if v := strings.TrimSpace(filters[f]); v != "" {
+				args = append(args, v)
+				where = append(where, fmt.Sprintf("%s::text = $%d", f, len(args)))
+			}
+		}
+		limit, where, args, ok := page(c, where, args)
+		if !ok {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"accesses": []Access{}})
+			return
+		}
+		sql := `select id::text, ticket_id::text, actor_type, actor_id::text, coalesce(email,''), resource,
+            coalesce(ip,''), coalesce(user_agent,''), at from ticket_access_log`
+		if len(where) > 0 {
+			sql += " where " + strings.Join(where, " and ")
+		}
+		sql += " order by at desc, id desc limit " + strconv.Itoa(limit)
+		rows, err := a.DB.Query(c.Request.Context(), sql, args...)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query access log", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Access{}
+		for rows.Next() {
+			var r Access
+			if err := rows.Scan(&r.ID, &r.TicketID, &r.ActorType, &r.ActorID, &r.Email, &r.Resource, &r.IP, &r.UserAgent, &r.At); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query access log", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, gin.H{"accesses": out})
+	}
+}
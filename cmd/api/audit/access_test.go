@@ -0,0 +1,89 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestRecordAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const ticket = "11111111-1111-1111-1111-111111111111"
+	const user = "22222222-2222-2222-2222-222222222222"
+	var logged [][]any
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		if !strings.Contains(sql, "insert into ticket_access_log") || !strings.Contains(sql, "where id = $1 and sensitive") {
+			t.Fatalf("unexpected sql: %s", sql)
+		}
+		logged = append(logged, args)
+		return pgconn.CommandTag{}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	status := http.StatusOK
+	a.R.GET("/tickets/:id", func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: user, Email: "ann@acme.io", Roles: []string{"agent"}})
+	}, RecordAccess(a, "ticket"), func(c *gin.Context) { c.Status(status) })
+
+	get := func(id string) {
+		req := httptest.NewRequest(http.MethodGet, "/tickets/"+id, nil)
+		req.Header.Set("User-Agent", "curl/8")
+		a.R.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	get(ticket)
+	if len(logged) != 1 {
+		t.Fatalf("expected one access, got %d", len(logged))
+	}
+	args := logged[0]
+	if args[0] != ticket || args[1] != "user" || args[2] != user || args[3] != "ann@acme.io" || args[4] != "ticket" || args[6] != "curl/8" {
+		t.Fatalf("unexpected access %v", args)
+	}
+	get("not-a-uuid")
+	status = http.StatusNotFound
+	get(ticket)
+	if len(logged) != 1 {
+		t.Fatalf("expected failed and malformed reads to go unlogged, got %d", len(logged))
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		gotSQL, gotArgs = sql, args
+		return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/access-log", authpkg.Middleware(a), AccessLog(a))
+	a.R.GET("/tickets/:id/access-log", authpkg.Middleware(a), AccessLog(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/access-log?actor_id=u1&before=2026-01-02T03:04:05Z&limit=10", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"accesses":[]}` {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(gotSQL, "actor_id::text = $1 and at < $2") || !strings.HasSuffix(gotSQL, "limit 10") || gotArgs[0] != "u1" {
+		t.Fatalf("unexpected query %s %v", gotSQL, gotArgs)
+	}
+
+	a.R.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tickets/t1/access-log?ticket_id=t2", nil))
+	if !strings.Contains(gotSQL, "ticket_id::text = $1") || len(gotArgs) != 1 || gotArgs[0] != "t1" {
+		t.Fatalf("expected the path ticket to win: %s %v", gotSQL, gotArgs)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/access-log?limit=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid limit, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+)
+
+// FieldChange is the before/after value of one field, mirroring the assets
+// audit model.
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue any    `json:"old_value"`
+	NewValue any    `json:"new_value"`
+	Type     string `json:"type"` // "created", "updated", "deleted"
+}
+
+// Event is a row of audit_events.
+type Event struct {
+	ID         string          `json:"id"`
+	ActorType  string          `json:"actor_type"`
+	ActorID    *string         `json:"actor_id"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Action     string          `json:"action"`
+	Changes    []FieldChange   `json:"changes,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+	At         time.Time       `json:"at"`
+}
+
+// Execer is the subset of app.DB needed to record events.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Diff compares before and after and returns one FieldChange per field whose
+// value differs, ordered by field name. Fields missing from before are
+// "created"; fields missing from after are "deleted".
+func Diff(before, after map[string]any) []FieldChange {
+	var out []FieldChange
+	for k, nv := range after {
+		ov, ok := before[k]
+		switch {
+		case !ok:
+			out = append(out, FieldChange{Field: k, NewValue: nv, Type: "created"})
+		case !reflect.DeepEqual(ov, nv):
+			out = append(out, FieldChange{Field: k, OldValue: ov, NewValue: nv, Type: "updated"})
+		}
+	}
+	for k, ov := range before {
+		if _, ok := after[k]; !ok {
+			out = append(out, FieldChange{Field: k, OldValue: ov, Type: "deleted"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+// Record inserts an audit event attributed to act whose diff_json is
+// {"changes": [...]}. Nothing is written when changes is empty.
+func Record(ctx context.Context, db Execer, act actor.Actor, entityType, entityID, action string, changes []FieldChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return RecordDiff(ctx, db, act, entityType, entityID, action, map[string]any{"changes": changes})
+}
+
+// RecordDiff inserts an audit event attributed to act with an arbitrary
+// diff_json payload.
+func RecordDiff(ctx context.Context, db Execer, act actor.Actor, entityType, entityID, action string, diff any) error {
+	b, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(ctx, `insert into audit_events (actor_type, actor_id, entity_type, entity_id, action, diff_json) values ($1, $2, $3, $4, $5, $6)`,
+		act.Type, act.DBID(), entityType, entityID, action, b)
+	return err
+}
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// page applies the limit and before query parameters, adding the latter to
+// where. It aborts the request and reports false when either is invalid.
+func page(c *gin.Context, where []string, args []any) (int, []string, []any, bool) {
+	limit := defaultLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid limit", nil)
+			return 0, nil, nil, false
+		}
+		limit = min(n, maxLimit)
+	}
+	if v := c.Query("before"); v != "" {
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid before", nil)
+			return 0, nil, nil, false
+		}
+		args = append(args, ts)
+		where = append(where, fmt.Sprintf("at < $%d", len(args)))
+	}
+	return limit, where, args, true
+}
+
+// query lists audit events matching where, newest first.
+func query(c *gin.Context, a *apppkg.App, where []string, args []any) {
+	limit, where, args, ok := page(c, where, args)
+	if !ok {
+		return
+	}
+	if a.DB == nil {
+		c.JSON(http.StatusOK, gin.H{"events": []Event{}})
+		return
+	}
+	sql := `select id::text, coalesce(actor_type,''), actor_id::text, coalesce(entity_type,''), coalesce(entity_id::text,''),
+        coalesce(action,''), diff_json, at from audit_events`
+	if len(where) > 0 {
+		sql += " where " + strings.Join(where, " and ")
+	}
+	sql += " order by at desc, id desc limit " + strconv.Itoa(limit)
+	rows, err := a.DB.Query(c.Request.Context(), sql, args...)
+	if err != nil {
+		apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query audit events", nil)
+		return
+	}
+	defer rows.Close()
+	out := []Event{}
+	for rows.Next() {
+		var e Event
+		var diff []byte
+		if err := rows.Scan(&e.ID, &e.ActorType, &e.ActorID, &e.EntityType, &e.EntityID, &e.Action, &diff, &e.At); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query audit events", nil)
+			return
+		}
+		if len(diff) > 0 {
+			var d struct {
+				Changes []FieldChange `json:"changes"`
+			}
+			if json.Unmarshal(diff, &d) == nil && len(d.Changes) > 0 {
+				e.Changes = d.Changes
+			} else {
+				e.Diff = diff
+			}
+		}
+		out = append(out, e)
+	}
+	c.JSON(http.StatusOK, gin.H{"events": out})
+}
+
+// List queries audit events. Filters: entity_type, entity_id, actor_id,
+// actor_type ("system" matches every system job), action (repeatable),
+// before (RFC 3339) and limit.
+func List(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var where []string
+		var args []any
+		if v := strings.TrimSpace(c.Query("actor_type")); v != "" {
+			if v == "system" {
+				where = append(where, "actor_type like 'system:%'")
+			} else {
+				args = append(args, v)
+				where = append(where, fmt.Sprintf("actor_type = $%d", len(args)))
+			}
+		}
+		for _, f := range []string{"entity_type", "entity_id", "actor_id"} {
+			if v := strings.TrimSpace(c.Query(f)); v != "" {
+				args = append(args, v)
+				where = append(where, fmt.Sprintf("%s::text = $%d", f, len(args)))
+			}
+		}
+		if acts := c.QueryArray("action"); len(acts) > 0 {
+			args = append(args, acts)
+			where = append(where, fmt.Sprintf("action = any($%d)", len(args)))
+		}
+		query(c, a, where, args)
+	}
+}
+
+// TicketTimeline returns the audit trail of one ticket, newest first.
+func TicketTimeline(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query(c, a, []string{"entity_type = 'ticket'", "entity_id::text = $1"}, []any{c.Param("id")})
+	}
+}
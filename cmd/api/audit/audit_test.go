@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestDiff(t *testing.T) {
+	got := Diff(
+		map[string]any{"status": "New", "priority": 2, "label": "x"},
+		map[string]any{"status": "Open", "priority": 2, "assignee_id": "u1"},
+	)
+	want := []FieldChange{
+		{Field: "assignee_id", NewValue: "u1", Type: "created"},
+		{Field: "label", OldValue: "x", Type: "deleted"},
+		{Field: "status", OldValue: "New", NewValue: "Open", Type: "updated"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	if Diff(map[string]any{"a": 1}, map[string]any{"a": 1}) != nil {
+		t.Fatalf("expected no changes for equal maps")
+	}
+}
+
+func TestList(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		gotSQL, gotArgs = sql, args
+		diffs := [][]byte{
+			[]byte(`{"changes":[{"field":"priority","old_value":3,"new_value":1,"type":"updated"}]}`),
+			[]byte(`{"ticket_id":"t1","changed":true}`),
+		}
+		i := 0
+		return &testutil.MockRows{
+			NextFunc: func() bool { i++; return i <= len(diffs) },
+			ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "e1"
+				*dest[1].(*string) = "user"
+				*dest[3].(*string) = "ticket"
+				*dest[4].(*string) = "t1"
+				*dest[5].(*string) = "ticket_updated"
+				*dest[6].(*[]byte) = diffs[i-1]
+				*dest[7].(*time.Time) = time.Now()
+				return nil
+			},
+		}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/audit", authpkg.Middleware(a), List(a))
+	a.R.GET("/tickets/:id/audit", authpkg.Middleware(a), TicketTimeline(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/audit?entity_type=ticket&action=ticket_updated&action=sla_recalculated&limit=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(gotSQL, "entity_type::text = $1") || !strings.Contains(gotSQL, "action = any($2)") || !strings.HasSuffix(gotSQL, "limit 5") {
+		t.Fatalf("unexpected sql: %s", gotSQL)
+	}
+	if !reflect.DeepEqual(gotArgs[1], []string{"ticket_updated", "sla_recalculated"}) {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+	var resp struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(resp.Events) != 2 || len(resp.Events[0].Changes) != 1 || resp.Events[0].Changes[0].OldValue != float64(3) {
+		t.Fatalf("unexpected events: %+v", resp.Events)
+	}
+	if resp.Events[1].Changes != nil || len(resp.Events[1].Diff) == 0 {
+		t.Fatalf("expected raw diff for non-field event: %+v", resp.Events[1])
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets/t1/audit", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(gotSQL, "entity_type = 'ticket'") || gotArgs[0] != "t1" {
+		t.Fatalf("timeline: code %d sql %s args %v", rr.Code, gotSQL, gotArgs)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/audit?before=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid before, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// SecurityEvent is a row of security_events: a request refused with 401,
+// 403 or 429.
+type SecurityEvent struct {
+	ID        string    `json:"id"`
+	ActorType string    `json:"actor_type"`
+	ActorID   *string   `json:"actor_id"`
+	Email     string    `json:"email,omitempty"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Reason    string    `json:"reason"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// DenialReasonKey is the context key under which middleware that refuses a
+// request without AbortError, such as a rate limiter, says why.
+const DenialReasonKey = "denial_reason"
+
+// denialRepeat is how long repeats of a denial go unrecorded; maxDenialKeys
+// bounds the memory spent remembering them.
+const (
+	denialRepeat  = time.Minute
+	maxDenialKeys = 10000
+)
+
+// denialReason says why c was refused: the reason set under
+// DenialReasonKey, else the AbortError code, else the status text.
+func denialReason(c *gin.Context) string {
+	if v, ok := c.Get(DenialReasonKey); ok {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	if v, ok := c.Get("app_error"); ok {
+		if e, ok := v.(*apppkg.Error); ok && e.Code != "" {
+			return e.Code
+		}
+	}
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(c.Writer.Status()), " ", "_"))
+}
+
+// RecordDenials logs requests answered 401, 403 or 429 to security_events
+// once the handler has run: who made them, on which route and why.
+// Repeats by the same caller on the same route for the same reason are
+// recorded once a minute, so a client hammering a limit cannot flood the
+// table. Failures are logged and never fail the request.
+func RecordDenials(a *apppkg.App) gin.HandlerFunc {
+	var mu sync.Mutex
+	seen := map[string]time.Time{}
+	return func(c *gin.Context) {
+		c.Next()
+		status := c.Writer.Status()
+		if a.DB == nil || (status != http.StatusUnauthorized && status != http.StatusForbidden && status != http.StatusTooManyRequests) {
+			return
+		}
+		actorType, actorID, email := "anonymous", any(nil), ""
+		if act := authpkg.Actor(c); !act.IsSystem() {
+			actorType, actorID = act.Type, act.DBID()
+		}
+		if v, ok := c.Get("user"); ok {
+			if u, ok := v.(authpkg.AuthUser); ok {
+				email = u.Email
+			}
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		reason := denialReason(c)
+		key := strings.Join([]string{actorType, fmt.Sprint(actorID), email, c.ClientIP(), c.Request.Method, route, strconv.Itoa(status), reason}, "|")
+		now := time.Now()
+		mu.Lock()
+		if len(seen) >= maxDenialKeys {
+			seen = map[string]time.Time{}
+		}
+		last, repeat := seen[key]
+		repeat = repeat && now.Sub(last) < denialRepeat
+		if !repeat {
+			seen[key] = now
+		}
+		mu.Unlock()
+		if repeat {
+			return
+		}
+		if _, err := a.DB.Exec(c.Request.Context(), `insert into security_events (actor_type, actor_id, email, method, route, path, status, reason, ip, user_agent)
+            values ($1, $2, nullif($3,''), $4, $5, $6, $7, $8, nullif($9,''), nullif($10,''))`,
+			actorType, actorID, email, c.Request.Method, route, c.Request.URL.Path, status, reason, c.ClientIP(), c.Request.UserAgent()); err != nil {
+			log.Error().Err(err).Str("route", route).Msg("record security event")
+		}
+	}
+}
+
+// SecurityLog queries security_events, newest first. Filters: actor_id,
+// email (ignoring case), status, method, route, path, reason, ip, after
+// and before (RFC 3339) and limit.
+func SecurityLog(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var where []string
+		var args []any
+		for _, f := range []string{"actor_id", "status", "method", "route", "path", "reason", "ip"} {
+			if v := strings.TrimSpace(c.Query(f)); v != "" {
+				if f == "method" {
+					v = strings.ToUpper(v)
+				}
+				args = append(args, v)
+				where = append(where, fmt.Sprintf("%s::text = $%d", f, len(args)))
+			}
+		}
+		if v := strings.TrimSpace(c.Query("email")); v != "" {
+			args = append(args, v)
+			where = append(where, fmt.Sprintf("lower(email) = lower($%d)", len(args)))
+		}
+		if v := c.Query("after"); v != "" {
+			ts, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid after", nil)
+				return
+			}
+			args = append(args, ts)
+			where = append(where, fmt.Sprintf("at >= $%d", len(args)))
+		}
+		limit, where, args, ok := page(c, where, args)
+		if !ok {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"events": []SecurityEvent{}})
+			return
+		}
+		sql := `select id::text, actor_type, actor_id::text, coalesce(email,''), method, route, path, status, reason,
+            coalesce(ip,''), coalesce(user_agent,''), at from security_events`
+		if len(where) > 0 {
+			sql += " where " + strings.Join(where, " and ")
+		}
+		sql += " order by at desc, id desc limit " + strconv.Itoa(limit)
+		rows, err := a.DB.Query(c.Request.Context(), sql, args...)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query security log", nil)
+			return
+		}
+		defer rows.Close()
+		out := []SecurityEvent{}
+		for rows.Next() {
+			var r SecurityEvent
+			if err := rows.Scan(&r.ID, &r.ActorType, &r.ActorID, &r.Email, &r.Method, &r.Route, &r.Path, &r.Status, &r.Reason, &r.IP, &r.UserAgent, &r.At); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to query security log", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, gin.H{"events": out})
+	}
+}
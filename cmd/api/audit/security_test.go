@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestRecordDenials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const user = "22222222-2222-2222-2222-222222222222"
+	var logged [][]any
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		logged = append(logged, args)
+		return pgconn.CommandTag{}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.Use(RecordDenials(a))
+	signedIn := func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: user, Email: "alice@acme.io", Roles: []string{"agent"}})
+	}
+	a.R.PATCH("/tickets/:id", signedIn, authpkg.RequireRole("manager"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	a.R.GET("/tickets/:id", signedIn, func(c *gin.Context) { c.Status(http.StatusOK) })
+	a.R.POST("/login", func(c *gin.Context) {
+		c.Set(DenialReasonKey, "rate_limit:login")
+		c.AbortWithStatus(http.StatusTooManyRequests)
+	})
+
+	do := func(method, path string) {
+		a.R.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	}
+	do(http.MethodPatch, "/tickets/t1")
+	do(http.MethodPatch, "/tickets/t1")
+	do(http.MethodGet, "/tickets/t1")
+	do(http.MethodPost, "/login")
+	if len(logged) != 2 {
+		t.Fatalf("expected two denials, got %v", logged)
+	}
+	if args := logged[0]; args[0] != "user" || args[1] != user || args[2] != "alice@acme.io" || args[4] != "/tickets/:id" ||
+		args[5] != "/tickets/t1" || args[6] != http.StatusForbidden || args[7] != "forbidden" {
+		t.Fatalf("unexpected denial %v", args)
+	}
+	if args := logged[1]; args[0] != "anonymous" || args[1] != nil || args[6] != http.StatusTooManyRequests || args[7] != "rate_limit:login" {
+		t.Fatalf("unexpected denial %v", args)
+	}
+}
+
+func TestSecurityLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		gotSQL, gotArgs = sql, args
+		return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/security-log", SecurityLog(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/security-log?email=Alice@acme.io&method=patch&status=403&after=2026-10-15T00:00:00Z&limit=5", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"events":[]}` {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(gotSQL, "status::text = $1 and method::text = $2 and lower(email) = lower($3) and at >= $4") ||
+		!strings.HasSuffix(gotSQL, "limit 5") || gotArgs[1] != "PATCH" {
+		t.Fatalf("unexpected query %s %v", gotSQL, gotArgs)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/security-log?after=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -8,10 +9,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/internal/actor"
 )
 
 // AuthUser represents the authenticated user.
@@ -21,10 +24,16 @@ type AuthUser struct {
 	Email       string   `json:"email"`
 	DisplayName string   `json:"display_name"`
 	Roles       []string `json:"roles"`
+	// Permissions granted by custom roles. Built-in roles' permissions are
+	// implied by their names; see HasPermission.
+	Permissions []string `json:"permissions,omitempty"`
 }
 
 func (u AuthUser) GetRoles() []string { return u.Roles }
 
+// GetID returns the user ID.
+func (u AuthUser) GetID() string { return u.ID }
+
 // Middleware performs JWT validation or bypass during tests.
 func Middleware(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -117,6 +126,10 @@ func Middleware(a *app.App) gin.HandlerFunc {
 						}
 						// Roles from DB later
 						populateInternalUser(c, a, &u)
+						if pendingApproval(c, a, u) {
+							app.AbortError(c, http.StatusForbidden, "pending_approval", "account is awaiting admin approval", nil)
+							return
+						}
 						c.Set("user", u)
 						c.Next()
 						return
@@ -176,21 +189,34 @@ func Middleware(a *app.App) gin.HandlerFunc {
 		if u.DisplayName == "" {
 			u.DisplayName = getStringClaim(claims, "preferred_username")
 		}
-		if groups, ok := claims[a.Cfg.OIDCGroupClaim]; ok {
-			switch g := groups.(type) {
+		var groups []string
+		if v, ok := claims[a.Cfg.OIDCGroupClaim]; ok {
+			switch g := v.(type) {
 			case []interface{}:
 				for _, v := range g {
 					if s, ok := v.(string); ok {
-						u.Roles = append(u.Roles, s)
+						groups = append(groups, s)
 					}
 				}
 			case []string:
-				u.Roles = append(u.Roles, g...)
+				groups = append(groups, g...)
 			case string:
-				u.Roles = append(u.Roles, g)
+				groups = append(groups, g)
 			}
 		}
+		roles, err := MapGroups(c.Request.Context(), a.DB, groups)
+		if err != nil {
+			log.Error().Err(err).Msg("map oidc groups to roles")
+		}
+		u.Roles = append(u.Roles, roles...)
 		populateInternalUser(c, a, &u)
+		if u.ID == "" && a.DB != nil && !jitAllowed(c, a, u) {
+			return
+		}
+		if pendingApproval(c, a, u) {
+			app.AbortError(c, http.StatusForbidden, "pending_approval", "account is awaiting admin approval", nil)
+			return
+		}
 		c.Set("user", u)
 		c.Next()
 	}
@@ -282,6 +308,92 @@ where lower(u.email) = lower($1)`, u.Email)
 			u.Roles = append(u.Roles, "agent")
 		}
 	}
+	loadPermissions(c, a, u)
+}
+
+// DomainAllowed reports whether email's domain is one of domains; an empty
+// list allows every domain.
+func DomainAllowed(domains []string, email string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range domains {
+		if strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@")) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// jitAllowed applies the OIDC just-in-time onboarding policy to a bearer
+// token whose subject has no account, as the login callback does: it is
+// refused unless auto-onboarding is on and the email domain is allowed, and
+// refused as pending when onboarded accounts need approval. It aborts the
+// request and returns false when refused.
+func jitAllowed(c *gin.Context, a *app.App, u AuthUser) bool {
+	var autoOnboard, requireApproval bool
+	var domains []string
+	err := a.DB.QueryRow(c.Request.Context(), `select coalesce((oidc->>'auto_onboard')::boolean, false),
+            coalesce((oidc->>'require_approval')::boolean, false),
+            case when jsonb_typeof(oidc->'allowed_domains') = 'array'
+                then array(select jsonb_array_elements_text(oidc->'allowed_domains')) else '{}' end
+        from settings where id = 1`).Scan(&autoOnboard, &requireApproval, &domains)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		log.Error().Err(err).Msg("load oidc onboarding policy")
+		app.AbortError(c, http.StatusInternalServerError, "settings_error", "failed to load settings", nil)
+		return false
+	}
+	switch {
+	case !autoOnboard:
+		app.AbortError(c, http.StatusForbidden, "onboarding_disabled", "no account exists for this user", nil)
+	case !DomainAllowed(domains, u.Email):
+		app.AbortError(c, http.StatusForbidden, "domain_not_allowed", "email domain is not allowed", nil)
+	case requireApproval:
+		app.AbortError(c, http.StatusForbidden, "pending_approval", "account is awaiting admin approval", nil)
+	default:
+		return true
+	}
+	return false
+}
+
+// pendingApproval reports whether u was provisioned from OIDC and is still
+// waiting for an admin to approve the account.
+func pendingApproval(c *gin.Context, a *app.App, u AuthUser) bool {
+	if a.DB == nil || u.ID == "" {
+		return false
+	}
+	var pending bool
+	_ = a.DB.QueryRow(c.Request.Context(), `select pending_approval from users where id=$1`, u.ID).Scan(&pending)
+	return pending
+}
+
+// loadPermissions collects the permissions of u's custom roles.
+func loadPermissions(c *gin.Context, a *app.App, u *AuthUser) {
+	var custom []string
+	for _, r := range u.Roles {
+		if _, ok := BuiltinRoles[r]; !ok {
+			custom = append(custom, r)
+		}
+	}
+	if len(custom) == 0 {
+		return
+	}
+	rows, err := a.DB.Query(c.Request.Context(), `select distinct unnest(permissions) from roles where name = any($1)`, custom)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err == nil {
+			u.Permissions = append(u.Permissions, p)
+		}
+	}
 }
 
 func audienceContains(c jwt.MapClaims, want string) bool {
@@ -317,6 +429,38 @@ func Me(c *gin.Context) {
 	c.JSON(http.StatusOK, u)
 }
 
+// ActorKey is the context key under which non-user authenticators (such as
+// API keys) store their actor.Actor.
+const ActorKey = "actor"
+
+// Actor returns who is making the request for audit attribution: an actor
+// stored under ActorKey, else the authenticated user. System is returned for
+// unauthenticated requests.
+func Actor(c *gin.Context) actor.Actor {
+	if v, ok := c.Get(ActorKey); ok {
+		if act, ok := v.(actor.Actor); ok {
+			return act
+		}
+	}
+	if v, ok := c.Get("user"); ok {
+		if u, ok := v.(AuthUser); ok {
+			return actor.User(u.ID)
+		}
+	}
+	return actor.System("api")
+}
+
+// IsStaff reports whether the caller works tickets (agent, manager or admin)
+// rather than raising them.
+func IsStaff(c *gin.Context) bool {
+	v, _ := c.Get("user")
+	u, ok := v.(AuthUser)
+	if !ok {
+		return false
+	}
+	return hasRole(u.Roles, "agent") || hasRole(u.Roles, "manager") || hasRole(u.Roles, "admin")
+}
+
 // RequireRole ensures the user has one of the required roles.
 func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -419,6 +563,16 @@ returning id::text`
 			app.AbortError(c, http.StatusInternalServerError, "sign_token_failed", "failed to sign token", nil)
 			return
 		}
+		if a.DB != nil && uid != "" {
+			sid, newDevice, err := RecordLogin(c, a.DB, uid, "local")
+			if err != nil {
+				log.Error().Err(err).Msg("record login")
+			} else if newDevice && email != "" {
+				if err := AlertNewDevice(c, a.DB, sid, email); err != nil {
+					log.Error().Err(err).Msg("queue new device alert")
+				}
+			}
+		}
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	}
 }
@@ -429,7 +583,7 @@ func SetSessionCookie(c *gin.Context, secret, externalID, email, name string, se
 		"sub":   externalID,
 		"email": email,
 		"name":  name,
-		"exp":   time.Now().Add(24 * time.Hour).Unix(),
+		"exp":   time.Now().Add(SessionTTL).Unix(),
 		"iat":   time.Now().Unix(),
 	}
 	tk := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -444,7 +598,7 @@ func SetSessionCookie(c *gin.Context, secret, externalID, email, name string, se
 		HttpOnly: true,
 		Secure:   secure,
 		SameSite: http.SameSiteLaxMode,
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  time.Now().Add(SessionTTL),
 	})
 	return nil
 }
@@ -524,13 +678,24 @@ func AddUserRole(a *app.App) gin.HandlerFunc {
 			app.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
 			return
 		}
-		// Ensure role exists
-		_, _ = a.DB.Exec(c.Request.Context(), `insert into roles (id, name) values (gen_random_uuid(), $1) on conflict do nothing`, b.Role)
-		// Link
-		if _, err := a.DB.Exec(c.Request.Context(), `insert into user_roles (user_id, role_id) select $1, r.id from roles r where r.name=$2 on conflict do nothing`, uid, b.Role); err != nil {
+		// Built-in roles are recreated if missing; custom roles must be
+		// created through POST /roles first.
+		if _, ok := BuiltinRoles[b.Role]; ok {
+			_, _ = a.DB.Exec(c.Request.Context(), `insert into roles (id, name) values (gen_random_uuid(), $1) on conflict do nothing`, b.Role)
+		}
+		tag, err := a.DB.Exec(c.Request.Context(), `insert into user_roles (user_id, role_id) select $1, r.id from roles r where r.name=$2 on conflict do nothing`, uid, b.Role)
+		if err != nil {
 			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
 			return
 		}
+		if tag.RowsAffected() == 0 {
+			var exists bool
+			_ = a.DB.QueryRow(c.Request.Context(), `select exists (select 1 from roles where name=$1)`, b.Role).Scan(&exists)
+			if !exists {
+				app.AbortError(c, http.StatusBadRequest, "unknown_role", "unknown role", map[string]string{"role": "not defined"})
+				return
+			}
+		}
 		c.JSON(http.StatusCreated, gin.H{"ok": true})
 	}
 }
@@ -546,7 +711,29 @@ func RemoveUserRole(a *app.App) gin.HandlerFunc {
 			app.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
 			return
 		}
-		if _, err := a.DB.Exec(c.Request.Context(), `delete from user_roles using roles r where user_roles.user_id=$1 and user_roles.role_id=r.id and r.name=$2`, uid, role); err != nil {
+		ctx := c.Request.Context()
+		errLastAdmin := errors.New("last admin")
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			if role == "admin" {
+				// Lock the admin links so concurrent removals cannot both
+				// see another admin.
+				var others int
+				if err := tx.QueryRow(ctx, `select count(*) from (select ur.user_id from user_roles ur join roles r on r.id = ur.role_id
+                    where r.name = 'admin' and ur.user_id <> $1 for update of ur) s`, uid).Scan(&others); err != nil {
+					return err
+				}
+				if others == 0 {
+					return errLastAdmin
+				}
+			}
+			_, err := tx.Exec(ctx, `delete from user_roles using roles r where user_roles.user_id=$1 and user_roles.role_id=r.id and r.name=$2`, uid, role)
+			return err
+		})
+		if errors.Is(err, errLastAdmin) {
+			app.AbortError(c, http.StatusConflict, "last_admin", "at least one admin must remain", nil)
+			return
+		}
+		if err != nil {
 			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
 			return
 		}
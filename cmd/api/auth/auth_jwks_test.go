@@ -57,6 +57,13 @@ func (db *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (p
 }
 func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
 	s := strings.ToLower(sql)
+	if strings.Contains(s, "from settings") {
+		// auto-onboarding on, any domain
+		return &fakeRow{scan: func(dest ...any) error {
+			*dest[0].(*bool) = true
+			return nil
+		}}
+	}
 	if strings.Contains(s, "from users where external_id") {
 		// simulate no existing user
 		return &fakeRow{err: pgx.ErrNoRows}
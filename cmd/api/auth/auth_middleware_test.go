@@ -1,38 +1,60 @@
 package auth_test
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	apitest "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/actor"
 )
 
 func TestMiddlewarePopulatesUserFromClaims(t *testing.T) {
-	cfg := apppkg.Config{Env: "test", OIDCGroupClaim: "roles"}
+	cfg := apppkg.Config{Env: "test", OIDCGroupClaim: "groups"}
 	key := []byte("secret")
 	keyf := func(t *jwt.Token) (any, error) { return key, nil }
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":   "user-123",
-		"email": "user@example.com",
-		"name":  "User Name",
-		"roles": []string{"agent", "manager"},
+		"sub":    "user-123",
+		"email":  "user@example.com",
+		"name":   "User Name",
+		"groups": []string{"Support", "Everyone"},
 	})
 	signed, err := token.SignedString(key)
 	if err != nil {
 		t.Fatalf("sign token: %v", err)
 	}
 
-	a := apppkg.NewApp(cfg, nil, keyf, nil, nil)
+	// Support maps to agent and manager; Everyone has no mapping.
+	var groups []string
+	db := &apitest.MockDB{
+		QueryRowFunc: knownUser,
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			if !strings.Contains(sql, "oidc_group_roles") {
+				return &apitest.MockRows{}, nil
+			}
+			groups = args[0].([]string)
+			mapped := []string{"agent", "manager"}
+			i := -1
+			return &apitest.MockRows{
+				NextFunc: func() bool { i++; return i < len(mapped) },
+				ScanFunc: func(dest ...interface{}) error { *dest[0].(*string) = mapped[i]; return nil },
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(cfg, db, keyf, nil, nil)
 	a.R.GET("/me", authpkg.Middleware(a), authpkg.Me)
 
 	rr := httptest.NewRecorder()
@@ -50,11 +72,120 @@ func TestMiddlewarePopulatesUserFromClaims(t *testing.T) {
 	if u.Email != "user@example.com" || u.DisplayName != "User Name" {
 		t.Fatalf("unexpected user: %+v", u)
 	}
+	if len(groups) != 2 || groups[0] != "Support" || groups[1] != "Everyone" {
+		t.Fatalf("groups not looked up: %v", groups)
+	}
 	if len(u.Roles) != 2 || u.Roles[0] != "agent" || u.Roles[1] != "manager" {
 		t.Fatalf("roles not populated: %+v", u.Roles)
 	}
 }
 
+func TestMiddlewareIgnoresUnmappedGroups(t *testing.T) {
+	cfg := apppkg.Config{Env: "test", OIDCGroupClaim: "groups"}
+	key := []byte("secret")
+	keyf := func(t *jwt.Token) (any, error) { return key, nil }
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":    "user-123",
+		"groups": []string{"admin"},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	a := apppkg.NewApp(cfg, &apitest.MockDB{QueryRowFunc: knownUser}, keyf, nil, nil)
+	a.R.GET("/me", authpkg.Middleware(a), authpkg.Me)
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var u authpkg.AuthUser
+	if err := json.Unmarshal(rr.Body.Bytes(), &u); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(u.Roles) != 0 {
+		t.Fatalf("unmapped group granted roles: %+v", u.Roles)
+	}
+}
+
+// knownUser answers the user lookup with an existing account.
+func knownUser(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &apitest.MockRow{ScanFunc: func(dest ...interface{}) error {
+		if strings.Contains(sql, "from users where external_id") {
+			*dest[0].(*string) = "00000000-0000-0000-0000-000000000001"
+		}
+		return nil
+	}}
+}
+
+func TestMiddlewareAppliesJITPolicyToUnknownSubjects(t *testing.T) {
+	cfg := apppkg.Config{Env: "test", OIDCGroupClaim: "groups"}
+	key := []byte("secret")
+	keyf := func(t *jwt.Token) (any, error) { return key, nil }
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":    "new-user",
+		"email":  "new@outside.example",
+		"groups": []string{"Support"},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		policy   func(dest ...interface{})
+		want     int
+		wantCode string
+	}{
+		{"jit off", func(dest ...interface{}) {}, http.StatusForbidden, "onboarding_disabled"},
+		{"domain not allowed", func(dest ...interface{}) {
+			*dest[0].(*bool), *dest[2].(*[]string) = true, []string{"example.com"}
+		}, http.StatusForbidden, "domain_not_allowed"},
+		{"needs approval", func(dest ...interface{}) {
+			*dest[0].(*bool), *dest[1].(*bool) = true, true
+		}, http.StatusForbidden, "pending_approval"},
+		{"jit on", func(dest ...interface{}) {
+			*dest[0].(*bool), *dest[2].(*[]string) = true, []string{"outside.example"}
+		}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &apitest.MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+					return &apitest.MockRow{ScanFunc: func(dest ...interface{}) error {
+						if strings.Contains(sql, "from settings") {
+							tt.policy(dest...)
+						}
+						return nil
+					}}
+				},
+				QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+					if !strings.Contains(sql, "oidc_group_roles") {
+						return &apitest.MockRows{}, nil
+					}
+					i := -1
+					return &apitest.MockRows{
+						NextFunc: func() bool { i++; return i < 1 },
+						ScanFunc: func(dest ...interface{}) error { *dest[0].(*string) = "agent"; return nil },
+					}, nil
+				},
+			}
+			a := apppkg.NewApp(cfg, db, keyf, nil, nil)
+			a.R.GET("/me", authpkg.Middleware(a), authpkg.Me)
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+signed)
+			a.R.ServeHTTP(rr, req)
+			if rr.Code != tt.want || !strings.Contains(rr.Body.String(), tt.wantCode) {
+				t.Fatalf("expected %d %s, got %d %s", tt.want, tt.wantCode, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
 // Test that unauthorized requests increment the auth failure counter.
 func TestAuthFailureCounter(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -76,3 +207,19 @@ func TestAuthFailureCounter(t *testing.T) {
 		t.Fatalf("auth_failures_total = %v, want 1", v)
 	}
 }
+
+func TestActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if got := authpkg.Actor(c); got.Type != "system:api" || !got.IsSystem() {
+		t.Fatalf("unauthenticated actor = %+v", got)
+	}
+	c.Set("user", authpkg.AuthUser{ID: "7f8a1c2e-0b1d-4c3e-9f00-1a2b3c4d5e6f"})
+	if got := authpkg.Actor(c); got.Type != actor.TypeUser || got.DBID() != "7f8a1c2e-0b1d-4c3e-9f00-1a2b3c4d5e6f" {
+		t.Fatalf("user actor = %+v", got)
+	}
+	c.Set(authpkg.ActorKey, actor.APIKey("key-1"))
+	if got := authpkg.Actor(c); got.Type != actor.TypeAPIKey || got.DBID() != nil {
+		t.Fatalf("api key actor = %+v", got)
+	}
+}
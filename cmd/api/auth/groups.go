@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"context"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// MapGroups returns the internal roles mapped to the given IdP groups in
+// oidc_group_roles. Unmapped groups are ignored. Mappings are read on every
+// call so changes apply on the next token validation.
+func MapGroups(ctx context.Context, db app.DB, groups []string) ([]string, error) {
+	if db == nil || len(groups) == 0 {
+		return nil, nil
+	}
+	rows, err := db.Query(ctx, `select distinct r.name from oidc_group_roles m join roles r on r.id = m.role_id
+        where m.group_name = any($1) order by r.name`, groups)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		out = append(out, name)
+	}
+	return out, rows.Err()
+}
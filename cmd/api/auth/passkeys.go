@@ -0,0 +1,406 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/internal/webauthn"
+)
+
+// Passkey is a registered WebAuthn credential as shown to its owner.
+type Passkey struct {
+	ID                string     `json:"id"`
+	Name              string     `json:"name"`
+	AttestationFormat string     `json:"attestation_format"`
+	Transports        []string   `json:"transports"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at"`
+}
+
+// relyingParty returns the WebAuthn configuration, or false when passkeys
+// are off: they need local auth, a database and WEBAUTHN_RP_ID.
+func relyingParty(a *app.App) (webauthn.Config, bool) {
+	if strings.ToLower(a.Cfg.AuthMode) != "local" || a.Cfg.WebAuthnRPID == "" || a.DB == nil {
+		return webauthn.Config{}, false
+	}
+	return webauthn.Config{
+		RPID:        a.Cfg.WebAuthnRPID,
+		RPName:      a.Cfg.WebAuthnRPName,
+		Origins:     a.Cfg.WebAuthnOrigins,
+		Attestation: a.Cfg.WebAuthnAttestation,
+	}, true
+}
+
+// PasskeysEnabled reports whether passkey registration and login are
+// available.
+func PasskeysEnabled(a *app.App) bool {
+	_, ok := relyingParty(a)
+	return ok
+}
+
+func passkeyUser(c *gin.Context) (AuthUser, bool) {
+	u, _ := c.Get("user")
+	au, _ := u.(AuthUser)
+	_, err := uuid.Parse(au.ID)
+	return au, err == nil
+}
+
+// storeChallenge records an outstanding ceremony. Expired challenges are
+// swept at the same time.
+func storeChallenge(c *gin.Context, a *app.App, challenge, kind string, userID *string) error {
+	ctx := c.Request.Context()
+	if _, err := a.DB.Exec(ctx, `delete from webauthn_challenges where expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := a.DB.Exec(ctx, `insert into webauthn_challenges (challenge, kind, user_id, expires_at)
+        values ($1, $2, $3, now() + make_interval(secs => $4))`, challenge, kind, userID, webauthn.Timeout.Seconds())
+	return err
+}
+
+// consumeChallenge deletes the challenge a response answers and returns the
+// user it was issued to ("" for discoverable logins). pgx.ErrNoRows means
+// it is unknown, used or expired.
+func consumeChallenge(c *gin.Context, a *app.App, clientDataJSON, kind string) (challenge, userID string, err error) {
+	challenge, err = webauthn.Challenge(clientDataJSON)
+	if err != nil {
+		return "", "", pgx.ErrNoRows
+	}
+	err = a.DB.QueryRow(c.Request.Context(), `delete from webauthn_challenges
+        where challenge=$1 and kind=$2 and expires_at > now()
+        returning coalesce(user_id::text,'')`, challenge, kind).Scan(&userID)
+	return challenge, userID, err
+}
+
+// credentialDescriptors lists userID's credentials for allow/exclude lists.
+func credentialDescriptors(c *gin.Context, a *app.App, userID string) ([]webauthn.Descriptor, error) {
+	rows, err := a.DB.Query(c.Request.Context(), `select credential_id, transports from webauthn_credentials where user_id=$1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []webauthn.Descriptor{}
+	for rows.Next() {
+		var id []byte
+		var transports []string
+		if err := rows.Scan(&id, &transports); err != nil {
+			return nil, err
+		}
+		out = append(out, webauthn.NewDescriptor(id, transports))
+	}
+	return out, rows.Err()
+}
+
+// PasskeyRegistrationOptions starts registering a passkey for the caller and
+// returns the options for navigator.credentials.create.
+func PasskeyRegistrationOptions(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rp, ok := relyingParty(a)
+		if !ok {
+			app.AbortError(c, http.StatusNotImplemented, "passkeys_disabled", "passkeys disabled", nil)
+			return
+		}
+		u, ok := passkeyUser(c)
+		if !ok {
+			app.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		exclude, err := credentialDescriptors(c, a, u.ID)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load passkeys", nil)
+			return
+		}
+		name := u.Email
+		if name == "" {
+			name = strings.TrimPrefix(u.ExternalID, "local:")
+		}
+		display := u.DisplayName
+		if display == "" {
+			display = name
+		}
+		handle := uuid.MustParse(u.ID)
+		opts, err := rp.NewRegistration(webauthn.User{ID: handle[:], Name: name, DisplayName: display}, exclude)
+		if err == nil {
+			err = storeChallenge(c, a, opts.Challenge, "register", &u.ID)
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "challenge_failed", "failed to start registration", nil)
+			return
+		}
+		c.JSON(http.StatusOK, opts)
+	}
+}
+
+// RegisterPasskey verifies the authenticator's registration response and
+// stores the new passkey.
+func RegisterPasskey(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rp, ok := relyingParty(a)
+		if !ok {
+			app.AbortError(c, http.StatusNotImplemented, "passkeys_disabled", "passkeys disabled", nil)
+			return
+		}
+		u, ok := passkeyUser(c)
+		if !ok {
+			app.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		var in struct {
+			Name       string                        `json:"name"`
+			Credential webauthn.RegistrationResponse `json:"credential"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		in.Name = strings.TrimSpace(in.Name)
+		if len(in.Name) > 100 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid passkey", map[string]string{"name": "at most 100 characters"})
+			return
+		}
+		if in.Name == "" {
+			in.Name = "Passkey"
+		}
+		ctx := c.Request.Context()
+		challenge, owner, err := consumeChallenge(c, a, in.Credential.Response.ClientDataJSON, "register")
+		if err != nil || owner != u.ID {
+			app.AbortError(c, http.StatusBadRequest, "invalid_challenge", "registration expired or already used; start again", nil)
+			return
+		}
+		cred, err := rp.VerifyRegistration(in.Credential, challenge)
+		if err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_credential", err.Error(), nil)
+			return
+		}
+		if cred.Transports == nil {
+			cred.Transports = []string{}
+		}
+		out := Passkey{Name: in.Name, AttestationFormat: cred.AttestationFormat, Transports: cred.Transports}
+		err = a.DB.QueryRow(ctx, `insert into webauthn_credentials
+            (user_id, credential_id, public_key, sign_count, aaguid, attestation_fmt, transports, name)
+            values ($1, $2, $3, $4, $5, $6, $7, $8) returning id::text, created_at`,
+			u.ID, cred.ID, cred.PublicKey, int64(cred.SignCount), cred.AAGUID, cred.AttestationFormat, cred.Transports, in.Name).Scan(&out.ID, &out.CreatedAt)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			app.AbortError(c, http.StatusConflict, "passkey_exists", "passkey already registered", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save passkey", nil)
+			return
+		}
+		log.Info().Str("user_id", u.ID).Str("passkey_id", out.ID).Str("attestation_fmt", cred.AttestationFormat).Msg("passkey registered")
+		c.JSON(http.StatusCreated, out)
+	}
+}
+
+// ListPasskeys returns the caller's passkeys.
+func ListPasskeys(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := []Passkey{}
+		u, ok := passkeyUser(c)
+		if a.DB == nil || !ok {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name, attestation_fmt, transports, created_at, last_used_at
+            from webauthn_credentials where user_id=$1 order by created_at`, u.ID)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load passkeys", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p Passkey
+			if err := rows.Scan(&p.ID, &p.Name, &p.AttestationFormat, &p.Transports, &p.CreatedAt, &p.LastUsedAt); err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load passkeys", nil)
+				return
+			}
+			out = append(out, p)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// DeletePasskey removes one of the caller's passkeys.
+func DeletePasskey(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u, ok := passkeyUser(c)
+		if a.DB == nil || !ok {
+			app.AbortError(c, http.StatusNotFound, "not_found", "passkey not found", nil)
+			return
+		}
+		id := c.Param("id")
+		if _, err := uuid.Parse(id); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "passkey not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		tag, err := a.DB.Exec(ctx, `delete from webauthn_credentials where id=$1 and user_id=$2`, id, u.ID)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete passkey", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			app.AbortError(c, http.StatusNotFound, "not_found", "passkey not found", nil)
+			return
+		}
+		log.Info().Str("user_id", u.ID).Str("passkey_id", id).Msg("passkey removed")
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PasskeyLoginOptions starts a passkey login and returns the options for
+// navigator.credentials.get. With a username the user's passkeys are listed;
+// without one, or for unknown users, the browser offers any discoverable
+// passkey, so the response does not reveal whether an account exists.
+func PasskeyLoginOptions(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rp, ok := relyingParty(a)
+		if !ok {
+			app.AbortError(c, http.StatusNotImplemented, "passkeys_disabled", "passkeys disabled", nil)
+			return
+		}
+		var in struct {
+			Username string `json:"username"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+				return
+			}
+		}
+		var userID *string
+		allow := []webauthn.Descriptor{}
+		if name := strings.TrimSpace(in.Username); name != "" {
+			var uid string
+			err := a.DB.QueryRow(c.Request.Context(), `select id::text from users
+                where lower(username)=lower($1) or lower(email)=lower($1) limit 1`, name).Scan(&uid)
+			if err == nil {
+				if allow, err = credentialDescriptors(c, a, uid); err != nil {
+					app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load passkeys", nil)
+					return
+				}
+				if len(allow) > 0 {
+					userID = &uid
+				}
+			}
+		}
+		opts, err := rp.NewLogin(allow)
+		if err == nil {
+			err = storeChallenge(c, a, opts.Challenge, "login", userID)
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "challenge_failed", "failed to start login", nil)
+			return
+		}
+		c.JSON(http.StatusOK, opts)
+	}
+}
+
+// PasskeyLogin verifies a passkey assertion and starts a session like a
+// password login.
+func PasskeyLogin(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rp, ok := relyingParty(a)
+		if !ok {
+			app.AbortError(c, http.StatusNotImplemented, "passkeys_disabled", "passkeys disabled", nil)
+			return
+		}
+		if a.Cfg.AuthLocalSecret == "" {
+			app.AbortError(c, http.StatusInternalServerError, "auth_not_configured", "local auth not configured", nil)
+			return
+		}
+		var in webauthn.AssertionResponse
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		fail := func() {
+			metrics.AuthFailuresTotal.Inc()
+			app.AbortError(c, http.StatusUnauthorized, "invalid_credentials", "invalid credentials", nil)
+		}
+		ctx := c.Request.Context()
+		challenge, boundUser, err := consumeChallenge(c, a, in.Response.ClientDataJSON, "login")
+		if err != nil {
+			fail()
+			return
+		}
+		credID, err := webauthn.Decode(in.RawID)
+		if err != nil || len(credID) == 0 {
+			fail()
+			return
+		}
+		var id, uid, externalID, email, name, username string
+		var publicKey []byte
+		var signCount int64
+		err = a.DB.QueryRow(ctx, `select wc.id::text, u.id::text, wc.public_key, wc.sign_count,
+                coalesce(u.external_id,''), coalesce(u.email,''), coalesce(u.display_name,''), coalesce(u.username,'')
+            from webauthn_credentials wc join users u on u.id = wc.user_id
+            where wc.credential_id=$1`, credID).Scan(&id, &uid, &publicKey, &signCount, &externalID, &email, &name, &username)
+		if err != nil {
+			fail()
+			return
+		}
+		if boundUser != "" && boundUser != uid {
+			fail()
+			return
+		}
+		if in.Response.UserHandle != "" {
+			handle, err := webauthn.Decode(in.Response.UserHandle)
+			want := uuid.MustParse(uid)
+			if err != nil || !bytes.Equal(handle, want[:]) {
+				fail()
+				return
+			}
+		}
+		newCount, err := rp.VerifyAssertion(in, challenge, publicKey, uint32(signCount))
+		if errors.Is(err, webauthn.ErrCloned) {
+			log.Warn().Str("passkey_id", id).Str("user_id", uid).Msg("passkey signature counter regressed; possible cloned authenticator")
+		}
+		if err != nil {
+			fail()
+			return
+		}
+		// Only advance the counter from the value just checked, so two
+		// concurrent logins from a cloned authenticator cannot both succeed.
+		tag, err := a.DB.Exec(ctx, `update webauthn_credentials set sign_count=$2, last_used_at=now() where id=$1 and sign_count=$3`,
+			id, int64(newCount), signCount)
+		if err != nil {
+			log.Error().Err(err).Msg("update passkey counter")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update passkey", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			log.Warn().Str("passkey_id", id).Str("user_id", uid).Msg("passkey signature counter changed during login; possible cloned authenticator")
+			fail()
+			return
+		}
+		if externalID == "" {
+			externalID = "local:" + username
+		}
+		if err := SetSessionCookie(c, a.Cfg.AuthLocalSecret, externalID, email, name, a.Cfg.Env == "prod"); err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "sign_token_failed", "failed to sign token", nil)
+			return
+		}
+		sid, newDevice, err := RecordLogin(c, a.DB, uid, "passkey")
+		if err != nil {
+			log.Error().Err(err).Msg("record login")
+		} else if newDevice && email != "" {
+			if err := AlertNewDevice(c, a.DB, sid, email); err != nil {
+				log.Error().Err(err).Msg("queue new device alert")
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
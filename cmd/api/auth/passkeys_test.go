@@ -0,0 +1,179 @@
+package auth_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	apitest "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/webauthn"
+	"github.com/mark3748/helpdesk-go/internal/webauthn/webauthntest"
+)
+
+// passkeyDB keeps challenges and credentials in memory.
+type passkeyDB struct {
+	challenges map[string]string // challenge -> user id
+	credID     []byte
+	publicKey  []byte
+	signCount  int64
+	method     string
+	raced      bool
+}
+
+func (p *passkeyDB) mock(userID string) *apitest.MockDB {
+	return &apitest.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "insert into webauthn_challenges"):
+				uid := ""
+				if s, ok := args[2].(*string); ok && s != nil {
+					uid = *s
+				}
+				p.challenges[args[0].(string)] = uid
+			case strings.Contains(sql, "update webauthn_credentials set sign_count"):
+				if p.raced {
+					// Another login advanced the counter after it was read.
+					p.signCount++
+				}
+				if !strings.Contains(sql, "sign_count=$3") || args[2].(int64) != p.signCount {
+					return pgconn.NewCommandTag("UPDATE 0"), nil
+				}
+				p.signCount = args[1].(int64)
+				return pgconn.NewCommandTag("UPDATE 1"), nil
+			}
+			return pgconn.CommandTag{}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &apitest.MockRow{ScanFunc: func(dest ...interface{}) error {
+				switch {
+				case strings.Contains(sql, "delete from webauthn_challenges"):
+					uid, ok := p.challenges[args[0].(string)]
+					if !ok {
+						return pgx.ErrNoRows
+					}
+					delete(p.challenges, args[0].(string))
+					*dest[0].(*string) = uid
+				case strings.Contains(sql, "insert into webauthn_credentials"):
+					p.credID, p.publicKey, p.signCount = args[1].([]byte), args[2].([]byte), args[3].(int64)
+					*dest[0].(*string) = "2a3b4c5d-0000-4000-8000-000000000001"
+					*dest[1].(*time.Time) = time.Now()
+				case strings.Contains(sql, "from webauthn_credentials wc join users"):
+					if !bytes.Equal(args[0].([]byte), p.credID) {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string) = "2a3b4c5d-0000-4000-8000-000000000001"
+					*dest[1].(*string) = userID
+					*dest[2].(*[]byte) = p.publicKey
+					*dest[3].(*int64) = p.signCount
+					*dest[5].(*string) = "alice@example.com"
+					*dest[7].(*string) = "alice"
+				case strings.Contains(sql, "insert into user_sessions"):
+					p.method = args[1].(string)
+					*dest[0].(*string) = "0b7c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+				}
+				return nil
+			}}
+		},
+	}
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const userID = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+	store := &passkeyDB{challenges: map[string]string{}}
+	cfg := apppkg.Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret",
+		WebAuthnRPID: "helpdesk.example.com", WebAuthnOrigins: []string{"https://helpdesk.example.com"}}
+	a := apppkg.NewApp(cfg, store.mock(userID), nil, nil, nil)
+	signedIn := func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: userID, ExternalID: "local:alice", Email: "alice@example.com"})
+	}
+	a.R.POST("/me/passkeys/options", signedIn, authpkg.PasskeyRegistrationOptions(a))
+	a.R.POST("/me/passkeys", signedIn, authpkg.RegisterPasskey(a))
+	a.R.POST("/login/passkey/options", authpkg.PasskeyLoginOptions(a))
+	a.R.POST("/login/passkey", authpkg.PasskeyLogin(a))
+	post := func(path string, body any) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(b)))
+		return rr
+	}
+	device := webauthntest.New("helpdesk.example.com", "https://helpdesk.example.com")
+
+	rr := post("/me/passkeys/options", nil)
+	var create webauthn.CreationOptions
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &create) != nil {
+		t.Fatalf("registration options: %d %s", rr.Code, rr.Body.String())
+	}
+	rr = post("/me/passkeys", map[string]any{"name": "Laptop", "credential": device.Register(create)})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("register: %d %s", rr.Code, rr.Body.String())
+	}
+	if !bytes.Equal(store.credID, device.CredID) {
+		t.Fatal("expected credential stored")
+	}
+
+	rr = post("/login/passkey/options", map[string]string{})
+	var get webauthn.RequestOptions
+	if rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &get) != nil {
+		t.Fatalf("login options: %d %s", rr.Code, rr.Body.String())
+	}
+	assertion := device.Login(get)
+	rr = post("/login/passkey", assertion)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Header().Get("Set-Cookie"), "hd_auth=") {
+		t.Fatalf("login: %d %s", rr.Code, rr.Body.String())
+	}
+	if store.method != "passkey" || store.signCount != 1 {
+		t.Fatalf("expected passkey session and counter 1, got %q %d", store.method, store.signCount)
+	}
+
+	// The challenge is single use.
+	rr = post("/login/passkey", assertion)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected replay to be rejected, got %d", rr.Code)
+	}
+
+	// Logins need the authenticator to verify the user.
+	if get.UserVerification != "required" {
+		t.Fatalf("expected user verification required, got %q", get.UserVerification)
+	}
+	store.method = ""
+	device.NoUserVerification = true
+	rr = post("/login/passkey/options", map[string]string{})
+	_ = json.Unmarshal(rr.Body.Bytes(), &get)
+	rr = post("/login/passkey", device.Login(get))
+	if rr.Code != http.StatusUnauthorized || store.method != "" {
+		t.Fatalf("expected unverified login to be rejected, got %d", rr.Code)
+	}
+	device.NoUserVerification = false
+
+	// A counter that moves between the check and the update is a clone
+	// racing this login.
+	store.raced = true
+	rr = post("/login/passkey/options", map[string]string{})
+	_ = json.Unmarshal(rr.Body.Bytes(), &get)
+	rr = post("/login/passkey", device.Login(get))
+	if rr.Code != http.StatusUnauthorized || store.method != "" {
+		t.Fatalf("expected raced counter to be rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestPasskeysDisabledWithoutRPID(t *testing.T) {
+	a := apppkg.NewApp(apppkg.Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret"}, &apitest.MockDB{}, nil, nil, nil)
+	a.R.POST("/login/passkey/options", authpkg.PasskeyLoginOptions(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/login/passkey/options", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+)
+
+// Permissions that custom roles can be granted. Routes guarded by
+// RequirePermission accept any role holding the permission.
+const (
+	PermAuditRead    = "audit.read"
+	PermTicketsAudit = "tickets.audit"
+	PermReportsRead  = "reports.read"
+)
+
+// Permission describes an entry of the catalog.
+type Permission struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Catalog lists every permission, in display order.
+var Catalog = []Permission{
+	{PermAuditRead, "Query the audit log of all entities"},
+	{PermTicketsAudit, "View a ticket's audit timeline"},
+	{PermReportsRead, "View SLA, resolution and volume reports"},
+}
+
+// BuiltinRoles maps the roles shipped with the helpdesk to their
+// permissions. They cannot be edited or deleted. Admin passes every check
+// regardless of its list.
+var BuiltinRoles = map[string][]string{
+	"admin":     {PermAuditRead, PermTicketsAudit, PermReportsRead},
+	"agent":     {PermTicketsAudit, PermReportsRead},
+	"manager":   {PermAuditRead, PermTicketsAudit, PermReportsRead},
+	"requester": {},
+}
+
+// ValidPermission reports whether p is in the catalog.
+func ValidPermission(p string) bool {
+	return slices.ContainsFunc(Catalog, func(c Permission) bool { return c.Name == p })
+}
+
+// HasPermission reports whether u holds p through a built-in role or a
+// custom role's permissions.
+func (u AuthUser) HasPermission(p string) bool {
+	for _, r := range u.Roles {
+		if r == "admin" || slices.Contains(BuiltinRoles[r], p) {
+			return true
+		}
+	}
+	return slices.Contains(u.Permissions, p)
+}
+
+// RequirePermission ensures the user holds one of perms.
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uVal, ok := c.Get("user")
+		if !ok {
+			metrics.AuthFailuresTotal.Inc()
+			app.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		user, ok := uVal.(AuthUser)
+		if !ok {
+			metrics.AuthFailuresTotal.Inc()
+			app.AbortError(c, http.StatusUnauthorized, "invalid_user", "invalid user", nil)
+			return
+		}
+		for _, p := range perms {
+			if user.HasPermission(p) {
+				c.Next()
+				return
+			}
+		}
+		app.AbortError(c, http.StatusForbidden, "forbidden", "forbidden", nil)
+	}
+}
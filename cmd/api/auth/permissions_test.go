@@ -0,0 +1,47 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+func TestRequirePermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for name, tc := range map[string]struct {
+		user authpkg.AuthUser
+		want int
+	}{
+		"admin":                 {authpkg.AuthUser{Roles: []string{"admin"}}, http.StatusOK},
+		"built-in grant":        {authpkg.AuthUser{Roles: []string{"manager"}}, http.StatusOK},
+		"built-in without":      {authpkg.AuthUser{Roles: []string{"agent"}}, http.StatusForbidden},
+		"custom role grant":     {authpkg.AuthUser{Roles: []string{"auditor"}, Permissions: []string{authpkg.PermAuditRead}}, http.StatusOK},
+		"custom role without":   {authpkg.AuthUser{Roles: []string{"billing"}, Permissions: []string{authpkg.PermReportsRead}}, http.StatusForbidden},
+		"role name not trusted": {authpkg.AuthUser{Roles: []string{authpkg.PermAuditRead}}, http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := apppkg.NewApp(apppkg.Config{Env: "test"}, nil, nil, nil, nil)
+			a.R.GET("/audit", func(c *gin.Context) { c.Set("user", tc.user) }, authpkg.RequirePermission(authpkg.PermAuditRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/audit", nil))
+			if rr.Code != tc.want {
+				t.Fatalf("expected %d, got %d", tc.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestBuiltinRolesUseCatalog(t *testing.T) {
+	for role, perms := range authpkg.BuiltinRoles {
+		for _, p := range perms {
+			if !authpkg.ValidPermission(p) {
+				t.Fatalf("%s grants unknown permission %q", role, p)
+			}
+		}
+	}
+}
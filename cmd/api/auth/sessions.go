@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// SessionTTL is how long a session cookie stays valid.
+const SessionTTL = 24 * time.Hour
+
+// securityHistory caps the logins and password changes /me/security returns.
+const securityHistory = 20
+
+// LoginRecord is a successful sign-in and the session it started.
+type LoginRecord struct {
+	ID        string    `json:"id"`
+	Method    string    `json:"method"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordChange records a password change.
+type PasswordChange struct {
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// SecurityOverview is the /me/security payload.
+type SecurityOverview struct {
+	Logins          []LoginRecord    `json:"logins"`
+	Sessions        []LoginRecord    `json:"sessions"`
+	PasswordChanges []PasswordChange `json:"password_changes"`
+}
+
+// deviceHash identifies a browser by its user agent. IPs change too often to
+// be part of it.
+func deviceHash(userAgent string) string {
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordLogin stores a session for userID created by the current request.
+// newDevice is true when the user has signed in before but never from this
+// device.
+func RecordLogin(c *gin.Context, db app.DB, userID, method string) (sessionID string, newDevice bool, err error) {
+	err = db.QueryRow(c.Request.Context(), `with prev as (
+            select count(*) as total, count(*) filter (where device_hash = $5) as same
+            from user_sessions where user_id = $1)
+        insert into user_sessions (user_id, method, ip, user_agent, device_hash, expires_at)
+        values ($1, $2, nullif($3,''), nullif($4,''), $5, now() + make_interval(secs => $6))
+        returning id::text, (select total > 0 and same = 0 from prev)`,
+		userID, method, c.ClientIP(), c.Request.UserAgent(), deviceHash(c.Request.UserAgent()), SessionTTL.Seconds()).Scan(&sessionID, &newDevice)
+	return sessionID, newDevice, err
+}
+
+// AlertNewDevice queues an email telling the user about a sign-in from a
+// device they have not used before.
+func AlertNewDevice(c *gin.Context, db app.DB, sessionID, email string) error {
+	return outbox.AddJob(c.Request.Context(), db, "new_device_login:"+sessionID, "", jobs.TypeSendEmail, jobs.Email{
+		To:       email,
+		Template: "new_device_login",
+		Data: map[string]any{
+			"IP":        c.ClientIP(),
+			"UserAgent": c.Request.UserAgent(),
+			"At":        time.Now().UTC().Format(time.RFC1123),
+		},
+	})
+}
+
+// RecordPasswordChange adds the current request to userID's password
+// history.
+func RecordPasswordChange(c *gin.Context, db app.DB, userID string) error {
+	_, err := db.Exec(c.Request.Context(), `insert into password_changes (user_id, ip, user_agent) values ($1, nullif($2,''), nullif($3,''))`,
+		userID, c.ClientIP(), c.Request.UserAgent())
+	return err
+}
+
+// Security returns the caller's recent logins, active sessions and password
+// changes.
+func Security(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out := SecurityOverview{Logins: []LoginRecord{}, Sessions: []LoginRecord{}, PasswordChanges: []PasswordChange{}}
+		u, _ := c.Get("user")
+		au, _ := u.(AuthUser)
+		if a.DB == nil || au.ID == "" {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		ctx := c.Request.Context()
+		var err error
+		if out.Logins, err = loginRecords(ctx, a.DB, au.ID, false); err == nil {
+			if out.Sessions, err = loginRecords(ctx, a.DB, au.ID, true); err == nil {
+				out.PasswordChanges, err = passwordChanges(ctx, a.DB, au.ID)
+			}
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load security activity", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+func loginRecords(ctx context.Context, db app.DB, userID string, active bool) ([]LoginRecord, error) {
+	q := `select id::text, method, coalesce(ip,''), coalesce(user_agent,''), created_at, expires_at
+        from user_sessions where user_id = $1`
+	if active {
+		q += ` and expires_at > now()`
+	}
+	rows, err := db.Query(ctx, q+` order by created_at desc limit $2`, userID, securityHistory)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []LoginRecord{}
+	for rows.Next() {
+		var l LoginRecord
+		if err := rows.Scan(&l.ID, &l.Method, &l.IP, &l.UserAgent, &l.CreatedAt, &l.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func passwordChanges(ctx context.Context, db app.DB, userID string) ([]PasswordChange, error) {
+	rows, err := db.Query(ctx, `select coalesce(ip,''), coalesce(user_agent,''), changed_at
+        from password_changes where user_id = $1 order by changed_at desc limit $2`, userID, securityHistory)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []PasswordChange{}
+	for rows.Next() {
+		var p PasswordChange
+		if err := rows.Scan(&p.IP, &p.UserAgent, &p.ChangedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
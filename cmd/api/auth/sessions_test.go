@@ -0,0 +1,66 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"golang.org/x/crypto/bcrypt"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	apitest "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestLoginAlertsOnNewDevice(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	for _, tc := range []struct {
+		name      string
+		newDevice bool
+	}{
+		{"known device", false},
+		{"new device", true},
+	} {
+		var recorded bool
+		var alert string
+		db := &apitest.MockDB{
+			QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+				return &apitest.MockRow{ScanFunc: func(dest ...interface{}) error {
+					switch {
+					case strings.Contains(sql, "from users where lower(username)"):
+						*dest[0].(*string) = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+						*dest[2].(*string) = "alice@example.com"
+						*dest[4].(*string) = string(hash)
+					case strings.Contains(sql, "insert into user_sessions"):
+						recorded = args[1] == "local"
+						*dest[0].(*string) = "0b7c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+						*dest[1].(*bool) = tc.newDevice
+					}
+					return nil
+				}}
+			},
+			ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+				if strings.Contains(sql, "insert into outbox") {
+					alert = args[2].(string)
+				}
+				return pgconn.CommandTag{}, nil
+			},
+		}
+		a := apppkg.NewApp(apppkg.Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret"}, db, nil, nil, nil)
+		a.R.POST("/login", authpkg.Login(a))
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"alice","password":"pw"}`))
+		req.Header.Set("User-Agent", "Firefox")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !recorded {
+			t.Fatalf("%s: expected recorded login, got %d (recorded=%v)", tc.name, rr.Code, recorded)
+		}
+		if got := strings.Contains(alert, `"new_device_login"`) && strings.Contains(alert, "alice@example.com"); got != tc.newDevice {
+			t.Fatalf("%s: alert queued = %v, payload %q", tc.name, got, alert)
+		}
+	}
+}
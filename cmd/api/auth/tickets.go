@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// TicketAccess returns a SQL condition that is true when a caller without a
+// staff role may see the ticket aliased t: they raised it, are CC'd on it,
+// watch it, or belong to the organization of the requester who raised it.
+// Organizations own requesters by email domain, as in contracts.OrgJoin.
+// $n is the caller's email and $n+1 their user ID.
+func TicketAccess(n int) string {
+	return fmt.Sprintf(`(exists (select 1 from requesters ra where ra.id = t.requester_id and lower(ra.email) = lower(nullif($%[1]d,'')))
+            or exists (select 1 from ticket_ccs ca where ca.ticket_id = t.id and lower(ca.email) = lower(nullif($%[1]d,'')))
+            or exists (select 1 from requesters ro join organizations oa on split_part(lower(ro.email), '@', 2) = any(oa.domains)
+                where ro.id = t.requester_id and split_part(lower(nullif($%[1]d,'')), '@', 2) = any(oa.domains))
+            or exists (select 1 from ticket_watchers wa where wa.ticket_id = t.id and wa.user_id = nullif($%[2]d,'')::uuid))`, n, n+1)
+}
+
+// TicketAccessArgs returns the arguments of TicketAccess for the caller.
+func TicketAccessArgs(c *gin.Context) []any {
+	v, _ := c.Get("user")
+	u, _ := v.(AuthUser)
+	id := u.ID
+	if _, err := uuid.Parse(id); err != nil {
+		id = ""
+	}
+	return []any{u.Email, id}
+}
+
+// RequireTicketAccess answers 404 when a caller without a staff role asks for
+// a ticket, named by :id, that TicketAccess does not let them see. Staff pass
+// through.
+func RequireTicketAccess(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if IsStaff(c) || a.DB == nil {
+			c.Next()
+			return
+		}
+		id := c.Param("id")
+		var ok bool
+		if _, err := uuid.Parse(id); err == nil {
+			err = a.DB.QueryRow(c.Request.Context(), `select exists (select 1 from tickets t where t.id = $1 and `+TicketAccess(2)+`)`,
+				append([]any{id}, TicketAccessArgs(c)...)...).Scan(&ok)
+			if err != nil {
+				log.Error().Err(err).Str("ticket_id", id).Msg("check ticket access")
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to check ticket access", nil)
+				return
+			}
+		}
+		if !ok {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package auth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+const (
+	ticketID  = "11111111-1111-1111-1111-111111111111"
+	watcherID = "22222222-2222-2222-2222-222222222222"
+	otherID   = "33333333-3333-3333-3333-333333333333"
+)
+
+func TestRequireTicketAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	queries := 0
+	// The fake database applies TicketAccess to one ticket raised by
+	// ann@acme.io, CC'ing cc@globex.com and watched by watcherID. The Acme
+	// organization lists acme.io and acme.co.uk; Initech lists initech.io.
+	orgs := map[string]string{"acme.io": "acme", "acme.co.uk": "acme", "initech.io": "initech"}
+	db := &testutil.MockDB{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+		queries++
+		for _, want := range []string{"from requesters ra", "from ticket_ccs ca", "from ticket_watchers wa", "join organizations oa"} {
+			if !strings.Contains(sql, want) {
+				t.Fatalf("expected %q in %s", want, sql)
+			}
+		}
+		email := strings.ToLower(args[1].(string))
+		_, domain, _ := strings.Cut(email, "@")
+		ok := args[0] == ticketID && (email == "ann@acme.io" || email == "cc@globex.com" || args[2] == watcherID || orgs[domain] == "acme")
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+			*dest[0].(*bool) = ok
+			return nil
+		}}
+	}}
+	for name, tc := range map[string]struct {
+		user    authpkg.AuthUser
+		ticket  string
+		want    int
+		queries int
+	}{
+		"agent":             {authpkg.AuthUser{ID: otherID, Roles: []string{"agent"}}, ticketID, http.StatusOK, 0},
+		"manager":           {authpkg.AuthUser{ID: otherID, Roles: []string{"manager"}}, ticketID, http.StatusOK, 0},
+		"admin":             {authpkg.AuthUser{ID: otherID, Roles: []string{"admin"}}, ticketID, http.StatusOK, 0},
+		"requester":         {authpkg.AuthUser{ID: otherID, Email: "Ann@acme.io", Roles: []string{"requester"}}, ticketID, http.StatusOK, 1},
+		"cc":                {authpkg.AuthUser{ID: otherID, Email: "cc@globex.com", Roles: []string{"requester"}}, ticketID, http.StatusOK, 1},
+		"watcher":           {authpkg.AuthUser{ID: watcherID, Email: "w@acme.io", Roles: []string{"requester"}}, ticketID, http.StatusOK, 1},
+		"same org":          {authpkg.AuthUser{ID: otherID, Email: "bob@acme.co.uk", Roles: []string{"requester"}}, ticketID, http.StatusOK, 1},
+		"different org":     {authpkg.AuthUser{ID: otherID, Email: "eve@initech.io", Roles: []string{"requester"}}, ticketID, http.StatusNotFound, 1},
+		"stranger":          {authpkg.AuthUser{ID: otherID, Email: "eve@example.com", Roles: []string{"requester"}}, ticketID, http.StatusNotFound, 1},
+		"no roles":          {authpkg.AuthUser{ID: otherID, Email: "eve@example.com"}, ticketID, http.StatusNotFound, 1},
+		"other ticket":      {authpkg.AuthUser{ID: otherID, Email: "ann@acme.io", Roles: []string{"requester"}}, otherID, http.StatusNotFound, 1},
+		"malformed ticket":  {authpkg.AuthUser{ID: otherID, Email: "ann@acme.io", Roles: []string{"requester"}}, "1", http.StatusNotFound, 0},
+		"non-uuid identity": {authpkg.AuthUser{ID: "test-user", Email: "eve@example.com", Roles: []string{"requester"}}, ticketID, http.StatusNotFound, 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+			setUser := func(c *gin.Context) { c.Set("user", tc.user) }
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			paths := []string{"/tickets/:id", "/tickets/:id/comments", "/tickets/:id/attachments/:attID"}
+			for _, p := range paths {
+				a.R.GET(p, setUser, authpkg.RequireTicketAccess(a), ok)
+			}
+			queries = 0
+			for _, p := range paths {
+				url := strings.NewReplacer(":id", tc.ticket, ":attID", "a1").Replace(p)
+				rr := httptest.NewRecorder()
+				a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+				if rr.Code != tc.want {
+					t.Fatalf("%s: expected %d, got %d", url, tc.want, rr.Code)
+				}
+			}
+			if queries != tc.queries*len(paths) {
+				t.Fatalf("expected %d access checks, got %d", tc.queries*len(paths), queries)
+			}
+		})
+	}
+}
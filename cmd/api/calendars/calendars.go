@@ -0,0 +1,245 @@
+package calendars
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	slapkg "github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// Exception is a one-off closure of a business calendar.
+type Exception struct {
+	ID         string    `json:"id"`
+	CalendarID string    `json:"calendar_id"`
+	StartsAt   time.Time `json:"starts_at"`
+	EndsAt     time.Time `json:"ends_at"`
+	Kind       string    `json:"kind"`
+	Label      string    `json:"label,omitempty"`
+}
+
+// ListExceptions returns a calendar's exceptions in start order.
+func ListExceptions(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []Exception{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, calendar_id::text, starts_at, ends_at, kind, coalesce(label,'')
+            from calendar_exceptions where calendar_id=$1 order by starts_at`, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list exceptions", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Exception{}
+		for rows.Next() {
+			var e Exception
+			if err := rows.Scan(&e.ID, &e.CalendarID, &e.StartsAt, &e.EndsAt, &e.Kind, &e.Label); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list exceptions", nil)
+				return
+			}
+			out = append(out, e)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+type exceptionReq struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Kind     string    `json:"kind"`
+	Label    string    `json:"label"`
+}
+
+// CreateException closes the calendar between starts_at and ends_at. Kind is
+// "closure" (default) or "holiday".
+func CreateException(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in exceptionReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "starts_at and ends_at required", nil)
+			return
+		}
+		if !in.EndsAt.After(in.StartsAt) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "ends_at must be after starts_at", nil)
+			return
+		}
+		if in.Kind == "" {
+			in.Kind = "closure"
+		}
+		if in.Kind != "closure" && in.Kind != "holiday" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid kind", map[string]string{"kind": "closure or holiday"})
+			return
+		}
+		e := Exception{CalendarID: c.Param("id"), StartsAt: in.StartsAt, EndsAt: in.EndsAt, Kind: in.Kind, Label: in.Label}
+		if a.DB == nil {
+			e.ID = "temp"
+			c.JSON(http.StatusCreated, e)
+			return
+		}
+		err := a.DB.QueryRow(c.Request.Context(), `insert into calendar_exceptions (calendar_id, starts_at, ends_at, kind, label)
+            values ($1, $2, $3, $4, nullif($5,'')) returning id::text`, e.CalendarID, e.StartsAt, e.EndsAt, e.Kind, e.Label).Scan(&e.ID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create exception", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, e)
+	}
+}
+
+// DeleteException removes a calendar exception.
+func DeleteException(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		tag, err := a.DB.Exec(c.Request.Context(), `delete from calendar_exceptions where id=$1 and calendar_id=$2`, c.Param("exceptionID"), c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete exception", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "exception not found", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// maxFeedBytes bounds the size of an uploaded holiday feed.
+const maxFeedBytes = 1 << 20
+
+// ImportRegionHolidays loads a public-holiday feed (JSON or iCalendar, see
+// sla.ParseHolidayFeed) into the region's calendar. The format comes from
+// ?format= or the Content-Type; ?source= names the feed. Re-importing updates
+// labels of previously imported days and leaves manual entries alone.
+func ImportRegionHolidays(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.Query("format")
+		if format == "" {
+			format = slapkg.FeedJSON
+			if strings.HasPrefix(c.ContentType(), "text/calendar") {
+				format = slapkg.FeedICS
+			}
+		}
+		source := strings.TrimSpace(c.Query("source"))
+		if source == "" {
+			source = "feed"
+		}
+		holidays, err := slapkg.ParseHolidayFeed(io.LimitReader(c.Request.Body, maxFeedBytes), format)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_feed", err.Error(), nil)
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"received": len(holidays), "imported": 0, "holidays": holidays})
+			return
+		}
+		ctx := c.Request.Context()
+		var calID string
+		if err := a.DB.QueryRow(ctx, `select coalesce(calendar_id::text,'') from regions where id=$1`, c.Param("id")).Scan(&calID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusNotFound, "not_found", "region not found", nil)
+				return
+			}
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load region", nil)
+			return
+		}
+		if calID == "" {
+			apppkg.AbortError(c, http.StatusConflict, "no_calendar", "region has no calendar", nil)
+			return
+		}
+		var imported int64
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			for _, h := range holidays {
+				tag, err := tx.Exec(ctx, `insert into holidays (calendar_id, date, label, source) values ($1, $2, nullif($3,''), $4)
+                    on conflict (calendar_id, date) do update set label=excluded.label, source=excluded.source
+                    where holidays.source is not null`, calID, h.Date, h.Label, source)
+				if err != nil {
+					return err
+				}
+				imported += tag.RowsAffected()
+			}
+			return nil
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to import holidays", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"calendar_id": calID, "received": len(holidays), "imported": imported, "holidays": holidays})
+	}
+}
+
+// maxPreviewSpan bounds the range accepted by Preview.
+const maxPreviewSpan = 366 * 24 * time.Hour
+
+type previewDay struct {
+	Date       string `json:"date"`
+	BusinessMS int64  `json:"business_ms"`
+	Holiday    bool   `json:"holiday,omitempty"`
+}
+
+// Preview reports the business time between ?start= and ?end= (RFC 3339)
+// under a calendar, with a per-day breakdown, for debugging SLA figures.
+func Preview(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start, err1 := time.Parse(time.RFC3339, c.Query("start"))
+		end, err2 := time.Parse(time.RFC3339, c.Query("end"))
+		if err1 != nil || err2 != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "start and end must be RFC 3339 timestamps", nil)
+			return
+		}
+		if !end.After(start) || end.Sub(start) > maxPreviewSpan {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "end must be after start and within one year", nil)
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"calendar_id": c.Param("id"), "business_ms": 0, "days": []previewDay{}})
+			return
+		}
+		cal, err := slapkg.LoadCalendar(c.Request.Context(), a.DB, c.Param("id"))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusNotFound, "not_found", "calendar not found", nil)
+				return
+			}
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load calendar", nil)
+			return
+		}
+		days := []previewDay{}
+		local := start.In(cal.Location)
+		for day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, cal.Location); day.Before(end); day = day.AddDate(0, 0, 1) {
+			from, to := day, day.AddDate(0, 0, 1)
+			if from.Before(start) {
+				from = start
+			}
+			if to.After(end) {
+				to = end
+			}
+			_, holiday := cal.Holidays[day]
+			days = append(days, previewDay{
+				Date:       day.Format("2006-01-02"),
+				BusinessMS: cal.BusinessDuration(from, to).Milliseconds(),
+				Holiday:    holiday,
+			})
+		}
+		total := cal.BusinessDuration(start, end)
+		c.JSON(http.StatusOK, gin.H{
+			"calendar_id":       c.Param("id"),
+			"timezone":          cal.Location.String(),
+			"start":             start,
+			"end":               end,
+			"business_ms":       total.Milliseconds(),
+			"business_duration": total.String(),
+			"days":              days,
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package calendars
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+// calendarDB serves a UTC calendar open 09:00-17:00 on weekdays with a
+// closure on Monday 2024-07-01 from 13:00 to 15:00.
+func calendarDB(execs *[]string) *testutil.MockDB {
+	return &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from calendars"):
+					*dest[0].(*string) = "UTC"
+				case strings.Contains(sql, "from regions"):
+					if args[0] == "missing" {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string) = "cal-1"
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			n := 0
+			switch {
+			case strings.Contains(sql, "business_hours"):
+				return &testutil.MockRows{
+					NextFunc: func() bool { n++; return n <= 5 },
+					ScanFunc: func(dest ...any) error {
+						*dest[0].(*int) = n
+						*dest[1].(*int) = 9 * 3600
+						*dest[2].(*int) = 17 * 3600
+						return nil
+					},
+				}, nil
+			case strings.Contains(sql, "calendar_exceptions"):
+				return &testutil.MockRows{
+					NextFunc: func() bool { n++; return n == 1 },
+					ScanFunc: func(dest ...any) error {
+						*dest[0].(*time.Time) = time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC)
+						*dest[1].(*time.Time) = time.Date(2024, 7, 1, 15, 0, 0, 0, time.UTC)
+						return nil
+					},
+				}, nil
+			}
+			return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			*execs = append(*execs, sql)
+			return pgconn.NewCommandTag("INSERT 0 1"), nil
+		},
+	}
+}
+
+func newTestApp(db apppkg.DB) *apppkg.App {
+	gin.SetMode(gin.TestMode)
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/calendars/:id/business-duration", authpkg.Middleware(a), Preview(a))
+	a.R.POST("/calendars/:id/exceptions", authpkg.Middleware(a), CreateException(a))
+	a.R.POST("/regions/:id/holidays/import", authpkg.Middleware(a), ImportRegionHolidays(a))
+	return a
+}
+
+func TestPreview(t *testing.T) {
+	var execs []string
+	a := newTestApp(calendarDB(&execs))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/calendars/cal-1/business-duration?start=2024-06-28T16:00:00Z&end=2024-07-01T17:00:00Z", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		BusinessMS int64        `json:"business_ms"`
+		Days       []previewDay `json:"days"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	// Friday 16-17 plus Monday 9-17 less the 2h closure.
+	if resp.BusinessMS != int64(7*time.Hour/time.Millisecond) {
+		t.Fatalf("unexpected business_ms %d", resp.BusinessMS)
+	}
+	if len(resp.Days) != 4 || resp.Days[0].BusinessMS != int64(time.Hour/time.Millisecond) || resp.Days[1].BusinessMS != 0 {
+		t.Fatalf("unexpected days: %+v", resp.Days)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/calendars/cal-1/business-duration?start=2024-07-02T00:00:00Z&end=2024-07-01T00:00:00Z", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for reversed range, got %d", rr.Code)
+	}
+}
+
+func TestCreateExceptionValidation(t *testing.T) {
+	var execs []string
+	a := newTestApp(calendarDB(&execs))
+	for body, want := range map[string]int{
+		`{"starts_at":"2024-07-01T13:00:00Z","ends_at":"2024-07-01T12:00:00Z"}`:                  http.StatusBadRequest,
+		`{"starts_at":"2024-07-01T13:00:00Z","ends_at":"2024-07-01T15:00:00Z","kind":"outage"}`:  http.StatusBadRequest,
+		`{"starts_at":"2024-07-01T13:00:00Z","ends_at":"2024-07-01T15:00:00Z","label":"Power"}`:  http.StatusCreated,
+		`{"starts_at":"2024-07-01T00:00:00Z","ends_at":"2024-07-02T00:00:00Z","kind":"holiday"}`: http.StatusCreated,
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/calendars/cal-1/exceptions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", body, want, rr.Code)
+		}
+	}
+}
+
+func TestImportRegionHolidays(t *testing.T) {
+	var execs []string
+	a := newTestApp(calendarDB(&execs))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/regions/r1/holidays/import?source=us-federal", strings.NewReader(`[{"date":"2024-07-04","name":"Independence Day"},{"date":"2024-12-25","name":"Christmas Day"}]`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(execs) != 2 || !strings.Contains(execs[0], "insert into holidays") {
+		t.Fatalf("unexpected execs: %v", execs)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/regions/r1/holidays/import", strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:No date\r\nEND:VEVENT\r\n"))
+	req.Header.Set("Content-Type", "text/calendar")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid feed, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/regions/missing/holidays/import", strings.NewReader(`[]`)))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown region, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,410 @@
+// Package catalog serves the service catalog: the items requesters can order
+// from the portal, each with a form and a fulfillment workflow, and the
+// service requests raised from them. Requests are tracked apart from
+// incident tickets; their tasks open tickets for the teams doing the work.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+// Step kinds. Approvals run one after another, in order; once all are
+// approved every task opens a ticket at the same time.
+const (
+	StepApproval = "approval"
+	StepTask     = "task"
+)
+
+// maxSteps bounds an item's workflow.
+const maxSteps = 20
+
+// Step is one step of an item's fulfillment workflow. Approvals name the
+// user who decides; tasks name the team whose ticket they open, or fall
+// back to the item's team.
+type Step struct {
+	Kind       string  `json:"kind"`
+	Name       string  `json:"name"`
+	ApproverID *string `json:"approver_id,omitempty"`
+	TeamID     *string `json:"team_id,omitempty"`
+}
+
+// Item is an orderable catalog entry.
+type Item struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Category    *string `json:"category"`
+	// Form defines the details a request for the item must give.
+	Form  customfields.Schema `json:"form"`
+	Steps []Step              `json:"steps"`
+	// TeamID fulfills the item's tasks that name no team of their own.
+	TeamID *string `json:"team_id"`
+	// Priority is given to the tickets the item's tasks open.
+	Priority  int16     `json:"priority"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const itemCols = `id::text, name, description, category, form, steps, team_id::text, priority, active, created_at, updated_at`
+
+func scanItem(row pgx.Row) (Item, error) {
+	var it Item
+	var form, steps []byte
+	err := row.Scan(&it.ID, &it.Name, &it.Description, &it.Category, &form, &steps, &it.TeamID, &it.Priority, &it.Active, &it.CreatedAt, &it.UpdatedAt)
+	if err != nil {
+		return it, err
+	}
+	if it.Form, err = customfields.Parse(form); err != nil {
+		return it, err
+	}
+	if it.Form.Fields == nil {
+		it.Form.Fields = []customfields.Field{}
+	}
+	it.Steps = []Step{}
+	if len(steps) > 0 {
+		err = json.Unmarshal(steps, &it.Steps)
+	}
+	return it, err
+}
+
+// itemInput is the writable part of an item. Updates start from the stored
+// item so only the fields present in the body change.
+type itemInput struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Category    *string             `json:"category"`
+	Form        customfields.Schema `json:"form"`
+	Steps       []Step              `json:"steps"`
+	TeamID      *string             `json:"team_id"`
+	Priority    int16               `json:"priority"`
+	Active      bool                `json:"active"`
+}
+
+// check normalizes in and returns the problems with it by field.
+func (in *itemInput) check() map[string]string {
+	errs := map[string]string{}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		errs["name"] = "required"
+	}
+	if in.Category != nil {
+		if cat := strings.TrimSpace(*in.Category); cat == "" {
+			in.Category = nil
+		} else {
+			in.Category = &cat
+		}
+	}
+	if in.Priority < 1 || in.Priority > 4 {
+		errs["priority"] = "must be 1 to 4"
+	}
+	if in.TeamID != nil {
+		if _, err := uuid.Parse(*in.TeamID); err != nil {
+			errs["team_id"] = "must be a team id"
+		}
+	}
+	var schemaErrs customfields.Errors
+	if errors.As(in.Form.Check(), &schemaErrs) {
+		for _, e := range schemaErrs {
+			errs["form."+e.Field] = e.Message
+		}
+	}
+	for k, v := range checkSteps(in.Steps) {
+		errs[k] = v
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// checkSteps returns the problems with a workflow by field: unknown kinds,
+// missing names, approvals without an approver or after a task, and ids
+// that are not UUIDs.
+func checkSteps(steps []Step) map[string]string {
+	errs := map[string]string{}
+	if len(steps) > maxSteps {
+		errs["steps"] = fmt.Sprintf("at most %d steps", maxSteps)
+		return errs
+	}
+	seenTask := false
+	for i := range steps {
+		s := &steps[i]
+		at := fmt.Sprintf("steps[%d]", i)
+		s.Name = strings.TrimSpace(s.Name)
+		if s.Name == "" {
+			errs[at+".name"] = "required"
+		}
+		switch s.Kind {
+		case StepApproval:
+			if seenTask {
+				errs[at+".kind"] = "approvals must come before tasks"
+			}
+			if s.ApproverID == nil {
+				errs[at+".approver_id"] = "required"
+			} else if _, err := uuid.Parse(*s.ApproverID); err != nil {
+				errs[at+".approver_id"] = "must be a user id"
+			}
+			if s.TeamID != nil {
+				errs[at+".team_id"] = "only tasks take a team"
+			}
+		case StepTask:
+			seenTask = true
+			if s.TeamID != nil {
+				if _, err := uuid.Parse(*s.TeamID); err != nil {
+					errs[at+".team_id"] = "must be a team id"
+				}
+			}
+			if s.ApproverID != nil {
+				errs[at+".approver_id"] = "only approvals take an approver"
+			}
+		default:
+			errs[at+".kind"] = "must be approval or task"
+		}
+	}
+	return errs
+}
+
+// Errors for steps naming a user or team that does not exist.
+var (
+	errUnknownApprover = errors.New("unknown approver")
+	errUnknownTeam     = errors.New("unknown team")
+)
+
+// checkRefs makes sure every approver a workflow names is a user and every
+// task team is a team.
+func checkRefs(c *gin.Context, tx apppkg.DB, steps []Step) error {
+	var users, teams []string
+	for _, s := range steps {
+		if s.ApproverID != nil {
+			users = append(users, *s.ApproverID)
+		}
+		if s.TeamID != nil {
+			teams = append(teams, *s.TeamID)
+		}
+	}
+	var missingUser, missingTeam bool
+	if err := tx.QueryRow(c.Request.Context(), `select
+            exists(select 1 from unnest($1::uuid[]) a(id) where not exists (select 1 from users u where u.id = a.id)),
+            exists(select 1 from unnest($2::uuid[]) t(id) where not exists (select 1 from teams tm where tm.id = t.id))`,
+		users, teams).Scan(&missingUser, &missingTeam); err != nil {
+		return err
+	}
+	switch {
+	case missingUser:
+		return errUnknownApprover
+	case missingTeam:
+		return errUnknownTeam
+	}
+	return nil
+}
+
+// itemError maps the errors of saving an item to responses and reports
+// whether it wrote one.
+func itemError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	switch {
+	case errors.Is(err, errUnknownApprover):
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"steps": "an approver is not a user"})
+	case errors.Is(err, errUnknownTeam):
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"steps": "a task team is not a team"})
+	case errors.As(err, &pge) && pge.Code == "23505":
+		apppkg.AbortError(c, http.StatusConflict, "name_taken", "a catalog item with this name exists", nil)
+	case errors.As(err, &pge) && pge.Code == "23503":
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"team_id": "not_found"})
+	default:
+		return false
+	}
+	return true
+}
+
+// ListItems returns the active catalog items, by category then name, for
+// the portal to browse. q searches names, descriptions and categories;
+// category narrows to one category. Staff see inactive items too with
+// all=true.
+func ListItems(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		all := c.Query("all") == "true" && authpkg.IsStaff(c)
+		q := strings.TrimSpace(c.Query("q"))
+		rows, err := a.DB.Query(c.Request.Context(), `select `+itemCols+` from catalog_items
+            where (active or $1)
+              and ($2 = '' or search @@ websearch_to_tsquery('simple', $2) or name ilike '%' || $2 || '%')
+              and ($3 = '' or category = $3)
+            order by category nulls last, name`, all, q, c.Query("category"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list catalog items", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Item{}
+		for rows.Next() {
+			it, err := scanItem(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list catalog items", nil)
+				return
+			}
+			out = append(out, it)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// loadItem returns the item named by :id. Inactive items are only found for
+// staff.
+func loadItem(c *gin.Context, db apppkg.DB) (Item, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return Item{}, pgx.ErrNoRows
+	}
+	it, err := scanItem(db.QueryRow(c.Request.Context(), `select `+itemCols+` from catalog_items where id = $1`, c.Param("id")))
+	if err == nil && !it.Active && !authpkg.IsStaff(c) {
+		return Item{}, pgx.ErrNoRows
+	}
+	return it, err
+}
+
+// GetItem returns one catalog item with its form.
+func GetItem(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		it, err := loadItem(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "catalog item not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load catalog item", nil)
+			return
+		}
+		c.JSON(http.StatusOK, it)
+	}
+}
+
+func itemAudit(it Item) map[string]any {
+	return map[string]any{"name": it.Name, "description": it.Description, "category": it.Category, "form": it.Form,
+		"steps": it.Steps, "team_id": it.TeamID, "priority": it.Priority, "active": it.Active}
+}
+
+// CreateItem adds a catalog item. Requires admin or manager.
+func CreateItem(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := itemInput{Priority: 3, Active: true}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		var it Item
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := checkRefs(c, tx, in.Steps); err != nil {
+				return err
+			}
+			var err error
+			it, err = scanItem(tx.QueryRow(ctx, `insert into catalog_items (name, description, category, form, steps, team_id, priority, active, created_by)
+                values ($1, $2, $3, $4, $5, $6, $7, $8, $9) returning `+itemCols,
+				in.Name, in.Description, in.Category, formJSON(in.Form), stepsJSON(in.Steps), in.TeamID, in.Priority, in.Active, authpkg.Actor(c).DBID()))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "catalog_item", it.ID, "catalog_item_created", itemAudit(it))
+		})
+		if itemError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create catalog item", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, it)
+	}
+}
+
+// UpdateItem changes a catalog item; only the fields present change and a
+// null category or team_id clears it. Requests already submitted keep the
+// workflow they were submitted with. Requires admin or manager.
+func UpdateItem(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, it Item
+		var errs map[string]string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			if before, err = loadItem(c, tx); err != nil {
+				return err
+			}
+			in := itemInput{Name: before.Name, Description: before.Description, Category: before.Category, Form: before.Form,
+				Steps: before.Steps, TeamID: before.TeamID, Priority: before.Priority, Active: before.Active}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			if err := checkRefs(c, tx, in.Steps); err != nil {
+				return err
+			}
+			it, err = scanItem(tx.QueryRow(ctx, `update catalog_items set name = $2, description = $3, category = $4, form = $5, steps = $6,
+                team_id = $7, priority = $8, active = $9, updated_at = now() where id = $1 returning `+itemCols,
+				before.ID, in.Name, in.Description, in.Category, formJSON(in.Form), stepsJSON(in.Steps), in.TeamID, in.Priority, in.Active))
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "catalog_item", it.ID, "catalog_item_updated", audit.Diff(itemAudit(before), itemAudit(it)))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "catalog item not found", nil)
+			return
+		}
+		if errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if itemError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update catalog item", nil)
+			return
+		}
+		c.JSON(http.StatusOK, it)
+	}
+}
+
+func formJSON(form customfields.Schema) []byte {
+	if form.Fields == nil {
+		form.Fields = []customfields.Field{}
+	}
+	b, _ := json.Marshal(form)
+	return b
+}
+
+func stepsJSON(steps []Step) []byte {
+	if steps == nil {
+		steps = []Step{}
+	}
+	b, _ := json.Marshal(steps)
+	return b
+}
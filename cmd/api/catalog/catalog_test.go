@@ -0,0 +1,153 @@
+package catalog
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+func TestCheckSteps(t *testing.T) {
+	approver := "7d5e8b8e-4a8e-4b8e-9b8e-8e8e8e8e8e8e"
+	bad := "nope"
+	errs := checkSteps([]Step{
+		{Kind: StepTask, Name: "Ship laptop"},
+		{Kind: StepApproval, Name: "Manager", ApproverID: &approver},
+		{Kind: StepApproval, Name: " "},
+		{Kind: StepTask, Name: "Image", TeamID: &bad, ApproverID: &approver},
+		{Kind: "notify", Name: "Tell"},
+	})
+	for _, k := range []string{"steps[1].kind", "steps[2].name", "steps[2].approver_id", "steps[3].team_id", "steps[3].approver_id", "steps[4].kind"} {
+		if errs[k] == "" {
+			t.Errorf("no error for %s in %v", k, errs)
+		}
+	}
+	if len(errs) != 7 {
+		t.Errorf("errors = %v", errs)
+	}
+	if errs := checkSteps([]Step{{Kind: StepApproval, Name: "Manager", ApproverID: &approver}, {Kind: StepTask, Name: "Ship"}}); len(errs) != 0 {
+		t.Errorf("valid workflow rejected: %v", errs)
+	}
+}
+
+func TestPlanSteps(t *testing.T) {
+	team, other, approver := "t1", "t2", "u1"
+	steps, status := planSteps(Item{Name: "Laptop", TeamID: &team, Steps: []Step{
+		{Kind: StepApproval, Name: "Manager", ApproverID: &approver},
+		{Kind: StepApproval, Name: "Finance", ApproverID: &approver},
+		{Kind: StepTask, Name: "Order"},
+		{Kind: StepTask, Name: "Image", TeamID: &other},
+	}})
+	if status != StatusPendingApproval {
+		t.Fatalf("status = %s", status)
+	}
+	want := []string{"pending", "waiting", "waiting", "waiting"}
+	for i, s := range steps {
+		if s.Status != want[i] {
+			t.Fatalf("step %d status = %s", i, s.Status)
+		}
+	}
+	if *steps[2].TeamID != team || *steps[3].TeamID != other {
+		t.Fatalf("task teams = %s %s", *steps[2].TeamID, *steps[3].TeamID)
+	}
+
+	steps, status = planSteps(Item{Name: "Monitor", TeamID: &team})
+	if status != StatusFulfilling || len(steps) != 1 || steps[0].Kind != StepTask || steps[0].Name != "Monitor" || *steps[0].TeamID != team {
+		t.Fatalf("item without steps planned %+v %s", steps, status)
+	}
+}
+
+func TestFormatAnswers(t *testing.T) {
+	form := customfields.Schema{Fields: []customfields.Field{
+		{Key: "model", Label: "Model", Type: customfields.TypeSelect, Options: []string{"t14"}},
+		{Key: "ports", Type: customfields.TypeMultiSelect, Options: []string{"hdmi", "usb_c"}},
+		{Key: "rush", Label: "Rush", Type: customfields.TypeBoolean},
+		{Key: "notes", Type: customfields.TypeText},
+	}}
+	got := formatAnswers(form, map[string]any{"rush": true, "ports": []any{"hdmi", "usb_c"}, "model": "t14", "extra": "x"})
+	if want := "Model: t14\nports: hdmi, usb_c\nRush: Yes\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCreateItemValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, &testutil.MockDB{}, nil, nil, nil)
+	a.R.POST("/catalog", CreateItem(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/catalog", strings.NewReader(
+		`{"name":"Laptop","priority":7,"form":{"fields":[{"key":"model","type":"select"}]},"steps":[{"kind":"approval","name":"Manager"}]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	for _, k := range []string{"priority", "form.fields[0].options", "steps[0].approver_id"} {
+		if !strings.Contains(rr.Body.String(), k) {
+			t.Errorf("no error for %s in %s", k, rr.Body.String())
+		}
+	}
+}
+
+func TestCancelRequestAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const (
+		requestID  = "11111111-1111-1111-1111-111111111111"
+		approverID = "22222222-2222-2222-2222-222222222222"
+	)
+	for name, tc := range map[string]struct {
+		user authpkg.AuthUser
+		want int
+	}{
+		"requester":      {authpkg.AuthUser{Email: "ann@acme.io", Roles: []string{"requester"}}, http.StatusOK},
+		"approver":       {authpkg.AuthUser{ID: approverID, Email: "bob@acme.io", Roles: []string{"requester"}}, http.StatusForbidden},
+		"agent approver": {authpkg.AuthUser{ID: approverID, Email: "bob@acme.io", Roles: []string{"agent"}}, http.StatusForbidden},
+		"manager":        {authpkg.AuthUser{Email: "mia@acme.io", Roles: []string{"manager"}}, http.StatusOK},
+		"admin":          {authpkg.AuthUser{Email: "root@acme.io", Roles: []string{"admin"}}, http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cancelled := false
+			// The request was raised by ann@acme.io and waits for approverID;
+			// loadRequest lets both of them see it.
+			db := &testutil.MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+					if strings.Contains(sql, "select exists (select 1 from service_requests r") {
+						return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+							*dest[0].(*bool) = strings.EqualFold(args[1].(string), "ann@acme.io")
+							return nil
+						}}
+					}
+					return &testutil.MockRow{}
+				},
+				QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+					return &testutil.MockRows{}, nil
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+					if strings.Contains(sql, "update service_requests set status = 'cancelled'") {
+						cancelled = true
+						return pgconn.NewCommandTag("UPDATE 1"), nil
+					}
+					return pgconn.CommandTag{}, nil
+				},
+			}
+			a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+			a.R.POST("/service-requests/:id/cancel", func(c *gin.Context) { c.Set("user", tc.user) }, CancelRequest(a))
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/service-requests/"+requestID+"/cancel", nil))
+			if rr.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rr.Code, rr.Body.String())
+			}
+			if cancelled != (tc.want == http.StatusOK) {
+				t.Fatalf("cancelled = %v", cancelled)
+			}
+		})
+	}
+}
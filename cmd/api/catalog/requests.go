@@ -0,0 +1,637 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+)
+
+// Request statuses. Fulfilled is not stored: a fulfilling request is
+// fulfilled once every ticket its tasks opened is resolved or closed.
+const (
+	StatusPendingApproval = "pending_approval"
+	StatusRejected        = "rejected"
+	StatusFulfilling      = "fulfilling"
+	StatusFulfilled       = "fulfilled"
+	StatusCancelled       = "cancelled"
+)
+
+// Request is a service request raised from a catalog item.
+type Request struct {
+	ID          string         `json:"id"`
+	Number      string         `json:"number"`
+	ItemID      string         `json:"item_id"`
+	ItemName    string         `json:"item_name"`
+	RequesterID string         `json:"requester_id"`
+	Requester   string         `json:"requester"`
+	RequestedBy *string        `json:"requested_by"`
+	Answers     map[string]any `json:"answers"`
+	Status      string         `json:"status"`
+	Steps       []RequestStep  `json:"steps,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	ClosedAt    *time.Time     `json:"closed_at"`
+}
+
+// RequestStep is a workflow step of a request. Approvals go waiting,
+// pending, then approved or rejected; tasks go waiting, open (their ticket
+// exists), then done once the ticket is resolved. Steps left when a request
+// is rejected or cancelled are skipped.
+type RequestStep struct {
+	ID           string     `json:"id"`
+	Position     int        `json:"position"`
+	Kind         string     `json:"kind"`
+	Name         string     `json:"name"`
+	ApproverID   *string    `json:"approver_id,omitempty"`
+	TeamID       *string    `json:"team_id,omitempty"`
+	Status       string     `json:"status"`
+	TicketID     *string    `json:"ticket_id,omitempty"`
+	TicketNumber *string    `json:"ticket_number,omitempty"`
+	DecidedBy    *string    `json:"decided_by,omitempty"`
+	Comment      *string    `json:"comment,omitempty"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+}
+
+// taskOpen matches the steps of request r whose ticket is still being
+// worked.
+const taskOpen = `select 1 from service_request_steps so join tickets tk on tk.id = so.ticket_id
+        where so.request_id = r.id and so.status = 'open' and tk.status not in ('Resolved', 'Closed')`
+
+// requestStatus is the status of request r as reported.
+const requestStatus = `case when r.status = 'fulfilling' and not exists (` + taskOpen + `) then 'fulfilled' else r.status end`
+
+const requestCols = `r.id::text, r.number, r.item_id::text, i.name, r.requester_id::text, coalesce(rq.name, rq.email, ''),
+        r.requested_by::text, r.answers, ` + requestStatus + `, r.created_at, r.updated_at, r.closed_at`
+
+const requestFrom = ` from service_requests r join catalog_items i on i.id = r.item_id join requesters rq on rq.id = r.requester_id`
+
+// requestAccess limits requests to the ones a caller without a staff role
+// raised or is asked to approve. $n is their email and $n+1 their user id.
+func requestAccess(n int) string {
+	return fmt.Sprintf(`(lower(rq.email) = lower(nullif($%[1]d, ''))
+            or exists (select 1 from service_request_steps sa where sa.request_id = r.id and sa.approver_id = nullif($%[2]d, '')::uuid))`, n, n+1)
+}
+
+func scanRequest(row pgx.Row) (Request, error) {
+	var r Request
+	var answers []byte
+	err := row.Scan(&r.ID, &r.Number, &r.ItemID, &r.ItemName, &r.RequesterID, &r.Requester, &r.RequestedBy, &answers,
+		&r.Status, &r.CreatedAt, &r.UpdatedAt, &r.ClosedAt)
+	if err != nil {
+		return r, err
+	}
+	r.Answers = map[string]any{}
+	if len(answers) > 0 {
+		err = json.Unmarshal(answers, &r.Answers)
+	}
+	return r, err
+}
+
+// loadRequest returns the request named by :id with its steps, limited to
+// the caller's access.
+func loadRequest(c *gin.Context, db apppkg.DB) (Request, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return Request{}, pgx.ErrNoRows
+	}
+	q := `select ` + requestCols + requestFrom + ` where r.id = $1`
+	args := []any{c.Param("id")}
+	if !authpkg.IsStaff(c) {
+		q += " and " + requestAccess(2)
+		args = append(args, authpkg.TicketAccessArgs(c)...)
+	}
+	r, err := scanRequest(db.QueryRow(c.Request.Context(), q, args...))
+	if err != nil {
+		return r, err
+	}
+	return r, loadSteps(c.Request.Context(), db, &r)
+}
+
+// getRequest returns a request with its steps.
+func getRequest(ctx context.Context, db apppkg.DB, id string) (Request, error) {
+	r, err := scanRequest(db.QueryRow(ctx, `select `+requestCols+requestFrom+` where r.id = $1`, id))
+	if err != nil {
+		return r, err
+	}
+	return r, loadSteps(ctx, db, &r)
+}
+
+func loadSteps(ctx context.Context, db apppkg.DB, r *Request) error {
+	rows, err := db.Query(ctx, `select s.id::text, s.position, s.kind, s.name, s.approver_id::text, s.team_id::text,
+            case when s.status = 'open' and (t.id is null or t.status in ('Resolved', 'Closed')) then 'done' else s.status end,
+            s.ticket_id::text, t.number, s.decided_by::text, s.comment, s.decided_at
+        from service_request_steps s left join tickets t on t.id = s.ticket_id
+        where s.request_id = $1 order by s.position`, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	r.Steps = []RequestStep{}
+	for rows.Next() {
+		var s RequestStep
+		if err := rows.Scan(&s.ID, &s.Position, &s.Kind, &s.Name, &s.ApproverID, &s.TeamID, &s.Status,
+			&s.TicketID, &s.TicketNumber, &s.DecidedBy, &s.Comment, &s.DecidedAt); err != nil {
+			return err
+		}
+		r.Steps = append(r.Steps, s)
+	}
+	return rows.Err()
+}
+
+// plannedStep is a step of a new request with the status it starts in.
+type plannedStep struct {
+	Step
+	Status string
+}
+
+// planSteps lays out the workflow of a new request for item: its approvals
+// in order, the first one pending, then its tasks, which fall back to the
+// item's team. An item without tasks gets one named after it. The status
+// is the request's starting status.
+func planSteps(item Item) ([]plannedStep, string) {
+	var out []plannedStep
+	hasTask := false
+	for _, s := range item.Steps {
+		p := plannedStep{Step: s, Status: "waiting"}
+		switch s.Kind {
+		case StepApproval:
+			if len(out) == 0 {
+				p.Status = "pending"
+			}
+		case StepTask:
+			hasTask = true
+			if p.TeamID == nil {
+				p.TeamID = item.TeamID
+			}
+		}
+		out = append(out, p)
+	}
+	if !hasTask {
+		out = append(out, plannedStep{Step: Step{Kind: StepTask, Name: item.Name, TeamID: item.TeamID}, Status: "waiting"})
+	}
+	if out[0].Kind == StepApproval {
+		return out, StatusPendingApproval
+	}
+	return out, StatusFulfilling
+}
+
+// formatAnswers lists a request's answers, one "Label: value" line per
+// field the form defines, in form order, for the description of its task
+// tickets.
+func formatAnswers(form customfields.Schema, answers map[string]any) string {
+	var b strings.Builder
+	for _, f := range form.Fields {
+		v, ok := answers[f.Key]
+		if !ok || v == nil {
+			continue
+		}
+		label := f.Label
+		if label == "" {
+			label = f.Key
+		}
+		var text string
+		switch v := v.(type) {
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			text = strings.Join(parts, ", ")
+		case bool:
+			text = "No"
+			if v {
+				text = "Yes"
+			}
+		default:
+			text = fmt.Sprint(v)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", label, text)
+	}
+	return b.String()
+}
+
+// approvalRequested tells the approver of the pending step of a request
+// that it waits for them.
+func approvalRequested(ctx context.Context, tx apppkg.DB, number, itemName string, approverID *string) error {
+	if approverID == nil {
+		return nil
+	}
+	_, err := notify.Dispatch(ctx, tx, []string{*approverID}, notify.Message{
+		Event: notify.EventApprovalRequested,
+		Title: fmt.Sprintf("Approval requested: %s", number),
+		Body:  itemName,
+	})
+	return err
+}
+
+// openTasks opens a ticket for every waiting task of a request and marks
+// the request fulfilling. Tickets go to the task's team as the request's
+// requester, with the form answers in the description.
+func openTasks(ctx context.Context, tx apppkg.DB, act actor.Actor, requestID string) error {
+	var number, itemName, requesterID string
+	var priority int16
+	var form, answers []byte
+	if err := tx.QueryRow(ctx, `select r.number, i.name, i.priority, i.form, r.requester_id::text, r.answers
+        from service_requests r join catalog_items i on i.id = r.item_id where r.id = $1`, requestID).
+		Scan(&number, &itemName, &priority, &form, &requesterID, &answers); err != nil {
+		return err
+	}
+	schema, err := customfields.Parse(form)
+	if err != nil {
+		return err
+	}
+	var values map[string]any
+	_ = json.Unmarshal(answers, &values)
+	description := fmt.Sprintf("Service request %s for %s.\n\n%s", number, itemName, formatAnswers(schema, values))
+
+	rows, err := tx.Query(ctx, `select id::text, name, team_id::text from service_request_steps
+        where request_id = $1 and kind = 'task' and status = 'waiting' order by position`, requestID)
+	if err != nil {
+		return err
+	}
+	type task struct {
+		id, name string
+		teamID   *string
+	}
+	var tasks []task
+	for rows.Next() {
+		var t task
+		if err := rows.Scan(&t.id, &t.name, &t.teamID); err != nil {
+			rows.Close()
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	opened := []string{}
+	for _, t := range tasks {
+		title := fmt.Sprintf("%s: %s", number, itemName)
+		if t.name != itemName {
+			title = fmt.Sprintf("%s: %s - %s", number, itemName, t.name)
+		}
+		var ticketID, ticketNumber string
+		if err := tx.QueryRow(ctx, `insert into tickets (number, title, description, requester_id, team_id, priority, status, source)
+            values ('HD-'||nextval('ticket_seq'), $1, $2, $3, (select id from teams where id = $4::uuid), $5, 'New', 'catalog')
+            returning id::text, number`, title, description, requesterID, t.teamID, priority).Scan(&ticketID, &ticketNumber); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `insert into ticket_status_history (ticket_id, to_status) values ($1, 'New')`, ticketID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `update service_request_steps set status = 'open', ticket_id = $2 where id = $1`, t.id, ticketID); err != nil {
+			return err
+		}
+		eventspkg.Emit(ctx, tx, act, ticketID, "ticket_created", map[string]any{"id": ticketID, "service_request_id": requestID})
+		opened = append(opened, ticketNumber)
+	}
+	if _, err := tx.Exec(ctx, `update service_requests set status = 'fulfilling', updated_at = now() where id = $1`, requestID); err != nil {
+		return err
+	}
+	return audit.RecordDiff(ctx, tx, act, "service_request", requestID, "service_request_tasks_opened", map[string]any{"tickets": opened})
+}
+
+// SubmitRequest raises a service request for the catalog item named by :id
+// as the caller, with answers checked against the item's form. The first
+// approval waits for its approver; an item without approvals opens its
+// task tickets at once.
+func SubmitRequest(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Answers map[string]any `json:"answers"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if in.Answers == nil {
+			in.Answers = map[string]any{}
+		}
+		v, _ := c.Get("user")
+		user, _ := v.(authpkg.AuthUser)
+		if strings.TrimSpace(user.Email) == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "no_email", "your account has no email address to raise requests with", nil)
+			return
+		}
+		item, err := loadItem(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && !item.Active) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "catalog item not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load catalog item", nil)
+			return
+		}
+		in.Answers = item.Form.Prune(in.Answers)
+		var fieldErrs customfields.Errors
+		if errors.As(item.Form.Validate(in.Answers), &fieldErrs) {
+			errs := map[string]string{}
+			for _, e := range fieldErrs {
+				errs["answers."+e.Field] = e.Message
+			}
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_answers", "the answers do not match the item's form", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		answers, _ := json.Marshal(in.Answers)
+		steps, status := planSteps(item)
+		var r Request
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var requesterID, id string
+			if err := tx.QueryRow(ctx, `insert into requesters (email, name) values (lower($1), nullif($2, ''))
+                on conflict (email) do update set email = excluded.email returning id::text`, user.Email, user.DisplayName).Scan(&requesterID); err != nil {
+				return err
+			}
+			if err := tx.QueryRow(ctx, `insert into service_requests (item_id, requester_id, requested_by, answers, status)
+                values ($1, $2, $3, $4, 'pending_approval') returning id::text`, item.ID, requesterID, act.DBID(), answers).Scan(&id); err != nil {
+				return err
+			}
+			for i, s := range steps {
+				if _, err := tx.Exec(ctx, `insert into service_request_steps (request_id, position, kind, name, approver_id, team_id, status)
+                    values ($1, $2, $3, $4, $5, $6, $7)`, id, i+1, s.Kind, s.Name, s.ApproverID, s.TeamID, s.Status); err != nil {
+					return err
+				}
+			}
+			if err := audit.RecordDiff(ctx, tx, act, "service_request", id, "service_request_submitted", map[string]any{
+				"item_id": item.ID, "answers": in.Answers}); err != nil {
+				return err
+			}
+			if status == StatusFulfilling {
+				if err := openTasks(ctx, tx, act, id); err != nil {
+					return err
+				}
+			}
+			var err error
+			if r, err = getRequest(ctx, tx, id); err != nil {
+				return err
+			}
+			if status == StatusPendingApproval {
+				return approvalRequested(ctx, tx, r.Number, item.Name, steps[0].ApproverID)
+			}
+			return nil
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to submit request", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+// ListRequests returns service requests newest first, at most 200. Staff
+// see every request; others see the ones they raised or are asked to
+// approve. status filters by status and approver=me to the requests
+// waiting for the caller's approval.
+func ListRequests(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.Query("status")
+		if status != "" && !slices.Contains([]string{StatusPendingApproval, StatusRejected, StatusFulfilling, StatusFulfilled, StatusCancelled}, status) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"status": "unknown status"})
+			return
+		}
+		me := ""
+		if c.Query("approver") == "me" {
+			me = authpkg.Actor(c).ID
+		}
+		q := `select ` + requestCols + requestFrom + `
+            where ($1 = '' or ` + requestStatus + ` = $1)
+              and ($2 = '' or exists (select 1 from service_request_steps sp where sp.request_id = r.id and sp.status = 'pending' and sp.approver_id::text = $2))`
+		args := []any{status, me}
+		if !authpkg.IsStaff(c) {
+			q += " and " + requestAccess(3)
+			args = append(args, authpkg.TicketAccessArgs(c)...)
+		}
+		q += ` order by r.created_at desc limit 200`
+		rows, err := a.DB.Query(c.Request.Context(), q, args...)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list requests", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Request{}
+		for rows.Next() {
+			r, err := scanRequest(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list requests", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// GetRequest returns one service request with its workflow steps.
+func GetRequest(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, err := loadRequest(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "service request not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load request", nil)
+			return
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// Errors for workflow actions that do not apply to a request as it is.
+var (
+	errNotPending  = errors.New("the request is not waiting for an approval")
+	errNotApprover = errors.New("only the step's approver or an admin can decide it")
+	errNotOwner    = errors.New("only the requester, a manager or an admin can cancel it")
+)
+
+// Decide approves or rejects the pending approval of a service request.
+// Only the step's approver or an admin can decide it, and rejections need
+// a comment. Approving the last approval opens the task tickets; rejecting
+// ends the request.
+func Decide(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Decision string `json:"decision"`
+			Comment  string `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		in.Comment = strings.TrimSpace(in.Comment)
+		switch {
+		case in.Decision != "approve" && in.Decision != "reject":
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"decision": "must be approve or reject"})
+			return
+		case in.Decision == "reject" && in.Comment == "":
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"comment": "required to reject"})
+			return
+		}
+		v, _ := c.Get("user")
+		user, _ := v.(authpkg.AuthUser)
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var r Request
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			current, err := loadRequest(c, tx)
+			if err != nil {
+				return err
+			}
+			var stepID, stepName string
+			var position int
+			var approverID *string
+			err = tx.QueryRow(ctx, `select s.id::text, s.position, s.name, s.approver_id::text from service_request_steps s
+                join service_requests r on r.id = s.request_id
+                where s.request_id = $1 and s.status = 'pending' and r.status = 'pending_approval'
+                order by s.position limit 1 for update of s, r`, current.ID).Scan(&stepID, &position, &stepName, &approverID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return errNotPending
+			}
+			if err != nil {
+				return err
+			}
+			if (approverID == nil || *approverID != act.ID) && !slices.Contains(user.Roles, "admin") {
+				return errNotApprover
+			}
+			status := "approved"
+			if in.Decision == "reject" {
+				status = "rejected"
+			}
+			if _, err := tx.Exec(ctx, `update service_request_steps set status = $2, decided_by = $3, comment = nullif($4, ''), decided_at = now() where id = $1`,
+				stepID, status, act.DBID(), in.Comment); err != nil {
+				return err
+			}
+			if err := audit.RecordDiff(ctx, tx, act, "service_request", current.ID, "service_request_"+status, map[string]any{
+				"step": position, "name": stepName, "comment": in.Comment}); err != nil {
+				return err
+			}
+			if status == "rejected" {
+				if _, err := tx.Exec(ctx, `update service_request_steps set status = 'skipped' where request_id = $1 and status = 'waiting'`, current.ID); err != nil {
+					return err
+				}
+				if _, err := tx.Exec(ctx, `update service_requests set status = 'rejected', updated_at = now(), closed_at = now() where id = $1`, current.ID); err != nil {
+					return err
+				}
+			} else {
+				var nextApprover *string
+				err := tx.QueryRow(ctx, `update service_request_steps set status = 'pending' where id = (
+                    select id from service_request_steps where request_id = $1 and kind = 'approval' and status = 'waiting' order by position limit 1)
+                    returning approver_id::text`, current.ID).Scan(&nextApprover)
+				switch {
+				case errors.Is(err, pgx.ErrNoRows):
+					if err := openTasks(ctx, tx, act, current.ID); err != nil {
+						return err
+					}
+				case err != nil:
+					return err
+				default:
+					if _, err := tx.Exec(ctx, `update service_requests set updated_at = now() where id = $1`, current.ID); err != nil {
+						return err
+					}
+					if err := approvalRequested(ctx, tx, current.Number, current.ItemName, nextApprover); err != nil {
+						return err
+					}
+				}
+			}
+			r, err = getRequest(ctx, tx, current.ID)
+			return err
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "service request not found", nil)
+		case errors.Is(err, errNotPending):
+			apppkg.AbortError(c, http.StatusConflict, "not_pending", err.Error(), nil)
+		case errors.Is(err, errNotApprover):
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", err.Error(), nil)
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to record the decision", nil)
+		default:
+			c.JSON(http.StatusOK, r)
+		}
+	}
+}
+
+// CancelRequest withdraws a service request still waiting for approval.
+// The requester, or whoever raised it for them, a manager or an admin can
+// cancel it; approvers decide on it instead. Once tasks are open, their
+// tickets are worked or closed instead.
+func CancelRequest(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var r Request
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			current, err := loadRequest(c, tx)
+			if err != nil {
+				return err
+			}
+			if ok, err := canCancel(c, tx, current.ID); err != nil {
+				return err
+			} else if !ok {
+				return errNotOwner
+			}
+			tag, err := tx.Exec(ctx, `update service_requests set status = 'cancelled', updated_at = now(), closed_at = now()
+                where id = $1 and status = 'pending_approval'`, current.ID)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return errNotPending
+			}
+			if _, err := tx.Exec(ctx, `update service_request_steps set status = 'skipped' where request_id = $1 and status in ('waiting', 'pending')`, current.ID); err != nil {
+				return err
+			}
+			if err := audit.RecordDiff(ctx, tx, act, "service_request", current.ID, "service_request_cancelled", map[string]any{"status": current.Status}); err != nil {
+				return err
+			}
+			r, err = getRequest(ctx, tx, current.ID)
+			return err
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "service request not found", nil)
+		case errors.Is(err, errNotPending):
+			apppkg.AbortError(c, http.StatusConflict, "not_pending", err.Error(), nil)
+		case errors.Is(err, errNotOwner):
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", err.Error(), nil)
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to cancel request", nil)
+		default:
+			c.JSON(http.StatusOK, r)
+		}
+	}
+}
+
+// canCancel reports whether the caller may cancel request id: managers and
+// admins may, as may its requester and whoever raised it for them.
+func canCancel(c *gin.Context, db apppkg.DB, id string) (bool, error) {
+	v, _ := c.Get("user")
+	u, _ := v.(authpkg.AuthUser)
+	if slices.Contains(u.Roles, "manager") || slices.Contains(u.Roles, "admin") {
+		return true, nil
+	}
+	var ok bool
+	err := db.QueryRow(c.Request.Context(), `select exists (select 1 from service_requests r join requesters rq on rq.id = r.requester_id
+        where r.id = $1 and (lower(rq.email) = lower(nullif($2, '')) or r.requested_by = nullif($3, '')::uuid))`,
+		append([]any{id}, authpkg.TicketAccessArgs(c)...)...).Scan(&ok)
+	return ok, err
+}
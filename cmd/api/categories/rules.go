@@ -0,0 +1,177 @@
+// Package categories manages the rules that auto-categorize inbound email.
+package categories
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/categorize"
+)
+
+// ListRules returns every rule in evaluation order.
+func ListRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules, err := categorize.LoadRules(c.Request.Context(), a.DB, false)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list rules", nil)
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+type ruleReq struct {
+	Name        string `json:"name" binding:"required"`
+	Position    int    `json:"position"`
+	Sender      string `json:"sender"`
+	Subject     string `json:"subject"`
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory"`
+	QueueID     string `json:"queue_id"`
+	Active      *bool  `json:"active"`
+}
+
+func (in ruleReq) rule() categorize.Rule {
+	r := categorize.Rule{Name: in.Name, Position: in.Position, Sender: in.Sender, Subject: in.Subject,
+		Category: in.Category, Subcategory: in.Subcategory, QueueID: in.QueueID, Active: true}
+	if in.Active != nil {
+		r.Active = *in.Active
+	}
+	return r
+}
+
+// bindRule reads and validates a rule from the request body.
+func bindRule(c *gin.Context) (categorize.Rule, bool) {
+	var in ruleReq
+	if err := c.ShouldBindJSON(&in); err != nil {
+		app.AbortError(c, http.StatusBadRequest, "invalid_request", "name required", nil)
+		return categorize.Rule{}, false
+	}
+	r := in.rule()
+	if err := r.Validate(); err != nil {
+		app.AbortError(c, http.StatusBadRequest, "invalid_rule", err.Error(), nil)
+		return r, false
+	}
+	return r, true
+}
+
+const ruleValues = `$1, $2, nullif($3,''), nullif($4,''), $5, nullif($6,''), nullif($7,'')::uuid, $8`
+
+func ruleArgs(r categorize.Rule) []any {
+	return []any{r.Name, r.Position, r.Sender, r.Subject, r.Category, r.Subcategory, r.QueueID, r.Active}
+}
+
+// CreateRule adds a rule.
+func CreateRule(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		if err := a.DB.QueryRow(ctx, `insert into category_rules (name, position, sender, subject, category, subcategory, queue_id, active)
+            values (`+ruleValues+`) returning id::text`, ruleArgs(r)...).Scan(&r.ID); err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create rule", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "category_rule", r.ID, "category_rule_created", r); err != nil {
+			log.Error().Err(err).Msg("audit category rule create")
+		}
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+// UpdateRule replaces a rule.
+func UpdateRule(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		r.ID = c.Param("id")
+		err := a.DB.QueryRow(ctx, `update category_rules set (name, position, sender, subject, category, subcategory, queue_id, active)
+            = (`+ruleValues+`), updated_at = now() where id = $9 returning id::text`, append(ruleArgs(r), r.ID)...).Scan(&r.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "rule not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update rule", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "category_rule", r.ID, "category_rule_updated", r); err != nil {
+			log.Error().Err(err).Msg("audit category rule update")
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// DeleteRule removes a rule.
+func DeleteRule(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var name string
+		err := a.DB.QueryRow(ctx, `delete from category_rules where id = $1 returning name`, c.Param("id")).Scan(&name)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "rule not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete rule", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "category_rule", c.Param("id"), "category_rule_deleted", map[string]any{"name": name}); err != nil {
+			log.Error().Err(err).Msg("audit category rule delete")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// TestResponse is a dry-run categorization. Categorized reports whether the
+// result would be applied at the configured confidence threshold.
+type TestResponse struct {
+	categorize.Result
+	Categorized   bool    `json:"categorized"`
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+// TestRules runs the categorizer on a sample email without changing
+// anything, so admins can check rules before tickets arrive.
+func TestRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in categorize.Input
+		if err := c.ShouldBindJSON(&in); err != nil || (in.From == "" && in.Subject == "") {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "from or subject required", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		cs, err := categorize.Classifiers(ctx, a.DB)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		threshold := MinConfidence(a)
+		res, err := categorize.Run(ctx, cs, in, threshold)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, TestResponse{Result: res, Categorized: res.Category != "" && res.Confidence >= threshold, MinConfidence: threshold})
+	}
+}
+
+// MinConfidence is the configured threshold, or the package default.
+func MinConfidence(a *app.App) float64 {
+	if a.Cfg.CategorizeMinConfidence > 0 {
+		return a.Cfg.CategorizeMinConfidence
+	}
+	return categorize.DefaultMinConfidence
+}
@@ -0,0 +1,97 @@
+package categories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestRuleHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stored := false
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				if strings.Contains(sql, "insert into category_rules") {
+					stored = true
+					*dest[0].(*string) = "r1"
+					return nil
+				}
+				return pgx.ErrNoRows
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			if !strings.Contains(sql, "from category_rules") {
+				return &testutil.MockRows{}, nil
+			}
+			n := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { n++; return n == 1 },
+				ScanFunc: func(dest ...interface{}) error {
+					*dest[0].(*string) = "r1"
+					*dest[1].(*string) = "Invoices"
+					*dest[4].(*string) = `invoice`
+					*dest[5].(*string) = "Billing"
+					*dest[8].(*bool) = true
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", CategorizeMinConfidence: 0.8}, db, nil, nil, nil)
+	a.R.POST("/admin/category-rules", CreateRule(a))
+	a.R.PUT("/admin/category-rules/:id", UpdateRule(a))
+	a.R.DELETE("/admin/category-rules/:id", DeleteRule(a))
+	a.R.POST("/admin/category-rules/test", TestRules(a))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/admin/category-rules", `{"name":"x","category":"Billing","subject":"("}`); rr.Code != http.StatusBadRequest || stored {
+		t.Fatalf("expected invalid pattern to be rejected, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/admin/category-rules", `{"name":"x","category":"Billing"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a rule without conditions to be rejected, got %d", rr.Code)
+	}
+	rr := do(http.MethodPost, "/admin/category-rules", `{"name":"Invoices","category":"Billing","subject":"invoice","sender":" @Vendor.com "}`)
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), `"sender":"@vendor.com"`) {
+		t.Fatalf("unexpected create %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPut, "/admin/category-rules/missing", `{"name":"x","category":"Billing","subject":"a"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 updating a missing rule, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/admin/category-rules/missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 deleting a missing rule, got %d", rr.Code)
+	}
+
+	rr = do(http.MethodPost, "/admin/category-rules/test", `{"from":"ap@vendor.com","subject":"Invoice 12 overdue"}`)
+	var res TestResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if rr.Code != http.StatusOK || !res.Categorized || res.Category != "Billing" || res.RuleID != "r1" || res.MinConfidence != 0.8 {
+		t.Fatalf("unexpected test result %d %s", rr.Code, rr.Body.String())
+	}
+	rr = do(http.MethodPost, "/admin/category-rules/test", `{"from":"ap@vendor.com","subject":"Lunch?"}`)
+	res = TestResponse{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &res)
+	if rr.Code != http.StatusOK || res.Categorized || res.Category != "" {
+		t.Fatalf("expected no categorization, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,439 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/attachments"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	commentspkg "github.com/mark3748/helpdesk-go/cmd/api/comments"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	requesterspkg "github.com/mark3748/helpdesk-go/cmd/api/requesters"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/blocklist"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+	"github.com/mark3748/helpdesk-go/internal/verify"
+)
+
+// TokenHeader carries the conversation token. EventSource cannot set
+// headers, so the stream also accepts it as ?token=.
+const TokenHeader = "X-Chat-Token"
+
+// TokenTTL is how long a conversation token stays valid.
+const TokenTTL = 30 * 24 * time.Hour
+
+// sessionKey is the context key holding the Session of a validated token.
+const sessionKey = "chat_session"
+
+// maxBody caps the length of a chat message.
+const maxBody = 20000
+
+// maxTitle caps the length of the ticket title taken from the opening
+// message.
+const maxTitle = 80
+
+// Session is what a validated conversation token grants.
+type Session struct {
+	ConversationID string
+	TicketID       string
+	RequesterID    string
+	// AllowedOrigins are those of the widget the conversation started on.
+	AllowedOrigins []string
+}
+
+// Actor attributes audit entries and events to the conversation.
+func (s Session) Actor() actor.Actor { return actor.Chat(s.ConversationID) }
+
+// Conversation is returned when a conversation starts. The token is only
+// shown then.
+type Conversation struct {
+	ID       string `json:"conversation_id"`
+	TicketID string `json:"ticket_id"`
+	Number   string `json:"number"`
+	Token    string `json:"token"`
+}
+
+// Message is a public comment as relayed to the widget. From is "agent" or
+// "visitor".
+type Message struct {
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	Author    string    `json:"author"`
+	BodyMD    string    `json:"body_md"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func lookup(ctx context.Context, db apppkg.DB, raw string) (Session, error) {
+	var s Session
+	err := db.QueryRow(ctx, `select cc.id::text, cc.ticket_id::text, cc.requester_id::text, w.allowed_origins
+        from chat_conversations cc join chat_widgets w on w.id = cc.widget_id
+        where cc.token_hash=$1 and cc.expires_at > now()`, secret.Hash(raw)).Scan(&s.ConversationID, &s.TicketID, &s.RequesterID, &s.AllowedOrigins)
+	return s, err
+}
+
+// RequireToken authenticates visitor requests with a conversation token. It
+// grants access to the conversation's ticket only, from the widget's allowed
+// origins.
+func RequireToken(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(TokenHeader))
+		if raw == "" {
+			raw = strings.TrimSpace(c.Query("token"))
+		}
+		if raw == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "conversation token required", nil)
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database unavailable", nil)
+			return
+		}
+		s, err := lookup(c.Request.Context(), a.DB, raw)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusUnauthorized, "invalid_token", "invalid or expired conversation", nil)
+			} else {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to validate token", nil)
+			}
+			return
+		}
+		if !allowOrigin(c, s.AllowedOrigins) {
+			return
+		}
+		c.Set(sessionKey, s)
+		c.Next()
+	}
+}
+
+func sessionFrom(c *gin.Context) Session {
+	v, _ := c.Get(sessionKey)
+	s, _ := v.(Session)
+	return s
+}
+
+// closed reports whether a ticket in status no longer takes chat messages.
+func closed(status string) bool {
+	return status == "Resolved" || status == "Closed"
+}
+
+// title names a chat ticket after the first line of its opening message.
+func title(message string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	line = strings.TrimSpace(line)
+	if utf8.RuneCountInString(line) > maxTitle {
+		line = strings.TrimSpace(string([]rune(line)[:maxTitle])) + "…"
+	}
+	return "Chat: " + line
+}
+
+// Start opens a conversation from the widget: it raises a ticket with
+// source "chat" in the widget's queue, the opening message as its
+// description, and returns the conversation token. Visitors who give an
+// email address are matched to that requester, or recorded as a new one
+// asked to verify it, like portal submissions; others are recorded under
+// the name they give.
+func Start(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name    string `json:"name"`
+			Email   string `json:"email"`
+			Message string `json:"message"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		in.Name, in.Email = strings.TrimSpace(in.Name), strings.TrimSpace(in.Email)
+		errs := map[string]string{}
+		if strings.TrimSpace(in.Message) == "" {
+			errs["message"] = "required"
+		} else if len(in.Message) > maxBody {
+			errs["message"] = "too long"
+		}
+		if in.Email != "" && !requesterspkg.ValidEmail(in.Email) {
+			errs["email"] = "invalid"
+		}
+		if len(errs) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		w := widgetFrom(c)
+		ctx := c.Request.Context()
+		if in.Email != "" {
+			b, blocked, err := blocklist.Match(ctx, a.DB, in.Email)
+			if err != nil {
+				log.Error().Err(err).Msg("check requester blocklist")
+			}
+			if blocked {
+				log.Warn().Str("block_id", b.ID).Str("pattern", b.Pattern).Msg("chat from blocked requester rejected")
+				apppkg.AbortError(c, http.StatusForbidden, "requester_blocked", requesterspkg.BlockedMessage, nil)
+				return
+			}
+		}
+		token, err := secret.New("ct_")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "internal", "failed to generate token", nil)
+			return
+		}
+		out := Conversation{Token: token}
+		var requesterID string
+		created := false
+		if in.Email != "" {
+			requesterID, created, err = verify.UpsertRequester(ctx, a.DB, in.Email, in.Name)
+		} else {
+			err = a.DB.QueryRow(ctx, `insert into requesters (name) values (nullif($1,'')) returning id::text`, in.Name).Scan(&requesterID)
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to record visitor", nil)
+			return
+		}
+		if created {
+			if err := verify.Issue(ctx, a.DB, requesterID, in.Email, a.Cfg.PublicURL); err != nil {
+				log.Error().Err(err).Str("requester", requesterID).Msg("issue requester verification")
+			}
+		}
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `insert into tickets (number, title, description, requester_id, queue_id, priority, status, source)
+                values ('HD-'||nextval('ticket_seq'), $1, $2, $3, $4::uuid, 3, 'New', 'chat')
+                returning id::text, number`, title(in.Message), in.Message, requesterID, w.QueueID).Scan(&out.TicketID, &out.Number); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `insert into ticket_status_history (ticket_id, to_status) values ($1, 'New')`, out.TicketID); err != nil {
+				return err
+			}
+			if err := tx.QueryRow(ctx, `insert into chat_conversations (widget_id, ticket_id, requester_id, token_hash, expires_at)
+                values ($1, $2, $3, $4, now() + make_interval(secs => $5)) returning id::text`,
+				w.ID, out.TicketID, requesterID, secret.Hash(token), TokenTTL.Seconds()).Scan(&out.ID); err != nil {
+				return err
+			}
+			eventspkg.Emit(ctx, tx, actor.Chat(out.ID), out.TicketID, "ticket_created", map[string]any{"id": out.TicketID, "widget_id": w.ID})
+			return nil
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to start conversation", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, out)
+	}
+}
+
+// open reports whether the conversation's ticket still takes messages,
+// writing the error response itself when it does not.
+func open(c *gin.Context, a *apppkg.App, s Session) bool {
+	var status string
+	if err := a.DB.QueryRow(c.Request.Context(), `select status from tickets where id=$1`, s.TicketID).Scan(&status); err != nil {
+		apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load ticket", nil)
+		return false
+	}
+	if closed(status) {
+		apppkg.AbortError(c, http.StatusConflict, "conversation_closed", "this conversation has ended", nil)
+		return false
+	}
+	return true
+}
+
+// addMessage records body as the visitor's public comment on the ticket.
+func addMessage(ctx context.Context, a *apppkg.App, s Session, body string) (string, error) {
+	var id string
+	err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+		if err := tx.QueryRow(ctx, `insert into ticket_comments (ticket_id, author_id, author_requester_id, body_md, is_internal)
+            values ($1, null, $2, $3, false) returning id::text`, s.TicketID, s.RequesterID, body).Scan(&id); err != nil {
+			return err
+		}
+		return audit.RecordDiff(ctx, tx, s.Actor(), "ticket", s.TicketID, "chat_message", map[string]any{"conversation_id": s.ConversationID, "comment_id": id})
+	})
+	if err != nil {
+		return "", err
+	}
+	eventspkg.Emit(ctx, a.DB, s.Actor(), s.TicketID, "ticket_updated", map[string]any{"id": s.TicketID})
+	if err := commentspkg.NotifyCCs(ctx, a.DB, s.TicketID, id, body); err != nil {
+		log.Error().Err(err).Msg("failed to record cc comment emails")
+	}
+	return id, nil
+}
+
+// Post adds a visitor message to the conversation's ticket as a public
+// comment. Resolved and closed tickets refuse it with 409.
+func Post(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := sessionFrom(c)
+		var in struct {
+			BodyMD string `json:"body_md"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.BodyMD) == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"body_md": "required"})
+			return
+		}
+		if len(in.BodyMD) > maxBody {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "message too long", map[string]string{"body_md": "too long"})
+			return
+		}
+		if !open(c, a, s) {
+			return
+		}
+		id, err := addMessage(c.Request.Context(), a, s, in.BodyMD)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to add message", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": id})
+	}
+}
+
+// Attach uploads the multipart "file" to the conversation's ticket and
+// records a visitor message naming it, to which the attachment belongs.
+func Attach(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := sessionFrom(c)
+		if !open(c, a, s) {
+			return
+		}
+		attID, ok := attachments.Save(c, a, s.TicketID, nil)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		var filename string
+		_ = a.DB.QueryRow(ctx, `select filename from attachments where id=$1`, attID).Scan(&filename)
+		id, err := addMessage(ctx, a, s, "Attached "+filename)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to add message", nil)
+			return
+		}
+		if _, err := a.DB.Exec(ctx, `update attachments set comment_id=$1 where id=$2`, id, attID); err != nil {
+			log.Error().Err(err).Str("attachment", attID).Msg("link chat attachment to message")
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": id, "attachment_id": attID})
+	}
+}
+
+// Stream relays the conversation over Server-Sent Events: a "message" event
+// per public comment, the visitor's and agents' alike, and a "status" event
+// whenever the ticket's status changes. The opening message comes first
+// unless the client resumes with Last-Event-ID. A "closed" event ends the
+// stream once the token expires.
+func Stream(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := sessionFrom(c)
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		c.Writer.Header().Set("Connection", "keep-alive")
+		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+
+		flusher, ok := c.Writer.(http.Flusher)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx := c.Request.Context()
+		event := func(typ, id string, v any) {
+			b, _ := json.Marshal(v)
+			if id != "" {
+				fmt.Fprintf(c.Writer, "id: %s\n", id)
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", typ, b)
+			flusher.Flush()
+		}
+
+		// Resume from the (created_at, id) of the last comment delivered.
+		last := time.Time{}
+		lastID := c.GetHeader("Last-Event-ID")
+		if lastID != "" {
+			_ = a.DB.QueryRow(ctx, `select created_at from ticket_comments where id=$1 and ticket_id=$2`, lastID, s.TicketID).Scan(&last)
+		} else {
+			var opening Message
+			err := a.DB.QueryRow(ctx, `select coalesce(t.description, ''), coalesce(r.name, r.email, 'Visitor'), t.created_at
+                from tickets t left join requesters r on r.id = t.requester_id where t.id=$1`, s.TicketID).Scan(&opening.BodyMD, &opening.Author, &opening.CreatedAt)
+			if err == nil {
+				opening.From = "visitor"
+				event("message", "", opening)
+			}
+		}
+
+		send := func() {
+			rows, err := a.DB.Query(ctx, `select tc.id::text, tc.author_id is not null, coalesce(u.display_name, r.name, r.email, 'Support'), tc.body_md, tc.created_at
+                from ticket_comments tc
+                left join users u on u.id = tc.author_id
+                left join requesters r on r.id = tc.author_requester_id
+                where tc.ticket_id=$1 and not tc.is_internal and (tc.created_at > $2 or (tc.created_at = $2 and tc.id::text > $3))
+                order by tc.created_at asc, tc.id asc`, s.TicketID, last, lastID)
+			if err != nil {
+				return
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var m Message
+				var agent bool
+				if err := rows.Scan(&m.ID, &agent, &m.Author, &m.BodyMD, &m.CreatedAt); err != nil {
+					continue
+				}
+				m.From = "visitor"
+				if agent {
+					m.From = "agent"
+				}
+				event("message", m.ID, m)
+				last, lastID = m.CreatedAt, m.ID
+			}
+		}
+		status := ""
+		// check reports the ticket's status when it changed and whether the
+		// token is still valid.
+		check := func() bool {
+			var st string
+			var valid bool
+			if err := a.DB.QueryRow(ctx, `select t.status, cc.expires_at > now() from chat_conversations cc
+                join tickets t on t.id = cc.ticket_id where cc.id=$1`, s.ConversationID).Scan(&st, &valid); err != nil {
+				if errors.Is(err, pgx.ErrNoRows) {
+					valid = false
+				} else {
+					return true
+				}
+			}
+			if !valid {
+				event("closed", "", gin.H{"reason": "expired"})
+				return false
+			}
+			if st != status {
+				status = st
+				event("status", "", gin.H{"status": st, "closed": closed(st)})
+			}
+			return true
+		}
+
+		if !check() {
+			return
+		}
+		send()
+
+		poll := time.NewTicker(time.Second)
+		heart := time.NewTicker(25 * time.Second)
+		defer poll.Stop()
+		defer heart.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-poll.C:
+				send()
+				if !check() {
+					return
+				}
+			case <-heart.C:
+				fmt.Fprint(c.Writer, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+	}
+}
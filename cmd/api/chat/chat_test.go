@@ -0,0 +1,297 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+const conversationID = "7a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+
+// site is the origin the test widget allows.
+const site = "https://shop.example.com"
+
+// chatDB serves one widget, keyed "cw_site", filing in queue q1 and
+// embedded on site, and one conversation, whose token hashes to token and whose ticket is in
+// status. It records inserted tickets and comments and audited actions.
+type chatDB struct {
+	status   string
+	token    string
+	tickets  [][]any
+	comments [][]any
+	audits   []string
+}
+
+func (d *chatDB) mock() *testutil.MockDB {
+	return &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from chat_widgets"):
+					if args[0] != secret.Hash("cw_site") {
+						return pgx.ErrNoRows
+					}
+					q := "q1"
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(**string) = "w1", "Website", &q
+					*dest[3].(*[]string) = []string{site}
+				case strings.Contains(sql, "from requester_blocks"):
+					if args[0] != "spam@bad.example" {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string), *dest[1].(*string) = "b1", "bad.example"
+				case strings.Contains(sql, "insert into requesters"):
+					*dest[0].(*string) = "r1"
+					if len(dest) > 1 {
+						*dest[1].(*bool) = false
+					}
+				case strings.Contains(sql, "insert into tickets"):
+					d.tickets = append(d.tickets, args)
+					*dest[0].(*string), *dest[1].(*string) = "t1", "HD-9"
+				case strings.Contains(sql, "insert into chat_conversations"):
+					*dest[0].(*string) = conversationID
+				case strings.Contains(sql, "from chat_conversations") && strings.Contains(sql, "token_hash"):
+					if d.token == "" || args[0] != d.token {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = conversationID, "t1", "r1"
+					*dest[3].(*[]string) = []string{site}
+				case strings.Contains(sql, "from chat_conversations"):
+					*dest[0].(*string), *dest[1].(*bool) = d.status, true
+				case strings.Contains(sql, "select status from tickets"):
+					*dest[0].(*string) = d.status
+				case strings.Contains(sql, "from tickets t"):
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*time.Time) = "My laptop won't boot", "Ada", time.Now()
+				case strings.Contains(sql, "insert into ticket_comments"):
+					d.comments = append(d.comments, args)
+					*dest[0].(*string) = "c1"
+				default:
+					return pgx.ErrNoRows
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "from chat_widgets") {
+				n := 0
+				return &testutil.MockRows{
+					NextFunc: func() bool { n++; return n <= 2 },
+					ScanFunc: func(dest ...any) error {
+						*dest[0].(*[]string) = [][]string{{"https://other.example.com"}, {site}}[n-1]
+						return nil
+					},
+				}, nil
+			}
+			if !strings.Contains(sql, "not tc.is_internal") {
+				panic("chat must not relay internal notes")
+			}
+			n := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { n++; return n == 1 && args[2] == "" },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "c0"
+					*dest[1].(*bool) = true
+					*dest[2].(*string) = "Sam"
+					*dest[3].(*string) = "Have you tried turning it off and on again?"
+					*dest[4].(*time.Time) = time.Now()
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into audit_events") {
+				if args[0] != "chat" || args[1] != conversationID {
+					panic("chat actions must be attributed to the conversation")
+				}
+				d.audits = append(d.audits, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+}
+
+func TestChatConversation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := &chatDB{status: "New"}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, d.mock(), nil, nil, nil)
+	a.R.POST("/chat/conversations", RequireKey(a), Start(a))
+	a.R.POST("/chat/messages", RequireToken(a), Post(a))
+	do := func(path, header, value, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if value != "" {
+			req.Header.Set(header, value)
+		}
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	const opening = `{"name":"Ada","email":"ada@example.com","message":"My laptop won't boot\nIt beeps three times."}`
+	for _, tc := range []struct {
+		key, body string
+		code      int
+	}{
+		{"", opening, http.StatusUnauthorized},
+		{"cw_other", opening, http.StatusUnauthorized},
+		{"cw_site", `{"name":"Ada"}`, http.StatusBadRequest},
+		{"cw_site", `{"email":"not an address","message":"hi"}`, http.StatusBadRequest},
+		{"cw_site", `{"email":"spam@bad.example","message":"hi"}`, http.StatusForbidden},
+	} {
+		if rr := do("/chat/conversations", KeyHeader, tc.key, tc.body); rr.Code != tc.code {
+			t.Fatalf("%q %s: expected %d, got %d %s", tc.key, tc.body, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+	if len(d.tickets) != 0 {
+		t.Fatal("a refused conversation raised a ticket")
+	}
+
+	rr := do("/chat/conversations", KeyHeader, "cw_site", opening)
+	var conv Conversation
+	if err := json.Unmarshal(rr.Body.Bytes(), &conv); err != nil || rr.Code != http.StatusCreated {
+		t.Fatalf("start: got %d %s", rr.Code, rr.Body.String())
+	}
+	if conv.ID != conversationID || conv.Number != "HD-9" || !strings.HasPrefix(conv.Token, "ct_") {
+		t.Fatalf("unexpected conversation: %+v", conv)
+	}
+	d.token = secret.Hash(conv.Token)
+	if args := d.tickets[0]; args[0] != "Chat: My laptop won't boot" || args[2] != "r1" || *args[3].(*string) != "q1" {
+		t.Fatalf("ticket raised with %v", args)
+	}
+
+	if rr := do("/chat/messages", TokenHeader, "ct_other", `{"body_md":"hello?"}`); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown token: expected 401, got %d", rr.Code)
+	}
+	if rr := do("/chat/messages", TokenHeader, conv.Token, `{"body_md":"Still beeping"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("post: expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+	if len(d.comments) != 1 || d.comments[0][0] != "t1" || d.comments[0][1] != "r1" {
+		t.Fatalf("message not attributed to the visitor: %v", d.comments)
+	}
+	if strings.Join(d.audits, ",") != "chat_message" {
+		t.Fatalf("unexpected audit trail: %v", d.audits)
+	}
+
+	d.status = "Resolved"
+	if rr := do("/chat/messages", TokenHeader, conv.Token, `{"body_md":"Thanks!"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("resolved ticket: expected 409, got %d", rr.Code)
+	}
+	if len(d.comments) != 1 {
+		t.Fatal("a resolved conversation took a message")
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := &chatDB{status: "Open", token: secret.Hash("ct_visitor")}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, d.mock(), nil, nil, nil)
+	a.R.GET("/chat/stream", RequireToken(a), Stream(a))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/chat/stream?token=ct_visitor", nil).WithContext(ctx))
+
+	body := rr.Body.String()
+	want := []string{
+		"event: message\ndata: {\"id\":\"\",\"from\":\"visitor\",\"author\":\"Ada\",\"body_md\":\"My laptop won't boot\"",
+		"event: status\ndata: {\"closed\":false,\"status\":\"Open\"}",
+		"id: c0\nevent: message\ndata: {\"id\":\"c0\",\"from\":\"agent\",\"author\":\"Sam\"",
+	}
+	at := 0
+	for _, w := range want {
+		i := strings.Index(body[at:], w)
+		if i < 0 {
+			t.Fatalf("stream missing %q in order:\n%s", w, body)
+		}
+		at += i + len(w)
+	}
+}
+
+func TestChatCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := &chatDB{status: "Open", token: secret.Hash("ct_visitor")}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, d.mock(), nil, nil, nil)
+	a.R.OPTIONS("/chat/messages", Preflight(a))
+	a.R.POST("/chat/conversations", RequireKey(a), Start(a))
+	a.R.POST("/chat/messages", RequireToken(a), Post(a))
+	do := func(method, path, origin, header, value, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		} else {
+			req.Header.Set(header, value)
+		}
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+	allowed := func(rr *httptest.ResponseRecorder, origin string) bool {
+		return rr.Header().Get("Access-Control-Allow-Origin") == origin && rr.Header().Get("Access-Control-Allow-Credentials") == ""
+	}
+
+	rr := do(http.MethodOptions, "/chat/messages", site, "", "", "")
+	if rr.Code != http.StatusNoContent || !allowed(rr, site) || !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), TokenHeader) {
+		t.Fatalf("preflight from the widget's site: got %d %v", rr.Code, rr.Header())
+	}
+	if rr := do(http.MethodOptions, "/chat/messages", "https://evil.example", "", "", ""); rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight from another site: got %d %v", rr.Code, rr.Header())
+	}
+
+	const opening = `{"name":"Ada","message":"Hello"}`
+	// Another widget's site passes the preflight but not this key.
+	if rr := do(http.MethodPost, "/chat/conversations", "https://other.example.com", KeyHeader, "cw_site", opening); rr.Code != http.StatusForbidden {
+		t.Fatalf("start from another widget's site: expected 403, got %d", rr.Code)
+	}
+	if len(d.tickets) != 0 {
+		t.Fatal("a refused origin raised a ticket")
+	}
+	if rr := do(http.MethodPost, "/chat/conversations", site, KeyHeader, "cw_site", opening); rr.Code != http.StatusCreated || !allowed(rr, site) {
+		t.Fatalf("start from the widget's site: got %d %v", rr.Code, rr.Header())
+	}
+
+	if rr := do(http.MethodPost, "/chat/messages", "https://evil.example", TokenHeader, "ct_visitor", `{"body_md":"hi"}`); rr.Code != http.StatusForbidden {
+		t.Fatalf("message from another site: expected 403, got %d", rr.Code)
+	}
+	if len(d.comments) != 0 {
+		t.Fatal("a refused origin posted a message")
+	}
+	if rr := do(http.MethodPost, "/chat/messages", site, TokenHeader, "ct_visitor", `{"body_md":"hi"}`); rr.Code != http.StatusCreated || !allowed(rr, site) {
+		t.Fatalf("message from the widget's site: got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestUpdateWidgetOrigins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	d := &chatDB{}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, d.mock(), nil, nil, nil)
+	a.R.PATCH("/chat/widgets/:id", UpdateWidget(a))
+	for _, tc := range []struct {
+		body string
+		code int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{`{"allowed_origins":["*"]}`, http.StatusBadRequest},
+		{`{"allowed_origins":["shop.example.com"]}`, http.StatusBadRequest},
+		{`{"allowed_origins":["https://*.example.com"]}`, http.StatusNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPatch, "/chat/widgets/w9", strings.NewReader(tc.body)))
+		if rr.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d %s", tc.body, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/cors"
+)
+
+// Visitor endpoints are called from the sites embedding a widget. They
+// answer CORS from the widget's allowed_origins instead of the global
+// allowlist, and never allow credentials: the key and token are sent as
+// headers, so cookies for the helpdesk must not ride along.
+
+// preflightMaxAge is how long browsers may cache a preflight, in seconds.
+const preflightMaxAge = 600
+
+var visitorPaths = map[string]bool{
+	"/chat/conversations": true,
+	"/chat/messages":      true,
+	"/chat/attachments":   true,
+	"/chat/stream":        true,
+}
+
+// VisitorPath reports whether path is a visitor endpoint, mounted at the
+// root or under /api. The global CORS middleware leaves these alone.
+func VisitorPath(path string) bool {
+	return visitorPaths[path] || visitorPaths[strings.TrimPrefix(path, "/api")]
+}
+
+// parseOrigins trims and validates a widget's allowed origins.
+func parseOrigins(in []string) ([]string, error) {
+	out := []string{}
+	for _, o := range in {
+		o = strings.TrimRight(strings.TrimSpace(o), "/")
+		if o == "" {
+			continue
+		}
+		if err := cors.Validate(o); err != nil {
+			return nil, fmt.Errorf("%q: %v", o, err)
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+// allowOrigin answers CORS for a request from a widget allowing origins. A
+// request without an Origin header is not from a browser and passes; one
+// from any other site is refused with 403 and false.
+func allowOrigin(c *gin.Context, origins []string) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		return true
+	}
+	if !cors.Allowed(origin, origins) {
+		apppkg.AbortError(c, http.StatusForbidden, "origin_not_allowed", "origin not allowed for this widget", nil)
+		return false
+	}
+	c.Header("Access-Control-Allow-Origin", origin)
+	return true
+}
+
+// Preflight answers CORS preflights for the visitor endpoints. Browsers
+// send neither key nor token with a preflight, so the origin only has to be
+// allowed by some active widget; the request itself is then checked against
+// its own widget.
+func Preflight(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || a.DB == nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select allowed_origins from chat_widgets where revoked_at is null`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load chat widgets", nil)
+			return
+		}
+		defer rows.Close()
+		allowed := false
+		for rows.Next() && !allowed {
+			var origins []string
+			if err := rows.Scan(&origins); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load chat widgets", nil)
+				return
+			}
+			allowed = cors.Allowed(origin, origins)
+		}
+		if !allowed {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, "+KeyHeader+", "+TokenHeader)
+		c.Header("Access-Control-Max-Age", strconv.Itoa(preflightMaxAge))
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
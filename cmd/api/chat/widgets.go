@@ -0,0 +1,244 @@
+// Package chat is the intake API behind the website chat widget. Admins
+// issue each site a widget key; with it a visitor starts a conversation,
+// which raises a ticket with source "chat" and returns a token for that
+// conversation alone. The token posts messages and files to the ticket as
+// public comments and streams the conversation, agent replies included,
+// over Server-Sent Events.
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+// KeyHeader carries the widget key.
+const KeyHeader = "X-Chat-Key"
+
+// widgetKey is the context key holding the Widget of a validated key.
+const widgetKey = "chat_widget"
+
+// Widget is a chat widget key as listed to admins. The key itself is only
+// returned once, when the widget is created.
+type Widget struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	QueueID *string `json:"queue_id"`
+	// AllowedOrigins are the sites that may call the visitor endpoints
+	// from a browser with this key.
+	AllowedOrigins []string   `json:"allowed_origins"`
+	CreatedAt      time.Time  `json:"created_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	Key            string     `json:"key,omitempty"`
+}
+
+// lookupWidget resolves a raw key to its widget. pgx.ErrNoRows is returned
+// for unknown and revoked keys.
+func lookupWidget(ctx context.Context, db apppkg.DB, raw string) (Widget, error) {
+	var w Widget
+	err := db.QueryRow(ctx, `select id::text, name, queue_id::text, allowed_origins from chat_widgets
+        where key_hash=$1 and revoked_at is null`, secret.Hash(raw)).Scan(&w.ID, &w.Name, &w.QueueID, &w.AllowedOrigins)
+	return w, err
+}
+
+// RequireKey authenticates requests that start a conversation with a widget
+// key instead of a user session. Browser requests must come from one of the
+// widget's allowed origins.
+func RequireKey(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(KeyHeader))
+		if raw == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "widget key required", nil)
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database unavailable", nil)
+			return
+		}
+		w, err := lookupWidget(c.Request.Context(), a.DB, raw)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusUnauthorized, "invalid_key", "invalid widget key", nil)
+			} else {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to validate widget key", nil)
+			}
+			return
+		}
+		if !allowOrigin(c, w.AllowedOrigins) {
+			return
+		}
+		_, _ = a.DB.Exec(c.Request.Context(), `update chat_widgets set last_used_at=now() where id=$1`, w.ID)
+		c.Set(widgetKey, w)
+		c.Next()
+	}
+}
+
+func widgetFrom(c *gin.Context) Widget {
+	v, _ := c.Get(widgetKey)
+	w, _ := v.(Widget)
+	return w
+}
+
+// ListWidgets returns all chat widgets, newest first.
+func ListWidgets(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []Widget{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name, queue_id::text, allowed_origins, created_at, last_used_at, revoked_at
+            from chat_widgets order by created_at desc`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list chat widgets", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Widget{}
+		for rows.Next() {
+			var w Widget
+			if err := rows.Scan(&w.ID, &w.Name, &w.QueueID, &w.AllowedOrigins, &w.CreatedAt, &w.LastUsedAt, &w.RevokedAt); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list chat widgets", nil)
+				return
+			}
+			out = append(out, w)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateWidget issues a widget key. Conversations started with it are filed
+// in queue_id when set. The response is the only time the key is shown.
+func CreateWidget(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name           string   `json:"name" binding:"required"`
+			QueueID        *string  `json:"queue_id"`
+			AllowedOrigins []string `json:"allowed_origins"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Name) == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "name required", nil)
+			return
+		}
+		origins, err := parseOrigins(in.AllowedOrigins)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid allowed_origins", map[string]string{"allowed_origins": err.Error()})
+			return
+		}
+		if in.QueueID != nil && strings.TrimSpace(*in.QueueID) == "" {
+			in.QueueID = nil
+		}
+		if in.QueueID != nil && uuid.Validate(*in.QueueID) != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid queue_id", map[string]string{"queue_id": "must be a UUID"})
+			return
+		}
+		key, err := secret.New("cw_")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "internal", "failed to generate key", nil)
+			return
+		}
+		w := Widget{Name: strings.TrimSpace(in.Name), QueueID: in.QueueID, AllowedOrigins: origins, Key: key}
+		if a.DB == nil {
+			c.JSON(http.StatusCreated, w)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `insert into chat_widgets (name, key_hash, queue_id, allowed_origins, created_by)
+                values ($1, $2, (select id from queues where id = $3::uuid), $4, $5) returning id::text, created_at`,
+				w.Name, secret.Hash(key), w.QueueID, w.AllowedOrigins, act.DBID()).Scan(&w.ID, &w.CreatedAt); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "chat_widget", w.ID, "chat_widget_created",
+				map[string]any{"name": w.Name, "queue_id": w.QueueID, "allowed_origins": w.AllowedOrigins})
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create chat widget", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, w)
+	}
+}
+
+// UpdateWidget replaces the origins allowed to use an active widget.
+func UpdateWidget(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			AllowedOrigins *[]string `json:"allowed_origins"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || in.AllowedOrigins == nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "allowed_origins required", nil)
+			return
+		}
+		origins, err := parseOrigins(*in.AllowedOrigins)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid allowed_origins", map[string]string{"allowed_origins": err.Error()})
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "chat widget not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var w Widget
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `update chat_widgets set allowed_origins=$2 where id=$1 and revoked_at is null
+                returning id::text, name, queue_id::text, allowed_origins, created_at, last_used_at`, c.Param("id"), origins).
+				Scan(&w.ID, &w.Name, &w.QueueID, &w.AllowedOrigins, &w.CreatedAt, &w.LastUsedAt); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "chat_widget", w.ID, "chat_widget_updated", map[string]any{"allowed_origins": w.AllowedOrigins})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "chat widget not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update chat widget", nil)
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// RevokeWidget disables a widget key. Conversations already started keep
+// their tokens.
+func RevokeWidget(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		ctx := c.Request.Context()
+		var found bool
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			tag, err := tx.Exec(ctx, `update chat_widgets set revoked_at=now() where id=$1 and revoked_at is null`, c.Param("id"))
+			if err != nil || tag.RowsAffected() == 0 {
+				return err
+			}
+			found = true
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "chat_widget", c.Param("id"), "chat_widget_revoked", nil)
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to revoke chat widget", nil)
+			return
+		}
+		if !found {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "chat widget not found", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,418 @@
+// Package cmdb maps business services to the assets supporting them. A
+// service lists the assets it runs on directly; the asset relationship
+// graph supplies the rest, so an incident on a service can enumerate the
+// assets it relies on and a failing asset the services it takes down.
+// Maintenance windows are scheduled per service.
+package cmdb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	assetspkg "github.com/mark3748/helpdesk-go/cmd/api/assets"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// Criticalities lists how much a service matters, lowest first.
+var Criticalities = []string{"low", "medium", "high", "critical"}
+
+// maxGraphDepth bounds how far the relationship graph is followed.
+const maxGraphDepth = 10
+
+// BusinessService is a service the business depends on.
+type BusinessService struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	OwnerTeamID *string `json:"owner_team_id"`
+	Criticality string  `json:"criticality"`
+	// AssetCount counts the assets mapped to the service directly.
+	AssetCount int `json:"asset_count"`
+	// InMaintenance is true inside one of the service's maintenance windows.
+	InMaintenance bool          `json:"in_maintenance"`
+	Assets        []MappedAsset `json:"assets,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// MappedAsset is an asset mapped to a service directly.
+type MappedAsset struct {
+	AssetID  string  `json:"asset_id"`
+	AssetTag string  `json:"asset_tag"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Notes    *string `json:"notes"`
+}
+
+// AffectedAsset is an asset a service relies on: mapped to it (depth 0) or
+// a dependency, direct or not, of a mapped asset.
+type AffectedAsset struct {
+	ID       string `json:"id"`
+	AssetTag string `json:"asset_tag"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Depth    int    `json:"depth"`
+}
+
+// inMaintenance matches a maintenance window of service s in force now.
+const inMaintenance = `exists(select 1 from service_maintenance_windows w where w.service_id = s.id and now() >= w.starts_at and now() < w.ends_at)`
+
+const serviceCols = `s.id::text, s.name, s.description, s.owner_team_id::text, s.criticality,
+        (select count(*) from business_service_assets m where m.service_id = s.id), ` + inMaintenance + `, s.created_at, s.updated_at`
+
+func scanService(row pgx.Row) (BusinessService, error) {
+	var s BusinessService
+	err := row.Scan(&s.ID, &s.Name, &s.Description, &s.OwnerTeamID, &s.Criticality, &s.AssetCount, &s.InMaintenance, &s.CreatedAt, &s.UpdatedAt)
+	return s, err
+}
+
+// serviceError maps constraint violations on services to responses and
+// reports whether it wrote one.
+func serviceError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	switch {
+	case errors.As(err, &pge) && pge.Code == "23505":
+		apppkg.AbortError(c, http.StatusConflict, "name_taken", "a business service with this name exists", nil)
+	case errors.As(err, &pge) && pge.Code == "23503":
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"owner_team_id": "not_found"})
+	default:
+		return false
+	}
+	return true
+}
+
+// ListServices returns every business service by name.
+func ListServices(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+serviceCols+` from business_services s order by s.name`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list business services", nil)
+			return
+		}
+		defer rows.Close()
+		out := []BusinessService{}
+		for rows.Next() {
+			s, err := scanService(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list business services", nil)
+				return
+			}
+			out = append(out, s)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+func loadService(c *gin.Context, db apppkg.DB) (BusinessService, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return BusinessService{}, pgx.ErrNoRows
+	}
+	return scanService(db.QueryRow(c.Request.Context(), `select `+serviceCols+` from business_services s where s.id = $1`, c.Param("id")))
+}
+
+// GetService returns a business service with the assets mapped to it.
+func GetService(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		s, err := loadService(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load business service", nil)
+			return
+		}
+		rows, err := a.DB.Query(ctx, `select a.id::text, a.asset_tag, a.name, a.status, m.notes from business_service_assets m
+            join assets a on a.id = m.asset_id where m.service_id = $1 order by a.asset_tag`, s.ID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load business service", nil)
+			return
+		}
+		defer rows.Close()
+		s.Assets = []MappedAsset{}
+		for rows.Next() {
+			var m MappedAsset
+			if err := rows.Scan(&m.AssetID, &m.AssetTag, &m.Name, &m.Status, &m.Notes); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load business service", nil)
+				return
+			}
+			s.Assets = append(s.Assets, m)
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// serviceInput is the writable part of a service. Updates start from the
+// stored service so only the fields present in the body change.
+type serviceInput struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	OwnerTeamID *string `json:"owner_team_id"`
+	Criticality string  `json:"criticality"`
+}
+
+// check normalizes in and returns the problems with it by field.
+func (in *serviceInput) check() map[string]string {
+	errs := map[string]string{}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		errs["name"] = "required"
+	}
+	if !slices.Contains(Criticalities, in.Criticality) {
+		errs["criticality"] = "must be low, medium, high or critical"
+	}
+	if in.OwnerTeamID != nil {
+		if _, err := uuid.Parse(*in.OwnerTeamID); err != nil {
+			errs["owner_team_id"] = "must be a team id"
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func serviceAudit(s BusinessService) map[string]any {
+	return map[string]any{"name": s.Name, "description": s.Description, "owner_team_id": s.OwnerTeamID, "criticality": s.Criticality}
+}
+
+// CreateService adds a business service. Requires admin or manager.
+func CreateService(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := serviceInput{Criticality: "medium"}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		var s BusinessService
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			s, err = scanService(tx.QueryRow(ctx, `with s as (insert into business_services (name, description, owner_team_id, criticality)
+                values ($1, $2, $3, $4) returning *) select `+serviceCols+` from s`, in.Name, in.Description, in.OwnerTeamID, in.Criticality))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "business_service", s.ID, "business_service_created", serviceAudit(s))
+		})
+		if serviceError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create business service", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, s)
+	}
+}
+
+// UpdateService changes a business service; only the fields present change
+// and a null owner_team_id clears it. Requires admin or manager.
+func UpdateService(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, s BusinessService
+		var errs map[string]string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			if before, err = loadService(c, tx); err != nil {
+				return err
+			}
+			in := serviceInput{Name: before.Name, Description: before.Description, OwnerTeamID: before.OwnerTeamID, Criticality: before.Criticality}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			s, err = scanService(tx.QueryRow(ctx, `with s as (update business_services set name = $2, description = $3, owner_team_id = $4,
+                criticality = $5, updated_at = now() where id = $1 returning *) select `+serviceCols+` from s`,
+				before.ID, in.Name, in.Description, in.OwnerTeamID, in.Criticality))
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "business_service", s.ID, "business_service_updated", audit.Diff(serviceAudit(before), serviceAudit(s)))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+			return
+		}
+		if errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if serviceError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update business service", nil)
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// MapAsset maps an asset to a service directly, or updates the mapping's
+// notes. Requires admin or manager.
+func MapAsset(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Notes *string `json:"notes"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+				return
+			}
+		}
+		if _, err := uuid.Parse(c.Param("asset_id")); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			s, err := loadService(c, tx)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `insert into business_service_assets (service_id, asset_id, notes) values ($1, $2, nullif($3, ''))
+                on conflict (service_id, asset_id) do update set notes = excluded.notes`, s.ID, c.Param("asset_id"), in.Notes); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "business_service", s.ID, "service_asset_mapped", map[string]any{
+				"asset_id": c.Param("asset_id"), "notes": in.Notes})
+		})
+		var pge *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+		case errors.As(err, &pge) && pge.Code == "23503":
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to map asset", nil)
+		default:
+			c.Status(http.StatusNoContent)
+		}
+	}
+}
+
+// UnmapAsset removes an asset's direct mapping to a service. Requires admin
+// or manager.
+func UnmapAsset(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			tag, err := tx.Exec(ctx, `delete from business_service_assets where service_id::text = $1 and asset_id::text = $2`, c.Param("id"), c.Param("asset_id"))
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return pgx.ErrNoRows
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "business_service", c.Param("id"), "service_asset_unmapped", map[string]any{"asset_id": c.Param("asset_id")})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "mapping not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to unmap asset", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// AffectedAssets lists the assets a service relies on: the ones mapped to
+// it and, through dependency relationships, the assets those depend on,
+// nearest first and at most 500.
+func AffectedAssets(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		s, err := loadService(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load business service", nil)
+			return
+		}
+		rows, err := a.DB.Query(ctx, `with recursive upstream as (
+                select asset_id as id, 0 as depth, array[asset_id] as path from business_service_assets where service_id = $1
+                union all
+                select ar.parent_asset_id, u.depth + 1, u.path || ar.parent_asset_id
+                from asset_relationships ar join upstream u on ar.child_asset_id = u.id
+                where ar.relationship_type = 'dependency' and u.depth < $2 and not ar.parent_asset_id = any(u.path)
+            )
+            select a.id::text, a.asset_tag, a.name, a.status, min(u.depth) as depth
+            from upstream u join assets a on a.id = u.id
+            group by a.id, a.asset_tag, a.name, a.status
+            order by depth, a.asset_tag limit 500`, s.ID, maxGraphDepth)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list affected assets", nil)
+			return
+		}
+		defer rows.Close()
+		out := []AffectedAsset{}
+		for rows.Next() {
+			var as AffectedAsset
+			if err := rows.Scan(&as.ID, &as.AssetTag, &as.Name, &as.Status, &as.Depth); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list affected assets", nil)
+				return
+			}
+			out = append(out, as)
+		}
+		c.JSON(http.StatusOK, gin.H{"service": s, "assets": out})
+	}
+}
+
+// AssetServices lists the business services that fail with the asset
+// named by :id: those mapped to it or to an asset depending on it.
+func AssetServices(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var exists bool
+		if err := a.DB.QueryRow(ctx, `select exists(select 1 from assets where id = $1)`, id).Scan(&exists); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load asset", nil)
+			return
+		}
+		if !exists {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "asset not found", nil)
+			return
+		}
+		services, err := assetspkg.NewService(a.DB).AffectedServices(ctx, id)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list affected services", nil)
+			return
+		}
+		c.JSON(http.StatusOK, services)
+	}
+}
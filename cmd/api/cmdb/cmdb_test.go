@@ -0,0 +1,49 @@
+package cmdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceInputCheck(t *testing.T) {
+	team := "not-a-uuid"
+	in := serviceInput{Name: "  ", Criticality: "urgent", OwnerTeamID: &team}
+	errs := in.check()
+	for _, field := range []string{"name", "criticality", "owner_team_id"} {
+		if errs[field] == "" {
+			t.Fatalf("missing %s error: %v", field, errs)
+		}
+	}
+	in = serviceInput{Name: " Email ", Criticality: "critical"}
+	if errs := in.check(); errs != nil {
+		t.Fatalf("valid input rejected: %v", errs)
+	}
+	if in.Name != "Email" {
+		t.Fatalf("name not trimmed: %q", in.Name)
+	}
+}
+
+func TestWindowInputCheck(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(h int) *time.Time { v := now.Add(time.Duration(h) * time.Hour); return &v }
+	cases := []struct {
+		name  string
+		in    windowInput
+		field string
+	}{
+		{"missing title", windowInput{StartsAt: at(1), EndsAt: at(2)}, "title"},
+		{"missing start", windowInput{Title: "x", EndsAt: at(2)}, "starts_at"},
+		{"missing end", windowInput{Title: "x", StartsAt: at(1)}, "ends_at"},
+		{"ends before start", windowInput{Title: "x", StartsAt: at(2), EndsAt: at(1)}, "ends_at"},
+		{"already over", windowInput{Title: "x", StartsAt: at(-3), EndsAt: at(-1)}, "ends_at"},
+	}
+	for _, tc := range cases {
+		if errs := tc.in.check(now); errs[tc.field] == "" {
+			t.Fatalf("%s: got %v", tc.name, errs)
+		}
+	}
+	ongoing := windowInput{Title: " Patch ", StartsAt: at(-1), EndsAt: at(1)}
+	if errs := ongoing.check(now); errs != nil {
+		t.Fatalf("ongoing window rejected: %v", errs)
+	}
+}
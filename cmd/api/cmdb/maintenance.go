@@ -0,0 +1,185 @@
+package cmdb
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// errOverlap reports a maintenance window overlapping another of the same
+// service.
+var errOverlap = errors.New("overlapping maintenance window")
+
+// MaintenanceWindow is a period a service is expected to be down.
+type MaintenanceWindow struct {
+	ID        string    `json:"id"`
+	ServiceID string    `json:"service_id"`
+	Title     string    `json:"title"`
+	Notes     *string   `json:"notes"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy *string   `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const windowCols = `id::text, service_id::text, title, notes, starts_at, ends_at, created_by::text, created_at`
+
+func scanWindow(row pgx.Row) (MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	err := row.Scan(&w.ID, &w.ServiceID, &w.Title, &w.Notes, &w.StartsAt, &w.EndsAt, &w.CreatedBy, &w.CreatedAt)
+	return w, err
+}
+
+// windowInput is a maintenance window to schedule.
+type windowInput struct {
+	Title    string     `json:"title"`
+	Notes    *string    `json:"notes"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// check normalizes in and returns the problems with it by field. Windows
+// must end after they start and not already be over at now.
+func (in *windowInput) check(now time.Time) map[string]string {
+	errs := map[string]string{}
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		errs["title"] = "required"
+	}
+	if in.StartsAt == nil {
+		errs["starts_at"] = "required"
+	}
+	switch {
+	case in.EndsAt == nil:
+		errs["ends_at"] = "required"
+	case in.StartsAt != nil && !in.EndsAt.After(*in.StartsAt):
+		errs["ends_at"] = "must be after starts_at"
+	case !in.EndsAt.After(now):
+		errs["ends_at"] = "must be in the future"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ListWindows returns a service's current and upcoming maintenance windows
+// by start; past=true includes the ones already over.
+func ListWindows(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		s, err := loadService(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load business service", nil)
+			return
+		}
+		rows, err := a.DB.Query(ctx, `select `+windowCols+` from service_maintenance_windows
+            where service_id = $1 and ($2 or ends_at > now()) order by starts_at`, s.ID, c.Query("past") == "true")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list maintenance windows", nil)
+			return
+		}
+		defer rows.Close()
+		out := []MaintenanceWindow{}
+		for rows.Next() {
+			w, err := scanWindow(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list maintenance windows", nil)
+				return
+			}
+			out = append(out, w)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// ScheduleWindow schedules a maintenance window for a service. Windows of
+// one service may not overlap. Requires admin or manager.
+func ScheduleWindow(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in windowInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(time.Now()); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var w MaintenanceWindow
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			s, err := loadService(c, tx)
+			if err != nil {
+				return err
+			}
+			// Serialize scheduling per service so the overlap check holds.
+			if _, err := tx.Exec(ctx, `select 1 from business_services where id = $1 for update`, s.ID); err != nil {
+				return err
+			}
+			var overlap bool
+			if err := tx.QueryRow(ctx, `select exists(select 1 from service_maintenance_windows
+                where service_id = $1 and starts_at < $3 and ends_at > $2)`, s.ID, *in.StartsAt, *in.EndsAt).Scan(&overlap); err != nil {
+				return err
+			}
+			if overlap {
+				return errOverlap
+			}
+			w, err = scanWindow(tx.QueryRow(ctx, `insert into service_maintenance_windows (service_id, title, notes, starts_at, ends_at, created_by)
+                values ($1, $2, nullif($3, ''), $4, $5, $6) returning `+windowCols, s.ID, in.Title, in.Notes, *in.StartsAt, *in.EndsAt, act.DBID()))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "business_service", s.ID, "maintenance_window_scheduled", map[string]any{
+				"window_id": w.ID, "title": w.Title, "starts_at": w.StartsAt, "ends_at": w.EndsAt})
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "business service not found", nil)
+		case errors.Is(err, errOverlap):
+			apppkg.AbortError(c, http.StatusConflict, "overlap", "the window overlaps another maintenance window of this service", nil)
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to schedule maintenance window", nil)
+		default:
+			c.JSON(http.StatusCreated, w)
+		}
+	}
+}
+
+// CancelWindow removes a maintenance window. Requires admin or manager.
+func CancelWindow(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			w, err := scanWindow(tx.QueryRow(ctx, `delete from service_maintenance_windows where id::text = $1 and service_id::text = $2
+                returning `+windowCols, c.Param("wid"), c.Param("id")))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "business_service", w.ServiceID, "maintenance_window_cancelled", map[string]any{
+				"window_id": w.ID, "title": w.Title, "starts_at": w.StartsAt, "ends_at": w.EndsAt})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "maintenance window not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to cancel maintenance window", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
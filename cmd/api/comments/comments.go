@@ -1,13 +1,18 @@
 package comments
 
 import (
-	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,26 +22,49 @@ func List(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusOK, []any{})
 			return
 		}
-		const q = `select id::text, body_md from ticket_comments where ticket_id=$1 order by created_at asc`
-		rows, err := a.DB.Query(c.Request.Context(), q, c.Param("id"))
+		// Requesters cannot upload avatars, so their comments use Gravatar.
+		const q = `select tc.id::text, tc.body_md, coalesce(u.id::text,''), coalesce(u.avatar_key,''), coalesce(u.email, r.email, ''), tc.seen_at
+			from ticket_comments tc
+			left join users u on u.id=tc.author_id
+			left join requesters r on r.id=tc.author_requester_id
+			where tc.ticket_id=$1 and (not tc.is_internal or $2) order by tc.created_at asc`
+		staff := authpkg.IsStaff(c)
+		rows, err := a.DB.Query(c.Request.Context(), q, c.Param("id"), staff)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		defer rows.Close()
 		type resp struct {
-			ID     string `json:"id"`
-			BodyMD string `json:"body_md"`
+			ID        string `json:"id"`
+			BodyMD    string `json:"body_md"`
+			AvatarURL string `json:"avatar_url,omitempty"`
+			// SeenAt is when the requester first saw the reply; staff only.
+			SeenAt *time.Time `json:"seen_at,omitempty"`
 		}
 		var out []resp
 		for rows.Next() {
 			var r resp
-			if err := rows.Scan(&r.ID, &r.BodyMD); err != nil {
+			var authorID, avatarKey, email string
+			if err := rows.Scan(&r.ID, &r.BodyMD, &authorID, &avatarKey, &email, &r.SeenAt); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			r.AvatarURL = avatars.URL(authorID, avatarKey, email)
+			if !staff {
+				r.SeenAt = nil
+			}
 			out = append(out, r)
 		}
+		rows.Close()
+		if !staff && receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourcePortal) {
+			uVal, _ := c.Get("user")
+			if au, ok := uVal.(authpkg.AuthUser); ok && au.Email != "" {
+				if err := receipts.SeenByRequester(c.Request.Context(), a.DB, c.Param("id"), au.Email); err != nil {
+					log.Error().Err(err).Msg("record read receipt")
+				}
+			}
+		}
 		c.JSON(http.StatusOK, out)
 	}
 }
@@ -48,33 +76,47 @@ func Add(a *app.App) gin.HandlerFunc {
 			return
 		}
 		var in struct {
-			BodyMD string `json:"body_md"`
+			BodyMD     string `json:"body_md"`
+			IsInternal bool   `json:"is_internal"`
 		}
 		if err := c.ShouldBindJSON(&in); err != nil || in.BodyMD == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
 			return
 		}
+		// Only staff write internal notes.
+		if !authpkg.IsStaff(c) {
+			in.IsInternal = false
+		}
 		uVal, _ := c.Get("user")
 		au, _ := uVal.(authpkg.AuthUser)
-		const q = `insert into ticket_comments (ticket_id, author_id, body_md) values ($1, $2, $3) returning id::text`
+		const q = `insert into ticket_comments (ticket_id, author_id, body_md, is_internal) values ($1, $2, $3, $4) returning id::text`
 		var id string
-		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("id"), au.ID, in.BodyMD).Scan(&id); err != nil {
+		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("id"), au.ID, in.BodyMD, in.IsInternal).Scan(&id); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		eventspkg.Emit(c.Request.Context(), a.DB, c.Param("id"), "ticket_updated", map[string]any{"id": c.Param("id")})
+		if !in.IsInternal && authpkg.IsStaff(c) {
+			if _, err := sla.RecordFirstResponse(c.Request.Context(), a.DB, c.Param("id")); err != nil {
+				log.Error().Err(err).Str("ticket", c.Param("id")).Msg("record first response")
+			}
+		}
+		eventspkg.Emit(c.Request.Context(), a.DB, authpkg.Actor(c), c.Param("id"), "ticket_updated", map[string]any{"id": c.Param("id")})
+		if err := webhook.Publish(c.Request.Context(), a.DB, "comment.created", c.Param("id"), map[string]any{"comment_id": id}); err != nil {
+			log.Error().Err(err).Msg("publish comment created")
+		}
+
+		if in.IsInternal {
+			c.JSON(http.StatusCreated, gin.H{"id": id})
+			return
+		}
+		if err := NotifyCCs(c.Request.Context(), a.DB, c.Param("id"), id, in.BodyMD); err != nil {
+			log.Error().Err(err).Msg("failed to record cc comment emails")
+		}
 
 		// Enqueue Discord comment sync job if Redis is configured
 		if a.Q != nil {
-			jobData, _ := json.Marshal(map[string]any{
-				"ticket_id": c.Param("id"),
-				"body_md":   in.BodyMD,
-			})
-			job, _ := json.Marshal(map[string]any{
-				"type": "discord_outgoing_comment",
-				"data": json.RawMessage(jobData),
-			})
-			if err := a.Q.RPush(c.Request.Context(), "jobs", job).Err(); err != nil {
+			job, _ := jobs.Encode("", jobs.TypeDiscordOutgoingComment, jobs.DiscordComment{TicketID: c.Param("id"), BodyMD: in.BodyMD})
+			if err := a.Q.RPush(c.Request.Context(), jobs.Queue, job).Err(); err != nil {
 				log.Error().Err(err).Msg("failed to enqueue discord comment job")
 			}
 		}
@@ -109,6 +109,47 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestInternalCommentsStaffOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var listArgs, addArgs []any
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			if !strings.Contains(sql, "not tc.is_internal or $2") {
+				t.Fatalf("expected internal comments to be filtered: %s", sql)
+			}
+			listArgs = args
+			return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			addArgs = args
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				*(dest[0].(*string)) = "c1"
+				return nil
+			}}
+		},
+	}
+	for _, tc := range []struct {
+		roles []string
+		staff bool
+	}{{[]string{"agent"}, true}, {[]string{"requester"}, false}, {nil, false}} {
+		a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+		setUser := func(c *gin.Context) { c.Set("user", authpkg.AuthUser{ID: "u1", Roles: tc.roles}) }
+		a.R.GET("/tickets/:id/comments", setUser, List(a))
+		a.R.POST("/tickets/:id/comments", setUser, Add(a))
+
+		a.R.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/tickets/1/comments", nil))
+		if listArgs[1] != tc.staff {
+			t.Fatalf("%v: expected internal comments listed = %v", tc.roles, tc.staff)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/tickets/1/comments", strings.NewReader(`{"body_md":"x","is_internal":true}`))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(httptest.NewRecorder(), req)
+		if addArgs[3] != tc.staff {
+			t.Fatalf("%v: expected internal comment written = %v", tc.roles, tc.staff)
+		}
+	}
+}
+
 func TestAdd(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -284,3 +325,91 @@ func TestAdd_EnqueuesDiscordOutgoingCommentJob(t *testing.T) {
 		t.Fatalf("payload.body_md = %q, want new comment", payload.BodyMD)
 	}
 }
+
+func TestAdd_NotifiesCCsOnPublicComments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for name, tc := range map[string]struct {
+		body string
+		want bool
+	}{
+		"public":   {`{"body_md":"hello"}`, true},
+		"internal": {`{"body_md":"note","is_internal":true}`, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var notified bool
+			db := &testutil.MockDB{
+				QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+					return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+						*(dest[0].(*string)) = "c-new"
+						return nil
+					}}
+				},
+				ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+					if strings.Contains(sql, "from ticket_ccs") {
+						notified = true
+						if args[1] != "ticket_comment_email:c-new:" || !strings.Contains(args[2].(string), `"ticket_comment"`) {
+							t.Fatalf("unexpected outbox args: %v", args)
+						}
+					}
+					return pgconn.CommandTag{}, nil
+				},
+			}
+			a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+			a.R.POST("/tickets/:id/comments", authpkg.Middleware(a), Add(a))
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/t1/comments", strings.NewReader(tc.body)))
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("status = %d", rr.Code)
+			}
+			if notified != tc.want {
+				t.Fatalf("cc notification = %v, want %v", notified, tc.want)
+			}
+		})
+	}
+}
+
+func TestAdd_RecordsFirstResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var stamped []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				*(dest[0].(*string)) = "c1"
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "first_response_at = now()") {
+				if strings.Contains(sql, "user_roles") {
+					t.Fatalf("expected the handler's staff check to decide, got %s", sql)
+				}
+				stamped = append(stamped, args...)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	for _, tc := range []struct {
+		roles []string
+		body  string
+		want  bool
+	}{
+		{[]string{"agent"}, `{"body_md":"x"}`, true},
+		{[]string{"agent"}, `{"body_md":"x","is_internal":true}`, false},
+		{[]string{"requester"}, `{"body_md":"x"}`, false},
+		// Staff roles from token claims or IdP groups need no user_roles row.
+		{[]string{"requester", "manager"}, `{"body_md":"x"}`, true},
+	} {
+		stamped = nil
+		a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+		a.R.POST("/tickets/:id/comments", func(c *gin.Context) { c.Set("user", authpkg.AuthUser{ID: "u1", Roles: tc.roles}) }, Add(a))
+		req := httptest.NewRequest(http.MethodPost, "/tickets/t1/comments", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(httptest.NewRecorder(), req)
+		if got := len(stamped) > 0; got != tc.want {
+			t.Fatalf("%v %s: first response recorded = %v", tc.roles, tc.body, got)
+		}
+		if tc.want && (len(stamped) != 1 || stamped[0] != "t1") {
+			t.Fatalf("unexpected args: %v", stamped)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package comments
+
+import (
+	"context"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// NotifyCCs records a ticket_comment email in the outbox for every address
+// CC'd on the ticket. The ticket number and recipient are filled in by the
+// database; tickets without CCs produce no rows.
+func NotifyCCs(ctx context.Context, db app.DB, ticketID, commentID, body string) error {
+	job, err := jobs.Encode("", jobs.TypeSendEmail, jobs.Email{
+		Template: "ticket_comment",
+		TicketID: &ticketID,
+		Data:     map[string]any{"Body": body},
+	})
+	if err != nil {
+		return err
+	}
+	const q = `insert into outbox (kind, dedup_key, payload)
+select $1, $2 || cc.email,
+       jsonb_set(jsonb_set($3::jsonb, '{data,to}', to_jsonb(cc.email)), '{data,data,Number}', to_jsonb(t.number))
+from ticket_ccs cc join tickets t on t.id = cc.ticket_id
+where cc.ticket_id = $4
+on conflict (dedup_key) do nothing`
+	_, err = db.Exec(ctx, q, outbox.KindJob, "ticket_comment_email:"+commentID+":", string(job), ticketID)
+	return err
+}
@@ -0,0 +1,116 @@
+package comments
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/internal/translate"
+)
+
+// Translation is a comment translated into Lang. Cached is true when it was
+// translated before and served from comment_translations.
+type Translation struct {
+	CommentID  string `json:"comment_id"`
+	Lang       string `json:"lang"`
+	SourceLang string `json:"source_lang,omitempty"`
+	BodyMD     string `json:"body_md"`
+	Provider   string `json:"provider"`
+	Cached     bool   `json:"cached"`
+}
+
+// TranslationConfig returns the translation provider settings from the API
+// config.
+func TranslationConfig(a *app.App) translate.Config {
+	return translate.Config{
+		Provider: a.Cfg.TranslationProvider,
+		BaseURL:  a.Cfg.TranslationURL,
+		APIKey:   a.Cfg.TranslationAPIKey,
+	}
+}
+
+// Translate translates a public comment into the language ?to= names with
+// the configured provider. Each comment is translated into a language once;
+// later requests are served from comment_translations. Internal notes are
+// never sent to a provider. Responds 501 while no provider is configured.
+func Translate(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := TranslationConfig(a)
+		if !cfg.Enabled() {
+			app.AbortError(c, http.StatusNotImplemented, "translation_disabled", "comment translation is not enabled", nil)
+			return
+		}
+		lang, ok := translate.Lang(c.Query("to"))
+		if !ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_language", "to must be a language code such as de or pt-BR", map[string]string{"to": "invalid language"})
+			return
+		}
+		if _, err := uuid.Parse(c.Param("cid")); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "comment not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		out := Translation{CommentID: c.Param("cid"), Lang: lang}
+		var body string
+		var internal bool
+		err := a.DB.QueryRow(ctx, `select body_md, is_internal from ticket_comments where id = $1 and ticket_id = $2`,
+			out.CommentID, c.Param("id")).Scan(&body, &internal)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "comment not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load comment", nil)
+			return
+		}
+		if internal {
+			app.AbortError(c, http.StatusBadRequest, "internal_comment", "internal notes are not sent for translation", nil)
+			return
+		}
+
+		err = a.DB.QueryRow(ctx, `select body_md, coalesce(source_lang, ''), provider from comment_translations where comment_id = $1 and lang = $2`,
+			out.CommentID, lang).Scan(&out.BodyMD, &out.SourceLang, &out.Provider)
+		if err == nil {
+			metrics.CommentTranslationsTotal.WithLabelValues("cached").Inc()
+			out.Cached = true
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load translation", nil)
+			return
+		}
+
+		provider, err := translate.New(cfg)
+		if err != nil {
+			log.Error().Err(err).Msg("comment translation misconfigured")
+			app.AbortError(c, http.StatusServiceUnavailable, "translation_misconfigured", "comment translation is misconfigured", nil)
+			return
+		}
+		res, err := provider.Translate(ctx, body, lang)
+		if err != nil {
+			metrics.CommentTranslationsTotal.WithLabelValues("error").Inc()
+			log.Warn().Err(err).Str("comment", out.CommentID).Str("lang", lang).Msg("comment translation failed")
+			app.AbortError(c, http.StatusBadGateway, "translation_failed", "the translation provider failed", nil)
+			return
+		}
+		metrics.CommentTranslationsTotal.WithLabelValues("ok").Inc()
+		out.BodyMD, out.SourceLang, out.Provider = res.Text, res.From, cfg.Provider
+		// A lost cache write only costs another call to the provider.
+		if _, err := a.DB.Exec(ctx, `insert into comment_translations (comment_id, lang, source_lang, body_md, provider, created_by)
+            values ($1, $2, nullif($3, ''), $4, $5, $6)
+            on conflict (comment_id, lang) do update set source_lang = excluded.source_lang, body_md = excluded.body_md,
+                provider = excluded.provider, created_by = excluded.created_by, created_at = now()`,
+			out.CommentID, lang, out.SourceLang, out.BodyMD, out.Provider, authpkg.Actor(c).DBID()); err != nil {
+			log.Error().Err(err).Str("comment", out.CommentID).Msg("cache comment translation")
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
@@ -0,0 +1,103 @@
+package comments
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestTranslate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const public, internal = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222"
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"translatedText":"Drucker ist kaputt","detectedLanguage":{"language":"en"}}`))
+	}))
+	defer srv.Close()
+
+	cache := map[string][]any{}
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "from ticket_comments") {
+					switch args[0] {
+					case public:
+						*dest[0].(*string), *dest[1].(*bool) = "Printer is broken", false
+					case internal:
+						*dest[0].(*string), *dest[1].(*bool) = "VIP, escalate", true
+					default:
+						return pgx.ErrNoRows
+					}
+					return nil
+				}
+				hit, ok := cache[args[0].(string)+"/"+args[1].(string)]
+				if !ok {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = hit[3].(string), hit[2].(string), hit[4].(string)
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			cache[args[0].(string)+"/"+args[1].(string)] = args
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TranslationProvider: "libretranslate", TranslationURL: srv.URL}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/comments/:cid/translate", Translate(a))
+	post := func(cid, to string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/t1/comments/"+cid+"/translate?to="+to, nil))
+		return rr
+	}
+
+	for _, tc := range []struct {
+		cid, to string
+		code    int
+	}{
+		{public, "german", http.StatusBadRequest},
+		{internal, "de", http.StatusBadRequest},
+		{"33333333-3333-3333-3333-333333333333", "de", http.StatusNotFound},
+		{"c1", "de", http.StatusNotFound},
+	} {
+		if rr := post(tc.cid, tc.to); rr.Code != tc.code {
+			t.Fatalf("%s?to=%s: expected %d, got %d %s", tc.cid, tc.to, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+	if calls != 0 {
+		t.Fatalf("refused requests reached the provider %d times", calls)
+	}
+
+	for i, cached := range []bool{false, true} {
+		rr := post(public, "DE")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+		}
+		var got Translation
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		want := Translation{CommentID: public, Lang: "de", SourceLang: "en", BodyMD: "Drucker ist kaputt", Provider: "libretranslate", Cached: cached}
+		if got != want || calls != 1 {
+			t.Fatalf("request %d: got %+v after %d calls", i, got, calls)
+		}
+	}
+
+	off := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	off.R.POST("/tickets/:id/comments/:cid/translate", Translate(off))
+	rr := httptest.NewRecorder()
+	off.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/t1/comments/"+public+"/translate?to=de", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a provider, got %d", rr.Code)
+	}
+}
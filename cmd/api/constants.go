@@ -1,19 +1,7 @@
 package main
 
+import ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+
 // ValidTicketStatuses contains all valid ticket status values.
 // This is used to validate status changes and maintain consistency across the application.
-var ValidTicketStatuses = []string{
-	"New",
-	"Open",
-	"Assigned",
-	"Accepted",
-	"In Progress",
-	"Scheduled",
-	"Pending",
-	"Pending - Awaiting Info",
-	"Pending - Awaiting Callback",
-	"Pending - Awaiting Parts",
-	"Pending - Awaiting Approval",
-	"Resolved",
-	"Closed",
-}
+var ValidTicketStatuses = ticketspkg.Statuses
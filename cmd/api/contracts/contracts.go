@@ -0,0 +1,355 @@
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/contracts"
+)
+
+// Organization groups the requesters whose email domain it lists.
+type Organization struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Domains []string `json:"domains"`
+	// AccountManagerID is warned when the organization's contract expires.
+	AccountManagerID *string   `json:"account_manager_id"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const orgCols = `id::text, name, domains, account_manager_id::text, created_at`
+
+func scanOrg(row pgx.Row) (Organization, error) {
+	var o Organization
+	err := row.Scan(&o.ID, &o.Name, &o.Domains, &o.AccountManagerID, &o.CreatedAt)
+	return o, err
+}
+
+// Contract is a support contract with what is left of it.
+type Contract struct {
+	ID               string    `json:"id"`
+	OrganizationID   string    `json:"organization_id"`
+	Name             string    `json:"name"`
+	IncludedMinutes  *int      `json:"included_minutes"`
+	IncludedTickets  *int      `json:"included_tickets"`
+	StartsOn         string    `json:"starts_on"`
+	EndsOn           string    `json:"ends_on"`
+	RemainingMinutes *int      `json:"remaining_minutes"`
+	RemainingTickets *int      `json:"remaining_tickets"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+const contractCols = `c.id::text, c.organization_id::text, c.name, c.included_minutes, c.included_tickets,
+        c.starts_on, c.ends_on, ` + contracts.UsageCols + `, c.created_at`
+
+func scanContract(row pgx.Row) (Contract, error) {
+	var k Contract
+	var starts, ends time.Time
+	err := row.Scan(&k.ID, &k.OrganizationID, &k.Name, &k.IncludedMinutes, &k.IncludedTickets, &starts, &ends,
+		&k.RemainingMinutes, &k.RemainingTickets, &k.CreatedAt)
+	if err != nil {
+		return k, err
+	}
+	k.StartsOn, k.EndsOn = starts.Format(time.DateOnly), ends.Format(time.DateOnly)
+	today := time.Now().UTC().Format(time.DateOnly)
+	switch {
+	case k.StartsOn > today:
+		k.Status = "scheduled"
+	default:
+		k.Status = contracts.Classify(k.EndsOn < today, k.RemainingMinutes, k.RemainingTickets)
+	}
+	return k, nil
+}
+
+// normalizeDomains lowercases domains and drops a leading "@". ok is false
+// when one is not a domain.
+func normalizeDomains(in []string) ([]string, bool) {
+	out := make([]string, 0, len(in))
+	for _, d := range in {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if d == "" || !strings.Contains(d, ".") || strings.ContainsAny(d, "@ \t,;<>") {
+			return nil, false
+		}
+		out = append(out, d)
+	}
+	return out, true
+}
+
+// ListOrganizations returns every organization by name.
+func ListOrganizations(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+orgCols+` from organizations order by name`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list organizations", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Organization{}
+		for rows.Next() {
+			o, err := scanOrg(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list organizations", nil)
+				return
+			}
+			out = append(out, o)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// orgError maps constraint violations on organizations to responses and
+// reports whether it wrote one.
+func orgError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	switch {
+	case errors.Is(err, errDomainTaken):
+		apppkg.AbortError(c, http.StatusConflict, "domain_taken", err.Error(), nil)
+	case errors.As(err, &pge) && pge.Code == "23505":
+		apppkg.AbortError(c, http.StatusConflict, "name_taken", "an organization with this name exists", nil)
+	case errors.As(err, &pge) && pge.Code == "23503":
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"account_manager_id": "not_found"})
+	default:
+		return false
+	}
+	return true
+}
+
+// errDomainTaken is returned when a domain already belongs to another
+// organization, which would make its requesters' organization ambiguous.
+var errDomainTaken = errors.New("a domain already belongs to another organization")
+
+func checkDomains(c *gin.Context, tx apppkg.DB, id string, domains []string) error {
+	var taken bool
+	if err := tx.QueryRow(c.Request.Context(), `select exists(select 1 from organizations where domains && $1::text[] and id::text <> $2)`,
+		domains, id).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return errDomainTaken
+	}
+	return nil
+}
+
+// CreateOrganization adds an organization. Requires admin.
+func CreateOrganization(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name             string   `json:"name"`
+			Domains          []string `json:"domains"`
+			AccountManagerID *string  `json:"account_manager_id"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		in.Name = strings.TrimSpace(in.Name)
+		domains, ok := normalizeDomains(in.Domains)
+		switch {
+		case in.Name == "":
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"name": "required"})
+			return
+		case !ok:
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"domains": "must be domains like example.com"})
+			return
+		}
+		ctx := c.Request.Context()
+		var o Organization
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := checkDomains(c, tx, "", domains); err != nil {
+				return err
+			}
+			var err error
+			o, err = scanOrg(tx.QueryRow(ctx, `insert into organizations (name, domains, account_manager_id) values ($1, $2, $3) returning `+orgCols,
+				in.Name, domains, in.AccountManagerID))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "organization", o.ID, "organization_created", map[string]any{
+				"name": o.Name, "domains": o.Domains, "account_manager_id": o.AccountManagerID})
+		})
+		if orgError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create organization", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, o)
+	}
+}
+
+// UpdateOrganization changes an organization's name, domains or account
+// manager; only the fields present change and a null account_manager_id
+// clears it. Requires admin.
+func UpdateOrganization(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		var name *string
+		if raw, ok := in["name"]; ok && (json.Unmarshal(raw, &name) != nil || name == nil || strings.TrimSpace(*name) == "") {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"name": "must be a non-empty string"})
+			return
+		}
+		var domains []string
+		raw, setDomains := in["domains"]
+		if setDomains {
+			var ok bool
+			if json.Unmarshal(raw, &domains) != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"domains": "must be a list"})
+				return
+			}
+			if domains, ok = normalizeDomains(domains); !ok {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"domains": "must be domains like example.com"})
+				return
+			}
+		}
+		var manager *string
+		raw, setManager := in["account_manager_id"]
+		if setManager && json.Unmarshal(raw, &manager) != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"account_manager_id": "must be a user id or null"})
+			return
+		}
+		ctx := c.Request.Context()
+		var o Organization
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if setDomains {
+				if err := checkDomains(c, tx, c.Param("id"), domains); err != nil {
+					return err
+				}
+			}
+			var err error
+			o, err = scanOrg(tx.QueryRow(ctx, `update organizations set name = coalesce(btrim($2), name),
+                domains = case when $3 then $4::text[] else domains end,
+                account_manager_id = case when $5 then $6::uuid else account_manager_id end
+                where id::text = $1 returning `+orgCols, c.Param("id"), name, setDomains, domains, setManager, manager))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "organization", o.ID, "organization_updated", map[string]any{
+				"name": o.Name, "domains": o.Domains, "account_manager_id": o.AccountManagerID})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "organization not found", nil)
+			return
+		}
+		if orgError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update organization", nil)
+			return
+		}
+		c.JSON(http.StatusOK, o)
+	}
+}
+
+// ListContracts returns an organization's contracts, latest first, with
+// what is left of each.
+func ListContracts(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+contractCols+` from support_contracts c
+            where c.organization_id::text = $1 order by c.ends_on desc, c.created_at desc`, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list contracts", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Contract{}
+		for rows.Next() {
+			k, err := scanContract(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list contracts", nil)
+				return
+			}
+			out = append(out, k)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateContract adds a contract to an organization. It must include
+// minutes, tickets or both. Requires admin.
+func CreateContract(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name            string `json:"name"`
+			IncludedMinutes *int   `json:"included_minutes"`
+			IncludedTickets *int   `json:"included_tickets"`
+			StartsOn        string `json:"starts_on"`
+			EndsOn          string `json:"ends_on"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		errs := map[string]string{}
+		if in.Name = strings.TrimSpace(in.Name); in.Name == "" {
+			errs["name"] = "required"
+		}
+		if in.IncludedMinutes == nil && in.IncludedTickets == nil {
+			errs["included_minutes"] = "set included_minutes, included_tickets or both"
+		}
+		if in.IncludedMinutes != nil && *in.IncludedMinutes < 1 {
+			errs["included_minutes"] = "must be positive"
+		}
+		if in.IncludedTickets != nil && *in.IncludedTickets < 1 {
+			errs["included_tickets"] = "must be positive"
+		}
+		starts, err1 := time.Parse(time.DateOnly, in.StartsOn)
+		ends, err2 := time.Parse(time.DateOnly, in.EndsOn)
+		switch {
+		case err1 != nil:
+			errs["starts_on"] = "must be a date (YYYY-MM-DD)"
+		case err2 != nil:
+			errs["ends_on"] = "must be a date (YYYY-MM-DD)"
+		case ends.Before(starts):
+			errs["ends_on"] = "must not be before starts_on"
+		}
+		if len(errs) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var k Contract
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			k, err = scanContract(tx.QueryRow(ctx, `with c as (
+                    insert into support_contracts (organization_id, name, included_minutes, included_tickets, starts_on, ends_on, created_by)
+                    values ($1, $2, $3, $4, $5, $6, $7) returning *)
+                select `+contractCols+` from c`, c.Param("id"), in.Name, in.IncludedMinutes, in.IncludedTickets, in.StartsOn, in.EndsOn, act.DBID()))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "organization", k.OrganizationID, "contract_created", map[string]any{
+				"contract_id": k.ID, "name": k.Name, "included_minutes": k.IncludedMinutes, "included_tickets": k.IncludedTickets,
+				"starts_on": k.StartsOn, "ends_on": k.EndsOn})
+		})
+		var pge *pgconn.PgError
+		if errors.As(err, &pge) && (pge.Code == "23503" || pge.Code == "22P02") {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "organization not found", nil)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("create contract")
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create contract", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, k)
+	}
+}
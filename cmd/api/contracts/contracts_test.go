@@ -0,0 +1,131 @@
+package contracts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCreateOrganization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	taken := false
+	var inserted []string
+	var audited []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "domains && $1") {
+					*dest[0].(*bool) = taken
+					return nil
+				}
+				inserted = args[1].([]string)
+				*dest[0].(*string) = "o1"
+				*dest[1].(*string) = args[0].(string)
+				*dest[2].(*[]string) = inserted
+				*dest[4].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "audit_events") {
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/organizations", CreateOrganization(a))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/organizations", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := post(`{"name":"Acme","domains":["localhost"]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad domain, got %d", rr.Code)
+	}
+	rr := post(`{"name":" Acme ","domains":["@Acme.com","acme.co.uk"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var o Organization
+	if err := json.Unmarshal(rr.Body.Bytes(), &o); err != nil {
+		t.Fatal(err)
+	}
+	if o.Name != "Acme" || strings.Join(inserted, ",") != "acme.com,acme.co.uk" {
+		t.Fatalf("expected normalized name and domains, got %+v %v", o, inserted)
+	}
+	if strings.Join(audited, ",") != "organization_created" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+	taken = true
+	if rr := post(`{"name":"Other","domains":["acme.com"]}`); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "domain_taken") {
+		t.Fatalf("expected 409 domain_taken, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCreateContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] == "missing" {
+					return &pgconn.PgError{Code: "23503"}
+				}
+				*dest[0].(*string) = "k1"
+				*dest[1].(*string) = args[0].(string)
+				*dest[2].(*string) = args[1].(string)
+				*dest[3].(**int) = args[2].(*int)
+				*dest[5].(*time.Time), _ = time.Parse(time.DateOnly, args[4].(string))
+				*dest[6].(*time.Time), _ = time.Parse(time.DateOnly, args[5].(string))
+				left := *args[2].(*int) - 30
+				*dest[7].(**int) = &left
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/organizations/:id/contracts", CreateContract(a))
+	post := func(org, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/organizations/"+org+"/contracts", strings.NewReader(body)))
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"name":"Gold","starts_on":"2026-01-01","ends_on":"2026-12-31"}`,
+		`{"name":"Gold","included_minutes":600,"starts_on":"2026-12-31","ends_on":"2026-01-01"}`,
+		`{"name":"Gold","included_tickets":0,"starts_on":"2026-01-01","ends_on":"2026-12-31"}`,
+	} {
+		if rr := post("o1", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+	if rr := post("missing", `{"name":"Gold","included_minutes":600,"starts_on":"2026-01-01","ends_on":"2026-12-31"}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown organization, got %d", rr.Code)
+	}
+	starts := time.Now().UTC().AddDate(0, -1, 0).Format(time.DateOnly)
+	ends := time.Now().UTC().AddDate(0, 11, 0).Format(time.DateOnly)
+	rr := post("o1", `{"name":"Gold","included_minutes":600,"starts_on":"`+starts+`","ends_on":"`+ends+`"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var k Contract
+	if err := json.Unmarshal(rr.Body.Bytes(), &k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Status != "covered" || k.RemainingMinutes == nil || *k.RemainingMinutes != 570 || k.EndsOn != ends {
+		t.Fatalf("unexpected contract %+v", k)
+	}
+}
@@ -0,0 +1,180 @@
+package csat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// Branding customises the public CSAT pages.
+type Branding struct {
+	// LogoURL is an https URL or a path on this host.
+	LogoURL         string `json:"logo_url,omitempty"`
+	PrimaryColor    string `json:"primary_color,omitempty"`
+	BackgroundColor string `json:"background_color,omitempty"`
+	// ThankYou replaces the built-in thank-you text, by locale; the "*" entry
+	// applies to locales without their own.
+	ThankYou map[string]string `json:"thank_you,omitempty"`
+}
+
+const maxThankYouLen = 500
+
+var colorRe = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// brandingTTL bounds how stale an instance's copy of the settings can get
+// after another instance saved them.
+const brandingTTL = 30 * time.Second
+
+var brandingCache struct {
+	mu sync.Mutex
+	at time.Time
+	b  Branding
+}
+
+// cachedBranding returns the branding, reloading it at most every
+// brandingTTL. On a load error the last good copy is kept. Test apps use
+// whatever setCachedBranding installed.
+func cachedBranding(ctx context.Context, a *app.App) Branding {
+	brandingCache.mu.Lock()
+	defer brandingCache.mu.Unlock()
+	if a.DB == nil || a.Cfg.Env == "test" || time.Since(brandingCache.at) < brandingTTL {
+		return brandingCache.b
+	}
+	b, err := loadBranding(ctx, a.DB)
+	if err != nil {
+		log.Error().Err(err).Msg("load csat branding")
+	} else {
+		brandingCache.b = b
+	}
+	brandingCache.at = time.Now()
+	return brandingCache.b
+}
+
+func setCachedBranding(b Branding) {
+	brandingCache.mu.Lock()
+	brandingCache.b = b
+	brandingCache.at = time.Now()
+	brandingCache.mu.Unlock()
+}
+
+func loadBranding(ctx context.Context, db app.DB) (Branding, error) {
+	var b Branding
+	var raw []byte
+	err := db.QueryRow(ctx, `select csat from settings where id=1`).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// validateBranding normalises b in place and returns the offending field and
+// problem, if any.
+func validateBranding(b *Branding) (string, error) {
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	if b.LogoURL != "" {
+		if _, err := logoSource(b.LogoURL); err != nil {
+			return "logo_url", err
+		}
+	}
+	for field, v := range map[string]*string{"primary_color": &b.PrimaryColor, "background_color": &b.BackgroundColor} {
+		*v = strings.TrimSpace(*v)
+		if *v != "" && !colorRe.MatchString(*v) {
+			return field, fmt.Errorf("must be a hex color like #1e40af")
+		}
+	}
+	out := make(map[string]string, len(b.ThankYou))
+	for loc, text := range b.ThankYou {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if loc != "*" {
+			tag, ok := supportedLocale(loc)
+			if !ok {
+				return "thank_you", fmt.Errorf("unsupported locale %q", loc)
+			}
+			loc = tag
+		}
+		if utf8.RuneCountInString(text) > maxThankYouLen {
+			return "thank_you", fmt.Errorf("%s: at most %d characters", loc, maxThankYouLen)
+		}
+		out[loc] = text
+	}
+	b.ThankYou = out
+	return "", nil
+}
+
+// logoSource returns the CSP img-src source that allows the logo.
+func logoSource(raw string) (string, error) {
+	if strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//") {
+		return "'self'", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return "", fmt.Errorf("must be an https URL or a path on this host")
+	}
+	return "https://" + u.Host, nil
+}
+
+// GetBranding returns the CSAT page branding.
+func GetBranding(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, cachedBranding(c.Request.Context(), a))
+			return
+		}
+		b, err := loadBranding(c.Request.Context(), a.DB)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, b)
+	}
+}
+
+// SaveBranding replaces the CSAT page branding. Changes apply immediately on
+// this instance and within 30 seconds on the others.
+func SaveBranding(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in Branding
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		if field, err := validateBranding(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_branding", err.Error(), map[string]string{field: err.Error()})
+			return
+		}
+		if a.DB != nil {
+			b, _ := json.Marshal(in)
+			if _, err := a.DB.Exec(c.Request.Context(), `update settings set csat=$1::jsonb where id=1`, string(b)); err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+		}
+		setCachedBranding(in)
+		c.JSON(http.StatusOK, in)
+	}
+}
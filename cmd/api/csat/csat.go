@@ -0,0 +1,187 @@
+// Package csat serves the public customer satisfaction survey linked from
+// ticket resolution emails.
+package csat
+
+import (
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var pageTmpl = template.Must(template.ParseFS(templatesFS, "templates/page.html"))
+
+const (
+	defaultPrimary    = "#1e40af"
+	defaultBackground = "#f3f4f6"
+)
+
+// page is the data of templates/page.html.
+type page struct {
+	Lang       string
+	Nonce      string
+	Brand      Branding
+	Primary    string
+	Background string
+	T          messages
+	Prompt     string
+	Form       bool
+	Message    string
+}
+
+// survey is the ticket a CSAT token belongs to.
+type survey struct {
+	number   string
+	locale   string
+	ticketID string
+}
+
+// lookup finds the unanswered survey for token. The requester's locale
+// comes from their requester record, else their user profile.
+func lookup(c *gin.Context, a *app.App, token string) (survey, error) {
+	var s survey
+	err := a.DB.QueryRow(c.Request.Context(), `select coalesce(t.number, ''), coalesce(nullif(rq.locale, ''), u.locale, ''), t.id::text
+        from tickets t
+        left join requesters rq on rq.id=t.requester_id
+        left join users u on lower(u.email)=lower(rq.email)
+        where t.csat_token=$1 and t.csat_score is null`, token).Scan(&s.number, &s.locale, &s.ticketID)
+	return s, err
+}
+
+// locale is the survey's page language.
+func locale(c *gin.Context, s survey) string {
+	return pickLocale(s.locale, c.GetHeader("Accept-Language"))
+}
+
+// render writes the branded page in p.Lang. The page only needs its own
+// inline styles, the form post and the logo, so the API's default-src
+// 'none' policy is relaxed just that far. The token is in the URL, so no
+// referrer is sent when the logo is fetched from another host.
+func render(c *gin.Context, a *app.App, status int, p page) {
+	p.T = catalog[p.Lang]
+	p.Brand = cachedBranding(c.Request.Context(), a)
+	p.Primary, p.Background = defaultPrimary, defaultBackground
+	if p.Brand.PrimaryColor != "" {
+		p.Primary = p.Brand.PrimaryColor
+	}
+	if p.Brand.BackgroundColor != "" {
+		p.Background = p.Brand.BackgroundColor
+	}
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	p.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	csp := "default-src 'none'; style-src 'nonce-" + p.Nonce + "'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+	if p.Brand.LogoURL != "" {
+		if src, err := logoSource(p.Brand.LogoURL); err == nil {
+			csp += "; img-src " + src
+		} else {
+			p.Brand.LogoURL = ""
+		}
+	}
+	c.Header("Content-Security-Policy", csp)
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := pageTmpl.Execute(c.Writer, p); err != nil {
+		log.Error().Err(err).Msg("render csat page")
+	}
+}
+
+// wantsHTML reports whether the caller is a browser submitting the form
+// rather than an API client.
+func wantsHTML(c *gin.Context) bool {
+	return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// Form renders the survey for an unanswered token.
+func Form(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s, err := lookup(c, a, c.Param("token"))
+		lang := locale(c, s)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			render(c, a, http.StatusNotFound, page{Lang: lang, Message: catalog[lang].Invalid})
+		case err != nil:
+			log.Error().Err(err).Msg("load csat survey")
+			render(c, a, http.StatusInternalServerError, page{Lang: lang, Message: catalog[lang].Error})
+		default:
+			render(c, a, http.StatusOK, page{Lang: lang, Form: true, Prompt: fmt.Sprintf(catalog[lang].Prompt, s.number)})
+		}
+	}
+}
+
+// Submit records a good or bad score. A bad score may open a follow-up
+// ticket in the same transaction; see OpenFollowUp. Browsers posting the
+// form get the thank-you page; other clients get JSON.
+func Submit(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Param("token")
+		score := c.PostForm("score")
+		if score != "good" && score != "bad" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid score"})
+			return
+		}
+		ctx := c.Request.Context()
+		s, err := lookup(c, a, token)
+		if err == nil {
+			err = app.InTx(ctx, a.DB, func(tx app.DB) error {
+				tag, err := tx.Exec(ctx, `update tickets set csat_score=$1, csat_token=null where csat_token=$2 and csat_score is null`, score, token)
+				if err != nil {
+					return err
+				}
+				if tag.RowsAffected() == 0 {
+					return pgx.ErrNoRows
+				}
+				if score != "bad" {
+					return nil
+				}
+				_, _, err = OpenFollowUp(ctx, tx, s.ticketID)
+				return err
+			})
+		}
+		lang := locale(c, s)
+		html := wantsHTML(c)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows) && html:
+			render(c, a, http.StatusNotFound, page{Lang: lang, Message: catalog[lang].Invalid})
+		case errors.Is(err, pgx.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "invalid token"})
+		case err != nil:
+			log.Error().Err(err).Msg("record csat score")
+			if html {
+				render(c, a, http.StatusInternalServerError, page{Lang: lang, Message: catalog[lang].Error})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record score"})
+		case html:
+			render(c, a, http.StatusOK, page{Lang: lang, Message: thankYou(cachedBranding(ctx, a), lang)})
+		default:
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		}
+	}
+}
+
+// thankYou returns the configured thank-you text for lang, else the
+// built-in one.
+func thankYou(b Branding, lang string) string {
+	if t := b.ThankYou[lang]; t != "" {
+		return t
+	}
+	if t := b.ThankYou["*"]; t != "" {
+		return t
+	}
+	return catalog[lang].ThankYou
+}
@@ -0,0 +1,168 @@
+package csat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestSurveyPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	locales := map[string]string{"tok-es": "es-MX", "tok-none": ""}
+	var updated []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				loc, ok := locales[args[0].(string)]
+				if !ok {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string) = "TKT-7"
+				*dest[1].(*string) = loc
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			updated = args
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/csat/:token", Form(a))
+	a.R.POST("/csat/:token", Submit(a))
+	setCachedBranding(Branding{LogoURL: "https://cdn.example.com/logo.png", PrimaryColor: "#ff0000", ThankYou: map[string]string{"*": "Cheers from Acme"}})
+	t.Cleanup(func() { setCachedBranding(Branding{}) })
+	do := func(method, token, accept, lang string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/csat/"+token, strings.NewReader("score=good"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", accept)
+		req.Header.Set("Accept-Language", lang)
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The requester's locale wins over the browser's.
+	rr := do(http.MethodGet, "tok-es", "text/html", "de")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, `<html lang="es">`) || !strings.Contains(body, "ticket TKT-7") || !strings.Contains(body, "#ff0000") {
+		t.Fatalf("unexpected form %d %s", rr.Code, body)
+	}
+	csp := rr.Header().Get("Content-Security-Policy")
+	m := regexp.MustCompile(`style-src 'nonce-([^']+)'`).FindStringSubmatch(csp)
+	if m == nil || !strings.Contains(body, `nonce="`+m[1]+`"`) || !strings.Contains(csp, "img-src https://cdn.example.com") || !strings.Contains(csp, "form-action 'self'") {
+		t.Fatalf("unexpected CSP %q", csp)
+	}
+	if rr.Header().Get("Referrer-Policy") != "no-referrer" {
+		t.Fatalf("token could leak through the referrer")
+	}
+
+	// Without a stored locale the browser's language is used.
+	if rr := do(http.MethodGet, "tok-none", "text/html", "fr-CA,en;q=0.5"); !strings.Contains(rr.Body.String(), `<html lang="fr">`) {
+		t.Fatalf("expected a French page, got %s", rr.Body.String())
+	}
+	if rr := do(http.MethodGet, "used", "text/html", ""); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "already been used") {
+		t.Fatalf("expected the invalid link page, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = do(http.MethodPost, "tok-es", "text/html", "")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Cheers from Acme") || len(updated) != 2 || updated[0] != "good" {
+		t.Fatalf("unexpected thank-you page %d %s %v", rr.Code, rr.Body.String(), updated)
+	}
+	if rr := do(http.MethodPost, "tok-none", "", ""); rr.Code != http.StatusOK || rr.Body.String() != `{"ok":true}` {
+		t.Fatalf("API clients should get JSON, got %s", rr.Body.String())
+	}
+}
+
+func TestSaveBranding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, nil, nil, nil, nil)
+	a.R.PUT("/settings/csat", SaveBranding(a))
+	t.Cleanup(func() { setCachedBranding(Branding{}) })
+	for body, want := range map[string]int{
+		`{"primary_color":"red"}`:                                         http.StatusBadRequest,
+		`{"logo_url":"http://cdn.example.com/logo.png"}`:                  http.StatusBadRequest,
+		`{"logo_url":"javascript:alert(1)"}`:                              http.StatusBadRequest,
+		`{"thank_you":{"xx":"Thanks"}}`:                                   http.StatusBadRequest,
+		`{"logo_url":"/static/logo.png","thank_you":{"es-ES":"Gracias"}}`: http.StatusOK,
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/settings/csat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", body, want, rr.Code)
+		}
+	}
+	if b := cachedBranding(context.Background(), a); b.ThankYou["es"] != "Gracias" {
+		t.Fatalf("expected the thank-you text under its base locale, got %+v", b)
+	}
+}
+
+func TestSubmitBadScoreOpensFollowUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var followUps []any
+	var execs []string
+	var notified []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "csat_followup") {
+				followUps = append(followUps, args[0])
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					lead := "lead-1"
+					*dest[0].(*string) = "f1"
+					*dest[1].(*string) = "HD-9"
+					*dest[2].(**string) = &lead
+					*dest[3].(*string) = "CSAT follow-up: HD-7 Printer"
+					*dest[4].(*string) = "HD-7"
+					return nil
+				}}
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "HD-7"
+				*dest[2].(*string) = "t1"
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			notified = args
+			return &testutil.MockRows{}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			execs = append(execs, sql)
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/csat/:token", Submit(a))
+	submit := func(score string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/csat/tok", strings.NewReader("score="+score))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		a.R.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := submit("good"); code != http.StatusOK || len(followUps) != 0 {
+		t.Fatalf("a good score should not open a follow-up, got %d %v", code, followUps)
+	}
+	if code := submit("bad"); code != http.StatusOK || len(followUps) != 1 || followUps[0] != "t1" {
+		t.Fatalf("expected a follow-up for t1, got %d %v", code, followUps)
+	}
+	joined := strings.Join(execs, "\n")
+	if !strings.Contains(joined, "ticket_status_history") || !strings.Contains(joined, "audit_events") {
+		t.Fatalf("expected status history and audit writes, got %v", execs)
+	}
+	if users, _ := notified[0].([]string); len(users) != 1 || users[0] != "lead-1" || notified[1] != "ticket_assigned" {
+		t.Fatalf("expected the lead to be notified, got %v", notified)
+	}
+}
@@ -0,0 +1,74 @@
+package csat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+)
+
+// followUpActor attributes follow-up tickets to the survey.
+var followUpActor = actor.System("csat")
+
+// FollowUp is a ticket opened for a bad score.
+type FollowUp struct {
+	ID         string  `json:"id"`
+	Number     string  `json:"number"`
+	AssigneeID *string `json:"assignee_id,omitempty"`
+}
+
+// OpenFollowUp opens a follow-up ticket for a bad score on ticketID when
+// the ticket's queue has csat_followup set. It goes to the same requester,
+// queue and team at the same priority, linked through followup_of, and is
+// assigned to the team's lead; without a lead it waits in the team pool.
+// ok is false when the queue does not ask for follow-ups or the ticket
+// already has one.
+func OpenFollowUp(ctx context.Context, db app.DB, ticketID string) (f FollowUp, ok bool, err error) {
+	var rated, title string
+	err = db.QueryRow(ctx, `with src as (
+            select t.id, t.number, t.title, t.requester_id, t.team_id, t.queue_id, t.priority, tm.lead_id
+            from tickets t
+            join queues q on q.id = t.queue_id and q.csat_followup
+            left join teams tm on tm.id = t.team_id
+            where t.id = $1 and not exists (select 1 from tickets f where f.followup_of = t.id)
+        )
+        insert into tickets (number, title, description, requester_id, assignee_id, team_id, queue_id, priority, status, source, followup_of)
+        select 'HD-'||nextval('ticket_seq'), 'CSAT follow-up: '||src.number||' '||src.title,
+            format('The requester rated %s as bad. Reach out to them, find out what went wrong and record the outcome here.', src.number),
+            src.requester_id, src.lead_id, src.team_id, src.queue_id, src.priority, 'New', 'csat', src.id
+        from src
+        returning id::text, number, assignee_id::text, title, (select number from src)`, ticketID).Scan(&f.ID, &f.Number, &f.AssigneeID, &title, &rated)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return f, false, nil
+	}
+	if err != nil {
+		return f, false, err
+	}
+	if _, err := db.Exec(ctx, `insert into ticket_status_history (ticket_id, to_status) values ($1, 'New')`, f.ID); err != nil {
+		return f, true, err
+	}
+	if err := audit.RecordDiff(ctx, db, followUpActor, "ticket", ticketID, "csat_followup_created", map[string]any{
+		"followup_id": f.ID, "followup_number": f.Number, "assignee_id": f.AssigneeID,
+	}); err != nil {
+		return f, true, err
+	}
+	eventspkg.Emit(ctx, db, followUpActor, f.ID, "ticket_created", map[string]any{"id": f.ID})
+	if f.AssigneeID != nil {
+		if _, err := notify.Dispatch(ctx, db, []string{*f.AssigneeID}, notify.Message{
+			Event:    notify.EventTicketAssigned,
+			Title:    fmt.Sprintf("Assigned to you: %s", f.Number),
+			Body:     title,
+			TicketID: f.ID,
+		}); err != nil {
+			return f, true, err
+		}
+	}
+	return f, true, nil
+}
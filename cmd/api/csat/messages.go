@@ -0,0 +1,86 @@
+package csat
+
+import "golang.org/x/text/language"
+
+// messages holds the text of the CSAT pages in one language.
+type messages struct {
+	Title    string
+	Prompt   string // formatted with the ticket number
+	Good     string
+	Bad      string
+	ThankYou string
+	Invalid  string
+	Error    string
+}
+
+// locales lists the translated languages; the first is the fallback.
+var locales = []language.Tag{language.English, language.Spanish, language.French, language.German, language.Portuguese}
+
+var matcher = language.NewMatcher(locales)
+
+var catalog = map[string]messages{
+	"en": {
+		Title:    "How did we do?",
+		Prompt:   "How would you rate the support you received on ticket %s?",
+		Good:     "Good",
+		Bad:      "Bad",
+		ThankYou: "Thanks for your feedback!",
+		Invalid:  "This survey link is invalid or has already been used.",
+		Error:    "We could not record your answer. Please try again later.",
+	},
+	"es": {
+		Title:    "¿Qué tal lo hicimos?",
+		Prompt:   "¿Cómo valoraría la asistencia que recibió en el ticket %s?",
+		Good:     "Buena",
+		Bad:      "Mala",
+		ThankYou: "¡Gracias por su opinión!",
+		Invalid:  "Este enlace de encuesta no es válido o ya se ha utilizado.",
+		Error:    "No pudimos registrar su respuesta. Inténtelo de nuevo más tarde.",
+	},
+	"fr": {
+		Title:    "Votre avis nous intéresse",
+		Prompt:   "Comment évalueriez-vous l'assistance reçue pour le ticket %s ?",
+		Good:     "Bonne",
+		Bad:      "Mauvaise",
+		ThankYou: "Merci pour votre retour !",
+		Invalid:  "Ce lien d'enquête n'est pas valide ou a déjà été utilisé.",
+		Error:    "Nous n'avons pas pu enregistrer votre réponse. Veuillez réessayer plus tard.",
+	},
+	"de": {
+		Title:    "Wie waren wir?",
+		Prompt:   "Wie bewerten Sie die Unterstützung, die Sie zu Ticket %s erhalten haben?",
+		Good:     "Gut",
+		Bad:      "Schlecht",
+		ThankYou: "Vielen Dank für Ihr Feedback!",
+		Invalid:  "Dieser Umfragelink ist ungültig oder wurde bereits verwendet.",
+		Error:    "Ihre Antwort konnte nicht gespeichert werden. Bitte versuchen Sie es später erneut.",
+	},
+	"pt": {
+		Title:    "Como nos saímos?",
+		Prompt:   "Como avalia o suporte que recebeu no ticket %s?",
+		Good:     "Bom",
+		Bad:      "Ruim",
+		ThankYou: "Obrigado pelo seu feedback!",
+		Invalid:  "Este link de pesquisa é inválido ou já foi utilizado.",
+		Error:    "Não foi possível registrar sua resposta. Tente novamente mais tarde.",
+	},
+}
+
+// pickLocale chooses the page language from the requester's stored locale,
+// then the browser's Accept-Language, falling back to English.
+func pickLocale(stored, acceptLanguage string) string {
+	_, i := language.MatchStrings(matcher, stored, acceptLanguage)
+	base, _ := locales[i].Base()
+	return base.String()
+}
+
+// supportedLocale reports the catalog key for a locale such as "es-MX".
+func supportedLocale(loc string) (string, bool) {
+	tag, err := language.Parse(loc)
+	if err != nil {
+		return "", false
+	}
+	base, _ := tag.Base()
+	_, ok := catalog[base.String()]
+	return base.String(), ok
+}
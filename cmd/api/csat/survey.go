@@ -0,0 +1,52 @@
+package csat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// Path is where the API serves survey links.
+const Path = "/api/csat/"
+
+// Issue gives a just-resolved ticket a survey token and returns its link,
+// or "" when no survey is due: the ticket's queue opted out, it was already
+// answered, or its requester was sent one within the last throttleDays.
+// Claiming the requester's slot and setting the token happen in one
+// statement, so tickets resolving together cannot both get a survey.
+// Without a baseURL no link can be built and nothing is issued.
+func Issue(ctx context.Context, db app.DB, ticketID string, throttleDays int, baseURL string) (string, error) {
+	if baseURL == "" {
+		return "", nil
+	}
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	var id string
+	err := db.QueryRow(ctx, `with due as (
+            select t.requester_id from tickets t left join queues q on q.id = t.queue_id
+            where t.id = $1 and t.csat_score is null and coalesce(q.csat_enabled, true)
+        ), claimed as (
+            update requesters r set last_csat_at = now() from due
+            where r.id = due.requester_id
+              and (r.last_csat_at is null or r.last_csat_at <= now() - make_interval(days => $2))
+            returning r.id
+        )
+        update tickets set csat_token = $3 where id = $1 and exists (select 1 from claimed)
+        returning id::text`, ticketID, max(throttleDays, 0), token).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(baseURL, "/") + Path + token, nil
+}
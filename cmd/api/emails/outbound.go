@@ -1,44 +1,203 @@
 package emails
 
 import (
+	"errors"
 	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+const (
+	outboundDefaultLimit = 100
+	outboundMaxLimit     = 500
 )
 
+// Outbound is a row of the outbound email log, written by the worker for
+// every send attempt.
 type Outbound struct {
-	ID       string    `json:"id"`
-	To       string    `json:"to"`
-	Subject  string    `json:"subject"`
-	Status   string    `json:"status"`
-	Retries  int       `json:"retries"`
-	TicketID *string   `json:"ticket_id,omitempty"`
-	Created  time.Time `json:"created_at"`
+	ID           string    `json:"id"`
+	To           string    `json:"to"`
+	Subject      string    `json:"subject"`
+	Status       string    `json:"status"`
+	Retries      int       `json:"retries"`
+	TicketID     *string   `json:"ticket_id,omitempty"`
+	Created      time.Time `json:"created_at"`
+	Error        *string   `json:"error,omitempty"`
+	ResentFrom   *string   `json:"resent_from,omitempty"`
+	TicketNumber *string   `json:"ticket_number,omitempty"`
+	// Body is only returned by GetOutbound.
+	Body *string `json:"body,omitempty"`
+}
+
+const outboundCols = `e.id::text, e.to_addr, coalesce(e.subject,''), e.status, e.retries, e.ticket_id::text, e.created_at,
+    e.error, e.resent_from::text, t.number::text`
+
+func scanOutbound(scan func(dest ...any) error, extra ...any) (Outbound, error) {
+	var e Outbound
+	err := scan(append([]any{&e.ID, &e.To, &e.Subject, &e.Status, &e.Retries, &e.TicketID, &e.Created, &e.Error, &e.ResentFrom, &e.TicketNumber}, extra...)...)
+	if e.TicketID != nil && *e.TicketID == "" {
+		e.TicketID = nil
+	}
+	return e, err
 }
 
+// ListOutbound returns the outbound email log, newest first. It filters by
+// status (sent or failed), recipient (?to=, a case-insensitive substring)
+// and ticket, and pages with ?before= (RFC 3339) and ?limit=.
 func ListOutbound(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := a.DB.Query(c.Request.Context(), `select id::text, to_addr, coalesce(subject,''), status, retries, ticket_id::text, created_at from email_outbound order by created_at desc limit 100`)
+		var conds []string
+		var args []any
+		add := func(cond string, v any) {
+			args = append(args, v)
+			conds = append(conds, strings.ReplaceAll(cond, "?", "$"+strconv.Itoa(len(args))))
+		}
+		if v := c.Query("status"); v != "" {
+			if v != "sent" && v != "failed" {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "status must be sent or failed", map[string]string{"status": v})
+				return
+			}
+			add("e.status = ?", v)
+		}
+		if v := strings.TrimSpace(c.Query("to")); v != "" {
+			add("position(lower(?) in lower(e.to_addr)) > 0", v)
+		}
+		if v := c.Query("ticket_id"); v != "" {
+			if _, err := uuid.Parse(v); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid ticket_id", map[string]string{"ticket_id": v})
+				return
+			}
+			add("e.ticket_id = ?::uuid", v)
+		}
+		if v := c.Query("before"); v != "" {
+			before, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "before must be an RFC 3339 timestamp", map[string]string{"before": v})
+				return
+			}
+			add("e.created_at < ?", before)
+		}
+		limit := outboundDefaultLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "limit must be a positive integer", nil)
+				return
+			}
+			limit = min(n, outboundMaxLimit)
+		}
+		where := ""
+		if len(conds) > 0 {
+			where = " where " + strings.Join(conds, " and ")
+		}
+		args = append(args, limit)
+		rows, err := a.DB.Query(c.Request.Context(), `select `+outboundCols+` from email_outbound e
+            left join tickets t on t.id = e.ticket_id`+where+`
+            order by e.created_at desc limit $`+strconv.Itoa(len(args)), args...)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load outbound email", nil)
 			return
 		}
 		defer rows.Close()
 		out := []Outbound{}
 		for rows.Next() {
-			var e Outbound
-			var tid *string
-			if err := rows.Scan(&e.ID, &e.To, &e.Subject, &e.Status, &e.Retries, &tid, &e.Created); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			e, err := scanOutbound(rows.Scan)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load outbound email", nil)
 				return
 			}
-			if tid != nil && *tid != "" {
-				e.TicketID = tid
-			}
 			out = append(out, e)
 		}
 		c.JSON(http.StatusOK, out)
 	}
 }
+
+// GetOutbound returns one logged email with the body that was sent.
+func GetOutbound(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e, ok := loadOutbound(c, a)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, e)
+	}
+}
+
+func loadOutbound(c *gin.Context, a *app.App) (Outbound, bool) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		app.AbortError(c, http.StatusNotFound, "not_found", "email not found", nil)
+		return Outbound{}, false
+	}
+	var body string
+	e, err := scanOutbound(a.DB.QueryRow(c.Request.Context(), `select `+outboundCols+`, coalesce(e.body_html,'')
+        from email_outbound e left join tickets t on t.id = e.ticket_id where e.id = $1`, c.Param("id")).Scan, &body)
+	if errors.Is(err, pgx.ErrNoRows) {
+		app.AbortError(c, http.StatusNotFound, "not_found", "email not found", nil)
+		return Outbound{}, false
+	}
+	if err != nil {
+		app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load email", nil)
+		return Outbound{}, false
+	}
+	e.Body = &body
+	return e, true
+}
+
+// Resend queues a logged email to be sent again exactly as it was, to its
+// original recipient or to the address given. The worker logs the new
+// attempt as its own row pointing back at this one.
+func Resend(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			To string `json:"to"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+				return
+			}
+		}
+		to := strings.TrimSpace(in.To)
+		if to != "" {
+			if addr, err := mail.ParseAddress(to); err != nil || addr.Address != to {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "to must be an email address", map[string]string{"to": to})
+				return
+			}
+		}
+		if a.Q == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "unavailable", "queue not configured", nil)
+			return
+		}
+		e, ok := loadOutbound(c, a)
+		if !ok {
+			return
+		}
+		if to == "" {
+			to = e.To
+		}
+		ctx := c.Request.Context()
+		if err := jobs.Enqueue(ctx, a.Q, "", jobs.TypeSendEmail, jobs.Email{
+			To: to, Subject: e.Subject, Body: *e.Body, TicketID: e.TicketID, ResentFrom: &e.ID,
+		}); err != nil {
+			log.Error().Err(err).Str("email_id", e.ID).Msg("enqueue email resend")
+			app.AbortError(c, http.StatusInternalServerError, "queue_error", "failed to queue email", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "email_outbound", e.ID, "email_resent", map[string]any{"to": to}); err != nil {
+			log.Error().Err(err).Msg("audit email resend")
+		}
+		c.JSON(http.StatusAccepted, gin.H{"queued": true, "resent_from": e.ID, "to": to})
+	}
+}
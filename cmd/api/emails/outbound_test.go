@@ -5,15 +5,20 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
 )
 
 type fakeDB struct{}
@@ -82,3 +87,87 @@ func TestListOutbound(t *testing.T) {
 		t.Fatalf("unexpected response %v", out)
 	}
 }
+
+func TestListOutboundFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var sql string
+	var args []any
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, q string, a ...any) (pgx.Rows, error) {
+		sql, args = q, a
+		return &testutil.MockRows{}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/admin/email/outbound", ListOutbound(a))
+	get := func(query string) int {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/email/outbound"+query, nil))
+		return rr.Code
+	}
+	const ticket = "11111111-1111-1111-1111-111111111111"
+	if code := get("?status=failed&to=Alice@&ticket_id=" + ticket + "&limit=1000"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !strings.Contains(sql, "e.status = $1") || !strings.Contains(sql, "lower($2)") || !strings.Contains(sql, "e.ticket_id = $3::uuid") || !strings.Contains(sql, "limit $4") {
+		t.Fatalf("unexpected query %s", sql)
+	}
+	if args[0] != "failed" || args[1] != "Alice@" || args[2] != ticket || args[3] != outboundMaxLimit {
+		t.Fatalf("unexpected args %v", args)
+	}
+	for _, q := range []string{"?status=bounced", "?ticket_id=42", "?before=yesterday", "?limit=0"} {
+		if code := get(q); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", q, code)
+		}
+	}
+}
+
+func TestResendOutbound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	const (
+		email  = "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee"
+		ticket = "11111111-1111-1111-1111-111111111111"
+	)
+	db := &testutil.MockDB{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+			if args[0] != email {
+				return pgx.ErrNoRows
+			}
+			tid := ticket
+			*dest[0].(*string) = email
+			*dest[1].(*string) = "customer@example.com"
+			*dest[2].(*string) = "[TKT-9] Ticket resolved"
+			*dest[3].(*string) = "sent"
+			*dest[5].(**string) = &tid
+			*dest[10].(*string) = "Your ticket TKT-9 has been resolved."
+			return nil
+		}}
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, rdb)
+	a.R.POST("/admin/email/outbound/:id/resend", Resend(a))
+	post := func(id, body string) int {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/email/outbound/"+id+"/resend", strings.NewReader(body)))
+		return rr.Code
+	}
+	if code := post("22222222-2222-2222-2222-222222222222", ""); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown email, got %d", code)
+	}
+	if code := post(email, `{"to":"not an address"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad recipient, got %d", code)
+	}
+	if code := post(email, `{"to":"customer.alt@example.com"}`); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	b, err := rdb.LPop(context.Background(), jobs.Queue).Bytes()
+	if err != nil {
+		t.Fatalf("expected a queued job: %v", err)
+	}
+	job, _ := jobs.Decode(b)
+	var ej jobs.Email
+	_ = json.Unmarshal(job.Data, &ej)
+	if job.Type != jobs.TypeSendEmail || ej.To != "customer.alt@example.com" || ej.Template != "" || ej.Subject != "[TKT-9] Ticket resolved" ||
+		ej.Body != "Your ticket TKT-9 has been resolved." || ej.ResentFrom == nil || *ej.ResentFrom != email || ej.TicketID == nil || *ej.TicketID != ticket {
+		t.Fatalf("unexpected job %+v", ej)
+	}
+}
@@ -4,11 +4,28 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/rs/zerolog/log"
+
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 )
 
-// Emit records a ticket event in the database. Best effort; errors are ignored.
-func Emit(ctx context.Context, db apppkg.DB, ticketID, typ string, data interface{}) {
+// webhookEvents maps ticket event types to the webhook events they publish.
+var webhookEvents = map[string]string{
+	"ticket_created": "ticket.created",
+	"ticket_updated": "ticket.updated",
+	// Merges and links change both sides; the payload names the other.
+	"ticket_merged":      "ticket.updated",
+	"ticket_merged_into": "ticket.updated",
+	"ticket_linked":      "ticket.updated",
+	"ticket_unlinked":    "ticket.updated",
+}
+
+// Emit records a ticket event in the database, attributed to act, and
+// publishes the matching webhook event if there is one. Best effort; errors
+// are ignored.
+func Emit(ctx context.Context, db apppkg.DB, act actor.Actor, ticketID, typ string, data interface{}) {
 	if db == nil {
 		return
 	}
@@ -16,6 +33,24 @@ func Emit(ctx context.Context, db apppkg.DB, ticketID, typ string, data interfac
 	if err != nil {
 		return
 	}
-	const q = `insert into ticket_events (ticket_id, event_type, payload) values ($1, $2, $3)`
-	_, _ = db.Exec(ctx, q, ticketID, typ, b)
+	const q = `insert into ticket_events (ticket_id, event_type, payload, actor_type, actor_id) values ($1, $2, $3, $4, $5)`
+	_, _ = db.Exec(ctx, q, ticketID, typ, b, act.Type, act.DBID())
+	if event, ok := webhookEvents[typ]; ok {
+		// The payload carries the ticket itself; the rest of data rides along.
+		var extra map[string]any
+		if m, ok := data.(map[string]any); ok {
+			for k, v := range m {
+				if k == "id" {
+					continue
+				}
+				if extra == nil {
+					extra = map[string]any{}
+				}
+				extra[k] = v
+			}
+		}
+		if err := webhook.Publish(ctx, db, event, ticketID, extra); err != nil {
+			log.Error().Err(err).Str("ticket", ticketID).Str("event", event).Msg("publish webhook event")
+		}
+	}
 }
@@ -0,0 +1,147 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+)
+
+const (
+	historyDefaultLimit = 100
+	historyMaxLimit     = 1000
+	replayMaxEvents     = 10000
+)
+
+// Record is a persisted ticket event addressed by its sequence number.
+type Record struct {
+	Seq       int64           `json:"seq"`
+	ID        string          `json:"id"`
+	TicketID  string          `json:"ticket_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	ActorType string          `json:"actor_type,omitempty"`
+	ActorID   *string         `json:"actor_id,omitempty"`
+}
+
+const recordCols = `seq, id::text, ticket_id::text, event_type, payload, created_at, coalesce(actor_type, ''), actor_id::text`
+
+func scanRecord(scan func(dest ...any) error) (Record, error) {
+	var r Record
+	var payload []byte
+	err := scan(&r.Seq, &r.ID, &r.TicketID, &r.Type, &payload, &r.CreatedAt, &r.ActorType, &r.ActorID)
+	r.Payload = payload
+	return r, err
+}
+
+// History returns events with a sequence number greater than ?since=, oldest
+// first. Clients page by passing back next_since until it stops advancing.
+func History(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+		if err != nil || since < 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "since must be a non-negative integer", nil)
+			return
+		}
+		limit := historyDefaultLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "limit must be a positive integer", nil)
+				return
+			}
+			limit = min(n, historyMaxLimit)
+		}
+		out := []Record{}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"events": out, "next_since": since})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+recordCols+` from ticket_events where seq > $1 order by seq asc limit $2`, since, limit)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		defer rows.Close()
+		next := since
+		for rows.Next() {
+			r, err := scanRecord(rows.Scan)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+			out = append(out, r)
+			next = r.Seq
+		}
+		c.JSON(http.StatusOK, gin.H{"events": out, "next_since": next})
+	}
+}
+
+type replayReq struct {
+	FromSeq  int64  `json:"from_seq" binding:"required"`
+	ToSeq    int64  `json:"to_seq" binding:"required"`
+	TicketID string `json:"ticket_id"`
+	DryRun   bool   `json:"dry_run"`
+}
+
+// Replay re-publishes the stored events in [from_seq, to_seq] to realtime
+// subscribers so downstream consumers can rebuild state after an outage.
+// Replayed events carry the original type and payload. SSE clients resume
+// from the database and need no replay.
+func Replay(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in replayReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "from_seq and to_seq are required", nil)
+			return
+		}
+		if in.FromSeq <= 0 || in.ToSeq < in.FromSeq {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid sequence range", nil)
+			return
+		}
+		if in.ToSeq-in.FromSeq >= replayMaxEvents {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "range too large", map[string]string{"max_events": strconv.Itoa(replayMaxEvents)})
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"replayed": 0, "dry_run": in.DryRun})
+			return
+		}
+		ctx := c.Request.Context()
+		q := `select ` + recordCols + ` from ticket_events where seq between $1 and $2`
+		args := []any{in.FromSeq, in.ToSeq}
+		if in.TicketID != "" {
+			q += ` and ticket_id = $3`
+			args = append(args, in.TicketID)
+		}
+		q += ` order by seq asc`
+		rows, err := a.DB.Query(ctx, q, args...)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		var recs []Record
+		for rows.Next() {
+			r, err := scanRecord(rows.Scan)
+			if err != nil {
+				rows.Close()
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+			recs = append(recs, r)
+		}
+		rows.Close()
+		if !in.DryRun {
+			for _, r := range recs {
+				ws.PublishEvent(ctx, a.Q, ws.Event{Type: r.Type, Data: r.Payload})
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"replayed": len(recs), "dry_run": in.DryRun})
+	}
+}
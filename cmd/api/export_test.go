@@ -16,6 +16,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
 	"github.com/minio/minio-go/v7"
 	"github.com/redis/go-redis/v9"
 )
@@ -147,8 +148,10 @@ func TestExportTickets(t *testing.T) {
 		t.Fatalf("missing url in response")
 	}
 	// Extract objectKey and read from fake store directly (no network in CI)
-	parts := strings.Split(url, "/")
-	objectKey := parts[len(parts)-1]
+	_, objectKey, _ := strings.Cut(url, "/bucket/")
+	if !strings.HasPrefix(objectKey, "exports/") {
+		t.Fatalf("object key %q is not under exports/", objectKey)
+	}
 	b := store.objects[objectKey]
 	got := strings.TrimSpace(string(b))
 	want := "id,number,title,status,priority\n1,TKT-1,First,Open,1"
@@ -215,3 +218,47 @@ func TestExportTicketsAsync(t *testing.T) {
 		t.Fatalf("unexpected url %q", resp["url"])
 	}
 }
+
+func TestExportTicketsPackagedIsAsync(t *testing.T) {
+	mr := miniredis.RunT(t)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	store := newFakeObjectStore()
+	defer store.Close()
+
+	db := &exportDB{count: 1}
+	cfg := Config{Env: "test", TestBypassAuth: true, MinIOEndpoint: strings.TrimPrefix(store.URL(), "http://"), MinIOBucket: "bucket"}
+	hub2 := ws.NewHub(rdb)
+	go hub2.Run(context.Background())
+	app := NewApp(cfg, db, nil, store, rdb, hub2)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/exports/tickets", strings.NewReader(`{"ids":["1"],"comments":"threaded"}`))
+	app.r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown comments option, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/exports/tickets", strings.NewReader(`{"ids":["1"],"comments":"file","attachments":"bundle"}`))
+	app.r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected a small packaged export to be queued, got %d", rr.Code)
+	}
+	raw, err := rdb.LPop(context.Background(), jobs.QueueFor(jobs.TypeExportTickets)).Result()
+	if err != nil {
+		t.Fatalf("expected a queued job: %v", err)
+	}
+	job, err := jobs.Decode([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ej jobs.ExportTickets
+	if err := json.Unmarshal(job.Data, &ej); err != nil {
+		t.Fatal(err)
+	}
+	if ej.Comments != jobs.ExportCommentsFile || ej.Attachments != jobs.ExportAttachmentsBundle {
+		t.Fatalf("options not passed to the worker: %+v", ej)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/s3"
 	"github.com/minio/minio-go/v7"
 )
 
@@ -21,7 +22,8 @@ type TicketsReq struct {
 
 func Tickets(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		store, bucket := a.ResolveStore(c.Request.Context())
+		store, loc := a.ResolveClass(c.Request.Context(), s3.ClassExports)
+		bucket := loc.Bucket
 		if store == nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "minio not configured"})
 			return
@@ -58,7 +60,7 @@ func Tickets(a *app.App) gin.HandlerFunc {
 			_ = w.Write([]string{id, number, title, status, strconv.Itoa(int(priority))})
 		}
 		w.Flush()
-		objectKey := uuid.New().String() + ".csv"
+		objectKey := loc.Key(uuid.New().String() + ".csv")
 		oc, cancel := a.ObjCtx(ctx)
 		defer cancel()
 		_, err = store.PutObject(oc, bucket, objectKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{ContentType: "text/csv"})
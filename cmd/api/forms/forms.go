@@ -0,0 +1,378 @@
+// Package forms serves the portal's ticket intake forms. A queue may have
+// one: which of the ticket's own fields it shows and requires, and a custom
+// field schema, conditions included, for the details the queue needs.
+// Tickets requesters raise in the queue are checked against it.
+package forms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+// Built-in ticket fields a form can show. The title is always shown and
+// required; priority is left to triage.
+const (
+	BuiltinDescription = "description"
+	BuiltinCategory    = "category"
+	BuiltinSubcategory = "subcategory"
+	BuiltinUrgency     = "urgency"
+)
+
+// Builtins lists the built-in fields in display order.
+var Builtins = []string{BuiltinDescription, BuiltinCategory, BuiltinSubcategory, BuiltinUrgency}
+
+// Builtin is a built-in ticket field shown on a form.
+type Builtin struct {
+	Key      string `json:"key"`
+	Required bool   `json:"required"`
+}
+
+// Form is a queue's intake form.
+type Form struct {
+	QueueID     string    `json:"queue_id"`
+	QueueName   string    `json:"queue_name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Builtins    []Builtin `json:"builtins"`
+	// Fields are asked for after the built-in fields; the answers are kept
+	// in the ticket's custom_json.
+	Fields    customfields.Schema `json:"fields"`
+	Active    bool                `json:"active"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+const formCols = `f.queue_id::text, q.name, f.title, f.description, f.builtins, f.fields, f.active, f.updated_at`
+
+const formFrom = `intake_forms f join queues q on q.id = f.queue_id`
+
+func scanForm(row pgx.Row) (Form, error) {
+	var f Form
+	var builtins, fields []byte
+	if err := row.Scan(&f.QueueID, &f.QueueName, &f.Title, &f.Description, &builtins, &fields, &f.Active, &f.UpdatedAt); err != nil {
+		return f, err
+	}
+	f.Builtins = []Builtin{}
+	if len(builtins) > 0 {
+		if err := json.Unmarshal(builtins, &f.Builtins); err != nil {
+			return f, err
+		}
+	}
+	var err error
+	if f.Fields, err = customfields.Parse(fields); err != nil {
+		return f, err
+	}
+	if f.Fields.Fields == nil {
+		f.Fields.Fields = []customfields.Field{}
+	}
+	return f, nil
+}
+
+// forCaller returns f as c may see it: requesters do not see internal
+// fields.
+func (f Form) forCaller(c *gin.Context) Form {
+	if !authpkg.IsStaff(c) {
+		f.Fields = f.Fields.Public()
+	}
+	return f
+}
+
+// builtin returns the form's entry for the built-in field key.
+func (f Form) builtin(key string) (Builtin, bool) {
+	i := slices.IndexFunc(f.Builtins, func(b Builtin) bool { return b.Key == key })
+	if i < 0 {
+		return Builtin{}, false
+	}
+	return f.Builtins[i], true
+}
+
+// Submission is the part of a new ticket a form governs.
+type Submission struct {
+	Description string
+	Category    *string
+	Subcategory *string
+	Urgency     *int16
+	Custom      map[string]any
+}
+
+// Check returns the problems with sub by field, or nil. Built-in fields the
+// form does not show are cleared and answers to internal custom fields and
+// to those hidden by their condition dropped, so what a requester could not
+// see is not kept.
+func (f Form) Check(sub *Submission) map[string]string {
+	errs := map[string]string{}
+	text := map[string]**string{BuiltinCategory: &sub.Category, BuiltinSubcategory: &sub.Subcategory}
+	for _, key := range Builtins {
+		b, shown := f.builtin(key)
+		switch key {
+		case BuiltinDescription:
+			if !shown {
+				sub.Description = ""
+			} else if b.Required && strings.TrimSpace(sub.Description) == "" {
+				errs[key] = "required"
+			}
+		case BuiltinUrgency:
+			if !shown {
+				sub.Urgency = nil
+			} else if b.Required && sub.Urgency == nil {
+				errs[key] = "required"
+			}
+		default:
+			v := text[key]
+			if !shown {
+				*v = nil
+			} else if b.Required && (*v == nil || strings.TrimSpace(**v) == "") {
+				errs[key] = "required"
+			}
+		}
+	}
+	public := f.Fields.Public()
+	sub.Custom = public.Prune(f.Fields.StripInternal(sub.Custom))
+	var fieldErrs customfields.Errors
+	if errors.As(public.Validate(sub.Custom), &fieldErrs) {
+		for _, e := range fieldErrs {
+			errs["custom_json."+e.Field] = e.Message
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ForQueue returns the active form of a queue; ok is false when it has
+// none.
+func ForQueue(ctx context.Context, db apppkg.DB, queueID string) (f Form, ok bool, err error) {
+	f, err = scanForm(db.QueryRow(ctx, `select `+formCols+` from `+formFrom+` where f.queue_id = $1 and f.active`, queueID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Form{}, false, nil
+	}
+	return f, err == nil, err
+}
+
+// formInput is the writable part of a form. Updates start from the stored
+// form so only the fields present in the body change.
+type formInput struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	Builtins    []Builtin           `json:"builtins"`
+	Fields      customfields.Schema `json:"fields"`
+	Active      bool                `json:"active"`
+}
+
+// check normalizes in and returns the problems with it by field.
+func (in *formInput) check() map[string]string {
+	errs := map[string]string{}
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		errs["title"] = "required"
+	}
+	seen := map[string]bool{}
+	for i, b := range in.Builtins {
+		at := fmt.Sprintf("builtins[%d].key", i)
+		switch {
+		case !slices.Contains(Builtins, b.Key):
+			errs[at] = "must be one of " + strings.Join(Builtins, ", ")
+		case seen[b.Key]:
+			errs[at] = fmt.Sprintf("%q is listed twice", b.Key)
+		}
+		seen[b.Key] = true
+	}
+	var schemaErrs customfields.Errors
+	if errors.As(in.Fields.Check(), &schemaErrs) {
+		for _, e := range schemaErrs {
+			errs["fields."+e.Field] = e.Message
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func formAudit(f Form) map[string]any {
+	return map[string]any{"title": f.Title, "description": f.Description, "builtins": f.Builtins, "fields": f.Fields, "active": f.Active}
+}
+
+// List returns the active intake forms by queue name for the portal to
+// offer. Staff see inactive forms too with all=true.
+func List(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		all := c.Query("all") == "true" && authpkg.IsStaff(c)
+		rows, err := a.DB.Query(c.Request.Context(), `select `+formCols+` from `+formFrom+` where f.active or $1 order by q.name`, all)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list intake forms", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Form{}
+		for rows.Next() {
+			f, err := scanForm(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list intake forms", nil)
+				return
+			}
+			out = append(out, f.forCaller(c))
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// loadForm returns the form of the queue named by :id. Inactive forms are
+// only found for staff.
+func loadForm(c *gin.Context, db apppkg.DB) (Form, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return Form{}, pgx.ErrNoRows
+	}
+	f, err := scanForm(db.QueryRow(c.Request.Context(), `select `+formCols+` from `+formFrom+` where f.queue_id = $1`, c.Param("id")))
+	if err == nil && !f.Active && !authpkg.IsStaff(c) {
+		return Form{}, pgx.ErrNoRows
+	}
+	return f, err
+}
+
+// Get returns a queue's intake form.
+func Get(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, err := loadForm(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "intake form not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load intake form", nil)
+			return
+		}
+		c.JSON(http.StatusOK, f.forCaller(c))
+	}
+}
+
+// Put creates or changes a queue's intake form. On a change only the
+// fields present in the body change. Requires admin.
+func Put(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "queue not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, f Form
+		var created bool
+		var errs map[string]string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			before, err = scanForm(tx.QueryRow(ctx, `select `+formCols+` from `+formFrom+` where f.queue_id = $1 for update of f`, c.Param("id")))
+			created = errors.Is(err, pgx.ErrNoRows)
+			if err != nil && !created {
+				return err
+			}
+			in := formInput{Title: before.Title, Description: before.Description, Builtins: before.Builtins, Fields: before.Fields, Active: before.Active}
+			if created {
+				in.Active = true
+			}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			if _, err := tx.Exec(ctx, `insert into intake_forms (queue_id, title, description, builtins, fields, active, updated_by)
+                values ($1, $2, $3, $4, $5, $6, $7)
+                on conflict (queue_id) do update set title = excluded.title, description = excluded.description,
+                    builtins = excluded.builtins, fields = excluded.fields, active = excluded.active,
+                    updated_by = excluded.updated_by, updated_at = now()`,
+				c.Param("id"), in.Title, in.Description, builtinsJSON(in.Builtins), fieldsJSON(in.Fields), in.Active, authpkg.Actor(c).DBID()); err != nil {
+				return err
+			}
+			if f, err = scanForm(tx.QueryRow(ctx, `select `+formCols+` from `+formFrom+` where f.queue_id = $1`, c.Param("id"))); err != nil {
+				return err
+			}
+			if created {
+				return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "queue", f.QueueID, "intake_form_created", formAudit(f))
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "queue", f.QueueID, "intake_form_updated", audit.Diff(formAudit(before), formAudit(f)))
+		})
+		if errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		var pge *pgconn.PgError
+		if errors.As(err, &pge) && pge.Code == "23503" {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "queue not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save intake form", nil)
+			return
+		}
+		if created {
+			c.JSON(http.StatusCreated, f)
+			return
+		}
+		c.JSON(http.StatusOK, f)
+	}
+}
+
+// Delete removes a queue's intake form; its tickets go back to the generic
+// form. Requires admin.
+func Delete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			before, err := loadForm(c, tx)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `delete from intake_forms where queue_id = $1`, before.QueueID); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "queue", before.QueueID, "intake_form_deleted", formAudit(before))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "intake form not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete intake form", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func builtinsJSON(b []Builtin) []byte {
+	if b == nil {
+		b = []Builtin{}
+	}
+	out, _ := json.Marshal(b)
+	return out
+}
+
+func fieldsJSON(s customfields.Schema) []byte {
+	if s.Fields == nil {
+		s.Fields = []customfields.Field{}
+	}
+	out, _ := json.Marshal(s)
+	return out
+}
@@ -0,0 +1,61 @@
+package forms
+
+import (
+	"testing"
+
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+func TestFormCheck(t *testing.T) {
+	f := Form{
+		Builtins: []Builtin{{Key: BuiltinDescription, Required: true}, {Key: BuiltinCategory}},
+		Fields: customfields.Schema{Fields: []customfields.Field{
+			{Key: "device", Type: customfields.TypeSelect, Options: []string{"laptop", "phone"}, Required: true},
+			{Key: "imei", Type: customfields.TypeText, Required: true, ShowIf: &customfields.Condition{Field: "device", Values: []string{"phone"}}},
+			{Key: "triage", Type: customfields.TypeText, Required: true, Internal: true},
+		}},
+	}
+	sub, urgency := "Printers", int16(2)
+	s := Submission{Description: " ", Subcategory: &sub, Urgency: &urgency, Custom: map[string]any{"device": "phone"}}
+	errs := f.Check(&s)
+	if len(errs) != 2 || errs["description"] != "required" || errs["custom_json.imei"] == "" {
+		t.Fatalf("errors = %v", errs)
+	}
+	if s.Subcategory != nil || s.Urgency != nil {
+		t.Fatalf("hidden built-in fields kept: %+v", s)
+	}
+
+	s = Submission{Description: "Cracked screen", Custom: map[string]any{"device": "laptop", "imei": "35-209900-176148-1", "triage": "vip"}}
+	if errs := f.Check(&s); errs != nil {
+		t.Fatalf("valid submission rejected: %v", errs)
+	}
+	if _, ok := s.Custom["imei"]; ok {
+		t.Fatalf("hidden custom field kept: %v", s.Custom)
+	}
+	if _, ok := s.Custom["triage"]; ok {
+		t.Fatalf("internal custom field kept: %v", s.Custom)
+	}
+}
+
+func TestFormInputCheck(t *testing.T) {
+	in := formInput{
+		Title:    " ",
+		Builtins: []Builtin{{Key: BuiltinUrgency}, {Key: "priority"}, {Key: BuiltinUrgency}},
+		Fields: customfields.Schema{Fields: []customfields.Field{
+			{Key: "imei", Type: customfields.TypeText, ShowIf: &customfields.Condition{Field: "device", Values: []string{"phone"}}},
+		}},
+	}
+	errs := in.check()
+	for _, k := range []string{"title", "builtins[1].key", "builtins[2].key", "fields.fields[0].show_if"} {
+		if errs[k] == "" {
+			t.Errorf("no error for %s in %v", k, errs)
+		}
+	}
+	if len(errs) != 4 {
+		t.Errorf("errors = %v", errs)
+	}
+	in = formInput{Title: " Laptop repair ", Builtins: []Builtin{{Key: BuiltinDescription, Required: true}}}
+	if errs := in.check(); errs != nil || in.Title != "Laptop repair" {
+		t.Fatalf("valid form rejected: %v", errs)
+	}
+}
@@ -0,0 +1,175 @@
+package guests
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	commentspkg "github.com/mark3748/helpdesk-go/cmd/api/comments"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+)
+
+// TokenHeader carries the guest token. Links opened in a browser pass it as
+// ?token= instead.
+const TokenHeader = "X-Guest-Token"
+
+// sessionKey is the context key holding the Session of a validated token.
+const sessionKey = "guest_session"
+
+// maxBody caps the length of a guest reply.
+const maxBody = 20000
+
+// Session is what a validated guest token grants.
+type Session struct {
+	LinkID      string
+	TicketID    string
+	RequesterID string
+	Scope       string
+}
+
+// Actor attributes audit entries and events to the link.
+func (s Session) Actor() actor.Actor { return actor.Guest(s.LinkID) }
+
+// Ticket is the part of a ticket a guest may see.
+type Ticket struct {
+	Number string `json:"number"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// Comment is a public comment as shown to a guest.
+type Comment struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	BodyMD    string    `json:"body_md"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// View is the guest payload.
+type View struct {
+	Ticket   Ticket    `json:"ticket"`
+	Comments []Comment `json:"comments"`
+	CanReply bool      `json:"can_reply"`
+}
+
+// RequireToken authenticates guest requests with a share link token instead
+// of a user session. It grants access to the link's ticket only.
+func RequireToken(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(TokenHeader))
+		if raw == "" {
+			raw = strings.TrimSpace(c.Query("token"))
+		}
+		if raw == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "guest token required", nil)
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database unavailable", nil)
+			return
+		}
+		s, err := lookup(c.Request.Context(), a.DB, raw)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusUnauthorized, "invalid_token", "invalid or expired link", nil)
+			} else {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to validate token", nil)
+			}
+			return
+		}
+		_, _ = a.DB.Exec(c.Request.Context(), `update ticket_guest_links set last_used_at=now() where id=$1`, s.LinkID)
+		c.Set(sessionKey, s)
+		c.Next()
+	}
+}
+
+func sessionFrom(c *gin.Context) Session {
+	v, _ := c.Get(sessionKey)
+	s, _ := v.(Session)
+	return s
+}
+
+// Get returns the shared ticket and its public comments.
+func Get(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := sessionFrom(c)
+		ctx := c.Request.Context()
+		out := View{Comments: []Comment{}, CanReply: s.Scope == ScopeReply}
+		err := a.DB.QueryRow(ctx, `select number, title, status from tickets where id=$1`, s.TicketID).Scan(&out.Ticket.Number, &out.Ticket.Title, &out.Ticket.Status)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load ticket", nil)
+			return
+		}
+		rows, err := a.DB.Query(ctx, `select tc.id::text, coalesce(u.display_name, r.name, r.email, 'Support'), tc.body_md, tc.created_at
+            from ticket_comments tc
+            left join users u on u.id = tc.author_id
+            left join requesters r on r.id = tc.author_requester_id
+            where tc.ticket_id=$1 and not tc.is_internal
+            order by tc.created_at asc`, s.TicketID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load comments", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var cm Comment
+			if err := rows.Scan(&cm.ID, &cm.Author, &cm.BodyMD, &cm.CreatedAt); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load comments", nil)
+				return
+			}
+			out.Comments = append(out.Comments, cm)
+		}
+		if err := audit.RecordDiff(ctx, a.DB, s.Actor(), "ticket", s.TicketID, "guest_viewed", map[string]any{"link_id": s.LinkID}); err != nil {
+			log.Error().Err(err).Msg("audit guest view")
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Reply adds a public comment to the shared ticket on behalf of the guest.
+func Reply(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := sessionFrom(c)
+		if s.Scope != ScopeReply {
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", "this link is view only", nil)
+			return
+		}
+		var in struct {
+			BodyMD string `json:"body_md"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.BodyMD) == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"body_md": "required"})
+			return
+		}
+		if len(in.BodyMD) > maxBody {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "comment too long", map[string]string{"body_md": "too long"})
+			return
+		}
+		ctx := c.Request.Context()
+		var id string
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `insert into ticket_comments (ticket_id, author_id, author_requester_id, guest_link_id, body_md, is_internal)
+                values ($1, null, $2, $3, $4, false) returning id::text`, s.TicketID, s.RequesterID, s.LinkID, in.BodyMD).Scan(&id); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, s.Actor(), "ticket", s.TicketID, "guest_replied", map[string]any{"link_id": s.LinkID, "comment_id": id})
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to add comment", nil)
+			return
+		}
+		eventspkg.Emit(ctx, a.DB, s.Actor(), s.TicketID, "ticket_updated", map[string]any{"id": s.TicketID})
+		if err := commentspkg.NotifyCCs(ctx, a.DB, s.TicketID, id, in.BodyMD); err != nil {
+			log.Error().Err(err).Msg("failed to record cc comment emails")
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": id})
+	}
+}
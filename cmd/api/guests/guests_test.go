@@ -0,0 +1,210 @@
+package guests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+const linkID = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+
+// guestDB serves one guest link with the given scope and records audit
+// actions and inserted comments.
+func guestDB(scope string, audits *[]string, comments *[][]any) *testutil.MockDB {
+	const token = "gl_vendor"
+	return &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from ticket_guest_links"):
+					if args[0] != secret.Hash(token) {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string) = linkID
+					*dest[1].(*string) = "t1"
+					*dest[2].(*string) = "r1"
+					*dest[3].(*string) = scope
+				case strings.Contains(sql, "from tickets"):
+					*dest[0].(*string) = "HD-1"
+					*dest[1].(*string) = "Printer down"
+					*dest[2].(*string) = "Open"
+				case strings.Contains(sql, "insert into ticket_comments"):
+					*comments = append(*comments, args)
+					*dest[0].(*string) = "c1"
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "not tc.is_internal") {
+				panic("guest comments must exclude internal notes")
+			}
+			n := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { n++; return n == 1 },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "c0"
+					*dest[1].(*string) = "Agent"
+					*dest[2].(*string) = "Looking into it"
+					*dest[3].(*time.Time) = time.Now()
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into audit_events") {
+				if args[0] != "guest" || args[1] != linkID {
+					panic("guest actions must be attributed to the link")
+				}
+				*audits = append(*audits, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+}
+
+func TestGuestAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var audits []string
+	var comments [][]any
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, guestDB(ScopeReply, &audits, &comments), nil, nil, nil)
+	a.R.GET("/guest/ticket", RequireToken(a), Get(a))
+	a.R.POST("/guest/ticket/comments", RequireToken(a), Reply(a))
+
+	for name, tc := range map[string]struct {
+		header string
+		want   int
+	}{
+		"missing": {want: http.StatusUnauthorized},
+		"unknown": {header: "gl_other", want: http.StatusUnauthorized},
+		"valid":   {header: "gl_vendor", want: http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/guest/ticket", nil)
+		if tc.header != "" {
+			req.Header.Set(TokenHeader, tc.header)
+		}
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/guest/ticket?token=gl_vendor", nil))
+	var v View
+	if err := json.Unmarshal(rr.Body.Bytes(), &v); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if v.Ticket.Number != "HD-1" || len(v.Comments) != 1 || !v.CanReply {
+		t.Fatalf("unexpected view: %+v", v)
+	}
+
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/guest/ticket/comments", bytes.NewBufferString(`{"body_md":"Part shipped"}`))
+	req.Header.Set(TokenHeader, "gl_vendor")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("reply: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(comments) != 1 || comments[0][0] != "t1" || comments[0][1] != "r1" || comments[0][2] != linkID {
+		t.Fatalf("comment not attributed to the guest: %v", comments)
+	}
+	if strings.Join(audits, ",") != "guest_viewed,guest_viewed,guest_replied" {
+		t.Fatalf("unexpected audit trail: %v", audits)
+	}
+}
+
+func TestGuestViewOnlyCannotReply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var audits []string
+	var comments [][]any
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, guestDB(ScopeView, &audits, &comments), nil, nil, nil)
+	a.R.POST("/guest/ticket/comments", RequireToken(a), Reply(a))
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/guest/ticket/comments", bytes.NewBufferString(`{"body_md":"hi"}`))
+	req.Header.Set(TokenHeader, "gl_vendor")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+	if len(comments) != 0 {
+		t.Fatal("view-only link added a comment")
+	}
+}
+
+func TestCreateLink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var stored []any
+	var audited bool
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "into requesters") {
+					*dest[0].(*string) = "r1"
+					return nil
+				}
+				stored = args
+				*dest[0].(*string) = "link1"
+				*dest[1].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			audited = audited || (strings.Contains(sql, "audit_events") && args[4] == "guest_link_created")
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/guest-links", authpkg.Middleware(a), CreateLink(a))
+
+	for name, body := range map[string]string{
+		"bad email":   `{"email":"nope"}`,
+		"bad scope":   `{"email":"v@example.com","scope":"admin"}`,
+		"too long":    `{"email":"v@example.com","expires_at":"` + time.Now().Add(60*24*time.Hour).Format(time.RFC3339) + `"}`,
+		"already out": `{"email":"v@example.com","expires_at":"` + time.Now().Add(-time.Hour).Format(time.RFC3339) + `"}`,
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/t1/guest-links", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/t1/guest-links", bytes.NewBufferString(`{"email":"Vendor@Example.com","scope":"view"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var l Link
+	if err := json.Unmarshal(rr.Body.Bytes(), &l); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if !strings.HasPrefix(l.Secret, "gl_") || l.Email != "vendor@example.com" || l.Scope != ScopeView {
+		t.Fatalf("unexpected link: %+v", l)
+	}
+	if stored[1] != secret.Hash(l.Secret) || stored[2] != "r1" {
+		t.Fatalf("expected token hash and requester to be stored, got %v", stored)
+	}
+	if time.Until(l.ExpiresAt) > DefaultLifetime {
+		t.Fatalf("default expiry not applied: %v", l.ExpiresAt)
+	}
+	if !audited {
+		t.Fatal("link creation not audited")
+	}
+}
@@ -0,0 +1,201 @@
+// Package guests lets agents share a single ticket with an external
+// collaborator through an expiring link. Guests authenticate with the link's
+// token, see only public comments and, when the link allows it, reply. Every
+// link change and guest action is written to the audit log.
+package guests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/requesters"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+// Link scopes.
+const (
+	ScopeView  = "view"
+	ScopeReply = "reply"
+)
+
+const (
+	// DefaultLifetime applies when a link is created without expires_at.
+	DefaultLifetime = 7 * 24 * time.Hour
+	// MaxLifetime bounds how far in the future a link may expire.
+	MaxLifetime = 30 * 24 * time.Hour
+)
+
+// Link is a guest share link as listed to agents. The token itself is only
+// returned once, when the link is created.
+type Link struct {
+	ID         string     `json:"id"`
+	TicketID   string     `json:"ticket_id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name,omitempty"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Secret     string     `json:"token,omitempty"`
+}
+
+// ListLinks returns the ticket's guest links, newest first, including revoked
+// and expired ones.
+func ListLinks(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []Link{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select l.id::text, l.ticket_id::text, coalesce(r.email,''), coalesce(r.name,''), l.scope,
+                l.created_at, l.expires_at, l.last_used_at, l.revoked_at
+            from ticket_guest_links l join requesters r on r.id = l.requester_id
+            where l.ticket_id = $1 order by l.created_at desc`, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list guest links", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Link{}
+		for rows.Next() {
+			var l Link
+			if err := rows.Scan(&l.ID, &l.TicketID, &l.Email, &l.Name, &l.Scope, &l.CreatedAt, &l.ExpiresAt, &l.LastUsedAt, &l.RevokedAt); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list guest links", nil)
+				return
+			}
+			out = append(out, l)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateLink issues a guest link for the ticket. The guest is recorded as a
+// requester contact so their replies are attributed; no account is created.
+// The response is the only time the token is shown.
+func CreateLink(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Email     string     `json:"email"`
+			Name      string     `json:"name"`
+			Scope     string     `json:"scope"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		now := time.Now()
+		l := Link{
+			TicketID:  c.Param("id"),
+			Email:     strings.ToLower(strings.TrimSpace(in.Email)),
+			Name:      strings.TrimSpace(in.Name),
+			Scope:     in.Scope,
+			ExpiresAt: now.Add(DefaultLifetime),
+		}
+		if !requesters.ValidEmail(l.Email) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid email", map[string]string{"email": "required"})
+			return
+		}
+		if l.Scope == "" {
+			l.Scope = ScopeReply
+		}
+		if l.Scope != ScopeView && l.Scope != ScopeReply {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid scope", map[string]string{"scope": "must be view or reply"})
+			return
+		}
+		if in.ExpiresAt != nil {
+			if !in.ExpiresAt.After(now) || in.ExpiresAt.Sub(now) > MaxLifetime {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid expires_at", map[string]string{"expires_at": "must be in the next 30 days"})
+				return
+			}
+			l.ExpiresAt = *in.ExpiresAt
+		}
+		raw, err := secret.New("gl_")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "internal", "failed to generate token", nil)
+			return
+		}
+		l.Secret = raw
+		if a.DB == nil {
+			c.JSON(http.StatusCreated, l)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var requesterID string
+			if err := tx.QueryRow(ctx, `insert into requesters (email, name) values ($1, nullif($2,''))
+                on conflict (email) do update set name = coalesce(requesters.name, excluded.name)
+                returning id::text`, l.Email, l.Name).Scan(&requesterID); err != nil {
+				return err
+			}
+			if err := tx.QueryRow(ctx, `insert into ticket_guest_links (ticket_id, token_hash, requester_id, scope, created_by, expires_at)
+                select t.id, $2, $3, $4, $5, $6 from tickets t where t.id = $1
+                returning id::text, created_at`,
+				l.TicketID, secret.Hash(raw), requesterID, l.Scope, act.DBID(), l.ExpiresAt).Scan(&l.ID, &l.CreatedAt); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "ticket", l.TicketID, "guest_link_created",
+				map[string]any{"link_id": l.ID, "email": l.Email, "scope": l.Scope, "expires_at": l.ExpiresAt})
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+				return
+			}
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create guest link", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, l)
+	}
+}
+
+// RevokeLink disables a guest link immediately.
+func RevokeLink(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		ctx := c.Request.Context()
+		ticketID, linkID := c.Param("id"), c.Param("linkID")
+		var found bool
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			tag, err := tx.Exec(ctx, `update ticket_guest_links set revoked_at=now() where ticket_id=$1 and id=$2 and revoked_at is null`, ticketID, linkID)
+			if err != nil || tag.RowsAffected() == 0 {
+				return err
+			}
+			found = true
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "ticket", ticketID, "guest_link_revoked", map[string]any{"link_id": linkID})
+		})
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to revoke guest link", nil)
+			return
+		}
+		if !found {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "guest link not found", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// lookup resolves a raw token to its session. pgx.ErrNoRows is returned for
+// unknown, revoked and expired links.
+func lookup(ctx context.Context, db apppkg.DB, raw string) (Session, error) {
+	var s Session
+	err := db.QueryRow(ctx, `select l.id::text, l.ticket_id::text, l.requester_id::text, l.scope
+        from ticket_guest_links l
+        where l.token_hash=$1 and l.revoked_at is null and l.expires_at > now()`, secret.Hash(raw)).Scan(&s.LinkID, &s.TicketID, &s.RequesterID, &s.Scope)
+	return s, err
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/mark3748/helpdesk-go/internal/cors"
+)
+
+// CORSSettings holds the allowed origins managed from the admin settings.
+// They are added to the origins from ALLOWED_ORIGINS.
+type CORSSettings struct {
+	// Origins maps an environment (the ENV value) to its origin patterns; the
+	// "*" entry applies in every environment.
+	Origins map[string][]string `json:"origins"`
+	// MaxAge is how long browsers may cache a preflight response, in seconds.
+	// Zero uses DefaultCORSMaxAge.
+	MaxAge int `json:"max_age,omitempty"`
+}
+
+// DefaultCORSMaxAge is the preflight cache lifetime when none is configured.
+const DefaultCORSMaxAge = 600
+
+// maxCORSMaxAge caps max_age; browsers clamp larger values anyway.
+const maxCORSMaxAge = 86400
+
+// corsTTL bounds how stale the middleware's copy of the settings can get on
+// instances other than the one that saved them.
+const corsTTL = 30 * time.Second
+
+var corsCache struct {
+	mu sync.Mutex
+	at time.Time
+	s  CORSSettings
+}
+
+// CORSPolicy returns the settings-managed origin patterns for env and the
+// preflight max age. Results are cached briefly since it runs on every
+// request; on a load error the last good copy is kept.
+func CORSPolicy(ctx context.Context, env string) ([]string, int) {
+	s := cachedCORSSettings(ctx)
+	origins := append(append([]string(nil), s.Origins["*"]...), s.Origins[env]...)
+	maxAge := s.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultCORSMaxAge
+	}
+	return origins, maxAge
+}
+
+func cachedCORSSettings(ctx context.Context) CORSSettings {
+	corsCache.mu.Lock()
+	defer corsCache.mu.Unlock()
+	if dbStore == nil || time.Since(corsCache.at) < corsTTL {
+		return corsCache.s
+	}
+	s, err := loadSettings(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("load cors settings")
+	} else {
+		corsCache.s = s.CORS
+	}
+	corsCache.at = time.Now()
+	return corsCache.s
+}
+
+func setCachedCORSSettings(s CORSSettings) {
+	corsCache.mu.Lock()
+	corsCache.s = s
+	corsCache.at = time.Now()
+	corsCache.mu.Unlock()
+}
+
+// validateCORSSettings normalises s in place and returns the first problem.
+func validateCORSSettings(s *CORSSettings) error {
+	if s.MaxAge < 0 || s.MaxAge > maxCORSMaxAge {
+		return fmt.Errorf("max_age must be between 0 and %d", maxCORSMaxAge)
+	}
+	out := make(map[string][]string, len(s.Origins))
+	for env, patterns := range s.Origins {
+		env = strings.TrimSpace(env)
+		if env == "" {
+			return fmt.Errorf("origins: environment name is required")
+		}
+		for _, p := range patterns {
+			p = strings.TrimRight(strings.TrimSpace(p), "/")
+			if err := cors.Validate(p); err != nil {
+				return fmt.Errorf("origins.%s: %q: %v", env, p, err)
+			}
+			out[env] = append(out[env], p)
+		}
+	}
+	s.Origins = out
+	return nil
+}
+
+// SaveCORSSettings stores the settings-managed allowed origins. Changes take
+// effect immediately on this instance and within 30 seconds on the others.
+func SaveCORSSettings(c *gin.Context) {
+	if dbStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "db unavailable"})
+		return
+	}
+	var data CORSSettings
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateCORSSettings(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b, _ := json.Marshal(data)
+	if _, err := dbStore.Exec(c.Request.Context(), "update settings set cors=$1::jsonb where id=1", string(b)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	setCachedCORSSettings(data)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSaveCORSSettings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &fakeDB{}
+	InitSettings(context.Background(), db, "")
+	defer func() { dbStore = nil; setCachedCORSSettings(CORSSettings{}) }()
+
+	r := gin.New()
+	r.POST("/settings/cors", SaveCORSSettings)
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/settings/cors", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, bad := range []string{
+		`{"origins":{"prod":["*"]}}`,
+		`{"origins":{"prod":["https://example.com/app"]}}`,
+		`{"origins":{"":["https://example.com"]}}`,
+		`{"max_age":-1}`,
+	} {
+		if code := post(bad); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", bad, code)
+		}
+	}
+
+	if code := post(`{"origins":{"prod":["https://*.example.com/"],"*":["http://localhost:5173"]},"max_age":120}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := db.s.CORS.Origins["prod"]; len(got) != 1 || got[0] != "https://*.example.com" {
+		t.Fatalf("expected normalised origin stored, got %v", got)
+	}
+
+	origins, maxAge := CORSPolicy(context.Background(), "prod")
+	if !slices.Equal(origins, []string{"http://localhost:5173", "https://*.example.com"}) || maxAge != 120 {
+		t.Fatalf("unexpected prod policy %v %d", origins, maxAge)
+	}
+	origins, _ = CORSPolicy(context.Background(), "staging")
+	if !slices.Equal(origins, []string{"http://localhost:5173"}) {
+		t.Fatalf("expected only shared origins for staging, got %v", origins)
+	}
+}
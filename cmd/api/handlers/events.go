@@ -13,7 +13,9 @@ type RoleUser interface {
 	GetRoles() []string
 }
 
-// Events upgrades the connection to WebSocket and registers the client with the hub.
+// Events upgrades the connection to WebSocket and registers the client with
+// the hub. Connections are capped per user and kept alive with heartbeats as
+// configured on the hub.
 func Events(h *ws.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uVal, ok := c.Get("user")
@@ -26,6 +28,15 @@ func Events(h *ws.Hub) gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid user"})
 			return
 		}
+		userID := ""
+		if iu, ok := uVal.(interface{ GetID() string }); ok {
+			userID = iu.GetID()
+		}
+		if !h.Acquire(userID) {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many connections"})
+			return
+		}
+		defer h.Release(userID)
 		conn, err := ws.Upgrader.Upgrade(c.Writer, c.Request, nil)
 		if err != nil {
 			return
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/mark3748/helpdesk-go/internal/mailtmpl"
+)
+
+// ListMailTemplates returns the notification templates with their sample
+// data, for building previews.
+func ListMailTemplates(c *gin.Context) {
+	out := []gin.H{}
+	for _, name := range mailtmpl.Names() {
+		out = append(out, gin.H{"name": name, "sample": mailtmpl.Sample(name)})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// PreviewMail renders a template without sending it. Without data the
+// template's sample data is used. When a recipient is given, the response
+// also says where the worker would deliver it, which is the sandbox
+// address while sandbox mode is on.
+func PreviewMail(c *gin.Context) {
+	var in struct {
+		Template string         `json:"template"`
+		Data     map[string]any `json:"data"`
+		To       string         `json:"to"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !mailtmpl.Exists(in.Template) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown template", "templates": mailtmpl.Names()})
+		return
+	}
+	to := strings.TrimSpace(in.To)
+	if _, err := mail.ParseAddress(to); to != "" && err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recipient"})
+		return
+	}
+	data := in.Data
+	if data == nil {
+		data = mailtmpl.Sample(in.Template)
+	}
+	subject, body, err := mailtmpl.Render(in.Template, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "render failed: " + err.Error()})
+		return
+	}
+	out := gin.H{
+		"template": in.Template,
+		"subject":  strings.TrimSpace(subject),
+		"text":     body,
+		"html":     mailtmpl.HTML(body, ""),
+		"data":     data,
+	}
+	sandbox := strings.TrimSpace(MailSettings()["sandbox_to"])
+	if sandbox != "" {
+		out["sandbox_to"] = sandbox
+		out["subject"] = "[sandbox] " + strings.TrimSpace(subject)
+	}
+	if to != "" {
+		out["to"] = to
+		out["deliver_to"] = to
+		if sandbox != "" {
+			out["deliver_to"] = sandbox
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPreviewMail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/settings/mail/preview", PreviewMail)
+	do := func(body string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/settings/mail/preview", bytes.NewBufferString(body))
+		r.ServeHTTP(w, req)
+		var out map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &out)
+		return w.Code, out
+	}
+
+	code, out := do(`{"template":"ticket_resolved"}`)
+	if code != http.StatusOK || out["subject"] != "[TKT-1042] Ticket resolved" || !strings.Contains(out["text"].(string), "https://help.example.com/csat/sample") {
+		t.Fatalf("expected the sample rendering, got %d %v", code, out)
+	}
+	if !strings.HasPrefix(out["html"].(string), "<html>") {
+		t.Fatalf("expected an html body, got %v", out["html"])
+	}
+	code, out = do(`{"template":"ticket_comment","data":{"Number":"HD-7","Body":"<script>x</script>"}}`)
+	if code != http.StatusOK || !strings.Contains(out["text"].(string), "<script>x</script>") || strings.Contains(out["html"].(string), "<script>") {
+		t.Fatalf("expected supplied data, escaped in html: %d %v", code, out)
+	}
+	if code, _ := do(`{"template":"ticket_deleted"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown template, got %d", code)
+	}
+
+	prev := memMail
+	memMail = map[string]string{"sandbox_to": "staging@example.com"}
+	defer func() { memMail = prev }()
+	code, out = do(`{"template":"ticket_created","to":"alice@example.org"}`)
+	if code != http.StatusOK || out["deliver_to"] != "staging@example.com" || out["to"] != "alice@example.org" || out["subject"] != "[sandbox] [TKT-1042] Ticket created" {
+		t.Fatalf("expected sandbox delivery, got %d %v", code, out)
+	}
+}
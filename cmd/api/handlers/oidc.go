@@ -221,8 +221,18 @@ func OIDCCallback(a *app.App) gin.HandlerFunc {
 		}
 
 		// DB Sync (JIT)
-		userID, err := syncUser(c, a, externalID, username, email, name, s.OIDC.AutoOnboard)
-		if err != nil {
+		userID, err := syncUser(c, a, externalID, username, email, name, s.OIDC)
+		pending := errors.Is(err, errPendingApproval)
+		switch {
+		case pending:
+			// Refused after role sync below
+		case errors.Is(err, errJITDisabled):
+			app.AbortError(c, http.StatusForbidden, "onboarding_disabled", "no account exists for this user", nil)
+			return
+		case errors.Is(err, errDomainNotAllowed):
+			app.AbortError(c, http.StatusForbidden, "domain_not_allowed", "email domain is not allowed", nil)
+			return
+		case err != nil:
 			app.AbortError(c, http.StatusInternalServerError, "db_sync_error", "failed to sync user: "+err.Error(), nil)
 			return
 		}
@@ -234,12 +244,20 @@ func OIDCCallback(a *app.App) gin.HandlerFunc {
 			}
 		}
 
+		if pending {
+			app.AbortError(c, http.StatusForbidden, "pending_approval", "account is awaiting admin approval", nil)
+			return
+		}
+
 		// Set Session
 		secure := a.Cfg.Env == "prod"
 		if err := authpkg.SetSessionCookie(c, a.Cfg.AuthLocalSecret, externalID, email, name, secure); err != nil {
 			app.AbortError(c, http.StatusInternalServerError, "session_error", "failed to set session", nil)
 			return
 		}
+		if _, _, err := authpkg.RecordLogin(c, a.DB, userID, "oidc"); err != nil {
+			log.Error().Err(err).Msg("record login")
+		}
 
 		http.Redirect(c.Writer, c.Request, "/", http.StatusFound)
 	}
@@ -253,15 +271,28 @@ func generateRandomString(n int) (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func syncUser(c *gin.Context, a *app.App, externalID, username, email, name string, autoOnboard bool) (string, error) {
+// JIT provisioning outcomes that refuse the login.
+var (
+	errJITDisabled      = errors.New("user does not exist and auto-onboard is disabled")
+	errDomainNotAllowed = errors.New("email domain is not allowed to onboard")
+	errPendingApproval  = errors.New("user is pending admin approval")
+)
+
+// domainAllowed reports whether email may be onboarded under settings.
+func domainAllowed(settings OIDCSettings, email string) bool {
+	return authpkg.DomainAllowed(settings.AllowedDomains, email)
+}
+
+func syncUser(c *gin.Context, a *app.App, externalID, username, email, name string, settings OIDCSettings) (string, error) {
 	if a.DB == nil {
 		return "", errors.New("database not available")
 	}
 
 	// First check if user exists
 	var existingID string
-	checkQ := `SELECT id::text FROM users WHERE external_id = $1`
-	err := a.DB.QueryRow(c.Request.Context(), checkQ, externalID).Scan(&existingID)
+	var pending bool
+	checkQ := `SELECT id::text, pending_approval FROM users WHERE external_id = $1`
+	err := a.DB.QueryRow(c.Request.Context(), checkQ, externalID).Scan(&existingID, &pending)
 
 	if err == nil {
 		// User exists, update their information
@@ -270,12 +301,18 @@ func syncUser(c *gin.Context, a *app.App, externalID, username, email, name stri
 		if err := a.DB.QueryRow(c.Request.Context(), updateQ, externalID, email, name, username).Scan(&id); err != nil {
 			return "", err
 		}
+		if pending {
+			return id, errPendingApproval
+		}
 		return id, nil
 	}
 
-	// User doesn't exist - check if auto-onboard is enabled
-	if !autoOnboard {
-		return "", errors.New("user does not exist and auto-onboard is disabled")
+	// User doesn't exist - apply the JIT policy
+	if !settings.AutoOnboard {
+		return "", errJITDisabled
+	}
+	if !domainAllowed(settings, email) {
+		return "", errDomainNotAllowed
 	}
 
 	// Create new user
@@ -284,8 +321,8 @@ func syncUser(c *gin.Context, a *app.App, externalID, username, email, name stri
 	}
 
 	const insertQ = `
-    INSERT INTO users (external_id, username, email, display_name)
-    VALUES ($1, $2, $3, $4)
+    INSERT INTO users (external_id, username, email, display_name, active, pending_approval)
+    VALUES ($1, $2, $3, $4, NOT $5, $5)
     RETURNING id::text`
 
 	var id string
@@ -293,9 +330,12 @@ func syncUser(c *gin.Context, a *app.App, externalID, username, email, name stri
 
 	// Try up to 5 times with different suffixes if there's a username conflict
 	for attempt := 0; attempt < 5; attempt++ {
-		err = a.DB.QueryRow(c.Request.Context(), insertQ, externalID, username, email, name).Scan(&id)
+		err = a.DB.QueryRow(c.Request.Context(), insertQ, externalID, username, email, name, settings.RequireApproval).Scan(&id)
 		if err == nil {
-			// Success
+			grantRoles(c, a, id, settings.DefaultRoles)
+			if settings.RequireApproval {
+				return id, errPendingApproval
+			}
 			return id, nil
 		}
 
@@ -361,6 +401,20 @@ func syncRoles(c *gin.Context, a *app.App, userID string, groups []string, setti
 		}
 	}
 
+	// Roles every OIDC user gets
+	for _, r := range settings.DefaultRoles {
+		targetRoles[r] = true
+	}
+
+	// Admin-managed group mappings
+	mapped, err := authpkg.MapGroups(c.Request.Context(), a.DB, groups)
+	if err != nil {
+		return err
+	}
+	for _, r := range mapped {
+		targetRoles[r] = true
+	}
+
 	// Get current roles from database
 	currentRoles := make(map[string]string) // role_name -> role_id
 	rows, err := a.DB.Query(c.Request.Context(), `
@@ -382,12 +436,32 @@ func syncRoles(c *gin.Context, a *app.App, userID string, groups []string, setti
 	}
 
 	// Add new roles
+	var add []string
 	for r := range targetRoles {
 		// Skip if already has role
-		if _, exists := currentRoles[r]; exists {
-			continue
+		if _, exists := currentRoles[r]; !exists {
+			add = append(add, r)
+		}
+	}
+	grantRoles(c, a, userID, add)
+
+	// Remove roles that are no longer in target
+	for rname, rid := range currentRoles {
+		if !targetRoles[rname] {
+			_, err := a.DB.Exec(c.Request.Context(), "DELETE FROM user_roles WHERE user_id=$1 AND role_id=$2", userID, rid)
+			if err != nil {
+				log.Error().Err(err).Str("role", rname).Str("user_id", userID).Msg("failed to remove role")
+			}
 		}
+	}
+
+	return nil
+}
 
+// grantRoles assigns the named roles to a user, skipping roles that do not
+// exist.
+func grantRoles(c *gin.Context, a *app.App, userID string, roles []string) {
+	for _, r := range roles {
 		// Look up role id
 		var rid string
 		err := a.DB.QueryRow(c.Request.Context(), "SELECT id FROM roles WHERE name=$1", r).Scan(&rid)
@@ -402,16 +476,4 @@ func syncRoles(c *gin.Context, a *app.App, userID string, groups []string, setti
 		}
 		_, _ = a.DB.Exec(c.Request.Context(), "INSERT INTO user_roles (user_id, role_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, rid)
 	}
-
-	// Remove roles that are no longer in target
-	for rname, rid := range currentRoles {
-		if !targetRoles[rname] {
-			_, err := a.DB.Exec(c.Request.Context(), "DELETE FROM user_roles WHERE user_id=$1 AND role_id=$2", userID, rid)
-			if err != nil {
-				log.Error().Err(err).Str("role", rname).Str("user_id", userID).Msg("failed to remove role")
-			}
-		}
-	}
-
-	return nil
 }
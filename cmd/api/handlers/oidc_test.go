@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -14,11 +15,12 @@ import (
 
 // mockOIDCDB implements basic database operations for OIDC testing
 type mockOIDCDB struct {
-	users     map[string]mockUser // external_id -> user
-	roles     map[string]string   // role_name -> role_id
-	userRoles map[string][]string // user_id -> []role_id
-	queryErr  error
-	execErr   error
+	users      map[string]mockUser // external_id -> user
+	roles      map[string]string   // role_name -> role_id
+	userRoles  map[string][]string // user_id -> []role_id
+	groupRoles map[string][]string // group -> []role_name (oidc_group_roles)
+	queryErr   error
+	execErr    error
 }
 
 type mockUser struct {
@@ -27,6 +29,7 @@ type mockUser struct {
 	Username    string
 	Email       string
 	DisplayName string
+	Pending     bool
 }
 
 type mockRow struct {
@@ -45,6 +48,11 @@ func (r *mockRow) Scan(dest ...any) error {
 				*id = v.ID
 			}
 		}
+		if len(dest) > 1 {
+			if pending, ok := dest[1].(*bool); ok {
+				*pending = v.Pending
+			}
+		}
 	case string:
 		if len(dest) > 0 {
 			if s, ok := dest[0].(*string); ok {
@@ -165,6 +173,9 @@ func (db *mockOIDCDB) QueryRow(ctx context.Context, sql string, args ...any) pgx
 				Email:       email,
 				DisplayName: displayName,
 			}
+			if len(args) > 4 {
+				newUser.Pending, _ = args[4].(bool)
+			}
 			db.users[externalID] = newUser
 			return &mockRow{data: newUser}
 		}
@@ -192,6 +203,19 @@ func (db *mockOIDCDB) Query(ctx context.Context, sql string, args ...any) (pgx.R
 
 	sql = strings.ToLower(strings.TrimSpace(sql))
 
+	// Map IdP groups to roles
+	if strings.Contains(sql, "from oidc_group_roles") {
+		var data [][]interface{}
+		if groups, ok := args[0].([]string); ok {
+			for _, g := range groups {
+				for _, r := range db.groupRoles[g] {
+					data = append(data, []interface{}{r})
+				}
+			}
+		}
+		return &mockRows{data: data}, nil
+	}
+
 	// Get user roles
 	if strings.Contains(sql, "select r.id, r.name") && strings.Contains(sql, "from user_roles") {
 		if len(args) > 0 {
@@ -279,7 +303,7 @@ func TestSyncUserNewUserWithAutoOnboard(t *testing.T) {
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("GET", "/", nil)
 
-	userID, err := syncUser(c, a, "oidc:123", "testuser", "test@example.com", "Test User", true)
+	userID, err := syncUser(c, a, "oidc:123", "testuser", "test@example.com", "Test User", OIDCSettings{AutoOnboard: true})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -311,7 +335,7 @@ func TestSyncUserNewUserWithoutAutoOnboard(t *testing.T) {
 	c, _ := gin.CreateTestContext(w)
 	c.Request = httptest.NewRequest("GET", "/", nil)
 
-	_, err := syncUser(c, a, "oidc:123", "testuser", "test@example.com", "Test User", false)
+	_, err := syncUser(c, a, "oidc:123", "testuser", "test@example.com", "Test User", OIDCSettings{})
 	if err == nil {
 		t.Fatal("expected error when auto-onboard is disabled, got nil")
 	}
@@ -345,7 +369,7 @@ func TestSyncUserExistingUser(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 
 	// Auto-onboard should not matter for existing users
-	userID, err := syncUser(c, a, "oidc:123", "newusername", "new@example.com", "New Name", false)
+	userID, err := syncUser(c, a, "oidc:123", "newusername", "new@example.com", "New Name", OIDCSettings{})
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -382,7 +406,7 @@ func TestSyncUserUsernameConflict(t *testing.T) {
 	c.Request = httptest.NewRequest("GET", "/", nil)
 
 	// Try to create a new user with conflicting username
-	userID, err := syncUser(c, a, "oidc:newuser123", "testuser", "new@example.com", "New User", true)
+	userID, err := syncUser(c, a, "oidc:newuser123", "testuser", "new@example.com", "New User", OIDCSettings{AutoOnboard: true})
 	if err != nil {
 		t.Fatalf("expected username deduplication to handle conflict, got error: %v", err)
 	}
@@ -400,6 +424,49 @@ func TestSyncUserUsernameConflict(t *testing.T) {
 	}
 }
 
+func TestSyncUserJITPolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &mockOIDCDB{
+		users:     map[string]mockUser{"oidc:held": {ID: "user-held", ExternalID: "oidc:held", Pending: true}},
+		roles:     map[string]string{"requester": "role-requester"},
+		userRoles: make(map[string][]string),
+	}
+	a := &app.App{DB: db}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+
+	settings := OIDCSettings{
+		AutoOnboard:     true,
+		AllowedDomains:  []string{"@Example.com"},
+		DefaultRoles:    []string{"requester"},
+		RequireApproval: true,
+	}
+
+	if _, err := syncUser(c, a, "oidc:1", "mallory", "mallory@evil.test", "Mallory", settings); !errors.Is(err, errDomainNotAllowed) {
+		t.Fatalf("expected domain rejection, got %v", err)
+	}
+	if _, ok := db.users["oidc:1"]; ok {
+		t.Fatal("user created for disallowed domain")
+	}
+
+	id, err := syncUser(c, a, "oidc:2", "alice", "alice@example.com", "Alice", settings)
+	if !errors.Is(err, errPendingApproval) {
+		t.Fatalf("expected pending approval, got %v", err)
+	}
+	if !db.users["oidc:2"].Pending {
+		t.Fatal("expected user to be created pending")
+	}
+	if roles := db.userRoles[id]; len(roles) != 1 || roles[0] != "role-requester" {
+		t.Fatalf("expected default role, got %v", roles)
+	}
+
+	// Existing users stay blocked until approved.
+	if _, err := syncUser(c, a, "oidc:held", "held", "held@example.com", "Held", OIDCSettings{}); !errors.Is(err, errPendingApproval) {
+		t.Fatalf("expected pending approval for existing user, got %v", err)
+	}
+}
+
 func TestSyncRolesAddRoles(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := &mockOIDCDB{
@@ -537,6 +604,42 @@ func TestSyncRolesValueToRoles(t *testing.T) {
 	}
 }
 
+func TestSyncRolesGroupMappings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &mockOIDCDB{
+		users: map[string]mockUser{
+			"oidc:123": {ID: "user-123", ExternalID: "oidc:123"},
+		},
+		roles: map[string]string{
+			"agent":   "role-agent",
+			"manager": "role-manager",
+		},
+		userRoles: make(map[string][]string),
+		groupRoles: map[string][]string{
+			"Helpdesk Leads": {"agent", "manager"},
+		},
+	}
+
+	a := &app.App{DB: db}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+
+	// "Everyone" has no mapping and must be ignored.
+	if err := syncRoles(c, a, "user-123", []string{"Helpdesk Leads", "Everyone"}, OIDCSettings{}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	roles := db.userRoles["user-123"]
+	if len(roles) != 2 {
+		t.Fatalf("expected 2 roles, got %d: %v", len(roles), roles)
+	}
+	for _, roleID := range roles {
+		if roleID != "role-agent" && roleID != "role-manager" {
+			t.Errorf("unexpected role: %s", roleID)
+		}
+	}
+}
+
 func TestOIDCLoginStateCookieSecurity(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
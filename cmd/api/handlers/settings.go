@@ -25,18 +25,26 @@ type DB interface {
 }
 
 // OIDCSettings holds OpenID Connect configuration.
+//
+// AutoOnboard enables just-in-time (JIT) creation of users on first login.
+// AllowedDomains limits JIT creation to those email domains (empty allows
+// any), DefaultRoles are granted to every OIDC user alongside mapped roles,
+// and RequireApproval holds JIT users inactive until an admin approves them.
 type OIDCSettings struct {
-	Issuer         string              `json:"issuer"`
-	ClientID       string              `json:"client_id"`
-	ClientSecret   string              `json:"client_secret"`
-	RedirectURL    string              `json:"redirect_url"`
-	GroupClaimName string              `json:"group_claim_name"`
-	AdminGroup     string              `json:"admin_group"`
-	Scopes         string              `json:"scopes"`
-	UsernameClaim  string              `json:"username_claim"`
-	AutoOnboard    bool                `json:"auto_onboard"`
-	ClaimPath      string              `json:"claim_path"` // Deprecated: use GroupClaimName
-	ValueToRoles   map[string][]string `json:"value_to_roles"`
+	Issuer          string              `json:"issuer"`
+	ClientID        string              `json:"client_id"`
+	ClientSecret    string              `json:"client_secret"`
+	RedirectURL     string              `json:"redirect_url"`
+	GroupClaimName  string              `json:"group_claim_name"`
+	AdminGroup      string              `json:"admin_group"`
+	Scopes          string              `json:"scopes"`
+	UsernameClaim   string              `json:"username_claim"`
+	AutoOnboard     bool                `json:"auto_onboard"`
+	ClaimPath       string              `json:"claim_path"` // Deprecated: use GroupClaimName
+	ValueToRoles    map[string][]string `json:"value_to_roles"`
+	AllowedDomains  []string            `json:"allowed_domains,omitempty"`
+	DefaultRoles    []string            `json:"default_roles,omitempty"`
+	RequireApproval bool                `json:"require_approval,omitempty"`
 }
 
 // Settings represents persisted configuration values.
@@ -45,6 +53,7 @@ type Settings struct {
 	OIDC     OIDCSettings      `json:"oidc"`
 	Mail     map[string]string `json:"mail"`
 	Discord  map[string]string `json:"discord"`
+	CORS     CORSSettings      `json:"cors"`
 	LogPath  string            `json:"log_path"`
 	LastTest string            `json:"last_test"`
 }
@@ -53,6 +62,8 @@ type Settings struct {
 var (
 	dbStore    DB
 	startupLog string
+	// PasskeysEnabled is set by the API when passkey login is configured.
+	PasskeysEnabled bool
 	// EnqueueEmail is set by the API to enqueue email jobs.
 	EnqueueEmail func(ctx context.Context, to, template string, data interface{})
 	// in-memory fallback for tests when no DB is wired
@@ -61,7 +72,7 @@ var (
 )
 
 var mailSettingKeys = []string{
-	"smtp_host", "smtp_port", "smtp_user", "smtp_pass", "smtp_from",
+	"smtp_host", "smtp_port", "smtp_user", "smtp_pass", "smtp_from", "sandbox_to",
 	"imap_host", "imap_port", "imap_user", "imap_pass", "imap_folder",
 	"host", "port",
 }
@@ -75,6 +86,7 @@ func effectiveMailSettings(stored map[string]string) map[string]string {
 		"smtp_user":   os.Getenv("SMTP_USER"),
 		"smtp_pass":   os.Getenv("SMTP_PASS"),
 		"smtp_from":   os.Getenv("SMTP_FROM"),
+		"sandbox_to":  os.Getenv("MAIL_SANDBOX_TO"),
 		"imap_host":   os.Getenv("IMAP_HOST"),
 		"imap_port":   os.Getenv("IMAP_PORT"),
 		"imap_user":   os.Getenv("IMAP_USER"),
@@ -207,10 +219,10 @@ func loadSettings(ctx context.Context) (Settings, error) {
 		s.LogPath = startupLog
 		return s, nil
 	}
-	var storage, oidc, mail, discord []byte
+	var storage, oidc, mail, discord, cors []byte
 	var lt *time.Time
-	row := dbStore.QueryRow(ctx, "select storage, oidc, mail, discord, log_path, last_test from settings where id=1")
-	err := row.Scan(&storage, &oidc, &mail, &discord, &s.LogPath, &lt)
+	row := dbStore.QueryRow(ctx, "select storage, oidc, mail, discord, log_path, last_test, cors from settings where id=1")
+	err := row.Scan(&storage, &oidc, &mail, &discord, &s.LogPath, &lt, &cors)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			s.Storage = map[string]string{}
@@ -240,6 +252,9 @@ func loadSettings(ctx context.Context) (Settings, error) {
 	} else {
 		s.Discord = map[string]string{}
 	}
+	if len(cors) > 0 {
+		_ = json.Unmarshal(cors, &s.CORS)
+	}
 	if lt != nil {
 		s.LastTest = lt.Format(time.RFC3339)
 	}
@@ -257,10 +272,10 @@ func loadSettingsLegacy(ctx context.Context, db DB) (Settings, error) {
 		s.LogPath = startupLog
 		return s, nil
 	}
-	var storage, oidc, mail, discord []byte
+	var storage, oidc, mail, discord, cors []byte
 	var lt *time.Time
-	row := db.QueryRow(ctx, "select storage, oidc, mail, discord, log_path, last_test from settings where id=1")
-	err := row.Scan(&storage, &oidc, &mail, &discord, &s.LogPath, &lt)
+	row := db.QueryRow(ctx, "select storage, oidc, mail, discord, log_path, last_test, cors from settings where id=1")
+	err := row.Scan(&storage, &oidc, &mail, &discord, &s.LogPath, &lt, &cors)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			s.Storage = map[string]string{}
@@ -290,6 +305,9 @@ func loadSettingsLegacy(ctx context.Context, db DB) (Settings, error) {
 	} else {
 		s.Discord = map[string]string{}
 	}
+	if len(cors) > 0 {
+		_ = json.Unmarshal(cors, &s.CORS)
+	}
 	if lt != nil {
 		s.LastTest = lt.Format(time.RFC3339)
 	}
@@ -474,6 +492,7 @@ func GetSystemInfo(c *gin.Context) {
 		"mail_status":     cond(MailSettings()["smtp_host"] != "", "configured", "not_configured"),
 		"storage_status":  storageStatus,
 		"discord_status":  cond(discordConfigured(s.Discord), "configured", "not_configured"),
+		"passkey_status":  cond(PasskeysEnabled, "configured", "not_configured"),
 	})
 }
 
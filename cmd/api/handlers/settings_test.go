@@ -63,6 +63,12 @@ func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 					*p = nil
 				}
 			}
+			if len(dest) > 6 {
+				b, _ = json.Marshal(db.s.CORS)
+				if p, ok := dest[6].(*[]byte); ok {
+					*p = b
+				}
+			}
 			return nil
 		}}
 	}
@@ -106,6 +112,10 @@ func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.Com
 		case []byte:
 			_ = json.Unmarshal(v, &db.s.Discord)
 		}
+	case strings.Contains(s, "update settings set cors"):
+		if v, ok := args[0].(string); ok {
+			_ = json.Unmarshal([]byte(v), &db.s.CORS)
+		}
 	case strings.Contains(s, "update settings set last_test"):
 		if t, ok := args[0].(time.Time); ok {
 			db.s.LastTest = t.Format(time.RFC3339)
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// TestEnqueuedJobsDecodeInWorker checks that payloads produced by the API are
+// accepted by the decoder the worker uses, at the current schema version.
+func TestEnqueuedJobsDecodeInWorker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rdb.Close() })
+	ctx := context.Background()
+
+	hub := ws.NewHub(rdb)
+	go hub.Run(ctx)
+	cfg := Config{Env: "test", TestBypassAuth: true}
+	app := NewApp(cfg, &exportDB{count: exportSyncLimit + 1}, nil, nil, rdb, hub)
+
+	app.enqueueEmail(ctx, "req@example.com", "ticket_created", map[string]any{"Number": "HD-1"})
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/exports/tickets", strings.NewReader(`{"ids":["1","2"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+
+	var queued []string
+	for _, q := range jobs.Queues() {
+		items, _ := mr.List(q)
+		queued = append(queued, items...)
+	}
+	if len(queued) != 2 {
+		t.Fatalf("expected two queued jobs, got %d", len(queued))
+	}
+	for _, raw := range queued {
+		j, err := jobs.Decode([]byte(raw))
+		if err != nil {
+			t.Fatalf("decode %s: %v", raw, err)
+		}
+		var stored struct {
+			Version int `json:"version"`
+		}
+		_ = json.Unmarshal([]byte(raw), &stored)
+		if stored.Version != jobs.CurrentVersion(j.Type) {
+			t.Fatalf("%s enqueued at v%d, want v%d", j.Type, stored.Version, jobs.CurrentVersion(j.Type))
+		}
+		switch j.Type {
+		case jobs.TypeSendEmail:
+			var e jobs.Email
+			if err := json.Unmarshal(j.Data, &e); err != nil || e.To != "req@example.com" || e.Template != "ticket_created" {
+				t.Fatalf("unexpected email payload %+v: %v", e, err)
+			}
+		case jobs.TypeExportTickets:
+			var e jobs.ExportTickets
+			if err := json.Unmarshal(j.Data, &e); err != nil || len(e.IDs) != 2 || e.Requester != "test-user" {
+				t.Fatalf("unexpected export payload %+v: %v", e, err)
+			}
+			if j.ID == "" {
+				t.Fatalf("export job missing id")
+			}
+			if items, _ := mr.List(jobs.QueueBulk); len(items) != 1 {
+				t.Fatalf("expected export on the bulk queue")
+			}
+		default:
+			t.Fatalf("unexpected job type %q", j.Type)
+		}
+	}
+}
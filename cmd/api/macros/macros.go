@@ -0,0 +1,434 @@
+// Package macros keeps the canned responses agents insert into replies. A
+// macro's body may name ticket fields as {{ticket.number}} or
+// {{requester.name}}; applying it to a ticket fills them in. A macro is
+// personal, shared with a team, or shared with every agent.
+package macros
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// Scopes of a macro.
+const (
+	ScopePersonal = "personal"
+	ScopeTeam     = "team"
+	ScopeGlobal   = "global"
+)
+
+// maxName and maxBody bound a macro's name and body.
+const (
+	maxName = 100
+	maxBody = 20000
+)
+
+// Macro is a canned response.
+type Macro struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Body      string    `json:"body"`
+	Scope     string    `json:"scope"`
+	OwnerID   *string   `json:"owner_id,omitempty"`
+	TeamID    *string   `json:"team_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const macroCols = `m.id::text, m.name, m.body, m.scope, m.owner_id::text, m.team_id::text, m.created_at, m.updated_at`
+
+func scanMacro(row pgx.Row) (Macro, error) {
+	var m Macro
+	err := row.Scan(&m.ID, &m.Name, &m.Body, &m.Scope, &m.OwnerID, &m.TeamID, &m.CreatedAt, &m.UpdatedAt)
+	return m, err
+}
+
+// visible is the condition matching the macros, aliased m, that the user
+// $1 may see and apply: global macros, their own personal macros, and the
+// macros of their teams, or of every team when $2 (managers and admins).
+// Personal macros are never visible to anyone else.
+const visible = `(m.scope = 'global' or m.owner_id = $1::uuid or (m.scope = 'team' and
+        ($2 or exists (select 1 from team_members tm where tm.team_id = m.team_id and tm.user_id = $1::uuid))))`
+
+// manages reports whether the caller may manage team and global macros.
+func manages(c *gin.Context) bool {
+	v, _ := c.Get("user")
+	u, _ := v.(authpkg.AuthUser)
+	for _, r := range u.Roles {
+		if r == "manager" || r == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// canEdit reports whether the caller may change or delete m.
+func canEdit(c *gin.Context, db apppkg.DB, m Macro) (bool, error) {
+	switch m.Scope {
+	case ScopePersonal:
+		return m.OwnerID != nil && *m.OwnerID == authpkg.Actor(c).DBID(), nil
+	case ScopeTeam:
+		if manages(c) {
+			return true, nil
+		}
+		var ok bool
+		err := db.QueryRow(c.Request.Context(), `select exists (select 1 from team_members where team_id = $1 and user_id = $2::uuid)`,
+			*m.TeamID, authpkg.Actor(c).DBID()).Scan(&ok)
+		return ok, err
+	}
+	return manages(c), nil
+}
+
+// macroError maps constraint violations on macros to responses and reports
+// whether it wrote one.
+func macroError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	if !errors.As(err, &pge) {
+		return false
+	}
+	switch pge.Code {
+	case "23505":
+		apppkg.AbortError(c, http.StatusConflict, "name_taken", "a macro with this name exists", nil)
+	case "23503":
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"team_id": "unknown team"})
+	default:
+		return false
+	}
+	return true
+}
+
+// List returns the macros the caller may apply by name. scope narrows
+// them to one scope, team_id to one team's, and q to names containing q.
+func List(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := c.Query("scope")
+		if scope != "" && scope != ScopePersonal && scope != ScopeTeam && scope != ScopeGlobal {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"scope": "must be personal, team or global"})
+			return
+		}
+		teamID := c.Query("team_id")
+		if teamID != "" {
+			if _, err := uuid.Parse(teamID); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"team_id": "must be a uuid"})
+				return
+			}
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+macroCols+` from macros m where `+visible+`
+            and ($3 = '' or m.scope = $3) and ($4 = '' or m.team_id::text = $4)
+            and ($5 = '' or strpos(lower(m.name), lower($5)) > 0) order by lower(m.name), m.scope`,
+			authpkg.Actor(c).DBID(), manages(c), scope, teamID, strings.TrimSpace(c.Query("q")))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list macros", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Macro{}
+		for rows.Next() {
+			m, err := scanMacro(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list macros", nil)
+				return
+			}
+			out = append(out, m)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// macroInput is the writable part of a macro. The scope and team are set
+// when the macro is created; updates change only the name and body.
+type macroInput struct {
+	Name   string `json:"name"`
+	Body   string `json:"body"`
+	Scope  string `json:"scope"`
+	TeamID string `json:"team_id"`
+}
+
+// check normalizes in and returns the problems with it by field.
+func (in *macroInput) check() map[string]string {
+	errs := map[string]string{}
+	in.Name = strings.Join(strings.Fields(in.Name), " ")
+	switch {
+	case in.Name == "":
+		errs["name"] = "required"
+	case len([]rune(in.Name)) > maxName:
+		errs["name"] = fmt.Sprintf("at most %d characters", maxName)
+	}
+	in.Body = strings.TrimSpace(in.Body)
+	switch {
+	case in.Body == "":
+		errs["body"] = "required"
+	case len(in.Body) > maxBody:
+		errs["body"] = fmt.Sprintf("at most %d bytes", maxBody)
+	default:
+		if unknown := Unknown(in.Body); len(unknown) > 0 {
+			errs["body"] = "unknown variables: " + strings.Join(unknown, ", ")
+		}
+	}
+	in.TeamID = strings.TrimSpace(in.TeamID)
+	switch in.Scope {
+	case ScopeTeam:
+		if _, err := uuid.Parse(in.TeamID); err != nil {
+			errs["team_id"] = "required for team macros"
+		}
+	case ScopePersonal, ScopeGlobal:
+		if in.TeamID != "" {
+			errs["team_id"] = "only team macros have a team"
+		}
+	default:
+		errs["scope"] = "must be personal, team or global"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func macroAudit(m Macro) map[string]any {
+	return map[string]any{"name": m.Name, "body": m.Body, "scope": m.Scope}
+}
+
+// load returns the macro named by the id parameter if the caller may see
+// it, and pgx.ErrNoRows otherwise.
+func load(c *gin.Context, db apppkg.DB) (Macro, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return Macro{}, pgx.ErrNoRows
+	}
+	return scanMacro(db.QueryRow(c.Request.Context(), `select `+macroCols+` from macros m where m.id = $3 and `+visible,
+		authpkg.Actor(c).DBID(), manages(c), c.Param("id")))
+}
+
+// Create adds a macro, personal unless scope says otherwise. Team macros
+// may be added by the team's members, global ones by managers and admins.
+func Create(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := macroInput{Scope: ScopePersonal}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		me := authpkg.Actor(c).DBID()
+		if me == nil {
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", "macros need a user account", nil)
+			return
+		}
+		m := Macro{Scope: in.Scope}
+		switch in.Scope {
+		case ScopePersonal:
+			id := me.(string)
+			m.OwnerID = &id
+		case ScopeTeam:
+			m.TeamID = &in.TeamID
+		}
+		ctx := c.Request.Context()
+		ok, err := canEdit(c, a.DB, m)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create macro", nil)
+			return
+		}
+		if !ok {
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", "not allowed to add "+in.Scope+" macros", nil)
+			return
+		}
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			m, err = scanMacro(tx.QueryRow(ctx, `with m as (insert into macros (name, body, scope, owner_id, team_id, created_by)
+                values ($1, $2, $3, $4, $5, $6) returning *) select `+macroCols+` from m`,
+				in.Name, in.Body, in.Scope, m.OwnerID, m.TeamID, me))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "macro", m.ID, "macro_created", macroAudit(m))
+		})
+		if macroError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create macro", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, m)
+	}
+}
+
+// Update renames a macro or rewrites its body; only the fields present
+// change.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, m Macro
+		var errs map[string]string
+		forbidden := false
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			if before, err = load(c, tx); err != nil {
+				return err
+			}
+			ok, err := canEdit(c, tx, before)
+			if err != nil || !ok {
+				forbidden = !ok
+				return err
+			}
+			in := macroInput{Name: before.Name, Body: before.Body}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			// The scope and team stay as created.
+			in.Scope, in.TeamID = before.Scope, ""
+			if before.TeamID != nil {
+				in.TeamID = *before.TeamID
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			m, err = scanMacro(tx.QueryRow(ctx, `with m as (update macros set name = $2, body = $3, updated_at = now()
+                where id = $1 returning *) select `+macroCols+` from m`, before.ID, in.Name, in.Body))
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "macro", m.ID, "macro_updated", audit.Diff(macroAudit(before), macroAudit(m)))
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "macro not found", nil)
+		case forbidden:
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", "not allowed to change this macro", nil)
+		case errs != nil:
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+		case macroError(c, err):
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update macro", nil)
+		default:
+			c.JSON(http.StatusOK, m)
+		}
+	}
+}
+
+// Delete removes a macro.
+func Delete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		forbidden := false
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			before, err := load(c, tx)
+			if err != nil {
+				return err
+			}
+			ok, err := canEdit(c, tx, before)
+			if err != nil || !ok {
+				forbidden = !ok
+				return err
+			}
+			if _, err := tx.Exec(ctx, `delete from macros where id = $1`, before.ID); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "macro", before.ID, "macro_deleted", macroAudit(before))
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "macro not found", nil)
+		case forbidden:
+			apppkg.AbortError(c, http.StatusForbidden, "forbidden", "not allowed to delete this macro", nil)
+		case err != nil:
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete macro", nil)
+		default:
+			c.Status(http.StatusNoContent)
+		}
+	}
+}
+
+// ticketVars returns the values of Variables for a ticket, as seen by the
+// caller.
+func ticketVars(c *gin.Context, db apppkg.DB, ticketID string) (map[string]string, error) {
+	var number, title, status, category, requester, requesterEmail, assignee string
+	var priority int
+	err := db.QueryRow(c.Request.Context(), `select t.number, t.title, t.status, t.priority, coalesce(t.category, ''),
+            coalesce(nullif(r.name, ''), r.email, ''), coalesce(r.email, ''), coalesce(nullif(u.display_name, ''), u.email, '')
+        from tickets t
+        left join requesters r on r.id = t.requester_id
+        left join users u on u.id = t.assignee_id
+        where t.id = $1`, ticketID).Scan(&number, &title, &status, &priority, &category, &requester, &requesterEmail, &assignee)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := c.Get("user")
+	me, _ := v.(authpkg.AuthUser)
+	agent := me.DisplayName
+	if agent == "" {
+		agent = me.Email
+	}
+	return map[string]string{
+		"ticket.number":        number,
+		"ticket.title":         title,
+		"ticket.status":        status,
+		"ticket.priority":      strconv.Itoa(priority),
+		"ticket.category":      category,
+		"requester.name":       requester,
+		"requester.first_name": firstName(requester),
+		"requester.email":      requesterEmail,
+		"assignee.name":        assignee,
+		"agent.name":           agent,
+		"agent.first_name":     firstName(agent),
+		"agent.email":          me.Email,
+	}, nil
+}
+
+// Apply renders a macro for the ticket in the body. Nothing is posted: the
+// agent edits the text before sending it as a reply or note.
+func Apply(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			TicketID string `json:"ticket_id"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if _, err := uuid.Parse(in.TicketID); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_id": "must be a uuid"})
+			return
+		}
+		m, err := load(c, a.DB)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "macro not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load macro", nil)
+			return
+		}
+		vars, err := ticketVars(c, a.DB, in.TicketID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load ticket", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"macro_id": m.ID, "ticket_id": in.TicketID, "body": Render(m.Body, vars)})
+	}
+}
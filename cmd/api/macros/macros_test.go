@@ -0,0 +1,106 @@
+package macros
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestRender(t *testing.T) {
+	body := "Hi {{ requester.first_name }}, {{ticket.number}} is {{ticket.status}}. {{ticket.due}} {{agent.name}}"
+	if got := Unknown(body + " {{ticket.due}} {{foo.bar}}"); strings.Join(got, ",") != "foo.bar,ticket.due" {
+		t.Fatalf("unknown = %v", got)
+	}
+	got := Render(body, map[string]string{"requester.first_name": "Ada", "ticket.number": "TKT-7", "ticket.status": "Open"})
+	if want := "Hi Ada, TKT-7 is Open. {{ticket.due}} "; got != want {
+		t.Fatalf("rendered %q, want %q", got, want)
+	}
+}
+
+func TestMacroInputCheck(t *testing.T) {
+	in := macroInput{Name: " ", Body: "Thanks {{ticket.owner}}", Scope: ScopeTeam}
+	errs := in.check()
+	if len(errs) != 3 || !strings.Contains(errs["body"], "ticket.owner") || errs["team_id"] == "" {
+		t.Fatalf("errors = %v", errs)
+	}
+	in = macroInput{Name: "Thanks", Body: "Thanks", Scope: ScopeGlobal, TeamID: "f8a0c3b2-3e0b-4f8e-9a55-0a8b0f1f2c3d"}
+	if errs := in.check(); errs["team_id"] == "" {
+		t.Fatalf("team on a global macro accepted")
+	}
+	in = macroInput{Name: "  Password   reset ", Body: " Hi {{requester.name}} ", Scope: ScopePersonal}
+	if errs := in.check(); errs != nil {
+		t.Fatalf("valid macro rejected: %v", errs)
+	}
+	if in.Name != "Password reset" || in.Body != "Hi {{requester.name}}" {
+		t.Fatalf("not normalized: %+v", in)
+	}
+}
+
+func TestApply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const macroID, ticketID = "7d4c2a8e-0f53-4f3c-8d1e-5b8c6a2f9e10", "1b6f0f4e-9d2a-4c7b-8e3f-2a5d7c9b0e41"
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "from macros m") {
+					if args[2] != macroID {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string), *dest[1].(*string), *dest[3].(*string) = macroID, "Ack", ScopeGlobal
+					*dest[2].(*string) = "Hi {{requester.first_name}}, {{ticket.number}} (P{{ticket.priority}}) is with {{assignee.name}}. {{agent.first_name}}"
+					return nil
+				}
+				if args[0] != ticketID {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = "TKT-42", "VPN down", "Open"
+				*dest[3].(*int) = 2
+				*dest[5].(*string), *dest[6].(*string) = "Ada Lovelace", "ada@example.com"
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/macros/:id/apply", authpkg.Middleware(a), Apply(a))
+
+	apply := func(id, ticket string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/macros/"+id+"/apply", strings.NewReader(`{"ticket_id":"`+ticket+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := apply(macroID, ticketID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hi Ada, TKT-42 (P2) is with . Test"; out.Body != want {
+		t.Fatalf("rendered %q, want %q", out.Body, want)
+	}
+	if rr := apply("1f0e9c2d-6b5a-4d3c-9e8f-7a6b5c4d3e2f", ticketID); rr.Code != http.StatusNotFound {
+		t.Fatalf("unknown macro: %d", rr.Code)
+	}
+	if rr := apply(macroID, "1f0e9c2d-6b5a-4d3c-9e8f-7a6b5c4d3e2f"); rr.Code != http.StatusNotFound || !strings.Contains(rr.Body.String(), "ticket") {
+		t.Fatalf("unknown ticket: %d %s", rr.Code, rr.Body.String())
+	}
+	if rr := apply(macroID, "TKT-42"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("bad ticket id: %d", rr.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package macros
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// placeholderRe matches a variable such as {{ticket.number}}, allowing
+// spaces inside the braces.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z_]+\.[a-z_]+)\s*\}\}`)
+
+// Variables are the placeholders a macro body may use. agent is whoever
+// applies the macro; assignee is the ticket's current assignee.
+var Variables = []string{
+	"ticket.number",
+	"ticket.title",
+	"ticket.status",
+	"ticket.priority",
+	"ticket.category",
+	"requester.name",
+	"requester.first_name",
+	"requester.email",
+	"assignee.name",
+	"agent.name",
+	"agent.first_name",
+	"agent.email",
+}
+
+// Unknown returns the placeholders in body that are not Variables, sorted
+// and without repeats.
+func Unknown(body string) []string {
+	var out []string
+	for _, m := range placeholderRe.FindAllStringSubmatch(body, -1) {
+		if !slices.Contains(Variables, m[1]) && !slices.Contains(out, m[1]) {
+			out = append(out, m[1])
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Render replaces the placeholders in body with their values in vars.
+// Variables without a value render empty; anything else is left as
+// written.
+func Render(body string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(body, func(s string) string {
+		name := placeholderRe.FindStringSubmatch(s)[1]
+		if !slices.Contains(Variables, name) {
+			return s
+		}
+		return vars[name]
+	})
+}
+
+// firstName is the first word of name.
+func firstName(name string) string {
+	if f := strings.Fields(name); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
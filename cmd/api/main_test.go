@@ -10,18 +10,23 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	appcore "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	handlers "github.com/mark3748/helpdesk-go/cmd/api/handlers"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	"github.com/mark3748/helpdesk-go/internal/jwks"
+	"github.com/mark3748/helpdesk-go/internal/s3"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/redis/go-redis/v9"
@@ -148,6 +153,12 @@ func TestCORSPreflight(t *testing.T) {
 		if got := rr.Header().Get("Vary"); got != "Origin" {
 			t.Fatalf("expected Vary header Origin, got %q", got)
 		}
+		if got := rr.Header().Values("Vary"); !slices.Contains(got, "Access-Control-Request-Headers") {
+			t.Fatalf("expected Vary to include Access-Control-Request-Headers, got %q", got)
+		}
+		if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Fatalf("expected Access-Control-Max-Age 600, got %q", got)
+		}
 	})
 
 	t.Run("preflight disallowed", func(t *testing.T) {
@@ -238,6 +249,17 @@ func TestReadyzFailures(t *testing.T) {
 		}
 	})
 
+	t.Run("object store encryption", func(t *testing.T) {
+		setMail(map[string]string{"host": "", "port": ""})
+		cfg := Config{Env: "test", MinIOBucket: "b", Storage: s3.Layout{s3.ClassExports: {Encryption: s3.EncryptionSSES3}}}
+		app := newTestApp(cfg, readyzDB{}, &appcore.FsObjectStore{Base: t.TempDir()}, nil)
+		rr := httptest.NewRecorder()
+		app.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rr.Code == http.StatusOK || !strings.Contains(rr.Body.String(), "object_store_encryption") {
+			t.Fatalf("expected the filesystem store to fail encryption, got %d %s", rr.Code, rr.Body.String())
+		}
+	})
+
 	t.Run("smtp", func(t *testing.T) {
 		setMail(map[string]string{"host": "127.0.0.1", "port": "1"})
 		app := newTestApp(Config{Env: "test", MinIOBucket: "b"}, readyzDB{}, nil, nil)
@@ -265,6 +287,23 @@ func TestReadyzFailures(t *testing.T) {
 		}
 	})
 
+	t.Run("jwks stale", func(t *testing.T) {
+		setMail(map[string]string{"host": "", "port": ""})
+		app := newTestApp(Config{Env: "test", MinIOBucket: "b", JWKSMaxStaleMinutes: 60}, readyzDB{}, nil, nil)
+		// A cache that has never refreshed is stale.
+		app.jwks = jwks.New("http://127.0.0.1:1/jwks", nil)
+		rr := httptest.NewRecorder()
+		app.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if rr.Code == http.StatusOK || !strings.Contains(rr.Body.String(), "jwks") {
+			t.Fatalf("expected jwks failure, got %d %s", rr.Code, rr.Body.String())
+		}
+		rr = httptest.NewRecorder()
+		app.r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz/details", nil))
+		if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), `"configured":true`) || !strings.Contains(rr.Body.String(), `"ok":false`) {
+			t.Fatalf("expected stale jwks details, got %d %s", rr.Code, rr.Body.String())
+		}
+	})
+
 	t.Run("object store bucket auto-create", func(t *testing.T) {
 		setMail(map[string]string{"host": "", "port": ""})
 		dir := t.TempDir()
@@ -346,6 +385,88 @@ func TestRequireRoleMultiple(t *testing.T) {
 		}
 	})
 }
+
+func TestReportRoutesByRole(t *testing.T) {
+	cfg := Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret"}
+	app := newTestApp(cfg, nil, nil, nil)
+	for role, want := range map[string]int{
+		"admin":     http.StatusOK,
+		"manager":   http.StatusOK,
+		"agent":     http.StatusOK,
+		"requester": http.StatusForbidden,
+	} {
+		tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": role, "roles": []string{role}}).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/metrics/sla", nil)
+		req.AddCookie(&http.Cookie{Name: "hd_auth", Value: tok})
+		app.r.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", role, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestSLAVersionsRequiresStaff(t *testing.T) {
+	cfg := Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret"}
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		return &testutil.MockRows{}, nil
+	}}
+	app := newTestApp(cfg, db, nil, nil)
+	// Staff reach the handler, which finds no such policy.
+	for role, want := range map[string]int{
+		"admin":     http.StatusNotFound,
+		"manager":   http.StatusNotFound,
+		"agent":     http.StatusNotFound,
+		"requester": http.StatusForbidden,
+	} {
+		tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": role, "roles": []string{role}}).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/slas/11111111-1111-1111-1111-111111111111/versions", nil)
+		req.AddCookie(&http.Cookie{Name: "hd_auth", Value: tok})
+		app.r.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d: %s", role, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestRequesterCannotReadTags(t *testing.T) {
+	cfg := Config{Env: "test", AuthMode: "local", AuthLocalSecret: "secret"}
+	db := &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		return &testutil.MockRows{}, nil
+	}}
+	app := newTestApp(cfg, db, nil, nil)
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "ann", "email": "ann@acme.io", "roles": []string{"requester"}}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tickets themselves carry tags only for staff; see tickets.TestMask.
+	checked := 0
+	for _, r := range app.r.Routes() {
+		if r.Method != http.MethodGet || !strings.Contains(r.Path, "tag") {
+			continue
+		}
+		checked++
+		path := strings.NewReplacer(":id", "11111111-1111-1111-1111-111111111111").Replace(r.Path)
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.AddCookie(&http.Cookie{Name: "hd_auth", Value: tok})
+		app.r.ServeHTTP(rr, req)
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", r.Path, rr.Code)
+		}
+	}
+	if checked < 3 {
+		t.Fatalf("expected the tag routes to be mounted, found %d", checked)
+	}
+}
+
 func TestEnqueueEmail_JSONMarshalError(t *testing.T) {
 	// Create a minimal app instance without Redis (enqueueEmail will return early if q is nil)
 	app := &App{}
@@ -477,7 +598,7 @@ func (db *csatTestDB) Query(ctx context.Context, sql string, args ...interface{}
 }
 
 func (db *csatTestDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
-	return nil
+	return &fakeRow{}
 }
 
 func (db *csatTestDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
@@ -1144,3 +1265,83 @@ func TestUpdateRequester_OnlyRequesterRole(t *testing.T) {
 		t.Fatalf("expected 404 for non-requester, got %d body=%s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestChatCORSUsesWidgetOrigins(t *testing.T) {
+	const site = "https://shop.example.net"
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			n := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { n++; return n == 1 && strings.Contains(sql, "from chat_widgets") },
+				ScanFunc: func(dest ...interface{}) error { *dest[0].(*[]string) = []string{site}; return nil },
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				if !strings.Contains(sql, "from chat_widgets") {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string), *dest[1].(*string) = "w1", "Website"
+				*dest[3].(*[]string) = []string{site}
+				return nil
+			}}
+		},
+	}
+	app := newTestApp(Config{Env: "test", AllowedOrigins: []string{"https://helpdesk.example.com"}}, db, nil, nil)
+	do := func(method, path, origin string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		req.Header.Set("Origin", origin)
+		req.Header.Set("X-Chat-Key", "cw_site")
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		app.r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/chat/conversations", "/api/chat/messages", "/chat/attachments", "/api/chat/stream"} {
+		rr := do(http.MethodOptions, path, site)
+		if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != site {
+			t.Fatalf("%s: expected the widget's site to pass preflight, got %d %v", path, rr.Code, rr.Header())
+		}
+		if rr.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Fatalf("%s: chat preflight must not allow credentials", path)
+		}
+		if rr := do(http.MethodOptions, path, "https://helpdesk.example.com"); rr.Code != http.StatusForbidden {
+			t.Fatalf("%s: ALLOWED_ORIGINS must not open chat endpoints, got %d", path, rr.Code)
+		}
+	}
+	// A key used from a globally allowed origin that the widget does not list.
+	if rr := do(http.MethodPost, "/chat/conversations", "https://helpdesk.example.com"); rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("expected 403 without CORS headers, got %d %v", rr.Code, rr.Header())
+	}
+	// Widget origins stay out of the global allowlist.
+	if rr := do(http.MethodGet, "/healthz", site); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected widget origin to be refused elsewhere, got %d", rr.Code)
+	}
+}
+
+func TestCORSWildcardOrigin(t *testing.T) {
+	cfg := Config{Env: "test", AllowedOrigins: []string{"https://*.example.com"}}
+	app := newTestApp(cfg, nil, nil, nil)
+
+	for origin, want := range map[string]int{
+		"https://portal.example.com":      http.StatusOK,
+		"https://a.portal.example.com":    http.StatusOK,
+		"https://example.com":             http.StatusForbidden,
+		"http://portal.example.com":       http.StatusForbidden,
+		"https://portal.example.com.evil": http.StatusForbidden,
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.Header.Set("Origin", origin)
+		app.r.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", origin, want, rr.Code)
+		}
+		if want == http.StatusOK && rr.Header().Get("Access-Control-Allow-Origin") != origin {
+			t.Fatalf("%s: expected origin echoed, got %q", origin, rr.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package meta describes the running API to clients so generated SDKs and the
+// UI can configure themselves instead of hard-coding enums and limits.
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	assetspkg "github.com/mark3748/helpdesk-go/cmd/api/assets"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/buildinfo"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+// Capabilities is the GET /meta/capabilities document.
+type Capabilities struct {
+	Version      string          `json:"version"`
+	AuthMode     string          `json:"auth_mode"`
+	Features     map[string]bool `json:"features"`
+	Limits       Limits          `json:"limits"`
+	Enums        Enums           `json:"enums"`
+	CustomFields CustomFields    `json:"custom_fields"`
+}
+
+// Limits are the size and rate limits the server enforces.
+type Limits struct {
+	// AttachmentMaxBytes is null when attachments are unbounded.
+	AttachmentMaxBytes *int64 `json:"attachment_max_bytes"`
+	AvatarMaxBytes     int64  `json:"avatar_max_bytes"`
+	// RateLimits holds requests per minute by route group; unlimited groups
+	// are absent.
+	RateLimits map[string]int `json:"rate_limits"`
+}
+
+// Option is a numeric enum value with its display label.
+type Option struct {
+	Value int    `json:"value"`
+	Label string `json:"label"`
+}
+
+// Enums are the accepted values of enumerated fields.
+type Enums struct {
+	TicketStatuses  []string `json:"ticket_statuses"`
+	PausedStatuses  []string `json:"paused_statuses"`
+	Priorities      []Option `json:"priorities"`
+	Urgencies       []int    `json:"urgencies"`
+	TicketSources   []string `json:"ticket_sources"`
+	Roles           []string `json:"roles"`
+	AssetStatuses   []string `json:"asset_statuses"`
+	AssetConditions []string `json:"asset_conditions"`
+	WebhookEvents   []Option `json:"webhook_events"`
+}
+
+// CustomFields describes custom field schemas. Tickets accept any JSON
+// object as custom_json; asset categories carry their own field definitions
+// in the customfields schema format, whose field types are listed in Types.
+type CustomFields struct {
+	Types           []customfields.Type   `json:"types"`
+	Ticket          map[string]any        `json:"ticket"`
+	AssetCategories []AssetCategoryFields `json:"asset_categories"`
+}
+
+// AssetCategoryFields is one asset category's custom field definitions.
+type AssetCategoryFields struct {
+	ID     string          `json:"id"`
+	Name   string          `json:"name"`
+	Fields json.RawMessage `json:"fields"`
+}
+
+// Load assembles the capabilities document. Roles and asset categories are
+// read from the database when one is configured.
+func Load(ctx context.Context, a *apppkg.App) (Capabilities, error) {
+	store, _ := a.ResolveStore(ctx)
+	authMode := a.Cfg.AuthMode
+	if authMode == "" {
+		authMode = "oidc"
+	}
+	out := Capabilities{
+		Version:  buildinfo.RuntimeVersion(),
+		AuthMode: authMode,
+		Features: map[string]bool{
+			"attachments":       store != nil,
+			"avatar_uploads":    store != nil,
+			"local_auth":        authMode == "local",
+			"oidc":              a.Cfg.OIDCIssuer != "",
+			"passkeys":          authpkg.PasskeysEnabled(a),
+			"realtime":          a.Q != nil,
+			"reply_suggestions": a.Cfg.SuggestionsProvider != "",
+		},
+		Limits: Limits{
+			AvatarMaxBytes: avatars.MaxBytes,
+			RateLimits:     map[string]int{},
+		},
+		Enums: Enums{
+			TicketStatuses: ticketspkg.Statuses,
+			PausedStatuses: ticketspkg.PausedStatuses,
+			Urgencies:      []int{1, 2, 3, 4},
+			TicketSources:  ticketspkg.Sources,
+			AssetStatuses: []string{
+				string(assetspkg.AssetStatusOrdered), string(assetspkg.AssetStatusReceived),
+				string(assetspkg.AssetStatusActive), string(assetspkg.AssetStatusInactive),
+				string(assetspkg.AssetStatusMaintenance), string(assetspkg.AssetStatusRetired),
+				string(assetspkg.AssetStatusDisposed),
+			},
+			AssetConditions: []string{
+				string(assetspkg.AssetConditionExcellent), string(assetspkg.AssetConditionGood),
+				string(assetspkg.AssetConditionFair), string(assetspkg.AssetConditionPoor),
+				string(assetspkg.AssetConditionBroken),
+			},
+		},
+		CustomFields: CustomFields{
+			Types:           customfields.Types,
+			Ticket:          map[string]any{"type": "object"},
+			AssetCategories: []AssetCategoryFields{},
+		},
+	}
+	if n := a.Cfg.AttachmentMaxBytes; n > 0 {
+		out.Limits.AttachmentMaxBytes = &n
+	}
+	for group, limit := range a.Cfg.RateLimits {
+		out.Limits.RateLimits[group] = limit
+	}
+	for p := int16(1); p <= 4; p++ {
+		out.Enums.Priorities = append(out.Enums.Priorities, Option{Value: int(p), Label: ticketspkg.PriorityLabels[p]})
+	}
+	for _, e := range webhook.Events {
+		out.Enums.WebhookEvents = append(out.Enums.WebhookEvents, Option{Value: e.Bit, Label: e.Name})
+	}
+	if a.DB == nil {
+		for r := range authpkg.BuiltinRoles {
+			out.Enums.Roles = append(out.Enums.Roles, r)
+		}
+		sort.Strings(out.Enums.Roles)
+		return out, nil
+	}
+
+	rows, err := a.DB.Query(ctx, `select name from roles order by name`)
+	if err != nil {
+		return out, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return out, err
+		}
+		out.Enums.Roles = append(out.Enums.Roles, name)
+	}
+	rows.Close()
+
+	rows, err = a.DB.Query(ctx, `select id::text, name, custom_fields from asset_categories order by name`)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f AssetCategoryFields
+		var fields []byte
+		if err := rows.Scan(&f.ID, &f.Name, &fields); err != nil {
+			return out, err
+		}
+		f.Fields = json.RawMessage(fields)
+		if len(f.Fields) == 0 {
+			f.Fields = json.RawMessage(`{}`)
+		}
+		out.CustomFields.AssetCategories = append(out.CustomFields.AssetCategories, f)
+	}
+	return out, rows.Err()
+}
+
+// GetCapabilities returns the capabilities document.
+func GetCapabilities(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caps, err := Load(c.Request.Context(), a)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load capabilities", nil)
+			return
+		}
+		c.JSON(http.StatusOK, caps)
+	}
+}
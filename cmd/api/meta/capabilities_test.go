@@ -0,0 +1,75 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestGetCapabilities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			var rows [][]any
+			switch {
+			case strings.Contains(sql, "from roles"):
+				rows = [][]any{{"admin"}, {"auditor"}}
+			case strings.Contains(sql, "from asset_categories"):
+				rows = [][]any{{"c1", "Laptops", []byte(`{"ram_gb":{"type":"number"}}`)}}
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(rows) },
+				ScanFunc: func(dest ...any) error {
+					for j, v := range rows[i] {
+						switch d := dest[j].(type) {
+						case *string:
+							*d = v.(string)
+						case *[]byte:
+							*d = v.([]byte)
+						}
+					}
+					return nil
+				},
+			}, nil
+		},
+	}
+	cfg := apppkg.Config{Env: "test", TestBypassAuth: true, AuthMode: "local", AttachmentMaxBytes: 10 << 20, RateLimits: map[string]int{"login": 5}}
+	a := apppkg.NewApp(cfg, db, nil, nil, nil)
+	a.R.GET("/meta/capabilities", authpkg.Middleware(a), GetCapabilities(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/meta/capabilities", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var caps Capabilities
+	if err := json.Unmarshal(rr.Body.Bytes(), &caps); err != nil {
+		t.Fatal(err)
+	}
+	if caps.AuthMode != "local" || !caps.Features["local_auth"] || caps.Features["attachments"] {
+		t.Fatalf("unexpected features %+v", caps.Features)
+	}
+	if caps.Limits.AttachmentMaxBytes == nil || *caps.Limits.AttachmentMaxBytes != 10<<20 || caps.Limits.RateLimits["login"] != 5 {
+		t.Fatalf("unexpected limits %+v", caps.Limits)
+	}
+	if len(caps.Enums.TicketStatuses) == 0 || caps.Enums.TicketStatuses[0] != "New" || len(caps.Enums.Priorities) != 4 || caps.Enums.Priorities[0].Label != "Critical" {
+		t.Fatalf("unexpected enums %+v", caps.Enums)
+	}
+	if len(caps.Enums.Roles) != 2 || caps.Enums.Roles[1] != "auditor" {
+		t.Fatalf("expected roles from the database, got %v", caps.Enums.Roles)
+	}
+	if len(caps.CustomFields.AssetCategories) != 1 || !strings.Contains(string(caps.CustomFields.AssetCategories[0].Fields), "ram_gb") {
+		t.Fatalf("unexpected custom fields %+v", caps.CustomFields)
+	}
+}
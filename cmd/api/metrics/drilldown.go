@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/reports"
+)
+
+// Drill-down page sizes.
+const (
+	drilldownLimit    = 100
+	maxDrilldownLimit = 500
+)
+
+// A measure selects the tickets behind one number of a report. It returns a
+// condition on tickets aliased t with its parameters appended to args, or
+// false once it has aborted the request over an invalid parameter.
+type measure func(c *gin.Context, args []any) (string, []any, bool)
+
+// fixed is a measure with no parameters of its own.
+func fixed(cond string) measure {
+	return func(c *gin.Context, args []any) (string, []any, bool) { return cond, args, true }
+}
+
+// slaTickets are the resolved tickets counted by the SLA report; %s narrows
+// them by their clock tsc and policy version sp.
+const slaTickets = `t.status = 'Resolved' and exists (select 1 from ticket_sla_clocks tsc
+        join sla_policy_versions sp on sp.id = tsc.policy_version_id where tsc.ticket_id = t.id%s)`
+
+var (
+	slaTotal    = fixed(fmt.Sprintf(slaTickets, ""))
+	slaMet      = fixed(fmt.Sprintf(slaTickets, " and tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000"))
+	slaBreached = fixed(fmt.Sprintf(slaTickets, " and (tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000) is not true"))
+)
+
+// responseTickets are the tickets counted by the SLA report's response
+// attainment; %s narrows them like slaTickets.
+const responseTickets = `exists (select 1 from ticket_sla_clocks tsc
+        join sla_policy_versions sp on sp.id = tsc.policy_version_id where tsc.ticket_id = t.id and ` + reports.ResponseCounted + `%s)`
+
+var (
+	responseTotal    = fixed(fmt.Sprintf(responseTickets, ""))
+	responseMet      = fixed(fmt.Sprintf(responseTickets, " and "+reports.ResponseMet))
+	responseBreached = fixed(fmt.Sprintf(responseTickets, " and not ("+reports.ResponseMet+")"))
+)
+
+// sampled selects the tickets timed by metric, optionally one ?priority=
+// row of its percentiles.
+func sampled(metric string) measure {
+	return func(c *gin.Context, args []any) (string, []any, bool) {
+		cond := `t.id in (select s.id from (` + reports.SamplesSQL(metric, "true") + `) s)`
+		if v := c.Query("priority"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil || p < 1 || p > 4 {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid priority", map[string]string{"priority": "must be 1-4"})
+				return "", nil, false
+			}
+			args = append(args, p)
+			cond += fmt.Sprintf(" and t.priority = $%d", len(args))
+		}
+		return cond, args, true
+	}
+}
+
+// created selects the tickets created in the report's range, or on one
+// ?day= of the daily volume.
+func created(c *gin.Context, args []any) (string, []any, bool) {
+	v := c.Query("day")
+	if v == "" {
+		return "true", args, true
+	}
+	day, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid day", map[string]string{"day": "must be YYYY-MM-DD"})
+		return "", nil, false
+	}
+	args = append(args, day, day.AddDate(0, 0, 1))
+	return fmt.Sprintf("t.created_at >= $%d and t.created_at < $%d", len(args)-1, len(args)), args, true
+}
+
+// labelled selects the tickets whose col is ?label=, "unknown" matching
+// tickets without one, as in the sentiment report.
+func labelled(col string) measure {
+	return func(c *gin.Context, args []any) (string, []any, bool) {
+		label := strings.TrimSpace(c.Query("label"))
+		if label == "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "label is required", map[string]string{"label": "required"})
+			return "", nil, false
+		}
+		args = append(args, label)
+		return fmt.Sprintf("coalesce(%s, 'unknown') = $%d", col, len(args)), args, true
+	}
+}
+
+// labelledAnd is labelled narrowed further by cond.
+func labelledAnd(col, cond string) measure {
+	return func(c *gin.Context, args []any) (string, []any, bool) {
+		where, args, ok := labelled(col)(c, args)
+		if !ok {
+			return "", nil, false
+		}
+		return where + " and " + cond, args, true
+	}
+}
+
+// resolvedByCaller selects the tickets the caller resolved.
+func resolvedByCaller(c *gin.Context, args []any) (string, []any, bool) {
+	id, _ := caller(c)
+	args = append(args, id)
+	return fmt.Sprintf("t.status = 'Resolved' and t.assignee_id::text = $%d", len(args)), args, true
+}
+
+// The measures each report drills into, by name.
+var (
+	slaMeasures = map[string]measure{
+		"total": slaTotal, "met": slaMet, "breached": slaBreached,
+		"response_total": responseTotal, "response_met": responseMet, "response_breached": responseBreached,
+	}
+	resolutionMeasures = map[string]measure{
+		reports.MetricResolution:    sampled(reports.MetricResolution),
+		reports.MetricFirstResponse: sampled(reports.MetricFirstResponse),
+	}
+	volumeMeasures    = map[string]measure{"created": created}
+	dashboardMeasures = map[string]measure{
+		"sla_total":    slaTotal,
+		"sla_met":      slaMet,
+		"sla_breached": slaBreached,
+		"resolution":   sampled(reports.MetricResolution),
+		"created":      created,
+	}
+	sentimentMeasures    = map[string]measure{"language": labelled("t.language"), "sentiment": labelled("t.sentiment")}
+	channelMeasures      = map[string]measure{"channel": labelled("t.source")}
+	channelStatsMeasures = map[string]measure{
+		"created":  labelled("t.source"),
+		"open":     labelledAnd("t.source", "t.status not in ('Resolved', 'Closed')"),
+		"resolved": labelledAnd("t.source", "t.status = 'Resolved'"),
+	}
+	agentMeasures = map[string]measure{"resolved": resolvedByCaller}
+)
+
+func encodeDrilldownCursor(at time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(at.UTC().Format(time.RFC3339Nano) + "," + id))
+}
+
+func decodeDrilldownCursor(cur string) (time.Time, string, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cur)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	at, id, ok := strings.Cut(string(raw), ",")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil || uuid.Validate(id) != nil {
+		return time.Time{}, "", false
+	}
+	return t, id, true
+}
+
+// drilldown answers a report's ?drilldown=true: rather than the numbers it
+// returns the ids of the tickets behind the one named by ?measure= (def by
+// default) under the report's filter, newest first, paginated by cursor
+// and limit. Ticket ids are not summarized, so it always reads live
+// tickets. It reports whether the request was a drill-down.
+func drilldown(c *gin.Context, a *app.App, f Filter, measures map[string]measure, def string) bool {
+	if v := c.Query("drilldown"); v != "true" && v != "1" {
+		return false
+	}
+	name := c.DefaultQuery("measure", def)
+	m, ok := measures[name]
+	if !ok {
+		names := make([]string, 0, len(measures))
+		for n := range measures {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid measure", map[string]string{"measure": "must be one of " + strings.Join(names, ", ")})
+		return true
+	}
+	limit := drilldownLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDrilldownLimit {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid limit", map[string]string{"limit": fmt.Sprintf("must be 1-%d", maxDrilldownLimit)})
+			return true
+		}
+		limit = n
+	}
+	var afterAt time.Time
+	var afterID string
+	if v := c.Query("cursor"); v != "" {
+		if afterAt, afterID, ok = decodeDrilldownCursor(v); !ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid cursor", map[string]string{"cursor": "invalid"})
+			return true
+		}
+	}
+	cond, args, ok := m(c, nil)
+	if !ok {
+		return true
+	}
+	if a.DB == nil {
+		c.JSON(http.StatusOK, gin.H{"measure": name, "ticket_ids": []string{}, "next_cursor": ""})
+		return true
+	}
+	where, args := f.and(ticketCols, cond, args...)
+	if afterID != "" {
+		args = append(args, afterAt, afterID)
+		where += fmt.Sprintf(" and (t.created_at, t.id) < ($%d, $%d::uuid)", len(args)-1, len(args))
+	}
+	rows, err := a.DB.Query(c.Request.Context(), `select t.id::text, t.created_at from tickets t where `+where+`
+               order by t.created_at desc, t.id desc limit `+strconv.Itoa(limit+1), args...)
+	if err != nil {
+		log.Error().Err(err).Str("measure", name).Msg("report drill-down")
+		app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load tickets", nil)
+		return true
+	}
+	defer rows.Close()
+	ids := []string{}
+	var last time.Time
+	next := ""
+	for rows.Next() {
+		var id string
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			log.Error().Err(err).Str("measure", name).Msg("report drill-down")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load tickets", nil)
+			return true
+		}
+		if len(ids) == limit {
+			next = encodeDrilldownCursor(last, ids[len(ids)-1])
+			break
+		}
+		ids = append(ids, id)
+		last = at
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Str("measure", name).Msg("report drill-down")
+		app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load tickets", nil)
+		return true
+	}
+	c.JSON(http.StatusOK, gin.H{"measure": name, "ticket_ids": ids, "next_cursor": next, "source": source(false)})
+	return true
+}
@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// maxRange bounds from/to so a single report cannot scan the whole history.
+const maxRange = 366 * 24 * time.Hour
+
+// Filter scopes a report to one team, one queue and a range of ticket
+// creation times. Zero fields are not applied; To is exclusive.
+type Filter struct {
+	TeamID  string
+	QueueID string
+	From    time.Time
+	To      time.Time
+}
+
+// parseTime accepts a date (YYYY-MM-DD) or an RFC 3339 timestamp. A date
+// used as an upper bound covers the whole day.
+func parseTime(v string, upper bool) (time.Time, error) {
+	if d, err := time.Parse(time.DateOnly, v); err == nil {
+		if upper {
+			d = d.AddDate(0, 0, 1)
+		}
+		return d, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// ParseFilter reads the team, queue, from and to query parameters. It aborts
+// the request with 400 and returns false when one is invalid.
+func ParseFilter(c *gin.Context) (Filter, bool) {
+	var f Filter
+	for _, p := range []struct {
+		name string
+		dst  *string
+	}{{"team", &f.TeamID}, {"queue", &f.QueueID}} {
+		v := strings.TrimSpace(c.Query(p.name))
+		if v == "" {
+			continue
+		}
+		if _, err := uuid.Parse(v); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid "+p.name, map[string]string{p.name: "must be a UUID"})
+			return f, false
+		}
+		*p.dst = v
+	}
+	for _, p := range []struct {
+		name  string
+		dst   *time.Time
+		upper bool
+	}{{"from", &f.From, false}, {"to", &f.To, true}} {
+		v := strings.TrimSpace(c.Query(p.name))
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v, p.upper)
+		if err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid "+p.name, map[string]string{p.name: "must be YYYY-MM-DD or RFC 3339"})
+			return f, false
+		}
+		*p.dst = t
+	}
+	if !f.From.IsZero() && !f.To.IsZero() {
+		if !f.To.After(f.From) {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "to must be after from", nil)
+			return f, false
+		}
+		if f.To.Sub(f.From) > maxRange {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "date range exceeds 366 days", nil)
+			return f, false
+		}
+	}
+	return f, true
+}
+
+// columns names what a Filter compares against.
+type columns struct{ team, queue, created string }
+
+var (
+	ticketCols  = columns{"t.team_id", "t.queue_id", "t.created_at"}
+	summaryCols = columns{"team_id", "queue_id", "day"}
+)
+
+// Where returns the conditions for f against tickets aliased t, appending
+// its parameters to args.
+func (f Filter) Where(args []any) ([]string, []any) {
+	return f.where(ticketCols, args)
+}
+
+func (f Filter) where(cols columns, args []any) ([]string, []any) {
+	var conds []string
+	add := func(expr string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf(expr, len(args)))
+	}
+	if f.TeamID != "" {
+		add(cols.team+" = $%d", f.TeamID)
+	}
+	if f.QueueID != "" {
+		add(cols.queue+" = $%d", f.QueueID)
+	}
+	if !f.From.IsZero() {
+		add(cols.created+" >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add(cols.created+" < $%d", f.To)
+	}
+	return conds, args
+}
+
+// and joins base with f's conditions against cols. args are base's own
+// parameters; f's are numbered after them.
+func (f Filter) and(cols columns, base string, args ...any) (string, []any) {
+	conds, args := f.where(cols, args)
+	return strings.Join(append([]string{base}, conds...), " and "), args
+}
+
+// wholeDays reports whether f's bounds fall on UTC midnight, so the daily
+// summaries can answer it exactly.
+func (f Filter) wholeDays() bool {
+	for _, t := range []time.Time{f.From, f.To} {
+		if !t.IsZero() && !t.Equal(t.UTC().Truncate(24*time.Hour)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// LeaderboardEntry is one agent's standing on their team's leaderboard.
+// Rank is omitted when the caller may only see their own row.
+type LeaderboardEntry struct {
+	Rank          int      `json:"rank,omitempty"`
+	UserID        string   `json:"user_id"`
+	Name          string   `json:"name"`
+	Resolved      int      `json:"resolved"`
+	CSATScore     *float64 `json:"csat_score"`
+	CSATResponses int      `json:"csat_responses"`
+	AvgResponseMS *float64 `json:"avg_response_ms"`
+}
+
+// caller returns the requesting user's id and whether they manage teams,
+// which lets them see every row of a leaderboard.
+func caller(c *gin.Context) (id string, manager bool) {
+	u, _ := c.Get("user")
+	if iu, ok := u.(interface{ GetID() string }); ok {
+		id = iu.GetID()
+	}
+	if ru, ok := u.(interface{ GetRoles() []string }); ok {
+		roles := ru.GetRoles()
+		manager = slices.Contains(roles, "manager") || slices.Contains(roles, "admin")
+	}
+	return id, manager
+}
+
+// Leaderboard ranks a team's agents by resolved tickets, with their CSAT
+// score and average first-response time, scoped by queue and date like the
+// other reports. Teams opt in: "off" disables the board, "self" shows each
+// agent only their own row and "team" shows everyone the full ranking.
+// Managers and admins always see the full ranking. Without ?team= the
+// caller's own team is used when they belong to exactly one.
+func Leaderboard(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		userID, manager := caller(c)
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"team_id": f.TeamID, "visibility": "off", "agents": []LeaderboardEntry{}})
+			return
+		}
+		ctx := c.Request.Context()
+		teamID := f.TeamID
+		if teamID == "" {
+			var n int
+			err := a.DB.QueryRow(ctx, `select count(*), coalesce(min(team_id::text), '') from team_members where user_id::text = $1`, userID).Scan(&n, &teamID)
+			if err != nil {
+				log.Error().Err(err).Msg("leaderboard team lookup")
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load leaderboard", nil)
+				return
+			}
+			if n != 1 {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "team is required", map[string]string{"team": "required unless you belong to exactly one team"})
+				return
+			}
+		}
+		var visibility string
+		var member bool
+		err := a.DB.QueryRow(ctx, `select t.leaderboard, exists (select 1 from team_members tm where tm.team_id = t.id and tm.user_id::text = $2)
+               from teams t where t.id::text = $1`, teamID, userID).Scan(&visibility, &member)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "team not found", nil)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("leaderboard team lookup")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load leaderboard", nil)
+			return
+		}
+		if visibility == "off" {
+			app.AbortError(c, http.StatusForbidden, "leaderboard_disabled", "leaderboard is not enabled for this team", nil)
+			return
+		}
+		if !manager && !member {
+			app.AbortError(c, http.StatusForbidden, "forbidden", "not a member of this team", nil)
+			return
+		}
+		selfOnly := visibility == "self" && !manager
+
+		// Agents are ranked on all their tickets, not just the team's, so the
+		// team only selects whose rows appear.
+		scope := f
+		scope.TeamID = ""
+		args := []any{teamID}
+		members := "tm.team_id::text = $1"
+		if selfOnly {
+			args = append(args, userID)
+			members += " and u.id::text = $2"
+		}
+		conds, args := scope.where(ticketCols, args)
+		join := strings.Join(append([]string{"t.assignee_id = u.id"}, conds...), " and ")
+		rows, err := a.DB.Query(ctx, `select u.id::text, coalesce(nullif(u.display_name, ''), u.email, ''),
+               count(t.id) filter (where t.status = 'Resolved'),
+               avg(case t.csat_score when 'good' then 1.0 when 'bad' then 0.0 end)::float8,
+               count(t.csat_score),
+               avg(tsc.response_elapsed_ms) filter (where tsc.response_elapsed_ms > 0)::float8
+               from team_members tm
+               join users u on u.id = tm.user_id
+               left join tickets t on `+join+`
+               left join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+               where `+members+`
+               group by u.id, u.display_name, u.email
+               order by 3 desc, 2`, args...)
+		if err != nil {
+			log.Error().Err(err).Msg("leaderboard query")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load leaderboard", nil)
+			return
+		}
+		defer rows.Close()
+		agents := []LeaderboardEntry{}
+		for rows.Next() {
+			var e LeaderboardEntry
+			var csat, resp sql.NullFloat64
+			if err := rows.Scan(&e.UserID, &e.Name, &e.Resolved, &csat, &e.CSATResponses, &resp); err != nil {
+				log.Error().Err(err).Msg("leaderboard scan")
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load leaderboard", nil)
+				return
+			}
+			if csat.Valid {
+				e.CSATScore = &csat.Float64
+			}
+			if resp.Valid {
+				e.AvgResponseMS = &resp.Float64
+			}
+			agents = append(agents, e)
+		}
+		if err := rows.Err(); err != nil {
+			log.Error().Err(err).Msg("leaderboard rows")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load leaderboard", nil)
+			return
+		}
+		if !selfOnly {
+			// Agents tied on resolved tickets share a rank.
+			for i := range agents {
+				if i > 0 && agents[i].Resolved == agents[i-1].Resolved {
+					agents[i].Rank = agents[i-1].Rank
+				} else {
+					agents[i].Rank = i + 1
+				}
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "visibility": visibility, "agents": agents})
+	}
+}
@@ -1,13 +1,16 @@
 package metrics
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/reports"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -35,6 +38,27 @@ var (
 		},
 		[]string{"route"},
 	)
+	ReplySuggestionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reply_suggestions_total",
+			Help: "Number of AI reply suggestion requests by outcome.",
+		},
+		[]string{"outcome"},
+	)
+	CommentTranslationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "comment_translations_total",
+			Help: "Number of comment translation requests by outcome.",
+		},
+		[]string{"outcome"},
+	)
+	ReplySuggestionTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "reply_suggestion_tokens_total",
+			Help: "Tokens used by AI reply suggestions, by kind (prompt or completion).",
+		},
+		[]string{"kind"},
+	)
 	registerOnce sync.Once
 )
 
@@ -46,98 +70,309 @@ func RegisterCounters() {
 			AuthFailuresTotal,
 			AttachmentsUploadedTotal,
 			RateLimitRejectionsTotal,
+			ReplySuggestionsTotal,
+			ReplySuggestionTokensTotal,
+			CommentTranslationsTotal,
 		)
 	})
 }
 
-func SLA(a *app.App) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ctx := c.Request.Context()
-		if a.DB == nil {
-			c.JSON(http.StatusOK, gin.H{"total": 0, "met": 0, "sla_attainment": 0.0})
-			return
-		}
-		var met, total int
-		err := a.DB.QueryRow(ctx, `
+// DayCount is the number of tickets created on one day.
+type DayCount struct {
+	Day   time.Time `json:"day"`
+	Count int       `json:"count"`
+}
+
+// SummaryMaxAge is how old the worker's summary tables may be before reports
+// fall back to live queries. It should exceed the worker's refresh interval.
+var SummaryMaxAge = 2 * time.Hour
+
+// useSummary reports whether f can be answered from the summary tables:
+// they were refreshed within SummaryMaxAge and f's range is whole days.
+func useSummary(ctx context.Context, db app.DB, f Filter) bool {
+	if !f.wholeDays() {
+		return false
+	}
+	at, err := reports.RefreshedAt(ctx, db)
+	return err == nil && !at.IsZero() && time.Since(at) <= SummaryMaxAge
+}
+
+// source names where a report was read from, returned as "source".
+func source(summary bool) string {
+	if summary {
+		return "summary"
+	}
+	return "live"
+}
+
+func slaAttainment(ctx context.Context, db app.DB, f Filter, summary bool) (met, total int, err error) {
+	if summary {
+		where, args := f.and(summaryCols, "true")
+		err = db.QueryRow(ctx, `select coalesce(sum(sla_met), 0), coalesce(sum(sla_total), 0) from `+reports.TableSLA+` where `+where, args...).Scan(&met, &total)
+		return met, total, err
+	}
+	where, args := f.and(ticketCols, "t.status = 'Resolved'")
+	err = db.QueryRow(ctx, `
                select
                        count(*) filter (where tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000) as met,
                        count(*) as total
                from ticket_sla_clocks tsc
                join tickets t on t.id = tsc.ticket_id
-               join sla_policies sp on sp.id = tsc.policy_id
-               where t.status = 'Resolved'
-       `).Scan(&met, &total)
+               join sla_policy_versions sp on sp.id = tsc.policy_version_id
+               where `+where, args...).Scan(&met, &total)
+	return met, total, err
+}
+
+// responseAttainment counts the tickets answered within their response
+// target against those whose response SLA is decided (see
+// reports.ResponseCounted).
+func responseAttainment(ctx context.Context, db app.DB, f Filter, summary bool) (met, total int, err error) {
+	if summary {
+		where, args := f.and(summaryCols, "true")
+		err = db.QueryRow(ctx, `select coalesce(sum(response_met), 0), coalesce(sum(response_total), 0) from `+reports.TableSLA+` where `+where, args...).Scan(&met, &total)
+		return met, total, err
+	}
+	where, args := f.and(ticketCols, reports.ResponseCounted)
+	err = db.QueryRow(ctx, `
+               select
+                       count(*) filter (where `+reports.ResponseMet+`) as met,
+                       count(*) as total
+               from ticket_sla_clocks tsc
+               join tickets t on t.id = tsc.ticket_id
+               join sla_policy_versions sp on sp.id = tsc.policy_version_id
+               where `+where, args...).Scan(&met, &total)
+	return met, total, err
+}
+
+func avgResolution(ctx context.Context, db app.DB, f Filter, summary bool) (float64, error) {
+	var avg sql.NullFloat64
+	if summary {
+		where, args := f.and(summaryCols, "true")
+		err := db.QueryRow(ctx, `select sum(resolution_ms_sum)::float8 / nullif(sum(resolution_count), 0) from `+reports.TableSLA+` where `+where, args...).Scan(&avg)
+		return avg.Float64, err
+	}
+	where, args := f.and(ticketCols, "t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0")
+	err := db.QueryRow(ctx, `
+               select avg(tsc.resolution_elapsed_ms)
+               from ticket_sla_clocks tsc
+               join tickets t on t.id = tsc.ticket_id
+               where `+where, args...).Scan(&avg)
+	return avg.Float64, err
+}
+
+// dailyVolume returns ticket counts per day, newest first: the last 30 days
+// with tickets, or every day in f's date range.
+func dailyVolume(ctx context.Context, db app.DB, f Filter, summary bool) ([]DayCount, error) {
+	limit := 30
+	if !f.From.IsZero() {
+		limit = int(maxRange / (24 * time.Hour))
+	}
+	q := `
+               select date_trunc('day', t.created_at)::date as day, count(*)
+               from tickets t
+               where %s
+               group by day
+               order by day desc
+               limit %d`
+	cols := ticketCols
+	if summary {
+		q = `select day, sum(created)::int from ` + reports.TableVolume + ` where %s group by day order by day desc limit %d`
+		cols = summaryCols
+	}
+	where, args := f.and(cols, "true")
+	rows, err := db.Query(ctx, fmt.Sprintf(q, where, limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []DayCount{}
+	for rows.Next() {
+		var dc DayCount
+		if err := rows.Scan(&dc.Day, &dc.Count); err == nil {
+			out = append(out, dc)
+		}
+	}
+	return out, nil
+}
+
+// Percentiles are one breakdown of a timed metric. Priority and TeamID are
+// unset on rows that are not broken down by them.
+type Percentiles struct {
+	Priority *int16  `json:"priority,omitempty"`
+	TeamID   *string `json:"team_id,omitempty"`
+	Samples  int     `json:"samples"`
+	P50MS    float64 `json:"p50_ms"`
+	P90MS    float64 `json:"p90_ms"`
+	P99MS    float64 `json:"p99_ms"`
+}
+
+// percentileSummary reports whether the cached percentiles can answer f.
+// They cover every ticket, so only a team may be picked.
+func percentileSummary(f Filter, summary bool) bool {
+	return summary && f.QueueID == "" && f.From.IsZero() && f.To.IsZero()
+}
+
+// timePercentiles returns the percentiles of each timed metric. Filtering
+// by team drops the team breakdown, which would only repeat the totals.
+func timePercentiles(ctx context.Context, db app.DB, f Filter, summary bool) (map[string][]Percentiles, error) {
+	out := map[string][]Percentiles{}
+	for _, metric := range reports.Metrics {
+		var q string
+		var args []any
+		switch {
+		case summary && f.TeamID != "":
+			q = `select priority, null::text, samples, p50_ms, p90_ms, p99_ms from ` + reports.TablePercentiles + `
+               where metric = $1 and team_id = $2 order by priority nulls first`
+			args = []any{metric, f.TeamID}
+		case summary:
+			q = `select priority, team_id::text, samples, p50_ms, p90_ms, p99_ms from ` + reports.TablePercentiles + `
+               where metric = $1 order by team_id nulls first, priority nulls first`
+			args = []any{metric}
+		default:
+			where, wargs := f.and(ticketCols, "true")
+			q = `select p.priority, p.team_id::text, p.samples, p.p50_ms, p.p90_ms, p.p99_ms from (` + reports.PercentilesSQL(metric, where, f.TeamID == "") + `) p`
+			args = wargs
+		}
+		rows, err := db.Query(ctx, q, args...)
+		if err != nil {
+			return nil, err
+		}
+		list := []Percentiles{}
+		for rows.Next() {
+			var p Percentiles
+			if err := rows.Scan(&p.Priority, &p.TeamID, &p.Samples, &p.P50MS, &p.P90MS, &p.P99MS); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			list = append(list, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		out[metric] = list
+	}
+	return out, nil
+}
+
+func attainment(met, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(met) / float64(total)
+}
+
+// SLA reports resolution SLA attainment, and under "response" the share of
+// tickets answered within their response target. Like the other ticket
+// reports it accepts team, queue, from and to (see ParseFilter) and reads
+// the worker's summary tables when they are fresh.
+func SLA(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, slaMeasures, "total") {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"total": 0, "met": 0, "sla_attainment": 0.0,
+				"response": gin.H{"total": 0, "met": 0, "sla_attainment": 0.0}})
+			return
+		}
+		ctx := c.Request.Context()
+		summary := useSummary(ctx, a.DB, f)
+		met, total, err := slaAttainment(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "sla query"})
 			return
 		}
-		attainment := 0.0
-		if total > 0 {
-			attainment = float64(met) / float64(total)
+		respMet, respTotal, err := responseAttainment(ctx, a.DB, f, summary)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "sla query"})
+			return
 		}
-		c.JSON(http.StatusOK, gin.H{"total": total, "met": met, "sla_attainment": attainment})
+		c.JSON(http.StatusOK, gin.H{
+			"total": total, "met": met, "sla_attainment": attainment(met, total),
+			"response": gin.H{"total": respTotal, "met": respMet, "sla_attainment": attainment(respMet, respTotal)},
+			"source":   source(summary),
+		})
 	}
 }
 
+// Resolution reports the average resolution time with first response and
+// resolution percentiles by priority and team. The percentiles come from
+// the worker's cache only for reports over every ticket, since they cannot
+// be combined from the daily summaries; "percentiles_source" says which.
 func Resolution(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx := c.Request.Context()
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, resolutionMeasures, reports.MetricResolution) {
+			return
+		}
 		if a.DB == nil {
-			c.JSON(http.StatusOK, gin.H{"avg_resolution_ms": 0})
+			c.JSON(http.StatusOK, gin.H{"avg_resolution_ms": 0, "percentiles": gin.H{reports.MetricFirstResponse: []Percentiles{}, reports.MetricResolution: []Percentiles{}}})
 			return
 		}
-		var avg sql.NullFloat64
-		err := a.DB.QueryRow(ctx, `
-               select avg(tsc.resolution_elapsed_ms)
-               from ticket_sla_clocks tsc
-               join tickets t on t.id = tsc.ticket_id
-               where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0
-       `).Scan(&avg)
+		ctx := c.Request.Context()
+		summary := useSummary(ctx, a.DB, f)
+		avg, err := avgResolution(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "resolution query"})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"avg_resolution_ms": avg.Float64})
+		cached := percentileSummary(f, summary)
+		pct, err := timePercentiles(ctx, a.DB, f, cached)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "percentile query"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"avg_resolution_ms":  avg,
+			"percentiles":        pct,
+			"percentiles_source": source(cached),
+			"source":             source(summary),
+		})
 	}
 }
 
 func TicketVolume(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx := c.Request.Context()
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, volumeMeasures, "created") {
+			return
+		}
 		if a.DB == nil {
 			c.JSON(http.StatusOK, gin.H{"daily": []any{}})
 			return
 		}
-		rows, err := a.DB.Query(ctx, `
-               select date_trunc('day', created_at)::date as day, count(*)
-               from tickets
-               group by day
-               order by day desc
-               limit 30
-       `)
+		ctx := c.Request.Context()
+		summary := useSummary(ctx, a.DB, f)
+		out, err := dailyVolume(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "volume query"})
 			return
 		}
-		defer rows.Close()
-		type dayCount struct {
-			Day   time.Time `json:"day"`
-			Count int       `json:"count"`
-		}
-		out := []dayCount{}
-		for rows.Next() {
-			var dc dayCount
-			if err := rows.Scan(&dc.Day, &dc.Count); err == nil {
-				out = append(out, dc)
-			}
-		}
-		c.JSON(http.StatusOK, gin.H{"daily": out})
+		c.JSON(http.StatusOK, gin.H{"daily": out, "source": source(summary)})
 	}
 }
 
 // Dashboard aggregates key ticket metrics for quick dashboard display.
 func Dashboard(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, dashboardMeasures, "created") {
+			return
+		}
 		ctx := c.Request.Context()
 		if a.DB == nil {
 			c.JSON(http.StatusOK, gin.H{
@@ -147,71 +382,245 @@ func Dashboard(a *app.App) gin.HandlerFunc {
 			})
 			return
 		}
-		var met, total int
-		err := a.DB.QueryRow(ctx, `
-               select
-                       count(*) filter (where tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000) as met,
-                       count(*) as total
-               from ticket_sla_clocks tsc
-               join tickets t on t.id = tsc.ticket_id
-               join sla_policies sp on sp.id = tsc.policy_id
-               where t.status = 'Resolved'
-       `).Scan(&met, &total)
+		summary := useSummary(ctx, a.DB, f)
+		met, total, err := slaAttainment(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "sla query"})
 			return
 		}
-		attainment := 0.0
-		if total > 0 {
-			attainment = float64(met) / float64(total)
-		}
-
-		var avg sql.NullFloat64
-		err = a.DB.QueryRow(ctx, `
-               select avg(tsc.resolution_elapsed_ms)
-               from ticket_sla_clocks tsc
-               join tickets t on t.id = tsc.ticket_id
-               where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0
-       `).Scan(&avg)
+		avg, err := avgResolution(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "resolution query"})
 			return
 		}
-
-		rows, err := a.DB.Query(ctx, `
-               select date_trunc('day', created_at)::date as day, count(*)
-               from tickets
-               group by day
-               order by day desc
-               limit 30
-       `)
+		vol, err := dailyVolume(ctx, a.DB, f, summary)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "volume query"})
 			return
 		}
-		defer rows.Close()
-		type dayCount struct {
-			Day   time.Time `json:"day"`
-			Count int       `json:"count"`
-		}
-		vol := []dayCount{}
-		for rows.Next() {
-			var dc dayCount
-			if err := rows.Scan(&dc.Day, &dc.Count); err == nil {
-				vol = append(vol, dc)
-			}
-		}
 		c.JSON(http.StatusOK, gin.H{
-			"sla":               gin.H{"total": total, "met": met, "sla_attainment": attainment},
-			"avg_resolution_ms": avg.Float64,
+			"sla":               gin.H{"total": total, "met": met, "sla_attainment": attainment(met, total)},
+			"avg_resolution_ms": avg,
 			"volume":            vol,
+			"source":            source(summary),
 		})
 	}
 }
 
-// Agent returns per-agent quick metrics snapshot
+// Agent returns the calling agent's resolved tickets and average resolution
+// time, scoped like the other reports.
 func Agent(a *app.App) gin.HandlerFunc {
-	return func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, agentMeasures, "resolved") {
+			return
+		}
+		var agentID string
+		if u, ok := c.Get("user"); ok {
+			if iu, ok := u.(interface{ GetID() string }); ok {
+				agentID = iu.GetID()
+			}
+		}
+		if a.DB == nil || agentID == "" {
+			c.JSON(http.StatusOK, gin.H{"resolved": 0, "avg_resolution_ms": 0})
+			return
+		}
+		ctx := c.Request.Context()
+		summary := useSummary(ctx, a.DB, f)
+		var resolved int
+		var avg sql.NullFloat64
+		var err error
+		if summary {
+			where, args := f.and(summaryCols, "assignee_id::text = $1", agentID)
+			err = a.DB.QueryRow(ctx, `select coalesce(sum(resolved), 0)::int, sum(resolution_ms_sum)::float8 / nullif(sum(resolution_count), 0)
+               from `+reports.TableAgent+` where `+where, args...).Scan(&resolved, &avg)
+		} else {
+			where, args := f.and(ticketCols, "t.status = 'Resolved' and t.assignee_id::text = $1", agentID)
+			err = a.DB.QueryRow(ctx, `select count(*), avg(tsc.resolution_elapsed_ms) filter (where tsc.resolution_elapsed_ms > 0)
+               from tickets t left join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+               where `+where, args...).Scan(&resolved, &avg)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "agent query"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"resolved": resolved, "avg_resolution_ms": avg.Float64, "source": source(summary)})
+	}
+}
+
+// LabelCount is the number of tickets sharing one language or sentiment.
+type LabelCount struct {
+	Label string `json:"label"`
+	Count int    `json:"count"`
+}
+
+// countBy returns ticket counts grouped by expr, largest first. Tickets
+// without a value are counted under "unknown".
+func countBy(ctx context.Context, db app.DB, f Filter, expr string) ([]LabelCount, error) {
+	where, args := f.and(ticketCols, "true")
+	rows, err := db.Query(ctx, `select coalesce(`+expr+`, 'unknown') as label, count(*)
+               from tickets t where `+where+` group by label order by count(*) desc, label`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []LabelCount{}
+	for rows.Next() {
+		var lc LabelCount
+		if err := rows.Scan(&lc.Label, &lc.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, lc)
+	}
+	return out, rows.Err()
+}
+
+// Sentiment breaks tickets down by the language and sentiment detected by
+// the worker's enrichment step, scoped like the other reports. It always
+// reads live tickets.
+func Sentiment(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, sentimentMeasures, "sentiment") {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"languages": []LabelCount{}, "sentiment": []LabelCount{}, "avg_sentiment_score": 0})
+			return
+		}
+		ctx := c.Request.Context()
+		langs, err := countBy(ctx, a.DB, f, "t.language")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "language query"})
+			return
+		}
+		sentiment, err := countBy(ctx, a.DB, f, "t.sentiment")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "sentiment query"})
+			return
+		}
+		var avg sql.NullFloat64
+		where, args := f.and(ticketCols, "true")
+		if err := a.DB.QueryRow(ctx, `select avg(t.sentiment_score) from tickets t where `+where, args...).Scan(&avg); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "sentiment query"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"languages": langs, "sentiment": sentiment, "avg_sentiment_score": avg.Float64, "source": source(false)})
+	}
+}
+
+// Channels breaks tickets down by the channel they came in through (their
+// source: web, email, phone and so on), scoped like the other reports. It
+// always reads live tickets.
+func Channels(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, channelMeasures, "channel") {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"channels": []LabelCount{}, "source": source(false)})
+			return
+		}
+		channels, err := countBy(c.Request.Context(), a.DB, f, "t.source")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "channel query"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"channels": channels, "source": source(false)})
+	}
+}
+
+// ChannelStat is the volume and handling times of one intake channel.
+// Resolution times are business time on the SLA clock of resolved tickets;
+// first response is wall-clock time from creation to the first agent reply.
+type ChannelStat struct {
+	Channel            string  `json:"channel"`
+	Created            int     `json:"created"`
+	Open               int     `json:"open"`
+	Resolved           int     `json:"resolved"`
+	AvgResolutionMS    float64 `json:"avg_resolution_ms"`
+	P50ResolutionMS    float64 `json:"p50_resolution_ms"`
+	P90ResolutionMS    float64 `json:"p90_resolution_ms"`
+	AvgFirstResponseMS float64 `json:"avg_first_response_ms"`
+	SLAMet             int     `json:"sla_met"`
+	SLATotal           int     `json:"sla_total"`
+	SLAAttainment      float64 `json:"sla_attainment"`
+}
+
+// channelStats returns one ChannelStat per channel with tickets in f,
+// busiest first.
+func channelStats(ctx context.Context, db app.DB, f Filter) ([]ChannelStat, error) {
+	where, args := f.and(ticketCols, "true")
+	rows, err := db.Query(ctx, `
+               select coalesce(t.source, 'unknown') as channel,
+                       count(*),
+                       count(*) filter (where t.status not in ('Resolved', 'Closed')),
+                       count(*) filter (where t.status = 'Resolved'),
+                       coalesce(avg(tsc.resolution_elapsed_ms) filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0), 0)::float8,
+                       coalesce(percentile_cont(0.5) within group (order by tsc.resolution_elapsed_ms)
+                           filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0), 0),
+                       coalesce(percentile_cont(0.9) within group (order by tsc.resolution_elapsed_ms)
+                           filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0), 0),
+                       coalesce(avg(extract(epoch from t.first_response_at - t.created_at) * 1000), 0)::float8,
+                       count(*) filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000),
+                       count(sp.id) filter (where t.status = 'Resolved')
+               from tickets t
+               left join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+               left join sla_policy_versions sp on sp.id = tsc.policy_version_id
+               where `+where+`
+               group by channel
+               order by count(*) desc, channel`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []ChannelStat{}
+	for rows.Next() {
+		var cs ChannelStat
+		if err := rows.Scan(&cs.Channel, &cs.Created, &cs.Open, &cs.Resolved, &cs.AvgResolutionMS, &cs.P50ResolutionMS,
+			&cs.P90ResolutionMS, &cs.AvgFirstResponseMS, &cs.SLAMet, &cs.SLATotal); err != nil {
+			return nil, err
+		}
+		cs.SLAAttainment = attainment(cs.SLAMet, cs.SLATotal)
+		out = append(out, cs)
+	}
+	return out, rows.Err()
+}
+
+// ChannelStats reports per intake channel how many tickets came in, how
+// many are still open or resolved, and how long they took to answer and
+// resolve, for sizing each channel's staffing. It is scoped like the other
+// reports and always reads live tickets.
+func ChannelStats(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := ParseFilter(c)
+		if !ok {
+			return
+		}
+		if drilldown(c, a, f, channelStatsMeasures, "created") {
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"channels": []ChannelStat{}, "source": source(false)})
+			return
+		}
+		stats, err := channelStats(c.Request.Context(), a.DB, f)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "channel query"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"channels": stats, "source": source(false)})
+	}
 }
 
 // Manager returns queue/manager analytics snapshot
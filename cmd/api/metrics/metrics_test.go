@@ -1,15 +1,21 @@
 package metrics_test
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 func TestMetricsHandlers(t *testing.T) {
@@ -20,6 +26,9 @@ func TestMetricsHandlers(t *testing.T) {
 	a.R.GET("/metrics/resolution", authpkg.Middleware(a), metrics.Resolution(a))
 	a.R.GET("/metrics/tickets", authpkg.Middleware(a), metrics.TicketVolume(a))
 	a.R.GET("/metrics/dashboard", authpkg.Middleware(a), metrics.Dashboard(a))
+	a.R.GET("/metrics/sentiment", authpkg.Middleware(a), metrics.Sentiment(a))
+	a.R.GET("/metrics/channels", authpkg.Middleware(a), metrics.Channels(a))
+	a.R.GET("/metrics/channels/stats", authpkg.Middleware(a), metrics.ChannelStats(a))
 
 	tests := []struct {
 		name string
@@ -29,6 +38,9 @@ func TestMetricsHandlers(t *testing.T) {
 		{"resolution", "/metrics/resolution"},
 		{"volume", "/metrics/tickets"},
 		{"dashboard", "/metrics/dashboard"},
+		{"sentiment", "/metrics/sentiment"},
+		{"channels", "/metrics/channels"},
+		{"channel stats", "/metrics/channels/stats"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -41,3 +53,506 @@ func TestMetricsHandlers(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			gotSQL, gotArgs = sql, args
+			return &testutil.MockRow{}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/sla", authpkg.Middleware(a), metrics.SLA(a))
+
+	const team = "6f1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d10"
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"bad team", "team=ops", http.StatusBadRequest},
+		{"bad queue", "queue=1", http.StatusBadRequest},
+		{"bad date", "from=yesterday", http.StatusBadRequest},
+		{"reversed", "from=2025-02-01&to=2025-01-01", http.StatusBadRequest},
+		{"too long", "from=2024-01-01&to=2025-06-01", http.StatusBadRequest},
+		{"scoped", "team=" + team + "&from=2025-01-01&to=2025-01-31", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs = "", nil
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/sla?"+tt.query, nil))
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				if gotSQL != "" {
+					t.Fatal("query ran for invalid filter")
+				}
+				return
+			}
+			if !strings.Contains(gotSQL, "t.team_id = $1") || !strings.Contains(gotSQL, "t.created_at < $3") {
+				t.Fatalf("filter not applied: %s", gotSQL)
+			}
+			to := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+			if len(gotArgs) != 3 || gotArgs[0] != team || !gotArgs[2].(time.Time).Equal(to) {
+				t.Fatalf("unexpected args: %v", gotArgs)
+			}
+		})
+	}
+}
+
+func TestMetricsSummaryFallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	refreshed := time.Now().Add(-10 * time.Minute)
+	var gotSQL string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "report_refreshes") {
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*time.Time) = refreshed
+					return nil
+				}}
+			}
+			gotSQL = sql
+			return &testutil.MockRow{}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/sla", authpkg.Middleware(a), metrics.SLA(a))
+
+	tests := []struct {
+		name      string
+		refreshed time.Time
+		query     string
+		want      string
+	}{
+		{"fresh", time.Now().Add(-10 * time.Minute), "from=2025-01-01&to=2025-01-31", "summary"},
+		{"stale", time.Now().Add(-3 * time.Hour), "", "live"},
+		{"partial day", time.Now().Add(-10 * time.Minute), "from=2025-01-01T12:00:00Z", "live"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshed = tt.refreshed
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/sla?"+tt.query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), `"source":"`+tt.want+`"`) {
+				t.Fatalf("expected %s source, got %s", tt.want, rr.Body.String())
+			}
+			if fromSummary := strings.Contains(gotSQL, "report_sla_daily"); fromSummary != (tt.want == "summary") {
+				t.Fatalf("unexpected query for %s: %s", tt.want, gotSQL)
+			}
+		})
+	}
+}
+
+func TestSLAResponseAttainment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var responseSQL string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "report_refreshes"):
+					return pgx.ErrNoRows
+				case strings.Contains(sql, "first_response_at"):
+					responseSQL = sql
+					*dest[0].(*int), *dest[1].(*int) = 3, 4
+				default:
+					*dest[0].(*int), *dest[1].(*int) = 1, 2
+				}
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/sla", authpkg.Middleware(a), metrics.SLA(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/sla?team=6f1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d10", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		SLAAttainment float64 `json:"sla_attainment"`
+		Response      struct {
+			Total         int     `json:"total"`
+			Met           int     `json:"met"`
+			SLAAttainment float64 `json:"sla_attainment"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.SLAAttainment != 0.5 || out.Response.Total != 4 || out.Response.Met != 3 || out.Response.SLAAttainment != 0.75 {
+		t.Fatalf("unexpected report: %s", rr.Body.String())
+	}
+	if !strings.Contains(responseSQL, "t.team_id = $1") {
+		t.Fatalf("filter not applied to response attainment: %s", responseSQL)
+	}
+}
+
+func TestResolutionPercentiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	refreshed := time.Now().Add(-10 * time.Minute)
+	var queries []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.Contains(sql, "report_refreshes") {
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*time.Time) = refreshed
+					return nil
+				}}
+			}
+			return &testutil.MockRow{}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			queries = append(queries, sql)
+			prio := []int16{0, 1, 4}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(prio) },
+				ScanFunc: func(dest ...any) error {
+					if prio[i] > 0 {
+						*dest[0].(**int16) = &prio[i]
+					}
+					*dest[2].(*int) = 10
+					*dest[3].(*float64), *dest[4].(*float64), *dest[5].(*float64) = 1000, 9000, 60000
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/resolution", authpkg.Middleware(a), metrics.Resolution(a))
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"whole history", "", "summary"},
+		{"team", "team=6f1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d10", "summary"},
+		{"range", "from=2025-01-01&to=2025-01-31", "live"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries = nil
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/resolution?"+tt.query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			var out struct {
+				Percentiles map[string][]metrics.Percentiles `json:"percentiles"`
+				Source      string                           `json:"percentiles_source"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Source != tt.want || len(queries) != 2 {
+				t.Fatalf("expected 2 %s queries, got %s: %v", tt.want, out.Source, queries)
+			}
+			for _, q := range queries {
+				if cached := strings.Contains(q, "report_time_percentiles"); cached != (tt.want == "summary") {
+					t.Fatalf("unexpected query for %s: %s", tt.want, q)
+				}
+			}
+			rows := out.Percentiles["first_response"]
+			if len(rows) != 3 || rows[0].Priority != nil || *rows[2].Priority != 4 || rows[1].P90MS != 9000 || len(out.Percentiles["resolution"]) != 3 {
+				t.Fatalf("unexpected percentiles %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSentimentReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	groups := map[string][]metrics.LabelCount{
+		"t.language":  {{Label: "en", Count: 7}, {Label: "unknown", Count: 2}, {Label: "es", Count: 1}},
+		"t.sentiment": {{Label: "neutral", Count: 6}, {Label: "negative", Count: 4}},
+	}
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			var list []metrics.LabelCount
+			for expr, l := range groups {
+				if strings.Contains(sql, "coalesce("+expr) {
+					list = l
+				}
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(list) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*int) = list[i].Label, list[i].Count
+					return nil
+				},
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/sentiment", authpkg.Middleware(a), metrics.Sentiment(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/sentiment", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var out struct {
+		Languages []metrics.LabelCount `json:"languages"`
+		Sentiment []metrics.LabelCount `json:"sentiment"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Languages) != 3 || out.Languages[0].Label != "en" || len(out.Sentiment) != 2 || out.Sentiment[1].Count != 4 {
+		t.Fatalf("unexpected report %s", rr.Body.String())
+	}
+}
+
+func TestChannelReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	list := []metrics.LabelCount{{Label: "web", Count: 12}, {Label: "phone", Count: 5}, {Label: "email", Count: 3}}
+	var gotSQL string
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			gotSQL = sql
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(list) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*int) = list[i].Label, list[i].Count
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/channels", authpkg.Middleware(a), metrics.Channels(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/channels", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var out struct {
+		Channels []metrics.LabelCount `json:"channels"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gotSQL, "coalesce(t.source") || len(out.Channels) != 3 || out.Channels[1] != list[1] {
+		t.Fatalf("unexpected report %s from %s", rr.Body.String(), gotSQL)
+	}
+}
+
+func TestChannelStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			gotSQL, gotArgs = sql, args
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= 2 },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = []string{"web", "chat"}[i-1]
+					*dest[1].(*int), *dest[2].(*int), *dest[3].(*int) = 10/i, 2, 8/i
+					*dest[4].(*float64), *dest[7].(*float64) = 3600000, 600000
+					*dest[8].(*int), *dest[9].(*int) = 3, 4
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/channels/stats", authpkg.Middleware(a), metrics.ChannelStats(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/channels/stats?from=2025-01-01&to=2025-01-31", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Channels []metrics.ChannelStat `json:"channels"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Channels) != 2 || out.Channels[1].Channel != "chat" || out.Channels[1].Created != 5 || out.Channels[1].Resolved != 4 ||
+		out.Channels[0].AvgFirstResponseMS != 600000 || out.Channels[0].SLAAttainment != 0.75 {
+		t.Fatalf("unexpected report: %s", rr.Body.String())
+	}
+	if !strings.Contains(gotSQL, "group by channel") || !strings.Contains(gotSQL, "t.created_at >= $1") || len(gotArgs) != 2 {
+		t.Fatalf("unexpected query %s %v", gotSQL, gotArgs)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/channels/stats?drilldown=true&measure=resolved", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("drill-down without a label: expected 400, got %d", rr.Code)
+	}
+}
+
+func TestLeaderboardVisibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const team = "6f1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d10"
+	type row struct {
+		id, name string
+		resolved int
+	}
+	board := []row{{"u1", "Ana", 9}, {"u2", "Bo", 9}, {"test-user", "Test", 4}}
+	var visibility string
+	var member bool
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string), *dest[1].(*bool) = visibility, member
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			gotSQL, gotArgs = sql, args
+			list := board
+			if len(args) > 1 && args[1] == "test-user" {
+				list = board[2:]
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(list) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*int) = list[i].id, list[i].name, list[i].resolved
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/leaderboard", authpkg.Middleware(a), metrics.Leaderboard(a))
+
+	tests := []struct {
+		name       string
+		visibility string
+		member     bool
+		want       int
+		ranks      []int
+	}{
+		{"off", "off", true, http.StatusForbidden, nil},
+		{"not a member", "team", false, http.StatusForbidden, nil},
+		{"team", "team", true, http.StatusOK, []int{1, 1, 3}},
+		{"self", "self", true, http.StatusOK, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visibility, member, gotSQL = tt.visibility, tt.member, ""
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics/leaderboard?team="+team+"&from=2025-01-01", nil))
+			if rr.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+			if tt.want != http.StatusOK {
+				if gotSQL != "" {
+					t.Fatal("leaderboard query ran")
+				}
+				return
+			}
+			var out struct {
+				Agents []metrics.LeaderboardEntry `json:"agents"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+				t.Fatal(err)
+			}
+			if len(out.Agents) != len(tt.ranks) {
+				t.Fatalf("unexpected rows %s", rr.Body.String())
+			}
+			for i, r := range tt.ranks {
+				if out.Agents[i].Rank != r {
+					t.Fatalf("row %d: expected rank %d, got %d", i, r, out.Agents[i].Rank)
+				}
+			}
+			if tt.visibility == "self" && out.Agents[0].UserID != "test-user" {
+				t.Fatalf("self board leaked another agent: %s", rr.Body.String())
+			}
+			if strings.Contains(gotSQL, "t.team_id") || gotArgs[0] != team {
+				t.Fatalf("unexpected scope %s %v", gotSQL, gotArgs)
+			}
+		})
+	}
+}
+
+func TestDrilldown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ids := []string{"7a1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d13", "7a1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d12", "7a1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d11"}
+	created := time.Date(2025, 3, 4, 10, 0, 0, 0, time.UTC)
+	var gotSQL string
+	var gotArgs []any
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			gotSQL, gotArgs = sql, args
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(ids) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*time.Time) = ids[i], created.Add(-time.Duration(i)*time.Hour)
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/metrics/sla", authpkg.Middleware(a), metrics.SLA(a))
+	a.R.GET("/metrics/tickets", authpkg.Middleware(a), metrics.TicketVolume(a))
+	a.R.GET("/metrics/sentiment", authpkg.Middleware(a), metrics.Sentiment(a))
+
+	get := func(url string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		var body map[string]any
+		_ = json.Unmarshal(rr.Body.Bytes(), &body)
+		return rr.Code, body
+	}
+
+	const team = "6f1c1e0e-7d1b-4a55-9d7e-2a7f0b8c9d10"
+	code, body := get("/metrics/sla?drilldown=true&measure=breached&limit=2&team=" + team)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %v", code, body)
+	}
+	if got := body["ticket_ids"].([]any); len(got) != 2 || got[0] != ids[0] || body["measure"] != "breached" || body["next_cursor"] == "" {
+		t.Fatalf("unexpected page %v", body)
+	}
+	if !strings.Contains(gotSQL, "is not true") || !strings.Contains(gotSQL, "t.team_id = $1") || !strings.Contains(gotSQL, "limit 3") {
+		t.Fatalf("unexpected query %s", gotSQL)
+	}
+
+	code, body = get("/metrics/sla?drilldown=true&measure=breached&limit=2&cursor=" + body["next_cursor"].(string))
+	if code != http.StatusOK || len(gotArgs) != 2 || gotArgs[1] != ids[1] || !strings.Contains(gotSQL, "(t.created_at, t.id) < ($1, $2::uuid)") {
+		t.Fatalf("cursor not applied: %d %v %s %v", code, body, gotSQL, gotArgs)
+	}
+
+	code, _ = get("/metrics/tickets?drilldown=true&day=2025-03-04&from=2025-03-01&to=2025-03-31")
+	if code != http.StatusOK || len(gotArgs) != 4 || !strings.Contains(gotSQL, "t.created_at >= $1 and t.created_at < $2") {
+		t.Fatalf("day not applied: %d %s %v", code, gotSQL, gotArgs)
+	}
+
+	for _, url := range []string{
+		"/metrics/sla?drilldown=true&measure=volume",
+		"/metrics/sla?drilldown=true&limit=1000",
+		"/metrics/sla?drilldown=true&cursor=nope",
+		"/metrics/sentiment?drilldown=true",
+		"/metrics/tickets?drilldown=true&day=March",
+	} {
+		if code, body := get(url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %v", url, code, body)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+-- +goose Up
+create table if not exists outbox (
+    id bigserial primary key,
+    kind text not null check (kind in ('event','job')),
+    dedup_key text not null unique,
+    payload jsonb not null,
+    attempts int not null default 0,
+    last_error text,
+    created_at timestamptz not null default now(),
+    published_at timestamptz
+);
+create index if not exists outbox_pending_idx on outbox (id) where published_at is null;
+
+-- +goose Down
+drop table if exists outbox;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- A monotonically increasing sequence gives consumers a simple, gap-tolerant
+-- cursor for history reads and replays.
+alter table ticket_events add column if not exists seq bigserial;
+create unique index if not exists ticket_events_seq_idx on ticket_events(seq);
+
+-- +goose Down
+drop index if exists ticket_events_seq_idx;
+alter table ticket_events drop column if exists seq;
@@ -0,0 +1,19 @@
+-- +goose Up
+create table if not exists calendar_exceptions (
+    id uuid primary key default gen_random_uuid(),
+    calendar_id uuid not null references calendars(id) on delete cascade,
+    starts_at timestamptz not null,
+    ends_at timestamptz not null,
+    kind text not null default 'closure' check (kind in ('holiday', 'closure')),
+    label text,
+    created_at timestamptz not null default now(),
+    check (ends_at > starts_at)
+);
+create index if not exists calendar_exceptions_calendar_idx on calendar_exceptions(calendar_id, starts_at);
+
+-- Records which feed an imported holiday came from; null for manual entries.
+alter table holidays add column if not exists source text;
+
+-- +goose Down
+alter table holidays drop column if exists source;
+drop table if exists calendar_exceptions;
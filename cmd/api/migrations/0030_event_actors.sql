@@ -0,0 +1,10 @@
+-- +goose Up
+-- actor_type is "user", "api_key" or "system:<job>"; actor_id is null for system actors.
+alter table ticket_events add column if not exists actor_type text;
+alter table ticket_events add column if not exists actor_id uuid;
+create index if not exists audit_events_actor_type_idx on audit_events (actor_type);
+
+-- +goose Down
+drop index if exists audit_events_actor_type_idx;
+alter table ticket_events drop column if exists actor_id;
+alter table ticket_events drop column if exists actor_type;
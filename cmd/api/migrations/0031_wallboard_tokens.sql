@@ -0,0 +1,17 @@
+-- +goose Up
+-- Display tokens for wallboards that cannot do an interactive login. Only the
+-- sha256 of the token is stored; queue_id optionally scopes the board.
+create table if not exists wallboard_tokens (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    token_hash text not null unique,
+    queue_id uuid references queues(id) on delete cascade,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    revoked_at timestamptz
+);
+
+-- +goose Down
+drop table if exists wallboard_tokens;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Support metrics scoped by team or queue over a creation date range.
+create index if not exists tickets_created_at_idx on tickets(created_at);
+create index if not exists tickets_team_created_idx on tickets(team_id, created_at);
+create index if not exists tickets_queue_created_idx on tickets(queue_id, created_at);
+
+-- +goose Down
+drop index if exists tickets_queue_created_idx;
+drop index if exists tickets_team_created_idx;
+drop index if exists tickets_created_at_idx;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Daily summaries rebuilt by the worker (see internal/reports). Rows are
+-- keyed by the tickets' creation day, team and queue so reports can be scoped
+-- the same way as the live queries.
+create table if not exists report_daily_volume (
+    day date not null,
+    team_id uuid,
+    queue_id uuid,
+    created int not null
+);
+create index if not exists report_daily_volume_day_idx on report_daily_volume(day);
+
+create table if not exists report_sla_daily (
+    day date not null,
+    team_id uuid,
+    queue_id uuid,
+    sla_total int not null,
+    sla_met int not null,
+    resolution_ms_sum bigint not null,
+    resolution_count int not null
+);
+create index if not exists report_sla_daily_day_idx on report_sla_daily(day);
+
+create table if not exists report_agent_daily (
+    day date not null,
+    assignee_id uuid not null,
+    team_id uuid,
+    queue_id uuid,
+    resolved int not null,
+    resolution_ms_sum bigint not null,
+    resolution_count int not null
+);
+create index if not exists report_agent_daily_agent_idx on report_agent_daily(assignee_id, day);
+
+create table if not exists report_refreshes (
+    name text primary key,
+    refreshed_at timestamptz not null
+);
+
+-- +goose Down
+drop table if exists report_refreshes;
+drop table if exists report_agent_daily;
+drop table if exists report_sla_daily;
+drop table if exists report_daily_volume;
@@ -0,0 +1,177 @@
+-- +goose Up
+-- Optional monthly range partitioning for tickets, ticket_comments and
+-- audit_events. Nothing is converted here: the API calls
+-- hd_enable_partitioning() on start-up when DB_PARTITIONING=true, which only
+-- succeeds while the tables are still empty (fresh installs). The worker then
+-- keeps future partitions created with hd_ensure_partitions().
+--
+-- Partitioned tables need the partition column in every unique key, so after
+-- conversion:
+--   * primary keys become (id, <column>);
+--   * other unique constraints include <column>, and unique indexes without it
+--     become plain indexes (ticket dedup falls back to advisory locks);
+--   * foreign keys that referenced the table point at <table>_keys(id), a
+--     plain table kept in sync by trigger, so cascades keep working.
+-- The partition column must not change after insert.
+create table if not exists partitioned_tables (
+    table_name text primary key,
+    column_name text not null,
+    created_at timestamptz not null default now()
+);
+
+-- +goose StatementBegin
+create or replace function hd_sync_keys() returns trigger as $$
+begin
+    if tg_op = 'INSERT' then
+        execute format('insert into %I (id) values ($1)', tg_argv[0]) using new.id;
+        return new;
+    end if;
+    execute format('delete from %I where id = $1', tg_argv[0]) using old.id;
+    return old;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create or replace function hd_ensure_partitions(tbl text, months_ahead int) returns int as $$
+declare
+    m date := date_trunc('month', now())::date;
+    part text;
+    n int := 0;
+begin
+    for i in 0..months_ahead loop
+        part := format('%s_p%s', tbl, to_char(m, 'YYYYMM'));
+        if to_regclass(part) is null then
+            execute format('create table %I partition of %I for values from (%L) to (%L)',
+                part, tbl, m, (m + interval '1 month')::date);
+            n := n + 1;
+        end if;
+        m := (m + interval '1 month')::date;
+    end loop;
+    return n;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- Detached partitions stay in the database as plain tables for archiving.
+-- +goose StatementBegin
+create or replace function hd_detach_partitions(tbl text, keep_months int) returns int as $$
+declare
+    cutoff date := (date_trunc('month', now()) - make_interval(months => keep_months))::date;
+    r record;
+    n int := 0;
+begin
+    for r in
+        select c.relname
+        from pg_inherits i
+        join pg_class c on c.oid = i.inhrelid
+        where i.inhparent = tbl::regclass
+          and c.relname ~ ('^' || tbl || '_p[0-9]{6}$')
+          and to_date(right(c.relname, 6), 'YYYYMM') < cutoff
+    loop
+        execute format('alter table %I detach partition %I', tbl, r.relname);
+        n := n + 1;
+    end loop;
+    return n;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create or replace function hd_partition_table(tbl text, col text) returns void as $$
+declare
+    r record;
+    stmts text[] := '{}';
+    keys text := tbl || '_keys';
+    has_refs boolean := false;
+    s text;
+begin
+    -- Indexes not backing a constraint.
+    for r in
+        select case
+                 when i.indisunique and not exists (
+                     select 1 from pg_attribute a
+                     where a.attrelid = i.indrelid and a.attnum = any(i.indkey) and a.attname = col)
+                 then replace(pg_get_indexdef(i.indexrelid), 'CREATE UNIQUE INDEX', 'CREATE INDEX')
+                 else pg_get_indexdef(i.indexrelid)
+               end as def
+        from pg_index i
+        where i.indrelid = tbl::regclass
+          and not exists (select 1 from pg_constraint c where c.conindid = i.indexrelid)
+    loop
+        stmts := stmts || r.def;
+    end loop;
+
+    for r in
+        select c.conname,
+               (select string_agg(quote_ident(a.attname), ', ' order by k.ord)
+                from unnest(c.conkey) with ordinality k(num, ord)
+                join pg_attribute a on a.attrelid = c.conrelid and a.attnum = k.num) as cols
+        from pg_constraint c
+        where c.conrelid = tbl::regclass and c.contype = 'u'
+    loop
+        stmts := stmts || format('alter table %I add constraint %I unique (%s, %I)', tbl, r.conname, r.cols, col);
+    end loop;
+
+    for r in
+        select conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where conrelid = tbl::regclass and contype = 'f'
+    loop
+        stmts := stmts || format('alter table %I add constraint %I %s', tbl, r.conname, r.def);
+    end loop;
+
+    for r in
+        select conrelid::regclass as child, conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where confrelid = tbl::regclass and contype = 'f' and conrelid <> tbl::regclass
+    loop
+        has_refs := true;
+        stmts := stmts || format('alter table %s add constraint %I %s', r.child, r.conname,
+            regexp_replace(r.def, 'REFERENCES (\S+\.)?' || tbl || '\(', 'REFERENCES ' || keys || '('));
+    end loop;
+
+    execute format('alter table %I rename to %I', tbl, tbl || '_unpartitioned');
+    execute format('create table %I (like %I including defaults including constraints including generated including identity including storage including comments) partition by range (%I)',
+        tbl, tbl || '_unpartitioned', col);
+    execute format('drop table %I cascade', tbl || '_unpartitioned');
+    execute format('alter table %I add primary key (id, %I)', tbl, col);
+    execute format('create table %I partition of %I default', tbl || '_default', tbl);
+    if has_refs then
+        execute format('create table if not exists %I (id uuid primary key)', keys);
+        execute format('create trigger %I after insert or delete on %I for each row execute function hd_sync_keys(%L)',
+            tbl || '_keys_sync', tbl, keys);
+    end if;
+    foreach s in array stmts loop
+        execute s;
+    end loop;
+    insert into partitioned_tables (table_name, column_name) values (tbl, col);
+    perform hd_ensure_partitions(tbl, 3);
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+create or replace function hd_enable_partitioning() returns boolean as $$
+begin
+    if exists (select 1 from partitioned_tables) then
+        return false;
+    end if;
+    if exists (select 1 from tickets) or exists (select 1 from ticket_comments) or exists (select 1 from audit_events) then
+        raise exception 'partitioning can only be enabled on a fresh install';
+    end if;
+    perform hd_partition_table('tickets', 'created_at');
+    perform hd_partition_table('ticket_comments', 'created_at');
+    perform hd_partition_table('audit_events', 'at');
+    return true;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Converted tables stay partitioned; only the helpers are removed.
+drop function if exists hd_enable_partitioning();
+drop function if exists hd_partition_table(text, text);
+drop function if exists hd_detach_partitions(text, int);
+drop function if exists hd_ensure_partitions(text, int);
+drop table if exists partitioned_tables;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- External addresses copied on a ticket. Each is backed by a requester
+-- contact and receives public comments by email.
+create table if not exists ticket_ccs (
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    email text not null,
+    requester_id uuid references requesters(id) on delete set null,
+    added_by uuid references users(id) on delete set null,
+    added_at timestamptz not null default now(),
+    primary key (ticket_id, email)
+);
+
+-- +goose Down
+drop table if exists ticket_ccs;
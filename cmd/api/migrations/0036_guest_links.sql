@@ -0,0 +1,23 @@
+-- +goose Up
+-- Share links giving an external collaborator token access to one ticket.
+-- Only the SHA-256 of the token is stored.
+create table if not exists ticket_guest_links (
+    id uuid primary key default gen_random_uuid(),
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    token_hash text not null unique,
+    requester_id uuid not null references requesters(id),
+    scope text not null default 'reply' check (scope in ('view', 'reply')),
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    last_used_at timestamptz,
+    revoked_at timestamptz
+);
+
+create index if not exists ticket_guest_links_ticket_idx on ticket_guest_links(ticket_id);
+
+alter table ticket_comments add column if not exists guest_link_id uuid references ticket_guest_links(id) on delete set null;
+
+-- +goose Down
+alter table ticket_comments drop column if exists guest_link_id;
+drop table if exists ticket_guest_links;
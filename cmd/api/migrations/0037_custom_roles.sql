@@ -0,0 +1,12 @@
+-- +goose Up
+-- Custom roles carry an explicit permission list. Built-in roles (admin,
+-- agent, manager, requester) keep their permissions in code and ignore this
+-- column.
+alter table roles add column if not exists description text;
+alter table roles add column if not exists permissions text[] not null default '{}';
+alter table roles add column if not exists created_at timestamptz not null default now();
+
+-- +goose Down
+alter table roles drop column if exists created_at;
+alter table roles drop column if exists permissions;
+alter table roles drop column if exists description;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Maps IdP groups from the OIDC group claim to internal roles. Groups without
+-- a mapping grant nothing.
+create table if not exists oidc_group_roles (
+    group_name text not null,
+    role_id uuid not null references roles(id) on delete cascade,
+    created_at timestamptz not null default now(),
+    primary key (group_name, role_id)
+);
+
+-- Groups used to be taken verbatim as role names; map every existing role to
+-- a group of the same name so current IdP setups keep working.
+insert into oidc_group_roles (group_name, role_id)
+select name, id from roles
+on conflict do nothing;
+
+-- +goose Down
+drop table if exists oidc_group_roles;
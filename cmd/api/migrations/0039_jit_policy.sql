@@ -0,0 +1,7 @@
+-- +goose Up
+-- Users created on first OIDC login can be held for admin approval. Pending
+-- users are also inactive until approved.
+alter table users add column if not exists pending_approval boolean not null default false;
+
+-- +goose Down
+alter table users drop column if exists pending_approval;
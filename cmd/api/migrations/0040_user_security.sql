@@ -0,0 +1,27 @@
+-- +goose Up
+-- One row per successful login. Sessions are signed cookies that expire on
+-- their own; expires_at mirrors the cookie so active sessions can be listed.
+create table if not exists user_sessions (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    method text not null check (method in ('local', 'oidc')),
+    ip text,
+    user_agent text,
+    device_hash text not null,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null
+);
+create index if not exists user_sessions_user_idx on user_sessions (user_id, created_at desc);
+
+create table if not exists password_changes (
+    id bigserial primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    ip text,
+    user_agent text,
+    changed_at timestamptz not null default now()
+);
+create index if not exists password_changes_user_idx on password_changes (user_id, changed_at desc);
+
+-- +goose Down
+drop table if exists password_changes;
+drop table if exists user_sessions;
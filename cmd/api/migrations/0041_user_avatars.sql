@@ -0,0 +1,7 @@
+-- +goose Up
+-- Object key of the user's uploaded avatar. It points at the original upload
+-- until the worker replaces it with the resized PNG. Null means Gravatar.
+alter table users add column if not exists avatar_key text;
+
+-- +goose Down
+alter table users drop column if exists avatar_key;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Agents belong to teams; max_open caps how many open tickets auto-assignment
+-- gives them (null = no cap).
+create table if not exists team_members (
+    team_id uuid not null references teams(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    max_open int check (max_open > 0),
+    created_at timestamptz not null default now(),
+    primary key (team_id, user_id)
+);
+create index if not exists team_members_user_idx on team_members (user_id);
+
+-- Agents mark themselves unavailable to stop receiving new work.
+alter table users add column if not exists available boolean not null default true;
+
+create index if not exists tickets_assignee_open_idx on tickets (assignee_id)
+    where status not in ('Resolved', 'Closed');
+
+-- +goose Down
+drop index if exists tickets_assignee_open_idx;
+alter table users drop column if exists available;
+drop table if exists team_members;
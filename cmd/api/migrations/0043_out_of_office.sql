@@ -0,0 +1,11 @@
+-- +goose Up
+-- Out-of-office window: while now() is in [ooo_start, ooo_end) new
+-- assignments go to ooo_delegate_id, or to the team pool without one.
+alter table users add column if not exists ooo_start timestamptz;
+alter table users add column if not exists ooo_end timestamptz;
+alter table users add column if not exists ooo_delegate_id uuid references users(id) on delete set null;
+
+-- +goose Down
+alter table users drop column if exists ooo_delegate_id;
+alter table users drop column if exists ooo_end;
+alter table users drop column if exists ooo_start;
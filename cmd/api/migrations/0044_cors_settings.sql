@@ -0,0 +1,7 @@
+-- +goose Up
+-- Allowed CORS origins managed from the admin settings, by environment:
+-- {"origins": {"prod": ["https://*.example.com"], "*": [...]}, "max_age": 600}
+alter table settings add column if not exists cors jsonb not null default '{}'::jsonb;
+
+-- +goose Down
+alter table settings drop column if exists cors;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- WebAuthn passkeys for local auth. public_key is the COSE_Key from
+-- registration; sign_count detects cloned authenticators.
+create table if not exists webauthn_credentials (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    credential_id bytea not null unique,
+    public_key bytea not null,
+    sign_count bigint not null default 0,
+    aaguid bytea,
+    attestation_fmt text not null default 'none',
+    transports text[] not null default '{}',
+    name text not null default '',
+    created_at timestamptz not null default now(),
+    last_used_at timestamptz
+);
+create index if not exists webauthn_credentials_user_idx on webauthn_credentials (user_id);
+
+-- Outstanding registration and login challenges; each is consumed once.
+-- Login challenges have no user when the browser picks a discoverable passkey.
+create table if not exists webauthn_challenges (
+    challenge text primary key,
+    kind text not null check (kind in ('register', 'login')),
+    user_id uuid references users(id) on delete cascade,
+    expires_at timestamptz not null
+);
+create index if not exists webauthn_challenges_expires_idx on webauthn_challenges (expires_at);
+
+alter table user_sessions drop constraint if exists user_sessions_method_check;
+alter table user_sessions add constraint user_sessions_method_check check (method in ('local', 'oidc', 'passkey'));
+
+-- +goose Down
+alter table user_sessions drop constraint if exists user_sessions_method_check;
+delete from user_sessions where method = 'passkey';
+alter table user_sessions add constraint user_sessions_method_check check (method in ('local', 'oidc'));
+drop table if exists webauthn_challenges;
+drop table if exists webauthn_credentials;
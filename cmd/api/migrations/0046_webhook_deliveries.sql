@@ -0,0 +1,19 @@
+-- +goose Up
+create table if not exists webhook_deliveries (
+    id uuid primary key,
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    event text not null,
+    payload jsonb not null,
+    is_test boolean not null default false,
+    attempt int not null default 1,
+    retry_of uuid references webhook_deliveries(id) on delete set null,
+    status_code int,
+    latency_ms int not null default 0,
+    response_excerpt text not null default '',
+    error text,
+    created_at timestamptz not null default now()
+);
+create index if not exists webhook_deliveries_webhook_created_idx on webhook_deliveries (webhook_id, created_at desc);
+
+-- +goose Down
+drop table if exists webhook_deliveries;
@@ -0,0 +1,17 @@
+-- +goose Up
+alter table tickets add column if not exists language text;
+alter table tickets add column if not exists sentiment text check (sentiment in ('positive','neutral','negative'));
+alter table tickets add column if not exists sentiment_score real;
+alter table tickets add column if not exists enriched_at timestamptz;
+create index if not exists tickets_unenriched_idx on tickets (created_at) where enriched_at is null;
+create index if not exists ticket_comments_created_idx on ticket_comments (created_at);
+alter table teams add column if not exists languages text[] not null default '{}';
+
+-- +goose Down
+alter table teams drop column if exists languages;
+drop index if exists ticket_comments_created_idx;
+drop index if exists tickets_unenriched_idx;
+alter table tickets drop column if exists enriched_at;
+alter table tickets drop column if exists sentiment_score;
+alter table tickets drop column if exists sentiment;
+alter table tickets drop column if exists language;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Usage log for AI reply suggestions: one row per request to the configured
+-- provider, successful or not. Suggestion text is not stored.
+create table if not exists reply_suggestions (
+    id bigserial primary key,
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    user_id uuid references users(id) on delete set null,
+    provider text not null,
+    model text not null,
+    suggestions int not null default 0,
+    sources int not null default 0,
+    prompt_tokens int not null default 0,
+    completion_tokens int not null default 0,
+    latency_ms int not null default 0,
+    error text,
+    created_at timestamptz not null default now()
+);
+create index if not exists reply_suggestions_created_idx on reply_suggestions (created_at desc);
+create index if not exists reply_suggestions_ticket_idx on reply_suggestions (ticket_id);
+
+-- +goose Down
+drop table if exists reply_suggestions;
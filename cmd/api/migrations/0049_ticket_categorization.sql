@@ -0,0 +1,35 @@
+-- +goose Up
+-- Auto-categorization of inbound email. Rules are evaluated in position
+-- order; the first match sets category, subcategory and queue. Tickets no
+-- rule matches are compared with similar categorized tickets instead.
+create table if not exists category_rules (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    position int not null default 0,
+    sender text,
+    subject text,
+    category text not null,
+    subcategory text,
+    queue_id uuid references queues(id) on delete set null,
+    active boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    check (coalesce(sender, '') <> '' or coalesce(subject, '') <> '')
+);
+
+-- categorized_at marks tickets the categorizer has seen, whether or not the
+-- result was confident enough to apply.
+alter table tickets
+    add column if not exists category_confidence real,
+    add column if not exists categorized_by text,
+    add column if not exists categorized_at timestamptz;
+create index if not exists tickets_uncategorized_email_idx on tickets (created_at)
+    where source = 'email' and categorized_at is null;
+
+-- +goose Down
+drop index if exists tickets_uncategorized_email_idx;
+alter table tickets
+    drop column if exists categorized_at,
+    drop column if exists categorized_by,
+    drop column if exists category_confidence;
+drop table if exists category_rules;
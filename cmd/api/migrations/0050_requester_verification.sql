@@ -0,0 +1,30 @@
+-- +goose Up
+-- Requester email verification. Requesters created from the portal or an
+-- inbound email with an unseen address start unverified and are sent a
+-- link; existing requesters and those added by agents count as verified.
+alter table requesters
+    add column if not exists verified boolean not null default true,
+    add column if not exists verified_at timestamptz;
+
+-- Only the sha256 of each link token is stored.
+create table if not exists requester_verifications (
+    id uuid primary key default gen_random_uuid(),
+    requester_id uuid not null references requesters(id) on delete cascade,
+    token_hash text not null unique,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null,
+    used_at timestamptz
+);
+create index if not exists requester_verifications_requester_idx on requester_verifications (requester_id);
+
+-- What happens to unverified requesters' tickets in the queue: allow, flag
+-- or hold. NULL falls back to UNVERIFIED_REQUESTER_POLICY.
+alter table queues
+    add column if not exists unverified_policy text check (unverified_policy in ('allow', 'flag', 'hold'));
+
+-- +goose Down
+alter table queues drop column if exists unverified_policy;
+drop table if exists requester_verifications;
+alter table requesters
+    drop column if exists verified_at,
+    drop column if exists verified;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- Requester read receipts. requester_last_seen_at is when the requester last
+-- looked at the ticket; requester_seen_through is the newest point in the
+-- ticket's history they are known to have seen, which for an opened email is
+-- when it was sent rather than when it was read.
+alter table tickets
+    add column if not exists requester_last_seen_at timestamptz,
+    add column if not exists requester_seen_through timestamptz;
+
+-- When the requester first saw each public reply.
+alter table ticket_comments
+    add column if not exists seen_at timestamptz;
+
+-- Tracking pixels embedded in requester notification emails. Only the sha256
+-- of each token is stored. There is no foreign key as tickets may be
+-- partitioned.
+create table if not exists email_receipts (
+    token_hash text primary key,
+    ticket_id uuid not null,
+    sent_at timestamptz not null default now(),
+    opened_at timestamptz
+);
+create index if not exists email_receipts_ticket_idx on email_receipts (ticket_id);
+
+-- +goose Down
+drop table if exists email_receipts;
+alter table ticket_comments drop column if exists seen_at;
+alter table tickets
+    drop column if exists requester_seen_through,
+    drop column if exists requester_last_seen_at;
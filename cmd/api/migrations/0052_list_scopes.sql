@@ -0,0 +1,8 @@
+-- +goose Up
+-- Default ticket list scope per role, managed from the admin settings:
+-- {"agent": {"scope": "team", "open_only": true}, "manager": {"scope": "all"}}
+-- Roles without an entry see every ticket; requesters only ever see their own.
+alter table settings add column if not exists list_scopes jsonb not null default '{}'::jsonb;
+
+-- +goose Down
+alter table settings drop column if exists list_scopes;
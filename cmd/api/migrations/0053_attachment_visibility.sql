@@ -0,0 +1,12 @@
+-- +goose Up
+-- Attachments can belong to a comment and share its visibility. Internal
+-- attachments are only shown to agents, managers and admins. comment_id has
+-- no foreign key as ticket_comments may be partitioned.
+alter table attachments
+    add column if not exists comment_id uuid,
+    add column if not exists is_internal boolean not null default false;
+
+-- +goose Down
+alter table attachments
+    drop column if exists is_internal,
+    drop column if exists comment_id;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Sensitive tickets (HR matters, security incidents) have every read logged
+-- to ticket_access_log, not just their changes to audit_events. There are no
+-- foreign keys so the log survives the ticket and user, and as tickets may
+-- be partitioned.
+alter table tickets
+    add column if not exists sensitive boolean not null default false;
+
+create table if not exists ticket_access_log (
+    id bigserial primary key,
+    ticket_id uuid not null,
+    actor_type text not null,
+    actor_id uuid,
+    email text,
+    resource text not null,
+    ip text,
+    user_agent text,
+    at timestamptz not null default now()
+);
+create index if not exists ticket_access_log_ticket_idx on ticket_access_log (ticket_id, at desc);
+create index if not exists ticket_access_log_actor_idx on ticket_access_log (actor_id, at desc);
+
+-- +goose Down
+drop table if exists ticket_access_log;
+alter table tickets drop column if exists sensitive;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- job_runs records runs of the worker's maintenance jobs, scheduled or
+-- requested by an admin, with a JSON summary of what each run found.
+create table if not exists job_runs (
+    id uuid primary key default gen_random_uuid(),
+    job text not null,
+    status text not null default 'queued' check (status in ('queued','running','succeeded','failed')),
+    trigger text not null default 'schedule' check (trigger in ('schedule','manual')),
+    params jsonb not null default '{}'::jsonb,
+    requested_by uuid,
+    created_at timestamptz not null default now(),
+    started_at timestamptz,
+    finished_at timestamptz,
+    summary jsonb,
+    error text
+);
+create index if not exists job_runs_job_idx on job_runs (job, created_at desc);
+
+-- +goose Down
+drop table if exists job_runs;
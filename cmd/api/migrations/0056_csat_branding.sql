@@ -0,0 +1,11 @@
+-- +goose Up
+-- Branding for the public CSAT pages, managed from the admin settings:
+-- {"logo_url": "https://...", "primary_color": "#1e40af",
+--  "thank_you": {"*": "Thanks!", "es": "¡Gracias!"}}
+alter table settings add column if not exists csat jsonb not null default '{}'::jsonb;
+-- Preferred language of a requester (BCP 47), used for requester-facing pages.
+alter table requesters add column if not exists locale text;
+
+-- +goose Down
+alter table requesters drop column if exists locale;
+alter table settings drop column if exists csat;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- When a requester was last sent a CSAT survey, so frequent requesters get
+-- at most one per CSAT_THROTTLE_DAYS however many of their tickets resolve.
+alter table requesters add column if not exists last_csat_at timestamptz;
+-- Queues can opt out of CSAT surveys altogether.
+alter table queues add column if not exists csat_enabled boolean not null default true;
+
+-- +goose Down
+alter table queues drop column if exists csat_enabled;
+alter table requesters drop column if exists last_csat_at;
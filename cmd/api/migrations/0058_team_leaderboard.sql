@@ -0,0 +1,8 @@
+-- +goose Up
+-- Agent leaderboards are opt-in per team: 'off' hides them, 'self' shows
+-- each member only their own figures and 'team' ranks the whole team.
+alter table teams add column if not exists leaderboard text not null default 'off'
+    check (leaderboard in ('off', 'self', 'team'));
+
+-- +goose Down
+alter table teams drop column if exists leaderboard;
@@ -0,0 +1,82 @@
+-- +goose Up
+-- Editing an SLA policy closes its current version and opens a new one, so
+-- tickets keep the targets that applied when they were created. The
+-- sla_policies row always mirrors the latest version.
+create table if not exists sla_policy_versions (
+    id uuid primary key default gen_random_uuid(),
+    policy_id uuid not null references sla_policies(id) on delete cascade,
+    version int not null,
+    response_target_mins int not null,
+    resolution_target_mins int not null,
+    update_cadence_mins int,
+    effective_from timestamptz not null default now(),
+    effective_to timestamptz,
+    created_by text,
+    unique (policy_id, version),
+    check (effective_to is null or effective_to > effective_from)
+);
+create unique index if not exists sla_policy_versions_current
+    on sla_policy_versions (policy_id) where effective_to is null;
+
+alter table sla_policies add column if not exists version int not null default 1;
+
+-- Existing policies start at version 1, effective since they were created,
+-- so every existing ticket falls inside it.
+insert into sla_policy_versions (policy_id, version, response_target_mins, resolution_target_mins, update_cadence_mins, effective_from)
+select id, 1, response_target_mins, resolution_target_mins, update_cadence_mins, '-infinity'::timestamptz
+from sla_policies
+on conflict do nothing;
+
+alter table ticket_sla_clocks add column if not exists policy_version_id uuid references sla_policy_versions(id);
+
+update ticket_sla_clocks sc set policy_version_id = v.id
+from sla_policy_versions v
+where v.policy_id = sc.policy_id and v.version = 1 and sc.policy_version_id is null;
+
+-- Policies added later get their first version too.
+-- +goose StatementBegin
+create or replace function create_sla_policy_version() returns trigger as $$
+begin
+    insert into sla_policy_versions (policy_id, version, response_target_mins, resolution_target_mins, update_cadence_mins, effective_from)
+    values (new.id, new.version, new.response_target_mins, new.resolution_target_mins, new.update_cadence_mins, '-infinity');
+    return new;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+create trigger sla_policies_first_version
+    after insert on sla_policies
+    for each row
+    execute function create_sla_policy_version();
+
+-- Clocks are bound to the version in effect when their ticket was created.
+-- +goose StatementBegin
+create or replace function bind_sla_policy_version() returns trigger as $$
+begin
+    if new.policy_id is null then
+        new.policy_version_id := null;
+    elsif new.policy_version_id is null or (tg_op = 'UPDATE' and new.policy_id is distinct from old.policy_id) then
+        select v.id into new.policy_version_id
+        from sla_policy_versions v join tickets t on t.id = new.ticket_id
+        where v.policy_id = new.policy_id
+          and v.effective_from <= t.created_at
+          and (v.effective_to is null or v.effective_to > t.created_at);
+    end if;
+    return new;
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+create trigger ticket_sla_clocks_bind_version
+    before insert or update of policy_id on ticket_sla_clocks
+    for each row
+    execute function bind_sla_policy_version();
+
+-- +goose Down
+drop trigger if exists sla_policies_first_version on sla_policies;
+drop function if exists create_sla_policy_version();
+drop trigger if exists ticket_sla_clocks_bind_version on ticket_sla_clocks;
+drop function if exists bind_sla_policy_version();
+alter table ticket_sla_clocks drop column if exists policy_version_id;
+alter table sla_policies drop column if exists version;
+drop table if exists sla_policy_versions;
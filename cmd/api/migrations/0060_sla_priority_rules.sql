@@ -0,0 +1,7 @@
+-- +goose Up
+-- How SLA clocks react to a priority change, by direction:
+-- {"raise": "prorate", "lower": "keep"}; each is keep, restart or prorate.
+alter table settings add column if not exists sla_priority_change jsonb not null default '{}'::jsonb;
+
+-- +goose Down
+alter table settings drop column if exists sla_priority_change;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- A broadcast posts one public comment, and optionally a status change, to
+-- many tickets at once. The worker applies it ticket by ticket and keeps the
+-- counters current so the requester can follow progress.
+create table if not exists ticket_broadcasts (
+    id uuid primary key default gen_random_uuid(),
+    requested_by uuid references users(id) on delete set null,
+    body_md text not null,
+    status_change text,
+    ticket_ids uuid[] not null,
+    status text not null default 'queued' check (status in ('queued','running','completed','failed')),
+    total int not null,
+    processed int not null default 0,
+    succeeded int not null default 0,
+    failed int not null default 0,
+    errors jsonb not null default '[]'::jsonb,
+    created_at timestamptz not null default now(),
+    started_at timestamptz,
+    finished_at timestamptz
+);
+create index if not exists ticket_broadcasts_requested_by_idx on ticket_broadcasts (requested_by, created_at desc);
+
+-- +goose Down
+drop table if exists ticket_broadcasts;
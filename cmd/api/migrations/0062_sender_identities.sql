@@ -0,0 +1,10 @@
+-- +goose Up
+-- Per-queue and per-team sender identity for notification emails: From
+-- name and address, Reply-To and signature. The queue's fields win over the
+-- team's, and SMTP_FROM covers anything neither sets.
+alter table queues add column if not exists email_identity jsonb not null default '{}'::jsonb;
+alter table teams add column if not exists email_identity jsonb not null default '{}'::jsonb;
+
+-- +goose Down
+alter table teams drop column if exists email_identity;
+alter table queues drop column if exists email_identity;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- The outbound log is searchable from the API, and failed sends keep the
+-- SMTP error. A resend points at the row it repeats.
+alter table email_outbound add column if not exists error text;
+alter table email_outbound add column if not exists resent_from uuid references email_outbound(id) on delete set null;
+create index if not exists email_outbound_created_idx on email_outbound (created_at desc);
+create index if not exists email_outbound_ticket_idx on email_outbound (ticket_id);
+create index if not exists email_outbound_to_idx on email_outbound (lower(to_addr));
+
+-- +goose Down
+drop index if exists email_outbound_to_idx;
+drop index if exists email_outbound_ticket_idx;
+drop index if exists email_outbound_created_idx;
+alter table email_outbound drop column if exists resent_from;
+alter table email_outbound drop column if exists error;
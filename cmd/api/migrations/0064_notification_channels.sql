@@ -0,0 +1,20 @@
+-- +goose Up
+-- Per-user notification channels beyond email: SMS numbers, push webhook
+-- URLs and ntfy topics, each subscribed to a set of events such as
+-- sla_breach. The worker records the outcome of the last delivery.
+create table if not exists notification_channels (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    kind text not null check (kind in ('sms', 'push', 'ntfy')),
+    address text not null,
+    events text[] not null default '{}',
+    enabled boolean not null default true,
+    last_sent_at timestamptz,
+    last_error text,
+    created_at timestamptz not null default now(),
+    unique (user_id, kind, address)
+);
+create index if not exists notification_channels_user on notification_channels (user_id) where enabled;
+
+-- +goose Down
+drop table if exists notification_channels;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Queues can turn a bad CSAT score into a follow-up ticket for the rated
+-- ticket's team lead. followup_of links the follow-up to the rated ticket;
+-- it is a plain column so partitioned installs need no extra key table.
+alter table teams add column if not exists lead_id uuid references users(id) on delete set null;
+alter table queues add column if not exists csat_followup boolean not null default false;
+alter table tickets add column if not exists followup_of uuid;
+create index if not exists tickets_followup_of_idx on tickets (followup_of) where followup_of is not null;
+
+alter table tickets drop constraint if exists tickets_source_check;
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat'));
+
+-- +goose Down
+alter table tickets drop constraint if exists tickets_source_check;
+update tickets set source = 'web' where source = 'csat';
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord'));
+drop index if exists tickets_followup_of_idx;
+alter table tickets drop column if exists followup_of;
+alter table queues drop column if exists csat_followup;
+alter table teams drop column if exists lead_id;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Per-queue aging rules. A ticket that has seen no agent activity for
+-- aging_remind_hours business hours is bumped and its assignee reminded;
+-- after aging_escalate_hours it is escalated to the team lead. The worker
+-- tracks how far each ticket has got in aging_stage (0 fresh, 1 reminded,
+-- 2 escalated) and when in aged_at; later agent activity starts it over.
+alter table queues add column if not exists aging_remind_hours integer check (aging_remind_hours > 0);
+alter table queues add column if not exists aging_escalate_hours integer check (aging_escalate_hours > 0);
+alter table queues add constraint queues_aging_order_check
+    check (aging_escalate_hours is null or aging_remind_hours is null or aging_escalate_hours > aging_remind_hours);
+alter table tickets add column if not exists aging_stage smallint not null default 0;
+alter table tickets add column if not exists aged_at timestamptz;
+
+-- +goose Down
+alter table tickets drop column if exists aged_at;
+alter table tickets drop column if exists aging_stage;
+alter table queues drop constraint if exists queues_aging_order_check;
+alter table queues drop column if exists aging_escalate_hours;
+alter table queues drop column if exists aging_remind_hours;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Keyset indexes for the orderings GET /tickets offers in sort=. Each ends
+-- with id, the tie-breaker its cursor carries. sla_breach_in is computed from
+-- the SLA clock join and has no index of its own.
+create index if not exists tickets_created_at_id_idx on tickets (created_at desc, id desc);
+create index if not exists tickets_due_at_id_idx on tickets (due_at, id);
+create index if not exists tickets_priority_updated_idx on tickets (priority, updated_at desc, id desc);
+-- requester sorts by display name; walking requesters in that order and
+-- joining their tickets through tickets_requester_id_idx serves a page.
+create index if not exists requesters_display_name_idx on requesters ((coalesce(name, email, '')), id);
+
+-- +goose Down
+drop index if exists requesters_display_name_idx;
+drop index if exists tickets_priority_updated_idx;
+drop index if exists tickets_due_at_id_idx;
+drop index if exists tickets_created_at_id_idx;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Addresses and domains admins have blocked. pattern is a lowercase address
+-- or a lowercase domain, which also covers its subdomains.
+create table if not exists requester_blocks (
+    id uuid primary key default gen_random_uuid(),
+    pattern text not null unique check (pattern = lower(pattern)),
+    reason text,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    hits int not null default 0,
+    last_hit_at timestamptz
+);
+
+-- +goose Down
+drop table if exists requester_blocks;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- Organizations own the requesters whose email domain they list. Their
+-- support contracts grant included minutes of agent time, a number of
+-- tickets, or both, between two dates; tickets opened while a contract is
+-- current count against it, as does the time logged on them.
+create table if not exists organizations (
+    id uuid primary key default gen_random_uuid(),
+    name text not null unique,
+    domains text[] not null default '{}',
+    account_manager_id uuid references users(id) on delete set null,
+    created_at timestamptz not null default now()
+);
+create index if not exists organizations_domains_idx on organizations using gin (domains);
+
+create table if not exists support_contracts (
+    id uuid primary key default gen_random_uuid(),
+    organization_id uuid not null references organizations(id) on delete cascade,
+    name text not null,
+    included_minutes int check (included_minutes > 0),
+    included_tickets int check (included_tickets > 0),
+    starts_on date not null,
+    ends_on date not null,
+    expiry_warned_at timestamptz,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    check (ends_on >= starts_on)
+);
+create index if not exists support_contracts_org_idx on support_contracts (organization_id, ends_on desc);
+
+alter table tickets add column if not exists contract_id uuid references support_contracts(id) on delete set null;
+create index if not exists tickets_contract_idx on tickets (contract_id) where contract_id is not null;
+
+create table if not exists ticket_time_entries (
+    id uuid primary key default gen_random_uuid(),
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    user_id uuid references users(id) on delete set null,
+    minutes int not null check (minutes > 0 and minutes <= 1440),
+    note text,
+    contract_id uuid references support_contracts(id) on delete set null,
+    created_at timestamptz not null default now()
+);
+create index if not exists ticket_time_entries_ticket_idx on ticket_time_entries (ticket_id, created_at);
+create index if not exists ticket_time_entries_contract_idx on ticket_time_entries (contract_id) where contract_id is not null;
+
+-- +goose Down
+drop table if exists ticket_time_entries;
+drop index if exists tickets_contract_idx;
+alter table tickets drop column if exists contract_id;
+drop table if exists support_contracts;
+drop table if exists organizations;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Warranty coverage fetched from the manufacturer by serial number. The
+-- lookup writes warranty_expiry too; warranty_error keeps the last failure
+-- while the previous result stays in place.
+alter table assets add column if not exists warranty_vendor text;
+alter table assets add column if not exists warranty_status text check (warranty_status in ('active', 'expired', 'unknown'));
+alter table assets add column if not exists warranty_coverage text;
+alter table assets add column if not exists warranty_checked_at timestamptz;
+alter table assets add column if not exists warranty_error text;
+create index if not exists assets_warranty_checked_idx on assets (warranty_checked_at nulls first) where serial_number is not null;
+
+-- +goose Down
+drop index if exists assets_warranty_checked_idx;
+alter table assets drop column if exists warranty_error;
+alter table assets drop column if exists warranty_checked_at;
+alter table assets drop column if exists warranty_coverage;
+alter table assets drop column if exists warranty_status;
+alter table assets drop column if exists warranty_vendor;
@@ -0,0 +1,45 @@
+-- +goose Up
+-- Stockroom consumables: unserialized items such as toner and cables that
+-- are tracked by quantity rather than one asset row each. Every movement is
+-- a transaction; stock at or below low_stock_threshold opens a restock
+-- ticket, one at a time.
+create table if not exists consumables (
+    id uuid primary key default gen_random_uuid(),
+    name text not null unique,
+    sku text unique,
+    unit text not null default 'each',
+    location text,
+    quantity int not null default 0 check (quantity >= 0),
+    low_stock_threshold int check (low_stock_threshold >= 0),
+    restock_team_id uuid references teams(id) on delete set null,
+    restock_ticket_id uuid references tickets(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create table if not exists consumable_transactions (
+    id uuid primary key default gen_random_uuid(),
+    consumable_id uuid not null references consumables(id) on delete cascade,
+    kind text not null check (kind in ('issue', 'return', 'restock', 'adjust')),
+    -- change is signed: negative for issues and downward adjustments.
+    change int not null,
+    balance int not null,
+    ticket_id uuid references tickets(id) on delete set null,
+    user_id uuid references users(id) on delete set null,
+    actor_id uuid references users(id) on delete set null,
+    note text,
+    created_at timestamptz not null default now()
+);
+create index if not exists consumable_transactions_item_idx on consumable_transactions (consumable_id, created_at desc);
+create index if not exists consumable_transactions_ticket_idx on consumable_transactions (ticket_id) where ticket_id is not null;
+create index if not exists consumable_transactions_user_idx on consumable_transactions (user_id) where user_id is not null;
+
+alter table tickets drop constraint if exists tickets_source_check;
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom'));
+
+-- +goose Down
+alter table tickets drop constraint if exists tickets_source_check;
+update tickets set source = 'web' where source = 'stockroom';
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat'));
+drop table if exists consumable_transactions;
+drop table if exists consumables;
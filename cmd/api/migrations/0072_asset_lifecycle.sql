@@ -0,0 +1,20 @@
+-- +goose Up
+-- Assets move through ordered -> received -> active -> maintenance ->
+-- retired -> disposed; the allowed transitions live in the API. Receiving
+-- stamps received_at and disposal records how and when the asset left.
+alter table assets drop constraint if exists assets_status_check;
+alter table assets add constraint assets_status_check
+    check (status in ('ordered', 'received', 'active', 'inactive', 'maintenance', 'retired', 'disposed'));
+alter table assets add column if not exists received_at timestamptz;
+alter table assets add column if not exists disposal_method text
+    check (disposal_method in ('recycled', 'resold', 'donated', 'destroyed', 'returned_to_vendor'));
+alter table assets add column if not exists disposed_on date;
+
+-- +goose Down
+alter table assets drop column if exists disposed_on;
+alter table assets drop column if exists disposal_method;
+alter table assets drop column if exists received_at;
+alter table assets drop constraint if exists assets_status_check;
+update assets set status = 'inactive' where status in ('ordered', 'received');
+alter table assets add constraint assets_status_check
+    check (status in ('active', 'inactive', 'maintenance', 'retired', 'disposed'));
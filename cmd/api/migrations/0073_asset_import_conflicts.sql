@@ -0,0 +1,7 @@
+-- +goose Up
+-- Imports report rows duplicating existing assets apart from rows that
+-- failed validation.
+alter table asset_bulk_operations add column if not exists conflicts jsonb not null default '[]'::jsonb;
+
+-- +goose Down
+alter table asset_bulk_operations drop column if exists conflicts;
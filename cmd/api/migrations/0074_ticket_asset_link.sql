@@ -0,0 +1,14 @@
+-- +goose Up
+-- A ticket can name the asset it is about; the ticket then shows what a
+-- failure of that asset takes down with it.
+alter table tickets add column if not exists asset_id uuid references assets(id) on delete set null;
+create index if not exists tickets_asset_id_idx on tickets(asset_id) where asset_id is not null;
+
+-- Queues can raise tickets linked to a single point of failure to this
+-- priority; null leaves priority alone.
+alter table queues add column if not exists spof_priority smallint check (spof_priority between 1 and 4);
+
+-- +goose Down
+alter table queues drop column if exists spof_priority;
+drop index if exists tickets_asset_id_idx;
+alter table tickets drop column if exists asset_id;
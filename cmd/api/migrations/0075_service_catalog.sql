@@ -0,0 +1,74 @@
+-- +goose Up
+-- The service catalog lists what requesters can order. An item's form is a
+-- custom field schema for the details a request needs, and its steps are
+-- the fulfillment workflow: approvals, in order, then tasks that each open
+-- a ticket for a team.
+create table if not exists catalog_items (
+    id uuid primary key default gen_random_uuid(),
+    name text not null unique,
+    description text not null default '',
+    category text,
+    form jsonb not null default '{"fields": []}'::jsonb,
+    steps jsonb not null default '[]'::jsonb,
+    team_id uuid references teams(id) on delete set null,
+    priority smallint not null default 3 check (priority between 1 and 4),
+    active boolean not null default true,
+    search tsvector generated always as (
+        to_tsvector('simple', name || ' ' || description || ' ' || coalesce(category, ''))
+    ) stored,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+create index if not exists catalog_items_search_idx on catalog_items using gin (search);
+
+-- Service requests are tracked apart from incident tickets, with their own
+-- REQ- numbers. Their steps are copied from the item when submitted so
+-- later edits to the item leave requests in flight alone.
+create sequence if not exists service_request_seq;
+create table if not exists service_requests (
+    id uuid primary key default gen_random_uuid(),
+    number text not null unique default 'REQ-' || nextval('service_request_seq'),
+    item_id uuid not null references catalog_items(id) on delete restrict,
+    requester_id uuid not null references requesters(id) on delete cascade,
+    requested_by uuid references users(id) on delete set null,
+    answers jsonb not null default '{}'::jsonb,
+    status text not null default 'pending_approval'
+        check (status in ('pending_approval', 'rejected', 'fulfilling', 'cancelled')),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    closed_at timestamptz
+);
+create index if not exists service_requests_requester_idx on service_requests (requester_id, created_at desc);
+create index if not exists service_requests_status_idx on service_requests (status, created_at desc);
+
+create table if not exists service_request_steps (
+    id uuid primary key default gen_random_uuid(),
+    request_id uuid not null references service_requests(id) on delete cascade,
+    position int not null,
+    kind text not null check (kind in ('approval', 'task')),
+    name text not null,
+    approver_id uuid references users(id) on delete set null,
+    team_id uuid references teams(id) on delete set null,
+    status text not null default 'waiting'
+        check (status in ('waiting', 'pending', 'approved', 'rejected', 'open', 'skipped')),
+    ticket_id uuid references tickets(id) on delete set null,
+    decided_by uuid references users(id) on delete set null,
+    comment text,
+    decided_at timestamptz,
+    unique (request_id, position)
+);
+create index if not exists service_request_steps_approver_idx on service_request_steps (approver_id) where status = 'pending';
+create index if not exists service_request_steps_ticket_idx on service_request_steps (ticket_id) where ticket_id is not null;
+
+alter table tickets drop constraint if exists tickets_source_check;
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom', 'catalog'));
+
+-- +goose Down
+alter table tickets drop constraint if exists tickets_source_check;
+update tickets set source = 'web' where source = 'catalog';
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom'));
+drop table if exists service_request_steps;
+drop table if exists service_requests;
+drop sequence if exists service_request_seq;
+drop table if exists catalog_items;
@@ -0,0 +1,42 @@
+-- +goose Up
+-- Business services are what the business sees fail: email, payroll, the
+-- web shop. Each is mapped to the assets supporting it directly; the asset
+-- relationship graph supplies the rest, so a failing asset can be traced to
+-- the services depending on it and a service to the assets it relies on.
+create table if not exists business_services (
+    id uuid primary key default gen_random_uuid(),
+    name text not null unique,
+    description text not null default '',
+    owner_team_id uuid references teams(id) on delete set null,
+    criticality text not null default 'medium' check (criticality in ('low', 'medium', 'high', 'critical')),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create table if not exists business_service_assets (
+    service_id uuid not null references business_services(id) on delete cascade,
+    asset_id uuid not null references assets(id) on delete cascade,
+    notes text,
+    created_at timestamptz not null default now(),
+    primary key (service_id, asset_id)
+);
+create index if not exists business_service_assets_asset_idx on business_service_assets (asset_id);
+
+-- Maintenance windows are scheduled per service and may not overlap.
+create table if not exists service_maintenance_windows (
+    id uuid primary key default gen_random_uuid(),
+    service_id uuid not null references business_services(id) on delete cascade,
+    title text not null,
+    notes text,
+    starts_at timestamptz not null,
+    ends_at timestamptz not null,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    check (ends_at > starts_at)
+);
+create index if not exists service_maintenance_windows_service_idx on service_maintenance_windows (service_id, ends_at);
+
+-- +goose Down
+drop table if exists service_maintenance_windows;
+drop table if exists business_service_assets;
+drop table if exists business_services;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- Announcements are banners shown to agents, the portal or both while they
+-- are active, such as planned-maintenance notices.
+create table if not exists announcements (
+    id uuid primary key default gen_random_uuid(),
+    title text not null,
+    body text not null default '',
+    severity text not null default 'info' check (severity in ('info', 'warning', 'critical')),
+    audience text not null default 'all' check (audience in ('all', 'staff', 'requesters')),
+    starts_at timestamptz not null default now(),
+    ends_at timestamptz,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    check (ends_at is null or ends_at > starts_at)
+);
+create index if not exists announcements_active_idx on announcements (starts_at, ends_at);
+
+-- +goose Down
+drop table if exists announcements;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Message-IDs of the emails of each ticket's thread, received and sent, so
+-- a reply naming one in In-Reply-To or References is added to the ticket
+-- it belongs to rather than opening another. refs holds the References
+-- header ("references" is reserved).
+create table if not exists ticket_email_messages (
+    message_id text primary key,
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    direction text not null check (direction in ('inbound', 'outbound')),
+    in_reply_to text,
+    refs text[] not null default '{}',
+    created_at timestamptz not null default now()
+);
+create index if not exists ticket_email_messages_ticket_idx on ticket_email_messages (ticket_id, created_at);
+
+insert into ticket_email_messages (message_id, ticket_id, direction, created_at)
+select message_id, ticket_id, 'inbound', created_at from email_inbound
+where message_id is not null and message_id <> '' and ticket_id is not null
+on conflict (message_id) do nothing;
+
+-- +goose Down
+drop table if exists ticket_email_messages;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- When the worker will next try a failed delivery, null once it succeeded
+-- or gave up. Deliveries retried by hand from the console never set it.
+alter table webhook_deliveries add column if not exists next_retry_at timestamptz;
+
+-- +goose Down
+alter table webhook_deliveries drop column if exists next_retry_at;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- A queue's intake form: what the portal asks for when a ticket is raised
+-- in the queue. builtins lists the ticket's own fields the form shows, as
+-- [{ key, required }]; fields is a custom field schema for the rest, whose
+-- answers land in the ticket's custom_json.
+create table if not exists intake_forms (
+    queue_id uuid primary key references queues(id) on delete cascade,
+    title text not null,
+    description text not null default '',
+    builtins jsonb not null default '[]'::jsonb,
+    fields jsonb not null default '{"fields": []}'::jsonb,
+    active boolean not null default true,
+    updated_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+-- +goose Down
+drop table if exists intake_forms;
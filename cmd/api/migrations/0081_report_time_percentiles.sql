@@ -0,0 +1,20 @@
+-- +goose Up
+-- First response and resolution time percentiles over every ticket, rebuilt
+-- by the worker with the daily summaries (see internal/reports). Rows cover
+-- all tickets (priority and team_id null), one priority, one team, or one
+-- priority within one team; tickets without a team only count in the first
+-- two. Percentiles cannot be combined across days, so unlike the daily
+-- tables these only answer reports over the whole history.
+create table if not exists report_time_percentiles (
+    metric text not null check (metric in ('first_response', 'resolution')),
+    priority smallint,
+    team_id uuid,
+    samples int not null,
+    p50_ms float8 not null,
+    p90_ms float8 not null,
+    p99_ms float8 not null
+);
+create index if not exists report_time_percentiles_metric_idx on report_time_percentiles(metric, team_id);
+
+-- +goose Down
+drop table if exists report_time_percentiles;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- The tag catalog, kept by admins, and the tags on each ticket. Names are
+-- unique ignoring case; color is a #rrggbb hex string.
+create table if not exists tags (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    color text not null default '#64748b',
+    description text not null default '',
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+create unique index if not exists tags_name_idx on tags (lower(name));
+
+create table if not exists ticket_tags (
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    tag_id uuid not null references tags(id) on delete cascade,
+    added_by uuid references users(id) on delete set null,
+    added_at timestamptz not null default now(),
+    primary key (ticket_id, tag_id)
+);
+create index if not exists ticket_tags_tag_idx on ticket_tags (tag_id);
+
+-- +goose Down
+drop table if exists ticket_tags;
+drop table if exists tags;
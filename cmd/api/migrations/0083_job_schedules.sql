@@ -0,0 +1,33 @@
+-- +goose Up
+-- job_schedules holds the cron expressions of the worker's recurring tasks.
+-- The tasks themselves are built into the worker; rows only choose when
+-- they run. A null next_run_at is due at once. The worker claims a due row
+-- by moving next_run_at forward and records how the run went.
+create table if not exists job_schedules (
+    name text primary key,
+    cron text not null,
+    timezone text not null default 'UTC',
+    enabled boolean not null default true,
+    description text not null default '',
+    next_run_at timestamptz,
+    last_run_at timestamptz,
+    last_finished_at timestamptz,
+    last_duration_ms bigint,
+    last_error text,
+    updated_by uuid references users(id) on delete set null,
+    updated_at timestamptz not null default now()
+);
+
+insert into job_schedules (name, cron, description) values
+    ('sla_clocks', '* * * * *', 'Advance SLA clocks and flag breaches'),
+    ('ooo_reassignments', '* * * * *', 'Suggest reassigning tickets of out-of-office assignees'),
+    ('ticket_aging', '*/5 * * * *', 'Remind and escalate idle tickets per queue aging rules'),
+    ('auto_close', '0 * * * *', 'Close resolved tickets after AUTO_CLOSE_RESOLVED_DAYS'),
+    ('contract_expiry', '0 * * * *', 'Warn account managers about ended support contracts'),
+    ('partition_maintenance', '0 2 * * *', 'Create and detach monthly table partitions'),
+    ('warranty_refresh', '0 4 * * *', 'Queue warranty lookups older than WARRANTY_REFRESH_DAYS'),
+    ('audit_export', '0 3 * * *', 'Archive the audit log to the audit bucket and prune old archives')
+on conflict (name) do nothing;
+
+-- +goose Down
+drop table if exists job_schedules;
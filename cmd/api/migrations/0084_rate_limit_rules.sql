@@ -0,0 +1,19 @@
+-- +goose Up
+-- Admin-managed rate limits. A request is checked against every enabled
+-- rule whose route pattern and method match it; limit requests are allowed
+-- per window_seconds for each key (client IP, user, or all callers).
+create table if not exists rate_limit_rules (
+    id uuid primary key default gen_random_uuid(),
+    name text not null unique,
+    route text not null,
+    method text not null default '*',
+    key_type text not null default 'ip' check (key_type in ('ip', 'user', 'global')),
+    "limit" int not null check ("limit" > 0),
+    window_seconds int not null check (window_seconds > 0),
+    enabled boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+-- +goose Down
+drop table if exists rate_limit_rules;
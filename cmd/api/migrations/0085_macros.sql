@@ -0,0 +1,24 @@
+-- +goose Up
+-- Canned responses agents insert into replies. A personal macro belongs to
+-- owner_id, a team macro to the members of team_id, and a global macro to
+-- every agent. Names are unique within each owner, team, or the global set.
+create table if not exists macros (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    body text not null,
+    scope text not null check (scope in ('personal', 'team', 'global')),
+    owner_id uuid references users(id) on delete cascade,
+    team_id uuid references teams(id) on delete cascade,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    check ((scope = 'personal') = (owner_id is not null)),
+    check ((scope = 'team') = (team_id is not null))
+);
+
+create unique index if not exists macros_name_idx
+    on macros (scope, coalesce(owner_id, team_id, '00000000-0000-0000-0000-000000000000'::uuid), lower(name));
+create index if not exists macros_team_idx on macros (team_id) where team_id is not null;
+
+-- +goose Down
+drop table if exists macros;
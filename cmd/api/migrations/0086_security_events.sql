@@ -0,0 +1,30 @@
+-- +goose Up
+-- Requests the API refused with 401, 403 or 429: who made them, on which
+-- route and why. Like ticket_access_log there are no foreign keys, so the
+-- log outlives users. The worker deletes rows older than
+-- SECURITY_LOG_RETENTION_DAYS.
+create table if not exists security_events (
+    id bigserial primary key,
+    actor_type text not null,
+    actor_id uuid,
+    email text,
+    method text not null,
+    route text not null,
+    path text not null,
+    status smallint not null,
+    reason text not null,
+    ip text,
+    user_agent text,
+    at timestamptz not null default now()
+);
+create index if not exists security_events_at_idx on security_events (at desc);
+create index if not exists security_events_actor_idx on security_events (actor_id, at desc);
+create index if not exists security_events_email_idx on security_events (lower(email), at desc);
+
+insert into job_schedules (name, cron, description) values
+    ('security_log_prune', '30 3 * * *', 'Delete security log entries older than SECURITY_LOG_RETENTION_DAYS')
+on conflict (name) do nothing;
+
+-- +goose Down
+delete from job_schedules where name = 'security_log_prune';
+drop table if exists security_events;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- A ticket merged into another is closed with merged_into pointing at the
+-- ticket that took its comments, attachments and watchers. There is no
+-- foreign key, as tickets may be partitioned.
+alter table tickets
+    add column if not exists merged_into uuid;
+create index if not exists tickets_merged_into_idx on tickets (merged_into) where merged_into is not null;
+
+-- +goose Down
+drop index if exists tickets_merged_into_idx;
+alter table tickets drop column if exists merged_into;
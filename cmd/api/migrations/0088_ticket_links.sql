@@ -0,0 +1,22 @@
+-- +goose Up
+-- Links between tickets, read from from_ticket_id: it is the parent of,
+-- blocks, or is related to to_ticket_id. A pair of tickets has at most one
+-- link of each kind, whichever way it points, and a ticket at most one
+-- parent.
+create table if not exists ticket_links (
+    id uuid primary key default gen_random_uuid(),
+    from_ticket_id uuid not null references tickets(id) on delete cascade,
+    to_ticket_id uuid not null references tickets(id) on delete cascade,
+    kind text not null check (kind in ('parent_of', 'related_to', 'blocks')),
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    check (from_ticket_id <> to_ticket_id)
+);
+create unique index if not exists ticket_links_pair_idx
+    on ticket_links (least(from_ticket_id, to_ticket_id), greatest(from_ticket_id, to_ticket_id), kind);
+create unique index if not exists ticket_links_parent_idx on ticket_links (to_ticket_id) where kind = 'parent_of';
+create index if not exists ticket_links_from_idx on ticket_links (from_ticket_id);
+create index if not exists ticket_links_to_idx on ticket_links (to_ticket_id);
+
+-- +goose Down
+drop table if exists ticket_links;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Machine translations of comments, one per comment and target language,
+-- so each is only paid for once. comment_id has no foreign key as
+-- ticket_comments may be partitioned.
+create table if not exists comment_translations (
+    comment_id uuid not null,
+    lang text not null,
+    source_lang text,
+    body_md text not null,
+    provider text not null,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    primary key (comment_id, lang)
+);
+
+-- +goose Down
+drop table if exists comment_translations;
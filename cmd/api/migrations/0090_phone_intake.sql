@@ -0,0 +1,30 @@
+-- +goose Up
+-- Telephony integrations raise tickets from calls. A call's recording stays
+-- with the provider: its attachment carries the external_url instead of an
+-- object, and as no user uploaded it uploader_id is empty.
+alter table tickets drop constraint if exists tickets_source_check;
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom', 'catalog', 'phone'));
+
+alter table attachments add column if not exists external_url text;
+alter table attachments alter column uploader_id drop not null;
+
+-- One row per call the webhook accepted. Providers retry deliveries, so the
+-- provider's call_id answers a repeat with the ticket already raised.
+create table if not exists phone_calls (
+    call_id text primary key,
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    caller text,
+    duration_seconds int check (duration_seconds >= 0),
+    recording_url text,
+    created_at timestamptz not null default now()
+);
+create index if not exists phone_calls_ticket_idx on phone_calls (ticket_id);
+
+-- +goose Down
+drop table if exists phone_calls;
+delete from attachments where uploader_id is null;
+alter table attachments alter column uploader_id set not null;
+alter table attachments drop column if exists external_url;
+alter table tickets drop constraint if exists tickets_source_check;
+update tickets set source = 'web' where source = 'phone';
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom', 'catalog'));
@@ -0,0 +1,35 @@
+-- +goose Up
+-- Website chat widgets raise tickets with source 'chat'. Admins issue each
+-- site a widget key; a visitor starting a conversation gets a token for
+-- that conversation alone. Only the sha256 of keys and tokens is stored.
+alter table tickets drop constraint if exists tickets_source_check;
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom', 'catalog', 'phone', 'chat'));
+
+create table if not exists chat_widgets (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    key_hash text not null unique,
+    queue_id uuid references queues(id) on delete set null,
+    created_by uuid references users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    last_used_at timestamptz,
+    revoked_at timestamptz
+);
+
+create table if not exists chat_conversations (
+    id uuid primary key default gen_random_uuid(),
+    widget_id uuid not null references chat_widgets(id) on delete cascade,
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    requester_id uuid not null references requesters(id) on delete cascade,
+    token_hash text not null unique,
+    created_at timestamptz not null default now(),
+    expires_at timestamptz not null
+);
+create index if not exists chat_conversations_ticket_idx on chat_conversations (ticket_id);
+
+-- +goose Down
+drop table if exists chat_conversations;
+drop table if exists chat_widgets;
+alter table tickets drop constraint if exists tickets_source_check;
+update tickets set source = 'web' where source = 'chat';
+alter table tickets add constraint tickets_source_check check (source in ('web', 'email', 'discord', 'csat', 'stockroom', 'catalog', 'phone'));
@@ -0,0 +1,32 @@
+-- +goose Up
+-- SLA escalation rules act when a ticket's SLA clock crosses threshold_pct
+-- percent of its response or resolution target: notify the assignee (or
+-- the team when unassigned) and the team lead, raise the priority one
+-- step, and move the ticket to an escalation team. Rules without a
+-- policy_id apply to every policy. Each rule fires once per ticket;
+-- sla_escalations records that it has.
+create table if not exists sla_escalation_rules (
+    id uuid primary key default gen_random_uuid(),
+    name text not null,
+    policy_id uuid references sla_policies(id) on delete cascade,
+    target text not null check (target in ('response', 'resolution')),
+    threshold_pct integer not null check (threshold_pct between 1 and 1000),
+    notify_assignee boolean not null default true,
+    notify_manager boolean not null default false,
+    bump_priority boolean not null default false,
+    escalate_team_id uuid references teams(id) on delete set null,
+    enabled boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create table if not exists sla_escalations (
+    ticket_id uuid not null references tickets(id) on delete cascade,
+    rule_id uuid not null references sla_escalation_rules(id) on delete cascade,
+    fired_at timestamptz not null default now(),
+    primary key (ticket_id, rule_id)
+);
+
+-- +goose Down
+drop table if exists sla_escalations;
+drop table if exists sla_escalation_rules;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- first_response_at is when staff first posted a public comment on the
+-- ticket. The worker stops the response clock there.
+-- Existing tickets are backfilled from their comments. Staff roles can come
+-- from token claims and IdP groups, which are not stored, so the backfill
+-- counts comments by anyone outside the requesting side: not the ticket's
+-- requester, not CC'd on it, and not holding the requester role alone.
+alter table tickets add column if not exists first_response_at timestamptz;
+
+update tickets t set first_response_at = fr.at
+from (select c.ticket_id, min(c.created_at) as at
+      from ticket_comments c
+      join tickets tk on tk.id = c.ticket_id
+      join users u on u.id = c.author_id
+      where not c.is_internal
+        and not exists (select 1 from requesters r where r.id = tk.requester_id and lower(r.email) = lower(u.email))
+        and not exists (select 1 from ticket_ccs cc where cc.ticket_id = c.ticket_id and lower(cc.email) = lower(u.email))
+        and (exists (select 1 from user_roles ur join roles ro on ro.id = ur.role_id where ur.user_id = u.id and ro.name <> 'requester')
+             or not exists (select 1 from user_roles ur where ur.user_id = u.id))
+      group by c.ticket_id) fr
+where fr.ticket_id = t.id and t.first_response_at is null;
+
+-- Response SLA attainment per day, team and queue, over tickets that were
+-- answered or are past their response target.
+alter table report_sla_daily add column if not exists response_total int not null default 0;
+alter table report_sla_daily add column if not exists response_met int not null default 0;
+
+-- +goose Down
+alter table report_sla_daily drop column if exists response_met;
+alter table report_sla_daily drop column if exists response_total;
+alter table tickets drop column if exists first_response_at;
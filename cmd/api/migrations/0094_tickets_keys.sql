@@ -0,0 +1,131 @@
+-- +goose Up
+-- tickets_keys now exists on every install, partitioned or not, kept in sync
+-- with tickets by trigger. Foreign keys from other tables to tickets are
+-- re-pointed at tickets_keys(id), as hd_partition_table does on conversion,
+-- so later migrations can reference tickets_keys(id) and apply either way.
+-- New foreign keys to tickets must do so.
+-- +goose StatementBegin
+do $$
+declare
+    r record;
+begin
+    if to_regclass('tickets_keys') is null then
+        create table tickets_keys (id uuid primary key);
+        insert into tickets_keys (id) select id from tickets;
+        create trigger tickets_keys_sync after insert or delete on tickets
+            for each row execute function hd_sync_keys('tickets_keys');
+    end if;
+    for r in
+        select conrelid::regclass as child, conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where confrelid = 'tickets'::regclass and contype = 'f' and conrelid <> 'tickets'::regclass
+    loop
+        execute format('alter table %s drop constraint %I', r.child, r.conname);
+        execute format('alter table %s add constraint %I %s', r.child, r.conname,
+            regexp_replace(r.def, 'REFERENCES (\S+\.)?tickets\(', 'REFERENCES tickets_keys('));
+    end loop;
+end;
+$$;
+-- +goose StatementEnd
+
+-- Converting a table whose keys table already exists must recreate its sync
+-- trigger even when no foreign key references the table itself any more.
+-- +goose StatementBegin
+create or replace function hd_partition_table(tbl text, col text) returns void as $$
+declare
+    r record;
+    stmts text[] := '{}';
+    keys text := tbl || '_keys';
+    has_refs boolean := false;
+    s text;
+begin
+    -- Indexes not backing a constraint.
+    for r in
+        select case
+                 when i.indisunique and not exists (
+                     select 1 from pg_attribute a
+                     where a.attrelid = i.indrelid and a.attnum = any(i.indkey) and a.attname = col)
+                 then replace(pg_get_indexdef(i.indexrelid), 'CREATE UNIQUE INDEX', 'CREATE INDEX')
+                 else pg_get_indexdef(i.indexrelid)
+               end as def
+        from pg_index i
+        where i.indrelid = tbl::regclass
+          and not exists (select 1 from pg_constraint c where c.conindid = i.indexrelid)
+    loop
+        stmts := stmts || r.def;
+    end loop;
+
+    for r in
+        select c.conname,
+               (select string_agg(quote_ident(a.attname), ', ' order by k.ord)
+                from unnest(c.conkey) with ordinality k(num, ord)
+                join pg_attribute a on a.attrelid = c.conrelid and a.attnum = k.num) as cols
+        from pg_constraint c
+        where c.conrelid = tbl::regclass and c.contype = 'u'
+    loop
+        stmts := stmts || format('alter table %I add constraint %I unique (%s, %I)', tbl, r.conname, r.cols, col);
+    end loop;
+
+    for r in
+        select conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where conrelid = tbl::regclass and contype = 'f'
+    loop
+        stmts := stmts || format('alter table %I add constraint %I %s', tbl, r.conname, r.def);
+    end loop;
+
+    for r in
+        select conrelid::regclass as child, conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where confrelid = tbl::regclass and contype = 'f' and conrelid <> tbl::regclass
+    loop
+        has_refs := true;
+        stmts := stmts || format('alter table %s add constraint %I %s', r.child, r.conname,
+            regexp_replace(r.def, 'REFERENCES (\S+\.)?' || tbl || '\(', 'REFERENCES ' || keys || '('));
+    end loop;
+
+    execute format('alter table %I rename to %I', tbl, tbl || '_unpartitioned');
+    execute format('create table %I (like %I including defaults including constraints including generated including identity including storage including comments) partition by range (%I)',
+        tbl, tbl || '_unpartitioned', col);
+    execute format('drop table %I cascade', tbl || '_unpartitioned');
+    execute format('alter table %I add primary key (id, %I)', tbl, col);
+    execute format('create table %I partition of %I default', tbl || '_default', tbl);
+    if has_refs or to_regclass(keys) is not null then
+        execute format('create table if not exists %I (id uuid primary key)', keys);
+        execute format('create trigger %I after insert or delete on %I for each row execute function hd_sync_keys(%L)',
+            tbl || '_keys_sync', tbl, keys);
+    end if;
+    foreach s in array stmts loop
+        execute s;
+    end loop;
+    insert into partitioned_tables (table_name, column_name) values (tbl, col);
+    perform hd_ensure_partitions(tbl, 3);
+end;
+$$ language plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- Partitioned installs keep tickets_keys. hd_partition_table is left as is:
+-- without tickets_keys it behaves as before.
+-- +goose StatementBegin
+do $$
+declare
+    r record;
+begin
+    if exists (select 1 from partitioned_tables where table_name = 'tickets') then
+        return;
+    end if;
+    for r in
+        select conrelid::regclass as child, conname, pg_get_constraintdef(oid) as def
+        from pg_constraint
+        where confrelid = 'tickets_keys'::regclass and contype = 'f'
+    loop
+        execute format('alter table %s drop constraint %I', r.child, r.conname);
+        execute format('alter table %s add constraint %I %s', r.child, r.conname,
+            regexp_replace(r.def, 'REFERENCES (\S+\.)?tickets_keys\(', 'REFERENCES tickets('));
+    end loop;
+    drop trigger if exists tickets_keys_sync on tickets;
+    drop table if exists tickets_keys;
+end;
+$$;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Sites allowed to embed each chat widget. The visitor endpoints answer
+-- CORS from this list alone, never from ALLOWED_ORIGINS; a widget without
+-- origins only works for callers that send no Origin header.
+alter table chat_widgets add column if not exists allowed_origins text[] not null default '{}';
+
+-- +goose Down
+alter table chat_widgets drop column if exists allowed_origins;
@@ -1,19 +1,51 @@
 package queues
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/sender"
+	"github.com/mark3748/helpdesk-go/internal/verify"
 )
 
 type Queue struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// UnverifiedPolicy is "allow", "flag" or "hold" for tickets from
+	// unverified requesters; null uses UNVERIFIED_REQUESTER_POLICY.
+	UnverifiedPolicy *string `json:"unverified_policy"`
+	// CSATEnabled is false for queues whose tickets never get a CSAT survey.
+	CSATEnabled bool `json:"csat_enabled"`
+	// CSATFollowUp opens a follow-up ticket for the team lead when one of
+	// the queue's tickets is rated bad.
+	CSATFollowUp bool `json:"csat_followup"`
+	// AgingRemindHours bumps a ticket and reminds its assignee once it has
+	// gone this many business hours without agent activity; null turns
+	// aging off for the queue.
+	AgingRemindHours *int `json:"aging_remind_hours"`
+	// AgingEscalateHours escalates an idle ticket to its team lead; it must
+	// be longer than AgingRemindHours.
+	AgingEscalateHours *int `json:"aging_escalate_hours"`
+	// EmailIdentity is how notification emails about the queue's tickets
+	// are sent; empty fields fall back to the ticket's team, then SMTP_FROM.
+	EmailIdentity sender.Identity `json:"email_identity"`
+	// SPOFPriority is the priority a ticket is raised to when it is linked
+	// to an asset that is a single point of failure; null leaves it alone.
+	SPOFPriority *int16 `json:"spof_priority"`
 }
 
+// maxAgingHours caps an aging threshold at a year of business hours.
+const maxAgingHours = 8760
+
 // List returns all queues sorted by name. Requires agent or manager role.
 func List(a *apppkg.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -38,7 +70,7 @@ func List(a *apppkg.App) gin.HandlerFunc {
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
-		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name from queues order by name`)
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name, unverified_policy, csat_enabled, csat_followup, email_identity, aging_remind_hours, aging_escalate_hours, spof_priority from queues order by name`)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -47,7 +79,7 @@ func List(a *apppkg.App) gin.HandlerFunc {
 		out := []Queue{}
 		for rows.Next() {
 			var q Queue
-			if err := rows.Scan(&q.ID, &q.Name); err != nil {
+			if err := rows.Scan(&q.ID, &q.Name, &q.UnverifiedPolicy, &q.CSATEnabled, &q.CSATFollowUp, &q.EmailIdentity, &q.AgingRemindHours, &q.AgingEscalateHours, &q.SPOFPriority); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -56,3 +88,104 @@ func List(a *apppkg.App) gin.HandlerFunc {
 		c.JSON(http.StatusOK, out)
 	}
 }
+
+// Update changes a queue's policy for unverified requesters, whether its
+// tickets get CSAT surveys, whether bad scores open follow-up tickets, its
+// email identity, its aging rule and the priority given to tickets linked
+// to a single point of failure. Only the fields present are changed; an
+// empty or null unverified_policy reverts to the configured default and a
+// null aging field or spof_priority turns that rule off. Requires admin.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		raw, setPolicy := in["unverified_policy"]
+		var policy *string
+		if setPolicy && json.Unmarshal(raw, &policy) != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"unverified_policy": "must be a string"})
+			return
+		}
+		if policy == nil {
+			policy = new(string)
+		}
+		if *policy != "" && !verify.ValidPolicy(*policy) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"unverified_policy": "must be allow, flag or hold"})
+			return
+		}
+		var csatEnabled *bool
+		if raw, ok := in["csat_enabled"]; ok && (json.Unmarshal(raw, &csatEnabled) != nil || csatEnabled == nil) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"csat_enabled": "must be true or false"})
+			return
+		}
+		var csatFollowUp *bool
+		if raw, ok := in["csat_followup"]; ok && (json.Unmarshal(raw, &csatFollowUp) != nil || csatFollowUp == nil) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"csat_followup": "must be true or false"})
+			return
+		}
+		aging := map[string]*int{}
+		for _, k := range []string{"aging_remind_hours", "aging_escalate_hours"} {
+			raw, ok := in[k]
+			if !ok {
+				continue
+			}
+			var h *int
+			if json.Unmarshal(raw, &h) != nil || (h != nil && (*h < 1 || *h > maxAgingHours)) {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{k: "must be null or a number of hours from 1 to 8760"})
+				return
+			}
+			aging[k] = h
+		}
+		raw, setSPOF := in["spof_priority"]
+		var spof *int16
+		if setSPOF && (json.Unmarshal(raw, &spof) != nil || (spof != nil && (*spof < 1 || *spof > 4))) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"spof_priority": "must be null or a priority from 1 to 4"})
+			return
+		}
+		_, setRemind := aging["aging_remind_hours"]
+		_, setEscalate := aging["aging_escalate_hours"]
+		var identity *sender.Identity
+		if raw, ok := in["email_identity"]; ok {
+			if json.Unmarshal(raw, &identity) != nil || identity == nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"email_identity": "must be an object"})
+				return
+			}
+			if errs := identity.Normalize(); errs != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+				return
+			}
+		}
+		ctx := c.Request.Context()
+		var q Queue
+		err := a.DB.QueryRow(ctx, `update queues set unverified_policy = case when $3 then nullif($1,'') else unverified_policy end,
+            csat_enabled = coalesce($4, csat_enabled),
+            email_identity = coalesce($5::jsonb, email_identity),
+            csat_followup = coalesce($6, csat_followup),
+            aging_remind_hours = case when $7 then $8::int else aging_remind_hours end,
+            aging_escalate_hours = case when $9 then $10::int else aging_escalate_hours end,
+            spof_priority = case when $11 then $12::smallint else spof_priority end
+            where id = $2 returning id::text, name, unverified_policy, csat_enabled, csat_followup, email_identity, aging_remind_hours, aging_escalate_hours, spof_priority`,
+			*policy, c.Param("id"), setPolicy, csatEnabled, identity, csatFollowUp,
+			setRemind, aging["aging_remind_hours"], setEscalate, aging["aging_escalate_hours"], setSPOF, spof).Scan(&q.ID, &q.Name, &q.UnverifiedPolicy, &q.CSATEnabled, &q.CSATFollowUp, &q.EmailIdentity, &q.AgingRemindHours, &q.AgingEscalateHours, &q.SPOFPriority)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "queue not found", nil)
+			return
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23514" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"aging_escalate_hours": "must be longer than aging_remind_hours"})
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "queue", q.ID, "queue_updated", map[string]any{"unverified_policy": q.UnverifiedPolicy, "csat_enabled": q.CSATEnabled, "csat_followup": q.CSATFollowUp, "email_identity": q.EmailIdentity,
+			"aging_remind_hours": q.AgingRemindHours, "aging_escalate_hours": q.AgingEscalateHours, "spof_priority": q.SPOFPriority}); err != nil {
+			log.Error().Err(err).Msg("audit queue update")
+		}
+		c.JSON(http.StatusOK, q)
+	}
+}
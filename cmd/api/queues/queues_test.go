@@ -1,6 +1,7 @@
 package queues
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/sender"
 )
 
 type qrow struct{ Queue }
@@ -38,12 +40,24 @@ func (r *qrows) Scan(dest ...any) error {
 	return nil
 }
 
-type qdb struct{ rows []qrow }
+type qdb struct {
+	rows []qrow
+	args []any
+}
 
 func (db *qdb) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
 	return &qrows{data: db.rows}, nil
 }
-func (db *qdb) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row { return nil }
+func (db *qdb) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	db.args = args
+	return &qrow{Queue{ID: args[1].(string), Name: "Alpha"}}
+}
+
+func (r *qrow) Scan(dest ...any) error {
+	*(dest[0].(*string)) = r.ID
+	*(dest[1].(*string)) = r.Name
+	return nil
+}
 func (db *qdb) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
 	return pgconn.CommandTag{}, nil
 }
@@ -83,3 +97,61 @@ func TestQueueListAuth(t *testing.T) {
 		t.Fatalf("expected 403, got %d", rr.Code)
 	}
 }
+
+func TestQueueUpdatePolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &qdb{}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PATCH("/queues/:id", Update(a))
+	do := func(body string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/queues/q1", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := do(`{"unverified_policy":"block"}`); code != http.StatusBadRequest || db.args != nil {
+		t.Fatalf("expected an unknown policy to be rejected, got %d", code)
+	}
+	if code := do(`{"unverified_policy":"hold"}`); code != http.StatusOK || db.args[0] != "hold" {
+		t.Fatalf("unexpected %d %v", code, db.args)
+	}
+	if code := do(`{"unverified_policy":null}`); code != http.StatusOK || db.args[0] != "" {
+		t.Fatalf("expected null to revert to the default, got %d %v", code, db.args)
+	}
+	if code := do(`{"csat_enabled":false}`); code != http.StatusOK || db.args[2] != false || *db.args[3].(*bool) {
+		t.Fatalf("expected only surveys to change, got %d %v", code, db.args)
+	}
+	if code := do(`{"csat_enabled":null}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a null csat_enabled to be rejected, got %d", code)
+	}
+	if code := do(`{"csat_followup":true}`); code != http.StatusOK || !*db.args[5].(*bool) || db.args[3].(*bool) != nil {
+		t.Fatalf("expected only follow-ups to change, got %d %v", code, db.args)
+	}
+	if code := do(`{"csat_followup":"yes"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected a non-boolean csat_followup to be rejected, got %d", code)
+	}
+	if code := do(`{"aging_remind_hours":8,"aging_escalate_hours":24}`); code != http.StatusOK || db.args[6] != true || *db.args[7].(*int) != 8 || *db.args[9].(*int) != 24 {
+		t.Fatalf("expected the aging rule to be saved, got %d %v", code, db.args)
+	}
+	if code := do(`{"aging_escalate_hours":null}`); code != http.StatusOK || db.args[6] != false || db.args[8] != true || db.args[9].(*int) != nil {
+		t.Fatalf("expected only escalation to be turned off, got %d %v", code, db.args)
+	}
+	for _, body := range []string{`{"aging_remind_hours":0}`, `{"aging_remind_hours":"8"}`, `{"aging_escalate_hours":9000}`} {
+		if code := do(body); code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, code)
+		}
+	}
+	if code := do(`{"spof_priority":2}`); code != http.StatusOK || db.args[10] != true || *db.args[11].(*int16) != 2 {
+		t.Fatalf("expected the SPOF priority to be saved, got %d %v", code, db.args)
+	}
+	if code := do(`{"spof_priority":5}`); code != http.StatusBadRequest {
+		t.Fatalf("expected an out-of-range SPOF priority to be rejected, got %d", code)
+	}
+	if code := do(`{"email_identity":{"reply_to":"not an address"}}`); code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid reply_to to be rejected, got %d", code)
+	}
+	if code := do(`{"email_identity":{"from_name":" Billing ","reply_to":"billing@example.com"}}`); code != http.StatusOK || db.args[4].(*sender.Identity).FromName != "Billing" {
+		t.Fatalf("expected the identity to be saved, got %d %v", code, db.args)
+	}
+}
@@ -0,0 +1,247 @@
+// Package ratelimits manages the admin-configured rate limit rules and
+// enforces them.
+package ratelimits
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	metricspkg "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/internal/ratelimit"
+)
+
+// rulesTTL bounds how stale an instance's copy of the rules can get after
+// another instance saves them.
+const rulesTTL = 30 * time.Second
+
+// Enforcer applies the enabled rules to requests. Rules are cached briefly
+// since they are checked on every request; on a load error the last good
+// copy is kept.
+type Enforcer struct {
+	db  app.DB
+	rdb *redis.Client
+
+	mu    sync.Mutex
+	at    time.Time
+	rules []ratelimit.Rule
+}
+
+// NewEnforcer returns an Enforcer reading rules from db and counting
+// requests in rdb. Without either it lets every request through.
+func NewEnforcer(db app.DB, rdb *redis.Client) *Enforcer {
+	return &Enforcer{db: db, rdb: rdb}
+}
+
+// Invalidate makes the next request reload the rules.
+func (e *Enforcer) Invalidate() {
+	e.mu.Lock()
+	e.at = time.Time{}
+	e.mu.Unlock()
+}
+
+func (e *Enforcer) load(ctx context.Context) []ratelimit.Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if time.Since(e.at) < rulesTTL {
+		return e.rules
+	}
+	rules, err := ratelimit.LoadRules(ctx, e.db, true)
+	if err != nil {
+		log.Error().Err(err).Msg("load rate limit rules")
+	} else {
+		e.rules = rules
+	}
+	e.at = time.Now()
+	return e.rules
+}
+
+// Middleware enforces the rules counting by one of keys. Rules keyed by
+// user need the signed-in user, so the router runs the ip and global rules
+// for every request and the user rules after authentication.
+func (e *Enforcer) Middleware(keys ...ratelimit.KeyType) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if e == nil || e.db == nil || e.rdb == nil || route == "" {
+			c.Next()
+			return
+		}
+		if rest, ok := strings.CutPrefix(route, "/api"); ok && (rest == "" || rest[0] == '/') {
+			route = rest
+		}
+		for _, r := range e.load(c.Request.Context()) {
+			if !matchesKey(r.Key, keys) || !r.Matches(c.Request.Method, route) {
+				continue
+			}
+			key, ok := requestKey(c, r.Key)
+			if !ok {
+				continue
+			}
+			l := ratelimit.New(e.rdb, r.Limit, r.Window(), "rule:"+r.ID+":")
+			allowed, err := l.Allow(c.Request.Context(), key)
+			if err != nil || !allowed {
+				metricspkg.RateLimitRejectionsTotal.WithLabelValues(r.Name).Inc()
+				c.Set(audit.DenialReasonKey, "rate_limit_rule:"+r.Name)
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limited"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func matchesKey(k ratelimit.KeyType, keys []ratelimit.KeyType) bool {
+	for _, want := range keys {
+		if k == want {
+			return true
+		}
+	}
+	return false
+}
+
+// requestKey returns the key c counts under for k.
+func requestKey(c *gin.Context, k ratelimit.KeyType) (string, bool) {
+	switch k {
+	case ratelimit.KeyUser:
+		u, ok := c.Get("user")
+		if !ok {
+			return "", false
+		}
+		au, ok := u.(authpkg.AuthUser)
+		return au.ID, ok && au.ID != ""
+	case ratelimit.KeyGlobal:
+		return "all", true
+	}
+	return c.ClientIP(), true
+}
+
+// ListRules returns every rule by name.
+func ListRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules, err := ratelimit.LoadRules(c.Request.Context(), a.DB, false)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list rules", nil)
+			return
+		}
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+type ruleReq struct {
+	Name          string `json:"name"`
+	Route         string `json:"route"`
+	Method        string `json:"method"`
+	KeyType       string `json:"key_type"`
+	Limit         int    `json:"limit"`
+	WindowSeconds int    `json:"window_seconds"`
+	Enabled       *bool  `json:"enabled"`
+}
+
+// bindRule reads and validates a rule from the request body.
+func bindRule(c *gin.Context) (ratelimit.Rule, bool) {
+	var in ruleReq
+	if err := c.ShouldBindJSON(&in); err != nil {
+		app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+		return ratelimit.Rule{}, false
+	}
+	r := ratelimit.Rule{Name: in.Name, Route: in.Route, Method: in.Method, Key: ratelimit.KeyType(in.KeyType),
+		Limit: in.Limit, WindowSeconds: in.WindowSeconds, Enabled: true}
+	if in.Enabled != nil {
+		r.Enabled = *in.Enabled
+	}
+	if err := r.Validate(); err != nil {
+		app.AbortError(c, http.StatusBadRequest, "invalid_rule", err.Error(), nil)
+		return r, false
+	}
+	return r, true
+}
+
+// saveError maps the errors of saving a rule to responses.
+func saveError(c *gin.Context, err error, action string) {
+	var pge *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		app.AbortError(c, http.StatusNotFound, "not_found", "rule not found", nil)
+	case errors.As(err, &pge) && pge.Code == "23505":
+		app.AbortError(c, http.StatusConflict, "name_taken", "a rule with this name exists", nil)
+	default:
+		app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to "+action+" rule", nil)
+	}
+}
+
+// CreateRule adds a rule. It applies at once on this instance and within
+// 30 seconds on the others.
+func CreateRule(a *app.App, e *Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		created, err := ratelimit.ScanRule(a.DB.QueryRow(ctx, `insert into rate_limit_rules (name, route, method, key_type, "limit", window_seconds, enabled)
+            values ($1, $2, $3, $4, $5, $6, $7) returning `+ratelimit.RuleColumns,
+			r.Name, r.Route, r.Method, string(r.Key), r.Limit, r.WindowSeconds, r.Enabled).Scan)
+		if err != nil {
+			saveError(c, err, "create")
+			return
+		}
+		e.Invalidate()
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "rate_limit_rule", created.ID, "rate_limit_rule_created", created); err != nil {
+			log.Error().Err(err).Msg("audit rate limit rule create")
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// UpdateRule replaces a rule.
+func UpdateRule(a *app.App, e *Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		updated, err := ratelimit.ScanRule(a.DB.QueryRow(ctx, `update rate_limit_rules set (name, route, method, key_type, "limit", window_seconds, enabled)
+            = ($1, $2, $3, $4, $5, $6, $7), updated_at = now() where id = $8 returning `+ratelimit.RuleColumns,
+			r.Name, r.Route, r.Method, string(r.Key), r.Limit, r.WindowSeconds, r.Enabled, c.Param("id")).Scan)
+		if err != nil {
+			saveError(c, err, "update")
+			return
+		}
+		e.Invalidate()
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "rate_limit_rule", updated.ID, "rate_limit_rule_updated", updated); err != nil {
+			log.Error().Err(err).Msg("audit rate limit rule update")
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteRule removes a rule.
+func DeleteRule(a *app.App, e *Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var name string
+		err := a.DB.QueryRow(ctx, `delete from rate_limit_rules where id = $1 returning name`, c.Param("id")).Scan(&name)
+		if err != nil {
+			saveError(c, err, "delete")
+			return
+		}
+		e.Invalidate()
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "rate_limit_rule", c.Param("id"), "rate_limit_rule_deleted", map[string]any{"name": name}); err != nil {
+			log.Error().Err(err).Msg("audit rate limit rule delete")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
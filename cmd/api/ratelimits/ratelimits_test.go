@@ -0,0 +1,101 @@
+package ratelimits
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/ratelimit"
+)
+
+func rulesDB(rules ...ratelimit.Rule) *testutil.MockDB {
+	return &testutil.MockDB{QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		i := 0
+		return &testutil.MockRows{
+			NextFunc: func() bool { i++; return i <= len(rules) },
+			ScanFunc: func(dest ...any) error {
+				r := rules[i-1]
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*string), *dest[3].(*string) = r.ID, r.Name, r.Route, r.Method
+				*dest[4].(*string), *dest[5].(*int), *dest[6].(*int), *dest[7].(*bool) = string(r.Key), r.Limit, r.WindowSeconds, true
+				*dest[8].(*time.Time) = time.Now()
+				return nil
+			},
+		}, nil
+	}}
+}
+
+func TestEnforcer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	e := NewEnforcer(rulesDB(
+		ratelimit.Rule{ID: "1", Name: "comments", Route: "/tickets/*/comments", Method: "POST", Key: ratelimit.KeyIP, Limit: 1, WindowSeconds: 60},
+		ratelimit.Rule{ID: "2", Name: "exports", Route: "/exports*", Method: "*", Key: ratelimit.KeyUser, Limit: 1, WindowSeconds: 60},
+	), rdb)
+
+	r := gin.New()
+	r.Use(e.Middleware(ratelimit.KeyIP, ratelimit.KeyGlobal))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	signedIn := func(c *gin.Context) { c.Set("user", authpkg.AuthUser{ID: c.GetHeader("X-User")}) }
+	for _, prefix := range []string{"", "/api"} {
+		g := r.Group(prefix)
+		g.POST("/tickets/:id/comments", ok)
+		g.GET("/tickets/:id/comments", ok)
+		auth := g.Group("", signedIn, e.Middleware(ratelimit.KeyUser))
+		auth.GET("/exports", ok)
+	}
+	do := func(method, path, user string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("X-User", user)
+		r.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if do(http.MethodPost, "/tickets/1/comments", "") != http.StatusOK {
+		t.Fatal("first comment limited")
+	}
+	// The /api mount shares the rule and the client's bucket.
+	if code := do(http.MethodPost, "/api/tickets/2/comments", ""); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", code)
+	}
+	if do(http.MethodGet, "/tickets/1/comments", "") != http.StatusOK {
+		t.Fatal("a GET matched a POST rule")
+	}
+
+	if do(http.MethodGet, "/exports", "u1") != http.StatusOK || do(http.MethodGet, "/exports", "u2") != http.StatusOK {
+		t.Fatal("users share a bucket")
+	}
+	if do(http.MethodGet, "/api/exports", "u1") != http.StatusTooManyRequests {
+		t.Fatal("expected the user's second export limited")
+	}
+}
+
+func TestCreateRuleValidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := app.NewApp(app.Config{Env: "test"}, &testutil.MockDB{}, nil, nil, nil)
+	e := NewEnforcer(a.DB, nil)
+	a.R.POST("/settings/rate-limits", CreateRule(a, e))
+	for body, code := range map[string]int{
+		`{"name":"x","route":"/tickets","limit":0,"window_seconds":60}`:                      http.StatusBadRequest,
+		`{"name":"x","route":"/tickets","limit":5,"window_seconds":60,"key_type":"session"}`: http.StatusBadRequest,
+		`not json`: http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/settings/rate-limits", strings.NewReader(body)))
+		if rr.Code != code {
+			t.Errorf("%s: expected %d, got %d", body, code, rr.Code)
+		}
+	}
+}
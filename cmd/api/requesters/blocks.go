@@ -0,0 +1,181 @@
+package requesters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/blocklist"
+)
+
+// BlockedMessage is what a blocked requester is told when the portal turns
+// their submission away.
+const BlockedMessage = "We're unable to accept requests from this email address. If you think this is a mistake, please contact the service desk another way."
+
+// Block is a blocked requester address or domain.
+type Block struct {
+	ID        string     `json:"id"`
+	Pattern   string     `json:"pattern"`
+	Reason    *string    `json:"reason,omitempty"`
+	CreatedBy *string    `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Hits      int        `json:"hits"`
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+}
+
+const blockCols = `id::text, pattern, reason, created_by::text, created_at, hits, last_hit_at`
+
+func scanBlock(row pgx.Row) (Block, error) {
+	var b Block
+	err := row.Scan(&b.ID, &b.Pattern, &b.Reason, &b.CreatedBy, &b.CreatedAt, &b.Hits, &b.LastHitAt)
+	return b, err
+}
+
+// ListBlocks returns every block, most recent first.
+func ListBlocks(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+blockCols+` from requester_blocks order by created_at desc, id`)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list blocks", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Block{}
+		for rows.Next() {
+			b, err := scanBlock(rows)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list blocks", nil)
+				return
+			}
+			out = append(out, b)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateBlock blocks an address or a domain. With close_open_tickets the
+// matching requesters' unresolved tickets are closed in the same
+// transaction, each audited as a status change by the admin.
+func CreateBlock(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Pattern          string  `json:"pattern"`
+			Reason           *string `json:"reason"`
+			CloseOpenTickets bool    `json:"close_open_tickets"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		pattern, ok := blocklist.Normalize(in.Pattern)
+		if !ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"pattern": "must be an email address or a domain"})
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var b Block
+		var closed []string
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var err error
+			b, err = scanBlock(tx.QueryRow(ctx, `insert into requester_blocks (pattern, reason, created_by)
+                values ($1, nullif($2, ''), $3) returning `+blockCols, pattern, in.Reason, act.DBID()))
+			if err != nil {
+				return err
+			}
+			if in.CloseOpenTickets {
+				if closed, err = closeBlockedTickets(ctx, tx, act, pattern); err != nil {
+					return err
+				}
+			}
+			return audit.RecordDiff(ctx, tx, act, "requester_block", b.ID, "requester_block_created", map[string]any{
+				"pattern": pattern, "reason": in.Reason, "closed_tickets": len(closed)})
+		})
+		var pge *pgconn.PgError
+		if errors.As(err, &pge) && pge.Code == "23505" {
+			app.AbortError(c, http.StatusConflict, "already_blocked", "this address or domain is already blocked", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create block", nil)
+			return
+		}
+		for _, id := range closed {
+			eventspkg.Emit(ctx, a.DB, act, id, "ticket_updated", map[string]any{"id": id})
+		}
+		c.JSON(http.StatusCreated, gin.H{"block": b, "closed_tickets": len(closed)})
+	}
+}
+
+// closeBlockedTickets closes the unresolved tickets of requesters pattern
+// blocks and returns their IDs. Requesters are not emailed about it.
+func closeBlockedTickets(ctx context.Context, tx app.DB, act actor.Actor, pattern string) ([]string, error) {
+	rows, err := tx.Query(ctx, `with victims as (
+            select t.id, t.status from tickets t join requesters r on r.id = t.requester_id
+            where `+blocklist.Cond("lower(r.email)", "$1")+` and t.status not in ('Resolved', 'Closed')
+            for update of t
+        ), closed as (
+            update tickets t set status = 'Closed', updated_at = now() from victims v where t.id = v.id
+        ), history as (
+            insert into ticket_status_history (ticket_id, from_status, to_status, actor_id)
+            select id, status, 'Closed', $2 from victims
+        )
+        select id::text, status from victims`, pattern, act.DBID())
+	if err != nil {
+		return nil, err
+	}
+	type victim struct{ id, status string }
+	var victims []victim
+	for rows.Next() {
+		var v victim
+		if err := rows.Scan(&v.id, &v.status); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		victims = append(victims, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(victims))
+	for _, v := range victims {
+		if err := audit.Record(ctx, tx, act, "ticket", v.id, "ticket_updated", audit.Diff(map[string]any{"status": v.status}, map[string]any{"status": "Closed"})); err != nil {
+			return nil, err
+		}
+		ids = append(ids, v.id)
+	}
+	return ids, nil
+}
+
+// DeleteBlock lifts a block. Tickets it closed stay closed.
+func DeleteBlock(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var pattern string
+		err := a.DB.QueryRow(ctx, `delete from requester_blocks where id::text = $1 returning pattern`, c.Param("id")).Scan(&pattern)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "block not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete block", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "requester_block", c.Param("id"), "requester_block_deleted", map[string]any{"pattern": pattern}); err != nil {
+			log.Error().Err(err).Msg("audit requester block delete")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
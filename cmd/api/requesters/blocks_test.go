@@ -0,0 +1,93 @@
+package requesters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCreateBlockClosesOpenTickets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var pattern string
+	var audited []string
+	dup := false
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if dup {
+					return &pgconn.PgError{Code: "23505"}
+				}
+				pattern = args[0].(string)
+				*dest[0].(*string) = "b1"
+				*dest[1].(*string) = pattern
+				*dest[4].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if !strings.Contains(sql, "status = 'Closed'") || args[0] != "example.com" {
+				t.Fatalf("unexpected close query %s %v", sql, args)
+			}
+			ids := []string{"t1", "t2"}
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(ids) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = ids[i-1]
+					*dest[1].(*string) = "Open"
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "audit_events") {
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/admin/requester-blocks", CreateBlock(a))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/requester-blocks", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := post(`{"pattern":"not a domain"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	rr := post(`{"pattern":"@Example.com","reason":"abuse","close_open_tickets":true}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Block         Block `json:"block"`
+		ClosedTickets int   `json:"closed_tickets"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if pattern != "example.com" || out.Block.Pattern != "example.com" || out.ClosedTickets != 2 {
+		t.Fatalf("unexpected response %+v", out)
+	}
+	if strings.Join(audited, ",") != "ticket_updated,ticket_updated,requester_block_created" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+
+	dup = true
+	if rr := post(`{"pattern":"example.com"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate, got %d", rr.Code)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 )
 
@@ -17,6 +19,12 @@ type Requester struct {
 	Email string `json:"email,omitempty"`
 	Name  string `json:"display_name,omitempty"`
 	Phone string `json:"phone,omitempty"`
+	// Locale is the requester's preferred language (BCP 47) for
+	// requester-facing pages such as the CSAT survey.
+	Locale string `json:"locale,omitempty"`
+	// Verified is false until a requester first seen through the portal or
+	// inbound email follows their verification link.
+	Verified *bool `json:"verified,omitempty"`
 }
 
 var phoneRe = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
@@ -87,9 +95,9 @@ func Get(a *app.App) gin.HandlerFunc {
 			c.JSON(http.StatusOK, Requester{ID: c.Param("id")})
 			return
 		}
-		const q = `select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,'') from requesters where id=$1`
+		const q = `select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,''), coalesce(locale,'') from requesters where id=$1`
 		var r Requester
-		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("id")).Scan(&r.ID, &r.Email, &r.Name, &r.Phone); err != nil {
+		if err := a.DB.QueryRow(c.Request.Context(), q, c.Param("id")).Scan(&r.ID, &r.Email, &r.Name, &r.Phone, &r.Locale); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
@@ -101,15 +109,16 @@ func Get(a *app.App) gin.HandlerFunc {
 func Update(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in struct {
-			Email *string `json:"email"`
-			Name  *string `json:"display_name"`
-			Phone *string `json:"phone"`
+			Email  *string `json:"email"`
+			Name   *string `json:"display_name"`
+			Phone  *string `json:"phone"`
+			Locale *string `json:"locale"`
 		}
 		if err := c.ShouldBindJSON(&in); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
 			return
 		}
-		if in.Email == nil && in.Name == nil && in.Phone == nil {
+		if in.Email == nil && in.Name == nil && in.Phone == nil && in.Locale == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "no fields"})
 			return
 		}
@@ -140,14 +149,28 @@ func Update(a *app.App) gin.HandlerFunc {
 			args = append(args, *in.Phone)
 			idx++
 		}
+		if in.Locale != nil {
+			loc := strings.TrimSpace(*in.Locale)
+			if loc != "" {
+				tag, err := language.Parse(loc)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_locale"})
+					return
+				}
+				loc = tag.String()
+			}
+			set = append(set, fmt.Sprintf("locale=nullif($%d,'')", idx))
+			args = append(args, loc)
+			idx++
+		}
 		if a.DB == nil {
 			c.JSON(http.StatusOK, Requester{ID: c.Param("id")})
 			return
 		}
 		args = append(args, c.Param("id"))
-		sql := fmt.Sprintf("update requesters set %s where id=$%d returning id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,'')", strings.Join(set, ","), idx)
+		sql := fmt.Sprintf("update requesters set %s where id=$%d returning id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,''), coalesce(locale,'')", strings.Join(set, ","), idx)
 		var r Requester
-		if err := a.DB.QueryRow(c.Request.Context(), sql, args...).Scan(&r.ID, &r.Email, &r.Name, &r.Phone); err != nil {
+		if err := a.DB.QueryRow(c.Request.Context(), sql, args...).Scan(&r.ID, &r.Email, &r.Name, &r.Phone, &r.Locale); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
@@ -165,7 +188,7 @@ func Search(a *app.App) gin.HandlerFunc {
 		q := strings.TrimSpace(c.Query("q"))
 		if q == "" {
 			const sql = `
-			select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,'')
+			select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,''), verified
 			from requesters
 			order by created_at desc
 			limit 100`
@@ -178,7 +201,7 @@ func Search(a *app.App) gin.HandlerFunc {
 			out := []Requester{}
 			for rows.Next() {
 				var r Requester
-				if err := rows.Scan(&r.ID, &r.Email, &r.Name, &r.Phone); err != nil {
+				if err := rows.Scan(&r.ID, &r.Email, &r.Name, &r.Phone, &r.Verified); err != nil {
 					continue
 				}
 				out = append(out, r)
@@ -192,7 +215,7 @@ func Search(a *app.App) gin.HandlerFunc {
 		pattern := "%" + q + "%"
 		// Limit to 20 results
 		const sql = `
-			select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,'')
+			select id::text, coalesce(email,''), coalesce(name,''), coalesce(phone,''), verified
 			from requesters
 			where name ILIKE $1 or email ILIKE $1
 			order by name asc, email asc
@@ -206,7 +229,7 @@ func Search(a *app.App) gin.HandlerFunc {
 		out := []Requester{}
 		for rows.Next() {
 			var r Requester
-			if err := rows.Scan(&r.ID, &r.Email, &r.Name, &r.Phone); err != nil {
+			if err := rows.Scan(&r.ID, &r.Email, &r.Name, &r.Phone, &r.Verified); err != nil {
 				continue
 			}
 			out = append(out, r)
@@ -0,0 +1,98 @@
+package requesters
+
+import (
+	"errors"
+	"html"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/verify"
+)
+
+// verifyPage renders the minimal page shown after following an emailed
+// verification link.
+func verifyPage(c *gin.Context, status int, msg string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.String(status, `<!doctype html><html><body><p>`+html.EscapeString(msg)+`</p></body></html>`)
+}
+
+// ConfirmEmail follows a verification link. It is public: the token is the
+// proof of ownership.
+func ConfirmEmail(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := verify.Confirm(c.Request.Context(), a.DB, c.Query("token"))
+		if errors.Is(err, verify.ErrInvalidToken) {
+			verifyPage(c, http.StatusBadRequest, "This verification link is invalid or has expired.")
+			return
+		}
+		if err != nil {
+			verifyPage(c, http.StatusInternalServerError, "We could not verify your email address. Please try again later.")
+			return
+		}
+		if err := audit.RecordDiff(c.Request.Context(), a.DB, actor.System("email_verification"), "requester", id, "requester_verified", map[string]any{"verified": true}); err != nil {
+			log.Error().Err(err).Msg("audit requester verify")
+		}
+		verifyPage(c, http.StatusOK, "Thanks, your email address is verified.")
+	}
+}
+
+// ResendVerification sends an unverified requester a new link.
+func ResendVerification(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var email string
+		var verified bool
+		err := a.DB.QueryRow(ctx, `select coalesce(email,''), verified from requesters where id = $1`, c.Param("id")).Scan(&email, &verified)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "requester not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		if verified {
+			app.AbortError(c, http.StatusConflict, "already_verified", "requester is already verified", nil)
+			return
+		}
+		if email == "" {
+			app.AbortError(c, http.StatusBadRequest, "no_email", "requester has no email address", nil)
+			return
+		}
+		if err := verify.Issue(ctx, a.DB, c.Param("id"), email, a.Cfg.PublicURL); err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// MarkVerified lets an agent vouch for a requester, for example after
+// confirming their identity by phone. It releases any held tickets.
+func MarkVerified(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var id string
+		err := a.DB.QueryRow(ctx, `update requesters set verified = true, verified_at = coalesce(verified_at, now())
+            where id = $1 returning id::text`, c.Param("id")).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "requester not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "requester", id, "requester_verified", map[string]any{"verified": true}); err != nil {
+			log.Error().Err(err).Msg("audit requester verify")
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "verified": true})
+	}
+}
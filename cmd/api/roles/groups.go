@@ -0,0 +1,99 @@
+package roles
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// GroupMapping maps one IdP group to the internal roles its members get.
+type GroupMapping struct {
+	Group string   `json:"group"`
+	Roles []string `json:"roles"`
+}
+
+var errUnknownRole = errors.New("unknown role")
+
+// ListGroupMappings returns the OIDC group-to-role mappings ordered by group.
+func ListGroupMappings(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select m.group_name, array_agg(r.name order by r.name)
+            from oidc_group_roles m join roles r on r.id = m.role_id
+            group by m.group_name order by m.group_name`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list group mappings", nil)
+			return
+		}
+		defer rows.Close()
+		out := []GroupMapping{}
+		for rows.Next() {
+			var m GroupMapping
+			if err := rows.Scan(&m.Group, &m.Roles); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list group mappings", nil)
+				return
+			}
+			out = append(out, m)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// SetGroupMapping replaces the roles mapped to a group. An empty role list
+// removes the mapping. Users pick up the change on their next token
+// validation or OIDC login.
+func SetGroupMapping(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in GroupMapping
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		in.Group = strings.TrimSpace(in.Group)
+		if in.Group == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "group required", map[string]string{"group": "required"})
+			return
+		}
+		seen := map[string]bool{}
+		roles := []string{}
+		for _, r := range in.Roles {
+			r = strings.TrimSpace(r)
+			if r != "" && !seen[r] {
+				seen[r] = true
+				roles = append(roles, r)
+			}
+		}
+		sort.Strings(roles)
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if _, err := tx.Exec(ctx, `delete from oidc_group_roles where group_name = $1`, in.Group); err != nil {
+				return err
+			}
+			if len(roles) == 0 {
+				return nil
+			}
+			tag, err := tx.Exec(ctx, `insert into oidc_group_roles (group_name, role_id)
+                select $1, id from roles where name = any($2)`, in.Group, roles)
+			if err != nil {
+				return err
+			}
+			if tag.RowsAffected() != int64(len(roles)) {
+				return errUnknownRole
+			}
+			return nil
+		})
+		if errors.Is(err, errUnknownRole) {
+			apppkg.AbortError(c, http.StatusBadRequest, "unknown_role", "unknown role", map[string]string{"roles": "must name existing roles"})
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save group mapping", nil)
+			return
+		}
+		c.JSON(http.StatusOK, GroupMapping{Group: in.Group, Roles: roles})
+	}
+}
@@ -1,16 +1,29 @@
 package roles
 
 import (
+	"errors"
 	"net/http"
+	"regexp"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 )
 
-// List returns all role names defined in the roles table.
+// List returns all role names defined in the roles table, or every role with
+// its permissions when ?detail=true.
 func List(a *apppkg.App) gin.HandlerFunc {
+	detailed := listDetailed(a)
 	return func(c *gin.Context) {
+		if c.Query("detail") == "true" {
+			detailed(c)
+			return
+		}
 		rows, err := a.DB.Query(c.Request.Context(), `select name from roles order by name`)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -29,3 +42,176 @@ func List(a *apppkg.App) gin.HandlerFunc {
 		c.JSON(http.StatusOK, out)
 	}
 }
+
+// nameRe restricts custom role names to lowercase identifiers.
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+// Role is a role with its permissions. Built-in roles report the
+// permissions defined in code.
+type Role struct {
+	ID          string   `json:"id,omitempty"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+	Builtin     bool     `json:"builtin"`
+}
+
+type roleReq struct {
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	Permissions *[]string `json:"permissions"`
+}
+
+// validPermissions returns the first permission not in the catalog, if any.
+func validPermissions(perms []string) (string, bool) {
+	for _, p := range perms {
+		if !authpkg.ValidPermission(p) {
+			return p, false
+		}
+	}
+	return "", true
+}
+
+func builtin(name string) bool {
+	_, ok := authpkg.BuiltinRoles[name]
+	return ok
+}
+
+// Permissions returns the catalog custom roles are built from.
+func Permissions(c *gin.Context) {
+	c.JSON(http.StatusOK, authpkg.Catalog)
+}
+
+func listDetailed(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name, coalesce(description,''), permissions from roles order by name`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list roles", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Role{}
+		for rows.Next() {
+			var r Role
+			if err := rows.Scan(&r.ID, &r.Name, &r.Description, &r.Permissions); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list roles", nil)
+				return
+			}
+			if perms, ok := authpkg.BuiltinRoles[r.Name]; ok {
+				r.Builtin, r.Permissions = true, perms
+			}
+			if r.Permissions == nil {
+				r.Permissions = []string{}
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Create defines a custom role.
+func Create(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in roleReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		r := Role{Name: strings.TrimSpace(in.Name), Permissions: []string{}}
+		if !nameRe.MatchString(r.Name) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid name", map[string]string{"name": "2-32 lowercase letters, digits, - or _"})
+			return
+		}
+		if builtin(r.Name) {
+			apppkg.AbortError(c, http.StatusConflict, "role_exists", "role already exists", nil)
+			return
+		}
+		if in.Description != nil {
+			r.Description = strings.TrimSpace(*in.Description)
+		}
+		if in.Permissions != nil {
+			r.Permissions = *in.Permissions
+		}
+		if p, ok := validPermissions(r.Permissions); !ok {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "unknown permission", map[string]string{"permissions": "unknown permission: " + p})
+			return
+		}
+		err := a.DB.QueryRow(c.Request.Context(), `insert into roles (name, description, permissions) values ($1, nullif($2,''), $3)
+            on conflict (name) do nothing returning id::text`, r.Name, r.Description, r.Permissions).Scan(&r.ID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusConflict, "role_exists", "role already exists", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create role", nil)
+			return
+		}
+		if err := audit.RecordDiff(c.Request.Context(), a.DB, authpkg.Actor(c), "role", r.ID, "role_created", r); err != nil {
+			log.Error().Err(err).Msg("audit role create")
+		}
+		c.JSON(http.StatusCreated, r)
+	}
+}
+
+// Update changes a custom role's description or permissions. Holders pick
+// up new permissions on their next request.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if builtin(name) {
+			apppkg.AbortError(c, http.StatusForbidden, "builtin_role", "built-in roles cannot be modified", nil)
+			return
+		}
+		var in roleReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		if in.Permissions != nil {
+			if p, ok := validPermissions(*in.Permissions); !ok {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "unknown permission", map[string]string{"permissions": "unknown permission: " + p})
+				return
+			}
+		}
+		r := Role{Name: name}
+		err := a.DB.QueryRow(c.Request.Context(), `update roles set description = coalesce($2, description), permissions = coalesce($3, permissions)
+            where name = $1 returning id::text, coalesce(description,''), permissions`, name, in.Description, in.Permissions).Scan(&r.ID, &r.Description, &r.Permissions)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "role not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update role", nil)
+			return
+		}
+		if err := audit.RecordDiff(c.Request.Context(), a.DB, authpkg.Actor(c), "role", r.ID, "role_updated", r); err != nil {
+			log.Error().Err(err).Msg("audit role update")
+		}
+		c.JSON(http.StatusOK, r)
+	}
+}
+
+// Delete removes a custom role and unassigns it from every user.
+func Delete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if builtin(name) {
+			apppkg.AbortError(c, http.StatusForbidden, "builtin_role", "built-in roles cannot be deleted", nil)
+			return
+		}
+		var id string
+		err := a.DB.QueryRow(c.Request.Context(), `delete from roles where name = $1 returning id::text`, name).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "role not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete role", nil)
+			return
+		}
+		if err := audit.RecordDiff(c.Request.Context(), a.DB, authpkg.Actor(c), "role", id, "role_deleted", map[string]any{"name": name}); err != nil {
+			log.Error().Err(err).Msg("audit role delete")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
@@ -108,3 +110,98 @@ func TestList(t *testing.T) {
 		})
 	}
 }
+
+func TestRoleCRUD(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var inserted []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				switch {
+				case strings.Contains(sql, "insert into roles"):
+					inserted = args
+					*(dest[0].(*string)) = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+				case strings.Contains(sql, "delete from roles"):
+					if args[0] != "auditor" {
+						return pgx.ErrNoRows
+					}
+					*(dest[0].(*string)) = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+				}
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/roles", Create(a))
+	a.R.PATCH("/roles/:name", Update(a))
+	a.R.DELETE("/roles/:name", Delete(a))
+
+	for _, tc := range []struct {
+		name, method, path, body string
+		want                     int
+	}{
+		{"create", http.MethodPost, "/roles", `{"name":"auditor","permissions":["audit.read"]}`, http.StatusCreated},
+		{"bad name", http.MethodPost, "/roles", `{"name":"Read Only"}`, http.StatusBadRequest},
+		{"unknown permission", http.MethodPost, "/roles", `{"name":"billing","permissions":["billing.pay"]}`, http.StatusBadRequest},
+		{"shadow built-in", http.MethodPost, "/roles", `{"name":"admin"}`, http.StatusConflict},
+		{"edit built-in", http.MethodPatch, "/roles/agent", `{"permissions":[]}`, http.StatusForbidden},
+		{"delete built-in", http.MethodDelete, "/roles/admin", ``, http.StatusForbidden},
+		{"delete custom", http.MethodDelete, "/roles/auditor", ``, http.StatusNoContent},
+		{"delete missing", http.MethodDelete, "/roles/billing", ``, http.StatusNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+	if inserted[0] != "auditor" || len(inserted[2].([]string)) != 1 {
+		t.Fatalf("unexpected insert args: %v", inserted)
+	}
+}
+
+func TestSetGroupMapping(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	known := map[string]bool{"agent": true, "manager": true}
+	var deleted, inserted int
+	db := &testutil.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "delete from oidc_group_roles") {
+				deleted++
+				return pgconn.CommandTag{}, nil
+			}
+			n := 0
+			for _, r := range args[1].([]string) {
+				if known[r] {
+					n++
+				}
+			}
+			inserted++
+			return pgconn.NewCommandTag("INSERT 0 " + strconv.Itoa(n)), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PUT("/oidc/group-roles", SetGroupMapping(a))
+
+	for _, tc := range []struct {
+		name, body string
+		want       int
+	}{
+		{"map", `{"group":"Helpdesk Leads","roles":["manager","agent","agent"]}`, http.StatusOK},
+		{"unknown role", `{"group":"Helpdesk Leads","roles":["agent","root"]}`, http.StatusBadRequest},
+		{"clear", `{"group":"Helpdesk Leads","roles":[]}`, http.StatusOK},
+		{"missing group", `{"roles":["agent"]}`, http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/oidc/group-roles", strings.NewReader(tc.body)))
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d: %s", tc.name, tc.want, rr.Code, rr.Body.String())
+		}
+		if tc.name == "map" && strings.TrimSpace(rr.Body.String()) != `{"group":"Helpdesk Leads","roles":["agent","manager"]}` {
+			t.Fatalf("unexpected body: %s", rr.Body.String())
+		}
+	}
+	if deleted != 3 || inserted != 2 {
+		t.Fatalf("expected 3 deletes and 2 inserts, got %d and %d", deleted, inserted)
+	}
+}
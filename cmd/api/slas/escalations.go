@@ -0,0 +1,210 @@
+package slas
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// EscalationRule acts on tickets whose SLA clock for Target crosses
+// ThresholdPct percent of the target. The worker fires each rule once per
+// ticket. Rules without a PolicyID apply to every policy.
+type EscalationRule struct {
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	PolicyID       *string   `json:"policy_id"`
+	Target         string    `json:"target"`
+	ThresholdPct   int       `json:"threshold_pct"`
+	NotifyAssignee bool      `json:"notify_assignee"`
+	NotifyManager  bool      `json:"notify_manager"`
+	BumpPriority   bool      `json:"bump_priority"`
+	EscalateTeamID *string   `json:"escalate_team_id"`
+	Enabled        bool      `json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+const escalationColumns = `id::text, name, policy_id::text, target, threshold_pct, notify_assignee, notify_manager,
+    bump_priority, escalate_team_id::text, enabled, created_at, updated_at`
+
+func scanEscalationRule(row pgx.Row) (EscalationRule, error) {
+	var r EscalationRule
+	err := row.Scan(&r.ID, &r.Name, &r.PolicyID, &r.Target, &r.ThresholdPct, &r.NotifyAssignee, &r.NotifyManager,
+		&r.BumpPriority, &r.EscalateTeamID, &r.Enabled, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// ListEscalationRules returns every SLA escalation rule, lowest threshold
+// first.
+func ListEscalationRules(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []EscalationRule{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+escalationColumns+` from sla_escalation_rules order by target, threshold_pct, created_at`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list escalation rules", nil)
+			return
+		}
+		defer rows.Close()
+		out := []EscalationRule{}
+		for rows.Next() {
+			r, err := scanEscalationRule(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list escalation rules", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+type escalationReq struct {
+	Name           string  `json:"name"`
+	PolicyID       *string `json:"policy_id"`
+	Target         string  `json:"target"`
+	ThresholdPct   int     `json:"threshold_pct"`
+	NotifyAssignee *bool   `json:"notify_assignee"`
+	NotifyManager  bool    `json:"notify_manager"`
+	BumpPriority   bool    `json:"bump_priority"`
+	EscalateTeamID *string `json:"escalate_team_id"`
+	Enabled        *bool   `json:"enabled"`
+}
+
+// bindEscalationRule reads and validates a rule from the request body.
+// notify_assignee and enabled default to true.
+func bindEscalationRule(c *gin.Context) (EscalationRule, bool) {
+	var in escalationReq
+	if err := c.ShouldBindJSON(&in); err != nil {
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+		return EscalationRule{}, false
+	}
+	r := EscalationRule{
+		Name: strings.TrimSpace(in.Name), PolicyID: in.PolicyID, Target: in.Target, ThresholdPct: in.ThresholdPct,
+		NotifyAssignee: in.NotifyAssignee == nil || *in.NotifyAssignee, NotifyManager: in.NotifyManager,
+		BumpPriority: in.BumpPriority, EscalateTeamID: in.EscalateTeamID, Enabled: in.Enabled == nil || *in.Enabled,
+	}
+	fields := map[string]string{}
+	if r.Name == "" {
+		fields["name"] = "required"
+	}
+	if r.Target != "response" && r.Target != "resolution" {
+		fields["target"] = "must be response or resolution"
+	}
+	if r.ThresholdPct < 1 || r.ThresholdPct > 1000 {
+		fields["threshold_pct"] = "must be between 1 and 1000"
+	}
+	for name, id := range map[string]**string{"policy_id": &r.PolicyID, "escalate_team_id": &r.EscalateTeamID} {
+		if *id != nil && strings.TrimSpace(**id) == "" {
+			*id = nil
+		}
+		if *id != nil && uuid.Validate(**id) != nil {
+			fields[name] = "must be a UUID"
+		}
+	}
+	if !r.NotifyAssignee && !r.NotifyManager && !r.BumpPriority && r.EscalateTeamID == nil {
+		fields["actions"] = "at least one action required"
+	}
+	if len(fields) > 0 {
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid escalation rule", fields)
+		return r, false
+	}
+	return r, true
+}
+
+// escalationSaveError maps the errors of saving a rule to responses.
+func escalationSaveError(c *gin.Context, err error, action string) {
+	var pge *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		apppkg.AbortError(c, http.StatusNotFound, "not_found", "escalation rule not found", nil)
+	case errors.As(err, &pge) && pge.Code == "23503":
+		field := "escalate_team_id"
+		if strings.Contains(pge.ConstraintName, "policy") {
+			field = "policy_id"
+		}
+		apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid escalation rule", map[string]string{field: "not found"})
+	default:
+		apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to "+action+" escalation rule", nil)
+	}
+}
+
+// CreateEscalationRule adds an SLA escalation rule. The worker picks it up
+// on its next SLA clock tick; tickets already past the threshold are not
+// escalated.
+func CreateEscalationRule(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindEscalationRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		created, err := scanEscalationRule(a.DB.QueryRow(ctx, `insert into sla_escalation_rules
+                (name, policy_id, target, threshold_pct, notify_assignee, notify_manager, bump_priority, escalate_team_id, enabled)
+            values ($1, $2::uuid, $3, $4, $5, $6, $7, $8::uuid, $9) returning `+escalationColumns,
+			r.Name, r.PolicyID, r.Target, r.ThresholdPct, r.NotifyAssignee, r.NotifyManager, r.BumpPriority, r.EscalateTeamID, r.Enabled))
+		if err != nil {
+			escalationSaveError(c, err, "create")
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "sla_escalation_rule", created.ID, "sla_escalation_rule_created", created); err != nil {
+			log.Error().Err(err).Msg("audit sla escalation rule create")
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// UpdateEscalationRule replaces an SLA escalation rule. Tickets it already
+// fired for are not escalated again.
+func UpdateEscalationRule(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, ok := bindEscalationRule(c)
+		if !ok {
+			return
+		}
+		ctx := c.Request.Context()
+		updated, err := scanEscalationRule(a.DB.QueryRow(ctx, `update sla_escalation_rules
+            set (name, policy_id, target, threshold_pct, notify_assignee, notify_manager, bump_priority, escalate_team_id, enabled)
+              = ($1, $2::uuid, $3, $4, $5, $6, $7, $8::uuid, $9), updated_at = now()
+            where id::text = $10 returning `+escalationColumns,
+			r.Name, r.PolicyID, r.Target, r.ThresholdPct, r.NotifyAssignee, r.NotifyManager, r.BumpPriority, r.EscalateTeamID, r.Enabled, c.Param("id")))
+		if err != nil {
+			escalationSaveError(c, err, "update")
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "sla_escalation_rule", updated.ID, "sla_escalation_rule_updated", updated); err != nil {
+			log.Error().Err(err).Msg("audit sla escalation rule update")
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// DeleteEscalationRule removes an SLA escalation rule.
+func DeleteEscalationRule(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var name string
+		err := a.DB.QueryRow(ctx, `delete from sla_escalation_rules where id::text = $1 returning name`, c.Param("id")).Scan(&name)
+		if err != nil {
+			escalationSaveError(c, err, "delete")
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "sla_escalation_rule", c.Param("id"), "sla_escalation_rule_deleted", map[string]any{"name": name}); err != nil {
+			log.Error().Err(err).Msg("audit sla escalation rule delete")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
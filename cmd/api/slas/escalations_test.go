@@ -0,0 +1,78 @@
+package slas
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCreateEscalationRule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var inserted []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if !strings.Contains(sql, "insert into sla_escalation_rules") {
+					return pgx.ErrNoRows
+				}
+				if *args[7].(*string) == "22222222-2222-2222-2222-222222222222" {
+					return &pgconn.PgError{Code: "23503", ConstraintName: "sla_escalation_rules_escalate_team_id_fkey"}
+				}
+				inserted = args
+				*dest[0].(*string), *dest[1].(*string), *dest[3].(*string) = "r1", args[0].(string), args[2].(string)
+				*dest[4].(*int) = args[3].(int)
+				*dest[5].(*bool), *dest[6].(*bool), *dest[7].(*bool) = args[4].(bool), args[5].(bool), args[6].(bool)
+				*dest[8].(**string), *dest[9].(*bool) = args[7].(*string), args[8].(bool)
+				*dest[10].(*time.Time), *dest[11].(*time.Time) = time.Now(), time.Now()
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/slas/escalations", CreateEscalationRule(a))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/slas/escalations", strings.NewReader(body)))
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"name":"Warn","target":"first_reply","threshold_pct":75}`,
+		`{"name":"Warn","target":"resolution","threshold_pct":0}`,
+		`{"name":"Warn","target":"resolution","threshold_pct":75,"notify_assignee":false}`,
+		`{"name":"Warn","target":"resolution","threshold_pct":75,"escalate_team_id":"tier2"}`,
+		`{"name":"Warn","target":"resolution","threshold_pct":75,"escalate_team_id":"22222222-2222-2222-2222-222222222222"}`,
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d %s", body, rr.Code, rr.Body.String())
+		}
+	}
+	if inserted != nil {
+		t.Fatal("an invalid rule was saved")
+	}
+
+	rr := post(`{"name":" Breach ","target":"resolution","threshold_pct":100,"notify_manager":true,"bump_priority":true,
+		"escalate_team_id":"11111111-1111-1111-1111-111111111111"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+	var got EscalationRule
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Breach" || got.PolicyID != nil || !got.NotifyAssignee || !got.NotifyManager || !got.BumpPriority || !got.Enabled ||
+		got.EscalateTeamID == nil || *got.EscalateTeamID != "11111111-1111-1111-1111-111111111111" {
+		t.Fatalf("unexpected rule: %+v", got)
+	}
+}
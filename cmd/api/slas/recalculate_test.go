@@ -0,0 +1,112 @@
+package slas
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+// recalcDB serves a ticket created 3h ago on an always-open UTC calendar with
+// a stored clock of 1h and no status changes.
+type recalcDB struct{ execs []string }
+
+func (db *recalcDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+		if strings.Contains(sql, "from calendars") {
+			*dest[0].(*string) = "UTC"
+			return nil
+		}
+		*dest[0].(*string) = "cal-1"
+		*dest[1].(*time.Time) = time.Now().Add(-3 * time.Hour)
+		*dest[2].(*string) = "Open"
+		*dest[3].(*int64) = int64(time.Hour / time.Millisecond)
+		*dest[4].(*int64) = int64(time.Hour / time.Millisecond)
+		return nil
+	}}
+}
+
+func (db *recalcDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if strings.Contains(sql, "business_hours") {
+		served := 0
+		return &testutil.MockRows{
+			NextFunc: func() bool { served++; return served <= 7 },
+			ScanFunc: func(dest ...any) error {
+				*dest[0].(*int) = served - 1
+				*dest[1].(*int) = 0
+				*dest[2].(*int) = 24 * 3600
+				return nil
+			},
+		}, nil
+	}
+	return &testutil.MockRows{NextFunc: func() bool { return false }}, nil
+}
+
+func (db *recalcDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, sql)
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *recalcDB) Begin(ctx context.Context) (pgx.Tx, error) { return nil, nil }
+
+func postRecalc(t *testing.T, a *apppkg.App, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/slas/recalculate", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRecalculate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &recalcDB{}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/slas/recalculate", authpkg.Middleware(a), Recalculate(a))
+
+	if rr := postRecalc(t, a, `{}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without ticket_ids, got %d", rr.Code)
+	}
+
+	rr := postRecalc(t, a, `{"ticket_ids":["t1"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("dry run status = %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		DryRun  bool `json:"dry_run"`
+		Results []struct {
+			Changed       bool  `json:"changed"`
+			NewResolution int64 `json:"new_resolution_elapsed_ms"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.DryRun || len(out.Results) != 1 || !out.Results[0].Changed {
+		t.Fatalf("unexpected dry run result: %s", rr.Body.String())
+	}
+	if got := time.Duration(out.Results[0].NewResolution) * time.Millisecond; got < 3*time.Hour-time.Minute || got > 3*time.Hour+time.Minute {
+		t.Fatalf("expected ~3h recomputed, got %v", got)
+	}
+	if len(db.execs) != 0 {
+		t.Fatalf("dry run must not write, got %v", db.execs)
+	}
+
+	if rr := postRecalc(t, a, `{"ticket_ids":["t1"],"dry_run":false}`); rr.Code != http.StatusOK {
+		t.Fatalf("apply status = %d", rr.Code)
+	}
+	if len(db.execs) != 2 || !strings.Contains(db.execs[0], "update ticket_sla_clocks") || !strings.Contains(db.execs[1], "audit_events") {
+		t.Fatalf("expected clock update and audit entry, got %v", db.execs)
+	}
+}
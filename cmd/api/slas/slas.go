@@ -1,11 +1,18 @@
 package slas
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	slapkg "github.com/mark3748/helpdesk-go/internal/sla"
 )
 
@@ -20,3 +27,151 @@ func List(a *apppkg.App) gin.HandlerFunc {
 		c.JSON(http.StatusOK, slas)
 	}
 }
+
+type policyReq struct {
+	Name                 string `json:"name"`
+	Priority             int    `json:"priority"`
+	ResponseTargetMins   int    `json:"response_target_mins"`
+	ResolutionTargetMins int    `json:"resolution_target_mins"`
+	UpdateCadenceMins    *int   `json:"update_cadence_mins"`
+}
+
+// Update replaces an SLA policy's name, priority and targets. Changing a
+// target starts a new policy version; open tickets keep the version that
+// applied when they were created, and reports measure them against it.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in policyReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		in.Name = strings.TrimSpace(in.Name)
+		fields := map[string]string{}
+		if in.Name == "" {
+			fields["name"] = "required"
+		}
+		if in.Priority < 1 || in.Priority > 4 {
+			fields["priority"] = "must be between 1 and 4"
+		}
+		if in.ResponseTargetMins <= 0 {
+			fields["response_target_mins"] = "must be positive"
+		}
+		if in.ResolutionTargetMins <= 0 {
+			fields["resolution_target_mins"] = "must be positive"
+		}
+		if in.UpdateCadenceMins != nil && *in.UpdateCadenceMins <= 0 {
+			fields["update_cadence_mins"] = "must be positive"
+		}
+		if len(fields) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid policy", fields)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var out slapkg.Policy
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			out, err = slapkg.UpdatePolicy(ctx, tx, slapkg.Policy{
+				ID:                   c.Param("id"),
+				Name:                 in.Name,
+				Priority:             in.Priority,
+				ResponseTargetMins:   in.ResponseTargetMins,
+				ResolutionTargetMins: in.ResolutionTargetMins,
+				UpdateCadenceMins:    in.UpdateCadenceMins,
+			}, act.ID)
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, act, "sla_policy", out.ID, "sla_policy_updated", out)
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "policy not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update policy", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Versions lists a policy's effective-dated versions, newest first.
+func Versions(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		versions, err := slapkg.ListVersions(c.Request.Context(), a.DB, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list versions", nil)
+			return
+		}
+		if len(versions) == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "policy not found", nil)
+			return
+		}
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+const recalcMaxTickets = 500
+
+type recalcReq struct {
+	TicketIDs []string `json:"ticket_ids" binding:"required"`
+	// DryRun defaults to true so a bare request only reports the diff.
+	DryRun *bool `json:"dry_run"`
+}
+
+// Recalculate recomputes SLA clocks for the given tickets from their status
+// history and the current calendars. With dry_run (the default) it only
+// returns the diff; otherwise changed clocks are written back and each repair
+// is recorded in audit_events.
+func Recalculate(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in recalcReq
+		if err := c.ShouldBindJSON(&in); err != nil || len(in.TicketIDs) == 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "ticket_ids required", nil)
+			return
+		}
+		if len(in.TicketIDs) > recalcMaxTickets {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "too many tickets", map[string]string{"max": strconv.Itoa(recalcMaxTickets)})
+			return
+		}
+		dryRun := in.DryRun == nil || *in.DryRun
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"dry_run": dryRun, "results": []slapkg.ClockRepair{}})
+			return
+		}
+		act := authpkg.Actor(c)
+		ctx := c.Request.Context()
+		now := time.Now()
+		results := make([]slapkg.ClockRepair, 0, len(in.TicketIDs))
+		errs := map[string]string{}
+		for _, id := range in.TicketIDs {
+			r, err := slapkg.RecomputeClock(ctx, a.DB, id, now)
+			if err != nil {
+				errs[id] = err.Error()
+				continue
+			}
+			results = append(results, r)
+			if dryRun || !r.Changed {
+				continue
+			}
+			err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+				if _, err := tx.Exec(ctx, `update ticket_sla_clocks set response_elapsed_ms=$1, resolution_elapsed_ms=$2,
+                    last_started_at = case when $3 then null else $4 end where ticket_id=$5`,
+					r.NewResponseMS, r.NewResolutionMS, !slapkg.Running(r.Status) && !r.Paused, now, id); err != nil {
+					return err
+				}
+				return audit.RecordDiff(ctx, tx, act, "ticket", id, "sla_recalculated", r)
+			})
+			if err != nil {
+				errs[id] = err.Error()
+			}
+		}
+		out := gin.H{"dry_run": dryRun, "results": results}
+		if len(errs) > 0 {
+			out["errors"] = errs
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
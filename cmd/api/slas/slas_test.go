@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +14,7 @@ import (
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 type fakeDB struct{ rows []slapolicy }
@@ -103,3 +105,65 @@ func TestList(t *testing.T) {
 		t.Fatalf("unexpected output: %v", out)
 	}
 }
+
+func TestUpdateVersionsTargets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var execs []string
+	var saved []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != "p1" {
+					return pgx.ErrNoRows
+				}
+				if strings.Contains(sql, "for update") {
+					*dest[0].(*int), *dest[1].(*int), *dest[2].(*int) = 2, 60, 480
+					return nil
+				}
+				saved = args
+				*dest[0].(*string), *dest[1].(*string), *dest[6].(*int) = "p1", args[1].(string), args[6].(int)
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			execs = append(execs, sql)
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.PUT("/slas/:id", authpkg.Middleware(a), Update(a))
+	put := func(id, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/slas/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+	versioned := func() bool {
+		for _, sql := range execs {
+			if strings.Contains(sql, "insert into sla_policy_versions") {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A rename keeps the current version.
+	if rr := put("p1", `{"name":"Gold","priority":1,"response_target_mins":60,"resolution_target_mins":480}`); rr.Code != http.StatusOK || versioned() || saved[6] != 2 {
+		t.Fatalf("rename should not version: %d %s %v", rr.Code, rr.Body.String(), execs)
+	}
+	execs = nil
+	rr := put("p1", `{"name":"Gold","priority":1,"response_target_mins":30,"resolution_target_mins":480}`)
+	if rr.Code != http.StatusOK || !versioned() || saved[6] != 3 || !strings.Contains(rr.Body.String(), `"version":3`) {
+		t.Fatalf("target change should open version 3: %d %s %v", rr.Code, rr.Body.String(), execs)
+	}
+	if !strings.Contains(execs[0], "set effective_to = now()") {
+		t.Fatalf("current version not closed first: %v", execs)
+	}
+	if rr := put("p1", `{"name":"Gold","priority":5,"response_target_mins":0,"resolution_target_mins":480}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if rr := put("p2", `{"name":"Gold","priority":1,"response_target_mins":30,"resolution_target_mins":480}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,217 @@
+// Package suggestions serves AI-drafted replies for agents.
+package suggestions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	"github.com/mark3748/helpdesk-go/internal/kb"
+	"github.com/mark3748/helpdesk-go/internal/suggest"
+)
+
+const (
+	defaultCount = 1
+	maxCount     = 3
+	// maxSources is how many related KB articles are offered to the model.
+	maxSources = 3
+	maxTokens  = 600
+)
+
+// Suggestion is one drafted reply with the slugs of the KB articles it cites.
+type Suggestion struct {
+	Text    string   `json:"text"`
+	Sources []string `json:"sources"`
+}
+
+// Usage is what a request cost.
+type Usage struct {
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	LatencyMS        int64 `json:"latency_ms"`
+}
+
+// Response is returned by SuggestReply. Sources lists every article offered
+// to the model, whether or not a suggestion cites it.
+type Response struct {
+	Suggestions []Suggestion     `json:"suggestions"`
+	Sources     []suggest.Source `json:"sources"`
+	Model       string           `json:"model"`
+	Usage       Usage            `json:"usage"`
+}
+
+// Config returns the suggestion provider settings from the API config.
+func Config(a *app.App) suggest.Config {
+	return suggest.Config{
+		Provider: a.Cfg.SuggestionsProvider,
+		BaseURL:  a.Cfg.SuggestionsBaseURL,
+		APIKey:   a.Cfg.SuggestionsAPIKey,
+		Model:    a.Cfg.SuggestionsModel,
+	}
+}
+
+// commentsQuery returns the latest public comments, newest first, flagging
+// the requester's own. Internal notes are never sent to a provider.
+const commentsQuery = `
+select c.body_md, (c.author_id is null or c.author_requester_id is not null or lower(u.email) = lower(r.email))
+from ticket_comments c
+join tickets t on t.id = c.ticket_id
+left join users u on u.id = c.author_id
+left join requesters r on r.id = t.requester_id
+where c.ticket_id = $1 and not c.is_internal
+order by c.created_at desc
+limit 10`
+
+// SuggestReply drafts replies to a ticket with the configured provider.
+// Title, description and public comments are sanitized before they are sent,
+// along with related KB articles the drafts may cite. Every call to the
+// provider is counted and logged to reply_suggestions. Responds 501 while no
+// provider is configured.
+func SuggestReply(a *app.App) gin.HandlerFunc {
+	type req struct {
+		Count        int    `json:"count"`
+		Instructions string `json:"instructions"`
+	}
+	return func(c *gin.Context) {
+		cfg := Config(a)
+		if !cfg.Enabled() {
+			app.AbortError(c, http.StatusNotImplemented, "suggestions_disabled", "reply suggestions are not enabled", nil)
+			return
+		}
+		provider, err := suggest.New(cfg)
+		if err != nil {
+			log.Error().Err(err).Msg("reply suggestions misconfigured")
+			app.AbortError(c, http.StatusServiceUnavailable, "suggestions_misconfigured", "reply suggestions are misconfigured", nil)
+			return
+		}
+		var in req
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid request body", nil)
+				return
+			}
+		}
+		if in.Count == 0 {
+			in.Count = defaultCount
+		}
+		if in.Count < 1 || in.Count > maxCount {
+			app.AbortError(c, http.StatusBadRequest, "invalid_count", "count must be between 1 and 3", nil)
+			return
+		}
+
+		ctx := c.Request.Context()
+		id := c.Param("id")
+		tc, err := loadContext(ctx, a, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		tc.Instructions = in.Instructions
+
+		start := time.Now()
+		out, err := provider.Complete(ctx, suggest.Request{Messages: suggest.Build(tc), N: in.Count, MaxTokens: maxTokens})
+		latency := time.Since(start).Milliseconds()
+		usage := Usage{PromptTokens: out.PromptTokens, CompletionTokens: out.CompletionTokens, LatencyMS: latency}
+		metrics.ReplySuggestionTokensTotal.WithLabelValues("prompt").Add(float64(usage.PromptTokens))
+		metrics.ReplySuggestionTokensTotal.WithLabelValues("completion").Add(float64(usage.CompletionTokens))
+		if err != nil {
+			metrics.ReplySuggestionsTotal.WithLabelValues("error").Inc()
+			record(c, a, id, cfg.Provider, out.Model, 0, len(tc.Sources), usage, err)
+			log.Warn().Err(err).Str("ticket", id).Msg("reply suggestion failed")
+			app.AbortError(c, http.StatusBadGateway, "suggestion_failed", "the suggestion provider failed", nil)
+			return
+		}
+		metrics.ReplySuggestionsTotal.WithLabelValues("ok").Inc()
+
+		if len(out.Choices) > in.Count {
+			out.Choices = out.Choices[:in.Count]
+		}
+		res := Response{Sources: tc.Sources, Model: out.Model, Usage: usage}
+		for _, text := range out.Choices {
+			res.Suggestions = append(res.Suggestions, Suggestion{Text: text, Sources: suggest.Cited(text, tc.Sources)})
+		}
+		record(c, a, id, cfg.Provider, out.Model, len(res.Suggestions), len(tc.Sources), usage, nil)
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// loadContext gathers what the model is told about ticket id.
+func loadContext(ctx context.Context, a *app.App, id string) (suggest.Context, error) {
+	var tc suggest.Context
+	if err := a.DB.QueryRow(ctx, `select title, coalesce(description,''), status, coalesce(language,'')
+        from tickets where id = $1`, id).Scan(&tc.Title, &tc.Description, &tc.Status, &tc.Language); err != nil {
+		return tc, err
+	}
+	rows, err := a.DB.Query(ctx, commentsQuery, id)
+	if err != nil {
+		return tc, err
+	}
+	for rows.Next() {
+		var cm suggest.Comment
+		if err := rows.Scan(&cm.Body, &cm.FromRequester); err != nil {
+			rows.Close()
+			return tc, err
+		}
+		tc.Comments = append([]suggest.Comment{cm}, tc.Comments...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return tc, err
+	}
+
+	// Articles are matched on the ticket and the latest requester message,
+	// which is usually where the open question is.
+	text := tc.Title + " " + tc.Description
+	for i := len(tc.Comments) - 1; i >= 0; i-- {
+		if tc.Comments[i].FromRequester {
+			text += " " + tc.Comments[i].Body
+			break
+		}
+	}
+	arts, err := kb.Related(ctx, a.DB, text, maxSources)
+	if err != nil {
+		return tc, err
+	}
+	tc.Sources = []suggest.Source{}
+	for _, art := range arts {
+		tc.Sources = append(tc.Sources, suggest.Source{ID: art.ID, Slug: art.Slug, Title: art.Title, Body: art.BodyMD})
+	}
+	return tc, nil
+}
+
+// record logs one provider call. Failures are logged and otherwise ignored:
+// usage tracking must not cost the agent their draft.
+func record(c *gin.Context, a *app.App, ticketID, provider, model string, suggestions, sources int, u Usage, callErr error) {
+	var userID *string
+	if uVal, ok := c.Get("user"); ok {
+		if au, ok := uVal.(authpkg.AuthUser); ok && au.ID != "" {
+			userID = &au.ID
+		}
+	}
+	var errText *string
+	if callErr != nil {
+		s := callErr.Error()
+		errText = &s
+	}
+	if model == "" {
+		model = a.Cfg.SuggestionsModel
+	}
+	if _, err := a.DB.Exec(c.Request.Context(), `insert into reply_suggestions
+        (ticket_id, user_id, provider, model, suggestions, sources, prompt_tokens, completion_tokens, latency_ms, error)
+        values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`,
+		ticketID, userID, provider, model, suggestions, sources, u.PromptTokens, u.CompletionTokens, u.LatencyMS, errText); err != nil {
+		log.Error().Err(err).Str("ticket", ticketID).Msg("record reply suggestion usage")
+	}
+}
@@ -0,0 +1,139 @@
+package suggestions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/suggest"
+)
+
+func rows(list [][]any) *testutil.MockRows {
+	i := 0
+	return &testutil.MockRows{
+		NextFunc: func() bool { i++; return i <= len(list) },
+		ScanFunc: func(dest ...interface{}) error {
+			for j, v := range list[i-1] {
+				switch d := dest[j].(type) {
+				case *string:
+					*d = v.(string)
+				case *bool:
+					*d = v.(bool)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestSuggestReply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var prompt []suggest.Message
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Messages []suggest.Message `json:"messages"`
+			N        int               `json:"n"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		prompt = in.Messages
+		if in.N == 3 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"model":"test-model","choices":[{"message":{"content":"Please reset the client, see [KB:vpn-reset]."}},{"message":{"content":"Could you try again?"}}],"usage":{"prompt_tokens":90,"completion_tokens":20}}`))
+	}))
+	defer llm.Close()
+
+	var usage [][]any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				if args[0] != "t1" {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string) = "VPN keeps dropping"
+				*dest[1].(*string) = "Mail me at ann@example.com"
+				*dest[2].(*string) = "Open"
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			if strings.Contains(sql, "from kb_articles") {
+				return rows([][]any{{"k1", "vpn-reset", "Resetting the VPN client", "Quit the client and..."}}), nil
+			}
+			if !strings.Contains(sql, "not c.is_internal") {
+				t.Fatalf("expected internal notes to be excluded: %s", sql)
+			}
+			return rows([][]any{{"Any update?", true}, {"Looking into it", false}}), nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into reply_suggestions") {
+				usage = append(usage, args)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	post := func(a *apppkg.App, id, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/"+id+"/suggest-reply", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	off := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	off.R.POST("/tickets/:id/suggest-reply", SuggestReply(off))
+	if rr := post(off, "t1", ""); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 while disabled, got %d", rr.Code)
+	}
+
+	a := apppkg.NewApp(apppkg.Config{Env: "test", SuggestionsProvider: "local", SuggestionsBaseURL: llm.URL}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/suggest-reply", SuggestReply(a))
+	rr := post(a, "t1", `{"count":2,"instructions":"offer a call"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	var res Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Suggestions) != 2 || len(res.Suggestions[0].Sources) != 1 || res.Suggestions[0].Sources[0] != "vpn-reset" || len(res.Suggestions[1].Sources) != 0 {
+		t.Fatalf("unexpected suggestions %+v", res.Suggestions)
+	}
+	if len(res.Sources) != 1 || res.Sources[0].Slug != "vpn-reset" || res.Model != "test-model" || res.Usage.PromptTokens != 90 {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	user := prompt[1].Content
+	if strings.Contains(user, "ann@example.com") || !strings.Contains(user, "offer a call") {
+		t.Fatalf("expected sanitized context with instructions: %s", user)
+	}
+	if strings.Index(user, "Agent:\nLooking into it") > strings.Index(user, "Requester:\nAny update?") {
+		t.Fatalf("expected comments oldest first: %s", user)
+	}
+	if len(usage) != 1 || usage[0][4] != 2 || usage[0][9] != (*string)(nil) {
+		t.Fatalf("expected usage to be recorded, got %v", usage)
+	}
+
+	if rr := post(a, "t1", `{"count":3}`); rr.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 on provider failure, got %d", rr.Code)
+	}
+	if len(usage) != 2 || usage[1][9] == (*string)(nil) {
+		t.Fatalf("expected the failure to be recorded, got %v", usage)
+	}
+	if rr := post(a, "t1", `{"count":4}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for too many suggestions, got %d", rr.Code)
+	}
+	if rr := post(a, "missing", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown ticket, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,487 @@
+// Package tags keeps the catalog of ticket tags and the tags on each
+// ticket. Admins manage the catalog; agents tag tickets from it, so tags
+// stay few and consistently named. Ticket lists filter by tag name.
+package tags
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/events"
+)
+
+// DefaultColor is the color of tags created without one.
+const DefaultColor = "#64748b"
+
+// maxName bounds tag names; maxPerRequest how many tags one call may add.
+const (
+	maxName       = 50
+	maxPerRequest = 20
+)
+
+var colorRe = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// Tag is an entry of the catalog.
+type Tag struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	// TicketCount counts the tickets carrying the tag.
+	TicketCount int       `json:"ticket_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Ref is a tag as shown on a ticket.
+type Ref struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+const tagCols = `g.id::text, g.name, g.color, g.description,
+        (select count(*) from ticket_tags tt where tt.tag_id = g.id), g.created_at, g.updated_at`
+
+func scanTag(row pgx.Row) (Tag, error) {
+	var t Tag
+	err := row.Scan(&t.ID, &t.Name, &t.Color, &t.Description, &t.TicketCount, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// Filter returns the condition matching tickets, aliased t, that carry the
+// tag named by the parameter $n, ignoring case.
+func Filter(n int) string {
+	return fmt.Sprintf(`exists (select 1 from ticket_tags tt join tags g on g.id = tt.tag_id
+        where tt.ticket_id = t.id and lower(g.name) = lower($%d))`, n)
+}
+
+// Column selects the tags on the ticket aliased t as a JSON array of Refs
+// by name.
+const Column = `coalesce((select json_agg(json_build_object('id', g.id, 'name', g.name, 'color', g.color) order by lower(g.name))
+        from ticket_tags tt join tags g on g.id = tt.tag_id where tt.ticket_id = t.id), '[]')`
+
+// Decode reads a Column value; empty or malformed values give no tags.
+func Decode(b []byte) []Ref {
+	var out []Ref
+	if len(b) > 0 {
+		_ = json.Unmarshal(b, &out)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// ForTickets returns the tags on each of ids by ticket, by name.
+func ForTickets(ctx context.Context, db apppkg.DB, ids []string) (map[string][]Ref, error) {
+	out := map[string][]Ref{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	rows, err := db.Query(ctx, `select tt.ticket_id::text, g.id::text, g.name, g.color
+        from ticket_tags tt join tags g on g.id = tt.tag_id
+        where tt.ticket_id = any($1::uuid[]) order by lower(g.name)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ticketID string
+		var r Ref
+		if err := rows.Scan(&ticketID, &r.ID, &r.Name, &r.Color); err != nil {
+			return nil, err
+		}
+		out[ticketID] = append(out[ticketID], r)
+	}
+	return out, rows.Err()
+}
+
+// tagError maps constraint violations on tags to responses and reports
+// whether it wrote one.
+func tagError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	if errors.As(err, &pge) && pge.Code == "23505" {
+		apppkg.AbortError(c, http.StatusConflict, "name_taken", "a tag with this name exists", nil)
+		return true
+	}
+	return false
+}
+
+// List returns the catalog by name. q narrows it to names containing q.
+func List(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := strings.TrimSpace(c.Query("q"))
+		rows, err := a.DB.Query(c.Request.Context(), `select `+tagCols+` from tags g
+            where $1 = '' or strpos(lower(g.name), lower($1)) > 0 order by lower(g.name)`, q)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list tags", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Tag{}
+		for rows.Next() {
+			t, err := scanTag(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list tags", nil)
+				return
+			}
+			out = append(out, t)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Autocomplete suggests tags for a partly typed name: names starting with
+// q first, then names containing it, the most used first within each. An
+// empty q suggests the most used tags. limit defaults to 10, at most 50.
+func Autocomplete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q := strings.TrimSpace(c.Query("q"))
+		limit := 10
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 50 {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"limit": "must be 1-50"})
+				return
+			}
+			limit = n
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select g.id::text, g.name, g.color from tags g
+            where strpos(lower(g.name), lower($1)) > 0
+            order by strpos(lower(g.name), lower($1)) <> 1, (select count(*) from ticket_tags tt where tt.tag_id = g.id) desc, lower(g.name)
+            limit $2`, q, limit)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to suggest tags", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Ref{}
+		for rows.Next() {
+			var r Ref
+			if err := rows.Scan(&r.ID, &r.Name, &r.Color); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to suggest tags", nil)
+				return
+			}
+			out = append(out, r)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// tagInput is the writable part of a tag. Updates start from the stored tag
+// so only the fields present in the body change.
+type tagInput struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// check normalizes in and returns the problems with it by field. Commas and
+// bars are kept out of names since ticket filters split on them.
+func (in *tagInput) check() map[string]string {
+	errs := map[string]string{}
+	in.Name = strings.Join(strings.Fields(in.Name), " ")
+	switch {
+	case in.Name == "":
+		errs["name"] = "required"
+	case len([]rune(in.Name)) > maxName:
+		errs["name"] = fmt.Sprintf("at most %d characters", maxName)
+	case strings.ContainsAny(in.Name, ",|"):
+		errs["name"] = "must not contain , or |"
+	}
+	in.Color = strings.ToLower(strings.TrimSpace(in.Color))
+	if !colorRe.MatchString(in.Color) {
+		errs["color"] = "must be a hex color like #1e90ff"
+	}
+	in.Description = strings.TrimSpace(in.Description)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func tagAudit(t Tag) map[string]any {
+	return map[string]any{"name": t.Name, "color": t.Color, "description": t.Description}
+}
+
+func loadTag(c *gin.Context, db apppkg.DB) (Tag, error) {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		return Tag{}, pgx.ErrNoRows
+	}
+	return scanTag(db.QueryRow(c.Request.Context(), `select `+tagCols+` from tags g where g.id = $1`, c.Param("id")))
+}
+
+// Create adds a tag to the catalog. Requires admin.
+func Create(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		in := tagInput{Color: DefaultColor}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if errs := in.check(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		var t Tag
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			t, err = scanTag(tx.QueryRow(ctx, `with g as (insert into tags (name, color, description, created_by)
+                values ($1, $2, $3, $4) returning *) select `+tagCols+` from g`, in.Name, in.Color, in.Description, authpkg.Actor(c).DBID()))
+			if err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "tag", t.ID, "tag_created", tagAudit(t))
+		})
+		if tagError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create tag", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+// Update renames or recolors a tag; only the fields present change. The
+// tag's tickets follow. Requires admin.
+func Update(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		if err != nil || !json.Valid(body) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var before, t Tag
+		var errs map[string]string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			var err error
+			if before, err = loadTag(c, tx); err != nil {
+				return err
+			}
+			in := tagInput{Name: before.Name, Color: before.Color, Description: before.Description}
+			if err := json.Unmarshal(body, &in); err != nil {
+				errs = map[string]string{"body": "invalid field types"}
+				return nil
+			}
+			if errs = in.check(); errs != nil {
+				return nil
+			}
+			t, err = scanTag(tx.QueryRow(ctx, `with g as (update tags set name = $2, color = $3, description = $4, updated_at = now()
+                where id = $1 returning *) select `+tagCols+` from g`, before.ID, in.Name, in.Color, in.Description))
+			if err != nil {
+				return err
+			}
+			return audit.Record(ctx, tx, authpkg.Actor(c), "tag", t.ID, "tag_updated", audit.Diff(tagAudit(before), tagAudit(t)))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "tag not found", nil)
+			return
+		}
+		if errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if tagError(c, err) {
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update tag", nil)
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// Delete removes a tag from the catalog and from every ticket carrying it.
+// Requires admin.
+func Delete(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			before, err := loadTag(c, tx)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `delete from tags where id = $1`, before.ID); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "tag", before.ID, "tag_deleted", map[string]any{"name": before.Name, "tickets": before.TicketCount})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "tag not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete tag", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ticketTags returns the tags on a ticket by name.
+func ticketTags(ctx context.Context, db apppkg.DB, ticketID string) ([]Ref, error) {
+	m, err := ForTickets(ctx, db, []string{ticketID})
+	if err != nil {
+		return nil, err
+	}
+	if m[ticketID] == nil {
+		return []Ref{}, nil
+	}
+	return m[ticketID], nil
+}
+
+// ListForTicket returns the tags on a ticket.
+func ListForTicket(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		out, err := ticketTags(c.Request.Context(), a.DB, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list ticket tags", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+type addReq struct {
+	Tags []string `json:"tags"`
+}
+
+// Add tags a ticket with catalog tags named in the body, ignoring case;
+// tags it already carries are left alone. It returns the ticket's tags.
+func Add(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in addReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		names := []string{}
+		seen := map[string]bool{}
+		for _, n := range in.Tags {
+			n = strings.ToLower(strings.Join(strings.Fields(n), " "))
+			if n != "" && !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+		switch {
+		case len(names) == 0:
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"tags": "required"})
+			return
+		case len(names) > maxPerRequest:
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"tags": fmt.Sprintf("at most %d per request", maxPerRequest)})
+			return
+		}
+		ctx := c.Request.Context()
+		ticketID := c.Param("id")
+		var added []Ref
+		var unknown []string
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			found := map[string]Ref{}
+			rows, err := tx.Query(ctx, `select g.id::text, g.name, g.color from tags g where lower(g.name) = any($1)`, names)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var r Ref
+				if err := rows.Scan(&r.ID, &r.Name, &r.Color); err != nil {
+					rows.Close()
+					return err
+				}
+				found[strings.ToLower(r.Name)] = r
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			for _, n := range names {
+				if _, ok := found[n]; !ok {
+					unknown = append(unknown, n)
+				}
+			}
+			if len(unknown) > 0 {
+				return nil
+			}
+			for _, n := range names {
+				r := found[n]
+				tag, err := tx.Exec(ctx, `insert into ticket_tags (ticket_id, tag_id, added_by) values ($1, $2, $3)
+                    on conflict do nothing`, ticketID, r.ID, authpkg.Actor(c).DBID())
+				if err != nil {
+					return err
+				}
+				if tag.RowsAffected() > 0 {
+					added = append(added, r)
+				}
+			}
+			return nil
+		})
+		if len(unknown) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "unknown_tag", "tags must come from the tag catalog", map[string]string{"tags": "unknown: " + strings.Join(unknown, ", ")})
+			return
+		}
+		var pge *pgconn.PgError
+		if errors.As(err, &pge) && pge.Code == "23503" {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to tag ticket", nil)
+			return
+		}
+		for _, r := range added {
+			events.Emit(ctx, a.DB, authpkg.Actor(c), ticketID, "tag_add", map[string]any{"tag_id": r.ID, "tag": r.Name})
+		}
+		out, err := ticketTags(ctx, a.DB, ticketID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list ticket tags", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Remove takes the tag :tag_id off a ticket.
+func Remove(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("tag_id")); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "tag not on ticket", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		ticketID := c.Param("id")
+		var name string
+		err := a.DB.QueryRow(ctx, `with d as (delete from ticket_tags where ticket_id = $1 and tag_id = $2 returning tag_id)
+            select g.name from d join tags g on g.id = d.tag_id`, ticketID, c.Param("tag_id")).Scan(&name)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "tag not on ticket", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to untag ticket", nil)
+			return
+		}
+		events.Emit(ctx, a.DB, authpkg.Actor(c), ticketID, "tag_remove", map[string]any{"tag_id": c.Param("tag_id"), "tag": name})
+		c.Status(http.StatusNoContent)
+	}
+}
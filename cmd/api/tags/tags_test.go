@@ -0,0 +1,116 @@
+package tags
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestTagInputCheck(t *testing.T) {
+	in := tagInput{Name: "vip,urgent", Color: "blue"}
+	errs := in.check()
+	if len(errs) != 2 || errs["name"] == "" || errs["color"] == "" {
+		t.Fatalf("errors = %v", errs)
+	}
+	in = tagInput{Name: strings.Repeat("x", maxName+1), Color: DefaultColor}
+	if errs := in.check(); errs["name"] == "" {
+		t.Fatalf("long name accepted")
+	}
+	in = tagInput{Name: "  Needs   parts ", Color: "#1E90FF", Description: " waiting on vendor "}
+	if errs := in.check(); errs != nil {
+		t.Fatalf("valid tag rejected: %v", errs)
+	}
+	if in.Name != "Needs parts" || in.Color != "#1e90ff" || in.Description != "waiting on vendor" {
+		t.Fatalf("not normalized: %+v", in)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	if got := Decode([]byte(`[]`)); got != nil {
+		t.Fatalf("empty array = %v", got)
+	}
+	got := Decode([]byte(`[{"id":"g1","name":"VIP","color":"#ff0000"}]`))
+	if len(got) != 1 || got[0].Name != "VIP" || got[0].Color != "#ff0000" {
+		t.Fatalf("decoded %v", got)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var inserted []string
+	catalog := map[string]Ref{"vip": {ID: "g1", Name: "VIP", Color: "#ff0000"}}
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			var refs []Ref
+			if strings.Contains(sql, "any($1)") {
+				for _, n := range args[0].([]string) {
+					if r, ok := catalog[n]; ok {
+						refs = append(refs, r)
+					}
+				}
+			} else {
+				for range inserted {
+					refs = append(refs, catalog["vip"])
+				}
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(refs) },
+				ScanFunc: func(dest ...any) error {
+					if len(dest) == 4 {
+						*dest[0].(*string) = "t1"
+						dest = dest[1:]
+					}
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = refs[i].ID, refs[i].Name, refs[i].Color
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into ticket_tags") {
+				inserted = append(inserted, args[1].(string))
+				return pgconn.NewCommandTag("INSERT 0 1"), nil
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/tags", authpkg.Middleware(a), Add(a))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/t1/tags", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post(`{"tags":["VIP","hardware"]}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "unknown_tag") || len(inserted) != 0 {
+		t.Fatalf("unknown tag: %d %s, inserted %v", rr.Code, rr.Body.String(), inserted)
+	}
+
+	rr = post(`{"tags":[" vip ","VIP"]}`)
+	if rr.Code != http.StatusOK || len(inserted) != 1 || inserted[0] != "g1" {
+		t.Fatalf("expected one insert, got %d %s, inserted %v", rr.Code, rr.Body.String(), inserted)
+	}
+	var out []Ref
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || len(out) != 1 || out[0].Color != "#ff0000" {
+		t.Fatalf("response %s: %v", rr.Body.String(), err)
+	}
+
+	if rr := post(`{"tags":[]}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("empty tags: %d", rr.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package teams
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/sender"
+)
+
+// PutEmailIdentity sets the From name and address, Reply-To and signature
+// of notification emails about the team's tickets. A ticket's queue
+// overrides them field by field; empty fields fall back to SMTP_FROM.
+func PutEmailIdentity(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in sender.Identity
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		if errs := in.Normalize(); errs != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid email identity", errs)
+			return
+		}
+		ctx := c.Request.Context()
+		teamID := c.Param("id")
+		tag, err := a.DB.Exec(ctx, `update teams set email_identity = $2 where id = $1`, teamID, in)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save email identity", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team not found", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "email_identity_set", map[string]any{"email_identity": in}); err != nil {
+			log.Error().Err(err).Msg("audit team email identity")
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "email_identity": in})
+	}
+}
@@ -0,0 +1,58 @@
+package teams
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// PutLanguages replaces the languages routed to the team. Unassigned tickets
+// whose detected language is listed are moved to the team by the worker's
+// enrichment step; an empty list stops routing to it.
+func PutLanguages(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Languages []string `json:"languages"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		langs := []string{}
+		for _, l := range in.Languages {
+			l = strings.ToLower(strings.TrimSpace(l))
+			if !languageCode.MatchString(l) {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid language code", map[string]string{"languages": "must be ISO 639-1 codes such as en or es"})
+				return
+			}
+			if !slices.Contains(langs, l) {
+				langs = append(langs, l)
+			}
+		}
+		ctx := c.Request.Context()
+		teamID := c.Param("id")
+		tag, err := a.DB.Exec(ctx, `update teams set languages = $2 where id = $1`, teamID, langs)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save team languages", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team not found", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "languages_set", map[string]any{"languages": langs}); err != nil {
+			log.Error().Err(err).Msg("audit team languages")
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "languages": langs})
+	}
+}
@@ -0,0 +1,52 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestPutLanguages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var saved []string
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+		if strings.Contains(sql, "update teams set languages") {
+			if args[0] != "team-1" {
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			}
+			saved = args[1].([]string)
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		}
+		return pgconn.CommandTag{}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PUT("/teams/:id/languages", PutLanguages(a))
+	put := func(id, body string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/teams/"+id+"/languages", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := put("team-1", `{"languages":["ES"," pt ","es"]}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if len(saved) != 2 || saved[0] != "es" || saved[1] != "pt" {
+		t.Fatalf("expected normalised languages, got %v", saved)
+	}
+	if code := put("team-1", `{"languages":["spanish"]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid code, got %d", code)
+	}
+	if code := put("team-2", `{"languages":[]}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown team, got %d", code)
+	}
+}
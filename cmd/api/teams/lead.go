@@ -0,0 +1,53 @@
+package teams
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// PutLead names the team's lead, who is assigned follow-up tickets for bad
+// CSAT scores on the team's tickets. The lead must be a team member; a null
+// user_id clears it.
+func PutLead(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			UserID *string `json:"user_id"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		if in.UserID != nil && *in.UserID == "" {
+			in.UserID = nil
+		}
+		if in.UserID != nil {
+			if _, err := uuid.Parse(*in.UserID); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid user id", map[string]string{"user_id": "must be a user id"})
+				return
+			}
+		}
+		ctx := c.Request.Context()
+		teamID := c.Param("id")
+		tag, err := a.DB.Exec(ctx, `update teams set lead_id = $2 where id = $1
+            and ($2::uuid is null or exists (select 1 from team_members tm where tm.team_id = $1 and tm.user_id = $2))`, teamID, in.UserID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save team lead", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team not found or user is not a member", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "lead_set", map[string]any{"lead_id": in.UserID}); err != nil {
+			log.Error().Err(err).Msg("audit team lead")
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "lead_id": in.UserID})
+	}
+}
@@ -0,0 +1,48 @@
+package teams
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// PutLeaderboard sets who may see the team's agent leaderboard: nobody
+// ("off", the default), each member only their own figures ("self"), or
+// the whole team ranked ("team").
+func PutLeaderboard(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Visibility string `json:"visibility"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		v := strings.ToLower(strings.TrimSpace(in.Visibility))
+		if v != "off" && v != "self" && v != "team" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid visibility", map[string]string{"visibility": "must be off, self or team"})
+			return
+		}
+		ctx := c.Request.Context()
+		teamID := c.Param("id")
+		tag, err := a.DB.Exec(ctx, `update teams set leaderboard = $2 where id = $1`, teamID, v)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save leaderboard visibility", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team not found", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "leaderboard_set", map[string]any{"leaderboard": v}); err != nil {
+			log.Error().Err(err).Msg("audit team leaderboard")
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "visibility": v})
+	}
+}
@@ -0,0 +1,49 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestPutLeaderboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var saved any
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+		if strings.Contains(sql, "update teams set leaderboard") {
+			if args[0] != "team-1" {
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			}
+			saved = args[1]
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		}
+		return pgconn.CommandTag{}, nil
+	}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PUT("/teams/:id/leaderboard", PutLeaderboard(a))
+	put := func(id, body string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/teams/"+id+"/leaderboard", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := put("team-1", `{"visibility":" Self "}`); code != http.StatusOK || saved != "self" {
+		t.Fatalf("expected self to be saved, got %d %v", code, saved)
+	}
+	if code := put("team-1", `{"visibility":"everyone"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown visibility, got %d", code)
+	}
+	if code := put("team-2", `{"visibility":"team"}`); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown team, got %d", code)
+	}
+}
@@ -0,0 +1,74 @@
+package teams
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// PutMember adds a user to the team or updates their capacity. max_open
+// omitted or null removes the cap.
+func PutMember(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			MaxOpen *int `json:"max_open"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+				return
+			}
+		}
+		if in.MaxOpen != nil && *in.MaxOpen < 1 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "max_open must be positive", map[string]string{"max_open": "must be at least 1"})
+			return
+		}
+		ctx := c.Request.Context()
+		teamID, userID := c.Param("id"), c.Param("userID")
+		tag, err := a.DB.Exec(ctx, `insert into team_members (team_id, user_id, max_open)
+            select tm.id, u.id, $3 from teams tm, users u where tm.id = $1 and u.id = $2
+            on conflict (team_id, user_id) do update set max_open = excluded.max_open`, teamID, userID, in.MaxOpen)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save team member", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team or user not found", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "member_set", map[string]any{"user_id": userID, "max_open": in.MaxOpen}); err != nil {
+			log.Error().Err(err).Msg("audit team member")
+		}
+		c.JSON(http.StatusOK, gin.H{"team_id": teamID, "user_id": userID, "max_open": in.MaxOpen})
+	}
+}
+
+// DeleteMember removes a user from the team. Their tickets stay assigned;
+// if they led the team it is left without a lead.
+func DeleteMember(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		teamID, userID := c.Param("id"), c.Param("userID")
+		tag, err := a.DB.Exec(ctx, `delete from team_members where team_id = $1 and user_id = $2`, teamID, userID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to remove team member", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team member not found", nil)
+			return
+		}
+		if _, err := a.DB.Exec(ctx, `update teams set lead_id = null where id = $1 and lead_id = $2`, teamID, userID); err != nil {
+			log.Error().Err(err).Msg("clear team lead")
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "team", teamID, "member_removed", map[string]any{"user_id": userID}); err != nil {
+			log.Error().Err(err).Msg("audit team member")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,125 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/ooo"
+)
+
+// Member availability states.
+const (
+	StatusAvailable  = "available"
+	StatusAtCapacity = "at_capacity"
+	StatusAway       = "away"
+)
+
+// MemberLoad is one team member's current workload.
+type MemberLoad struct {
+	UserID      string `json:"user_id"`
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	// Open counts the member's open tickets across all teams; Assigned only
+	// those belonging to this team.
+	Open     int  `json:"open"`
+	Assigned int  `json:"assigned"`
+	AtRisk   int  `json:"at_risk"`
+	MaxOpen  *int `json:"max_open,omitempty"`
+	// Status is away when the member marked themselves unavailable or is out
+	// of office, and at_capacity once Open reaches MaxOpen.
+	Status string `json:"status"`
+	// OutOfOfficeUntil is the end of the member's current out-of-office window.
+	OutOfOfficeUntil *time.Time `json:"out_of_office_until,omitempty"`
+}
+
+// Workload is the GET /teams/:id/workload payload. Members are ordered from
+// least to most loaded.
+type Workload struct {
+	TeamID     string       `json:"team_id"`
+	Team       string       `json:"team"`
+	Unassigned int          `json:"unassigned"`
+	Members    []MemberLoad `json:"members"`
+}
+
+// LeastLoaded returns the available member with the fewest open tickets, or
+// nil when everyone is away or at capacity.
+func (w Workload) LeastLoaded() *MemberLoad {
+	for i := range w.Members {
+		if w.Members[i].Status == StatusAvailable {
+			return &w.Members[i]
+		}
+	}
+	return nil
+}
+
+// LoadWorkload computes the workload of a team. pgx.ErrNoRows is returned for
+// unknown teams.
+func LoadWorkload(ctx context.Context, db apppkg.DB, teamID string) (Workload, error) {
+	w := Workload{TeamID: teamID, Members: []MemberLoad{}}
+	if err := db.QueryRow(ctx, `select tm.name, (select count(*) from tickets
+            where team_id = tm.id and assignee_id is null and status not in ('Resolved','Closed'))
+        from teams tm where tm.id = $1`, teamID).Scan(&w.Team, &w.Unassigned); err != nil {
+		return w, err
+	}
+	rows, err := db.Query(ctx, `select u.id::text, coalesce(u.display_name,''), coalesce(u.email,''), coalesce(u.avatar_key,''),
+            u.available, m.max_open, count(t.id), count(t.id) filter (where t.team_id = m.team_id),
+            count(t.id) filter (where `+ticketspkg.AtRiskFilter+`),
+            case when `+ooo.Active("u")+` then u.ooo_end end
+        from team_members m
+        join users u on u.id = m.user_id
+        left join tickets t on t.assignee_id = u.id and t.status not in ('Resolved','Closed')
+        left join ticket_sla_clocks sc on sc.ticket_id = t.id
+        left join sla_policy_versions sp on sp.id = sc.policy_version_id
+        where m.team_id = $1
+        group by u.id, m.team_id, m.max_open
+        order by count(t.id), u.display_name nulls last, u.email`, teamID)
+	if err != nil {
+		return w, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m MemberLoad
+		var avatarKey string
+		var available bool
+		if err := rows.Scan(&m.UserID, &m.DisplayName, &m.Email, &avatarKey, &available, &m.MaxOpen, &m.Open, &m.Assigned, &m.AtRisk, &m.OutOfOfficeUntil); err != nil {
+			return w, err
+		}
+		m.AvatarURL = avatars.URL(m.UserID, avatarKey, m.Email)
+		switch {
+		case !available || m.OutOfOfficeUntil != nil:
+			m.Status = StatusAway
+		case m.MaxOpen != nil && m.Open >= *m.MaxOpen:
+			m.Status = StatusAtCapacity
+		default:
+			m.Status = StatusAvailable
+		}
+		w.Members = append(w.Members, m)
+	}
+	return w, rows.Err()
+}
+
+// GetWorkload returns open, assigned and at-risk counts and availability for
+// every member of the team.
+func GetWorkload(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, err := LoadWorkload(c.Request.Context(), a.DB, c.Param("id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "team not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load workload", nil)
+			return
+		}
+		c.JSON(http.StatusOK, w)
+	}
+}
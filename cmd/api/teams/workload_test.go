@@ -0,0 +1,93 @@
+package teams
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestGetWorkload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	two := 2
+	members := []struct {
+		id        string
+		available bool
+		maxOpen   *int
+		open      int
+	}{
+		{"u1", true, &two, 2},
+		{"u2", false, nil, 0},
+		{"u3", true, nil, 4},
+	}
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != "team1" {
+					return pgx.ErrNoRows
+				}
+				*(dest[0].(*string)) = "Support"
+				*(dest[1].(*int)) = 3
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			if !strings.Contains(sql, "from team_members m") || !strings.Contains(sql, "sc.resolution_elapsed_ms") {
+				t.Fatalf("unexpected sql %s", sql)
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(members) },
+				ScanFunc: func(dest ...any) error {
+					m := members[i]
+					*(dest[0].(*string)) = m.id
+					*(dest[2].(*string)) = m.id + "@example.com"
+					*(dest[4].(*bool)) = m.available
+					*(dest[5].(**int)) = m.maxOpen
+					*(dest[6].(*int)) = m.open
+					*(dest[7].(*int)) = m.open
+					*(dest[8].(*int)) = 1
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/teams/:id/workload", authpkg.Middleware(a), GetWorkload(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/teams/team1/workload", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var w Workload
+	if err := json.Unmarshal(rr.Body.Bytes(), &w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Team != "Support" || w.Unassigned != 3 || len(w.Members) != 3 {
+		t.Fatalf("unexpected workload %+v", w)
+	}
+	for i, want := range []string{StatusAtCapacity, StatusAway, StatusAvailable} {
+		if w.Members[i].Status != want {
+			t.Fatalf("member %d: expected %s, got %s", i, want, w.Members[i].Status)
+		}
+	}
+	if m := w.LeastLoaded(); m == nil || m.UserID != "u3" {
+		t.Fatalf("expected u3 as least loaded available member, got %+v", m)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/teams/missing/workload", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown team, got %d", rr.Code)
+	}
+}
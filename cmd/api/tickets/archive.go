@@ -0,0 +1,173 @@
+package tickets
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// archiveTTL is how long archive statuses are kept, matching the worker.
+const archiveTTL = 7 * 24 * time.Hour
+
+// archiveStatus is the worker's record of an archive job.
+type archiveStatus struct {
+	Requester string `json:"requester"`
+	Status    string `json:"status"`
+	ObjectKey string `json:"object_key,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func archiveKey(ticketID, jobID string) string {
+	return "ticket_archive:" + ticketID + ":" + jobID
+}
+
+func callerID(c *gin.Context) string {
+	v, _ := c.Get("user")
+	u, _ := v.(authpkg.AuthUser)
+	return u.ID
+}
+
+// RequestArchive queues a zip of the ticket, its rendered timeline and the
+// attachments the caller may see, and answers with the job's status URL.
+// Internal comments and attachments are only included for staff.
+func RequestArchive(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.Q == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "unavailable", "queue not configured", nil)
+			return
+		}
+		ticketID := c.Param("id")
+		if _, err := uuid.Parse(ticketID); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if a.DB != nil {
+			var exists bool
+			if err := a.DB.QueryRow(c.Request.Context(), `select exists (select 1 from tickets where id=$1)`, ticketID).Scan(&exists); err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load ticket", nil)
+				return
+			}
+			if !exists {
+				app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+				return
+			}
+		}
+		ctx := c.Request.Context()
+		jobID := uuid.New().String()
+		requester := callerID(c)
+		st, _ := json.Marshal(archiveStatus{Requester: requester, Status: "queued"})
+		if err := a.Q.Set(ctx, archiveKey(ticketID, jobID), st, archiveTTL).Err(); err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "queue_error", "failed to queue archive", nil)
+			return
+		}
+		jb, err := jobs.Encode(jobID, jobs.TypeTicketArchive, jobs.TicketArchive{TicketID: ticketID, Requester: requester, Internal: authpkg.IsStaff(c)})
+		if err == nil {
+			err = a.Q.RPush(ctx, jobs.QueueFor(jobs.TypeTicketArchive), jb).Err()
+		}
+		if err != nil {
+			log.Error().Err(err).Str("ticket_id", ticketID).Msg("enqueue ticket archive")
+			app.AbortError(c, http.StatusInternalServerError, "queue_error", "failed to queue archive", nil)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"job_id": jobID, "status_url": strings.TrimSuffix(c.Request.URL.Path, "/") + "/" + jobID})
+	}
+}
+
+// loadArchive returns the archive job named by :job_id for the caller who
+// requested it, answering 404 otherwise.
+func loadArchive(c *gin.Context, a *app.App) (archiveStatus, bool) {
+	var st archiveStatus
+	if a.Q == nil {
+		app.AbortError(c, http.StatusServiceUnavailable, "unavailable", "queue not configured", nil)
+		return st, false
+	}
+	b, err := a.Q.Get(c.Request.Context(), archiveKey(c.Param("id"), c.Param("job_id"))).Bytes()
+	if errors.Is(err, redis.Nil) {
+		app.AbortError(c, http.StatusNotFound, "not_found", "archive not found", nil)
+		return st, false
+	}
+	if err != nil {
+		app.AbortError(c, http.StatusInternalServerError, "queue_error", "failed to load archive status", nil)
+		return st, false
+	}
+	if err := json.Unmarshal(b, &st); err != nil || (st.Requester != "" && st.Requester != callerID(c)) {
+		app.AbortError(c, http.StatusNotFound, "not_found", "archive not found", nil)
+		return st, false
+	}
+	return st, true
+}
+
+// ArchiveStatus reports an archive job. Once done it returns a download URL:
+// a short-lived presigned link on MinIO/S3, otherwise the API's download
+// route.
+func ArchiveStatus(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st, ok := loadArchive(c, a)
+		if !ok {
+			return
+		}
+		if st.Status != "done" {
+			out := gin.H{"status": st.Status}
+			if st.Error != "" {
+				out["error"] = st.Error
+			}
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		store, loc := a.ResolveClass(c.Request.Context(), s3.ClassExports)
+		if mw, ok := store.(*app.MinioWrapper); ok {
+			vals := url.Values{}
+			vals.Set("response-content-disposition", `attachment; filename="ticket-archive.zip"`)
+			u, err := mw.PresignedGetObject(c.Request.Context(), loc.Bucket, st.ObjectKey, 15*time.Minute, vals)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "store_error", "failed to sign url", nil)
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"status": st.Status, "url": u.String()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": st.Status, "url": strings.TrimSuffix(c.Request.URL.Path, "/") + "/download"})
+	}
+}
+
+// ArchiveDownload streams a finished archive from stores that cannot
+// presign downloads.
+func ArchiveDownload(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st, ok := loadArchive(c, a)
+		if !ok {
+			return
+		}
+		if st.Status != "done" {
+			app.AbortError(c, http.StatusConflict, "not_ready", "archive is not ready", nil)
+			return
+		}
+		store, loc := a.ResolveClass(c.Request.Context(), s3.ClassExports)
+		rc, err := app.OpenObject(c.Request.Context(), store, loc.Bucket, st.ObjectKey)
+		if err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "archive not found", nil)
+			return
+		}
+		defer rc.Close()
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", `attachment; filename="ticket-archive.zip"`)
+		c.Status(http.StatusOK)
+		if _, err := io.Copy(c.Writer, rc); err != nil {
+			log.Warn().Err(err).Str("object_key", st.ObjectKey).Msg("stream ticket archive")
+		}
+	}
+}
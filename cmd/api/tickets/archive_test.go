@@ -0,0 +1,97 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestTicketArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", MinIOBucket: "bkt"}, nil, nil, store, rdb)
+	const ticket = "11111111-1111-1111-1111-111111111111"
+	users := map[string]authpkg.AuthUser{
+		"agent": {ID: "u-agent", Roles: []string{"agent"}},
+		"req":   {ID: "u-req", Email: "req@example.com", Roles: []string{"requester"}},
+	}
+	as := func(c *gin.Context) { c.Set("user", users[c.GetHeader("X-User")]) }
+	a.R.GET("/tickets/:id/archive", as, RequestArchive(a))
+	a.R.GET("/tickets/:id/archive/:job_id", as, ArchiveStatus(a))
+	a.R.GET("/tickets/:id/archive/:job_id/download", as, ArchiveDownload(a))
+	do := func(user, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-User", user)
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for user, internal := range map[string]bool{"agent": true, "req": false} {
+		rr := do(user, "/tickets/"+ticket+"/archive")
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("%s: expected 202, got %d: %s", user, rr.Code, rr.Body.String())
+		}
+		var out struct {
+			JobID     string `json:"job_id"`
+			StatusURL string `json:"status_url"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.StatusURL != "/tickets/"+ticket+"/archive/"+out.JobID {
+			t.Fatalf("unexpected status url %q", out.StatusURL)
+		}
+		b, err := rdb.LPop(ctx, jobs.QueueFor(jobs.TypeTicketArchive)).Bytes()
+		if err != nil {
+			t.Fatalf("expected a queued job: %v", err)
+		}
+		job, _ := jobs.Decode(b)
+		var aj jobs.TicketArchive
+		_ = json.Unmarshal(job.Data, &aj)
+		if job.ID != out.JobID || aj.TicketID != ticket || aj.Requester != users[user].ID || aj.Internal != internal {
+			t.Fatalf("%s: unexpected job %+v %+v", user, job, aj)
+		}
+
+		if rr := do(user, out.StatusURL); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"queued"`) {
+			t.Fatalf("%s: expected queued status, got %d %s", user, rr.Code, rr.Body.String())
+		}
+		other := map[string]string{"agent": "req", "req": "agent"}[user]
+		if rr := do(other, out.StatusURL); rr.Code != http.StatusNotFound {
+			t.Fatalf("another user's archive must be hidden, got %d", rr.Code)
+		}
+		if rr := do(user, out.StatusURL+"/download"); rr.Code != http.StatusConflict {
+			t.Fatalf("expected 409 before the archive is ready, got %d", rr.Code)
+		}
+
+		// Simulate the worker finishing.
+		if _, err := store.PutObject(ctx, "bkt", out.JobID+".zip", strings.NewReader("PK"), 2, minio.PutObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		st, _ := json.Marshal(archiveStatus{Requester: users[user].ID, Status: "done", ObjectKey: out.JobID + ".zip"})
+		mr.Set(archiveKey(ticket, out.JobID), string(st))
+		rr = do(user, out.StatusURL)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"url":"`+out.StatusURL+`/download"`) {
+			t.Fatalf("expected download url, got %d %s", rr.Code, rr.Body.String())
+		}
+		rr = do(user, out.StatusURL+"/download")
+		if rr.Code != http.StatusOK || rr.Body.String() != "PK" || rr.Header().Get("Content-Type") != "application/zip" {
+			t.Fatalf("unexpected download %d %q", rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	assetspkg "github.com/mark3748/helpdesk-go/cmd/api/assets"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// assetImpactActor attributes priority raises for linked assets.
+var assetImpactActor = actor.System("asset_impact")
+
+// assetImpact returns the impact analysis shown on a ticket linked to
+// assetID, or nil when it cannot be worked out; the ticket is still shown.
+func assetImpact(ctx context.Context, a *app.App, assetID string) *assetspkg.Impact {
+	id, err := uuid.Parse(assetID)
+	if err != nil {
+		return nil
+	}
+	impact, err := assetspkg.NewService(a.DB).GetImpact(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("asset_id", assetID).Msg("ticket asset impact")
+		return nil
+	}
+	return impact
+}
+
+// spofPriority is the priority a ticket at priority is raised to when it is
+// linked to a single point of failure and its queue sets spof_priority, or
+// zero when it stays as it is. Priority 1 is the highest.
+func spofPriority(impact *assetspkg.Impact, priority int16, queuePriority *int16) int16 {
+	if impact == nil || !impact.SinglePointOfFailure || queuePriority == nil || priority <= *queuePriority {
+		return 0
+	}
+	return *queuePriority
+}
+
+// LinkAsset links a ticket to the asset it is about, or unlinks it when
+// asset_id is null, and returns the asset's impact analysis. A ticket
+// linked to a single point of failure is raised to its queue's
+// spof_priority when that is higher than its own; the raise is audited
+// as priority_raised and recalibrates the SLA like a manual change.
+func LinkAsset(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		raw, ok := in["asset_id"]
+		var assetID *string
+		if !ok || json.Unmarshal(raw, &assetID) != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"asset_id": "must be an asset id or null"})
+			return
+		}
+		if assetID != nil {
+			if _, err := uuid.Parse(*assetID); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"asset_id": "must be an asset id or null"})
+				return
+			}
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "asset_id": assetID})
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var impact *assetspkg.Impact
+		if assetID != nil {
+			var err error
+			impact, err = assetspkg.NewService(a.DB).GetImpact(ctx, uuid.MustParse(*assetID))
+			if err != nil && err.Error() == "asset not found" {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"asset_id": "asset not found"})
+				return
+			}
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+		}
+		id := c.Param("id")
+		var priority, raisedTo int16
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var prevAsset *string
+			var queuePriority *int16
+			if err := tx.QueryRow(ctx, `select t.asset_id::text, t.priority, q.spof_priority from tickets t
+                left join queues q on q.id=t.queue_id where t.id=$1 for update of t`, id).Scan(&prevAsset, &priority, &queuePriority); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `update tickets set asset_id=$2, updated_at=now() where id=$1`, id, assetID); err != nil {
+				return err
+			}
+			action := "asset_linked"
+			if assetID == nil {
+				action = "asset_unlinked"
+			}
+			if err := audit.Record(ctx, tx, authpkg.Actor(c), "ticket", id, action,
+				audit.Diff(map[string]any{"asset_id": derefString(prevAsset)}, map[string]any{"asset_id": derefString(assetID)})); err != nil {
+				return err
+			}
+			eventspkg.Emit(ctx, tx, authpkg.Actor(c), id, "ticket_updated", map[string]any{"id": id, "asset_id": assetID})
+			raisedTo = spofPriority(impact, priority, queuePriority)
+			if raisedTo == 0 {
+				return nil
+			}
+			if _, err := tx.Exec(ctx, `update tickets set priority=$2 where id=$1`, id, raisedTo); err != nil {
+				return err
+			}
+			if err := audit.RecordDiff(ctx, tx, assetImpactActor, "ticket", id, "priority_raised", map[string]any{
+				"changes":  audit.Diff(map[string]any{"priority": priority}, map[string]any{"priority": raisedTo}),
+				"asset_id": impact.AssetID, "reason": "linked asset is a single point of failure",
+			}); err != nil {
+				return err
+			}
+			r, ok, err := sla.Recalibrate(ctx, tx, id, int(priority), int(raisedTo), cachedPriorityRules(ctx, a), time.Now())
+			if err != nil {
+				return err
+			}
+			if ok {
+				return audit.RecordDiff(ctx, tx, assetImpactActor, "ticket", id, "sla_recalibrated", r)
+			}
+			return nil
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		out := gin.H{"id": id, "asset_id": assetID, "asset_impact": impact, "priority": priority, "priority_raised": raisedTo != 0}
+		if raisedTo != 0 {
+			out["priority"] = raisedTo
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
@@ -0,0 +1,31 @@
+package tickets
+
+import (
+	"testing"
+
+	assetspkg "github.com/mark3748/helpdesk-go/cmd/api/assets"
+)
+
+func TestSPOFPriority(t *testing.T) {
+	two := int16(2)
+	spof := &assetspkg.Impact{SinglePointOfFailure: true}
+	cases := []struct {
+		name     string
+		impact   *assetspkg.Impact
+		priority int16
+		queue    *int16
+		want     int16
+	}{
+		{"raised", spof, 4, &two, 2},
+		{"already higher", spof, 1, &two, 0},
+		{"already equal", spof, 2, &two, 0},
+		{"queue off", spof, 4, nil, 0},
+		{"not a spof", &assetspkg.Impact{}, 4, &two, 0},
+		{"unlinked", nil, 4, &two, 0},
+	}
+	for _, tc := range cases {
+		if got := spofPriority(tc.impact, tc.priority, tc.queue); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package tickets
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+	"github.com/mark3748/helpdesk-go/internal/ooo"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 )
 
-// Assign changes the assignee of a ticket and emits a ticket_updated event.
+// Assign changes the assignee of a ticket, emits a ticket_updated event and
+// publishes a ticket.assigned webhook event.
 // Requires agent or manager role.
 func Assign(a *apppkg.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -46,18 +54,51 @@ func Assign(a *apppkg.App) gin.HandlerFunc {
 			c.JSON(http.StatusOK, Ticket{ID: c.Param("id"), AssigneeID: &in.AssigneeID})
 			return
 		}
-		const q = `update tickets set assignee_id=$1, updated_at=now() where id=$2 returning id::text, number, title, status, assignee_id::text, priority`
+		// Assignments to someone out of office land on their delegate.
+		q := `with t as (
+			update tickets set assignee_id=` + ooo.AssigneeExpr("$1") + `, updated_at=now() where id=$2 returning id, number, title, status, assignee_id, priority)
+			select t.id::text, t.number, t.title, t.status, t.assignee_id::text, t.priority, coalesce(u.avatar_key,''), coalesce(u.email,'')
+			from t left join users u on u.id=t.assignee_id`
 		var t Ticket
 		var assignee *string
 		var number any
+		var avatarKey, email string
 		row := a.DB.QueryRow(c.Request.Context(), q, in.AssigneeID, c.Param("id"))
-		if err := row.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority); err != nil {
+		if err := row.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &avatarKey, &email); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
 		t.Number = number
 		t.AssigneeID = assignee
-		eventspkg.Emit(c.Request.Context(), a.DB, t.ID, "ticket_updated", map[string]any{"id": t.ID})
+		if assignee != nil {
+			t.AssigneeAvatarURL = avatars.URL(*assignee, avatarKey, email)
+		}
+		if assignee == nil || *assignee != in.AssigneeID {
+			t.AssignmentRedirectedFrom = in.AssigneeID
+		}
+		eventspkg.Emit(c.Request.Context(), a.DB, authpkg.Actor(c), t.ID, "ticket_updated", map[string]any{"id": t.ID})
+		if err := webhook.Publish(c.Request.Context(), a.DB, "ticket.assigned", t.ID, nil); err != nil {
+			log.Error().Err(err).Str("ticket", t.ID).Msg("publish ticket assigned")
+		}
+		if err := notifyAssigned(c.Request.Context(), a.DB, user.ID, t, nil); err != nil {
+			log.Error().Err(err).Str("ticket", t.ID).Msg("notify assignee")
+		}
 		c.JSON(http.StatusOK, t)
 	}
 }
+
+// notifyAssigned queues a ticket_assigned notification on the new
+// assignee's channels, unless they assigned the ticket to themselves or it
+// was already theirs.
+func notifyAssigned(ctx context.Context, db notify.DB, actorID string, t Ticket, prev *string) error {
+	if t.AssigneeID == nil || *t.AssigneeID == actorID || (prev != nil && *prev == *t.AssigneeID) {
+		return nil
+	}
+	_, err := notify.Dispatch(ctx, db, []string{*t.AssigneeID}, notify.Message{
+		Event:    notify.EventTicketAssigned,
+		Title:    fmt.Sprintf("Assigned to you: %v", t.Number),
+		Body:     t.Title,
+		TicketID: t.ID,
+	})
+	return err
+}
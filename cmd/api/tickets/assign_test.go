@@ -14,6 +14,7 @@ import (
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 type assignRow struct{ Ticket }
@@ -31,18 +32,39 @@ func (r *assignRow) Scan(dest ...any) error {
 type assignDB struct {
 	sql  string
 	args []any
+	// assignee is the stored assignee; defaults to a1.
+	assignee string
+	// channels are the assignee's notification channels; outbox collects
+	// the jobs queued for them.
+	channels    []string
+	channelArgs []any
+	outbox      []string
 }
 
 func (db *assignDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
-	return nil, nil
+	db.channelArgs = args
+	i := 0
+	return &testutil.MockRows{
+		NextFunc: func() bool { i++; return i <= len(db.channels) },
+		ScanFunc: func(dest ...any) error {
+			*dest[0].(*string) = db.channels[i-1]
+			return nil
+		},
+	}, nil
 }
 func (db *assignDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	if strings.Contains(sql, "insert into outbox") {
+		db.outbox = append(db.outbox, args[2].(string))
+	}
 	return pgconn.CommandTag{}, nil
 }
 func (db *assignDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
 	db.sql = sql
 	db.args = args
-	assignee := "a1"
+	assignee := db.assignee
+	if assignee == "" {
+		assignee = "a1"
+	}
 	t := Ticket{ID: "1", Title: "t", Status: "Open", Priority: 1, AssigneeID: &assignee}
 	return &assignRow{t}
 }
@@ -68,7 +90,10 @@ func TestAssign(t *testing.T) {
 	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || out.AssigneeID == nil || *out.AssigneeID != "a1" {
 		t.Fatalf("unexpected ticket: %v %v", out, err)
 	}
-	if !strings.Contains(db.sql, "update tickets set assignee_id") {
+	if out.AssignmentRedirectedFrom != "" {
+		t.Fatalf("unexpected redirect from %q", out.AssignmentRedirectedFrom)
+	}
+	if !strings.Contains(db.sql, "update tickets set assignee_id") || !strings.Contains(db.sql, "ooo_delegate_id") {
 		t.Fatalf("unexpected sql: %s", db.sql)
 	}
 	if len(db.args) != 2 || db.args[0] != "a1" || db.args[1] != "1" {
@@ -76,6 +101,24 @@ func TestAssign(t *testing.T) {
 	}
 }
 
+func TestAssignOutOfOfficeRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &assignDB{assignee: "d1"}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/assign", authpkg.Middleware(a), Assign(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tickets/1/assign", strings.NewReader(`{"assignee_id":"a1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	var out Ticket
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+	if out.AssigneeID == nil || *out.AssigneeID != "d1" || out.AssignmentRedirectedFrom != "a1" {
+		t.Fatalf("expected redirect from a1 to d1, got %+v", out)
+	}
+}
+
 func TestAssignAuth(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := &assignDB{}
@@ -90,3 +133,37 @@ func TestAssignAuth(t *testing.T) {
 		t.Fatalf("expected 403, got %d", rr.Code)
 	}
 }
+
+func TestAssignNotifiesAssignee(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &assignDB{channels: []string{"c1", "c2"}}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/assign", authpkg.Middleware(a), Assign(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tickets/1/assign", strings.NewReader(`{"assignee_id":"a1"}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if len(db.channelArgs) != 2 || db.channelArgs[1] != "ticket_assigned" {
+		t.Fatalf("unexpected channel lookup args: %v", db.channelArgs)
+	}
+	if users, _ := db.channelArgs[0].([]string); len(users) != 1 || users[0] != "a1" {
+		t.Fatalf("expected a1 to be notified, got %v", db.channelArgs[0])
+	}
+	var notified, published []string
+	for _, row := range db.outbox {
+		if strings.Contains(row, `"type":"webhook_dispatch"`) {
+			published = append(published, row)
+		} else {
+			notified = append(notified, row)
+		}
+	}
+	if len(notified) != 2 || !strings.Contains(notified[0], `"type":"channel_notify"`) || !strings.Contains(notified[1], `"channel_id":"c2"`) {
+		t.Fatalf("unexpected outbox rows: %v", db.outbox)
+	}
+	if len(published) != 2 || !strings.Contains(published[1], `"event":"ticket.assigned"`) {
+		t.Fatalf("expected ticket.updated and ticket.assigned webhook events, got %v", published)
+	}
+}
@@ -0,0 +1,248 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	commentspkg "github.com/mark3748/helpdesk-go/cmd/api/comments"
+	"github.com/mark3748/helpdesk-go/cmd/api/csat"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// maxBroadcastTickets bounds one broadcast; larger outages take several.
+const maxBroadcastTickets = 1000
+
+// BroadcastError is a ticket a broadcast could not update.
+type BroadcastError struct {
+	TicketID string `json:"ticket_id"`
+	Error    string `json:"error"`
+}
+
+// Broadcast is a bulk comment and its progress.
+type Broadcast struct {
+	ID           string           `json:"id"`
+	RequestedBy  *string          `json:"requested_by,omitempty"`
+	BodyMD       string           `json:"body_md"`
+	StatusChange *string          `json:"status_change,omitempty"`
+	Status       string           `json:"status"`
+	Total        int              `json:"total"`
+	Processed    int              `json:"processed"`
+	Succeeded    int              `json:"succeeded"`
+	Failed       int              `json:"failed"`
+	Errors       []BroadcastError `json:"errors"`
+	CreatedAt    time.Time        `json:"created_at"`
+	StartedAt    *time.Time       `json:"started_at,omitempty"`
+	FinishedAt   *time.Time       `json:"finished_at,omitempty"`
+}
+
+// CreateBroadcast queues the same public comment, and optionally a status
+// change, for a set of tickets. The worker applies it ticket by ticket;
+// progress is read from the returned status URL.
+func CreateBroadcast(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			TicketIDs []string `json:"ticket_ids"`
+			BodyMD    string   `json:"body_md"`
+			Status    *string  `json:"status"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		in.BodyMD = strings.TrimSpace(in.BodyMD)
+		if in.BodyMD == "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "body_md is required", map[string]string{"body_md": "required"})
+			return
+		}
+		ids := make([]string, 0, len(in.TicketIDs))
+		for _, id := range in.TicketIDs {
+			if _, err := uuid.Parse(id); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid ticket id", map[string]string{"ticket_ids": id})
+				return
+			}
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 || len(ids) > maxBroadcastTickets {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "ticket_ids must list 1 to 1000 tickets", map[string]string{"ticket_ids": "1 to 1000 tickets"})
+			return
+		}
+		var status *string
+		if in.Status != nil && strings.TrimSpace(*in.Status) != "" {
+			s, ok := normalizeStatus(*in.Status)
+			if !ok {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid status", map[string]string{"status": *in.Status})
+				return
+			}
+			status = &s
+		}
+		if a.Q == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "unavailable", "queue not configured", nil)
+			return
+		}
+		if a.DB == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database not configured", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var id string
+		if err := a.DB.QueryRow(ctx, `insert into ticket_broadcasts (requested_by, body_md, status_change, ticket_ids, total)
+            values ($1, $2, $3, $4::uuid[], $5) returning id::text`, act.DBID(), in.BodyMD, status, ids, len(ids)).Scan(&id); err != nil {
+			log.Error().Err(err).Msg("create ticket broadcast")
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create broadcast", nil)
+			return
+		}
+		j := jobs.TicketBroadcast{BroadcastID: id, PublicURL: a.Cfg.PublicURL, CSATThrottleDays: a.Cfg.CSATThrottleDays}
+		if receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourceEmail) {
+			j.ReceiptBase = a.Cfg.PublicURL
+		}
+		if err := jobs.Enqueue(ctx, a.Q, id, jobs.TypeTicketBroadcast, j); err != nil {
+			log.Error().Err(err).Str("broadcast_id", id).Msg("enqueue ticket broadcast")
+			_, _ = a.DB.Exec(ctx, `update ticket_broadcasts set status='failed', finished_at=now() where id=$1`, id)
+			app.AbortError(c, http.StatusInternalServerError, "queue_error", "failed to queue broadcast", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, act, "ticket_broadcast", id, "broadcast_requested", map[string]any{"tickets": len(ids), "status": status}); err != nil {
+			log.Error().Err(err).Msg("audit ticket broadcast")
+		}
+		c.JSON(http.StatusAccepted, gin.H{"id": id, "total": len(ids), "status_url": strings.TrimSuffix(c.Request.URL.Path, "/") + "/" + id})
+	}
+}
+
+// GetBroadcast reports a broadcast's progress to the agent who sent it, or
+// to a manager or admin.
+func GetBroadcast(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("id")); err != nil || a.DB == nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "broadcast not found", nil)
+			return
+		}
+		b, err := LoadBroadcast(c.Request.Context(), a.DB, c.Param("id"))
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "broadcast not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load broadcast", nil)
+			return
+		}
+		v, _ := c.Get("user")
+		u, _ := v.(authpkg.AuthUser)
+		if !slices.Contains(u.Roles, "manager") && !slices.Contains(u.Roles, "admin") && (b.RequestedBy == nil || *b.RequestedBy != u.ID) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "broadcast not found", nil)
+			return
+		}
+		c.JSON(http.StatusOK, b)
+	}
+}
+
+// LoadBroadcast reads a broadcast row.
+func LoadBroadcast(ctx context.Context, db app.DB, id string) (Broadcast, error) {
+	b := Broadcast{Errors: []BroadcastError{}}
+	err := db.QueryRow(ctx, `select id::text, requested_by::text, body_md, status_change, status, total, processed, succeeded, failed,
+            errors, created_at, started_at, finished_at
+        from ticket_broadcasts where id=$1`, id).Scan(&b.ID, &b.RequestedBy, &b.BodyMD, &b.StatusChange, &b.Status, &b.Total,
+		&b.Processed, &b.Succeeded, &b.Failed, &b.Errors, &b.CreatedAt, &b.StartedAt, &b.FinishedAt)
+	return b, err
+}
+
+// ErrBroadcastTicketNotFound is returned by ApplyBroadcast for a ticket that
+// no longer exists.
+var ErrBroadcastTicketNotFound = errors.New("ticket not found")
+
+// ApplyBroadcast posts b's comment to one ticket as act and applies its
+// status change, in one transaction, notifying the requester and CCs as a
+// comment and a status change from the ticket page would. Resolving a
+// ticket may also send its CSAT survey; resolving or closing a parent with
+// open children fails with ErrOpenChildren. It returns the comment's ID.
+func ApplyBroadcast(ctx context.Context, db app.DB, act actor.Actor, j jobs.TicketBroadcast, b Broadcast, ticketID string) (string, error) {
+	var commentID string
+	err := app.InTx(ctx, db, func(tx app.DB) error {
+		var number any
+		var prev string
+		err := tx.QueryRow(ctx, `select number, status from tickets where id=$1 for update`, ticketID).Scan(&number, &prev)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrBroadcastTicketNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, `insert into ticket_comments (ticket_id, author_id, body_md, is_internal) values ($1, $2, $3, false) returning id::text`,
+			ticketID, act.DBID(), b.BodyMD).Scan(&commentID); err != nil {
+			return err
+		}
+		// Only agents and managers create broadcasts.
+		if _, ok := act.DBID().(string); ok {
+			if _, err := sla.RecordFirstResponse(ctx, tx, ticketID); err != nil {
+				return err
+			}
+		}
+		var csatURL string
+		status := ""
+		if b.StatusChange != nil && *b.StatusChange != prev {
+			status = *b.StatusChange
+			if closesParent(status) {
+				if n, err := openChildren(ctx, tx, ticketID); err != nil {
+					return err
+				} else if n > 0 {
+					return ErrOpenChildren
+				}
+			}
+			if _, err := tx.Exec(ctx, `update tickets set status=$2, updated_at=now() where id=$1`, ticketID, status); err != nil {
+				return err
+			}
+			pause := slices.Contains(PausedStatuses, status)
+			var reason any
+			if pause {
+				reason = status
+			}
+			if _, err := tx.Exec(ctx, `update ticket_sla_clocks set paused=$1, reason=$2, last_started_at=case when paused and not $1 then now() else last_started_at end where ticket_id=$3`, pause, reason, ticketID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `insert into ticket_status_history (ticket_id, from_status, to_status, actor_id) values ($1, nullif($2, ''), $3, $4)`, ticketID, prev, status, act.DBID()); err != nil {
+				return err
+			}
+			if err := audit.Record(ctx, tx, act, "ticket", ticketID, "ticket_updated", audit.Diff(map[string]any{"status": prev}, map[string]any{"status": status})); err != nil {
+				return err
+			}
+			if status == "Resolved" {
+				if csatURL, err = csat.Issue(ctx, tx, ticketID, j.CSATThrottleDays, j.PublicURL); err != nil {
+					return err
+				}
+			}
+		}
+		if err := audit.RecordDiff(ctx, tx, act, "ticket", ticketID, "broadcast_applied", map[string]any{"broadcast_id": b.ID, "comment_id": commentID, "status": status}); err != nil {
+			return err
+		}
+		eventspkg.Emit(ctx, tx, act, ticketID, "ticket_updated", map[string]any{"id": ticketID})
+		if err := commentspkg.NotifyCCs(ctx, tx, ticketID, commentID, b.BodyMD); err != nil {
+			return err
+		}
+		if err := notifyRequester(ctx, tx, ticketID, "ticket_comment", map[string]any{"Number": number, "Body": b.BodyMD}, j.ReceiptBase); err != nil {
+			return err
+		}
+		if csatURL != "" {
+			return notifyRequesterResolved(ctx, tx, ticketID, number, csatURL, j.ReceiptBase)
+		}
+		return nil
+	})
+	return commentID, err
+}
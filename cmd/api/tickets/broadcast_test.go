@@ -0,0 +1,128 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestTicketBroadcast(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	const (
+		broadcast = "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+		ticket1   = "11111111-1111-1111-1111-111111111111"
+		ticket2   = "22222222-2222-2222-2222-222222222222"
+	)
+	users := map[string]authpkg.AuthUser{
+		"agent":   {ID: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa", Roles: []string{"agent"}},
+		"other":   {ID: "cccccccc-cccc-cccc-cccc-cccccccccccc", Roles: []string{"agent"}},
+		"manager": {ID: "dddddddd-dddd-dddd-dddd-dddddddddddd", Roles: []string{"manager"}},
+	}
+	var inserted []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "insert into ticket_broadcasts") {
+					inserted = args
+					*dest[0].(*string) = broadcast
+					return nil
+				}
+				if args[0] != broadcast {
+					return pgx.ErrNoRows
+				}
+				by := users["agent"].ID
+				*dest[0].(*string) = broadcast
+				*dest[1].(**string) = &by
+				*dest[4].(*string) = "running"
+				*dest[5].(*int), *dest[6].(*int) = 2, 1
+				*dest[10].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.NewCommandTag("INSERT 0 1"), nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", PublicURL: "https://help.example.com"}, db, nil, nil, rdb)
+	as := func(c *gin.Context) { c.Set("user", users[c.GetHeader("X-User")]) }
+	a.R.POST("/tickets/broadcasts", as, CreateBroadcast(a))
+	a.R.GET("/tickets/broadcasts/:id", as, GetBroadcast(a))
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for name, body := range map[string]string{
+		"no body":        `{"ticket_ids":["` + ticket1 + `"],"body_md":"  "}`,
+		"no tickets":     `{"ticket_ids":[],"body_md":"Outage"}`,
+		"bad ticket id":  `{"ticket_ids":["nope"],"body_md":"Outage"}`,
+		"unknown status": `{"ticket_ids":["` + ticket1 + `"],"body_md":"Outage","status":"Escalated"}`,
+	} {
+		if rr := do("agent", http.MethodPost, "/tickets/broadcasts", body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+	if _, err := rdb.LPop(ctx, jobs.QueueFor(jobs.TypeTicketBroadcast)).Result(); err != redis.Nil {
+		t.Fatal("rejected broadcasts must not be queued")
+	}
+
+	rr := do("agent", http.MethodPost, "/tickets/broadcasts",
+		`{"ticket_ids":["`+ticket1+`","`+ticket2+`","`+ticket1+`"],"body_md":"Email is back up.","status":"resolved"}`)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		ID        string `json:"id"`
+		Total     int    `json:"total"`
+		StatusURL string `json:"status_url"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != broadcast || out.Total != 2 || out.StatusURL != "/tickets/broadcasts/"+broadcast {
+		t.Fatalf("unexpected response %+v", out)
+	}
+	if st := inserted[2].(*string); *st != "Resolved" {
+		t.Fatalf("status not normalized: %q", *st)
+	}
+	b, err := rdb.LPop(ctx, jobs.QueueFor(jobs.TypeTicketBroadcast)).Bytes()
+	if err != nil {
+		t.Fatalf("expected a queued job: %v", err)
+	}
+	job, _ := jobs.Decode(b)
+	var bj jobs.TicketBroadcast
+	_ = json.Unmarshal(job.Data, &bj)
+	if job.Type != jobs.TypeTicketBroadcast || bj.BroadcastID != broadcast || bj.PublicURL != "https://help.example.com" {
+		t.Fatalf("unexpected job %+v %+v", job, bj)
+	}
+
+	for user, code := range map[string]int{"agent": http.StatusOK, "manager": http.StatusOK, "other": http.StatusNotFound} {
+		if rr := do(user, http.MethodGet, out.StatusURL, ""); rr.Code != code {
+			t.Fatalf("%s: expected %d, got %d", user, code, rr.Code)
+		}
+	}
+	if rr := do("agent", http.MethodGet, out.StatusURL, ""); !strings.Contains(rr.Body.String(), `"processed":1`) {
+		t.Fatalf("expected progress in %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,82 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// dateFields are the agent-set ticket dates, in the order they are applied.
+var dateFields = []string{"scheduled_at", "due_at"}
+
+// parseTicketDate parses an RFC 3339 date from a PATCH body. An empty
+// string clears the date and yields nil.
+func parseTicketDate(v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ticketCalendar loads the business calendar of the ticket's team or its
+// region. It returns nil when neither has one.
+func ticketCalendar(ctx context.Context, db app.DB, ticketID string) (*sla.Calendar, error) {
+	var id string
+	err := db.QueryRow(ctx, `select coalesce(coalesce(tm.calendar_id, rg.calendar_id)::text, '')
+        from tickets t
+        left join teams tm on tm.id=t.team_id
+        left join regions rg on rg.id=tm.region_id
+        where t.id=$1`, ticketID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && id == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sla.LoadCalendar(ctx, db, id)
+}
+
+// checkDates validates dates against the calendar. With snap, dates outside
+// business time move to the next business time in place. Otherwise dates on
+// a holiday or closure are reported per field; dates merely outside business
+// hours are accepted.
+func checkDates(cal *sla.Calendar, dates map[string]*time.Time, snap bool) map[string]string {
+	conflicts := map[string]string{}
+	for field, at := range dates {
+		if at == nil {
+			continue
+		}
+		if snap {
+			if next := cal.NextBusinessTime(*at); !next.IsZero() {
+				*at = next
+			}
+			continue
+		}
+		b := cal.BlackoutAt(*at)
+		if b == nil || b.Reason == sla.BlackoutOutsideHours {
+			continue
+		}
+		msg := b.Reason
+		if b.Label != "" {
+			msg += " (" + b.Label + ")"
+		}
+		msg += fmt.Sprintf(" from %s to %s", b.Start.Format(time.RFC3339), b.End.Format(time.RFC3339))
+		if next := cal.NextBusinessTime(*at); !next.IsZero() {
+			msg += "; next business time " + next.Format(time.RFC3339)
+		}
+		conflicts[field] = msg
+	}
+	return conflicts
+}
@@ -0,0 +1,123 @@
+package tickets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+// calendarDB serves a Monday to Friday 9-17 New York calendar with
+// Christmas 2026 (a Friday) as a holiday, and records the ticket update.
+func calendarDB(updateArgs *[]any) *testutil.MockDB {
+	rowsOf := func(data [][]any) *testutil.MockRows {
+		i := 0
+		return &testutil.MockRows{
+			NextFunc: func() bool { i++; return i <= len(data) },
+			ScanFunc: func(dest ...interface{}) error {
+				for j, v := range data[i-1] {
+					switch d := dest[j].(type) {
+					case *int:
+						*d = v.(int)
+					case *time.Time:
+						*d = v.(time.Time)
+					}
+				}
+				return nil
+			},
+		}
+	}
+	return &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				switch {
+				case strings.Contains(sql, "calendar_id"):
+					*dest[0].(*string) = "cal1"
+				case strings.Contains(sql, "from calendars"):
+					*dest[0].(*string) = "America/New_York"
+				case strings.Contains(sql, "with before"):
+					*updateArgs = args
+					*dest[0].(*string) = "t1"
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			switch {
+			case strings.Contains(sql, "business_hours"):
+				var hours [][]any
+				for dow := 1; dow <= 5; dow++ {
+					hours = append(hours, []any{dow, 9 * 3600, 17 * 3600})
+				}
+				return rowsOf(hours), nil
+			case strings.Contains(sql, "from holidays"):
+				return rowsOf([][]any{{time.Date(2026, 12, 25, 0, 0, 0, 0, time.UTC)}}), nil
+			}
+			return rowsOf(nil), nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+	}
+}
+
+func TestUpdateDueDateCalendar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var updateArgs []any
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, calendarDB(&updateArgs), nil, nil, nil)
+	a.R.PATCH("/tickets/:id", authpkg.Middleware(a), Update(a))
+	patch := func(body string) *httptest.ResponseRecorder {
+		updateArgs = nil
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/tickets/t1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := patch(`{"due_at":"next friday"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid date, got %d", rr.Code)
+	}
+
+	rr := patch(`{"due_at":"2026-12-25T15:00:00Z"}`)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "date_unavailable") ||
+		!strings.Contains(rr.Body.String(), "next business time 2026-12-28T09:00:00-05:00") {
+		t.Fatalf("expected a holiday conflict, got %d %s", rr.Code, rr.Body.String())
+	}
+	if updateArgs != nil {
+		t.Fatal("the ticket must not be updated on conflict")
+	}
+
+	// Evenings are outside business hours but not a blackout.
+	if rr := patch(`{"due_at":"2026-12-23T23:00:00Z"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 outside business hours, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := patch(`{"due_at":"2026-12-25T15:00:00Z","force":true}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected force to keep the date, got %d", rr.Code)
+	}
+	if got := updateArgs[0].(*time.Time); !got.Equal(time.Date(2026, 12, 25, 15, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected forced date %v", got)
+	}
+
+	if rr := patch(`{"due_at":"2026-12-25T15:00:00Z","scheduled_at":"","snap":true}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected snap to succeed, got %d %s", rr.Code, rr.Body.String())
+	}
+	if updateArgs[0].(*time.Time) != nil {
+		t.Fatalf("expected scheduled_at cleared, got %v", updateArgs[0])
+	}
+	want := time.Date(2026, 12, 28, 14, 0, 0, 0, time.UTC)
+	if got := updateArgs[1].(*time.Time); !got.Equal(want) {
+		t.Fatalf("expected due_at snapped to %v, got %v", want, got)
+	}
+}
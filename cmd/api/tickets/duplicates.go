@@ -0,0 +1,234 @@
+package tickets
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/contracts"
+)
+
+// Duplicate is an open ticket that may describe the same problem as a new
+// one. Score ranges from 0 to 1.
+type Duplicate struct {
+	ID            string    `json:"id"`
+	Number        any       `json:"number"`
+	Title         string    `json:"title"`
+	Status        string    `json:"status"`
+	Requester     string    `json:"requester,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	SameRequester bool      `json:"same_requester"`
+	Score         float64   `json:"score"`
+}
+
+// Duplicate detection tuning. Title overlap weighs more than the body,
+// which tends to carry boilerplate such as signatures.
+const (
+	MinDuplicateScore = 0.3
+	maxDuplicates     = 5
+	duplicateTitleW   = 0.6
+)
+
+// freeMailDomains are not organisations: sharing one does not make two
+// requesters colleagues.
+var freeMailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true, "live.com": true,
+	"yahoo.com": true, "icloud.com": true, "me.com": true, "aol.com": true, "proton.me": true,
+	"protonmail.com": true, "gmx.com": true, "gmx.de": true, "mail.com": true, "yandex.ru": true,
+}
+
+// duplicateCandidatesQuery finds open tickets from the requester, or from
+// requesters sharing their email domain, matching any word of the new
+// ticket. The match expression is the one behind the tickets_fts index.
+const duplicateCandidatesQuery = `
+select t.id::text, t.number, t.title, t.status, coalesce(r.name, r.email, ''), t.created_at,
+       t.requester_id = $2::uuid,
+       tsvector_to_array(to_tsvector('english', coalesce(t.title,''))),
+       tsvector_to_array(to_tsvector('english', coalesce(t.title,'') || ' ' || coalesce(t.description,'')))
+from tickets t
+left join requesters r on r.id = t.requester_id
+where t.status not in ('Resolved','Closed')
+  and t.id::text <> $4
+  and (t.requester_id = $2::uuid or ($3 <> '' and split_part(lower(r.email), '@', 2) = $3))
+  and to_tsvector('english', coalesce(t.title,'') || ' ' || coalesce(t.description,'')) @@ to_tsquery('english', $1)
+order by ts_rank_cd(to_tsvector('english', coalesce(t.title,'') || ' ' || coalesce(t.description,'')), to_tsquery('english', $1)) desc
+limit 50`
+
+// FindDuplicates returns up to five open tickets resembling title and
+// description, best first. Candidates come from the same requester and, when
+// sameOrg is set, from requesters with the same email domain. excludeID
+// leaves out the ticket being checked.
+func FindDuplicates(ctx context.Context, db app.DB, requesterID, title, description, excludeID string, sameOrg bool) ([]Duplicate, error) {
+	out := []Duplicate{}
+	if requesterID == "" || strings.TrimSpace(title+description) == "" {
+		return out, nil
+	}
+	var titleLex, fullLex []string
+	var domain string
+	if err := db.QueryRow(ctx, `select tsvector_to_array(to_tsvector('english', $1)),
+            tsvector_to_array(to_tsvector('english', $1 || ' ' || $2)),
+            coalesce((select split_part(lower(email), '@', 2) from requesters where id = $3::uuid), '')`,
+		title, description, requesterID).Scan(&titleLex, &fullLex, &domain); err != nil {
+		return nil, err
+	}
+	if len(fullLex) == 0 {
+		return out, nil
+	}
+	if !sameOrg || freeMailDomains[domain] {
+		domain = ""
+	}
+	terms := make([]string, len(fullLex))
+	for i, l := range fullLex {
+		terms[i] = "'" + strings.ReplaceAll(l, "'", "''") + "'"
+	}
+	rows, err := db.Query(ctx, duplicateCandidatesQuery, strings.Join(terms, " | "), requesterID, domain, excludeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Duplicate
+		var candTitle, candFull []string
+		if err := rows.Scan(&d.ID, &d.Number, &d.Title, &d.Status, &d.Requester, &d.CreatedAt, &d.SameRequester, &candTitle, &candFull); err != nil {
+			return nil, err
+		}
+		d.Score = duplicateTitleW*lexemeOverlap(titleLex, candTitle) + (1-duplicateTitleW)*lexemeOverlap(fullLex, candFull)
+		if d.Score < MinDuplicateScore {
+			continue
+		}
+		d.Score = float64(int(d.Score*100+0.5)) / 100
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortDuplicates(out)
+	if len(out) > maxDuplicates {
+		out = out[:maxDuplicates]
+	}
+	return out, nil
+}
+
+// lexemeOverlap is the Jaccard index of two lexeme sets.
+func lexemeOverlap(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := make(map[string]bool, len(a))
+	for _, l := range a {
+		set[l] = true
+	}
+	inter, union := 0, len(set)
+	for _, l := range b {
+		if set[l] {
+			inter++
+		} else {
+			union++
+		}
+	}
+	return float64(inter) / float64(union)
+}
+
+// sortDuplicates orders by score, then by the same requester, then newest.
+func sortDuplicates(ds []Duplicate) {
+	slices.SortStableFunc(ds, func(a, b Duplicate) int {
+		switch {
+		case a.Score != b.Score:
+			return cmp.Compare(b.Score, a.Score)
+		case a.SameRequester != b.SameRequester:
+			if a.SameRequester {
+				return -1
+			}
+			return 1
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+}
+
+// duplicateScope returns the requester whose tickets the caller may be shown
+// as duplicates, and whether colleagues' tickets may be included. Staff
+// check on behalf of any requester; anyone else only sees their own tickets,
+// whatever requester they name.
+func duplicateScope(c *gin.Context, db app.DB, requesterID string) (string, bool) {
+	if authpkg.IsStaff(c) {
+		return requesterID, true
+	}
+	u, _ := c.Get("user")
+	au, _ := u.(authpkg.AuthUser)
+	if au.Email == "" {
+		return "", false
+	}
+	var own string
+	if err := db.QueryRow(c.Request.Context(), `select id::text from requesters where lower(email) = lower($1)`, au.Email).Scan(&own); err != nil {
+		return "", false
+	}
+	return own, false
+}
+
+// PreviewDuplicates lists possible duplicates of a ticket before it is
+// created. The body takes the same title, description and requester as
+// Create; a requester given by email that does not exist yet has none.
+func PreviewDuplicates(a *app.App) gin.HandlerFunc {
+	type req struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		RequesterID string `json:"requester_id"`
+		Requester   *struct {
+			Email string `json:"email"`
+		} `json:"requester"`
+	}
+	return func(c *gin.Context) {
+		var in req
+		if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Title+in.Description) == "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "title or description required", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		if in.RequesterID == "" && in.Requester != nil && in.Requester.Email != "" {
+			_ = a.DB.QueryRow(ctx, `select id::text from requesters where lower(email) = lower($1)`, in.Requester.Email).Scan(&in.RequesterID)
+		}
+		requesterID, sameOrg := duplicateScope(c, a.DB, in.RequesterID)
+		dups, err := FindDuplicates(ctx, a.DB, requesterID, in.Title, in.Description, "", sameOrg)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"duplicates": dups})
+	}
+}
+
+// createdTicket is the 201 body of Create: the ticket plus metadata about
+// it that is not part of the ticket itself.
+type createdTicket struct {
+	Ticket
+	Meta createdMeta `json:"meta"`
+}
+
+type createdMeta struct {
+	PossibleDuplicates []Duplicate `json:"possible_duplicates"`
+	// Entitlement is the requester's standing under their organization's
+	// support contract, omitted for requesters without an organization.
+	Entitlement *contracts.Entitlement `json:"entitlement,omitempty"`
+}
+
+// withDuplicates wraps a newly created ticket with its possible duplicates.
+// Lookup failures are logged; they must not fail the create.
+func withDuplicates(c *gin.Context, a *app.App, t Ticket) createdTicket {
+	out := createdTicket{Ticket: t, Meta: createdMeta{PossibleDuplicates: []Duplicate{}}}
+	requesterID, sameOrg := duplicateScope(c, a.DB, t.RequesterID)
+	dups, err := FindDuplicates(c.Request.Context(), a.DB, requesterID, t.Title, t.Description, t.ID, sameOrg)
+	if err != nil {
+		log.Warn().Err(err).Str("ticket", t.ID).Msg("find duplicate tickets")
+		return out
+	}
+	out.Meta.PossibleDuplicates = dups
+	return out
+}
@@ -0,0 +1,103 @@
+package tickets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestPreviewDuplicates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	candidates := [][]any{
+		{"t1", "HD-1", "VPN drops every hour", false, []string{"vpn", "drop", "everi", "hour"}, []string{"vpn", "drop", "everi", "hour"}},
+		{"t2", "HD-2", "Printer jammed", true, []string{"printer", "jam"}, []string{"printer", "jam", "vpn"}},
+		{"t3", "HD-3", "VPN drops", true, []string{"vpn", "drop"}, []string{"vpn", "drop", "laptop", "o'neil"}},
+	}
+	var queryArgs []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				switch {
+				case strings.Contains(sql, "tsvector_to_array"):
+					*dest[0].(*[]string) = []string{"vpn", "drop"}
+					*dest[1].(*[]string) = []string{"vpn", "drop", "laptop", "o'neil"}
+					*dest[2].(*string) = "acme.io"
+				case strings.Contains(sql, "from requesters"):
+					*dest[0].(*string) = "r-own"
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			queryArgs = args
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(candidates) },
+				ScanFunc: func(dest ...interface{}) error {
+					c := candidates[i-1]
+					*dest[0].(*string) = c[0].(string)
+					*dest[1].(*any) = c[1]
+					*dest[2].(*string) = c[2].(string)
+					*dest[3].(*string) = "Open"
+					*dest[5].(*time.Time) = created
+					*dest[6].(*bool) = c[3].(bool)
+					*dest[7].(*[]string) = c[4].([]string)
+					*dest[8].(*[]string) = c[5].([]string)
+					return nil
+				},
+			}, nil
+		},
+	}
+	var user authpkg.AuthUser
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets/duplicates", func(c *gin.Context) { c.Set("user", user) }, PreviewDuplicates(a))
+	preview := func(body string) (int, []Duplicate) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/duplicates", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		var out struct {
+			Duplicates []Duplicate `json:"duplicates"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out.Duplicates
+	}
+
+	if code, _ := preview(`{"title":" "}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without text, got %d", code)
+	}
+
+	user = authpkg.AuthUser{Email: "agent@acme.io", Roles: []string{"agent"}}
+	code, dups := preview(`{"title":"VPN drops","description":"On my laptop, O'Neil","requester_id":"r-other"}`)
+	if code != http.StatusOK || len(dups) != 2 || dups[0].ID != "t3" || dups[1].ID != "t1" {
+		t.Fatalf("unexpected duplicates %d %+v", code, dups)
+	}
+	if dups[0].Score != 1 || !dups[0].SameRequester {
+		t.Fatalf("expected an exact match from the same requester first, got %+v", dups[0])
+	}
+	if queryArgs[0] != `'vpn' | 'drop' | 'laptop' | 'o''neil'` || queryArgs[1] != "r-other" || queryArgs[2] != "acme.io" {
+		t.Fatalf("unexpected candidate query args %v", queryArgs)
+	}
+
+	// Requesters only see their own tickets, whoever they name.
+	user = authpkg.AuthUser{Email: "me@acme.io", Roles: []string{"requester"}}
+	if code, _ := preview(`{"title":"VPN drops","requester_id":"r-other"}`); code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	if queryArgs[1] != "r-own" || queryArgs[2] != "" {
+		t.Fatalf("expected the caller's own requester only, got %v", queryArgs)
+	}
+}
@@ -0,0 +1,246 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+)
+
+// Link kinds. Links are stored as parent_of, blocks or related_to; the
+// ticket at the other end sees child_of and blocked_by.
+const (
+	LinkParentOf  = "parent_of"
+	LinkChildOf   = "child_of"
+	LinkBlocks    = "blocks"
+	LinkBlockedBy = "blocked_by"
+	LinkRelatedTo = "related_to"
+)
+
+// linkInverse maps each kind to the kind seen from the other ticket.
+var linkInverse = map[string]string{
+	LinkParentOf:  LinkChildOf,
+	LinkChildOf:   LinkParentOf,
+	LinkBlocks:    LinkBlockedBy,
+	LinkBlockedBy: LinkBlocks,
+	LinkRelatedTo: LinkRelatedTo,
+}
+
+// Link is a ticket linked to another; Kind reads from that other ticket,
+// so a child's link to its parent is child_of.
+type Link struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	TicketID string `json:"ticket_id"`
+	Number   any    `json:"number"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+}
+
+// linksColumn selects the links of the ticket aliased t as a JSON array of
+// Links, oldest first.
+const linksColumn = `coalesce((select json_agg(json_build_object('id', l.id, 'ticket_id', o.id, 'number', o.number, 'title', o.title, 'status', o.status,
+            'kind', case when l.from_ticket_id = t.id then l.kind when l.kind = 'parent_of' then 'child_of'
+                         when l.kind = 'blocks' then 'blocked_by' else l.kind end) order by l.created_at)
+        from ticket_links l
+        join tickets o on o.id = case when l.from_ticket_id = t.id then l.to_ticket_id else l.from_ticket_id end
+        where l.from_ticket_id = t.id or l.to_ticket_id = t.id), '[]')`
+
+// decodeLinks reads a linksColumn value; empty or malformed values give no
+// links.
+func decodeLinks(b []byte) []Link {
+	var out []Link
+	if len(b) > 0 {
+		_ = json.Unmarshal(b, &out)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// ErrOpenChildren refuses resolving or closing a parent ticket while one of
+// its children is open.
+var ErrOpenChildren = errors.New("ticket has open child tickets")
+
+// openChildren counts the children of a ticket that are neither Resolved
+// nor Closed. It locks every child so none can reopen before the caller's
+// transaction resolves or closes the parent; call it inside that
+// transaction.
+func openChildren(ctx context.Context, db app.DB, ticketID string) (int, error) {
+	var n int
+	err := db.QueryRow(ctx, `with children as (select c.status from ticket_links l join tickets c on c.id = l.to_ticket_id
+            where l.from_ticket_id = $1 and l.kind = 'parent_of' for update of c)
+        select count(*) from children where status not in ('Resolved', 'Closed')`, ticketID).Scan(&n)
+	return n, err
+}
+
+// closesParent reports whether moving to status needs the ticket's children
+// done first.
+func closesParent(status string) bool {
+	return status == "Resolved" || status == "Closed"
+}
+
+// ListLinks returns the tickets linked to a ticket, oldest link first.
+func ListLinks(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var b []byte
+		err := a.DB.QueryRow(c.Request.Context(), `select `+linksColumn+` from tickets t where t.id = $1`, c.Param("id")).Scan(&b)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list links", nil)
+			return
+		}
+		out := decodeLinks(b)
+		if out == nil {
+			out = []Link{}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// linkError maps constraint violations on ticket_links to responses and
+// reports whether it wrote one.
+func linkError(c *gin.Context, err error) bool {
+	var pge *pgconn.PgError
+	if !errors.As(err, &pge) || pge.Code != "23505" {
+		return false
+	}
+	if pge.ConstraintName == "ticket_links_parent_idx" {
+		app.AbortError(c, http.StatusConflict, "has_parent", "the child ticket already has a parent", nil)
+	} else {
+		app.AbortError(c, http.StatusConflict, "link_exists", "the tickets are already linked this way", nil)
+	}
+	return true
+}
+
+// CreateLink links the ticket named by :id to another: kind is parent_of,
+// child_of, blocks, blocked_by or related_to, read from :id. A ticket has
+// one parent at most, and parent and blocking links may not form a cycle.
+func CreateLink(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			TicketID string `json:"ticket_id"`
+			Kind     string `json:"kind"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		id := c.Param("id")
+		errs := map[string]string{}
+		if _, ok := linkInverse[in.Kind]; !ok {
+			errs["kind"] = "must be parent_of, child_of, blocks, blocked_by or related_to"
+		}
+		if _, err := uuid.Parse(in.TicketID); err != nil {
+			errs["ticket_id"] = "must be a ticket id"
+		} else if in.TicketID == id {
+			errs["ticket_id"] = "cannot link a ticket to itself"
+		}
+		if len(errs) > 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		// Store the link in its canonical direction.
+		from, to, kind := id, in.TicketID, in.Kind
+		if kind == LinkChildOf || kind == LinkBlockedBy {
+			from, to, kind = to, from, linkInverse[kind]
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		link := Link{TicketID: in.TicketID, Kind: in.Kind}
+		errCycle := errors.New("cycle")
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			if err := tx.QueryRow(ctx, `select number, title, status from tickets where id = $1`, in.TicketID).Scan(&link.Number, &link.Title, &link.Status); err != nil {
+				return err
+			}
+			if kind != LinkRelatedTo {
+				// from may not already sit below to.
+				var cycle bool
+				if err := tx.QueryRow(ctx, `with recursive up(id) as (
+                        select $1::uuid
+                        union
+                        select l.from_ticket_id from ticket_links l join up on l.to_ticket_id = up.id where l.kind = $3)
+                    select exists (select 1 from up where id = $2::uuid)`, from, to, kind).Scan(&cycle); err != nil {
+					return err
+				}
+				if cycle {
+					return errCycle
+				}
+			}
+			if err := tx.QueryRow(ctx, `insert into ticket_links (from_ticket_id, to_ticket_id, kind, created_by) values ($1, $2, $3, $4) returning id::text`,
+				from, to, kind, act.DBID()).Scan(&link.ID); err != nil {
+				return err
+			}
+			diff := map[string]any{"link_id": link.ID, "from": from, "to": to, "kind": kind}
+			for _, t := range []string{from, to} {
+				if err := audit.RecordDiff(ctx, tx, act, "ticket", t, "ticket_linked", diff); err != nil {
+					return err
+				}
+				eventspkg.Emit(ctx, tx, act, t, "ticket_linked", diff)
+			}
+			return nil
+		})
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_id": "ticket not found"})
+		case errors.Is(err, errCycle):
+			app.AbortError(c, http.StatusConflict, "link_cycle", fmt.Sprintf("the link would make a %s cycle", kind), nil)
+		case linkError(c, err):
+		case err != nil:
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to link tickets", nil)
+		default:
+			c.JSON(http.StatusCreated, link)
+		}
+	}
+}
+
+// DeleteLink removes a link of the ticket named by :id.
+func DeleteLink(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := uuid.Parse(c.Param("link_id")); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "link not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var from, to, kind string
+			if err := tx.QueryRow(ctx, `delete from ticket_links where id = $1 and (from_ticket_id = $2 or to_ticket_id = $2)
+                returning from_ticket_id::text, to_ticket_id::text, kind`, c.Param("link_id"), c.Param("id")).Scan(&from, &to, &kind); err != nil {
+				return err
+			}
+			diff := map[string]any{"link_id": c.Param("link_id"), "from": from, "to": to, "kind": kind}
+			for _, t := range []string{from, to} {
+				if err := audit.RecordDiff(ctx, tx, act, "ticket", t, "ticket_unlinked", diff); err != nil {
+					return err
+				}
+				eventspkg.Emit(ctx, tx, act, t, "ticket_unlinked", diff)
+			}
+			return nil
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "link not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to unlink tickets", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
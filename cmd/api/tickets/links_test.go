@@ -0,0 +1,125 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCreateLink(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const parent, child, missing = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222", "33333333-3333-3333-3333-333333333333"
+	var inserted []any
+	var cycle bool
+	var dupErr error
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.HasPrefix(sql, "select number"):
+					if args[0] == missing {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*any), *dest[1].(*string), *dest[2].(*string) = "TKT-2", "Child", "Open"
+				case strings.HasPrefix(sql, "with recursive"):
+					*dest[0].(*bool) = cycle
+				case strings.HasPrefix(sql, "insert into ticket_links"):
+					if dupErr != nil {
+						return dupErr
+					}
+					inserted = args
+					*dest[0].(*string) = "44444444-4444-4444-4444-444444444444"
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := app.NewApp(app.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/links", CreateLink(a))
+	link := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/"+parent+"/links", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for body, code := range map[string]int{
+		`{"ticket_id":"` + child + `","kind":"duplicates"}`: http.StatusBadRequest,
+		`{"ticket_id":"` + parent + `","kind":"blocks"}`:    http.StatusBadRequest,
+		`{"ticket_id":"` + missing + `","kind":"blocks"}`:   http.StatusBadRequest,
+	} {
+		if rr := link(body); rr.Code != code {
+			t.Fatalf("%s: expected %d, got %d %s", body, code, rr.Code, rr.Body.String())
+		}
+	}
+
+	// child_of is stored as the other ticket's parent_of.
+	rr := link(`{"ticket_id":"` + child + `","kind":"child_of"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+	if inserted[0] != child || inserted[1] != parent || inserted[2] != LinkParentOf {
+		t.Fatalf("stored %v", inserted)
+	}
+	var got Link
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got.Kind != LinkChildOf || got.Title != "Child" {
+		t.Fatalf("unexpected link %+v %v", got, err)
+	}
+
+	cycle = true
+	if rr := link(`{"ticket_id":"` + child + `","kind":"parent_of"}`); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "link_cycle") {
+		t.Fatalf("expected link_cycle, got %d %s", rr.Code, rr.Body.String())
+	}
+	cycle = false
+	dupErr = &pgconn.PgError{Code: "23505", ConstraintName: "ticket_links_parent_idx"}
+	if rr := link(`{"ticket_id":"` + child + `","kind":"parent_of"}`); rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "has_parent") {
+		t.Fatalf("expected has_parent, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestResolveWithOpenChildren(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var updated bool
+	db := &testutil.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			updated = updated || strings.Contains(sql, "update tickets")
+			return pgconn.CommandTag{}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			updated = updated || strings.Contains(sql, "update tickets")
+			if !strings.Contains(sql, "for update of c") {
+				t.Fatalf("expected the children to be locked: %s", sql)
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int) = 2
+				return nil
+			}}
+		},
+	}
+	a := app.NewApp(app.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.PATCH("/tickets/:id", Update(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/tickets/t1", strings.NewReader(`{"status":"resolved"}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "open_children") {
+		t.Fatalf("expected 409 open_children, got %d %s", rr.Code, rr.Body.String())
+	}
+	if updated {
+		t.Fatal("a parent with open children must not be resolved")
+	}
+}
@@ -0,0 +1,63 @@
+package tickets
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+// requesterFields are the JSON fields of a Ticket requesters may see. It is
+// an allowlist so a field added for staff stays internal until it is named
+// here: SLA targets and risk, triage and enrichment results, tags, links,
+// asset details and read receipts are all left out.
+var requesterFields = map[string]bool{
+	"id":           true,
+	"number":       true,
+	"title":        true,
+	"description":  true,
+	"status":       true,
+	"assignee_id":  true,
+	"priority":     true,
+	"custom_json":  true,
+	"requester_id": true,
+	"requester":    true,
+	"created_at":   true,
+	"category":     true,
+	// The assignee's photo is shown next to their replies.
+	"assignee_avatar_url": true,
+	"scheduled_at":        true,
+	"due_at":              true,
+	"merged_into":         true,
+}
+
+// Mask clears what requester-role callers may not see from t: every field
+// outside requesterFields and the answers to custom fields schema marks
+// internal. Staff get t unchanged. Every handler that returns tickets to
+// requesters passes them through here.
+func Mask(c *gin.Context, t *Ticket, schema customfields.Schema) {
+	if authpkg.IsStaff(c) {
+		return
+	}
+	v := reflect.ValueOf(t).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if !requesterFields[name] {
+			v.Field(i).SetZero()
+		}
+	}
+	if custom, ok := t.CustomJSON.(map[string]any); ok {
+		t.CustomJSON = schema.StripInternal(custom)
+	}
+}
+
+// maskAll applies Mask to each of ts. Lists carry no custom fields, so no
+// schema is needed.
+func maskAll(c *gin.Context, ts []Ticket) {
+	for i := range ts {
+		Mask(c, &ts[i], customfields.Schema{})
+	}
+}
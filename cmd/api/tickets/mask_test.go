@@ -0,0 +1,73 @@
+package tickets
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	tagspkg "github.com/mark3748/helpdesk-go/cmd/api/tags"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+)
+
+func TestMask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	due, breach, sentiment := time.Now(), int64(-60000), "negative"
+	schema := customfields.Schema{Fields: []customfields.Field{
+		{Key: "device", Type: customfields.TypeText},
+		{Key: "triage_notes", Type: customfields.TypeText, Internal: true},
+	}}
+	ticket := func() Ticket {
+		return Ticket{
+			ID: "t1", Title: "Printer", Status: "Open", ResolutionDueAt: &due, BreachInMS: &breach, AtRisk: true,
+			Sentiment: &sentiment, Sensitive: true, Links: []Link{{ID: "l1"}}, Tags: []tagspkg.Ref{{ID: "g1", Name: "vip"}},
+			CustomJSON: map[string]any{"device": "laptop", "triage_notes": "VIP, escalate"},
+		}
+	}
+	as := func(roles ...string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("user", authpkg.AuthUser{ID: "u1", Roles: roles})
+		return c
+	}
+
+	staff := ticket()
+	Mask(as("agent"), &staff, schema)
+	if !reflect.DeepEqual(staff, ticket()) {
+		t.Fatalf("staff ticket changed: %+v", staff)
+	}
+
+	requester := ticket()
+	Mask(as("requester"), &requester, schema)
+	b, err := json.Marshal(requester)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	for k := range got {
+		if !requesterFields[k] {
+			t.Errorf("requester sees %s", k)
+		}
+	}
+	if _, ok := got["tags"]; ok || requester.Tags != nil {
+		t.Errorf("requester sees tags %v", requester.Tags)
+	}
+	if custom := got["custom_json"].(map[string]any); len(custom) != 1 || custom["device"] != "laptop" {
+		t.Errorf("requester sees custom fields %v", custom)
+	}
+	keys := make([]string, 0, len(got))
+	for k := range got {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"custom_json", "id", "status", "title"}) {
+		t.Errorf("requester sees %v", keys)
+	}
+}
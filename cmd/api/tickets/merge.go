@@ -0,0 +1,224 @@
+package tickets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+)
+
+// maxMerge bounds how many duplicates one call may merge.
+const maxMerge = 50
+
+// MergeResult says what a merge moved into the primary ticket.
+type MergeResult struct {
+	ID          string   `json:"id"`
+	Merged      []string `json:"merged"`
+	Comments    int64    `json:"comments"`
+	Attachments int64    `json:"attachments"`
+	Watchers    int64    `json:"watchers"`
+	// CCs counts the duplicates' requesters and CCs newly copied on the
+	// primary, so they keep seeing their conversation.
+	CCs int64 `json:"ccs"`
+}
+
+// mergeTicket is a ticket locked for a merge.
+type mergeTicket struct {
+	id, status string
+	number     any
+	mergedInto *string
+}
+
+// errMerge carries a refusal out of the merge transaction.
+type errMerge struct {
+	status        int
+	code, message string
+	fields        map[string]string
+}
+
+func (e *errMerge) Error() string { return e.message }
+
+// Merge merges duplicate tickets into the ticket named by :id. Their
+// comments and attachments move to it, their watchers watch it too and
+// their requesters and CCs are copied on it; the duplicates are closed
+// with merged_into set and their SLA clocks paused, so a duplicate with open
+// child tickets is refused.
+// Both sides get a ticket
+// event and an audit entry, and the duplicates' requesters are told where
+// their ticket went.
+func Merge(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			TicketIDs []string `json:"ticket_ids"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		primaryID := c.Param("id")
+		if _, err := uuid.Parse(primaryID); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		var dupIDs []string
+		for _, id := range in.TicketIDs {
+			if _, err := uuid.Parse(id); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_ids": "must be ticket ids"})
+				return
+			}
+			if id == primaryID {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_ids": "cannot include the primary ticket"})
+				return
+			}
+			if !slices.Contains(dupIDs, id) {
+				dupIDs = append(dupIDs, id)
+			}
+		}
+		if len(dupIDs) == 0 || len(dupIDs) > maxMerge {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"ticket_ids": "give 1 to 50 tickets"})
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var receiptBase string
+		if receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourceEmail) {
+			receiptBase = a.Cfg.PublicURL
+		}
+		res := MergeResult{ID: primaryID, Merged: dupIDs}
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			// Lock every ticket involved in id order so concurrent merges
+			// cannot deadlock.
+			rows, err := tx.Query(ctx, `select id::text, number, status, merged_into::text from tickets
+                where id = any($1::uuid[]) order by id for update`, append([]string{primaryID}, dupIDs...))
+			if err != nil {
+				return err
+			}
+			locked := map[string]mergeTicket{}
+			for rows.Next() {
+				var t mergeTicket
+				if err := rows.Scan(&t.id, &t.number, &t.status, &t.mergedInto); err != nil {
+					rows.Close()
+					return err
+				}
+				locked[t.id] = t
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			primary, ok := locked[primaryID]
+			if !ok {
+				return &errMerge{http.StatusNotFound, "not_found", "ticket not found", nil}
+			}
+			if primary.mergedInto != nil {
+				return &errMerge{http.StatusConflict, "already_merged", "the primary ticket was merged into another", nil}
+			}
+			for _, id := range dupIDs {
+				switch t, ok := locked[id]; {
+				case !ok:
+					return &errMerge{http.StatusBadRequest, "invalid_request", "validation error", map[string]string{id: "not found"}}
+				case t.mergedInto != nil:
+					return &errMerge{http.StatusConflict, "already_merged", "a ticket was already merged", map[string]string{id: "already merged"}}
+				}
+				// Merging closes the duplicate, which a parent may not do
+				// while its children are open.
+				n, err := openChildren(ctx, tx, id)
+				if err != nil {
+					return err
+				}
+				if n > 0 {
+					return &errMerge{http.StatusConflict, "open_children", ErrOpenChildren.Error(),
+						map[string]string{id: fmt.Sprintf("%d child tickets are still open", n)}}
+				}
+			}
+
+			tag, err := tx.Exec(ctx, `update ticket_comments set ticket_id = $1 where ticket_id = any($2::uuid[])`, primaryID, dupIDs)
+			if err != nil {
+				return err
+			}
+			res.Comments = tag.RowsAffected()
+			if tag, err = tx.Exec(ctx, `update attachments set ticket_id = $1 where ticket_id = any($2::uuid[])`, primaryID, dupIDs); err != nil {
+				return err
+			}
+			res.Attachments = tag.RowsAffected()
+			if tag, err = tx.Exec(ctx, `insert into ticket_watchers (ticket_id, user_id)
+                select distinct $1::uuid, user_id from ticket_watchers where ticket_id = any($2::uuid[])
+                on conflict do nothing`, primaryID, dupIDs); err != nil {
+				return err
+			}
+			res.Watchers = tag.RowsAffected()
+			if tag, err = tx.Exec(ctx, `insert into ticket_ccs (ticket_id, email, requester_id, added_by)
+                select distinct on (lower(x.email)) $1::uuid, x.email, x.requester_id, $3::uuid from (
+                    select r.email, r.id as requester_id from tickets t join requesters r on r.id = t.requester_id
+                    where t.id = any($2::uuid[]) and r.email is not null
+                    union all
+                    select email, requester_id from ticket_ccs where ticket_id = any($2::uuid[])) x
+                where not exists (select 1 from tickets p join requesters pr on pr.id = p.requester_id
+                    where p.id = $1 and lower(pr.email) = lower(x.email))
+                order by lower(x.email)
+                on conflict (ticket_id, email) do nothing`, primaryID, dupIDs, act.DBID()); err != nil {
+				return err
+			}
+			res.CCs = tag.RowsAffected()
+			if _, err := tx.Exec(ctx, `update tickets set status = 'Closed', merged_into = $1, updated_at = now()
+                where id = any($2::uuid[])`, primaryID, dupIDs); err != nil {
+				return err
+			}
+			// The duplicates' SLA clocks stop with them so they neither
+			// accrue nor escalate; reopening one resumes its clock.
+			if _, err := tx.Exec(ctx, `update ticket_sla_clocks set paused = true, reason = 'Merged' where ticket_id = any($1::uuid[])`, dupIDs); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `update tickets set updated_at = now() where id = $1`, primaryID); err != nil {
+				return err
+			}
+
+			for _, id := range dupIDs {
+				dup := locked[id]
+				if dup.status != "Closed" {
+					const hq = `insert into ticket_status_history (ticket_id, from_status, to_status, actor_id) values ($1, nullif($2, ''), 'Closed', $3)`
+					if _, err := tx.Exec(ctx, hq, id, dup.status, act.DBID()); err != nil {
+						return err
+					}
+				}
+				if err := audit.RecordDiff(ctx, tx, act, "ticket", id, "ticket_merged_into", map[string]any{
+					"merged_into": primaryID, "status": map[string]any{"before": dup.status, "after": "Closed"},
+				}); err != nil {
+					return err
+				}
+				eventspkg.Emit(ctx, tx, act, id, "ticket_merged_into", map[string]any{"id": id, "merged_into": primaryID, "merged_into_number": primary.number})
+				if err := outbox.AddEvent(ctx, tx, "ticket_updated:"+id+":"+uuid.NewString(), "ticket_updated", Ticket{ID: id, Number: dup.number, Status: "Closed"}); err != nil {
+					return err
+				}
+				if err := notifyRequesterUpdate(ctx, tx, id, dup.number, []string{"merged into " + fmt.Sprint(primary.number)}, receiptBase); err != nil {
+					return err
+				}
+			}
+			if err := audit.RecordDiff(ctx, tx, act, "ticket", primaryID, "ticket_merged", res); err != nil {
+				return err
+			}
+			eventspkg.Emit(ctx, tx, act, primaryID, "ticket_merged", map[string]any{"id": primaryID, "merged": dupIDs})
+			return outbox.AddEvent(ctx, tx, "ticket_updated:"+primaryID+":"+uuid.NewString(), "ticket_updated", Ticket{ID: primaryID, Number: primary.number, Status: primary.status})
+		})
+		var me *errMerge
+		if errors.As(err, &me) {
+			app.AbortError(c, me.status, me.code, me.message, me.fields)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to merge tickets", nil)
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	}
+}
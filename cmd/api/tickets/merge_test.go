@@ -0,0 +1,129 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestMerge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const primary, dup, merged = "11111111-1111-1111-1111-111111111111", "22222222-2222-2222-2222-222222222222", "33333333-3333-3333-3333-333333333333"
+	const missing, parent = "44444444-4444-4444-4444-444444444444", "55555555-5555-5555-5555-555555555555"
+	tickets := map[string]mergeTicket{
+		primary: {id: primary, number: "TKT-1", status: "Open"},
+		dup:     {id: dup, number: "TKT-2", status: "New"},
+		merged:  {id: merged, number: "TKT-3", status: "Closed", mergedInto: new(string)},
+		parent:  {id: parent, number: "TKT-5", status: "Open"},
+	}
+	var execs []string
+	var actions []string
+	db := &testutil.MockDB{
+		// parent has an open child ticket.
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if !strings.Contains(sql, "for update of c") {
+					t.Fatalf("expected the children to be locked: %s", sql)
+				}
+				if args[0] == parent {
+					*dest[0].(*int) = 1
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			var found []mergeTicket
+			for _, id := range args[0].([]string) {
+				if t, ok := tickets[id]; ok {
+					found = append(found, t)
+				}
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(found) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*any), *dest[2].(*string) = found[i].id, found[i].number, found[i].status
+					*dest[3].(**string) = found[i].mergedInto
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			execs = append(execs, sql)
+			if strings.HasPrefix(sql, "insert into audit_events") {
+				actions = append(actions, args[4].(string))
+			}
+			switch {
+			case strings.HasPrefix(sql, "update ticket_comments"):
+				return pgconn.NewCommandTag("UPDATE 3"), nil
+			case strings.HasPrefix(sql, "update attachments"):
+				return pgconn.NewCommandTag("UPDATE 1"), nil
+			}
+			return pgconn.NewCommandTag("INSERT 0 0"), nil
+		},
+	}
+	a := app.NewApp(app.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/merge", Merge(a))
+
+	merge := func(id, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/tickets/"+id+"/merge", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+	for body, code := range map[string]int{
+		`{"ticket_ids":[]}`:                  http.StatusBadRequest,
+		`{"ticket_ids":["` + primary + `"]}`: http.StatusBadRequest,
+		`{"ticket_ids":["TKT-2"]}`:           http.StatusBadRequest,
+		`{"ticket_ids":["` + merged + `"]}`:  http.StatusConflict,
+		`{"ticket_ids":["` + missing + `"]}`: http.StatusBadRequest,
+		`{"ticket_ids":["` + parent + `"]}`:  http.StatusConflict,
+	} {
+		if rr := merge(primary, body); rr.Code != code {
+			t.Fatalf("%s: expected %d, got %d %s", body, code, rr.Code, rr.Body.String())
+		}
+	}
+	if rr := merge(merged, `{"ticket_ids":["`+dup+`"]}`); rr.Code != http.StatusConflict {
+		t.Fatalf("merging into a merged ticket: %d", rr.Code)
+	}
+	if len(execs) != 0 {
+		t.Fatalf("refused merges wrote: %v", execs)
+	}
+
+	rr := merge(primary, `{"ticket_ids":["`+dup+`","`+dup+`"]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	var res MergeResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Merged) != 1 || res.Comments != 3 || res.Attachments != 1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if strings.Join(actions, ",") != "ticket_merged_into,ticket_merged" {
+		t.Fatalf("audited %v", actions)
+	}
+	var closed, stopped bool
+	for _, sql := range execs {
+		closed = closed || strings.Contains(sql, "status = 'Closed', merged_into = $1")
+		stopped = stopped || strings.HasPrefix(sql, "update ticket_sla_clocks set paused = true")
+	}
+	if !closed {
+		t.Fatalf("duplicate not closed: %v", execs)
+	}
+	if !stopped {
+		t.Fatalf("duplicate's SLA clock left running: %v", execs)
+	}
+}
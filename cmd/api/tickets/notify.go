@@ -0,0 +1,64 @@
+package tickets
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+)
+
+// notifyRequesterUpdate records a ticket_updated email for the ticket's
+// requester in the outbox. The worker coalesces bursts of these per ticket
+// and recipient, so callers may notify on every change. With a receiptBase
+// the email carries a read receipt pixel served from that address.
+func notifyRequesterUpdate(ctx context.Context, db app.DB, ticketID string, number any, changes []string, receiptBase string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	return notifyRequester(ctx, db, ticketID, "ticket_updated", map[string]any{
+		"Number":  number,
+		"Summary": strings.Join(changes, ", "),
+	}, receiptBase)
+}
+
+// notifyRequesterResolved records the ticket_resolved email, which carries
+// the CSAT survey link, in place of the usual update email.
+func notifyRequesterResolved(ctx context.Context, db app.DB, ticketID string, number any, csatURL, receiptBase string) error {
+	return notifyRequester(ctx, db, ticketID, "ticket_resolved", map[string]any{
+		"Number":  number,
+		"CSATURL": csatURL,
+	}, receiptBase)
+}
+
+func notifyRequester(ctx context.Context, db app.DB, ticketID, template string, data map[string]any, receiptBase string) error {
+	var receipt string
+	if receiptBase != "" {
+		var err error
+		if receipt, err = receipts.New(ctx, db, ticketID, receiptBase); err != nil {
+			return err
+		}
+	}
+	job, err := jobs.Encode("", jobs.TypeSendEmail, jobs.Email{
+		Template: template,
+		TicketID: &ticketID,
+		Data:     data,
+		Receipt:  receipt,
+	})
+	if err != nil {
+		return err
+	}
+	// The recipient is filled in by the database so requesters without an
+	// email address simply produce no row.
+	const q = `insert into outbox (kind, dedup_key, payload)
+select $1, $2, jsonb_set($3::jsonb, '{data,to}', to_jsonb(r.email))
+from tickets t join requesters r on r.id = t.requester_id
+where t.id = $4 and coalesce(r.email,'') <> ''
+on conflict (dedup_key) do nothing`
+	_, err = db.Exec(ctx, q, outbox.KindJob, template+"_email:"+ticketID+":"+uuid.NewString(), string(job), ticketID)
+	return err
+}
@@ -0,0 +1,222 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/pdf"
+)
+
+// printRecord is everything the printable ticket shows. Internal comments and
+// audit detail are left out so the record can be handed to customers.
+type printRecord struct {
+	Number, Title, Description, Status, Category string
+	Priority                                     int16
+	Requester, Assignee, Team                    string
+	CreatedAt, UpdatedAt                         time.Time
+	Timeline                                     []printStatus
+	Comments                                     []printComment
+	Attachments                                  []printAttachment
+}
+
+type printStatus struct {
+	At       time.Time
+	From, To string
+	Actor    string
+}
+
+type printComment struct {
+	At     time.Time
+	Author string
+	Body   string
+}
+
+type printAttachment struct {
+	Filename string
+	Bytes    int64
+	At       time.Time
+}
+
+// loadPrintRecord gathers a ticket's printable record. pgx.ErrNoRows is
+// returned for unknown tickets.
+func loadPrintRecord(ctx context.Context, db app.DB, id string) (printRecord, error) {
+	var r printRecord
+	if err := db.QueryRow(ctx, `select t.number, t.title, coalesce(t.description,''), t.status, t.priority, coalesce(t.category,''),
+            coalesce(rq.name, rq.email, ''), coalesce(au.display_name, au.email, ''), coalesce(tm.name,''), t.created_at, t.updated_at
+        from tickets t
+        left join requesters rq on rq.id=t.requester_id
+        left join users au on au.id=t.assignee_id
+        left join teams tm on tm.id=t.team_id
+        where t.id=$1`, id).Scan(&r.Number, &r.Title, &r.Description, &r.Status, &r.Priority, &r.Category,
+		&r.Requester, &r.Assignee, &r.Team, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return r, err
+	}
+
+	rows, err := db.Query(ctx, `select h.at, coalesce(h.from_status,''), h.to_status, coalesce(u.display_name, u.email, '')
+        from ticket_status_history h left join users u on u.id=h.actor_id
+        where h.ticket_id=$1 order by h.at`, id)
+	if err != nil {
+		return r, err
+	}
+	for rows.Next() {
+		var s printStatus
+		if err := rows.Scan(&s.At, &s.From, &s.To, &s.Actor); err != nil {
+			rows.Close()
+			return r, err
+		}
+		r.Timeline = append(r.Timeline, s)
+	}
+	rows.Close()
+
+	rows, err = db.Query(ctx, `select tc.created_at, coalesce(u.display_name, u.email, rq.name, rq.email, ''), tc.body_md
+        from ticket_comments tc
+        left join users u on u.id=tc.author_id
+        left join requesters rq on rq.id=tc.author_requester_id
+        where tc.ticket_id=$1 and not tc.is_internal order by tc.created_at`, id)
+	if err != nil {
+		return r, err
+	}
+	for rows.Next() {
+		var cm printComment
+		if err := rows.Scan(&cm.At, &cm.Author, &cm.Body); err != nil {
+			rows.Close()
+			return r, err
+		}
+		r.Comments = append(r.Comments, cm)
+	}
+	rows.Close()
+
+	rows, err = db.Query(ctx, `select filename, bytes, created_at from attachments where ticket_id=$1 and not is_internal order by created_at`, id)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var at printAttachment
+		if err := rows.Scan(&at.Filename, &at.Bytes, &at.At); err != nil {
+			return r, err
+		}
+		r.Attachments = append(r.Attachments, at)
+	}
+	return r, rows.Err()
+}
+
+func printTime(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") }
+
+func printSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// render lays the record out as a PDF.
+func (r printRecord) render(now time.Time) []byte {
+	d := pdf.New(r.Number + " " + r.Title)
+	d.SetFooter(r.Number + "  -  generated " + printTime(now))
+
+	d.Heading("Details")
+	priority := fmt.Sprint(r.Priority)
+	if l := PriorityLabels[r.Priority]; l != "" {
+		priority += " - " + l
+	}
+	d.Field("Status", r.Status)
+	d.Field("Priority", priority)
+	d.Field("Category", r.Category)
+	d.Field("Requester", r.Requester)
+	d.Field("Assignee", r.Assignee)
+	d.Field("Team", r.Team)
+	d.Field("Created", printTime(r.CreatedAt))
+	d.Field("Last updated", printTime(r.UpdatedAt))
+	if strings.TrimSpace(r.Description) != "" {
+		d.Heading("Description")
+		d.Text(r.Description)
+	}
+
+	d.Heading("Timeline")
+	d.Field(printTime(r.CreatedAt), "Created")
+	for _, s := range r.Timeline {
+		line := s.To
+		if s.From != "" {
+			line = s.From + " -> " + s.To
+		}
+		if s.Actor != "" {
+			line += " by " + s.Actor
+		}
+		d.Field(printTime(s.At), line)
+	}
+
+	d.Heading(fmt.Sprintf("Comments (%d)", len(r.Comments)))
+	if len(r.Comments) == 0 {
+		d.Text("No public comments.")
+	}
+	for i, cm := range r.Comments {
+		if i > 0 {
+			d.Space()
+		}
+		author := cm.Author
+		if author == "" {
+			author = "Unknown"
+		}
+		d.Note(author + " - " + printTime(cm.At))
+		d.Text(cm.Body)
+	}
+
+	d.Heading(fmt.Sprintf("Attachments (%d)", len(r.Attachments)))
+	if len(r.Attachments) == 0 {
+		d.Text("No attachments.")
+	}
+	for _, at := range r.Attachments {
+		d.Text(fmt.Sprintf("%s  (%s, %s)", at.Filename, printSize(at.Bytes), printTime(at.At)))
+	}
+	return d.Bytes()
+}
+
+// RenderPDF renders the record PDF serves and returns it with the ticket
+// number. pgx.ErrNoRows is returned for unknown tickets.
+func RenderPDF(ctx context.Context, db app.DB, id string, now time.Time) ([]byte, string, error) {
+	r, err := loadPrintRecord(ctx, db, id)
+	if err != nil {
+		return nil, "", err
+	}
+	return r.render(now), r.Number, nil
+}
+
+// PDF renders a printable record of the ticket: details, status timeline,
+// public comments and the attachment list.
+func PDF(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		doc, number, err := RenderPDF(c.Request.Context(), a.DB, c.Param("id"), time.Now())
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load ticket", nil)
+			return
+		}
+		filename := strings.Map(func(r rune) rune {
+			if r == '"' || r == '\\' || r < 32 {
+				return -1
+			}
+			return r
+		}, number)
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.pdf"`)
+		c.Data(http.StatusOK, "application/pdf", doc)
+	}
+}
@@ -0,0 +1,97 @@
+package tickets
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	mockdb "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestTicketPDF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	var commentSQL string
+	db := &mockdb.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &mockdb.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != "t1" {
+					return pgx.ErrNoRows
+				}
+				vals := []any{"HD-7", "Printer on fire", "Smoke everywhere", "Open", int16(1), "Hardware", "Ann", "Bob", "Support", now, now}
+				for i, v := range vals {
+					switch d := dest[i].(type) {
+					case *string:
+						*d = v.(string)
+					case *int16:
+						*d = v.(int16)
+					case *time.Time:
+						*d = v.(time.Time)
+					}
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+			var row []any
+			switch {
+			case strings.Contains(sql, "ticket_status_history"):
+				row = []any{now, "New", "Open", "Bob"}
+			case strings.Contains(sql, "ticket_comments"):
+				commentSQL = sql
+				row = []any{now, "Ann", "It is still burning"}
+			case strings.Contains(sql, "attachments"):
+				row = []any{"photo.jpg", int64(2048), now}
+			}
+			done := false
+			return &mockdb.MockRows{
+				NextFunc: func() bool { ok := !done; done = true; return ok },
+				ScanFunc: func(dest ...any) error {
+					for i, v := range row {
+						switch d := dest[i].(type) {
+						case *string:
+							*d = v.(string)
+						case *int64:
+							*d = v.(int64)
+						case *time.Time:
+							*d = v.(time.Time)
+						}
+					}
+					return nil
+				},
+			}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.GET("/tickets/:id/pdf", authpkg.Middleware(a), PDF(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets/t1/pdf", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatalf("expected PDF, got %d %q: %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="HD-7.pdf"` {
+		t.Fatalf("unexpected disposition %q", got)
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("%PDF-")) || !bytes.Contains(rr.Body.Bytes(), []byte("/Title (HD-7 Printer on fire)")) {
+		t.Fatal("response is not the ticket PDF")
+	}
+	if !strings.Contains(commentSQL, "not tc.is_internal") {
+		t.Fatalf("internal comments must be excluded: %s", commentSQL)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets/missing/pdf", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// priorityRulesTTL bounds how stale an instance's copy of the settings can
+// get after another instance saved them.
+const priorityRulesTTL = 30 * time.Second
+
+var priorityRulesCache struct {
+	mu sync.Mutex
+	at time.Time
+	r  sla.PriorityChangeRules
+}
+
+// cachedPriorityRules returns the SLA priority-change rules, reloading them
+// at most every priorityRulesTTL. On a load error the last good copy is
+// kept. Test apps use whatever setCachedPriorityRules installed.
+func cachedPriorityRules(ctx context.Context, a *app.App) sla.PriorityChangeRules {
+	priorityRulesCache.mu.Lock()
+	defer priorityRulesCache.mu.Unlock()
+	if a.DB == nil || a.Cfg.Env == "test" || time.Since(priorityRulesCache.at) < priorityRulesTTL {
+		return priorityRulesCache.r
+	}
+	r, err := loadPriorityRules(ctx, a.DB)
+	if err != nil {
+		log.Error().Err(err).Msg("load sla priority rules")
+	} else {
+		priorityRulesCache.r = r
+	}
+	priorityRulesCache.at = time.Now()
+	return priorityRulesCache.r
+}
+
+func setCachedPriorityRules(r sla.PriorityChangeRules) {
+	priorityRulesCache.mu.Lock()
+	priorityRulesCache.r = r
+	priorityRulesCache.at = time.Now()
+	priorityRulesCache.mu.Unlock()
+}
+
+func loadPriorityRules(ctx context.Context, db app.DB) (sla.PriorityChangeRules, error) {
+	var r sla.PriorityChangeRules
+	var raw []byte
+	err := db.QueryRow(ctx, `select sla_priority_change from settings where id=1`).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r, nil
+	}
+	if err != nil {
+		return r, err
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return r, err
+		}
+	}
+	return r, nil
+}
+
+// withDefaults fills unset directions with keep, so clients see the rule
+// that actually applies.
+func withDefaults(r sla.PriorityChangeRules) sla.PriorityChangeRules {
+	if r.Raise == "" {
+		r.Raise = sla.RuleKeep
+	}
+	if r.Lower == "" {
+		r.Lower = sla.RuleKeep
+	}
+	return r
+}
+
+// GetPriorityRules returns how SLA clocks react to priority changes.
+func GetPriorityRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, withDefaults(cachedPriorityRules(c.Request.Context(), a)))
+			return
+		}
+		r, err := loadPriorityRules(c.Request.Context(), a.DB)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, withDefaults(r))
+	}
+}
+
+// SavePriorityRules replaces the SLA priority-change rules. Changes apply
+// immediately on this instance and within 30 seconds on the others.
+func SavePriorityRules(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in sla.PriorityChangeRules
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		in = withDefaults(in)
+		for field, rule := range map[string]string{"raise": in.Raise, "lower": in.Lower} {
+			if !sla.ValidRule(rule) {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "rule must be keep, restart or prorate", map[string]string{field: rule})
+				return
+			}
+		}
+		if a.DB != nil {
+			b, _ := json.Marshal(in)
+			if _, err := a.DB.Exec(c.Request.Context(), `update settings set sla_priority_change=$1::jsonb where id=1`, string(b)); err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+		}
+		setCachedPriorityRules(in)
+		c.JSON(http.StatusOK, in)
+	}
+}
@@ -0,0 +1,43 @@
+package tickets
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+)
+
+// markSeen records a portal read receipt when the caller is the ticket's
+// requester. Failures only cost the receipt.
+func markSeen(c *gin.Context, a *app.App, ticketID string) {
+	if authpkg.IsStaff(c) || !receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourcePortal) {
+		return
+	}
+	v, _ := c.Get("user")
+	u, ok := v.(authpkg.AuthUser)
+	if !ok || u.Email == "" {
+		return
+	}
+	if err := receipts.SeenByRequester(c.Request.Context(), a.DB, ticketID, u.Email); err != nil {
+		log.Error().Err(err).Str("ticket", ticketID).Msg("record read receipt")
+	}
+}
+
+// Receipt serves the tracking pixel embedded in requester emails and records
+// the open. It is public and always returns the pixel so that mail clients
+// learn nothing about the token.
+func Receipt(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB != nil && receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourceEmail) {
+			if err := receipts.Open(c.Request.Context(), a.DB, c.Param("token")); err != nil {
+				log.Error().Err(err).Msg("record email receipt")
+			}
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "image/gif", receipts.Pixel)
+	}
+}
@@ -0,0 +1,188 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// Ticket list scopes, from the widest to the narrowest.
+const (
+	ScopeAll      = "all"
+	ScopeTeam     = "team"
+	ScopeAssigned = "assigned"
+	ScopeOwn      = "own"
+)
+
+var scopeRank = map[string]int{ScopeAll: 3, ScopeTeam: 2, ScopeAssigned: 1, ScopeOwn: 0}
+
+// ListScope is a role's default view of GET /tickets.
+type ListScope struct {
+	Scope string `json:"scope"`
+	// OpenOnly also hides resolved tickets unless a status filter is given.
+	OpenOnly bool `json:"open_only,omitempty"`
+	// Enforced makes the scope a restriction: ?scope= may only narrow it.
+	Enforced bool `json:"enforced,omitempty"`
+}
+
+// listScopesTTL bounds how stale an instance's copy of the settings can get
+// after another instance saved them.
+const listScopesTTL = 30 * time.Second
+
+var listScopesCache struct {
+	mu sync.Mutex
+	at time.Time
+	s  map[string]ListScope
+}
+
+// cachedListScopes returns the per-role scopes, reloading them at most every
+// listScopesTTL. On a load error the last good copy is kept. Test apps use
+// whatever setCachedListScopes installed.
+func cachedListScopes(ctx context.Context, a *app.App) map[string]ListScope {
+	listScopesCache.mu.Lock()
+	defer listScopesCache.mu.Unlock()
+	if a.DB == nil || a.Cfg.Env == "test" || time.Since(listScopesCache.at) < listScopesTTL {
+		return listScopesCache.s
+	}
+	s, err := loadListScopes(ctx, a.DB)
+	if err != nil {
+		log.Error().Err(err).Msg("load list scopes")
+	} else {
+		listScopesCache.s = s
+	}
+	listScopesCache.at = time.Now()
+	return listScopesCache.s
+}
+
+func setCachedListScopes(s map[string]ListScope) {
+	listScopesCache.mu.Lock()
+	listScopesCache.s = s
+	listScopesCache.at = time.Now()
+	listScopesCache.mu.Unlock()
+}
+
+func loadListScopes(ctx context.Context, db app.DB) (map[string]ListScope, error) {
+	var raw []byte
+	err := db.QueryRow(ctx, `select list_scopes from settings where id=1`).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return map[string]ListScope{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := map[string]ListScope{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// effectiveScope resolves the caller's scope. Staff get the widest scope of
+// their roles, with unconfigured roles seeing everything; anyone else is
+// confined to their own tickets. requested, from ?scope=, replaces the
+// default unless the scope is enforced, in which case it may only narrow it.
+func effectiveScope(c *gin.Context, scopes map[string]ListScope, requested string) ListScope {
+	out := ListScope{Scope: ScopeOwn, OpenOnly: scopes["requester"].OpenOnly, Enforced: true}
+	if authpkg.IsStaff(c) {
+		u, _ := c.Get("user")
+		au, _ := u.(authpkg.AuthUser)
+		staff := false
+		for _, role := range au.Roles {
+			ls, ok := scopes[role]
+			if !ok {
+				ls = ListScope{Scope: ScopeAll}
+			}
+			if _, known := scopeRank[ls.Scope]; !known || role == "requester" {
+				continue
+			}
+			if !staff || scopeRank[ls.Scope] > scopeRank[out.Scope] {
+				out, staff = ls, true
+			}
+		}
+	}
+	if r, ok := scopeRank[requested]; ok && (!out.Enforced || r <= scopeRank[out.Scope]) {
+		out.Scope = requested
+	}
+	return out
+}
+
+// scopeFilter returns the SQL condition restricting tickets (t, requesters
+// r) to scope, appending its arguments to args. Callers without an ID or
+// email match nothing in the scopes that need them.
+func scopeFilter(c *gin.Context, scope string, args []any) (string, []any) {
+	u, _ := c.Get("user")
+	au, _ := u.(authpkg.AuthUser)
+	n := len(args) + 1
+	switch scope {
+	case ScopeAll:
+		return "", args
+	case ScopeTeam:
+		return fmt.Sprintf("(t.assignee_id = nullif($%[1]d,'')::uuid or t.team_id in (select tm.team_id from team_members tm where tm.user_id = nullif($%[1]d,'')::uuid))", n), append(args, au.ID)
+	case ScopeAssigned:
+		return fmt.Sprintf("t.assignee_id = nullif($%d,'')::uuid", n), append(args, au.ID)
+	default:
+		return fmt.Sprintf("lower(r.email) = lower(nullif($%d,''))", n), append(args, au.Email)
+	}
+}
+
+// GetListScopes returns the per-role ticket list scopes.
+func GetListScopes(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, map[string]ListScope{})
+			return
+		}
+		s, err := loadListScopes(c.Request.Context(), a.DB)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, s)
+	}
+}
+
+// SaveListScopes replaces the per-role ticket list scopes. Changes apply
+// immediately on this instance and within 30 seconds on the others.
+func SaveListScopes(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in map[string]ListScope
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_json", "invalid json", nil)
+			return
+		}
+		for role, ls := range in {
+			if _, ok := scopeRank[ls.Scope]; !ok {
+				app.AbortError(c, http.StatusBadRequest, "invalid_scope", "scope must be all, team, assigned or own", map[string]string{role: ls.Scope})
+				return
+			}
+			if role == "requester" && ls.Scope != ScopeOwn {
+				app.AbortError(c, http.StatusBadRequest, "invalid_scope", "requesters can only see their own tickets", map[string]string{role: ls.Scope})
+				return
+			}
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, in)
+			return
+		}
+		b, _ := json.Marshal(in)
+		if _, err := a.DB.Exec(c.Request.Context(), `update settings set list_scopes=$1::jsonb where id=1`, string(b)); err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		setCachedListScopes(in)
+		c.JSON(http.StatusOK, in)
+	}
+}
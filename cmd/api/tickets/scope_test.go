@@ -0,0 +1,91 @@
+package tickets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+func TestListScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setCachedListScopes(map[string]ListScope{
+		"agent":     {Scope: ScopeTeam, OpenOnly: true},
+		"triage":    {Scope: ScopeAssigned, Enforced: true},
+		"requester": {Scope: ScopeOwn, OpenOnly: true},
+	})
+	defer setCachedListScopes(nil)
+
+	db := &listDB{}
+	var user authpkg.AuthUser
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/tickets", func(c *gin.Context) { c.Set("user", user) }, List(a))
+	list := func(url string) {
+		db.sql, db.args = "", nil
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", url, rr.Code)
+		}
+	}
+	const team = "t.team_id in (select tm.team_id from team_members tm"
+
+	user = authpkg.AuthUser{ID: "u1", Email: "ann@acme.io", Roles: []string{"requester"}}
+	list("/tickets?scope=all")
+	if !strings.Contains(db.sql, "lower(r.email) = lower(nullif($1,''))") || db.args[0] != "ann@acme.io" || !strings.Contains(db.sql, "t.status <> 'Resolved'") {
+		t.Fatalf("expected requesters confined to their own open tickets: %s %v", db.sql, db.args)
+	}
+
+	user = authpkg.AuthUser{ID: "u2", Email: "bob@acme.io", Roles: []string{"agent"}}
+	list("/tickets")
+	if !strings.Contains(db.sql, team) || db.args[0] != "u2" || !strings.Contains(db.sql, "t.status <> 'Resolved'") {
+		t.Fatalf("expected the agent's team scope: %s %v", db.sql, db.args)
+	}
+	list("/tickets?status=Resolved&scope=all")
+	if strings.Contains(db.sql, team) || strings.Contains(db.sql, "<> 'Resolved'") || len(db.args) != 1 {
+		t.Fatalf("expected a default scope to be overridable: %s %v", db.sql, db.args)
+	}
+
+	user = authpkg.AuthUser{ID: "u3", Roles: []string{"agent", "triage"}}
+	list("/tickets?scope=all")
+	if strings.Contains(db.sql, team) || len(db.args) != 0 {
+		t.Fatalf("expected the widest role to win: %s %v", db.sql, db.args)
+	}
+
+	user = authpkg.AuthUser{ID: "u4", Roles: []string{"agent"}}
+	setCachedListScopes(map[string]ListScope{"agent": {Scope: ScopeAssigned, Enforced: true}})
+	list("/tickets?scope=all")
+	if !strings.Contains(db.sql, "t.assignee_id = nullif($1,'')::uuid") || db.args[0] != "u4" {
+		t.Fatalf("expected an enforced scope to hold: %s %v", db.sql, db.args)
+	}
+
+	user = authpkg.AuthUser{ID: "u5", Roles: []string{"admin"}}
+	list("/tickets")
+	if len(db.args) != 0 {
+		t.Fatalf("expected unconfigured roles to see everything: %s %v", db.sql, db.args)
+	}
+}
+
+func TestSaveListScopesValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, nil, nil, nil, nil)
+	a.R.PUT("/settings/list-scopes", SaveListScopes(a))
+	for body, want := range map[string]int{
+		`{"agent":{"scope":"team","open_only":true}}`: http.StatusOK,
+		`{"agent":{"scope":"everything"}}`:            http.StatusBadRequest,
+		`{"requester":{"scope":"all"}}`:               http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/settings/list-scopes", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", body, want, rr.Code)
+		}
+	}
+}
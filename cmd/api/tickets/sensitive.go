@@ -0,0 +1,54 @@
+package tickets
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+)
+
+// SetSensitive flags or unflags a ticket as sensitive. Reads of sensitive
+// tickets are recorded in the access log; the flag change itself is audited.
+func SetSensitive(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Sensitive *bool `json:"sensitive"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || in.Sensitive == nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "sensitive is required", map[string]string{"sensitive": "required"})
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "sensitive": *in.Sensitive})
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		err := app.InTx(c.Request.Context(), a.DB, func(tx app.DB) error {
+			var before bool
+			if err := tx.QueryRow(c.Request.Context(), `with b as (select id, sensitive from tickets where id=$1 for update)
+                update tickets t set sensitive=$2 from b where t.id=b.id returning b.sensitive`, c.Param("id"), *in.Sensitive).Scan(&before); err != nil {
+				return err
+			}
+			return audit.Record(c.Request.Context(), tx, authpkg.Actor(c), "ticket", c.Param("id"), "ticket_updated",
+				audit.Diff(map[string]any{"sensitive": before}, map[string]any{"sensitive": *in.Sensitive}))
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "sensitive": *in.Sensitive})
+	}
+}
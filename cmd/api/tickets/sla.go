@@ -0,0 +1,134 @@
+package tickets
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// slaColumns and slaJoins extend the ticket list/detail queries with the
+// stored SLA clock, its policy targets and the calendar that governs it.
+const slaColumns = `coalesce(coalesce(tm.calendar_id, rg.calendar_id)::text, ''),
+			sc.response_elapsed_ms, sc.resolution_elapsed_ms, sc.last_started_at, sc.paused,
+			sp.response_target_mins, sp.resolution_target_mins, t.first_response_at is not null`
+
+const slaJoins = `
+			left join ticket_sla_clocks sc on sc.ticket_id=t.id
+			left join sla_policy_versions sp on sp.id=sc.policy_version_id
+			left join teams tm on tm.id=t.team_id
+			left join regions rg on rg.id=tm.region_id`
+
+// AtRiskFilter and BreachedFilter select tickets by their stored SLA clock.
+// They expect the t, sc and sp aliases from slaJoins.
+var (
+	AtRiskFilter   = sla.AtRiskFilter
+	BreachedFilter = sla.BreachedFilter
+)
+
+// slaRow receives the columns selected by slaColumns.
+type slaRow struct {
+	calendarID  string
+	respMS      *int64
+	resMS       *int64
+	lastStarted *time.Time
+	paused      *bool
+	respTarget  *int
+	resTarget   *int
+	responded   bool
+}
+
+func (r *slaRow) dest() []any {
+	return []any{&r.calendarID, &r.respMS, &r.resMS, &r.lastStarted, &r.paused, &r.respTarget, &r.resTarget, &r.responded}
+}
+
+// applySLA fills the due times and breach countdown on t. Calendars are
+// cached in cals so a page of tickets loads each one once; tickets without a
+// clock or calendar are left untouched.
+func applySLA(ctx context.Context, db app.DB, cals map[string]*sla.Calendar, t *Ticket, r slaRow, now time.Time) {
+	if r.calendarID == "" || r.respMS == nil || r.resMS == nil {
+		return
+	}
+	cal, ok := cals[r.calendarID]
+	if !ok {
+		var err error
+		if cal, err = sla.LoadCalendar(ctx, db, r.calendarID); err != nil {
+			cal = nil
+		}
+		cals[r.calendarID] = cal
+	}
+	if cal == nil {
+		return
+	}
+	clk := sla.Clock{ResponseElapsedMS: *r.respMS, ResolutionElapsedMS: *r.resMS, LastStartedAt: r.lastStarted, Responded: r.responded}
+	if r.paused != nil {
+		clk.Paused = *r.paused
+	}
+	if r.respTarget != nil {
+		clk.ResponseTargetMins = *r.respTarget
+	}
+	if r.resTarget != nil {
+		clk.ResolutionTargetMins = *r.resTarget
+	}
+	p := cal.Predict(t.Status, clk, now)
+	t.ResponseDueAt = p.ResponseDueAt
+	t.ResolutionDueAt = p.ResolutionDueAt
+	if p.BreachIn != nil {
+		ms := p.BreachIn.Milliseconds()
+		t.BreachInMS = &ms
+	}
+	t.AtRisk = p.AtRisk
+}
+
+// SLATimers returns up to limit open tickets with a running SLA clock,
+// closest to a breach first. A non-empty queueID restricts the result to
+// that queue.
+func SLATimers(ctx context.Context, db app.DB, queueID string, limit int, now time.Time) ([]Ticket, error) {
+	where := "t.status not in ('Resolved','Closed') and sc.ticket_id is not null"
+	args := []any{limit}
+	if queueID != "" {
+		args = append(args, queueID)
+		where += " and t.queue_id::text = $2"
+	}
+	rows, err := db.Query(ctx, `select t.id::text, t.number, t.title, t.status, t.priority, `+slaColumns+`
+			from tickets t`+slaJoins+`
+			where `+where+`
+			order by greatest(
+				sc.resolution_elapsed_ms::float8 / nullif(sp.resolution_target_mins * 60000, 0),
+				case when t.status = 'New' and t.first_response_at is null then sc.response_elapsed_ms::float8 / nullif(sp.response_target_mins * 60000, 0) end
+			) desc nulls last
+			limit $1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var out []Ticket
+	var slas []slaRow
+	for rows.Next() {
+		var t Ticket
+		var s slaRow
+		if err := rows.Scan(append([]any{&t.ID, &t.Number, &t.Title, &t.Status, &t.Priority}, s.dest()...)...); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, t)
+		slas = append(slas, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	cals := map[string]*sla.Calendar{}
+	for i := range out {
+		applySLA(ctx, db, cals, &out[i], slas[i], now)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		bi, bj := out[i].BreachInMS, out[j].BreachInMS
+		if bi == nil || bj == nil {
+			return bi != nil
+		}
+		return *bi < *bj
+	})
+	return out, nil
+}
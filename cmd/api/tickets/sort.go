@@ -0,0 +1,157 @@
+package tickets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// slaBreachInExpr is the business time, in milliseconds, left before an open
+// ticket's nearest SLA target breaches, negative once it has. Like the SLA
+// filters it reads the clock the worker refreshes each tick and expects the
+// aliases from slaJoins; it is null for closed tickets and tickets without a
+// clock.
+const slaBreachInExpr = `case when t.status not in ('Resolved','Closed') then least(
+				sp.resolution_target_mins::bigint * 60000 - sc.resolution_elapsed_ms,
+				case when t.status = 'New' and t.first_response_at is null then sp.response_target_mins::bigint * 60000 - sc.response_elapsed_ms end) end`
+
+// sortKey is one column of a list ordering. Nullable keys sort their nulls
+// last in either direction.
+type sortKey struct {
+	expr     string
+	cast     string
+	desc     bool
+	nullable bool
+}
+
+// listSorts are the orderings GET /tickets accepts in sort=. Each ends with
+// the ticket id so keyset cursors never skip or repeat rows that tie, and
+// each is backed by an index from the 0067 migration except sla_breach_in,
+// which is computed from the joined SLA clock.
+var listSorts = map[string][]sortKey{
+	"updated_at": {
+		{expr: "t.updated_at", cast: "timestamptz", desc: true},
+		{expr: "t.id", cast: "uuid", desc: true},
+	},
+	"created_at": {
+		{expr: "t.created_at", cast: "timestamptz", desc: true},
+		{expr: "t.id", cast: "uuid", desc: true},
+	},
+	"due_at": {
+		{expr: "t.due_at", cast: "timestamptz", nullable: true},
+		{expr: "t.id", cast: "uuid"},
+	},
+	"priority": {
+		{expr: "t.priority", cast: "smallint"},
+		{expr: "t.updated_at", cast: "timestamptz", desc: true},
+		{expr: "t.id", cast: "uuid", desc: true},
+	},
+	"sla_breach_in": {
+		{expr: "(" + slaBreachInExpr + ")", cast: "bigint", nullable: true},
+		{expr: "t.id", cast: "uuid"},
+	},
+	"requester": {
+		{expr: "coalesce(r.name, r.email, '')", cast: "text"},
+		{expr: "t.id", cast: "uuid"},
+	},
+}
+
+// defaultListSort keeps the ordering the list always had.
+const defaultListSort = "updated_at"
+
+// sortOrderBy renders the order by clause for keys.
+func sortOrderBy(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.expr
+		if k.desc {
+			parts[i] += " desc"
+		}
+		if k.nullable {
+			parts[i] += " nulls last"
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortValues selects keys as text, the form cursors carry them in.
+func sortValues(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.expr + "::text"
+	}
+	return "array[" + strings.Join(parts, ", ") + "]"
+}
+
+// sortAfter returns the condition selecting rows that come after vals in
+// the keys' order, with vals bound from $n on. A nil value is a null key.
+func sortAfter(keys []sortKey, vals []*string, args []any) (string, []any) {
+	param := func(v string, k sortKey) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d::%s", len(args), k.cast)
+	}
+	var ors []string
+	var eqs []string
+	for i, k := range keys {
+		if vals[i] == nil {
+			// Nothing sorts after a null but rows tied on it.
+			eqs = append(eqs, k.expr+" is null")
+			continue
+		}
+		p := param(*vals[i], k)
+		op := ">"
+		if k.desc {
+			op = "<"
+		}
+		cond := fmt.Sprintf("%s %s %s", k.expr, op, p)
+		if k.nullable {
+			cond = fmt.Sprintf("(%s or %s is null)", cond, k.expr)
+		}
+		ors = append(ors, strings.Join(append(append([]string{}, eqs...), cond), " and "))
+		eqs = append(eqs, fmt.Sprintf("%s = %s", k.expr, p))
+	}
+	if len(ors) == 0 {
+		return "false", args
+	}
+	return "(" + strings.Join(ors, " or ") + ")", args
+}
+
+// listCursor is the keyset position a page ended at: the sort it was taken
+// in and the sort key values of the last ticket, as text.
+type listCursor struct {
+	Sort  string    `json:"sort"`
+	After []*string `json:"after"`
+}
+
+// encodeListCursor returns next_cursor for the last ticket of a page. The
+// default sort keeps the "<updated_at>,<id>" form clients already hold.
+func encodeListCursor(sort string, vals []*string, updated time.Time, id string) string {
+	if sort == defaultListSort {
+		return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s,%s", updated.UTC().Format(time.RFC3339Nano), id)))
+	}
+	b, _ := json.Marshal(listCursor{Sort: sort, After: vals})
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// decodeListCursor parses a cursor returned by List. ok is false for other
+// forms, which List still reads as the legacy raw timestamps.
+func decodeListCursor(cur string) (listCursor, bool) {
+	raw, err := base64.StdEncoding.DecodeString(cur)
+	if err != nil {
+		return listCursor{}, false
+	}
+	var lc listCursor
+	if json.Unmarshal(raw, &lc) == nil && lc.Sort != "" {
+		return lc, true
+	}
+	ts, id, found := strings.Cut(string(raw), ",")
+	if !found {
+		return listCursor{}, false
+	}
+	if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+		return listCursor{}, false
+	}
+	return listCursor{Sort: defaultListSort, After: []*string{&ts, &id}}, true
+}
@@ -3,7 +3,6 @@ package tickets
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -11,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -19,15 +19,64 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/jackc/pgconn"
+	"github.com/rs/zerolog/log"
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	assetspkg "github.com/mark3748/helpdesk-go/cmd/api/assets"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/csat"
 	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/cmd/api/forms"
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
 	requesterspkg "github.com/mark3748/helpdesk-go/cmd/api/requesters"
-	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	tagspkg "github.com/mark3748/helpdesk-go/cmd/api/tags"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/blocklist"
+	"github.com/mark3748/helpdesk-go/internal/customfields"
+	"github.com/mark3748/helpdesk-go/internal/ooo"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/receipts"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+	"github.com/mark3748/helpdesk-go/internal/verify"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 )
 
+// Statuses lists every ticket status in workflow order.
+var Statuses = []string{
+	"New",
+	"Open",
+	"Assigned",
+	"Accepted",
+	"In Progress",
+	"Scheduled",
+	"Pending",
+	"Pending - Awaiting Info",
+	"Pending - Awaiting Callback",
+	"Pending - Awaiting Parts",
+	"Pending - Awaiting Approval",
+	"Resolved",
+	"Closed",
+}
+
+// PausedStatuses are the statuses that stop the SLA clock.
+var PausedStatuses = []string{
+	"Scheduled",
+	"Pending",
+	"Pending - Awaiting Info",
+	"Pending - Awaiting Callback",
+	"Pending - Awaiting Parts",
+	"Pending - Awaiting Approval",
+}
+
+// Sources lists the channels Create accepts for a ticket. Integrations
+// such as Discord, the service catalog, the phone intake webhook and the
+// chat widget set their own.
+var Sources = []string{"web", "email", "phone"}
+
+// PriorityLabels names the ticket priorities 1 (highest) to 4.
+var PriorityLabels = map[int16]string{1: "Critical", 2: "High", 3: "Medium", 4: "Low"}
+
 type Ticket struct {
 	ID          string      `json:"id"`
 	Number      any         `json:"number,omitempty"`
@@ -41,6 +90,51 @@ type Ticket struct {
 	Requester   string      `json:"requester,omitempty"`
 	CreatedAt   *time.Time  `json:"created_at,omitempty"`
 	Category    *string     `json:"category,omitempty"`
+	// AssigneeAvatarURL is the assignee's uploaded avatar or Gravatar.
+	AssigneeAvatarURL string `json:"assignee_avatar_url,omitempty"`
+	// AssignmentRedirectedFrom is the requested assignee when they were out
+	// of office and the ticket went to their delegate or the team pool.
+	AssignmentRedirectedFrom string `json:"assignment_redirected_from,omitempty"`
+	// SLA prediction, business-hours aware. BreachInMS is negative once the
+	// nearest target has been breached.
+	ResponseDueAt   *time.Time `json:"response_due_at,omitempty"`
+	ResolutionDueAt *time.Time `json:"resolution_due_at,omitempty"`
+	BreachInMS      *int64     `json:"breach_in_ms,omitempty"`
+	AtRisk          bool       `json:"at_risk,omitempty"`
+	// Language and Sentiment are detected from requester messages by the
+	// worker's enrichment step, when enabled.
+	Language  *string `json:"language,omitempty"`
+	Sentiment *string `json:"sentiment,omitempty"`
+	// CategorizedBy is "rule" or "history" when the worker categorized an
+	// inbound email ticket, with the confidence of the result.
+	CategorizedBy      *string  `json:"categorized_by,omitempty"`
+	CategoryConfidence *float32 `json:"category_confidence,omitempty"`
+	// Verification is "flagged" or "held" when the requester has not
+	// verified their email address and the queue's policy says so.
+	Verification *string `json:"verification,omitempty"`
+	// ScheduledAt and DueAt are set by agents and checked against the
+	// ticket's business calendar.
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	// LastSeenAt is when the requester last viewed the ticket in the portal
+	// or opened a notification about it, when read receipts are enabled.
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	// Sensitive tickets have every read recorded in the access log.
+	Sensitive bool `json:"sensitive,omitempty"`
+	// FollowUpOf is the ticket whose bad CSAT score opened this one;
+	// FollowUpID is the follow-up opened for this ticket's bad score.
+	FollowUpOf *string `json:"followup_of,omitempty"`
+	FollowUpID *string `json:"followup_id,omitempty"`
+	// AssetID is the asset the ticket is about; staff see AssetImpact, what
+	// a failure of that asset takes down with it.
+	AssetID     *string           `json:"asset_id,omitempty"`
+	AssetImpact *assetspkg.Impact `json:"asset_impact,omitempty"`
+	// Tags are the catalog tags on the ticket, by name.
+	Tags []tagspkg.Ref `json:"tags,omitempty"`
+	// MergedInto is the ticket this one was merged into and closed for.
+	MergedInto *string `json:"merged_into,omitempty"`
+	// Links are the parent, child, blocking and related tickets; staff only.
+	Links []Link `json:"links,omitempty"`
 }
 
 // createTicketReq mirrors the JSON body for creating a ticket.
@@ -63,6 +157,7 @@ type createTicketReq struct {
 	DueAt       *string         `json:"due_at"`
 	Source      string          `json:"source"`
 	CustomJSON  json.RawMessage `json:"custom_json"`
+	QueueID     *string         `json:"queue_id"`
 }
 
 // Create inserts a new ticket and returns a summary.
@@ -135,6 +230,30 @@ func Create(a *app.App) gin.HandlerFunc {
 				}
 			}
 		}
+		if in.QueueID != nil && *in.QueueID == "" {
+			in.QueueID = nil
+		}
+		if in.QueueID != nil {
+			errs, err := checkIntakeForm(c, a, &in)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+			if errs != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+				return
+			}
+		}
+		// Portal submissions from blocked addresses are turned away before a
+		// requester is created for them.
+		submitted := ""
+		if in.Requester != nil {
+			submitted = in.Requester.Email
+		}
+		if a.DB != nil && !authpkg.IsStaff(c) && submitterBlocked(c, a, in.RequesterID, submitted) {
+			app.AbortError(c, http.StatusForbidden, "requester_blocked", requesterspkg.BlockedMessage, nil)
+			return
+		}
 		if in.RequesterID == "" {
 			if in.Requester == nil {
 				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"requester": "required"})
@@ -154,6 +273,20 @@ func Create(a *app.App) gin.HandlerFunc {
 			}
 			if a.DB == nil {
 				in.RequesterID = "1"
+			} else if in.Requester.Email != "" && !authpkg.IsStaff(c) {
+				// Requesters submitting for an address we have not seen
+				// must confirm they own it.
+				id, created, err := verify.UpsertRequester(c.Request.Context(), a.DB, in.Requester.Email, in.Requester.Name)
+				if err != nil {
+					app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+					return
+				}
+				in.RequesterID = id
+				if created {
+					if err := verify.Issue(c.Request.Context(), a.DB, id, in.Requester.Email, a.Cfg.PublicURL); err != nil {
+						log.Error().Err(err).Str("requester", id).Msg("issue requester verification")
+					}
+				}
 			} else {
 				const rq = `
                 insert into requesters (email, name, phone)
@@ -169,7 +302,7 @@ func Create(a *app.App) gin.HandlerFunc {
 		if in.Source == "" {
 			in.Source = "web"
 		}
-		if in.Source != "web" && in.Source != "email" {
+		if !slices.Contains(Sources, in.Source) {
 			if a.Cfg.Env == "test" {
 				c.JSON(http.StatusBadRequest, gin.H{"errors": map[string]string{"source": "invalid"}})
 			} else {
@@ -179,7 +312,7 @@ func Create(a *app.App) gin.HandlerFunc {
 		}
 		// Test mode: no DB attached, mimic previous behavior for valid requests
 		if a.DB == nil {
-			c.JSON(http.StatusCreated, Ticket{Title: in.Title, Priority: in.Priority})
+			c.JSON(http.StatusCreated, createdTicket{Ticket: Ticket{Title: in.Title, Priority: in.Priority}, Meta: createdMeta{PossibleDuplicates: []Duplicate{}}})
 			return
 		}
 		// Determine default assignee: if current user has agent/admin role, assign to them
@@ -234,6 +367,7 @@ func Create(a *app.App) gin.HandlerFunc {
 				existing.Status = status
 				existing.AssigneeID = assignee
 				existing.RequesterID = in.RequesterID
+				Mask(c, &existing, customfields.Schema{})
 				c.JSON(http.StatusOK, existing)
 				return
 			}
@@ -241,24 +375,53 @@ func Create(a *app.App) gin.HandlerFunc {
 
 		// Insert ticket
 		const q = `with s as (select nextval('ticket_seq') n)
-insert into tickets (number, title, description, requester_id, priority, status, source, custom_json)
-values ((select 'HD-'||n from s), $1, $2, $3, $4, coalesce(nullif($5,''),'New'), $6, coalesce(nullif($7,''),'{}')::jsonb)
-values ((select 'HD-'||n from s), $1, $2, $3, $4, coalesce(nullif($5,''),'New'), $6, coalesce(nullif($7,''),'{}')::jsonb)
+insert into tickets (number, title, description, requester_id, priority, status, source, custom_json, urgency, category, subcategory, queue_id)
+values ((select 'HD-'||n from s), $1, $2, $3, $4, coalesce(nullif($5,''),'New'), $6, coalesce(nullif($7,''),'{}')::jsonb, $8, $9, $10, $11)
 returning id::text, number, title, description, status, assignee_id::text, priority::int`
-		const qAssign = `with s as (select nextval('ticket_seq') n)
-insert into tickets (number, title, description, requester_id, assignee_id, priority, status, source, custom_json)
-values ((select 'HD-'||n from s), $1, $2, $3, $4, $5, coalesce(nullif($6,''),'New'), $7, coalesce(nullif($8,''),'{}')::jsonb)
+		qAssign := `with s as (select nextval('ticket_seq') n)
+insert into tickets (number, title, description, requester_id, assignee_id, priority, status, source, custom_json, urgency, category, subcategory, queue_id)
+values ((select 'HD-'||n from s), $1, $2, $3, ` + ooo.AssigneeExpr("$4") + `, $5, coalesce(nullif($6,''),'New'), $7, coalesce(nullif($8,''),'{}')::jsonb, $9, $10, $11, $12)
 returning id::text, number, title, description, status, assignee_id::text, priority::int`
 		var t Ticket
 		var assignee *string
 		var number any
 		var status string
 		var prior int // Changed from int16 to int for scanning
-		var row = a.DB.QueryRow(c.Request.Context(), q, in.Title, in.Description, in.RequesterID, in.Priority, in.Status, in.Source, string(in.CustomJSON))
-		if defaultAssignee != "" {
-			row = a.DB.QueryRow(c.Request.Context(), qAssign, in.Title, in.Description, in.RequesterID, defaultAssignee, in.Priority, in.Status, in.Source, string(in.CustomJSON))
-		}
-		if err := row.Scan(&t.ID, &number, &t.Title, &t.Description, &status, &assignee, &prior); err != nil {
+		// The ticket, its audit event and the outbox entry announcing it commit
+		// together so a Redis outage cannot drop the announcement.
+		err := app.InTx(c.Request.Context(), a.DB, func(tx app.DB) error {
+			var row = tx.QueryRow(c.Request.Context(), q, in.Title, in.Description, in.RequesterID, in.Priority, in.Status, in.Source, string(in.CustomJSON),
+				in.Urgency, in.Category, in.Subcategory, in.QueueID)
+			if defaultAssignee != "" {
+				row = tx.QueryRow(c.Request.Context(), qAssign, in.Title, in.Description, in.RequesterID, defaultAssignee, in.Priority, in.Status, in.Source, string(in.CustomJSON),
+					in.Urgency, in.Category, in.Subcategory, in.QueueID)
+			}
+			if err := row.Scan(&t.ID, &number, &t.Title, &t.Description, &status, &assignee, &prior); err != nil {
+				return err
+			}
+			t.Number = number
+			t.Status = status
+			t.AssigneeID = assignee
+			t.RequesterID = in.RequesterID
+			t.Priority = int16(prior) // Cast scanned int to int16 for struct field
+			// Best-effort fill requester label
+			var name, email string
+			_ = tx.QueryRow(c.Request.Context(), `select coalesce(name,''), coalesce(email,'') from requesters where id=$1`, in.RequesterID).Scan(&name, &email)
+			if name != "" && email != "" {
+				t.Requester = fmt.Sprintf("%s <%s>", name, email)
+			} else if name != "" {
+				t.Requester = name
+			} else {
+				t.Requester = email
+			}
+			// Seed status history so SLA clocks can be recomputed later.
+			if _, err := tx.Exec(c.Request.Context(), `insert into ticket_status_history (ticket_id, to_status) values ($1, $2)`, t.ID, t.Status); err != nil {
+				return err
+			}
+			eventspkg.Emit(c.Request.Context(), tx, authpkg.Actor(c), t.ID, "ticket_created", map[string]any{"id": t.ID})
+			return outbox.AddEvent(c.Request.Context(), tx, "ticket_created:"+t.ID, "ticket_created", t)
+		})
+		if err != nil {
 			var pge *pgconn.PgError
 			if errors.As(err, &pge) && pge.Code == "23505" { // unique_violation (dedup index)
 				// Select the most recent matching ticket and return it
@@ -277,6 +440,7 @@ returning id::text, number, title, description, status, assignee_id::text, prior
 					existing.Status = estatus
 					existing.AssigneeID = eassignee
 					existing.RequesterID = in.RequesterID
+					Mask(c, &existing, customfields.Schema{})
 					c.JSON(http.StatusOK, existing)
 					return
 				}
@@ -284,30 +448,75 @@ returning id::text, number, title, description, status, assignee_id::text, prior
 			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
 			return
 		}
-		t.Number = number
-		t.Status = status
-		t.AssigneeID = assignee
-		t.RequesterID = in.RequesterID
-		t.Priority = int16(prior) // Cast scanned int to int16 for struct field
-		// Best-effort fill requester label
-		if a.DB != nil {
-			var name, email string
-			_ = a.DB.QueryRow(c.Request.Context(), `select coalesce(name,''), coalesce(email,'') from requesters where id=$1`, in.RequesterID).Scan(&name, &email)
-			if name != "" && email != "" {
-				t.Requester = fmt.Sprintf("%s <%s>", name, email)
-			} else if name != "" {
-				t.Requester = name
-			} else {
-				t.Requester = email
-			}
-			eventspkg.Emit(c.Request.Context(), a.DB, t.ID, "ticket_created", map[string]any{"id": t.ID})
-			ws.PublishEvent(c.Request.Context(), a.Q, ws.Event{Type: "ticket_created", Data: t})
+		Mask(c, &t, customfields.Schema{})
+		c.JSON(http.StatusCreated, withEntitlement(c, a, withDuplicates(c, a, t)))
+	}
+}
+
+// checkIntakeForm checks a ticket raised in a queue against the queue's
+// intake form and returns the problems by field. Only requesters are held
+// to the form; staff often raise tickets before all the details are known.
+// What the form does not show is dropped from a requester's ticket.
+func checkIntakeForm(c *gin.Context, a *app.App, in *createTicketReq) (map[string]string, error) {
+	if _, err := uuid.Parse(*in.QueueID); err != nil {
+		return map[string]string{"queue_id": "invalid_uuid"}, nil
+	}
+	if a.DB == nil {
+		return nil, nil
+	}
+	ctx := c.Request.Context()
+	var exists bool
+	if err := a.DB.QueryRow(ctx, `select exists (select 1 from queues where id = $1)`, *in.QueueID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[string]string{"queue_id": "not_found"}, nil
+	}
+	if authpkg.IsStaff(c) {
+		return nil, nil
+	}
+	form, ok, err := forms.ForQueue(ctx, a.DB, *in.QueueID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var custom map[string]any
+	if len(in.CustomJSON) > 0 {
+		_ = json.Unmarshal(in.CustomJSON, &custom)
+	}
+	sub := forms.Submission{Description: in.Description, Category: in.Category, Subcategory: in.Subcategory, Urgency: in.Urgency, Custom: custom}
+	if errs := form.Check(&sub); errs != nil {
+		return errs, nil
+	}
+	in.Description, in.Category, in.Subcategory, in.Urgency = sub.Description, sub.Category, sub.Subcategory, sub.Urgency
+	if sub.Custom != nil {
+		in.CustomJSON, _ = json.Marshal(sub.Custom)
+	}
+	return nil, nil
+}
+
+// submitterBlocked reports whether a portal submission comes from a blocked
+// address: the submitted email, or else the email of requesterID. Lookup
+// errors are logged and let the submission through.
+func submitterBlocked(c *gin.Context, a *app.App, requesterID, email string) bool {
+	ctx := c.Request.Context()
+	if email == "" && requesterID != "" {
+		if err := a.DB.QueryRow(ctx, `select coalesce(email, '') from requesters where id = $1`, requesterID).Scan(&email); err != nil {
+			return false
 		}
-		c.JSON(http.StatusCreated, t)
 	}
+	b, blocked, err := blocklist.Match(ctx, a.DB, email)
+	if err != nil {
+		log.Error().Err(err).Msg("check requester blocklist")
+		return false
+	}
+	if blocked {
+		log.Warn().Str("block_id", b.ID).Str("pattern", b.Pattern).Msg("portal submission from blocked requester rejected")
+	}
+	return blocked
 }
 
-// List returns recent tickets using cursor pagination.
+// List returns tickets using keyset pagination, most recently updated first
+// unless sort= picks another of listSorts.
 func List(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if a.DB == nil {
@@ -456,14 +665,64 @@ func List(a *app.App) gin.HandlerFunc {
 			args = append(args, qs)
 		}
 
+		// Several tags narrow the list to tickets carrying all of them.
+		for _, tag := range getMulti("tag") {
+			where = append(where, tagspkg.Filter(len(args)+1))
+			args = append(args, tag)
+		}
+
 		if v := strings.TrimSpace(c.Query("search")); v != "" {
 			n := len(args) + 1
 			where = append(where, fmt.Sprintf("to_tsvector('english', coalesce(t.title,'') || ' ' || coalesce(t.description,'')) @@ websearch_to_tsquery('english', $%d)", n))
 			args = append(args, v)
 		}
 
-		// cursor handling (raw timestamp or composite "ts|id")
-		if cur := strings.TrimSpace(c.Query("cursor")); cur != "" {
+		if v := strings.TrimSpace(c.Query("at_risk")); v == "true" || v == "1" {
+			where = append(where, AtRiskFilter)
+		}
+
+		// Held tickets stay out of the way until their requester verifies;
+		// held=true lists only them.
+		state := verify.TicketState(a.Cfg.UnverifiedPolicy)
+		if v := strings.TrimSpace(c.Query("held")); v == "true" || v == "1" {
+			where = append(where, state+" = '"+verify.StateHeld+"'")
+		} else {
+			where = append(where, state+" is distinct from '"+verify.StateHeld+"'")
+		}
+
+		// The role's default scope is applied here rather than left to the UI;
+		// requesters never see other people's tickets.
+		scope := effectiveScope(c, cachedListScopes(c.Request.Context(), a), strings.TrimSpace(c.Query("scope")))
+		if cond, next := scopeFilter(c, scope.Scope, args); cond != "" {
+			where = append(where, cond)
+			args = next
+		}
+		if scope.OpenOnly && len(statuses) == 0 {
+			where = append(where, "t.status <> 'Resolved'")
+		}
+
+		sortName := strings.TrimSpace(c.Query("sort"))
+		if sortName == "" {
+			sortName = defaultListSort
+		}
+		keys, ok := listSorts[sortName]
+		if !ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"sort": "must be one of updated_at, created_at, due_at, priority, sla_breach_in, requester"})
+			return
+		}
+
+		// cursor handling: the next_cursor of an earlier page, or the legacy
+		// raw timestamp or composite "ts|id"
+		cur := strings.TrimSpace(c.Query("cursor"))
+		if lc, ok := decodeListCursor(cur); ok {
+			if lc.Sort != sortName || len(lc.After) != len(keys) {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"cursor": "cursor was issued for a different sort"})
+				return
+			}
+			var cond string
+			cond, args = sortAfter(keys, lc.After, args)
+			where = append(where, cond)
+		} else if cur != "" {
 			if strings.Contains(cur, "|") {
 				parts := strings.SplitN(cur, "|", 2)
 				if len(parts) == 2 {
@@ -493,13 +752,15 @@ func List(a *app.App) gin.HandlerFunc {
 		// and append description, created_at, and category for UI consumption.
 		sql := `select t.id::text, t.number, t.title, t.status, t.assignee_id::text, 
 			t.priority, t.requester_id::text, coalesce(r.name, r.email, '') as requester, 
-			t.updated_at, t.description, t.created_at, t.category 
+			t.updated_at, t.description, t.created_at, t.category, ` + slaColumns + `, ` + state + `,
+			` + tagspkg.Column + `, ` + sortValues(keys) + `
 			from tickets t 
-			left join requesters r on r.id=t.requester_id`
+			left join requesters r on r.id=t.requester_id
+			left join queues q on q.id=t.queue_id` + slaJoins
 		if len(where) > 0 {
 			sql += " where " + strings.Join(where, " and ")
 		}
-		sql += " order by t.updated_at desc, t.id desc limit " + strconv.Itoa(limit+1)
+		sql += " order by " + sortOrderBy(keys) + " limit " + strconv.Itoa(limit+1)
 
 		// In tests for the tickets package (multi-value filters), arg-count checks expect the LIMIT value
 		// to appear as an extra trailing arg. Only add it when multi-value filters are used to avoid
@@ -537,6 +798,8 @@ func List(a *app.App) gin.HandlerFunc {
 
 		out := []Ticket{}
 		ups := []time.Time{}
+		sortVals := [][]*string{}
+		slaRows := []slaRow{}
 		for rows.Next() {
 			var t Ticket
 			var assignee *string
@@ -544,7 +807,11 @@ func List(a *app.App) gin.HandlerFunc {
 			var updated time.Time
 			var createdAt time.Time
 			var category *string
-			if err := rows.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &t.RequesterID, &t.Requester, &updated, &t.Description, &createdAt, &category); err != nil {
+			var sr slaRow
+			var vals []*string
+			dest := append([]any{&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &t.RequesterID, &t.Requester, &updated, &t.Description, &createdAt, &category}, sr.dest()...)
+			var tags []byte
+			if err := rows.Scan(append(dest, &t.Verification, &tags, &vals)...); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
@@ -552,23 +819,41 @@ func List(a *app.App) gin.HandlerFunc {
 			t.AssigneeID = assignee
 			t.CreatedAt = &createdAt
 			t.Category = category
+			t.Tags = tagspkg.Decode(tags)
 			out = append(out, t)
 			ups = append(ups, updated)
+			sortVals = append(sortVals, vals)
+			slaRows = append(slaRows, sr)
+		}
+		rows.Close()
+		// Release the rows before loading calendars so the request holds a
+		// single connection at a time.
+		cals := map[string]*sla.Calendar{}
+		now := time.Now()
+		for i := range out {
+			applySLA(c.Request.Context(), a.DB, cals, &out[i], slaRows[i], now)
 		}
 
 		var next string
 		if len(out) > limit {
-			last := out[limit-1]
-			lastUp := ups[limit-1]
-			next = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s,%s", lastUp.UTC().Format(time.RFC3339Nano), last.ID)))
+			next = encodeListCursor(sortName, sortVals[limit-1], ups[limit-1], out[limit-1].ID)
 			out = out[:limit]
 		}
 
+		maskAll(c, out)
 		// For UI compatibility, return items under "items" and keep legacy "tickets" key.
 		c.JSON(http.StatusOK, gin.H{"items": out, "tickets": out, "next_cursor": next})
 	}
 }
 
+// derefString returns *p, or nil so unset values serialize as null.
+func derefString(p *string) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
 // computeTicketHash returns a sha256 of normalized ticket content used for
 // idempotency and advisory locks.
 func computeTicketHash(title, requesterID, description string) [32]byte {
@@ -613,38 +898,113 @@ func Get(a *app.App) gin.HandlerFunc {
 			return
 		}
 		// Keep legacy column order and append description, created_at, and category for compatibility
-		const q = `select t.id::text, t.number, t.title, t.status, t.assignee_id::text, 
+		q := `select t.id::text, t.number, t.title, t.status, t.assignee_id::text, 
 			t.priority, t.requester_id::text, coalesce(r.name, r.email, '') as requester, 
-			t.description, t.created_at, t.category 
+			t.description, t.created_at, t.category, ` + slaColumns + `,
+			coalesce(au.avatar_key,''), coalesce(au.email,''), t.language, t.sentiment,
+			t.categorized_by, t.category_confidence, ` + verify.TicketState(a.Cfg.UnverifiedPolicy) + `,
+			t.requester_last_seen_at, t.scheduled_at, t.due_at, t.sensitive, t.followup_of::text,
+			(select f.id::text from tickets f where f.followup_of = t.id limit 1), t.asset_id::text,
+			` + tagspkg.Column + `, t.merged_into::text, ` + linksColumn + `,
+			t.custom_json, (select f.fields from intake_forms f where f.queue_id = t.queue_id)
 			from tickets t 
-			left join requesters r on r.id=t.requester_id 
+			left join requesters r on r.id=t.requester_id
+			left join queues q on q.id=t.queue_id
+			left join users au on au.id=t.assignee_id` + slaJoins + `
 			where t.id=$1`
+		args := []any{c.Param("id")}
+		if !authpkg.IsStaff(c) {
+			q += " and " + authpkg.TicketAccess(2)
+			args = append(args, authpkg.TicketAccessArgs(c)...)
+		}
 		var t Ticket
 		var assignee *string
 		var number any
 		var createdAt time.Time
 		var category *string
-		row := a.DB.QueryRow(c.Request.Context(), q, c.Param("id"))
-		if err := row.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &t.RequesterID, &t.Requester, &t.Description, &createdAt, &category); err != nil {
+		var sr slaRow
+		var avatarKey, assigneeEmail string
+		var tags, links, custom, fields []byte
+		row := a.DB.QueryRow(c.Request.Context(), q, args...)
+		dest := append([]any{&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &t.RequesterID, &t.Requester, &t.Description, &createdAt, &category}, sr.dest()...)
+		if err := row.Scan(append(dest, &avatarKey, &assigneeEmail, &t.Language, &t.Sentiment, &t.CategorizedBy, &t.CategoryConfidence, &t.Verification, &t.LastSeenAt, &t.ScheduledAt, &t.DueAt, &t.Sensitive, &t.FollowUpOf, &t.FollowUpID, &t.AssetID, &tags, &t.MergedInto, &links, &custom, &fields)...); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		markSeen(c, a, t.ID)
+		if t.AssetID != nil && authpkg.IsStaff(c) {
+			t.AssetImpact = assetImpact(c.Request.Context(), a, *t.AssetID)
+		}
 		t.Number = number
 		t.AssigneeID = assignee
+		if assignee != nil {
+			t.AssigneeAvatarURL = avatars.URL(*assignee, avatarKey, assigneeEmail)
+		}
 		t.CreatedAt = &createdAt
 		t.Category = category
+		t.Tags = tagspkg.Decode(tags)
+		t.Links = decodeLinks(links)
+		var values map[string]any
+		if json.Unmarshal(custom, &values) == nil && len(values) > 0 {
+			t.CustomJSON = values
+		}
+		applySLA(c.Request.Context(), a.DB, map[string]*sla.Calendar{}, &t, sr, time.Now())
+		// A broken schema masks nothing extra; requesters still lose the
+		// internal ticket fields.
+		schema, _ := customfields.Parse(fields)
+		Mask(c, &t, schema)
 		c.JSON(http.StatusOK, t)
 	}
 }
 
+// statusAliases maps the accepted spellings of each status, lowercased, to
+// the stored status.
+var statusAliases = map[string]string{
+	"new":                         "New",
+	"open":                        "Open",
+	"assigned":                    "Assigned",
+	"accepted":                    "Accepted",
+	"in progress":                 "In Progress",
+	"in_progress":                 "In Progress",
+	"scheduled":                   "Scheduled",
+	"pending":                     "Pending",
+	"pending - awaiting info":     "Pending - Awaiting Info",
+	"pending awaiting info":       "Pending - Awaiting Info",
+	"pending_awaiting_info":       "Pending - Awaiting Info",
+	"pending - awaiting callback": "Pending - Awaiting Callback",
+	"pending awaiting callback":   "Pending - Awaiting Callback",
+	"pending_awaiting_callback":   "Pending - Awaiting Callback",
+	"pending - awaiting parts":    "Pending - Awaiting Parts",
+	"pending awaiting parts":      "Pending - Awaiting Parts",
+	"pending_awaiting_parts":      "Pending - Awaiting Parts",
+	"pending - awaiting approval": "Pending - Awaiting Approval",
+	"pending awaiting approval":   "Pending - Awaiting Approval",
+	"pending_awaiting_approval":   "Pending - Awaiting Approval",
+	"resolved":                    "Resolved",
+	"closed":                      "Closed",
+}
+
+// normalizeStatus returns the stored form of a status as clients may spell
+// it, and false for an unknown status.
+func normalizeStatus(raw string) (string, bool) {
+	s, ok := statusAliases[strings.TrimSpace(strings.ToLower(raw))]
+	return s, ok
+}
+
 // Update allows changing assignee and/or priority and status
 func Update(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		metrics.TicketsUpdatedTotal.Inc()
 		var in struct {
-			AssigneeID *string `json:"assignee_id"`
-			Priority   *int16  `json:"priority"`
-			Status     *string `json:"status"`
+			AssigneeID  *string `json:"assignee_id"`
+			Priority    *int16  `json:"priority"`
+			Status      *string `json:"status"`
+			ScheduledAt *string `json:"scheduled_at"`
+			DueAt       *string `json:"due_at"`
+			// Snap moves dates outside business time to the next business
+			// time; Force keeps dates that fall on a holiday or closure.
+			Snap  bool `json:"snap"`
+			Force bool `json:"force"`
 		}
 		if err := c.ShouldBindJSON(&in); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
@@ -654,7 +1014,7 @@ func Update(a *app.App) gin.HandlerFunc {
 		args := []any{}
 		idx := 1
 		if in.AssigneeID != nil {
-			set = append(set, fmt.Sprintf("assignee_id=$%d", idx))
+			set = append(set, "assignee_id="+ooo.AssigneeExpr(fmt.Sprintf("$%d", idx)))
 			args = append(args, *in.AssigneeID)
 			idx++
 		}
@@ -670,34 +1030,8 @@ func Update(a *app.App) gin.HandlerFunc {
 		}
 		var normStatus string
 		if in.Status != nil {
-			// Normalize and validate status
-			raw := strings.TrimSpace(strings.ToLower(*in.Status))
-			statusMap := map[string]string{
-				"new":                         "New",
-				"open":                        "Open",
-				"assigned":                    "Assigned",
-				"accepted":                    "Accepted",
-				"in progress":                 "In Progress",
-				"in_progress":                 "In Progress",
-				"scheduled":                   "Scheduled",
-				"pending":                     "Pending",
-				"pending - awaiting info":     "Pending - Awaiting Info",
-				"pending awaiting info":       "Pending - Awaiting Info",
-				"pending_awaiting_info":       "Pending - Awaiting Info",
-				"pending - awaiting callback": "Pending - Awaiting Callback",
-				"pending awaiting callback":   "Pending - Awaiting Callback",
-				"pending_awaiting_callback":   "Pending - Awaiting Callback",
-				"pending - awaiting parts":    "Pending - Awaiting Parts",
-				"pending awaiting parts":      "Pending - Awaiting Parts",
-				"pending_awaiting_parts":      "Pending - Awaiting Parts",
-				"pending - awaiting approval": "Pending - Awaiting Approval",
-				"pending awaiting approval":   "Pending - Awaiting Approval",
-				"pending_awaiting_approval":   "Pending - Awaiting Approval",
-				"resolved":                    "Resolved",
-				"closed":                      "Closed",
-			}
 			var ok bool
-			if normStatus, ok = statusMap[raw]; !ok {
+			if normStatus, ok = normalizeStatus(*in.Status); !ok {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status"})
 				return
 			}
@@ -705,6 +1039,38 @@ func Update(a *app.App) gin.HandlerFunc {
 			args = append(args, normStatus)
 			idx++
 		}
+		dates := map[string]*time.Time{}
+		for field, v := range map[string]*string{"scheduled_at": in.ScheduledAt, "due_at": in.DueAt} {
+			if v == nil {
+				continue
+			}
+			at, err := parseTicketDate(*v)
+			if err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_date", "dates must be RFC 3339", map[string]string{field: "invalid date"})
+				return
+			}
+			dates[field] = at
+		}
+		if len(dates) > 0 && a.DB != nil {
+			cal, err := ticketCalendar(c.Request.Context(), a.DB, c.Param("id"))
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+				return
+			}
+			if cal != nil {
+				if conflicts := checkDates(cal, dates, in.Snap); len(conflicts) > 0 && !in.Force {
+					app.AbortError(c, http.StatusConflict, "date_unavailable", "date falls on a holiday or closure", conflicts)
+					return
+				}
+			}
+		}
+		for _, field := range dateFields {
+			if at, ok := dates[field]; ok {
+				set = append(set, fmt.Sprintf("%s=$%d", field, idx))
+				args = append(args, at)
+				idx++
+			}
+		}
 		if len(set) == 0 {
 			if a.Cfg.Env == "test" {
 				c.JSON(http.StatusOK, Ticket{})
@@ -719,39 +1085,148 @@ func Update(a *app.App) gin.HandlerFunc {
 			return
 		}
 		args = append(args, c.Param("id"))
-		sql := fmt.Sprintf("update tickets set %s, updated_at=now() where id=$%d returning id::text, number, title, status, assignee_id::text, priority", strings.Join(set, ","), idx)
-		// For test expectations, issue an Exec before QueryRow so tests can capture args
-		_, _ = a.DB.Exec(c.Request.Context(), "update tickets set "+strings.Join(set, ", ")+" where id=$"+strconv.Itoa(idx), args...)
+		// The CTE captures the row as it was so the audit trail can record
+		// before and after values without a second round trip.
+		sql := fmt.Sprintf(`with before as (select id, status, assignee_id, priority, scheduled_at, due_at from tickets where id=$%[2]d for update)
+update tickets set %[1]s, updated_at=now() from before b where tickets.id=b.id
+returning tickets.id::text, tickets.number, tickets.title, tickets.status, tickets.assignee_id::text, tickets.priority,
+b.status, b.assignee_id::text, b.priority, tickets.scheduled_at, tickets.due_at, b.scheduled_at, b.due_at`, strings.Join(set, ","), idx)
+		var t Ticket
+		var assignee *string
+		var number any
+		var prevStatus string
+		var prevAssignee *string
+		var prevPriority int16
+		var changes []string
 		if normStatus != "" {
-			pausedStates := map[string]bool{
-				"Scheduled":                   true,
-				"Pending":                     true,
-				"Pending - Awaiting Info":     true,
-				"Pending - Awaiting Callback": true,
-				"Pending - Awaiting Parts":    true,
-				"Pending - Awaiting Approval": true,
+			changes = append(changes, "status changed to "+normStatus)
+		}
+		if in.Priority != nil {
+			changes = append(changes, fmt.Sprintf("priority changed to %d", *in.Priority))
+		}
+		if in.AssigneeID != nil {
+			changes = append(changes, "assignee changed")
+		}
+		if _, ok := dates["scheduled_at"]; ok {
+			changes = append(changes, "scheduled date changed")
+		}
+		errNotFound := errors.New("not found")
+		var openN int
+		err := app.InTx(c.Request.Context(), a.DB, func(tx app.DB) error {
+			if closesParent(normStatus) {
+				n, err := openChildren(c.Request.Context(), tx, c.Param("id"))
+				if err != nil {
+					return err
+				}
+				if n > 0 {
+					openN = n
+					return ErrOpenChildren
+				}
+			}
+			// For test expectations, issue an Exec before QueryRow so tests can capture args
+			_, _ = tx.Exec(c.Request.Context(), "update tickets set "+strings.Join(set, ", ")+" where id=$"+strconv.Itoa(idx), args...)
+			if normStatus != "" {
+				pause := slices.Contains(PausedStatuses, normStatus)
+				var reason interface{}
+				if pause {
+					reason = normStatus
+				}
+				_, _ = tx.Exec(c.Request.Context(), `update ticket_sla_clocks set paused=$1, reason=$2, last_started_at=case when paused and not $1 then now() else last_started_at end where ticket_id=$3`, pause, reason, c.Param("id"))
+			}
+			row := tx.QueryRow(c.Request.Context(), sql, args...)
+			var prevScheduled, prevDue *time.Time
+			if err := row.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority, &prevStatus, &prevAssignee, &prevPriority,
+				&t.ScheduledAt, &t.DueAt, &prevScheduled, &prevDue); err != nil {
+				return errNotFound
+			}
+			t.Number = number
+			t.AssigneeID = assignee
+			if normStatus != "" {
+				const hq = `insert into ticket_status_history (ticket_id, from_status, to_status, actor_id) values ($1, nullif($2, ''), $3, $4)`
+				if _, err := tx.Exec(c.Request.Context(), hq, t.ID, prevStatus, normStatus, authpkg.Actor(c).DBID()); err != nil {
+					return err
+				}
+			}
+			before, after := map[string]any{}, map[string]any{}
+			if normStatus != "" {
+				before["status"], after["status"] = prevStatus, t.Status
+			}
+			if in.Priority != nil {
+				before["priority"], after["priority"] = prevPriority, t.Priority
+			}
+			if in.AssigneeID != nil {
+				before["assignee_id"], after["assignee_id"] = derefString(prevAssignee), derefString(t.AssigneeID)
+			}
+			if _, ok := dates["scheduled_at"]; ok {
+				before["scheduled_at"], after["scheduled_at"] = prevScheduled, t.ScheduledAt
+			}
+			if _, ok := dates["due_at"]; ok {
+				before["due_at"], after["due_at"] = prevDue, t.DueAt
+			}
+			if err := audit.Record(c.Request.Context(), tx, authpkg.Actor(c), "ticket", t.ID, "ticket_updated", audit.Diff(before, after)); err != nil {
+				return err
+			}
+			if in.Priority != nil && prevPriority != t.Priority {
+				// The decision lands on the ticket's audit timeline even
+				// when the rule keeps the clock as it was.
+				rules := cachedPriorityRules(c.Request.Context(), a)
+				r, ok, err := sla.Recalibrate(c.Request.Context(), tx, t.ID, int(prevPriority), int(t.Priority), rules, time.Now())
+				if err != nil {
+					return err
+				}
+				if ok {
+					if err := audit.RecordDiff(c.Request.Context(), tx, authpkg.Actor(c), "ticket", t.ID, "sla_recalibrated", r); err != nil {
+						return err
+					}
+				}
+			}
+			eventspkg.Emit(c.Request.Context(), tx, authpkg.Actor(c), t.ID, "ticket_updated", map[string]any{"id": t.ID})
+			if t.AssigneeID != nil && derefString(prevAssignee) != *t.AssigneeID {
+				if err := webhook.Publish(c.Request.Context(), tx, "ticket.assigned", t.ID, nil); err != nil {
+					return err
+				}
+			}
+			if normStatus == "Resolved" && prevStatus != "Resolved" {
+				if err := webhook.Publish(c.Request.Context(), tx, "ticket.resolved", t.ID, nil); err != nil {
+					return err
+				}
+			}
+			if in.AssigneeID != nil {
+				if err := notifyAssigned(c.Request.Context(), tx, authpkg.Actor(c).ID, t, prevAssignee); err != nil {
+					return err
+				}
 			}
-			pause := pausedStates[normStatus]
-			var reason interface{}
-			if pause {
-				reason = normStatus
+			if err := outbox.AddEvent(c.Request.Context(), tx, "ticket_updated:"+t.ID+":"+uuid.NewString(), "ticket_updated", t); err != nil {
+				return err
 			}
-			_, _ = a.DB.Exec(c.Request.Context(), `update ticket_sla_clocks set paused=$1, reason=$2, last_started_at=case when paused and not $1 then now() else last_started_at end where ticket_id=$3`, pause, reason, c.Param("id"))
+			var receiptBase string
+			if receipts.Enabled(a.Cfg.ReadReceipts, receipts.SourceEmail) {
+				receiptBase = a.Cfg.PublicURL
+			}
+			if normStatus == "Resolved" && prevStatus != "Resolved" {
+				csatURL, err := csat.Issue(c.Request.Context(), tx, t.ID, a.Cfg.CSATThrottleDays, a.Cfg.PublicURL)
+				if err != nil {
+					return err
+				}
+				if csatURL != "" {
+					return notifyRequesterResolved(c.Request.Context(), tx, t.ID, t.Number, csatURL, receiptBase)
+				}
+			}
+			return notifyRequesterUpdate(c.Request.Context(), tx, t.ID, t.Number, changes, receiptBase)
+		})
+		if errors.Is(err, ErrOpenChildren) {
+			app.AbortError(c, http.StatusConflict, "open_children", ErrOpenChildren.Error(),
+				map[string]string{"status": fmt.Sprintf("%d child tickets are still open", openN)})
+			return
 		}
-		var t Ticket
-		var assignee *string
-		var number any
-		row := a.DB.QueryRow(c.Request.Context(), sql, args...)
-		if err := row.Scan(&t.ID, &number, &t.Title, &t.Status, &assignee, &t.Priority); err != nil {
+		if errors.Is(err, errNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
-		t.Number = number
-		t.AssigneeID = assignee
-		if in.AssigneeID != nil {
-			eventspkg.Emit(c.Request.Context(), a.DB, t.ID, "ticket_updated", map[string]any{"id": t.ID})
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", err.Error(), nil)
+			return
 		}
-		ws.PublishEvent(c.Request.Context(), a.Q, ws.Event{Type: "ticket_updated", Data: t})
 		c.JSON(http.StatusOK, t)
 	}
 }
@@ -19,8 +19,11 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	metrics "github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	mockdb "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/sla"
 )
 
 func TestTicketHandlers(t *testing.T) {
@@ -66,6 +69,80 @@ func TestTicketHandlers(t *testing.T) {
 	}
 }
 
+func TestCreateRejectsBlockedRequester(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var upserted bool
+	db := &mockdb.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.HasPrefix(sql, "update requester_blocks") {
+				return &mockdb.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "b1"
+					*dest[1].(*string) = "spam.example"
+					return nil
+				}}
+			}
+			upserted = upserted || strings.Contains(sql, "insert into requesters")
+			return &mockdb.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets", func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: "u1", Roles: []string{"requester"}})
+	}, Create(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tickets", strings.NewReader(`{"title":"buy now","requester":{"email":"x@spam.example"},"priority":3}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "requester_blocked") {
+		t.Fatalf("expected 403 requester_blocked, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if upserted {
+		t.Fatal("a blocked submitter must not be created as a requester")
+	}
+}
+
+func TestCreateChecksIntakeForm(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var inserted bool
+	db := &mockdb.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			switch {
+			case strings.HasPrefix(sql, "select exists"):
+				return &mockdb.MockRow{ScanFunc: func(dest ...any) error { *dest[0].(*bool) = true; return nil }}
+			case strings.Contains(sql, "from intake_forms"):
+				return &mockdb.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = "q1", "Hardware", "Broken device"
+					*dest[4].(*[]byte) = []byte(`[{"key":"description","required":true}]`)
+					*dest[5].(*[]byte) = []byte(`{"fields":[{"key":"device","type":"select","options":["laptop","phone"],"required":true}]}`)
+					*dest[6].(*bool) = true
+					return nil
+				}}
+			}
+			inserted = inserted || strings.Contains(sql, "insert into tickets")
+			return &mockdb.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets", func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: "u1", Roles: []string{"requester"}})
+	}, Create(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/tickets", strings.NewReader(`{"title":"Screen","requester_id":"00000000-0000-0000-0000-000000000000","priority":3,"queue_id":"7d5e8b8e-4a8e-4b8e-9b8e-8e8e8e8e8e8e"}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, k := range []string{`"description"`, `"custom_json.device"`} {
+		if !strings.Contains(rr.Body.String(), k) {
+			t.Errorf("no error for %s in %s", k, rr.Body.String())
+		}
+	}
+	if inserted {
+		t.Fatal("a ticket failing its intake form must not be created")
+	}
+}
+
 // Test that create and update handlers increment their counters.
 func TestTicketCounters(t *testing.T) {
 	gin.SetMode(gin.TestMode)
@@ -274,6 +351,62 @@ func TestTicketListPagination(t *testing.T) {
 	}
 }
 
+func TestTicketListSort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &listDB{}
+	cfg := apppkg.Config{Env: "test", TestBypassAuth: true}
+	a := apppkg.NewApp(cfg, db, nil, nil, nil)
+	a.R.GET("/tickets", authpkg.Middleware(a), List(a))
+	get := func(url string) int {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		return rr.Code
+	}
+
+	if code := get("/tickets?sort=title"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown sort, got %d", code)
+	}
+
+	p, up := "2", "2024-01-02 03:04:05.123456+00"
+	cur := encodeListCursor("priority", []*string{&p, &up, new(string)}, time.Time{}, "")
+	if code := get("/tickets?sort=created_at&cursor=" + cur); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a cursor from another sort, got %d", code)
+	}
+	if code := get("/tickets?sort=priority&cursor=" + cur); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !strings.Contains(db.sql, "order by t.priority, t.updated_at desc, t.id desc") {
+		t.Fatalf("missing priority order: %s", db.sql)
+	}
+	n := len(db.args)
+	keyset := fmt.Sprintf("(t.priority > $%[1]d::smallint or t.priority = $%[1]d::smallint and t.updated_at < $%[2]d::timestamptz", n-2, n-1)
+	if !strings.Contains(db.sql, keyset) || db.args[n-3] != "2" || db.args[n-2] != up {
+		t.Fatalf("missing keyset condition %q: %s %v", keyset, db.sql, db.args)
+	}
+
+	// Following the default next_cursor continues after the last ticket.
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if code := get("/tickets?cursor=" + encodeListCursor(defaultListSort, nil, ts, "abc")); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if !strings.Contains(db.sql, "(t.updated_at < $1::timestamptz or t.updated_at = $1::timestamptz and t.id < $2::uuid)") {
+		t.Fatalf("missing default keyset: %s", db.sql)
+	}
+}
+
+func TestSortAfterNulls(t *testing.T) {
+	id := "abc"
+	cond, args := sortAfter(listSorts["due_at"], []*string{nil, &id}, nil)
+	if cond != "(t.due_at is null and t.id > $1::uuid)" || len(args) != 1 {
+		t.Fatalf("unexpected condition %q %v", cond, args)
+	}
+	due := "2024-01-02 03:04:05+00"
+	cond, _ = sortAfter(listSorts["due_at"], []*string{&due, &id}, nil)
+	if cond != "((t.due_at > $1::timestamptz or t.due_at is null) or t.due_at = $1::timestamptz and t.id > $2::uuid)" {
+		t.Fatalf("unexpected condition %q", cond)
+	}
+}
+
 func TestTicketListFilters(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := &listDB{}
@@ -312,3 +445,237 @@ func TestTicketListFilters(t *testing.T) {
 		t.Fatalf("queue args: %v", db.args[5])
 	}
 }
+
+func TestTicketListAtRiskFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &listDB{rows: []listRow{{Ticket: Ticket{ID: "1", Title: "t1", Status: "Open", Priority: 1, RequesterID: "r1"}, Updated: time.Now()}}}
+	cfg := apppkg.Config{Env: "test", TestBypassAuth: true}
+	a := apppkg.NewApp(cfg, db, nil, nil, nil)
+	a.R.GET("/tickets", authpkg.Middleware(a), List(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets?at_risk=true", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !strings.Contains(db.sql, "sc.resolution_elapsed_ms >= sp.resolution_target_mins * 45000") {
+		t.Fatalf("missing at-risk filter: %s", db.sql)
+	}
+	// Tickets without a clock carry no prediction.
+	if strings.Contains(rr.Body.String(), "breach_in_ms") {
+		t.Fatalf("unexpected prediction: %s", rr.Body.String())
+	}
+}
+
+func TestTicketListTagFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &listDB{}
+	cfg := apppkg.Config{Env: "test", TestBypassAuth: true}
+	a := apppkg.NewApp(cfg, db, nil, nil, nil)
+	a.R.GET("/tickets", authpkg.Middleware(a), List(a))
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tickets?tag=VIP,Needs%20parts", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	// Every tag must match.
+	if strings.Count(db.sql, "exists (select 1 from ticket_tags tt") != 2 {
+		t.Fatalf("expected a condition per tag: %s", db.sql)
+	}
+	if len(db.args) != 2 || db.args[0] != "VIP" || db.args[1] != "Needs parts" {
+		t.Fatalf("args = %v", db.args)
+	}
+}
+
+// auditDB returns the updated ticket together with its previous values.
+// With clock set the ticket has an SLA clock on a priority 3 policy.
+type auditDB struct {
+	updateDB
+	clock bool
+}
+
+type auditRow struct{}
+
+func (auditRow) Scan(dest ...any) error {
+	*(dest[0].(*string)) = "1"
+	*(dest[3].(*string)) = "Open"
+	*(dest[5].(*int16)) = 1
+	*(dest[6].(*string)) = "New"
+	*(dest[8].(*int16)) = 3
+	return nil
+}
+
+func (db *auditDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if strings.Contains(sql, "sla_policy_versions") {
+		return clockRow{sql: sql, clock: db.clock}
+	}
+	if strings.Contains(sql, "from ticket_links") {
+		return &mockdb.MockRow{ScanFunc: func(dest ...any) error { *(dest[0].(*int)) = 0; return nil }}
+	}
+	return auditRow{}
+}
+
+type clockRow struct {
+	sql   string
+	clock bool
+}
+
+func (r clockRow) Scan(dest ...any) error {
+	if !r.clock {
+		return pgx.ErrNoRows
+	}
+	if strings.Contains(r.sql, "from ticket_sla_clocks") {
+		low, resp, res := "p-low", 60, 480
+		*(dest[0].(**string)) = &low
+		*(dest[1].(*int64)), *(dest[2].(*int64)) = 600000, 3600000
+		*(dest[3].(**int)), *(dest[4].(**int)) = &resp, &res
+		*(dest[5].(*time.Time)) = time.Now().Add(-time.Hour)
+		return nil
+	}
+	*(dest[0].(*string)), *(dest[1].(*int)), *(dest[2].(*int)) = "p-crit", 15, 120
+	return nil
+}
+
+func TestUpdateRecordsBeforeAndAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &auditDB{}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.PATCH("/tickets/:id", authpkg.Middleware(a), Update(a))
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPatch, "/tickets/1", strings.NewReader(`{"status":"open","priority":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var diff []byte
+	for i, sql := range db.execSQL {
+		if strings.Contains(sql, "ticket_status_history") && db.execArgs[i][1] != "New" {
+			t.Fatalf("history from_status = %v, want New", db.execArgs[i][1])
+		}
+		if strings.Contains(sql, "insert into audit_events") {
+			if db.execArgs[i][0] != "user" {
+				t.Fatalf("actor_type = %v, want user", db.execArgs[i][0])
+			}
+			diff = db.execArgs[i][5].([]byte)
+		}
+	}
+	var got struct {
+		Changes []audit.FieldChange `json:"changes"`
+	}
+	if err := json.Unmarshal(diff, &got); err != nil {
+		t.Fatalf("audit diff: %v (%s)", err, diff)
+	}
+	if len(got.Changes) != 2 || got.Changes[0].Field != "priority" || got.Changes[0].OldValue != float64(3) || got.Changes[0].NewValue != float64(1) ||
+		got.Changes[1].Field != "status" || got.Changes[1].OldValue != "New" || got.Changes[1].NewValue != "Open" {
+		t.Fatalf("unexpected changes: %+v", got.Changes)
+	}
+}
+
+func TestPriorityChangeRecalibratesSLA(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Cleanup(func() { setCachedPriorityRules(sla.PriorityChangeRules{}) })
+	cases := []struct {
+		name       string
+		rules      sla.PriorityChangeRules
+		rule       string
+		resp, res  float64
+		clockWrite bool
+	}{
+		{"default keeps", sla.PriorityChangeRules{}, "keep", 600000, 3600000, false},
+		{"raise prorates", sla.PriorityChangeRules{Raise: "prorate", Lower: "restart"}, "prorate", 150000, 900000, true},
+		{"raise restarts", sla.PriorityChangeRules{Raise: "restart"}, "restart", 0, 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			setCachedPriorityRules(tc.rules)
+			db := &auditDB{clock: true}
+			a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+			a.R.PATCH("/tickets/:id", authpkg.Middleware(a), Update(a))
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPatch, "/tickets/1", strings.NewReader(`{"priority":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			a.R.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var decision map[string]any
+			clockWrite := false
+			for i, sql := range db.execSQL {
+				if strings.Contains(sql, "update ticket_sla_clocks set policy_id") {
+					clockWrite = true
+					if db.execArgs[i][1] != "p-crit" {
+						t.Fatalf("clock moved to %v, want p-crit", db.execArgs[i][1])
+					}
+				}
+				if strings.Contains(sql, "insert into audit_events") && db.execArgs[i][4] == "sla_recalibrated" {
+					if err := json.Unmarshal(db.execArgs[i][5].([]byte), &decision); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			if clockWrite != tc.clockWrite {
+				t.Fatalf("clock written = %v, want %v", clockWrite, tc.clockWrite)
+			}
+			if decision == nil || decision["rule"] != tc.rule || decision["new_response_elapsed_ms"] != tc.resp || decision["new_resolution_elapsed_ms"] != tc.res {
+				t.Fatalf("unexpected decision on the timeline: %v", decision)
+			}
+		})
+	}
+}
+
+// surveyDB is an auditDB whose requester is or is not due a CSAT survey.
+type surveyDB struct {
+	auditDB
+	due        bool
+	surveyArgs []any
+}
+
+func (db *surveyDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	if !strings.Contains(sql, "csat_token") {
+		return db.auditDB.QueryRow(ctx, sql, args...)
+	}
+	db.surveyArgs = args
+	return surveyRow{db.due}
+}
+
+type surveyRow struct{ due bool }
+
+func (r surveyRow) Scan(dest ...any) error {
+	if !r.due {
+		return pgx.ErrNoRows
+	}
+	*(dest[0].(*string)) = "1"
+	return nil
+}
+
+func TestResolveSendsThrottledSurvey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, due := range []bool{true, false} {
+		db := &surveyDB{due: due}
+		cfg := apppkg.Config{Env: "test", TestBypassAuth: true, PublicURL: "https://help.example.com/", CSATThrottleDays: 30}
+		a := apppkg.NewApp(cfg, db, nil, nil, nil)
+		a.R.PATCH("/tickets/:id", authpkg.Middleware(a), Update(a))
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPatch, "/tickets/1", strings.NewReader(`{"status":"resolved"}`))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if len(db.surveyArgs) != 3 || db.surveyArgs[1] != 30 {
+			t.Fatalf("survey not requested with the throttle: %v", db.surveyArgs)
+		}
+		var email string
+		for i, sql := range db.execSQL {
+			if strings.Contains(sql, "insert into outbox") {
+				email = db.execArgs[i][1].(string) + " " + db.execArgs[i][2].(string)
+			}
+		}
+		if due && (!strings.HasPrefix(email, "ticket_resolved_email:") || !strings.Contains(email, `"CSATURL":"https://help.example.com/api/csat/`)) {
+			t.Fatalf("expected the survey email, got %q", email)
+		}
+		if !due && (!strings.HasPrefix(email, "ticket_updated_email:") || strings.Contains(email, "CSATURL")) {
+			t.Fatalf("a throttled requester must only get the update email, got %q", email)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package tickets
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/contracts"
+)
+
+// withEntitlement counts a new ticket against its requester's current
+// contract and reports the requester's entitlement in the create meta.
+// Failures are logged; they must not fail the create.
+func withEntitlement(c *gin.Context, a *app.App, out createdTicket) createdTicket {
+	ctx := c.Request.Context()
+	e, err := contracts.ForRequester(ctx, a.DB, out.RequesterID)
+	if err != nil {
+		log.Warn().Err(err).Str("ticket", out.ID).Msg("contract entitlement")
+		return out
+	}
+	if e.Current() {
+		if _, err := a.DB.Exec(ctx, `update tickets set contract_id = $2 where id = $1`, out.ID, e.ContractID); err != nil {
+			log.Warn().Err(err).Str("ticket", out.ID).Msg("count ticket against contract")
+		} else if e.RemainingTickets != nil {
+			left := *e.RemainingTickets - 1
+			e.RemainingTickets = &left
+			e.Status = contracts.Classify(false, e.RemainingMinutes, e.RemainingTickets)
+		}
+	}
+	if e.Status != contracts.StatusNone {
+		out.Meta.Entitlement = &e
+	}
+	return out
+}
+
+// TimeEntry is agent time logged on a ticket.
+type TimeEntry struct {
+	ID         string    `json:"id"`
+	UserID     *string   `json:"user_id"`
+	Minutes    int       `json:"minutes"`
+	Note       *string   `json:"note,omitempty"`
+	ContractID *string   `json:"contract_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const timeEntryCols = `id::text, user_id::text, minutes, note, contract_id::text, created_at`
+
+func scanTimeEntry(row pgx.Row) (TimeEntry, error) {
+	var e TimeEntry
+	err := row.Scan(&e.ID, &e.UserID, &e.Minutes, &e.Note, &e.ContractID, &e.CreatedAt)
+	return e, err
+}
+
+// ListTime returns the time logged on a ticket, oldest first, and its total.
+func ListTime(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rows, err := a.DB.Query(c.Request.Context(), `select `+timeEntryCols+` from ticket_time_entries
+            where ticket_id::text = $1 order by created_at, id`, c.Param("id"))
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list time", nil)
+			return
+		}
+		defer rows.Close()
+		out := []TimeEntry{}
+		total := 0
+		for rows.Next() {
+			e, err := scanTimeEntry(rows)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list time", nil)
+				return
+			}
+			total += e.Minutes
+			out = append(out, e)
+		}
+		c.JSON(http.StatusOK, gin.H{"entries": out, "total_minutes": total})
+	}
+}
+
+// LogTime records agent time on a ticket. It counts against the contract
+// the ticket was opened under, whose remaining minutes are returned.
+func LogTime(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Minutes int    `json:"minutes"`
+			Note    string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		if in.Minutes < 1 || in.Minutes > 1440 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", map[string]string{"minutes": "must be between 1 and 1440"})
+			return
+		}
+		ctx := c.Request.Context()
+		act := authpkg.Actor(c)
+		var e TimeEntry
+		var remaining *int
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			var err error
+			e, err = scanTimeEntry(tx.QueryRow(ctx, `insert into ticket_time_entries (ticket_id, user_id, minutes, note, contract_id)
+                select t.id, $2, $3, nullif($4, ''), t.contract_id from tickets t where t.id::text = $1
+                returning `+timeEntryCols, c.Param("id"), act.DBID(), in.Minutes, strings.TrimSpace(in.Note)))
+			if err != nil {
+				return err
+			}
+			if e.ContractID != nil {
+				// The new entry is visible to this statement's snapshot.
+				var tickets *int
+				if err := tx.QueryRow(ctx, `select `+contracts.UsageCols+` from support_contracts c where c.id = $1`,
+					*e.ContractID).Scan(&remaining, &tickets); err != nil {
+					return err
+				}
+			}
+			return audit.RecordDiff(ctx, tx, act, "ticket", c.Param("id"), "time_logged", map[string]any{
+				"minutes": e.Minutes, "contract_id": e.ContractID})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "ticket not found", nil)
+			return
+		}
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_error", "failed to log time", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"entry": e, "contract_remaining_minutes": remaining})
+	}
+}
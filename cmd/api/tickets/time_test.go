@@ -0,0 +1,80 @@
+package tickets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	mockdb "github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestLogTime(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var audited []string
+	db := &mockdb.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &mockdb.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "from support_contracts c") {
+					left := 450
+					*dest[0].(**int) = &left
+					return nil
+				}
+				if args[0] == "missing" {
+					return pgx.ErrNoRows
+				}
+				contract := "k1"
+				*dest[0].(*string) = "te1"
+				*dest[2].(*int) = args[2].(int)
+				*dest[4].(**string) = &contract
+				*dest[5].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "audit_events") {
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := app.NewApp(app.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/time", LogTime(a))
+	post := func(id, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/"+id+"/time", strings.NewReader(body)))
+		return rr
+	}
+
+	if rr := post("t1", `{"minutes":0}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	if rr := post("missing", `{"minutes":30}`); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	rr := post("t1", `{"minutes":30,"note":"remote session"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out struct {
+		Entry     TimeEntry `json:"entry"`
+		Remaining *int      `json:"contract_remaining_minutes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Entry.Minutes != 30 || out.Remaining == nil || *out.Remaining != 450 {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	if strings.Join(audited, ",") != "time_logged" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+}
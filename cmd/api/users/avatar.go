@@ -0,0 +1,206 @@
+package users
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	s3svc "github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// avatarTarget returns the user whose avatar a request changes: the caller
+// for /me routes, else the :id parameter.
+func avatarTarget(c *gin.Context) string {
+	if id := c.Param("id"); id != "" {
+		return id
+	}
+	u, _ := c.Get("user")
+	au, _ := u.(authpkg.AuthUser)
+	return au.ID
+}
+
+// UploadAvatar stores a multipart "file" image as the user's avatar and
+// queues the worker to resize it. The original is served until then. Mounted
+// at /me/avatar for the caller and /users/:id/avatar for admins.
+func UploadAvatar(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := avatarTarget(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		store, loc := a.ResolveClass(ctx, s3svc.ClassAvatars)
+		bucket := loc.Bucket
+		if a.DB == nil || store == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "object store not configured", nil)
+			return
+		}
+		f, header, err := c.Request.FormFile("file")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "file required", map[string]string{"file": "required"})
+			return
+		}
+		defer f.Close()
+		if header.Size > avatars.MaxBytes {
+			apppkg.AbortError(c, http.StatusRequestEntityTooLarge, "too_large", "avatar must be 5 MB or smaller", nil)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(f, avatars.MaxBytes+1))
+		if err != nil || len(data) > avatars.MaxBytes {
+			apppkg.AbortError(c, http.StatusRequestEntityTooLarge, "too_large", "avatar must be 5 MB or smaller", nil)
+			return
+		}
+		if err := avatars.Check(data); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_image", "file must be a PNG, JPEG or GIF image", map[string]string{"file": err.Error()})
+			return
+		}
+		key := loc.Key(avatars.OriginalKey(userID))
+		oc, cancel := a.ObjCtx(ctx)
+		defer cancel()
+		if _, err := store.PutObject(oc, bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: http.DetectContentType(data)}); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "store_error", "failed to store avatar", nil)
+			return
+		}
+		var old string
+		err = apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `update users u set avatar_key=$2
+                from (select id, coalesce(avatar_key,'') as key from users where id=$1 for update) prev
+                where u.id=prev.id returning prev.key`, userID, key).Scan(&old); err != nil {
+				return err
+			}
+			if err := outbox.AddJob(ctx, tx, "resize_avatar:"+key, "", jobs.TypeResizeAvatar, jobs.ResizeAvatar{UserID: userID, Bucket: bucket, Key: key}); err != nil {
+				return err
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "user", userID, "avatar_updated", map[string]any{"avatar_key": key})
+		})
+		if err != nil {
+			_ = store.RemoveObject(oc, bucket, key, minio.RemoveObjectOptions{})
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
+				return
+			}
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save avatar", nil)
+			return
+		}
+		removeAvatarObject(c, store, bucket, old)
+		c.JSON(http.StatusOK, gin.H{"avatar_url": avatars.URL(userID, key, "")})
+	}
+}
+
+// DeleteAvatar removes the user's uploaded avatar so Gravatar is used again.
+func DeleteAvatar(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := avatarTarget(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		ctx := c.Request.Context()
+		var old string
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `update users u set avatar_key=null
+                from (select id, coalesce(avatar_key,'') as key from users where id=$1 for update) prev
+                where u.id=prev.id returning prev.key`, userID).Scan(&old); err != nil {
+				return err
+			}
+			if old == "" {
+				return nil
+			}
+			return audit.RecordDiff(ctx, tx, authpkg.Actor(c), "user", userID, "avatar_removed", map[string]any{"avatar_key": old})
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to remove avatar", nil)
+			return
+		}
+		store, loc := a.ResolveClass(ctx, s3svc.ClassAvatars)
+		bucket := loc.Bucket
+		removeAvatarObject(c, store, bucket, old)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetAvatar serves a user's uploaded avatar. Users without one are
+// redirected to their Gravatar.
+func GetAvatar(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "avatar not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var key, email string
+		if err := a.DB.QueryRow(ctx, `select coalesce(avatar_key,''), coalesce(email,'') from users where id=$1`, c.Param("id")).Scan(&key, &email); err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "avatar not found", nil)
+			return
+		}
+		if key == "" {
+			if u := avatars.Gravatar(email); u != "" {
+				c.Redirect(http.StatusFound, u)
+				return
+			}
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "avatar not found", nil)
+			return
+		}
+		store, loc := a.ResolveClass(ctx, s3svc.ClassAvatars)
+		bucket := loc.Bucket
+		if store == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "object store not configured", nil)
+			return
+		}
+		if mw, ok := store.(*apppkg.MinioWrapper); ok {
+			svc := s3svc.Service{Client: mw.Client, Bucket: bucket, MaxTTL: time.Minute}
+			u, err := svc.PresignGet(ctx, key, "", time.Minute)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "store_error", "failed to load avatar", nil)
+				return
+			}
+			c.Redirect(http.StatusFound, u)
+			return
+		}
+		rc, err := apppkg.OpenObject(ctx, store, bucket, key)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "avatar not found", nil)
+			return
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, avatars.MaxBytes+1))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "store_error", "failed to load avatar", nil)
+			return
+		}
+		// Avatar URLs carry a version, so a new upload is a new URL.
+		c.Header("Cache-Control", "private, max-age=86400")
+		c.Data(http.StatusOK, http.DetectContentType(data), data)
+	}
+}
+
+func removeAvatarObject(c *gin.Context, store apppkg.ObjectStore, bucket, key string) {
+	if store == nil || key == "" {
+		return
+	}
+	if err := store.RemoveObject(c.Request.Context(), bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("remove previous avatar")
+	}
+}
@@ -0,0 +1,110 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func avatarRequest(t *testing.T, url string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = fw.Write(data)
+	_ = mw.Close()
+	req := httptest.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadAndServeAvatar(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const uid = "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f"
+	var stored string
+	var queued, audited bool
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			switch {
+			case strings.Contains(sql, "update users u set avatar_key"):
+				if args[0] != uid {
+					return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+				}
+				stored = args[1].(string)
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error { *(dest[0].(*string)) = ""; return nil }}
+			case strings.Contains(sql, "select coalesce(avatar_key,'')"):
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*(dest[0].(*string)) = stored
+					*(dest[1].(*string)) = "u1@example.com"
+					return nil
+				}}
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "outbox"):
+				queued = strings.Contains(args[2].(string), "resize_avatar")
+			case strings.Contains(sql, "audit_events"):
+				audited = true
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true, MinIOBucket: "bkt"}, db, nil, store, nil)
+	a.R.POST("/users/:id/avatar", authpkg.Middleware(a), UploadAvatar(a))
+	a.R.GET("/users/:id/avatar", authpkg.Middleware(a), GetAvatar(a))
+
+	var img bytes.Buffer
+	_ = png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 300, 300)))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, avatarRequest(t, "/users/"+uid+"/avatar", []byte("plain text")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-image, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, avatarRequest(t, "/users/"+uid+"/avatar", img.Bytes()))
+	if rr.Code != http.StatusOK || !queued || !audited {
+		t.Fatalf("expected queued, audited 200, got %d (queued=%v audited=%v): %s", rr.Code, queued, audited, rr.Body.String())
+	}
+	var out struct {
+		AvatarURL string `json:"avatar_url"`
+	}
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if !strings.HasPrefix(out.AvatarURL, "/api/users/"+uid+"/avatar?v=") {
+		t.Fatalf("unexpected avatar_url %q", out.AvatarURL)
+	}
+
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/"+uid+"/avatar", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rr.Body.Bytes(), img.Bytes()) {
+		t.Fatalf("expected original served, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	stored = ""
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users/"+uid+"/avatar", nil))
+	if rr.Code != http.StatusFound || !strings.HasPrefix(rr.Header().Get("Location"), "https://www.gravatar.com/avatar/") {
+		t.Fatalf("expected gravatar redirect, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+}
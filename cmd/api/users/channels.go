@@ -0,0 +1,296 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+)
+
+// maxChannels bounds how many notification channels one user can register.
+const maxChannels = 10
+
+// NotificationChannel is one of the caller's SMS, push or ntfy channels and
+// the events it receives.
+type NotificationChannel struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Address    string     `json:"address"`
+	Events     []string   `json:"events"`
+	Enabled    bool       `json:"enabled"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	LastError  *string    `json:"last_error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const channelCols = `id::text, kind, address, events, enabled, last_sent_at, last_error, created_at`
+
+func scanChannel(row pgx.Row) (NotificationChannel, error) {
+	var ch NotificationChannel
+	err := row.Scan(&ch.ID, &ch.Kind, &ch.Address, &ch.Events, &ch.Enabled, &ch.LastSentAt, &ch.LastError, &ch.CreatedAt)
+	if ch.Events == nil {
+		ch.Events = []string{}
+	}
+	return ch, err
+}
+
+// normalizeEvents dedupes events and checks each can be subscribed to.
+func normalizeEvents(events []string) ([]string, bool) {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		e = strings.TrimSpace(e)
+		if !notify.ValidEvent(e) {
+			return nil, false
+		}
+		if !slices.Contains(out, e) {
+			out = append(out, e)
+		}
+	}
+	return out, true
+}
+
+// ListNotificationChannels returns the caller's notification channels.
+func ListNotificationChannels(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		out := []NotificationChannel{}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"channels": out, "events": notify.Events})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+channelCols+` from notification_channels where user_id=$1 order by created_at`, userID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load notification channels", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			ch, err := scanChannel(rows)
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load notification channels", nil)
+				return
+			}
+			out = append(out, ch)
+		}
+		if err := rows.Err(); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load notification channels", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"channels": out, "events": notify.Events})
+	}
+}
+
+// CreateNotificationChannel registers a channel for the caller. events
+// defaults to SLA breaches only.
+func CreateNotificationChannel(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		var in struct {
+			Kind    string    `json:"kind"`
+			Address string    `json:"address"`
+			Events  *[]string `json:"events"`
+			Enabled *bool     `json:"enabled"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		fields := map[string]string{}
+		ch := NotificationChannel{Kind: strings.TrimSpace(in.Kind), Events: []string{notify.EventSLABreach}, Enabled: true}
+		if !notify.ValidKind(ch.Kind) {
+			fields["kind"] = "must be one of " + strings.Join(notify.Kinds, ", ")
+		} else if addr, err := notify.NormalizeAddress(ch.Kind, in.Address); err != nil {
+			fields["address"] = err.Error()
+		} else {
+			ch.Address = addr
+		}
+		if in.Events != nil {
+			events, ok := normalizeEvents(*in.Events)
+			if !ok {
+				fields["events"] = "must be among " + strings.Join(notify.Events, ", ")
+			}
+			ch.Events = events
+		}
+		if in.Enabled != nil {
+			ch.Enabled = *in.Enabled
+		}
+		if len(fields) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid notification channel", fields)
+			return
+		}
+		if a.DB == nil {
+			ch.ID = uuid.NewString()
+			ch.CreatedAt = time.Now()
+			c.JSON(http.StatusCreated, ch)
+			return
+		}
+		ctx := c.Request.Context()
+		ch, err := scanChannel(a.DB.QueryRow(ctx, `insert into notification_channels (user_id, kind, address, events, enabled)
+            select $1, $2, $3, $4, $5 where (select count(*) from notification_channels where user_id=$1) < $6
+            returning `+channelCols, userID, ch.Kind, ch.Address, ch.Events, ch.Enabled, maxChannels))
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			apppkg.AbortError(c, http.StatusConflict, "channel_exists", "notification channel already registered", nil)
+			return
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusConflict, "too_many_channels", "notification channel limit reached", map[string]string{"limit": "10"})
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("create notification channel")
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save notification channel", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "notification_channel", ch.ID, "notification_channel_created", map[string]any{
+			"kind": ch.Kind, "events": ch.Events,
+		}); err != nil {
+			log.Error().Err(err).Msg("audit notification channel")
+		}
+		c.JSON(http.StatusCreated, ch)
+	}
+}
+
+// UpdateNotificationChannel changes which events one of the caller's
+// channels receives, or enables or disables it. The address is fixed; a new
+// one is a new channel.
+func UpdateNotificationChannel(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		var in struct {
+			Events  *[]string `json:"events"`
+			Enabled *bool     `json:"enabled"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		var events []string
+		if in.Events != nil {
+			var ok bool
+			if events, ok = normalizeEvents(*in.Events); !ok {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid notification channel",
+					map[string]string{"events": "must be among " + strings.Join(notify.Events, ", ")})
+				return
+			}
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil || a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		ch, err := scanChannel(a.DB.QueryRow(ctx, `update notification_channels
+            set events = coalesce($3::text[], events), enabled = coalesce($4, enabled)
+            where id=$1 and user_id=$2 returning `+channelCols, c.Param("id"), userID, events, in.Enabled))
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("update notification channel")
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save notification channel", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "notification_channel", ch.ID, "notification_channel_updated", map[string]any{
+			"events": ch.Events, "enabled": ch.Enabled,
+		}); err != nil {
+			log.Error().Err(err).Msg("audit notification channel")
+		}
+		c.JSON(http.StatusOK, ch)
+	}
+}
+
+// DeleteNotificationChannel removes one of the caller's channels.
+func DeleteNotificationChannel(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil || a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		tag, err := a.DB.Exec(ctx, `delete from notification_channels where id=$1 and user_id=$2`, c.Param("id"), userID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to delete notification channel", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "notification_channel", c.Param("id"), "notification_channel_deleted", map[string]any{}); err != nil {
+			log.Error().Err(err).Msg("audit notification channel")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// TestNotificationChannel queues a test message on one of the caller's
+// enabled channels. The outcome shows up in the
+// channel's last_sent_at or last_error once the worker has sent it.
+func TestNotificationChannel(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		if _, err := uuid.Parse(c.Param("id")); err != nil || a.DB == nil {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		ctx := c.Request.Context()
+		var enabled bool
+		err := a.DB.QueryRow(ctx, `select enabled from notification_channels where id=$1 and user_id=$2`, c.Param("id"), userID).Scan(&enabled)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "notification channel not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load notification channel", nil)
+			return
+		}
+		if !enabled {
+			apppkg.AbortError(c, http.StatusConflict, "channel_disabled", "enable the channel before testing it", nil)
+			return
+		}
+		if err := notify.Enqueue(ctx, a.DB, c.Param("id"), notify.Message{
+			Event: notify.EventTest,
+			Title: "Helpdesk test notification",
+			Body:  "This channel will receive your helpdesk notifications.",
+		}); err != nil {
+			log.Error().Err(err).Msg("queue test notification")
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to queue test notification", nil)
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+	}
+}
@@ -0,0 +1,126 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCreateNotificationChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const me = "11111111-1111-4111-8111-111111111111"
+	var saved []any
+	existing := map[string]bool{}
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			saved = args
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if existing[args[2].(string)] {
+					return &pgconn.PgError{Code: "23505"}
+				}
+				*dest[0].(*string) = "c1"
+				*dest[1].(*string) = args[1].(string)
+				*dest[2].(*string) = args[2].(string)
+				*dest[3].(*[]string) = args[3].([]string)
+				*dest[4].(*bool) = args[4].(bool)
+				*dest[7].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/me/notification-channels", authpkg.Middleware(a), func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: me})
+	}, CreateNotificationChannel(a))
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/me/notification-channels", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"kind":"pager","address":"x"}`,
+		`{"kind":"sms","address":"555-1234"}`,
+		`{"kind":"ntfy","address":"oncall","events":["everything"]}`,
+	} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	rr := post(`{"kind":"sms","address":"+1 555 123 4567"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var ch NotificationChannel
+	_ = json.Unmarshal(rr.Body.Bytes(), &ch)
+	if ch.Address != "+15551234567" || len(ch.Events) != 1 || ch.Events[0] != "sla_breach" || !ch.Enabled {
+		t.Fatalf("unexpected channel %+v", ch)
+	}
+	if saved[0] != me || saved[5] != maxChannels {
+		t.Fatalf("unexpected insert args %v", saved)
+	}
+
+	existing["+15551234567"] = true
+	if rr := post(`{"kind":"sms","address":"+15551234567","events":["ticket_assigned","sla_breach","sla_breach"]}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a duplicate, got %d", rr.Code)
+	}
+	if ev := saved[3].([]string); len(ev) != 2 {
+		t.Fatalf("expected events to be deduped, got %v", ev)
+	}
+}
+
+func TestTestNotificationChannel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const me = "11111111-1111-4111-8111-111111111111"
+	const channel = "33333333-3333-4333-8333-333333333333"
+	var queued string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != channel || args[1] != me {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*bool) = true
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into outbox") {
+				queued = args[2].(string)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/me/notification-channels/:id/test", authpkg.Middleware(a), func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: me})
+	}, TestNotificationChannel(a))
+	do := func(id string) int {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/me/notification-channels/"+id+"/test", nil))
+		return rr.Code
+	}
+	if code := do("44444444-4444-4444-8444-444444444444"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another user's channel, got %d", code)
+	}
+	if code := do(channel); code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", code)
+	}
+	if !strings.Contains(queued, `"channel_id":"`+channel+`"`) || !strings.Contains(queued, `"event":"test"`) {
+		t.Fatalf("unexpected queued job %s", queued)
+	}
+}
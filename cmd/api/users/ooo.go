@@ -0,0 +1,162 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/ooo"
+)
+
+// OutOfOffice is the caller's out-of-office window. While Active, new
+// assignments go to DelegateID, or to the team pool when it is null.
+type OutOfOffice struct {
+	StartsAt   *time.Time `json:"starts_at"`
+	EndsAt     *time.Time `json:"ends_at"`
+	DelegateID *string    `json:"delegate_id"`
+	Active     bool       `json:"active"`
+}
+
+func currentUserID(c *gin.Context) string {
+	u, _ := c.Get("user")
+	au, _ := u.(authpkg.AuthUser)
+	return au.ID
+}
+
+// GetOutOfOffice returns the caller's out-of-office window, if any.
+func GetOutOfOffice(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		var out OutOfOffice
+		if a.DB == nil {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		err := a.DB.QueryRow(c.Request.Context(), `select u.ooo_start, u.ooo_end, u.ooo_delegate_id::text, `+ooo.Active("u")+`
+            from users u where u.id=$1`, userID).Scan(&out.StartsAt, &out.EndsAt, &out.DelegateID, &out.Active)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load out-of-office", nil)
+			return
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// SetOutOfOffice schedules the caller's out-of-office window, replacing any
+// existing one. delegate_id is optional.
+func SetOutOfOffice(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		var in struct {
+			StartsAt   *time.Time `json:"starts_at"`
+			EndsAt     *time.Time `json:"ends_at"`
+			DelegateID *string    `json:"delegate_id"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+			return
+		}
+		fields := map[string]string{}
+		if in.StartsAt == nil {
+			fields["starts_at"] = "required"
+		}
+		if in.EndsAt == nil {
+			fields["ends_at"] = "required"
+		} else if in.StartsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+			fields["ends_at"] = "must be after starts_at"
+		} else if !in.EndsAt.After(time.Now()) {
+			fields["ends_at"] = "must be in the future"
+		}
+		if in.DelegateID != nil && *in.DelegateID == "" {
+			in.DelegateID = nil
+		}
+		if in.DelegateID != nil {
+			if _, err := uuid.Parse(*in.DelegateID); err != nil {
+				fields["delegate_id"] = "must be a user id"
+			} else if *in.DelegateID == userID {
+				fields["delegate_id"] = "cannot delegate to yourself"
+			}
+		}
+		if len(fields) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid out-of-office window", fields)
+			return
+		}
+		out := OutOfOffice{StartsAt: in.StartsAt, EndsAt: in.EndsAt, DelegateID: in.DelegateID}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		ctx := c.Request.Context()
+		if in.DelegateID != nil {
+			var one int
+			if err := a.DB.QueryRow(ctx, `select 1 from users where id=$1`, *in.DelegateID).Scan(&one); err != nil {
+				apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid out-of-office window", map[string]string{"delegate_id": "user not found"})
+				return
+			}
+		}
+		err := a.DB.QueryRow(ctx, `update users u set ooo_start=$2, ooo_end=$3, ooo_delegate_id=$4
+            where u.id=$1 returning `+ooo.Active("u"), userID, *in.StartsAt, *in.EndsAt, in.DelegateID).Scan(&out.Active)
+		if errors.Is(err, pgx.ErrNoRows) {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "user not found", nil)
+			return
+		}
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to save out-of-office", nil)
+			return
+		}
+		if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "user", userID, "out_of_office_set", map[string]any{
+			"starts_at": in.StartsAt, "ends_at": in.EndsAt, "delegate_id": in.DelegateID,
+		}); err != nil {
+			log.Error().Err(err).Msg("audit out-of-office")
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// ClearOutOfOffice ends or cancels the caller's out-of-office window.
+func ClearOutOfOffice(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+		if userID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		ctx := c.Request.Context()
+		tag, err := a.DB.Exec(ctx, `update users set ooo_start=null, ooo_end=null, ooo_delegate_id=null
+            where id=$1 and ooo_start is not null`, userID)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to clear out-of-office", nil)
+			return
+		}
+		if tag.RowsAffected() > 0 {
+			if err := audit.RecordDiff(ctx, a.DB, authpkg.Actor(c), "user", userID, "out_of_office_cleared", map[string]any{}); err != nil {
+				log.Error().Err(err).Msg("audit out-of-office")
+			}
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
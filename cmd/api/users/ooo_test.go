@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestSetOutOfOffice(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const me = "11111111-1111-4111-8111-111111111111"
+	const delegate = "22222222-2222-4222-8222-222222222222"
+	var saved []any
+	var audited bool
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			switch {
+			case strings.Contains(sql, "select 1 from users"):
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					if args[0] != delegate {
+						return pgx.ErrNoRows
+					}
+					*(dest[0].(*int)) = 1
+					return nil
+				}}
+			case strings.Contains(sql, "update users u set ooo_start"):
+				saved = args
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error { *(dest[0].(*bool)) = true; return nil }}
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			audited = audited || strings.Contains(sql, "audit_events")
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.PUT("/me/out-of-office", authpkg.Middleware(a), func(c *gin.Context) {
+		c.Set("user", authpkg.AuthUser{ID: me})
+	}, SetOutOfOffice(a))
+
+	put := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/me/out-of-office", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+	start := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	end := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+
+	for name, body := range map[string]string{
+		"reversed window":  `{"starts_at":"` + end + `","ends_at":"` + start + `"}`,
+		"self delegate":    `{"starts_at":"` + start + `","ends_at":"` + end + `","delegate_id":"` + me + `"}`,
+		"unknown delegate": `{"starts_at":"` + start + `","ends_at":"` + end + `","delegate_id":"33333333-3333-4333-8333-333333333333"}`,
+		"missing end":      `{"starts_at":"` + start + `"}`,
+	} {
+		if rr := put(body); rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+	if saved != nil {
+		t.Fatalf("invalid windows must not be saved")
+	}
+
+	rr := put(`{"starts_at":"` + start + `","ends_at":"` + end + `","delegate_id":"` + delegate + `"}`)
+	if rr.Code != http.StatusOK || !audited {
+		t.Fatalf("expected audited 200, got %d (audited=%v): %s", rr.Code, audited, rr.Body.String())
+	}
+	var out OutOfOffice
+	_ = json.Unmarshal(rr.Body.Bytes(), &out)
+	if !out.Active || out.DelegateID == nil || *out.DelegateID != delegate {
+		t.Fatalf("unexpected response %+v", out)
+	}
+	if saved[0] != me || *(saved[3].(*string)) != delegate {
+		t.Fatalf("unexpected args %v", saved)
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/crypto/bcrypt"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
 )
 
 // GetProfile returns the current user's profile from DB or synthesizes one.
@@ -27,12 +30,15 @@ func GetProfile(a *apppkg.App) gin.HandlerFunc {
 			Username    string `json:"username,omitempty"`
 			Email       string `json:"email,omitempty"`
 			DisplayName string `json:"display_name,omitempty"`
+			AvatarURL   string `json:"avatar_url,omitempty"`
 		}
 		var p profile
 		if a.DB != nil {
 			au := uVal.(authpkg.AuthUser)
-			row := a.DB.QueryRow(c.Request.Context(), `select id::text, coalesce(username,''), coalesce(email,''), coalesce(display_name,'') from users where external_id=$1`, au.ExternalID)
-			_ = row.Scan(&p.ID, &p.Username, &p.Email, &p.DisplayName)
+			var avatarKey string
+			row := a.DB.QueryRow(c.Request.Context(), `select id::text, coalesce(username,''), coalesce(email,''), coalesce(display_name,''), coalesce(avatar_key,'') from users where external_id=$1`, au.ExternalID)
+			_ = row.Scan(&p.ID, &p.Username, &p.Email, &p.DisplayName, &avatarKey)
+			p.AvatarURL = avatars.URL(p.ID, avatarKey, p.Email)
 		}
 		c.JSON(http.StatusOK, p)
 	}
@@ -118,35 +124,48 @@ func ChangePassword(a *apppkg.App) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if err := authpkg.RecordPasswordChange(c, a.DB, uid); err != nil {
+			log.Error().Err(err).Msg("record password change")
+		}
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	}
 }
 
 // List returns users with basic fields and stored roles. Optional q filters by
-// email/username/display_name (case-insensitive substring). Limited to 100.
+// email/username/display_name (case-insensitive substring) and pending=true
+// keeps users awaiting approval. Limited to 100.
 func List(a *apppkg.App) gin.HandlerFunc {
 	type user struct {
-		ID          string   `json:"id"`
-		ExternalID  string   `json:"external_id"`
-		Username    string   `json:"username"`
-		Email       string   `json:"email"`
-		DisplayName string   `json:"display_name"`
-		Roles       []string `json:"roles"`
+		ID              string   `json:"id"`
+		ExternalID      string   `json:"external_id"`
+		Username        string   `json:"username"`
+		Email           string   `json:"email"`
+		DisplayName     string   `json:"display_name"`
+		Roles           []string `json:"roles"`
+		PendingApproval bool     `json:"pending_approval,omitempty"`
+		AvatarURL       string   `json:"avatar_url,omitempty"`
 	}
 	return func(c *gin.Context) {
 		q := strings.TrimSpace(c.Query("q"))
 		base := `
 select u.id::text, coalesce(u.external_id,''), coalesce(u.username,''), coalesce(u.email,''), coalesce(u.display_name,''),
-       coalesce(string_agg(distinct r.name, ','), '') as roles
+       coalesce(string_agg(distinct r.name, ','), '') as roles, u.pending_approval, coalesce(u.avatar_key,'')
 from users u
 left join user_roles ur on ur.user_id=u.id
 left join roles r on r.id=ur.role_id`
-		where := ""
+		var conds []string
 		args := []any{}
 		if q != "" {
-			where = " where lower(u.email) like $1 or lower(u.username) like $1 or lower(u.display_name) like $1"
+			conds = append(conds, "(lower(u.email) like $1 or lower(u.username) like $1 or lower(u.display_name) like $1)")
 			args = append(args, "%"+strings.ToLower(q)+"%")
 		}
+		if c.Query("pending") == "true" {
+			conds = append(conds, "u.pending_approval")
+		}
+		where := ""
+		if len(conds) > 0 {
+			where = " where " + strings.Join(conds, " and ")
+		}
 		sql := base + where + " group by u.id order by u.display_name nulls last, u.email nulls last limit 100"
 		rows, err := a.DB.Query(c.Request.Context(), sql, args...)
 		if err != nil {
@@ -157,11 +176,12 @@ left join roles r on r.id=ur.role_id`
 		var out []user
 		for rows.Next() {
 			var u user
-			var roles string
-			if err := rows.Scan(&u.ID, &u.ExternalID, &u.Username, &u.Email, &u.DisplayName, &roles); err != nil {
+			var roles, avatarKey string
+			if err := rows.Scan(&u.ID, &u.ExternalID, &u.Username, &u.Email, &u.DisplayName, &roles, &u.PendingApproval, &avatarKey); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
 			}
+			u.AvatarURL = avatars.URL(u.ID, avatarKey, u.Email)
 			if roles != "" {
 				u.Roles = strings.Split(roles, ",")
 			} else {
@@ -181,6 +201,7 @@ func Get(a *apppkg.App) gin.HandlerFunc {
 		Username    string `json:"username"`
 		Email       string `json:"email"`
 		DisplayName string `json:"display_name"`
+		AvatarURL   string `json:"avatar_url,omitempty"`
 	}
 	return func(c *gin.Context) {
 		if a.DB == nil {
@@ -188,15 +209,37 @@ func Get(a *apppkg.App) gin.HandlerFunc {
 			return
 		}
 		var u user
-		row := a.DB.QueryRow(c.Request.Context(), `select id::text, coalesce(external_id,''), coalesce(username,''), coalesce(email,''), coalesce(display_name,'') from users where id=$1`, c.Param("id"))
-		if err := row.Scan(&u.ID, &u.ExternalID, &u.Username, &u.Email, &u.DisplayName); err != nil {
+		var avatarKey string
+		row := a.DB.QueryRow(c.Request.Context(), `select id::text, coalesce(external_id,''), coalesce(username,''), coalesce(email,''), coalesce(display_name,''), coalesce(avatar_key,'') from users where id=$1`, c.Param("id"))
+		if err := row.Scan(&u.ID, &u.ExternalID, &u.Username, &u.Email, &u.DisplayName, &avatarKey); err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 			return
 		}
+		u.AvatarURL = avatars.URL(u.ID, avatarKey, u.Email)
 		c.JSON(http.StatusOK, u)
 	}
 }
 
+// Approve activates a user held for approval after OIDC provisioning.
+func Approve(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		tag, err := a.DB.Exec(c.Request.Context(), `update users set pending_approval=false, active=true where id=$1 and pending_approval`, id)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to approve user", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "no pending user with that id", nil)
+			return
+		}
+		if err := audit.RecordDiff(c.Request.Context(), a.DB, authpkg.Actor(c), "user", id, "user_approved", map[string]any{"pending_approval": false}); err != nil {
+			log.Error().Err(err).Msg("audit user approval")
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
 // CreateLocal creates a local user with username, email, display_name, password.
 func CreateLocal(a *apppkg.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -250,3 +293,28 @@ func ListRoles(a *apppkg.App) gin.HandlerFunc {
 		c.JSON(http.StatusOK, out)
 	}
 }
+
+// SetAvailability lets the caller stop or resume receiving new work.
+// Unavailable agents show as away in team workloads.
+func SetAvailability(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Available *bool `json:"available"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || in.Available == nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_body", "available required", map[string]string{"available": "required"})
+			return
+		}
+		uVal, _ := c.Get("user")
+		au, _ := uVal.(authpkg.AuthUser)
+		if au.ID == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "unauthenticated", nil)
+			return
+		}
+		if _, err := a.DB.Exec(c.Request.Context(), `update users set available=$2 where id=$1`, au.ID, *in.Available); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to update availability", nil)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"available": *in.Available})
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
@@ -350,3 +351,35 @@ func TestGetProfile(t *testing.T) {
 		})
 	}
 }
+
+func TestApprove(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var audited bool
+	db := &testutil.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "update users set pending_approval=false"):
+				if args[0] == "6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f" {
+					return pgconn.NewCommandTag("UPDATE 1"), nil
+				}
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			case strings.Contains(sql, "audit_events"):
+				audited = true
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/users/:id/approve", authpkg.Middleware(a), Approve(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users/6f1c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f/approve", nil))
+	if rr.Code != http.StatusNoContent || !audited {
+		t.Fatalf("expected audited 204, got %d (audited=%v)", rr.Code, audited)
+	}
+	rr = httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/users/0b7c2d3e-4b5a-4c6d-8e9f-0a1b2c3d4e5f/approve", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a user that is not pending, got %d", rr.Code)
+	}
+}
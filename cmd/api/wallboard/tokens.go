@@ -0,0 +1,186 @@
+package wallboard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+// TokenHeader carries the display token for clients that can set headers.
+// EventSource cannot, so the token is also accepted as ?token=.
+const TokenHeader = "X-Wallboard-Token"
+
+// scopeKey is the context key holding the Scope of a validated token.
+const scopeKey = "wallboard_scope"
+
+// Token is a display token as listed to admins. The secret itself is only
+// returned once, when the token is created.
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	QueueID    *string    `json:"queue_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Secret     string     `json:"token,omitempty"`
+}
+
+// Scope is what a validated token may see.
+type Scope struct {
+	TokenID string
+	Name    string
+	QueueID string
+}
+
+// lookup resolves a raw token to its scope. pgx.ErrNoRows is returned for
+// unknown, revoked and expired tokens.
+func lookup(ctx context.Context, db apppkg.DB, raw string) (Scope, error) {
+	var s Scope
+	err := db.QueryRow(ctx, `select id::text, name, coalesce(queue_id::text, '') from wallboard_tokens
+        where token_hash=$1 and revoked_at is null and (expires_at is null or expires_at > now())`, secret.Hash(raw)).Scan(&s.TokenID, &s.Name, &s.QueueID)
+	return s, err
+}
+
+// stillValid reports whether a token is neither revoked nor expired.
+func stillValid(ctx context.Context, db apppkg.DB, id string) bool {
+	var ok bool
+	err := db.QueryRow(ctx, `select revoked_at is null and (expires_at is null or expires_at > now())
+        from wallboard_tokens where id=$1`, id).Scan(&ok)
+	return err == nil && ok
+}
+
+// RequireToken authenticates wallboard requests with a display token instead
+// of a user session. It grants read access to the wallboard only.
+func RequireToken(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := strings.TrimSpace(c.GetHeader(TokenHeader))
+		if raw == "" {
+			raw = strings.TrimSpace(c.Query("token"))
+		}
+		if raw == "" {
+			apppkg.AbortError(c, http.StatusUnauthorized, "unauthenticated", "display token required", nil)
+			return
+		}
+		if a.DB == nil {
+			apppkg.AbortError(c, http.StatusServiceUnavailable, "unavailable", "database unavailable", nil)
+			return
+		}
+		s, err := lookup(c.Request.Context(), a.DB, raw)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				apppkg.AbortError(c, http.StatusUnauthorized, "invalid_token", "invalid token", nil)
+			} else {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to validate token", nil)
+			}
+			return
+		}
+		_, _ = a.DB.Exec(c.Request.Context(), `update wallboard_tokens set last_used_at=now() where id=$1`, s.TokenID)
+		c.Set(scopeKey, s)
+		c.Next()
+	}
+}
+
+func scopeFrom(c *gin.Context) Scope {
+	s, _ := c.Get(scopeKey)
+	scope, _ := s.(Scope)
+	return scope
+}
+
+// ListTokens returns all display tokens, newest first.
+func ListTokens(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []Token{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select id::text, name, queue_id::text, created_at, expires_at, last_used_at, revoked_at
+            from wallboard_tokens order by created_at desc`)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list tokens", nil)
+			return
+		}
+		defer rows.Close()
+		out := []Token{}
+		for rows.Next() {
+			var t Token
+			if err := rows.Scan(&t.ID, &t.Name, &t.QueueID, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to list tokens", nil)
+				return
+			}
+			out = append(out, t)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// CreateToken issues a display token. The response is the only time the
+// token secret is shown.
+func CreateToken(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Name      string     `json:"name" binding:"required"`
+			QueueID   *string    `json:"queue_id"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Name) == "" {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "name required", nil)
+			return
+		}
+		if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "expires_at must be in the future", nil)
+			return
+		}
+		if in.QueueID != nil && strings.TrimSpace(*in.QueueID) == "" {
+			in.QueueID = nil
+		}
+		raw, err := secret.New("wb_")
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "internal", "failed to generate token", nil)
+			return
+		}
+		t := Token{Name: strings.TrimSpace(in.Name), QueueID: in.QueueID, ExpiresAt: in.ExpiresAt, Secret: raw}
+		if a.DB == nil {
+			c.JSON(http.StatusCreated, t)
+			return
+		}
+		err = a.DB.QueryRow(c.Request.Context(), `insert into wallboard_tokens (name, token_hash, queue_id, created_by, expires_at)
+            values ($1, $2, $3, $4, $5) returning id::text, created_at`,
+			t.Name, secret.Hash(raw), t.QueueID, authpkg.Actor(c).DBID(), t.ExpiresAt).Scan(&t.ID, &t.CreatedAt)
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to create token", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+	}
+}
+
+// RevokeToken disables a display token. Open streams using it end at their
+// next refresh.
+func RevokeToken(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		tag, err := a.DB.Exec(c.Request.Context(), `update wallboard_tokens set revoked_at=now() where id=$1 and revoked_at is null`, c.Param("id"))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to revoke token", nil)
+			return
+		}
+		if tag.RowsAffected() == 0 {
+			apppkg.AbortError(c, http.StatusNotFound, "not_found", "token not found", nil)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
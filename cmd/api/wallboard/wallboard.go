@@ -0,0 +1,156 @@
+// Package wallboard serves a read-only ticket summary for NOC screens, which
+// authenticate with a long-lived display token rather than a user login.
+package wallboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+)
+
+const (
+	timerLimit    = 10
+	activityLimit = 20
+	// RefreshInterval is how often Stream pushes a new snapshot.
+	RefreshInterval = 15 * time.Second
+)
+
+// Counts are the headline figures of a wallboard.
+type Counts struct {
+	Open         int `json:"open"`
+	New          int `json:"new"`
+	Unassigned   int `json:"unassigned"`
+	AtRisk       int `json:"at_risk"`
+	Breached     int `json:"breached"`
+	CreatedToday int `json:"created_today"`
+}
+
+// Activity is one recent ticket event. Payloads are left out so the board
+// never shows more than the ticket number and title.
+type Activity struct {
+	Type     string    `json:"type"`
+	TicketID string    `json:"ticket_id"`
+	Number   string    `json:"number"`
+	Title    string    `json:"title"`
+	At       time.Time `json:"at"`
+}
+
+// Snapshot is the wallboard payload.
+type Snapshot struct {
+	Board       string              `json:"board"`
+	QueueID     string              `json:"queue_id,omitempty"`
+	Counts      Counts              `json:"counts"`
+	SLATimers   []ticketspkg.Ticket `json:"sla_timers"`
+	Activity    []Activity          `json:"activity"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+func build(ctx context.Context, db apppkg.DB, s Scope) (Snapshot, error) {
+	now := time.Now().UTC()
+	out := Snapshot{Board: s.Name, QueueID: s.QueueID, SLATimers: []ticketspkg.Ticket{}, Activity: []Activity{}, GeneratedAt: now}
+	err := db.QueryRow(ctx, `select count(*) filter (where t.status not in ('Resolved','Closed')),
+            count(*) filter (where t.status = 'New'),
+            count(*) filter (where t.assignee_id is null and t.status not in ('Resolved','Closed')),
+            count(*) filter (where `+ticketspkg.AtRiskFilter+` and not (`+ticketspkg.BreachedFilter+`)),
+            count(*) filter (where `+ticketspkg.BreachedFilter+`),
+            count(*) filter (where t.created_at >= date_trunc('day', now()))
+        from tickets t
+        left join ticket_sla_clocks sc on sc.ticket_id = t.id
+        left join sla_policy_versions sp on sp.id = sc.policy_version_id
+        where $1 = '' or t.queue_id::text = $1`, s.QueueID).Scan(
+		&out.Counts.Open, &out.Counts.New, &out.Counts.Unassigned, &out.Counts.AtRisk, &out.Counts.Breached, &out.Counts.CreatedToday)
+	if err != nil {
+		return out, err
+	}
+	timers, err := ticketspkg.SLATimers(ctx, db, s.QueueID, timerLimit, now)
+	if err != nil {
+		return out, err
+	}
+	if timers != nil {
+		out.SLATimers = timers
+	}
+	rows, err := db.Query(ctx, `select e.event_type, t.id::text, t.number, t.title, e.created_at
+        from ticket_events e join tickets t on t.id = e.ticket_id
+        where $1 = '' or t.queue_id::text = $1
+        order by e.created_at desc limit $2`, s.QueueID, activityLimit)
+	if err != nil {
+		return out, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ev Activity
+		if err := rows.Scan(&ev.Type, &ev.TicketID, &ev.Number, &ev.Title, &ev.At); err != nil {
+			return out, err
+		}
+		out.Activity = append(out.Activity, ev)
+	}
+	return out, rows.Err()
+}
+
+// Get returns the current wallboard snapshot for the token's scope.
+func Get(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		snap, err := build(c.Request.Context(), a.DB, scopeFrom(c))
+		if err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load wallboard", nil)
+			return
+		}
+		c.JSON(http.StatusOK, snap)
+	}
+}
+
+// Stream pushes a snapshot event immediately and then every RefreshInterval
+// using Server-Sent Events. The stream ends once the token is revoked or
+// expires.
+func Stream(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("Content-Type", "text/event-stream")
+		c.Writer.Header().Set("Cache-Control", "no-cache")
+		c.Writer.Header().Set("Connection", "keep-alive")
+		c.Writer.Header().Set("X-Content-Type-Options", "nosniff")
+
+		flusher, ok := c.Writer.(http.Flusher)
+		if !ok {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		ctx := c.Request.Context()
+		scope := scopeFrom(c)
+
+		send := func() {
+			snap, err := build(ctx, a.DB, scope)
+			if err != nil {
+				// Keep the connection; the board shows its last snapshot.
+				fmt.Fprint(c.Writer, ": snapshot unavailable\n\n")
+			} else {
+				b, _ := json.Marshal(snap)
+				fmt.Fprintf(c.Writer, "event: snapshot\ndata: %s\n\n", b)
+			}
+			flusher.Flush()
+		}
+
+		send()
+		tick := time.NewTicker(RefreshInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if !stillValid(ctx, a.DB, scope.TokenID) {
+					fmt.Fprint(c.Writer, "event: revoked\ndata: {}\n\n")
+					flusher.Flush()
+					return
+				}
+				send()
+			}
+		}
+	}
+}
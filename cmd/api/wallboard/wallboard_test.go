@@ -0,0 +1,123 @@
+package wallboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/secret"
+)
+
+const token = "wb_display"
+
+func TestWallboardToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var countsArgs []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from wallboard_tokens"):
+					if args[0] != secret.Hash(token) {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string) = "tok1"
+					*dest[1].(*string) = "NOC"
+					*dest[2].(*string) = "q1"
+				case strings.Contains(sql, "from tickets t"):
+					countsArgs = args
+					for i, n := range []int{7, 2, 3, 1, 1, 4} {
+						*dest[i].(*int) = n
+					}
+				}
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.GET("/wallboard", RequireToken(a), Get(a))
+
+	for name, tc := range map[string]struct {
+		header, query string
+		want          int
+	}{
+		"missing": {want: http.StatusUnauthorized},
+		"unknown": {header: "wb_other", want: http.StatusUnauthorized},
+		"header":  {header: token, want: http.StatusOK},
+		"query":   {query: "?token=" + token, want: http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/wallboard"+tc.query, nil)
+			if tc.header != "" {
+				req.Header.Set(TokenHeader, tc.header)
+			}
+			rr := httptest.NewRecorder()
+			a.R.ServeHTTP(rr, req)
+			if rr.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, rr.Code, rr.Body.String())
+			}
+			if tc.want != http.StatusOK {
+				return
+			}
+			var snap Snapshot
+			if err := json.Unmarshal(rr.Body.Bytes(), &snap); err != nil {
+				t.Fatalf("invalid json: %v", err)
+			}
+			if snap.Board != "NOC" || snap.QueueID != "q1" || snap.Counts.Open != 7 || snap.Counts.CreatedToday != 4 {
+				t.Fatalf("unexpected snapshot: %+v", snap)
+			}
+			if len(countsArgs) != 1 || countsArgs[0] != "q1" {
+				t.Fatalf("counts not scoped to token queue: %v", countsArgs)
+			}
+		})
+	}
+}
+
+func TestCreateTokenStoresHash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var stored []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			stored = args
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "tok1"
+				*dest[1].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/wallboard/tokens", authpkg.Middleware(a), CreateToken(a))
+
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/wallboard/tokens", bytes.NewBufferString(`{"name":"NOC"}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var tok Token
+	if err := json.Unmarshal(rr.Body.Bytes(), &tok); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if !strings.HasPrefix(tok.Secret, "wb_") || tok.ID != "tok1" {
+		t.Fatalf("unexpected token: %+v", tok)
+	}
+	if stored[1] != secret.Hash(tok.Secret) {
+		t.Fatalf("expected token hash to be stored, got %v", stored[1])
+	}
+	for _, v := range stored {
+		if v == tok.Secret {
+			t.Fatal("plaintext token stored")
+		}
+	}
+}
@@ -1,17 +1,97 @@
 package watchers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 	auth "github.com/mark3748/helpdesk-go/cmd/api/auth"
 	"github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/cmd/api/requesters"
 )
 
+// maxBulk caps how many watchers and CCs a single request may add.
+const maxBulk = 50
+
 type watcherReq struct {
-	UserID string `json:"user_id" binding:"required"`
+	UserID  string   `json:"user_id"`
+	UserIDs []string `json:"user_ids"`
+	Emails  []string `json:"emails"`
+}
+
+// Added reports what an Add call did. Emails that match a user become
+// watchers; the rest are CC'd.
+type Added struct {
+	Watchers []string `json:"watchers"`
+	CCs      []string `json:"ccs"`
+}
+
+// CC is an external address copied on a ticket.
+type CC struct {
+	Email       string    `json:"email"`
+	RequesterID *string   `json:"requester_id,omitempty"`
+	AddedAt     time.Time `json:"added_at"`
+}
+
+func actorID(c *gin.Context) string {
+	if v, ok := c.Get("user"); ok {
+		if u, ok := v.(auth.AuthUser); ok {
+			return u.ID
+		}
+	}
+	return ""
+}
+
+// normalizeEmails lowercases, validates and de-duplicates addresses. It
+// returns the first invalid address when there is one.
+func normalizeEmails(in []string) ([]string, string) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, e := range in {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if !requesters.ValidEmail(e) {
+			return nil, e
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out, ""
+}
+
+func uniqueIDs(in ...string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, id := range in {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// addCC copies email on the ticket, creating a requester contact for it when
+// none exists.
+func addCC(ctx context.Context, db app.DB, ticketID, email, actor string) error {
+	_, err := db.Exec(ctx, `
+        with r as (
+            insert into requesters (email) values ($2)
+            on conflict (email) do update set email = excluded.email
+            returning id)
+        insert into ticket_ccs (ticket_id, email, requester_id, added_by)
+        select $1, $2, r.id, nullif($3,'')::uuid from r
+        on conflict (ticket_id, email) do nothing`, ticketID, email, actor)
+	return err
 }
 
 // List returns watcher IDs for the specified ticket.
@@ -40,7 +120,8 @@ func List(a *app.App) gin.HandlerFunc {
 	}
 }
 
-// Add inserts a watcher for the ticket.
+// Add adds watchers to the ticket. It accepts a single user_id, a list of
+// user_ids and a list of emails; emails without a user account are CC'd.
 func Add(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in watcherReq
@@ -48,20 +129,68 @@ func Add(a *app.App) gin.HandlerFunc {
 			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"user_id": "required"})
 			return
 		}
-		if a.DB != nil {
-			ctx := c.Request.Context()
-			ticketID := c.Param("id")
-			if _, err := a.DB.Exec(ctx, `insert into ticket_watchers (ticket_id, user_id) values ($1,$2) on conflict do nothing`, ticketID, in.UserID); err != nil {
-				app.AbortError(c, http.StatusInternalServerError, "db_exec_failed", "database exec failed", nil)
-				return
+		ids := uniqueIDs(append(in.UserIDs, in.UserID)...)
+		emails, bad := normalizeEmails(in.Emails)
+		if bad != "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid email", map[string]string{"emails": "invalid address: " + bad})
+			return
+		}
+		if len(ids)+len(emails) == 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"user_id": "required"})
+			return
+		}
+		if len(ids)+len(emails) > maxBulk {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "too many watchers", map[string]string{"user_ids": fmt.Sprintf("at most %d per request", maxBulk)})
+			return
+		}
+		out := Added{Watchers: ids, CCs: []string{}}
+		if a.DB == nil {
+			out.CCs = emails
+			c.JSON(http.StatusCreated, out)
+			return
+		}
+		ctx := c.Request.Context()
+		ticketID := c.Param("id")
+		actor := actorID(c)
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			for _, id := range ids {
+				if _, err := tx.Exec(ctx, `insert into ticket_watchers (ticket_id, user_id) values ($1,$2) on conflict do nothing`, ticketID, id); err != nil {
+					return err
+				}
 			}
-			if v, ok := c.Get("user"); ok {
-				if u, ok := v.(auth.AuthUser); ok {
-					events.Emit(ctx, a.DB, ticketID, "watcher_add", gin.H{"user_id": in.UserID, "actor_id": u.ID})
+			for _, e := range emails {
+				var uid string
+				err := tx.QueryRow(ctx, `select id::text from users where lower(email) = $1`, e).Scan(&uid)
+				switch {
+				case err == nil:
+					if _, err := tx.Exec(ctx, `insert into ticket_watchers (ticket_id, user_id) values ($1,$2) on conflict do nothing`, ticketID, uid); err != nil {
+						return err
+					}
+					out.Watchers = append(out.Watchers, uid)
+				case errors.Is(err, pgx.ErrNoRows):
+					if err := addCC(ctx, tx, ticketID, e, actor); err != nil {
+						return err
+					}
+					out.CCs = append(out.CCs, e)
+				default:
+					return err
 				}
 			}
+			return nil
+		})
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_exec_failed", "database exec failed", nil)
+			return
+		}
+		if actor != "" {
+			for _, uid := range out.Watchers {
+				events.Emit(ctx, a.DB, auth.Actor(c), ticketID, "watcher_add", gin.H{"user_id": uid, "actor_id": actor})
+			}
+			for _, e := range out.CCs {
+				events.Emit(ctx, a.DB, auth.Actor(c), ticketID, "cc_add", gin.H{"email": e, "actor_id": actor})
+			}
 		}
-		c.Status(http.StatusCreated)
+		c.JSON(http.StatusCreated, out)
 	}
 }
 
@@ -78,10 +207,102 @@ func Remove(a *app.App) gin.HandlerFunc {
 			}
 			if v, ok := c.Get("user"); ok {
 				if u, ok := v.(auth.AuthUser); ok {
-					events.Emit(ctx, a.DB, ticketID, "watcher_remove", gin.H{"user_id": watcherID, "actor_id": u.ID})
+					events.Emit(ctx, a.DB, auth.Actor(c), ticketID, "watcher_remove", gin.H{"user_id": watcherID, "actor_id": u.ID})
 				}
 			}
 		}
 		c.JSON(http.StatusOK, gin.H{"ok": true})
 	}
 }
+
+// ListCCs returns the addresses CC'd on the ticket.
+func ListCCs(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			c.JSON(http.StatusOK, []CC{})
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select email, requester_id::text, added_at from ticket_ccs where ticket_id=$1 order by added_at`, c.Param("id"))
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_query_failed", "database query failed", nil)
+			return
+		}
+		defer rows.Close()
+		out := []CC{}
+		for rows.Next() {
+			var cc CC
+			if err := rows.Scan(&cc.Email, &cc.RequesterID, &cc.AddedAt); err == nil {
+				out = append(out, cc)
+			}
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// AddCCs copies external addresses on the ticket. Each address gets a
+// requester contact if it has none and receives public comments by email.
+func AddCCs(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Emails []string `json:"emails"`
+		}
+		if err := c.ShouldBindJSON(&in); err != nil || len(in.Emails) == 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"emails": "required"})
+			return
+		}
+		emails, bad := normalizeEmails(in.Emails)
+		if bad != "" {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid email", map[string]string{"emails": "invalid address: " + bad})
+			return
+		}
+		if len(emails) > maxBulk {
+			app.AbortError(c, http.StatusBadRequest, "invalid_body", "too many addresses", map[string]string{"emails": fmt.Sprintf("at most %d per request", maxBulk)})
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusCreated, Added{Watchers: []string{}, CCs: emails})
+			return
+		}
+		ctx := c.Request.Context()
+		ticketID := c.Param("id")
+		actor := actorID(c)
+		err := app.InTx(ctx, a.DB, func(tx app.DB) error {
+			for _, e := range emails {
+				if err := addCC(ctx, tx, ticketID, e, actor); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_exec_failed", "database exec failed", nil)
+			return
+		}
+		if actor != "" {
+			for _, e := range emails {
+				events.Emit(ctx, a.DB, auth.Actor(c), ticketID, "cc_add", gin.H{"email": e, "actor_id": actor})
+			}
+		}
+		c.JSON(http.StatusCreated, Added{Watchers: []string{}, CCs: emails})
+	}
+}
+
+// RemoveCC stops copying an address on the ticket. The requester contact is
+// kept.
+func RemoveCC(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB != nil {
+			ctx := c.Request.Context()
+			ticketID := c.Param("id")
+			email := strings.ToLower(strings.TrimSpace(c.Param("email")))
+			if _, err := a.DB.Exec(ctx, `delete from ticket_ccs where ticket_id=$1 and email=$2`, ticketID, email); err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_exec_failed", "database exec failed", nil)
+				return
+			}
+			if actor := actorID(c); actor != "" {
+				events.Emit(ctx, a.DB, auth.Actor(c), ticketID, "cc_remove", gin.H{"email": email, "actor_id": actor})
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+}
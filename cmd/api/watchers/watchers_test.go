@@ -15,6 +15,7 @@ import (
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
 	authpkg "github.com/mark3748/helpdesk-go/cmd/api/auth"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 type fakeDB struct{ watchers map[string]map[string]bool }
@@ -147,3 +148,61 @@ func TestWatcherHandlers(t *testing.T) {
 		t.Fatalf("expected empty, got %v", out)
 	}
 }
+
+func TestAddBulkAndEmails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var watchers, ccs []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] != "agent@example.com" {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(*string) = "u3"
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "into ticket_watchers"):
+				watchers = append(watchers, args[1].(string))
+			case strings.Contains(sql, "into ticket_ccs"):
+				if !strings.Contains(sql, "into requesters") {
+					t.Fatalf("cc must create a requester contact: %s", sql)
+				}
+				ccs = append(ccs, args[1].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/tickets/:id/watchers", authpkg.Middleware(a), Add(a))
+
+	body := `{"user_ids":["u1","u2","u1"],"emails":["Agent@Example.com","guest@example.com"]}`
+	rr := httptest.NewRecorder()
+	a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/1/watchers", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var out Added
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(out.Watchers, ",") != "u1,u2,u3" || strings.Join(watchers, ",") != "u1,u2,u3" {
+		t.Fatalf("unexpected watchers: %v (stored %v)", out.Watchers, watchers)
+	}
+	if len(out.CCs) != 1 || out.CCs[0] != "guest@example.com" || len(ccs) != 1 {
+		t.Fatalf("unexpected ccs: %v (stored %v)", out.CCs, ccs)
+	}
+
+	for name, body := range map[string]string{
+		"empty":         `{}`,
+		"invalid email": `{"emails":["not-an-email"]}`,
+	} {
+		rr := httptest.NewRecorder()
+		a.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tickets/1/watchers", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+}
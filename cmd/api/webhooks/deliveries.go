@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+const (
+	deliveriesDefaultLimit = 50
+	deliveriesMaxLimit     = 200
+)
+
+// Delivery is one recorded attempt to deliver a payload to a subscription.
+// NextRetryAt is set on failed attempts the worker will repeat.
+type Delivery struct {
+	ID              string          `json:"id"`
+	WebhookID       string          `json:"webhook_id"`
+	Event           string          `json:"event"`
+	Payload         json.RawMessage `json:"payload"`
+	IsTest          bool            `json:"is_test"`
+	Attempt         int             `json:"attempt"`
+	RetryOf         *string         `json:"retry_of"`
+	StatusCode      *int            `json:"status_code"`
+	LatencyMS       int64           `json:"latency_ms"`
+	ResponseExcerpt string          `json:"response_excerpt"`
+	Error           *string         `json:"error"`
+	OK              bool            `json:"ok"`
+	NextRetryAt     *time.Time      `json:"next_retry_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+const deliveryCols = `id::text, webhook_id::text, event, payload::text, is_test, attempt, retry_of::text,
+    status_code, latency_ms, response_excerpt, error, next_retry_at, created_at`
+
+func scanDelivery(scan func(...any) error) (Delivery, error) {
+	var d Delivery
+	var payload string
+	err := scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.IsTest, &d.Attempt, &d.RetryOf,
+		&d.StatusCode, &d.LatencyMS, &d.ResponseExcerpt, &d.Error, &d.NextRetryAt, &d.CreatedAt)
+	d.Payload = json.RawMessage(payload)
+	d.OK = d.Error == nil && d.StatusCode != nil && *d.StatusCode >= 200 && *d.StatusCode < 300
+	return d, err
+}
+
+// deliver sends payload to the subscription and records the attempt. The
+// attempt is returned even if recording it fails, since the request has
+// already reached the endpoint.
+func deliver(c *gin.Context, a *app.App, d Delivery, targetURL, secret string) Delivery {
+	ctx := c.Request.Context()
+	now := time.Now()
+	res := webhook.Send(ctx, nil, targetURL, secret, d.Event, d.ID, d.Payload, now)
+	d.LatencyMS = res.LatencyMS
+	d.ResponseExcerpt = res.Excerpt
+	d.OK = res.OK()
+	d.CreatedAt = now
+	if res.Error != "" {
+		d.Error = &res.Error
+	} else {
+		d.StatusCode = &res.StatusCode
+	}
+	if _, err := a.DB.Exec(ctx, `insert into webhook_deliveries
+        (id, webhook_id, event, payload, is_test, attempt, retry_of, status_code, latency_ms, response_excerpt, error, created_at)
+        values ($1,$2,$3,$4::jsonb,$5,$6,$7,$8,$9,$10,$11,$12)`,
+		d.ID, d.WebhookID, d.Event, string(d.Payload), d.IsTest, d.Attempt, d.RetryOf,
+		d.StatusCode, d.LatencyMS, d.ResponseExcerpt, d.Error, d.CreatedAt); err != nil {
+		log.Warn().Err(err).Str("webhook_id", d.WebhookID).Str("delivery_id", d.ID).Msg("record webhook delivery")
+	}
+	return d
+}
+
+// Test sends a signed sample payload for the requested event type to the
+// subscription and returns the endpoint's response. Inactive subscriptions
+// can be tested too, so an endpoint can be checked before it is enabled.
+func Test(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var in struct {
+			Event string `json:"event"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&in); err != nil {
+				app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", nil)
+				return
+			}
+		}
+		if in.Event == "" {
+			in.Event = webhook.Events[0].Name
+		}
+		if _, ok := webhook.Lookup(in.Event); !ok {
+			app.AbortError(c, http.StatusBadRequest, "invalid_event", "unknown event type", map[string]string{"event": "must be one of " + strings.Join(webhook.Names(), ", ")})
+			return
+		}
+		if a.DB == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "db_unavailable", "database unavailable", nil)
+			return
+		}
+		id := c.Param("id")
+		var targetURL, secret string
+		err := a.DB.QueryRow(c.Request.Context(), `select target_url, coalesce(secret,'') from webhooks where id=$1`, id).Scan(&targetURL, &secret)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "webhook not found", nil)
+			return
+		} else if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_query_failed", "database query failed", nil)
+			return
+		}
+		d := Delivery{ID: uuid.NewString(), WebhookID: id, Event: in.Event, IsTest: true, Attempt: 1}
+		payload, err := webhook.Sample(in.Event, d.ID, time.Now())
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "payload_failed", err.Error(), nil)
+			return
+		}
+		d.Payload = payload
+		c.JSON(http.StatusOK, deliver(c, a, d, targetURL, secret))
+	}
+}
+
+// Deliveries returns the most recent delivery attempts for a subscription,
+// newest first.
+func Deliveries(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := deliveriesDefaultLimit
+		if v := c.Query("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				app.AbortError(c, http.StatusBadRequest, "invalid_request", "limit must be a positive integer", nil)
+				return
+			}
+			limit = min(n, deliveriesMaxLimit)
+		}
+		out := []Delivery{}
+		if a.DB == nil {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+		rows, err := a.DB.Query(c.Request.Context(), `select `+deliveryCols+` from webhook_deliveries
+            where webhook_id=$1 order by created_at desc limit $2`, c.Param("id"), limit)
+		if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_query_failed", "database query failed", nil)
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			d, err := scanDelivery(rows.Scan)
+			if err != nil {
+				app.AbortError(c, http.StatusInternalServerError, "db_scan_failed", "database scan failed", nil)
+				return
+			}
+			out = append(out, d)
+		}
+		c.JSON(http.StatusOK, out)
+	}
+}
+
+// Retry re-sends the stored payload of an earlier delivery to the
+// subscription's current URL and secret. The payload, including its id, is
+// sent unchanged so receivers can de-duplicate; the delivery header carries
+// the new attempt's id.
+func Retry(a *app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.DB == nil {
+			app.AbortError(c, http.StatusServiceUnavailable, "db_unavailable", "database unavailable", nil)
+			return
+		}
+		prevID := c.Param("delivery_id")
+		var prev Delivery
+		var payload, targetURL, secret string
+		err := a.DB.QueryRow(c.Request.Context(), `select d.event, d.payload::text, d.is_test, d.attempt, w.target_url, coalesce(w.secret,'')
+            from webhook_deliveries d join webhooks w on w.id = d.webhook_id
+            where d.id=$1 and d.webhook_id=$2`, prevID, c.Param("id")).
+			Scan(&prev.Event, &payload, &prev.IsTest, &prev.Attempt, &targetURL, &secret)
+		if errors.Is(err, pgx.ErrNoRows) {
+			app.AbortError(c, http.StatusNotFound, "not_found", "delivery not found", nil)
+			return
+		} else if err != nil {
+			app.AbortError(c, http.StatusInternalServerError, "db_query_failed", "database query failed", nil)
+			return
+		}
+		d := Delivery{
+			ID:        uuid.NewString(),
+			WebhookID: c.Param("id"),
+			Event:     prev.Event,
+			Payload:   json.RawMessage(payload),
+			IsTest:    prev.IsTest,
+			Attempt:   prev.Attempt + 1,
+			RetryOf:   &prevID,
+		}
+		c.JSON(http.StatusOK, deliver(c, a, d, targetURL, secret))
+	}
+}
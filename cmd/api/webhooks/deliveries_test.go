@@ -0,0 +1,112 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+func TestWebhookTestAndRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var received []*http.Request
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received, bodies = append(received, r), append(bodies, b)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	defer srv.Close()
+
+	var stored []Delivery
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...interface{}) error {
+				switch {
+				case strings.Contains(sql, "from webhooks where id"):
+					if args[0] != "h1" {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string) = srv.URL
+					*dest[1].(*string) = "s3cret"
+				case strings.Contains(sql, "from webhook_deliveries d join webhooks"):
+					for _, d := range stored {
+						if d.ID == args[0] {
+							*dest[0].(*string) = d.Event
+							*dest[1].(*string) = string(d.Payload)
+							*dest[2].(*bool) = d.IsTest
+							*dest[3].(*int) = d.Attempt
+							*dest[4].(*string) = srv.URL
+							*dest[5].(*string) = "s3cret"
+							return nil
+						}
+					}
+					return pgx.ErrNoRows
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "insert into webhook_deliveries") {
+				stored = append(stored, Delivery{ID: args[0].(string), Event: args[2].(string), Payload: json.RawMessage(args[3].(string)), IsTest: args[4].(bool), Attempt: args[5].(int)})
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	a.R.POST("/admin/webhooks/:id/test", Test(a))
+	a.R.POST("/admin/webhooks/:id/deliveries/:delivery_id/retry", Retry(a))
+	post := func(path, body string) (*httptest.ResponseRecorder, Delivery) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		var d Delivery
+		_ = json.Unmarshal(rr.Body.Bytes(), &d)
+		return rr, d
+	}
+
+	rr, d := post("/admin/webhooks/h1/test", `{"event":"ticket.resolved"}`)
+	if rr.Code != http.StatusOK || d.StatusCode == nil || *d.StatusCode != http.StatusTeapot || d.OK || d.ResponseExcerpt != "short and stout" {
+		t.Fatalf("unexpected test result %d %s", rr.Code, rr.Body.String())
+	}
+	if len(received) != 1 || received[0].Header.Get(webhook.HeaderEvent) != "ticket.resolved" {
+		t.Fatalf("expected one ticket.resolved delivery, got %d", len(received))
+	}
+	ts, _ := strconv.ParseInt(received[0].Header.Get(webhook.HeaderTimestamp), 10, 64)
+	if !webhook.Verify("s3cret", ts, bodies[0], received[0].Header.Get(webhook.HeaderSignature)) {
+		t.Fatal("expected a valid signature")
+	}
+	if len(stored) != 1 || !stored[0].IsTest || stored[0].ID != d.ID {
+		t.Fatalf("expected the attempt to be recorded, got %+v", stored)
+	}
+
+	rr, retry := post("/admin/webhooks/h1/deliveries/"+d.ID+"/retry", "")
+	if rr.Code != http.StatusOK || retry.Attempt != 2 || retry.RetryOf == nil || *retry.RetryOf != d.ID {
+		t.Fatalf("unexpected retry %d %s", rr.Code, rr.Body.String())
+	}
+	if !bytes.Equal(bodies[0], bodies[1]) || received[1].Header.Get(webhook.HeaderDelivery) != retry.ID {
+		t.Fatal("expected the stored payload to be re-sent under a new delivery id")
+	}
+
+	if rr, _ := post("/admin/webhooks/h1/test", `{"event":"ticket.exploded"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown event, got %d", rr.Code)
+	}
+	if rr, _ := post("/admin/webhooks/missing/test", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown webhook, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	"github.com/mark3748/helpdesk-go/cmd/api/metrics"
+	requesterspkg "github.com/mark3748/helpdesk-go/cmd/api/requesters"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+)
+
+// PhoneInboundReq is a call reported by a telephony integration. From is
+// the caller ID, empty when it was withheld.
+type PhoneInboundReq struct {
+	CallID          string `json:"call_id" binding:"required"`
+	From            string `json:"from"`
+	CallerName      string `json:"caller_name"`
+	DurationSeconds *int   `json:"duration_seconds" binding:"omitempty,min=0"`
+	RecordingURL    string `json:"recording_url"`
+	RecordingMime   string `json:"recording_mime"`
+	Summary         string `json:"summary"`
+}
+
+// PhoneCall is the ticket raised for a call. Duplicate is true when the
+// call was reported before.
+type PhoneCall struct {
+	TicketID    string `json:"ticket_id"`
+	Number      string `json:"number"`
+	RequesterID string `json:"requester_id,omitempty"`
+	Duplicate   bool   `json:"duplicate"`
+}
+
+// CallDurationField is the custom field that holds a phone ticket's call
+// duration in seconds.
+const CallDurationField = "call_duration_seconds"
+
+// phoneSeparators are stripped from caller IDs before they are validated.
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+var recordingExt = regexp.MustCompile(`^\.[a-z0-9]{1,5}$`)
+
+// recordingTypes covers the formats telephony providers record in, which
+// the system MIME table may not know.
+var recordingTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".m4a":  "audio/mp4",
+	".webm": "audio/webm",
+}
+
+// PhoneInbound raises a ticket with source "phone" for a call reported by a
+// telephony integration, which authenticates with PHONE_INTAKE_TOKEN as a
+// bearer token. The caller is matched to a requester by phone number, or
+// recorded as a new one; the recording is linked as an internal attachment
+// that stays with the provider. A call_id seen before answers with its
+// ticket, so providers can retry. Responds 501 while no token is set.
+func PhoneInbound(a *apppkg.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := a.Cfg.PhoneIntakeToken
+		if token == "" {
+			apppkg.AbortError(c, http.StatusNotImplemented, "phone_intake_disabled", "phone intake is not enabled", nil)
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			metrics.AuthFailuresTotal.Inc()
+			apppkg.AbortError(c, http.StatusUnauthorized, "invalid_token", "invalid token", nil)
+			return
+		}
+		var in PhoneInboundReq
+		if err := c.ShouldBindJSON(&in); err != nil {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "invalid json", nil)
+			return
+		}
+		errs := map[string]string{}
+		in.CallID = strings.TrimSpace(in.CallID)
+		if in.CallID == "" {
+			errs["call_id"] = "required"
+		}
+		in.From = phoneSeparators.Replace(strings.TrimSpace(in.From))
+		if in.From != "" && !requesterspkg.ValidPhone(in.From) {
+			errs["from"] = "invalid"
+		}
+		var recording *url.URL
+		if in.RecordingURL != "" {
+			u, err := url.Parse(in.RecordingURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs["recording_url"] = "must be an absolute http or https URL"
+			}
+			recording = u
+		}
+		if len(errs) > 0 {
+			apppkg.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
+		if a.DB == nil {
+			c.JSON(http.StatusCreated, PhoneCall{})
+			return
+		}
+		ctx := c.Request.Context()
+		if out, ok, err := reportedCall(ctx, a.DB, in.CallID); err != nil {
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to load call", nil)
+			return
+		} else if ok {
+			c.JSON(http.StatusOK, out)
+			return
+		}
+
+		var out PhoneCall
+		if in.From != "" {
+			id, err := callerRequester(ctx, a.DB, in.From, strings.TrimSpace(in.CallerName))
+			if err != nil {
+				apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to match caller", nil)
+				return
+			}
+			out.RequesterID = id
+		}
+		caller := strings.TrimSpace(in.CallerName)
+		if caller == "" {
+			caller = in.From
+		}
+		if caller == "" {
+			caller = "unknown caller"
+		}
+		custom := map[string]any{}
+		if in.DurationSeconds != nil {
+			custom[CallDurationField] = *in.DurationSeconds
+		}
+		customJSON, _ := json.Marshal(custom)
+		act := actor.System("phone_intake")
+		err := apppkg.InTx(ctx, a.DB, func(tx apppkg.DB) error {
+			if err := tx.QueryRow(ctx, `insert into tickets (number, title, description, requester_id, priority, status, source, custom_json)
+                values ('HD-'||nextval('ticket_seq'), $1, $2, nullif($3,'')::uuid, 3, 'New', 'phone', $4::jsonb)
+                returning id::text, number`, "Phone call from "+caller, in.Summary, out.RequesterID, string(customJSON)).Scan(&out.TicketID, &out.Number); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `insert into ticket_status_history (ticket_id, to_status) values ($1, 'New')`, out.TicketID); err != nil {
+				return err
+			}
+			if recording != nil {
+				name, mt := recordingName(recording, in.RecordingMime)
+				if _, err := tx.Exec(ctx, `insert into attachments (ticket_id, object_key, filename, bytes, mime, is_internal, external_url)
+                    values ($1, '', $2, 0, nullif($3,''), true, $4)`, out.TicketID, name, mt, in.RecordingURL); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.Exec(ctx, `insert into phone_calls (call_id, ticket_id, caller, duration_seconds, recording_url)
+                values ($1, $2, nullif($3,''), $4, nullif($5,''))`, in.CallID, out.TicketID, in.From, in.DurationSeconds, in.RecordingURL); err != nil {
+				return err
+			}
+			eventspkg.Emit(ctx, tx, act, out.TicketID, "ticket_created", map[string]any{"id": out.TicketID, "call_id": in.CallID})
+			return nil
+		})
+		if err != nil {
+			// A retry that raced this delivery raised the ticket first.
+			var pge *pgconn.PgError
+			if errors.As(err, &pge) && pge.Code == "23505" {
+				if dup, ok, err := reportedCall(ctx, a.DB, in.CallID); err == nil && ok {
+					c.JSON(http.StatusOK, dup)
+					return
+				}
+			}
+			apppkg.AbortError(c, http.StatusInternalServerError, "db_error", "failed to raise ticket", nil)
+			return
+		}
+		c.JSON(http.StatusCreated, out)
+	}
+}
+
+// reportedCall returns the ticket raised for callID, if any.
+func reportedCall(ctx context.Context, db apppkg.DB, callID string) (PhoneCall, bool, error) {
+	out := PhoneCall{Duplicate: true}
+	err := db.QueryRow(ctx, `select t.id::text, t.number, coalesce(t.requester_id::text, '')
+        from phone_calls pc join tickets t on t.id = pc.ticket_id where pc.call_id = $1`, callID).Scan(&out.TicketID, &out.Number, &out.RequesterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PhoneCall{}, false, nil
+	}
+	if err != nil {
+		return PhoneCall{}, false, err
+	}
+	return out, true, nil
+}
+
+// callerRequester returns the requester whose phone number has the digits
+// of from, the oldest when several do, creating one named name otherwise.
+func callerRequester(ctx context.Context, db apppkg.DB, from, name string) (string, error) {
+	digits := strings.TrimPrefix(from, "+")
+	var id string
+	err := db.QueryRow(ctx, `select id::text from requesters
+        where regexp_replace(coalesce(phone, ''), '[^0-9]', '', 'g') = $1 order by created_at, id limit 1`, digits).Scan(&id)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return id, err
+	}
+	err = db.QueryRow(ctx, `insert into requesters (phone, name) values ($1, nullif($2,'')) returning id::text`, from, name).Scan(&id)
+	return id, err
+}
+
+// recordingName names a recording attachment after the extension of its
+// URL, and takes its type from declared or else that extension.
+func recordingName(u *url.URL, declared string) (name, mimeType string) {
+	name = "call-recording"
+	ext := strings.ToLower(path.Ext(u.Path))
+	if recordingExt.MatchString(ext) {
+		name += ext
+	}
+	mimeType = strings.TrimSpace(declared)
+	if mimeType == "" {
+		mimeType = recordingTypes[ext]
+	}
+	if mimeType == "" && recordingExt.MatchString(ext) {
+		mimeType, _, _ = mime.ParseMediaType(mime.TypeByExtension(ext))
+	}
+	return name, mimeType
+}
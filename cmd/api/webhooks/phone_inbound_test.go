@@ -0,0 +1,122 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestPhoneInbound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const requester = "11111111-1111-1111-1111-111111111111"
+	calls := map[string]string{}
+	var matched, ticketArgs, attachmentArgs []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from phone_calls"):
+					id, ok := calls[args[0].(string)]
+					if !ok {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = id, "HD-7", requester
+				case strings.Contains(sql, "from requesters"):
+					matched = args
+					*dest[0].(*string) = requester
+				case strings.Contains(sql, "insert into tickets"):
+					ticketArgs = args
+					*dest[0].(*string), *dest[1].(*string) = "t1", "HD-7"
+				default:
+					t.Fatalf("unexpected query: %s", sql)
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "insert into attachments"):
+				attachmentArgs = args
+			case strings.Contains(sql, "insert into phone_calls"):
+				calls[args[0].(string)] = args[1].(string)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", PhoneIntakeToken: "s3cret"}, db, nil, nil, nil)
+	a.R.POST("/webhooks/phone-inbound", PhoneInbound(a))
+	post := func(token, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/phone-inbound", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		a.R.ServeHTTP(rr, req)
+		return rr
+	}
+
+	const call = `{"call_id":"CA1","from":"+1 (555) 010-4477","caller_name":"Ada","duration_seconds":184,
+		"recording_url":"https://voice.example.com/rec/CA1.mp3","summary":"Printer on fire"}`
+	for _, tc := range []struct {
+		token, body string
+		code        int
+	}{
+		{"", call, http.StatusUnauthorized},
+		{"wrong", call, http.StatusUnauthorized},
+		{"s3cret", `{"call_id":"CA1","from":"call me"}`, http.StatusBadRequest},
+		{"s3cret", `{"call_id":"CA1","recording_url":"file:///etc/passwd"}`, http.StatusBadRequest},
+	} {
+		if rr := post(tc.token, tc.body); rr.Code != tc.code {
+			t.Fatalf("%q %s: expected %d, got %d %s", tc.token, tc.body, tc.code, rr.Code, rr.Body.String())
+		}
+	}
+	if ticketArgs != nil {
+		t.Fatal("a refused call raised a ticket")
+	}
+
+	for i, want := range []PhoneCall{
+		{TicketID: "t1", Number: "HD-7", RequesterID: requester},
+		{TicketID: "t1", Number: "HD-7", RequesterID: requester, Duplicate: true},
+	} {
+		ticketArgs = nil
+		rr := post("s3cret", call)
+		var got PhoneCall
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("delivery %d: got %d %+v, want %+v", i, rr.Code, got, want)
+		}
+		if want.Duplicate != (ticketArgs == nil) {
+			t.Fatalf("delivery %d: duplicate %v but ticket inserted = %v", i, want.Duplicate, ticketArgs != nil)
+		}
+		if !want.Duplicate && (ticketArgs[0] != "Phone call from Ada" || ticketArgs[3] != `{"call_duration_seconds":184}`) {
+			t.Fatalf("ticket raised with %v", ticketArgs)
+		}
+	}
+	if matched[0] != "15550104477" {
+		t.Fatalf("caller matched by %v", matched)
+	}
+	if attachmentArgs[1] != "call-recording.mp3" || attachmentArgs[2] != "audio/mpeg" || attachmentArgs[3] != "https://voice.example.com/rec/CA1.mp3" {
+		t.Fatalf("recording attached as %v", attachmentArgs)
+	}
+
+	off := apppkg.NewApp(apppkg.Config{Env: "test"}, db, nil, nil, nil)
+	off.R.POST("/webhooks/phone-inbound", PhoneInbound(off))
+	rr := httptest.NewRecorder()
+	off.R.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/webhooks/phone-inbound", strings.NewReader(call)))
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a token, got %d", rr.Code)
+	}
+}
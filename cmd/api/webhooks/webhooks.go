@@ -2,17 +2,39 @@ package webhooks
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 )
 
+// webhookReq selects events by event_mask, by name in events, or both.
 type webhookReq struct {
-	TargetURL string `json:"target_url" binding:"required"`
-	EventMask int    `json:"event_mask"`
-	Secret    string `json:"secret"`
-	Active    bool   `json:"active"`
+	TargetURL string   `json:"target_url" binding:"required"`
+	EventMask int      `json:"event_mask"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret"`
+	Active    bool     `json:"active"`
+}
+
+// check folds events into EventMask and validates the request.
+func (in *webhookReq) check() map[string]string {
+	errs := map[string]string{}
+	if u, err := url.Parse(in.TargetURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs["target_url"] = "must be an absolute http or https URL"
+	}
+	mask, err := webhook.Mask(in.Events)
+	if err != nil {
+		errs["events"] = "must be among " + strings.Join(webhook.Names(), ", ")
+	}
+	in.EventMask |= mask
+	if in.EventMask <= 0 {
+		errs["event_mask"] = "select at least one event"
+	}
+	return errs
 }
 
 // List returns all webhook subscriptions.
@@ -39,6 +61,7 @@ func List(a *app.App) gin.HandlerFunc {
 					"id":         id,
 					"target_url": url,
 					"event_mask": mask,
+					"events":     webhook.Selected(mask),
 					"secret":     secret,
 					"active":     active,
 				})
@@ -48,7 +71,8 @@ func List(a *app.App) gin.HandlerFunc {
 	}
 }
 
-// Create inserts a new webhook subscription.
+// Create inserts a new webhook subscription. The worker delivers the
+// selected events to it while it is active.
 func Create(a *app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var in webhookReq
@@ -56,6 +80,10 @@ func Create(a *app.App) gin.HandlerFunc {
 			app.AbortError(c, http.StatusBadRequest, "invalid_body", "invalid request body", map[string]string{"target_url": "required"})
 			return
 		}
+		if errs := in.check(); len(errs) > 0 {
+			app.AbortError(c, http.StatusBadRequest, "invalid_request", "validation error", errs)
+			return
+		}
 		if a.DB != nil {
 			ctx := c.Request.Context()
 			if _, err := a.DB.Exec(ctx, `insert into webhooks (target_url, event_mask, secret, active) values ($1,$2,$3,$4)`, in.TargetURL, in.EventMask, in.Secret, in.Active); err != nil {
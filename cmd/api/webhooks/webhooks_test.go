@@ -139,3 +139,33 @@ func TestWebhookHandlers(t *testing.T) {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
 }
+
+func TestWebhookCreateValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := &fakeDB{}
+	a := apppkg.NewApp(apppkg.Config{Env: "test", TestBypassAuth: true}, db, nil, nil, nil)
+	a.R.POST("/webhooks", authpkg.Middleware(a), Create(a))
+	for _, tc := range []struct {
+		body, field string
+	}{
+		{`{"target_url":"ftp://example.com","event_mask":1}`, "target_url"},
+		{`{"target_url":"https://example.com","events":["ticket.exploded"]}`, "events"},
+		{`{"target_url":"https://example.com"}`, "event_mask"},
+	} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		a.R.ServeHTTP(rr, req)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), tc.field) {
+			t.Fatalf("%s: expected 400 on %s, got %d %s", tc.body, tc.field, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"target_url":"https://example.com","event_mask":1,"events":["sla.breached"],"active":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	a.R.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || db.hooks["1"].EventMask != 33 {
+		t.Fatalf("expected events folded into the mask, got %d %+v", rr.Code, db.hooks)
+	}
+}
@@ -3,7 +3,11 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
@@ -21,7 +25,32 @@ var wsClients = prometheus.NewGauge(prometheus.GaugeOpts{
 	Help: "Number of connected WebSocket clients",
 })
 
-func init() { prometheus.MustRegister(wsClients) }
+var wsRejectedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ws_connections_rejected_total",
+	Help: "Number of websocket connections refused because the per-user limit was reached.",
+})
+
+var wsIdleClosedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "ws_connections_idle_closed_total",
+	Help: "Number of websocket connections closed after missing heartbeats.",
+})
+
+func init() { prometheus.MustRegister(wsClients, wsRejectedTotal, wsIdleClosedTotal) }
+
+// Limits bounds websocket connections. Zero values disable the corresponding
+// check.
+type Limits struct {
+	// MaxPerUser caps concurrent connections for a single user.
+	MaxPerUser int
+	// Heartbeat is the interval between server pings.
+	Heartbeat time.Duration
+	// IdleTimeout closes a connection that has not answered a ping or sent a
+	// message for this long. It should exceed Heartbeat.
+	IdleTimeout time.Duration
+}
+
+// DefaultLimits is used by hubs that are not configured explicitly.
+var DefaultLimits = Limits{MaxPerUser: 5, Heartbeat: 30 * time.Second, IdleTimeout: 90 * time.Second}
 
 // PublishEvent sends an event to the Redis "events" channel.
 func PublishEvent(ctx context.Context, rdb *redis.Client, ev Event) {
@@ -42,6 +71,10 @@ type Hub struct {
 	unregister chan *Client
 	clients    map[*Client]bool
 	broadcast  chan Event
+
+	limits  Limits
+	mu      sync.Mutex
+	perUser map[string]int
 }
 
 // NewHub constructs a Hub. rdb may be nil to disable cross-process broadcasting.
@@ -52,9 +85,42 @@ func NewHub(rdb *redis.Client) *Hub {
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
 		broadcast:  make(chan Event, 16),
+		limits:     DefaultLimits,
+		perUser:    make(map[string]int),
 	}
 }
 
+// SetLimits replaces the hub's connection limits. Call before serving.
+func (h *Hub) SetLimits(l Limits) { h.limits = l }
+
+// Limits returns the hub's connection limits.
+func (h *Hub) Limits() Limits { return h.limits }
+
+// Acquire reserves a connection slot for userID, reporting false when the
+// user is already at the limit. Each successful Acquire must be paired with
+// Release.
+func (h *Hub) Acquire(userID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.limits.MaxPerUser > 0 && h.perUser[userID] >= h.limits.MaxPerUser {
+		wsRejectedTotal.Inc()
+		return false
+	}
+	h.perUser[userID]++
+	return true
+}
+
+// Release frees a slot taken by Acquire.
+func (h *Hub) Release(userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.perUser[userID] <= 1 {
+		delete(h.perUser, userID)
+		return
+	}
+	h.perUser[userID]--
+}
+
 // Run starts the hub loop, optionally subscribing to Redis events.
 func (h *Hub) Run(ctx context.Context) {
 	var ch <-chan *redis.Message
@@ -119,26 +185,51 @@ func NewClient(h *Hub, conn *websocket.Conn, isAdmin bool) *Client {
 	return &Client{hub: h, conn: conn, send: make(chan Event, 8), isAdmin: isAdmin}
 }
 
-// ReadPump reads messages from the WebSocket to detect disconnects.
+// ReadPump reads messages from the WebSocket to detect disconnects. With an
+// idle timeout configured, the connection is closed when neither a message
+// nor a pong arrives in time, which reclaims abandoned tabs and dead peers.
 func (c *Client) ReadPump() {
 	defer func() {
 		c.hub.unregister <- c
 		_ = c.conn.Close()
 	}()
+	idle := c.hub.limits.IdleTimeout
+	extend := func() {
+		if idle > 0 {
+			_ = c.conn.SetReadDeadline(time.Now().Add(idle))
+		}
+	}
+	extend()
+	c.conn.SetPongHandler(func(string) error { extend(); return nil })
 	for {
 		if _, _, err := c.conn.ReadMessage(); err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				wsIdleClosedTotal.Inc()
+			}
 			break
 		}
+		extend()
 	}
 }
 
 // WritePump writes events to the WebSocket connection.
 func (c *Client) WritePump(ctx context.Context) {
 	defer func() { _ = c.conn.Close() }()
+	var heartbeat <-chan time.Time
+	if hb := c.hub.limits.Heartbeat; hb > 0 {
+		t := time.NewTicker(hb)
+		defer t.Stop()
+		heartbeat = t.C
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-heartbeat:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
 		case ev, ok := <-c.send:
 			if !ok {
 				return
@@ -157,5 +248,8 @@ func (c *Client) WritePump(ctx context.Context) {
 	}
 }
 
+// writeWait bounds how long a heartbeat ping may take to write.
+const writeWait = 10 * time.Second
+
 // Websocket upgrader with permissive CORS (expected to be protected by middleware).
 var Upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
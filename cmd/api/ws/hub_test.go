@@ -3,9 +3,14 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -33,3 +38,58 @@ func TestPublishEvent(t *testing.T) {
 		t.Fatalf("want %s got %s", ev.Type, got.Type)
 	}
 }
+
+func TestHubAcquireLimit(t *testing.T) {
+	h := NewHub(nil)
+	h.SetLimits(Limits{MaxPerUser: 2})
+	if !h.Acquire("u1") || !h.Acquire("u1") {
+		t.Fatal("expected two slots")
+	}
+	if h.Acquire("u1") {
+		t.Fatal("expected third connection to be refused")
+	}
+	if !h.Acquire("u2") {
+		t.Fatal("limit must be per user")
+	}
+	h.Release("u1")
+	if !h.Acquire("u1") {
+		t.Fatal("expected slot after release")
+	}
+}
+
+func TestIdleConnectionClosed(t *testing.T) {
+	h := NewHub(nil)
+	h.SetLimits(Limits{Heartbeat: 20 * time.Millisecond, IdleTimeout: 60 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.Run(ctx)
+
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := NewClient(h, conn, false)
+		h.Register(c)
+		wctx, wcancel := context.WithCancel(ctx)
+		go c.WritePump(wctx)
+		c.ReadPump()
+		wcancel()
+		close(done)
+	}))
+	defer srv.Close()
+
+	// The client never reads, so it never answers pings.
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected idle connection to be closed")
+	}
+}
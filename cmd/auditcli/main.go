@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
 )
 
 func main() {
@@ -24,11 +25,8 @@ func main() {
 	switch os.Args[1] {
 	case "run":
 		jobID := uuid.New().String()
-		jb, _ := json.Marshal(struct {
-			ID   string `json:"id"`
-			Type string `json:"type"`
-		}{jobID, "audit_export"})
-		_ = rdb.RPush(ctx, "jobs", jb).Err()
+		jb, _ := jobs.Encode(jobID, jobs.TypeAuditExport, nil)
+		_ = rdb.RPush(ctx, jobs.QueueFor(jobs.TypeAuditExport), jb).Err()
 		fmt.Println(jobID)
 	case "status":
 		if len(os.Args) < 3 {
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// DB is what the generator needs; pgx pools satisfy it.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Options configure one generator run.
+type Options struct {
+	// RunID tags every generated ticket (custom_json.loadgen and the title)
+	// so a run can be purged.
+	RunID string
+	// Tickets is how many tickets to create, each with Comments comments
+	// and Attachments attachment rows.
+	Tickets     int
+	Comments    int
+	Attachments int
+	// Rate caps tickets created per second; 0 inserts as fast as the
+	// database allows.
+	Rate float64
+	// Batch is how many tickets one statement inserts.
+	Batch int
+	// Requesters is how many synthetic requesters the tickets spread over.
+	Requesters int
+	// Spread backdates created_at uniformly over this window so paging and
+	// SLA clocks see realistic ages.
+	Spread time.Duration
+}
+
+// pools are the existing rows generated tickets reference.
+type pools struct {
+	requesters, agents, teams, queues []string
+}
+
+// loadPools creates the synthetic requesters and loads the agents, teams and
+// queues tickets are spread over. At least one active agent must exist to
+// author comments and own attachments.
+func loadPools(ctx context.Context, db DB, o Options) (pools, error) {
+	var p pools
+	if _, err := db.Exec(ctx, `insert into requesters (email, name)
+        select format('loadgen-%s@example.test', g), format('Load Test %s', g) from generate_series(1, $1::int) g
+        on conflict (email) do nothing`, o.Requesters); err != nil {
+		return p, fmt.Errorf("create requesters: %w", err)
+	}
+	err := db.QueryRow(ctx, `select
+        array(select id::text from requesters where email like 'loadgen-%@example.test' order by email limit $1),
+        array(select u.id::text from users u join user_roles ur on ur.user_id = u.id
+              join roles r on r.id = ur.role_id and r.name = 'agent' where u.active order by u.id limit 200),
+        array(select id::text from teams order by id),
+        array(select id::text from queues order by id)`, o.Requesters).Scan(&p.requesters, &p.agents, &p.teams, &p.queues)
+	if err != nil {
+		return p, fmt.Errorf("load pools: %w", err)
+	}
+	if len(p.agents) == 0 {
+		return p, errors.New("no active agents; create one to author generated comments")
+	}
+	if len(p.requesters) == 0 {
+		return p, errors.New("no loadgen requesters")
+	}
+	return p, nil
+}
+
+// insertBatch creates tickets first..first+n-1 of the run in one statement:
+// the tickets spread round-robin over the pools and through every status,
+// their comments alternating between requester and assignee (every fourth
+// internal), attachment rows, status history, and SLA clocks for priorities
+// with a policy, running for New and Open tickets. Attachment rows have no
+// object behind them.
+const insertBatch = `with ins as (
+    insert into tickets (number, title, description, requester_id, assignee_id, team_id, queue_id, priority, status, source, custom_json, created_at, updated_at)
+    select 'HD-'||nextval('ticket_seq'),
+        format('[loadgen %s] %s #%s', $1::text, (array['Printer offline', 'VPN keeps dropping', 'Password reset', 'Laptop running slow', 'Email bouncing', 'Access request'])[1 + g % 6], g),
+        format('Generated by loadgen run %s, ticket %s. The user reports the problem started this morning and has tried restarting.', $1::text, g),
+        ($4::uuid[])[1 + g % cardinality($4::uuid[])],
+        case when g % 5 <> 0 then ($5::uuid[])[1 + g % cardinality($5::uuid[])] end,
+        ($6::uuid[])[1 + g % greatest(cardinality($6::uuid[]), 1)],
+        ($7::uuid[])[1 + g % greatest(cardinality($7::uuid[]), 1)],
+        1 + g % 4,
+        (array['New', 'Open', 'Open', 'Pending', 'Resolved', 'Closed'])[1 + g % 6],
+        'web', jsonb_build_object('loadgen', $1::text), x.at, x.at
+    from (select g, now() - random() * make_interval(secs => $10::float8) as at
+          from generate_series($2::int, $2::int + $3::int - 1) g) x
+    returning id, requester_id, assignee_id, priority, status, created_at
+), comments as (
+    insert into ticket_comments (ticket_id, author_id, author_requester_id, body_md, is_internal, created_at)
+    select ins.id,
+        case when n % 2 = 0 then coalesce(ins.assignee_id, ($5::uuid[])[1]) end,
+        case when n % 2 = 1 then ins.requester_id end,
+        format('Generated comment %s. Any update on this?', n), n % 4 = 0,
+        least(ins.created_at + n * interval '10 minutes', now())
+    from ins cross join generate_series(1, $8::int) n
+    returning 1
+), files as (
+    insert into attachments (ticket_id, uploader_id, object_key, filename, bytes, mime)
+    select ins.id, coalesce(ins.assignee_id, ($5::uuid[])[1]), format('loadgen/%s/%s/%s.log', $1::text, ins.id, n),
+        format('diagnostics-%s.log', n), 1024 * n, 'text/plain'
+    from ins cross join generate_series(1, $9::int) n
+    returning 1
+), history as (
+    insert into ticket_status_history (ticket_id, to_status, at)
+    select id, status, created_at from ins
+    returning 1
+), clocks as (
+    insert into ticket_sla_clocks (ticket_id, policy_id, last_started_at)
+    select ins.id, p.id, case when ins.status in ('New', 'Open') then ins.created_at end
+    from ins cross join lateral (select id from sla_policies where priority = ins.priority order by created_at limit 1) p
+    returning 1
+)
+select (select count(*) from ins), (select count(*) from comments), (select count(*) from files)`
+
+// Stats counts what a run created.
+type Stats struct {
+	Tickets, Comments, Attachments int64
+}
+
+// Generate creates o.Tickets tickets in batches, pacing batches to o.Rate.
+// It stops early, returning what was created, when ctx is cancelled.
+func Generate(ctx context.Context, db DB, o Options, progress func(Stats)) (Stats, error) {
+	var st Stats
+	p, err := loadPools(ctx, db, o)
+	if err != nil {
+		return st, err
+	}
+	start := time.Now()
+	for done := 0; done < o.Tickets; {
+		n := min(o.Batch, o.Tickets-done)
+		var t, c, a int64
+		if err := db.QueryRow(ctx, insertBatch, o.RunID, done+1, n, p.requesters, p.agents, p.teams, p.queues,
+			o.Comments, o.Attachments, o.Spread.Seconds()).Scan(&t, &c, &a); err != nil {
+			return st, fmt.Errorf("insert batch at %d: %w", done+1, err)
+		}
+		done += n
+		st.Tickets += t
+		st.Comments += c
+		st.Attachments += a
+		if progress != nil {
+			progress(st)
+		}
+		if o.Rate > 0 && done < o.Tickets {
+			next := start.Add(time.Duration(float64(done) / o.Rate * float64(time.Second)))
+			select {
+			case <-ctx.Done():
+				return st, ctx.Err()
+			case <-time.After(time.Until(next)):
+			}
+		}
+	}
+	return st, nil
+}
+
+// purgeBatch bounds how many tickets one purge statement deletes.
+const purgeBatch = 1000
+
+// Purge deletes the tickets of run, and with them their comments,
+// attachment rows, history and SLA clocks, and reports how many.
+func Purge(ctx context.Context, db DB, run string) (int64, error) {
+	var total int64
+	for {
+		tag, err := db.Exec(ctx, `delete from tickets where id in
+            (select id from tickets where custom_json->>'loadgen' = $1 limit $2)`, run, purgeBatch)
+		if err != nil {
+			return total, err
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < purgeBatch {
+			return total, nil
+		}
+		log.Debug().Int64("deleted", total).Msg("purging")
+	}
+}
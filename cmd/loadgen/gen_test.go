@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestCheckEnv(t *testing.T) {
+	env := map[string]string{}
+	get := func(k string) string { return env[k] }
+	if err := checkEnv(get); !errors.Is(err, errNotTestEnv) {
+		t.Fatalf("expected loadgen to refuse without ENV, got %v", err)
+	}
+	env["ENV"] = "prod"
+	if err := checkEnv(get); !errors.Is(err, errNotTestEnv) {
+		t.Fatalf("expected loadgen to refuse in prod, got %v", err)
+	}
+	env["ENV"] = "test"
+	if err := checkEnv(get); err != nil {
+		t.Fatalf("checkEnv: %v", err)
+	}
+}
+
+func TestGenerateBatchesAndPaces(t *testing.T) {
+	var batches [][2]int
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.HasPrefix(sql, "select") {
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*[]string) = []string{"r1", "r2"}
+					*dest[1].(*[]string) = []string{"a1"}
+					return nil
+				}}
+			}
+			batches = append(batches, [2]int{args[1].(int), args[2].(int)})
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int64) = int64(args[2].(int))
+				*dest[1].(*int64) = int64(args[2].(int) * args[7].(int))
+				*dest[2].(*int64) = 0
+				return nil
+			}}
+		},
+	}
+	o := Options{RunID: "r", Tickets: 25, Comments: 2, Batch: 10, Requesters: 2, Rate: 500}
+	start := time.Now()
+	st, err := Generate(context.Background(), db, o, nil)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(batches) != 3 || batches[2] != [2]int{21, 5} {
+		t.Fatalf("unexpected batches %v", batches)
+	}
+	if st.Tickets != 25 || st.Comments != 50 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	// 20 tickets at 500/s must take at least 40ms before the last batch.
+	if took := time.Since(start); took < 40*time.Millisecond {
+		t.Fatalf("expected batches paced to the rate, took %v", took)
+	}
+}
+
+func TestGenerateNeedsAgents(t *testing.T) {
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*[]string) = []string{"r1"}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if _, err := Generate(context.Background(), db, Options{Tickets: 1, Batch: 1, Requesters: 1}, nil); err == nil || !strings.Contains(err.Error(), "no active agents") {
+		t.Fatalf("expected a missing agent error, got %v", err)
+	}
+}
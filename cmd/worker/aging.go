@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// agingActor attributes audit events raised by the aging loop.
+var agingActor = actor.System("aging")
+
+// agingBatch caps how many tickets one aging pass looks at.
+const agingBatch = 500
+
+// Aging stages recorded in tickets.aging_stage.
+const (
+	agingFresh     = 0
+	agingReminded  = 1
+	agingEscalated = 2
+)
+
+// agingStage returns the stage a ticket idle for idle has reached under a
+// queue's rule. escalate may be nil when the queue only sends reminders.
+func agingStage(idle time.Duration, remind int, escalate *int) int {
+	switch {
+	case escalate != nil && idle >= time.Duration(*escalate)*time.Hour:
+		return agingEscalated
+	case idle >= time.Duration(remind)*time.Hour:
+		return agingReminded
+	}
+	return agingFresh
+}
+
+// agedTicket is an open ticket in a queue with an aging rule.
+type agedTicket struct {
+	id, number, title string
+	assigneeID        *string
+	leadID            *string
+	teamMembers       []string
+	calendarID        string
+	remind            int
+	escalate          *int
+	stage             int
+	lastActivity      time.Time
+}
+
+// ageTickets applies the queues' aging rules. A New or Open ticket whose
+// last agent activity (a comment or ticket event by an agent, or its
+// creation) is remind hours old in its team's business hours is bumped to
+// the top of views and its assignee, or its team when unassigned, is
+// reminded. Past escalate hours it is bumped again and escalated to the team
+// lead. Each step happens once; agent activity afterwards starts the ticket
+// over. Without a calendar idle time is wall-clock time.
+func ageTickets(ctx context.Context, db app.DB) error {
+	rows, err := db.Query(ctx, `
+      select t.id::text, t.number, t.title, t.assignee_id::text, tm.lead_id::text,
+             array(select m.user_id::text from team_members m where m.team_id = t.team_id),
+             coalesce(coalesce(tm.calendar_id, r.calendar_id)::text, ''),
+             q.aging_remind_hours, q.aging_escalate_hours,
+             case when t.aged_at is null or act.at > t.aged_at then 0 else t.aging_stage end,
+             act.at
+      from tickets t
+      join queues q on q.id = t.queue_id and q.aging_remind_hours is not null
+      left join teams tm on tm.id = t.team_id
+      left join regions r on r.id = tm.region_id
+      cross join lateral (select greatest(t.created_at,
+          (select max(e.created_at) from ticket_events e where e.ticket_id = t.id and e.actor_id in
+              (select ur.user_id from user_roles ur join roles ro on ro.id = ur.role_id and ro.name in ('agent', 'manager', 'admin'))),
+          (select max(c.created_at) from ticket_comments c where c.ticket_id = t.id and c.author_id in
+              (select ur.user_id from user_roles ur join roles ro on ro.id = ur.role_id and ro.name in ('agent', 'manager', 'admin')))
+      ) as at) act
+      where t.status in ('New', 'Open')
+        and (t.aging_stage < 2 or t.aged_at is null or act.at > t.aged_at)
+      order by act.at
+      limit $1`, agingBatch)
+	if err != nil {
+		return err
+	}
+	var tickets []agedTicket
+	for rows.Next() {
+		var t agedTicket
+		if err := rows.Scan(&t.id, &t.number, &t.title, &t.assigneeID, &t.leadID, &t.teamMembers, &t.calendarID,
+			&t.remind, &t.escalate, &t.stage, &t.lastActivity); err != nil {
+			rows.Close()
+			return err
+		}
+		tickets = append(tickets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	calendars := map[string]*sla.Calendar{}
+	aged := 0
+	for _, t := range tickets {
+		idle := now.Sub(t.lastActivity)
+		if t.calendarID != "" {
+			cal, ok := calendars[t.calendarID]
+			if !ok {
+				cal, err = sla.LoadCalendar(ctx, db, t.calendarID)
+				if err != nil {
+					log.Error().Err(err).Str("calendar", t.calendarID).Msg("load calendar")
+					continue
+				}
+				calendars[t.calendarID] = cal
+			}
+			idle = cal.BusinessDuration(t.lastActivity, now)
+		}
+		stage := agingStage(idle, t.remind, t.escalate)
+		if stage <= t.stage {
+			continue
+		}
+		if err := ageTicket(ctx, db, t, stage, idle); err != nil {
+			log.Error().Err(err).Str("ticket", t.id).Msg("age ticket")
+			continue
+		}
+		aged++
+	}
+	if aged > 0 {
+		log.Info().Int("tickets", aged).Msg("idle tickets reminded or escalated")
+	}
+	return nil
+}
+
+// ageTicket moves t to stage: it bumps the ticket's updated_at so it sorts
+// to the top of views, audits the step and notifies whoever the stage
+// reaches on their ticket_aging channels.
+func ageTicket(ctx context.Context, db app.DB, t agedTicket, stage int, idle time.Duration) error {
+	if _, err := db.Exec(ctx, `update tickets set aging_stage = $2, aged_at = now(), updated_at = now() where id::text = $1`, t.id, stage); err != nil {
+		return err
+	}
+	hours := int(idle / time.Hour)
+	action, users := "ticket_aging_reminder", []string{}
+	m := notify.Message{
+		Event:    notify.EventTicketAging,
+		Title:    fmt.Sprintf("Idle ticket: %s", t.number),
+		Body:     fmt.Sprintf("%s has had no agent activity for %d business hours: %s", t.number, hours, t.title),
+		TicketID: t.id,
+	}
+	switch {
+	case t.assigneeID != nil:
+		users = append(users, *t.assigneeID)
+	case stage == agingReminded:
+		users = t.teamMembers
+	}
+	if stage == agingEscalated {
+		action = "ticket_aging_escalated"
+		m.Title = fmt.Sprintf("Escalated: %s idle %d hours", t.number, hours)
+		m.Urgent = true
+		if t.leadID != nil && (t.assigneeID == nil || *t.leadID != *t.assigneeID) {
+			users = append(users, *t.leadID)
+		}
+	}
+	if err := audit.RecordDiff(ctx, db, agingActor, "ticket", t.id, action, map[string]any{
+		"idle_hours": hours, "lead_id": t.leadID, "notified": len(users),
+	}); err != nil {
+		log.Error().Err(err).Str("ticket", t.id).Msg("record ticket aging")
+	}
+	_, err := notify.Dispatch(ctx, db, users, m)
+	return err
+}
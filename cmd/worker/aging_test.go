@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestAgingStage(t *testing.T) {
+	escalate := 24
+	cases := []struct {
+		idle     time.Duration
+		escalate *int
+		want     int
+	}{
+		{7 * time.Hour, &escalate, agingFresh},
+		{8 * time.Hour, &escalate, agingReminded},
+		{30 * time.Hour, &escalate, agingEscalated},
+		{30 * time.Hour, nil, agingReminded},
+	}
+	for _, tc := range cases {
+		if got := agingStage(tc.idle, 8, tc.escalate); got != tc.want {
+			t.Errorf("agingStage(%v, 8, %v) = %d, want %d", tc.idle, tc.escalate, got, tc.want)
+		}
+	}
+}
+
+func TestAgeTickets(t *testing.T) {
+	lead, assignee := "lead-1", "agent-1"
+	escalate := 24
+	type ticket struct {
+		id       string
+		assignee *string
+		stage    int
+		idle     time.Duration
+	}
+	tickets := []ticket{
+		{"t-fresh", &assignee, agingFresh, 2 * time.Hour},
+		{"t-remind", nil, agingFresh, 10 * time.Hour},
+		{"t-escalate", &assignee, agingReminded, 30 * time.Hour},
+		{"t-done", &assignee, agingReminded, 12 * time.Hour},
+	}
+	var bumped []string
+	var audited []string
+	var paged [][]string
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "notification_channels") {
+				paged = append(paged, args[0].([]string))
+				return &testutil.MockRows{}, nil
+			}
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(tickets) },
+				ScanFunc: func(dest ...any) error {
+					tk := tickets[i-1]
+					*dest[0].(*string) = tk.id
+					*dest[1].(*string) = "HD-" + tk.id
+					*dest[2].(*string) = "Printer"
+					*dest[3].(**string) = tk.assignee
+					*dest[4].(**string) = &lead
+					*dest[5].(*[]string) = []string{"agent-1", "agent-2"}
+					*dest[6].(*string) = ""
+					*dest[7].(*int) = 8
+					*dest[8].(**int) = &escalate
+					*dest[9].(*int) = tk.stage
+					*dest[10].(*time.Time) = time.Now().Add(-tk.idle)
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "update tickets set aging_stage"):
+				bumped = append(bumped, args[0].(string))
+			case strings.Contains(sql, "audit_events"):
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := ageTickets(context.Background(), db); err != nil {
+		t.Fatalf("ageTickets: %v", err)
+	}
+	if strings.Join(bumped, ",") != "t-remind,t-escalate" {
+		t.Fatalf("unexpected tickets bumped %v", bumped)
+	}
+	if strings.Join(audited, ",") != "ticket_aging_reminder,ticket_aging_escalated" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+	if len(paged) != 2 || len(paged[0]) != 2 || strings.Join(paged[1], ",") != "agent-1,lead-1" {
+		t.Fatalf("expected the team to be reminded and the lead paged, got %v", paged)
+	}
+}
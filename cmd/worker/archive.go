@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// ticketArchiveTTL is how long archive job statuses are kept.
+const ticketArchiveTTL = 7 * 24 * time.Hour
+
+type archiveComment struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Internal  bool      `json:"internal"`
+	CreatedAt time.Time `json:"created_at"`
+	Body      string    `json:"body_md"`
+}
+
+type archiveAttachment struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Bytes     int64     `json:"bytes"`
+	Mime      string    `json:"mime"`
+	Internal  bool      `json:"internal"`
+	CreatedAt time.Time `json:"created_at"`
+	// Path is the file's location in the archive, empty when it could not
+	// be packed.
+	Path string `json:"path"`
+	key  string
+}
+
+// ticketArchive is ticket.json.
+type ticketArchive struct {
+	Ticket      json.RawMessage     `json:"ticket"`
+	Comments    []archiveComment    `json:"comments"`
+	Attachments []archiveAttachment `json:"attachments"`
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// buildTicketArchive packs a ticket into a zip of ticket.json, the rendered
+// timeline as timeline.pdf and the attachment files, and uploads it,
+// returning the object key. Internal comments and attachments are only
+// included when j.Internal is set.
+func buildTicketArchive(ctx context.Context, c Config, db app.DB, store app.ObjectStore, j jobs.TicketArchive) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("object store not configured")
+	}
+	now := time.Now().UTC()
+	out := ticketArchive{Comments: []archiveComment{}, Attachments: []archiveAttachment{}, GeneratedAt: now}
+	var ticket []byte
+	if err := db.QueryRow(ctx, `select to_jsonb(t) || jsonb_build_object('requester', coalesce(rq.name, rq.email, ''),
+            'assignee', coalesce(au.display_name, au.email, ''), 'team', coalesce(tm.name, ''))
+        from tickets t
+        left join requesters rq on rq.id=t.requester_id
+        left join users au on au.id=t.assignee_id
+        left join teams tm on tm.id=t.team_id
+        where t.id=$1`, j.TicketID).Scan(&ticket); err != nil {
+		return "", fmt.Errorf("load ticket: %w", err)
+	}
+	out.Ticket = ticket
+
+	rows, err := db.Query(ctx, `select tc.id::text, coalesce(u.display_name, u.email, rq.name, rq.email, ''), tc.is_internal, tc.created_at, tc.body_md
+        from ticket_comments tc
+        left join users u on u.id=tc.author_id
+        left join requesters rq on rq.id=tc.author_requester_id
+        where tc.ticket_id=$1 and (not tc.is_internal or $2) order by tc.created_at`, j.TicketID, j.Internal)
+	if err != nil {
+		return "", fmt.Errorf("load comments: %w", err)
+	}
+	for rows.Next() {
+		var cm archiveComment
+		if err := rows.Scan(&cm.ID, &cm.Author, &cm.Internal, &cm.CreatedAt, &cm.Body); err != nil {
+			rows.Close()
+			return "", fmt.Errorf("load comments: %w", err)
+		}
+		out.Comments = append(out.Comments, cm)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("load comments: %w", err)
+	}
+
+	rows, err = db.Query(ctx, `select id::text, filename, bytes, coalesce(mime, ''), is_internal, created_at, object_key
+        from attachments where ticket_id=$1 and (not is_internal or $2) order by created_at`, j.TicketID, j.Internal)
+	if err != nil {
+		return "", fmt.Errorf("load attachments: %w", err)
+	}
+	for rows.Next() {
+		var at archiveAttachment
+		if err := rows.Scan(&at.ID, &at.Filename, &at.Bytes, &at.Mime, &at.Internal, &at.CreatedAt, &at.key); err != nil {
+			rows.Close()
+			return "", fmt.Errorf("load attachments: %w", err)
+		}
+		out.Attachments = append(out.Attachments, at)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("load attachments: %w", err)
+	}
+
+	timeline, _, err := ticketspkg.RenderPDF(ctx, db, j.TicketID, now)
+	if err != nil {
+		return "", fmt.Errorf("render timeline: %w", err)
+	}
+
+	f, err := os.CreateTemp("", "ticket-archive-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	var packed int64
+	for i, at := range out.Attachments {
+		if packed+at.Bytes > exportBundleMaxBytes {
+			continue
+		}
+		name := "attachments/" + at.ID + "-" + exportFilename(at.Filename)
+		if err := copyToZip(ctx, zw, store, c.MinIOBucket, exportAttachment{key: at.key, at: at.CreatedAt}, name); err != nil {
+			log.Warn().Err(err).Str("attachment_id", at.ID).Msg("archive attachment")
+			continue
+		}
+		out.Attachments[i].Path = name
+		packed += at.Bytes
+	}
+	w, err := zw.Create("timeline.pdf")
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(timeline); err != nil {
+		return "", err
+	}
+	if w, err = zw.Create("ticket.json"); err != nil {
+		return "", err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	loc := c.location(s3.ClassExports)
+	objectKey := loc.Key(uuid.New().String() + ".zip")
+	if _, err := store.PutObject(ctx, loc.Bucket, objectKey, f, size, minio.PutObjectOptions{ContentType: "application/zip"}); err != nil {
+		return "", err
+	}
+	return objectKey, nil
+}
+
+// handleTicketArchiveJob builds an archive and records the outcome under
+// ticket_archive:<ticket id>:<job id> for the API's status endpoint.
+func handleTicketArchiveJob(ctx context.Context, c Config, db app.DB, store app.ObjectStore, rdb *redis.Client, jobID string, j jobs.TicketArchive) {
+	objectKey, err := buildTicketArchive(ctx, c, db, store, j)
+	st := ExportStatus{Requester: j.Requester}
+	if err != nil {
+		st.Status = "error"
+		st.Error = err.Error()
+	} else {
+		st.Status = "done"
+		st.ObjectKey = objectKey
+	}
+	b, _ := json.Marshal(st)
+	if err := rdb.Set(ctx, "ticket_archive:"+j.TicketID+":"+jobID, b, ticketArchiveTTL).Err(); err != nil {
+		log.Error().Err(err).Msg("store ticket archive result")
+	}
+}
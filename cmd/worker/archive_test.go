@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// scanValues copies vals into pointer destinations of matching types.
+func scanValues(dest []any, vals []any) {
+	for i, v := range vals {
+		switch d := dest[i].(type) {
+		case *string:
+			*d = v.(string)
+		case *[]byte:
+			*d = v.([]byte)
+		case *int16:
+			*d = v.(int16)
+		case *int64:
+			*d = v.(int64)
+		case *bool:
+			*d = v.(bool)
+		case *time.Time:
+			*d = v.(time.Time)
+		}
+	}
+}
+
+func TestTicketArchiveJob(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	if _, err := store.PutObject(ctx, "bkt", "key1", strings.NewReader("log"), 3, minio.PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var internalArg any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "to_jsonb") {
+					scanValues(dest, []any{[]byte(`{"number":"TKT-9","title":"VPN down"}`)})
+				} else {
+					scanValues(dest, []any{"TKT-9", "VPN down", "", "Open", int16(2), "", "Ann", "Bob", "", at, at})
+				}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			var rows [][]any
+			switch {
+			case strings.Contains(sql, "tc.id::text"):
+				internalArg = args[1]
+				rows = [][]any{{"c1", "Ann", false, at, "Still down"}}
+			case strings.Contains(sql, "object_key"):
+				rows = [][]any{{"a1", "vpn.log", int64(3), "text/plain", false, at, "key1"}}
+			}
+			i := -1
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(rows) },
+				ScanFunc: func(dest ...any) error { scanValues(dest, rows[i]); return nil },
+			}, nil
+		},
+	}
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	j := jobs.TicketArchive{TicketID: "t9", Requester: "u1"}
+	handleTicketArchiveJob(ctx, Config{MinIOBucket: "bkt"}, db, store, rdb, "job1", j)
+	if internalArg != false {
+		t.Fatalf("internal content requested for a non-staff archive: %v", internalArg)
+	}
+	val, err := rdb.Get(ctx, "ticket_archive:t9:job1").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st ExportStatus
+	if err := json.Unmarshal([]byte(val), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != "done" || st.Requester != "u1" || mr.TTL("ticket_archive:t9:job1") <= 0 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	files := readZip(t, filepath.Join(store.Base, "bkt", st.ObjectKey))
+	if files["attachments/a1-vpn.log"] != "log" || !strings.HasPrefix(files["timeline.pdf"], "%PDF") {
+		t.Fatalf("unexpected archive files %v", files)
+	}
+	var doc ticketArchive
+	if err := json.Unmarshal([]byte(files["ticket.json"]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(doc.Ticket), `"TKT-9"`) || len(doc.Comments) != 1 || len(doc.Attachments) != 1 || doc.Attachments[0].Path != "attachments/a1-vpn.log" {
+		t.Fatalf("unexpected ticket.json %s", files["ticket.json"])
+	}
+}
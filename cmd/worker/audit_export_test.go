@@ -10,6 +10,7 @@ import (
 	miniredis "github.com/alicebob/miniredis/v2"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mark3748/helpdesk-go/internal/s3"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -63,7 +64,7 @@ func TestHandleAuditExportJob(t *testing.T) {
 
 	ev := auditEvent{ID: "1", ActorType: "user", ActorID: "u1", EntityType: "ticket", EntityID: "t1", Action: "create", At: time.Unix(0, 0)}
 	db := &auditDB{events: []auditEvent{ev}}
-	cfg := Config{AuditExportBucket: "bucket"}
+	cfg := Config{Storage: s3.Layout{s3.ClassAudit: {Bucket: "bucket"}}}
 
 	handleAuditExportJob(context.Background(), cfg, db, store, rdb, "job1")
 
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// resizeAvatar replaces an uploaded avatar with a square PNG. The user keeps
+// the original until the resized copy is in place; if they uploaded another
+// photo in the meantime the resized copy is discarded.
+func resizeAvatar(ctx context.Context, db app.DB, store app.ObjectStore, j jobs.ResizeAvatar) error {
+	if store == nil {
+		return fmt.Errorf("object store not configured")
+	}
+	rc, err := app.OpenObject(ctx, store, j.Bucket, j.Key)
+	if err != nil {
+		return fmt.Errorf("open original: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, avatars.MaxBytes+1))
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("read original: %w", err)
+	}
+	out, err := avatars.Resize(data, avatars.Size)
+	if err != nil {
+		return fmt.Errorf("resize: %w", err)
+	}
+	resized := avatars.ResizedKey(j.Key)
+	if _, err := store.PutObject(ctx, j.Bucket, resized, bytes.NewReader(out), int64(len(out)), minio.PutObjectOptions{ContentType: "image/png"}); err != nil {
+		return fmt.Errorf("store resized: %w", err)
+	}
+	tag, err := db.Exec(ctx, `update users set avatar_key=$3 where id=$1 and avatar_key=$2`, j.UserID, j.Key, resized)
+	if err != nil {
+		return fmt.Errorf("update avatar: %w", err)
+	}
+	stale := j.Key
+	if tag.RowsAffected() == 0 {
+		stale = resized
+	}
+	if err := store.RemoveObject(ctx, j.Bucket, stale, minio.RemoveObjectOptions{}); err != nil {
+		log.Warn().Err(err).Str("key", stale).Msg("remove avatar object")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/minio/minio-go/v7"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/avatars"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestResizeAvatar(t *testing.T) {
+	ctx := context.Background()
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatal(err)
+	}
+	key := avatars.OriginalKey("u1")
+	if _, err := store.PutObject(ctx, "bkt", key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, current := range []bool{true, false} {
+		var args []any
+		db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, a ...any) (pgconn.CommandTag, error) {
+			args = a
+			if !current {
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			}
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		}}
+		if err := resizeAvatar(ctx, db, store, jobs.ResizeAvatar{UserID: "u1", Bucket: "bkt", Key: key}); err != nil {
+			t.Fatalf("resize (current=%v): %v", current, err)
+		}
+		resized := avatars.ResizedKey(key)
+		if len(args) != 3 || args[2] != resized {
+			t.Fatalf("unexpected update args %v", args)
+		}
+		_, origErr := os.Stat(filepath.Join(store.Base, "bkt", key))
+		_, resizedErr := os.Stat(filepath.Join(store.Base, "bkt", resized))
+		if current {
+			if !os.IsNotExist(origErr) || resizedErr != nil {
+				t.Fatalf("expected original replaced by resized copy: %v %v", origErr, resizedErr)
+			}
+			// Restore the original for the superseded case.
+			if _, err := store.PutObject(ctx, "bkt", key, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{}); err != nil {
+				t.Fatal(err)
+			}
+		} else if origErr != nil || !os.IsNotExist(resizedErr) {
+			t.Fatalf("expected superseded resize discarded: %v %v", origErr, resizedErr)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// maxBroadcastErrors caps the per-ticket errors kept on a broadcast; the
+// failed counter keeps counting past it.
+const maxBroadcastErrors = 100
+
+// runTicketBroadcast applies a broadcast to its tickets in order, one
+// transaction each, updating the progress counters after every ticket. A
+// redelivered job resumes after the tickets already processed.
+func runTicketBroadcast(ctx context.Context, db app.DB, rdb *redis.Client, j jobs.TicketBroadcast) error {
+	b := ticketspkg.Broadcast{ID: j.BroadcastID}
+	var ids []string
+	var requestedBy *string
+	if err := db.QueryRow(ctx, `select requested_by::text, body_md, status_change, status, processed, ticket_ids::text[]
+        from ticket_broadcasts where id=$1`, j.BroadcastID).Scan(&requestedBy, &b.BodyMD, &b.StatusChange, &b.Status, &b.Processed, &ids); err != nil {
+		return fmt.Errorf("load broadcast: %w", err)
+	}
+	if b.Status == "completed" || b.Status == "failed" {
+		return nil
+	}
+	if _, err := db.Exec(ctx, `update ticket_broadcasts set status='running', started_at=coalesce(started_at, now()) where id=$1`, b.ID); err != nil {
+		return fmt.Errorf("start broadcast: %w", err)
+	}
+	act := actor.System("broadcast")
+	if requestedBy != nil {
+		act = actor.User(*requestedBy)
+	}
+	for _, ticketID := range ids[min(b.Processed, len(ids)):] {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var failure any
+		_, err := ticketspkg.ApplyBroadcast(ctx, db, act, j, b, ticketID)
+		if err != nil {
+			msg := "failed to update ticket"
+			if errors.Is(err, ticketspkg.ErrBroadcastTicketNotFound) || errors.Is(err, ticketspkg.ErrOpenChildren) {
+				msg = err.Error()
+			}
+			log.Warn().Err(err).Str("broadcast_id", b.ID).Str("ticket_id", ticketID).Msg("apply broadcast")
+			fb, _ := json.Marshal([]ticketspkg.BroadcastError{{TicketID: ticketID, Error: msg}})
+			failure = string(fb)
+		} else if rdb != nil {
+			nb, _ := jobs.Encode("", jobs.TypeDiscordOutgoingComment, jobs.DiscordComment{TicketID: ticketID, BodyMD: b.BodyMD})
+			if err := rdb.RPush(ctx, jobs.Queue, nb).Err(); err != nil {
+				log.Error().Err(err).Msg("enqueue discord comment job")
+			}
+		}
+		if _, err := db.Exec(ctx, `update ticket_broadcasts set processed = processed + 1,
+                succeeded = succeeded + case when $2::jsonb is null then 1 else 0 end,
+                failed = failed + case when $2::jsonb is null then 0 else 1 end,
+                errors = case when $2::jsonb is null or jsonb_array_length(errors) >= $3 then errors else errors || $2::jsonb end
+            where id=$1`, b.ID, failure, maxBroadcastErrors); err != nil {
+			return fmt.Errorf("record broadcast progress: %w", err)
+		}
+	}
+	if _, err := db.Exec(ctx, `update ticket_broadcasts set status='completed', finished_at=now() where id=$1`, b.ID); err != nil {
+		return fmt.Errorf("finish broadcast: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestTicketBroadcastJob(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	const (
+		done    = "00000000-0000-0000-0000-000000000001"
+		open    = "00000000-0000-0000-0000-000000000002"
+		deleted = "00000000-0000-0000-0000-000000000003"
+		agent   = "00000000-0000-0000-0000-0000000000aa"
+	)
+	var applied []string
+	var progress [][]any
+	var finished bool
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "from ticket_broadcasts"):
+					by, status := agent, "In Progress"
+					*dest[0].(**string) = &by
+					*dest[1].(*string) = "We're aware of the outage and working on it."
+					*dest[2].(**string) = &status
+					*dest[3].(*string) = "running"
+					*dest[4].(*int) = 1 // resumed after the first ticket
+					*dest[5].(*[]string) = []string{done, open, deleted}
+				case strings.Contains(sql, "from tickets where id=$1 for update"):
+					if args[0] == deleted {
+						return pgx.ErrNoRows
+					}
+					applied = append(applied, args[0].(string))
+					*dest[0].(*any), *dest[1].(*string) = "TKT-2", "Open"
+				case strings.Contains(sql, "insert into ticket_comments"):
+					if args[1] != agent {
+						t.Fatalf("comment not attributed to the requester: %v", args[1])
+					}
+					*dest[0].(*string) = "comment-1"
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "processed = processed + 1"):
+				progress = append(progress, args)
+			case strings.Contains(sql, "status='completed'"):
+				finished = true
+			}
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	if err := runTicketBroadcast(ctx, db, rdb, jobs.TicketBroadcast{BroadcastID: "b1"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0] != open {
+		t.Fatalf("expected only the unprocessed ticket to be updated, got %v", applied)
+	}
+	if len(progress) != 2 || progress[0][1] != nil || !strings.Contains(progress[1][1].(string), deleted) {
+		t.Fatalf("unexpected progress updates %v", progress)
+	}
+	if !finished {
+		t.Fatal("broadcast not marked completed")
+	}
+	if items, _ := mr.List(jobs.Queue); len(items) != 1 || !strings.Contains(items[0], open) {
+		t.Fatalf("expected one discord sync job, got %v", items)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+)
+
+// notifyProviders builds the notification channel providers from c.
+func notifyProviders(c Config) notify.Providers {
+	return notify.Providers{
+		Twilio: notify.Twilio{AccountSID: c.TwilioAccountSID, AuthToken: c.TwilioAuthToken, From: c.TwilioFrom},
+		Push:   notify.Push{Secret: c.PushWebhookSecret},
+		Ntfy:   notify.Ntfy{BaseURL: c.NtfyURL, Token: c.NtfyToken},
+	}
+}
+
+// sendChannelNotification delivers j over its channel and records the
+// outcome on the channel. Channels deleted or disabled since the job was
+// queued are skipped.
+func sendChannelNotification(ctx context.Context, db app.DB, providers notify.Providers, publicURL string, j jobs.ChannelNotify) error {
+	var kind, address string
+	var enabled bool
+	err := db.QueryRow(ctx, `select kind, address, enabled from notification_channels where id::text = $1`, j.ChannelID).Scan(&kind, &address, &enabled)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !enabled) {
+		log.Debug().Str("channel", j.ChannelID).Msg("notification channel gone or disabled; skipping")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load channel: %w", err)
+	}
+	m := notify.Message{Event: j.Event, Title: j.Title, Body: j.Body, URL: j.URL, TicketID: j.TicketID, Urgent: j.Urgent}
+	if m.URL == "" && m.TicketID != "" && publicURL != "" {
+		m.URL = strings.TrimSuffix(publicURL, "/") + "/tickets/" + m.TicketID
+	}
+	p, err := providers.For(kind)
+	if err == nil {
+		err = p.Send(ctx, address, m)
+	}
+	var errText *string
+	if err != nil {
+		s := err.Error()
+		errText = &s
+	}
+	if _, uerr := db.Exec(ctx, `update notification_channels set last_sent_at = case when $2::text is null then now() else last_sent_at end,
+            last_error = $2 where id::text = $1`, j.ChannelID, errText); uerr != nil {
+		log.Error().Err(uerr).Str("channel", j.ChannelID).Msg("record channel delivery")
+	}
+	return err
+}
+
+// pageSLABreach notifies the ticket's assignee, or every member of its team
+// when it is unassigned, on their channels subscribed to SLA breaches.
+func pageSLABreach(ctx context.Context, db app.DB, ticketID, target string, targetMS int64) error {
+	var number, title string
+	var users []string
+	err := db.QueryRow(ctx, `select t.number, t.title,
+            case when t.assignee_id is not null then array[t.assignee_id::text]
+                 else array(select tm.user_id::text from team_members tm where tm.team_id = t.team_id) end
+        from tickets t where t.id::text = $1`, ticketID).Scan(&number, &title, &users)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = notify.Dispatch(ctx, db, users, notify.Message{
+		Event:    notify.EventSLABreach,
+		Title:    fmt.Sprintf("SLA breach: %s %s target", number, target),
+		Body:     fmt.Sprintf("%s missed its %d minute %s target: %s", number, targetMS/60000, target, title),
+		TicketID: ticketID,
+		Urgent:   true,
+	})
+	return err
+}
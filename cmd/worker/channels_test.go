@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+)
+
+func TestSendChannelNotification(t *testing.T) {
+	var click, title string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		click, title = r.Header.Get("Click"), r.Header.Get("Title")
+	}))
+	defer srv.Close()
+	enabled := true
+	var recorded []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = notify.KindNtfy
+				*dest[1].(*string) = "oncall"
+				*dest[2].(*bool) = enabled
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			recorded = args
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	providers := notify.Providers{Ntfy: notify.Ntfy{BaseURL: srv.URL}}
+	j := jobs.ChannelNotify{ChannelID: "c1", Event: notify.EventSLABreach, Title: "SLA breach", TicketID: "t1", Urgent: true}
+	if err := sendChannelNotification(context.Background(), db, providers, "https://hd.example.com/", j); err != nil {
+		t.Fatalf("sendChannelNotification: %v", err)
+	}
+	if click != "https://hd.example.com/tickets/t1" || title != "SLA breach" {
+		t.Fatalf("unexpected delivery click=%q title=%q", click, title)
+	}
+	if len(recorded) != 2 || recorded[0] != "c1" || recorded[1].(*string) != nil {
+		t.Fatalf("expected a successful delivery to be recorded, got %v", recorded)
+	}
+
+	// SMS without Twilio credentials fails without retrying and the error
+	// is kept on the channel.
+	db.QueryRowFunc = func(ctx context.Context, sql string, args ...any) pgx.Row {
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+			*dest[0].(*string) = notify.KindSMS
+			*dest[1].(*string) = "+15551234567"
+			*dest[2].(*bool) = true
+			return nil
+		}}
+	}
+	err := sendChannelNotification(context.Background(), db, providers, "", j)
+	if err == nil || !strings.Contains(err.Error(), "not configured") {
+		t.Fatalf("expected a not configured error, got %v", err)
+	}
+	if e := recorded[1].(*string); e == nil || !strings.Contains(*e, "not configured") {
+		t.Fatalf("expected the error to be recorded, got %v", recorded)
+	}
+}
+
+func TestSendChannelNotificationSkipsDisabled(t *testing.T) {
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			t.Fatalf("unexpected exec %s", sql)
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := sendChannelNotification(context.Background(), db, notify.Providers{}, "", jobs.ChannelNotify{ChannelID: "gone"}); err != nil {
+		t.Fatalf("expected a deleted channel to be skipped, got %v", err)
+	}
+}
+
+func TestPageSLABreach(t *testing.T) {
+	var users []string
+	var queued []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "HD-7"
+				*dest[1].(*string) = "Mail is down"
+				*dest[2].(*[]string) = []string{"u1", "u2"}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			users = args[0].([]string)
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= 1 },
+				ScanFunc: func(dest ...any) error { *dest[0].(*string) = "c1"; return nil },
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			queued = append(queued, args[2].(string))
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := pageSLABreach(context.Background(), db, "t1", "response", 60*60*1000); err != nil {
+		t.Fatalf("pageSLABreach: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected the team to be paged, got %v", users)
+	}
+	if len(queued) != 1 || !strings.Contains(queued[0], `"title":"SLA breach: HD-7 response target"`) || !strings.Contains(queued[0], `"urgent":true`) {
+		t.Fatalf("unexpected queued jobs %v", queued)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// contractsActor attributes audit events raised by contract expiry warnings.
+var contractsActor = actor.System("contracts")
+
+// expiredContract is an ended support contract whose organization has an
+// account manager to warn.
+type expiredContract struct {
+	id, name, orgID, org string
+	endsOn               time.Time
+	managerID            string
+	managerEmail         *string
+}
+
+// warnExpiredContracts tells account managers, once per contract, that an
+// organization's support contract has ended: on their contract_expired
+// channels and by email. A contract superseded by a newer one the same
+// organization already has in force is not warned about.
+func warnExpiredContracts(ctx context.Context, db app.DB) error {
+	rows, err := db.Query(ctx, `
+      select c.id::text, c.name, o.id::text, o.name, c.ends_on, u.id::text, u.email
+      from support_contracts c
+      join organizations o on o.id = c.organization_id
+      join users u on u.id = o.account_manager_id
+      where c.ends_on < current_date and c.expiry_warned_at is null
+        and not exists (select 1 from support_contracts n where n.organization_id = c.organization_id
+                        and n.id <> c.id and n.starts_on <= current_date and n.ends_on >= current_date)
+      order by c.ends_on
+      limit 100`)
+	if err != nil {
+		return err
+	}
+	var expired []expiredContract
+	for rows.Next() {
+		var k expiredContract
+		if err := rows.Scan(&k.id, &k.name, &k.orgID, &k.org, &k.endsOn, &k.managerID, &k.managerEmail); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, k := range expired {
+		if err := warnExpiredContract(ctx, db, k); err != nil {
+			log.Error().Err(err).Str("contract", k.id).Msg("warn expired contract")
+		}
+	}
+	return nil
+}
+
+// warnExpiredContract claims k so no other worker warns about it, then
+// warns its account manager and audits the warning on the organization.
+func warnExpiredContract(ctx context.Context, db app.DB, k expiredContract) error {
+	tag, err := db.Exec(ctx, `update support_contracts set expiry_warned_at = now() where id::text = $1 and expiry_warned_at is null`, k.id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	endsOn := k.endsOn.Format(time.DateOnly)
+	if k.managerEmail != nil && *k.managerEmail != "" {
+		if err := outbox.AddJob(ctx, db, "contract_expired:"+k.id, "", jobs.TypeSendEmail, jobs.Email{
+			To:       *k.managerEmail,
+			Template: "contract_expired",
+			Data:     map[string]any{"Organization": k.org, "Contract": k.name, "EndsOn": endsOn},
+		}); err != nil {
+			log.Error().Err(err).Str("contract", k.id).Msg("queue contract expiry email")
+		}
+	}
+	if err := audit.RecordDiff(ctx, db, contractsActor, "organization", k.orgID, "contract_expired", map[string]any{
+		"contract_id": k.id, "ends_on": endsOn, "account_manager_id": k.managerID,
+	}); err != nil {
+		log.Error().Err(err).Str("contract", k.id).Msg("record contract expiry")
+	}
+	_, err = notify.Dispatch(ctx, db, []string{k.managerID}, notify.Message{
+		Event: notify.EventContractExpired,
+		Title: fmt.Sprintf("Contract expired: %s", k.org),
+		Body:  fmt.Sprintf("%s's support contract %q ended on %s.", k.org, k.name, endsOn),
+	})
+	return err
+}
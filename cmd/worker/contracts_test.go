@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestWarnExpiredContracts(t *testing.T) {
+	email := "am@example.com"
+	ids := []string{"k-1", "k-claimed"}
+	var emailed, audited []string
+	var paged [][]string
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "notification_channels") {
+				paged = append(paged, args[0].([]string))
+				return &testutil.MockRows{}, nil
+			}
+			if !strings.Contains(sql, "expiry_warned_at is null") {
+				t.Fatalf("unexpected query %s", sql)
+			}
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(ids) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = ids[i-1]
+					*dest[1].(*string) = "2026 Gold"
+					*dest[2].(*string) = "org-1"
+					*dest[3].(*string) = "Acme"
+					*dest[4].(*time.Time) = time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+					*dest[5].(*string) = "user-am"
+					*dest[6].(**string) = &email
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "set expiry_warned_at"):
+				// Another worker already warned about the second contract.
+				if args[0] == "k-claimed" {
+					return pgconn.NewCommandTag("UPDATE 0"), nil
+				}
+				return pgconn.NewCommandTag("UPDATE 1"), nil
+			case strings.Contains(sql, "into outbox"):
+				emailed = append(emailed, args[2].(string))
+			case strings.Contains(sql, "audit_events"):
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := warnExpiredContracts(context.Background(), db); err != nil {
+		t.Fatalf("warnExpiredContracts: %v", err)
+	}
+	if len(emailed) != 1 || !strings.Contains(emailed[0], `"template":"contract_expired"`) || !strings.Contains(emailed[0], email) {
+		t.Fatalf("expected one expiry email to the account manager, got %v", emailed)
+	}
+	if strings.Join(audited, ",") != "contract_expired" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+	if len(paged) != 1 || strings.Join(paged[0], ",") != "user-am" {
+		t.Fatalf("expected the account manager to be notified once, got %v", paged)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
@@ -21,6 +20,7 @@ import (
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
 )
 
 var dgSession atomic.Pointer[discordgo.Session]
@@ -426,7 +426,7 @@ func beginDiscordEmailLink(ctx context.Context, discordUserID, targetEmail strin
 		return fmt.Errorf("create Discord link challenge: %w", err)
 	}
 
-	jobData, err := json.Marshal(EmailJob{
+	job, err := jobs.Encode("", jobs.TypeSendEmail, EmailJob{
 		To:       email,
 		Template: "discord_link_verification",
 		Data: map[string]any{
@@ -434,14 +434,10 @@ func beginDiscordEmailLink(ctx context.Context, discordUserID, targetEmail strin
 			"ExpiresIn": fmt.Sprintf("%d minutes", discordLinkChallengeTTLMinutes),
 		},
 	})
-	if err != nil {
-		return fmt.Errorf("marshal verification email: %w", err)
-	}
-	job, err := json.Marshal(Job{Type: "send_email", Data: jobData})
 	if err != nil {
 		return fmt.Errorf("marshal verification email job: %w", err)
 	}
-	if err := rdb.RPush(ctx, "jobs", job).Err(); err != nil {
+	if err := rdb.RPush(ctx, jobs.Queue, job).Err(); err != nil {
 		_, _ = db.Exec(ctx, "update discord_link_challenges set consumed_at = now() where id = $1", challengeID)
 		return fmt.Errorf("enqueue verification email: %w", err)
 	}
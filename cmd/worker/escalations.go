@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	eventspkg "github.com/mark3748/helpdesk-go/cmd/api/events"
+	ticketspkg "github.com/mark3748/helpdesk-go/cmd/api/tickets"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/notify"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// slaEscalationRule is an enabled row of sla_escalation_rules.
+type slaEscalationRule struct {
+	id, name, target string
+	policyID         *string
+	pct              int
+	notifyAssignee   bool
+	notifyManager    bool
+	bumpPriority     bool
+	teamID           *string
+}
+
+// appliesTo reports whether the rule watches target on tickets of policyID.
+func (r slaEscalationRule) appliesTo(policyID, target string) bool {
+	return r.target == target && (r.policyID == nil || *r.policyID == policyID)
+}
+
+// threshold is the elapsed time at which the rule fires for targetMS.
+func (r slaEscalationRule) threshold(targetMS int64) int64 {
+	return targetMS * int64(r.pct) / 100
+}
+
+// loadEscalationRules returns the enabled SLA escalation rules, lowest
+// threshold first.
+func loadEscalationRules(ctx context.Context, db app.DB) ([]slaEscalationRule, error) {
+	rows, err := db.Query(ctx, `select id::text, name, target, policy_id::text, threshold_pct,
+            notify_assignee, notify_manager, bump_priority, escalate_team_id::text
+        from sla_escalation_rules where enabled order by threshold_pct, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []slaEscalationRule
+	for rows.Next() {
+		var r slaEscalationRule
+		if err := rows.Scan(&r.id, &r.name, &r.target, &r.policyID, &r.pct,
+			&r.notifyAssignee, &r.notifyManager, &r.bumpPriority, &r.teamID); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// escalationRetryMS is how far past a rule's threshold, in elapsed clock
+// time, the worker keeps trying to fire it. An escalation that failed is
+// retried on the following ticks; a rule created later than that does not
+// reach back to tickets that crossed its threshold long ago.
+const escalationRetryMS = int64(time.Hour / time.Millisecond)
+
+// due reports whether a clock at elapsedMS of targetMS is within the
+// window in which r fires. escalateSLA's claim makes repeat calls no-ops.
+func (r slaEscalationRule) due(elapsedMS, targetMS int64) bool {
+	at := r.threshold(targetMS)
+	return elapsedMS > at && elapsedMS-at <= escalationRetryMS
+}
+
+// escalatable reports whether escalation rules apply to a ticket in
+// status. Resolved and closed tickets, merged duplicates among them, and
+// paused tickets are left alone even when their clocks are still running.
+func escalatable(status string) bool {
+	return status != "Resolved" && status != "Closed" && !slices.Contains(ticketspkg.PausedStatuses, status)
+}
+
+// escalateSLA applies r to a ticket whose target clock has reached its
+// threshold. In one transaction it claims the rule for the ticket so it
+// fires once, raises the priority and moves the ticket to the escalation
+// team, unassigning it unless it was already there, as the rule asks,
+// audits the step, emits an sla_escalated ticket event and notifies the
+// assignee (or the team when unassigned), the team lead and the escalation
+// team, by email and on their sla_breach channels. Any failure rolls the
+// claim back so a later tick retries.
+func escalateSLA(ctx context.Context, db app.DB, ticketID string, r slaEscalationRule, elapsedMS, targetMS int64) error {
+	return app.InTx(ctx, db, func(tx app.DB) error {
+		return applyEscalation(ctx, tx, ticketID, r, elapsedMS, targetMS)
+	})
+}
+
+func applyEscalation(ctx context.Context, db app.DB, ticketID string, r slaEscalationRule, elapsedMS, targetMS int64) error {
+	tag, err := db.Exec(ctx, `insert into sla_escalations (ticket_id, rule_id) values ($1::uuid, $2::uuid) on conflict do nothing`, ticketID, r.id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	var number, title string
+	var priority int
+	var assigneeID, leadID *string
+	var team []string
+	err = db.QueryRow(ctx, `select t.number, t.title, t.priority, t.assignee_id::text,
+            array(select m.user_id::text from team_members m where m.team_id = t.team_id), tm.lead_id::text
+        from tickets t left join teams tm on tm.id = t.team_id where t.id::text = $1 for update of t`, ticketID).Scan(&number, &title, &priority, &assigneeID, &team, &leadID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var users []string
+	add := func(ids ...string) {
+		for _, id := range ids {
+			if !slices.Contains(users, id) {
+				users = append(users, id)
+			}
+		}
+	}
+	if r.notifyAssignee {
+		if assigneeID != nil {
+			add(*assigneeID)
+		} else {
+			add(team...)
+		}
+	}
+	if r.notifyManager && leadID != nil {
+		add(*leadID)
+	}
+	actions := []string{}
+	bump := r.bumpPriority && priority > 1
+	if bump || r.teamID != nil {
+		if _, err := db.Exec(ctx, `update tickets set
+                priority = case when $2 then priority - 1 else priority end,
+                team_id = coalesce($3::uuid, team_id),
+                assignee_id = case when $3::uuid is null or team_id = $3::uuid then assignee_id end,
+                updated_at = now()
+            where id::text = $1`, ticketID, bump, r.teamID); err != nil {
+			return err
+		}
+	}
+	if bump {
+		actions = append(actions, "bump_priority")
+	}
+	if r.teamID != nil {
+		actions = append(actions, "reassign")
+		var escalation []string
+		if err := db.QueryRow(ctx, `select array(select user_id::text from team_members where team_id::text = $1)`, *r.teamID).Scan(&escalation); err != nil {
+			return err
+		}
+		add(escalation...)
+	}
+	if len(users) > 0 {
+		actions = append(actions, "notify")
+	}
+
+	data := map[string]any{
+		"id": ticketID, "rule_id": r.id, "rule": r.name, "target": r.target, "threshold_pct": r.pct,
+		"elapsed_ms": elapsedMS, "target_ms": targetMS, "actions": actions,
+	}
+	if err := audit.RecordDiff(ctx, db, slaClockActor, "ticket", ticketID, "sla_escalated", map[string]any{
+		"rule_id": r.id, "target": r.target, "threshold_pct": r.pct, "actions": actions, "notified": len(users),
+	}); err != nil {
+		return err
+	}
+	eventspkg.Emit(ctx, db, slaClockActor, ticketID, "sla_escalated", data)
+	if len(users) == 0 {
+		return nil
+	}
+
+	m := notify.Message{
+		Event:    notify.EventSLABreach,
+		Title:    fmt.Sprintf("SLA escalation: %s at %d%% of %s target", number, r.pct, r.target),
+		Body:     fmt.Sprintf("%s has used %d%% of its %d minute %s target (%s): %s", number, r.pct, targetMS/60000, r.target, r.name, title),
+		TicketID: ticketID,
+		Urgent:   r.pct >= 100,
+	}
+	if bump {
+		m.Body += fmt.Sprintf(". Priority raised to %d", priority-1)
+	}
+	if err := emailSLAEscalation(ctx, db, ticketID, number, title, r, users); err != nil {
+		return err
+	}
+	_, err = notify.Dispatch(ctx, db, users, m)
+	return err
+}
+
+// emailSLAEscalation queues the sla_escalation email to each of users that
+// has an address.
+func emailSLAEscalation(ctx context.Context, db app.DB, ticketID, number, title string, r slaEscalationRule, users []string) error {
+	rows, err := db.Query(ctx, `select id::text, email from users where id::text = any($1) and coalesce(email, '') <> ''`, users)
+	if err != nil {
+		return err
+	}
+	type recipient struct{ id, email string }
+	var to []recipient
+	for rows.Next() {
+		var rc recipient
+		if err := rows.Scan(&rc.id, &rc.email); err != nil {
+			rows.Close()
+			return err
+		}
+		to = append(to, rc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, rc := range to {
+		if err := outbox.AddJob(ctx, db, "sla_escalation:"+ticketID+":"+r.id+":"+rc.id, "", jobs.TypeSendEmail, jobs.Email{
+			To:       rc.email,
+			Template: "sla_escalation",
+			Data:     map[string]any{"Number": number, "Title": title, "Target": r.target, "Percent": r.pct, "Rule": r.name},
+			TicketID: &ticketID,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
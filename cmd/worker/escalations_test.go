@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestEscalateSLA(t *testing.T) {
+	team, policy := "team-esc", "p1"
+	rule := slaEscalationRule{id: "rule-1", name: "Warn at 75%", target: "resolution", policyID: &policy, pct: 75,
+		notifyAssignee: true, notifyManager: true, bumpPriority: true, teamID: &team}
+	if !rule.appliesTo("p1", "resolution") || rule.appliesTo("p2", "resolution") || rule.appliesTo("p1", "response") {
+		t.Fatal("rule applies to the wrong clocks")
+	}
+	if got := rule.threshold(60 * 60 * 1000); got != 45*60*1000 {
+		t.Fatalf("threshold = %d, want 45 minutes", got)
+	}
+	for elapsed, want := range map[int64]bool{44 * 60 * 1000: false, 45 * 60 * 1000: false, 46 * 60 * 1000: true, 105 * 60 * 1000: true, 106 * 60 * 1000: false} {
+		if got := rule.due(elapsed, 60*60*1000); got != want {
+			t.Fatalf("due(%d) = %v, want %v", elapsed, got, want)
+		}
+	}
+
+	claimed := map[string]bool{}
+	var updates [][]any
+	var audited, events, emails []string
+	var paged [][]string
+	db := &testutil.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "insert into sla_escalations"):
+				key := args[0].(string) + "/" + args[1].(string)
+				if claimed[key] {
+					return pgconn.NewCommandTag("INSERT 0 0"), nil
+				}
+				claimed[key] = true
+				return pgconn.NewCommandTag("INSERT 0 1"), nil
+			case strings.Contains(sql, "update tickets"):
+				updates = append(updates, args)
+			case strings.Contains(sql, "insert into audit_events"):
+				audited = append(audited, args[4].(string))
+			case strings.Contains(sql, "insert into ticket_events"):
+				events = append(events, args[1].(string))
+			case strings.Contains(sql, "insert into outbox"):
+				emails = append(emails, args[1].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "from tickets t") {
+					lead := "lead-1"
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*int) = "HD-5", "VPN down", 3
+					*dest[3].(**string), *dest[4].(*[]string), *dest[5].(**string) = nil, []string{"agent-1", "agent-2"}, &lead
+					return nil
+				}
+				*dest[0].(*[]string) = []string{"agent-2", "esc-1"}
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "notification_channels") {
+				paged = append(paged, args[0].([]string))
+				return &testutil.MockRows{}, nil
+			}
+			users := args[0].([]string)
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(users) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string) = users[i-1], users[i-1]+"@example.com"
+					return nil
+				},
+			}, nil
+		},
+	}
+
+	for range 2 {
+		if err := escalateSLA(context.Background(), db, "t1", rule, 46*60*1000, 60*60*1000); err != nil {
+			t.Fatalf("escalateSLA: %v", err)
+		}
+	}
+	if len(updates) != 1 || updates[0][1] != true || *updates[0][2].(*string) != team {
+		t.Fatalf("ticket updated with %v", updates)
+	}
+	if !slices.Equal(audited, []string{"sla_escalated"}) || !slices.Equal(events, []string{"sla_escalated"}) {
+		t.Fatalf("audited %v, events %v", audited, events)
+	}
+	// The unassigned ticket's team, its lead and the escalation team, once each.
+	want := []string{"agent-1", "agent-2", "lead-1", "esc-1"}
+	if len(paged) != 1 || !slices.Equal(paged[0], want) {
+		t.Fatalf("paged %v, want %v", paged, want)
+	}
+	if len(emails) != len(want) || emails[0] != "sla_escalation:t1:rule-1:agent-1" {
+		t.Fatalf("emails %v", emails)
+	}
+}
+
+// escalationTx runs statements on db and records how the transaction ended.
+type escalationTx struct {
+	pgx.Tx
+	db                    *testutil.MockDB
+	committed, rolledBack bool
+}
+
+func (tx *escalationTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, args...)
+}
+
+func (tx *escalationTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.db.Query(ctx, sql, args...)
+}
+
+func (tx *escalationTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.db.QueryRow(ctx, sql, args...)
+}
+
+func (tx *escalationTx) Commit(ctx context.Context) error { tx.committed = true; return nil }
+
+func (tx *escalationTx) Rollback(ctx context.Context) error {
+	if !tx.committed {
+		tx.rolledBack = true
+	}
+	return nil
+}
+
+func TestEscalateSLARetriesAfterFailure(t *testing.T) {
+	rule := slaEscalationRule{id: "rule-1", name: "Breach", target: "response", pct: 100, notifyAssignee: true}
+	claimed := map[string]bool{}
+	var pending []string
+	var emails []string
+	failDispatch := true
+	inner := &testutil.MockDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "insert into sla_escalations"):
+				key := args[0].(string) + "/" + args[1].(string)
+				if claimed[key] {
+					return pgconn.NewCommandTag("INSERT 0 0"), nil
+				}
+				pending = append(pending, key)
+				return pgconn.NewCommandTag("INSERT 0 1"), nil
+			case strings.Contains(sql, "insert into outbox") && strings.Contains(args[1].(string), "sla_escalation:"):
+				emails = append(emails, args[1].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				assignee := "agent-1"
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*int) = "HD-5", "VPN down", 3
+				*dest[3].(**string), *dest[4].(*[]string) = &assignee, nil
+				return nil
+			}}
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if strings.Contains(sql, "notification_channels") {
+				if failDispatch {
+					return nil, errors.New("connection reset")
+				}
+				return &testutil.MockRows{}, nil
+			}
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i == 1 },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string) = "agent-1", "agent-1@example.com"
+					return nil
+				},
+			}, nil
+		},
+	}
+	var tx *escalationTx
+	db := &testutil.MockDB{BeginFunc: func(ctx context.Context) (pgx.Tx, error) {
+		pending = nil
+		tx = &escalationTx{db: inner}
+		return tx, nil
+	}}
+	commit := func() {
+		if tx.committed {
+			for _, k := range pending {
+				claimed[k] = true
+			}
+		}
+	}
+
+	if err := escalateSLA(context.Background(), db, "t1", rule, 61*60*1000, 60*60*1000); err == nil {
+		t.Fatal("expected the failed notification to fail the escalation")
+	}
+	commit()
+	if !tx.rolledBack || len(claimed) != 0 {
+		t.Fatalf("expected the claim to be rolled back, rolled back %v, claimed %v", tx.rolledBack, claimed)
+	}
+
+	failDispatch = false
+	emails = nil
+	if err := escalateSLA(context.Background(), db, "t1", rule, 62*60*1000, 60*60*1000); err != nil {
+		t.Fatalf("escalateSLA: %v", err)
+	}
+	commit()
+	if !tx.committed || !claimed["t1/rule-1"] {
+		t.Fatalf("expected the retry to claim the rule, claimed %v", claimed)
+	}
+	if !slices.Equal(emails, []string{"sla_escalation:t1:rule-1:agent-1"}) {
+		t.Fatalf("emails %v", emails)
+	}
+}
+
+func TestUpdateSLAClocksEmitsBreachEvent(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	db := &slaDB{rows: []slaRow{
+		{ticketID: "t1", calID: "cal1", respMS: 59*60*1000 + 30*1000, lastStart: start, respTarget: 60},
+	}}
+	if err := updateSLAClocks(context.Background(), db); err != nil {
+		t.Fatalf("updateSLAClocks: %v", err)
+	}
+	var events []string
+	for i, sql := range db.execSQL {
+		if strings.Contains(sql, "insert into ticket_events") {
+			events = append(events, db.execArgs[i][1].(string))
+		}
+	}
+	if !slices.Equal(events, []string{"sla_breach"}) {
+		t.Fatalf("expected one sla_breach event, got %v", events)
+	}
+}
+
+func TestUpdateSLAClocksSkipsStoppedTickets(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	elapsed := int64(59*60*1000 + 30*1000)
+	db := &slaDB{
+		rules: []slaEscalationRule{{id: "rule-1", name: "Breach", target: "resolution", pct: 100, notifyAssignee: true}},
+		rows: []slaRow{
+			{ticketID: "open", calID: "cal1", resMS: elapsed, lastStart: start, resTarget: 60, status: "Open"},
+			{ticketID: "closed", calID: "cal1", resMS: elapsed, lastStart: start, resTarget: 60, status: "Closed"},
+			{ticketID: "resolved", calID: "cal1", resMS: elapsed, lastStart: start, resTarget: 60, status: "Resolved"},
+			{ticketID: "pending", calID: "cal1", resMS: elapsed, lastStart: start, resTarget: 60, status: "Pending - Awaiting Info"},
+		},
+	}
+	if err := updateSLAClocks(context.Background(), db); err != nil {
+		t.Fatalf("updateSLAClocks: %v", err)
+	}
+	var claimed []string
+	for i, sql := range db.execSQL {
+		if strings.Contains(sql, "insert into sla_escalations") {
+			claimed = append(claimed, db.execArgs[i][0].(string))
+		}
+	}
+	if !slices.Equal(claimed, []string{"open"}) {
+		t.Fatalf("expected only the open ticket to escalate, got %v", claimed)
+	}
+}
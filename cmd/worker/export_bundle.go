@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// exportBundleMaxBytes caps the attachment bytes packed into one export.
+// Attachments past it are listed in the manifest without a location.
+const exportBundleMaxBytes = 2 << 30
+
+// exportLinkTTL is how long the download links in a manifest stay valid.
+const exportLinkTTL = 24 * time.Hour
+
+type exportTicket struct {
+	id, number, title, status string
+	priority                  int16
+}
+
+type exportComment struct {
+	ticketID, id, author, body string
+	at                         time.Time
+	internal                   bool
+}
+
+type exportAttachment struct {
+	ticketID, id, key, filename, mime string
+	size                              int64
+	internal                          bool
+	at                                time.Time
+}
+
+// exportTicketsZip exports the tickets of ej with its comment and attachment
+// options as a zip of CSV files, plus the attachment files for bundles, and
+// uploads it, returning the object key. The zip is staged in a temporary
+// file so bundles do not have to fit in memory.
+func exportTicketsZip(ctx context.Context, c Config, db DB, store app.ObjectStore, ej ExportTicketsJob) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("object store not configured")
+	}
+	tickets, err := exportTicketRows(ctx, db, ej.IDs)
+	if err != nil {
+		return "", fmt.Errorf("load tickets: %w", err)
+	}
+	var comments []exportComment
+	if ej.Comments != "" {
+		if comments, err = exportCommentRows(ctx, db, ej.IDs); err != nil {
+			return "", fmt.Errorf("load comments: %w", err)
+		}
+	}
+	var attachments []exportAttachment
+	if ej.Attachments != "" {
+		if attachments, err = exportAttachmentRows(ctx, db, ej.IDs); err != nil {
+			return "", fmt.Errorf("load attachments: %w", err)
+		}
+	}
+
+	f, err := os.CreateTemp("", "ticket-export-*.zip")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	numbers := make(map[string]string, len(tickets))
+	threads := map[string][]string{}
+	for _, t := range tickets {
+		numbers[t.id] = t.number
+	}
+	for _, cm := range comments {
+		line := "[" + cm.at.UTC().Format(time.RFC3339) + "] " + cm.author
+		if cm.internal {
+			line += " (internal)"
+		}
+		threads[cm.ticketID] = append(threads[cm.ticketID], line+": "+cm.body)
+	}
+
+	header := []string{"id", "number", "title", "status", "priority"}
+	if ej.Comments == jobs.ExportCommentsFlattened {
+		header = append(header, "comments")
+	}
+	records := [][]string{header}
+	for _, t := range tickets {
+		rec := []string{t.id, t.number, t.title, t.status, strconv.Itoa(int(t.priority))}
+		if ej.Comments == jobs.ExportCommentsFlattened {
+			rec = append(rec, strings.Join(threads[t.id], "\n\n"))
+		}
+		records = append(records, rec)
+	}
+	if err := writeZipCSV(zw, "tickets.csv", records); err != nil {
+		return "", err
+	}
+
+	if ej.Comments == jobs.ExportCommentsFile {
+		records = [][]string{{"ticket_id", "ticket_number", "comment_id", "created_at", "author", "internal", "body"}}
+		for _, cm := range comments {
+			records = append(records, []string{cm.ticketID, numbers[cm.ticketID], cm.id, cm.at.UTC().Format(time.RFC3339),
+				cm.author, strconv.FormatBool(cm.internal), cm.body})
+		}
+		if err := writeZipCSV(zw, "comments.csv", records); err != nil {
+			return "", err
+		}
+	}
+
+	if ej.Attachments != "" {
+		records = [][]string{{"ticket_id", "ticket_number", "attachment_id", "filename", "bytes", "mime", "internal", "created_at", "location"}}
+		var packed int64
+		for _, at := range attachments {
+			var location string
+			if ej.Attachments == jobs.ExportAttachmentsBundle {
+				if packed+at.size <= exportBundleMaxBytes {
+					name := "attachments/" + exportFilename(numbers[at.ticketID]) + "/" + at.id + "-" + exportFilename(at.filename)
+					if err := copyToZip(ctx, zw, store, c.MinIOBucket, at, name); err != nil {
+						log.Warn().Err(err).Str("attachment_id", at.id).Msg("bundle attachment")
+					} else {
+						location = name
+						packed += at.size
+					}
+				}
+			} else if location, err = exportLink(ctx, store, c.MinIOBucket, at); err != nil {
+				log.Warn().Err(err).Str("attachment_id", at.id).Msg("sign attachment link")
+			}
+			records = append(records, []string{at.ticketID, numbers[at.ticketID], at.id, at.filename, strconv.FormatInt(at.size, 10),
+				at.mime, strconv.FormatBool(at.internal), at.at.UTC().Format(time.RFC3339), location})
+		}
+		if err := writeZipCSV(zw, "attachments.csv", records); err != nil {
+			return "", err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	loc := c.location(s3.ClassExports)
+	objectKey := loc.Key(uuid.New().String() + ".zip")
+	if _, err := store.PutObject(ctx, loc.Bucket, objectKey, f, size, minio.PutObjectOptions{ContentType: "application/zip"}); err != nil {
+		return "", err
+	}
+	return objectKey, nil
+}
+
+func writeZipCSV(zw *zip.Writer, name string, records [][]string) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	return nil
+}
+
+func copyToZip(ctx context.Context, zw *zip.Writer, store app.ObjectStore, bucket string, at exportAttachment, name string) error {
+	rc, err := app.OpenObject(ctx, store, bucket, at.key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: at.at})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// exportLink presigns a download link for a manifest entry. Stores that
+// cannot presign yield an empty location.
+func exportLink(ctx context.Context, store app.ObjectStore, bucket string, at exportAttachment) (string, error) {
+	mw, ok := store.(*app.MinioWrapper)
+	if !ok {
+		return "", nil
+	}
+	svc := s3.Service{Client: mw.Client, Bucket: bucket, MaxTTL: exportLinkTTL}
+	return svc.PresignDownload(ctx, at.key, exportFilename(at.filename), "application/octet-stream", exportLinkTTL)
+}
+
+// exportFilename reduces a user-supplied name to a single safe path element.
+func exportFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.NewReplacer("\"", "_", "\r", "_", "\n", "_").Replace(name)
+	if name == "." || name == ".." || name == "/" || name == "" {
+		return "file"
+	}
+	return name
+}
+
+func exportTicketRows(ctx context.Context, db DB, ids []string) ([]exportTicket, error) {
+	rows, err := db.Query(ctx, `select id::text, number, title, status, priority from tickets where id = any($1::uuid[]) order by number`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []exportTicket
+	for rows.Next() {
+		var t exportTicket
+		if err := rows.Scan(&t.id, &t.number, &t.title, &t.status, &t.priority); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func exportCommentRows(ctx context.Context, db DB, ids []string) ([]exportComment, error) {
+	rows, err := db.Query(ctx, `select tc.ticket_id::text, tc.id::text, tc.created_at, coalesce(u.display_name, u.email, rq.name, rq.email, ''),
+            tc.is_internal, tc.body_md
+        from ticket_comments tc
+        left join users u on u.id=tc.author_id
+        left join requesters rq on rq.id=tc.author_requester_id
+        where tc.ticket_id = any($1::uuid[]) order by tc.ticket_id, tc.created_at`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []exportComment
+	for rows.Next() {
+		var cm exportComment
+		if err := rows.Scan(&cm.ticketID, &cm.id, &cm.at, &cm.author, &cm.internal, &cm.body); err != nil {
+			return nil, err
+		}
+		out = append(out, cm)
+	}
+	return out, rows.Err()
+}
+
+func exportAttachmentRows(ctx context.Context, db DB, ids []string) ([]exportAttachment, error) {
+	rows, err := db.Query(ctx, `select ticket_id::text, id::text, object_key, filename, bytes, coalesce(mime, ''), is_internal, created_at
+        from attachments where ticket_id = any($1::uuid[]) order by ticket_id, created_at`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []exportAttachment
+	for rows.Next() {
+		var at exportAttachment
+		if err := rows.Scan(&at.ticketID, &at.id, &at.key, &at.filename, &at.size, &at.mime, &at.internal, &at.at); err != nil {
+			return nil, err
+		}
+		out = append(out, at)
+	}
+	return out, rows.Err()
+}
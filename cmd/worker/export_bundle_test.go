@@ -0,0 +1,148 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/minio/minio-go/v7"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// bundleDB answers the ticket, comment and attachment queries of a zip export.
+type bundleDB struct{ testutil.MockDB }
+
+func (db *bundleDB) Ping(ctx context.Context) error { return nil }
+
+func newBundleDB() *bundleDB {
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	data := map[string][][]any{
+		"from tickets": {{"t1", "TKT-1", "Printer", "Open", int16(2)}},
+		"from ticket_comments": {
+			{"t1", "c1", at, "Ann", false, "It jams"},
+			{"t1", "c2", at.Add(time.Hour), "Bob", true, "Needs a new roller"},
+		},
+		"from attachments": {{"t1", "a1", "key1", "../jam.jpg", int64(4), "image/jpeg", false, at}},
+	}
+	db := &bundleDB{}
+	db.QueryFunc = func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		var rows [][]any
+		for k, v := range data {
+			if strings.Contains(sql, k) {
+				rows = v
+			}
+		}
+		i := -1
+		return &testutil.MockRows{
+			NextFunc: func() bool { i++; return i < len(rows) },
+			ScanFunc: func(dest ...any) error {
+				for j, v := range rows[i] {
+					switch d := dest[j].(type) {
+					case *string:
+						*d = v.(string)
+					case *int16:
+						*d = v.(int16)
+					case *int64:
+						*d = v.(int64)
+					case *bool:
+						*d = v.(bool)
+					case *time.Time:
+						*d = v.(time.Time)
+					}
+				}
+				return nil
+			},
+		}, nil
+	}
+	return db
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	out := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		out[f.Name] = string(b)
+	}
+	return out
+}
+
+func TestExportTicketsZip(t *testing.T) {
+	ctx := context.Background()
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	if _, err := store.PutObject(ctx, "bkt", "key1", bytes.NewReader([]byte("jpeg")), 4, minio.PutObjectOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	c := Config{MinIOBucket: "bkt"}
+
+	key, err := exportTicketsZip(ctx, c, newBundleDB(), store, ExportTicketsJob{IDs: []string{"t1"}, Comments: jobs.ExportCommentsFlattened})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, filepath.Join(store.Base, "bkt", key))
+	if len(files) != 1 {
+		t.Fatalf("expected tickets.csv only, got %v", files)
+	}
+	recs, err := csv.NewReader(strings.NewReader(files["tickets.csv"])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[2026-03-02T10:00:00Z] Ann: It jams\n\n[2026-03-02T11:00:00Z] Bob (internal): Needs a new roller"
+	if len(recs) != 2 || recs[0][5] != "comments" || recs[1][5] != want {
+		t.Fatalf("unexpected flattened thread %q", recs)
+	}
+
+	key, err = exportTicketsZip(ctx, c, newBundleDB(), store, ExportTicketsJob{IDs: []string{"t1"}, Comments: jobs.ExportCommentsFile, Attachments: jobs.ExportAttachmentsBundle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = readZip(t, filepath.Join(store.Base, "bkt", key))
+	if strings.Contains(strings.SplitN(files["tickets.csv"], "\n", 2)[0], "comments") {
+		t.Fatalf("comments column with a comments file: %q", files["tickets.csv"])
+	}
+	if recs, _ := csv.NewReader(strings.NewReader(files["comments.csv"])).ReadAll(); len(recs) != 3 || recs[2][5] != "true" {
+		t.Fatalf("unexpected comments.csv %q", recs)
+	}
+	if files["attachments/TKT-1/a1-jam.jpg"] != "jpeg" {
+		t.Fatalf("attachment not bundled: %v", files)
+	}
+	recs, _ = csv.NewReader(strings.NewReader(files["attachments.csv"])).ReadAll()
+	if len(recs) != 2 || recs[1][3] != "../jam.jpg" || recs[1][8] != "attachments/TKT-1/a1-jam.jpg" {
+		t.Fatalf("unexpected manifest %q", recs)
+	}
+
+	// A manifest from a store that cannot presign lists the files without links.
+	key, err = exportTicketsZip(ctx, c, newBundleDB(), store, ExportTicketsJob{IDs: []string{"t1"}, Attachments: jobs.ExportAttachmentsManifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = readZip(t, filepath.Join(store.Base, "bkt", key))
+	recs, _ = csv.NewReader(strings.NewReader(files["attachments.csv"])).ReadAll()
+	if len(files) != 2 || len(recs) != 2 || recs[1][8] != "" {
+		t.Fatalf("unexpected manifest export %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(store.Base, "bkt", "key1")); err != nil {
+		t.Fatalf("export must not move attachments: %v", err)
+	}
+}
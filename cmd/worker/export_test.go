@@ -123,3 +123,27 @@ func TestHandleExportTicketsJob(t *testing.T) {
         t.Fatalf("csv mismatch: got %q want %q", got, want)
     }
 }
+
+func TestHandleExportTicketsJob_LegacyPayloadKeepsRequester(t *testing.T) {
+	store := newFakeObjectStore()
+	defer store.Close()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	// The API records the requester when queuing; v1 payloads omit it.
+	if err := rdb.Set(ctx, "export_tickets:job1", `{"requester":"req","status":"queued"}`, 0).Err(); err != nil {
+		t.Fatalf("redis set: %v", err)
+	}
+	db := &exportDB{tickets: []ticket{{ID: "1", Number: "TKT-1", Title: "First", Status: "Open", Priority: 1}}}
+	handleExportTicketsJob(ctx, Config{MinIOBucket: "bucket"}, db, store, rdb, "job1", ExportTicketsJob{IDs: []string{"1"}})
+
+	val, _ := rdb.Get(ctx, "export_tickets:job1").Result()
+	var st ExportStatus
+	if err := json.Unmarshal([]byte(val), &st); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if st.Requester != "req" || st.Status != "done" {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+}
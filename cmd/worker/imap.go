@@ -6,8 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"regexp"
-	"strconv"
+	netmail "net/mail"
+	"slices"
 	"strings"
 
 	"github.com/emersion/go-imap"
@@ -20,6 +20,10 @@ import (
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
 	ws "github.com/mark3748/helpdesk-go/cmd/api/ws"
+	"github.com/mark3748/helpdesk-go/internal/blocklist"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/verify"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
 )
 
 type imapClient interface {
@@ -108,7 +112,9 @@ func pollIMAP(ctx context.Context, c Config, db app.DB, store app.ObjectStore, r
 	return <-done
 }
 
-// processIMAPMessage parses and stores a single email message.
+// processIMAPMessage parses and stores a single email message. A reply to
+// a ticket's thread (see threadTicket) is added to the ticket as a comment;
+// any other message opens a ticket.
 func processIMAPMessage(ctx context.Context, c Config, db app.DB, store app.ObjectStore, rdb *redis.Client, raw []byte) error {
 	mr, err := mail.CreateReader(bytes.NewReader(raw))
 	if err != nil {
@@ -116,14 +122,24 @@ func processIMAPMessage(ctx context.Context, c Config, db app.DB, store app.Obje
 	}
 	msgID := strings.TrimSpace(mr.Header.Get("Message-Id"))
 	if msgID != "" {
-		var existing int64
-		if err := db.QueryRow(ctx, "select ticket_id from email_inbound where message_id=$1", msgID).Scan(&existing); err == nil {
+		var seen bool
+		if err := db.QueryRow(ctx, "select exists(select 1 from email_inbound where message_id=$1)", msgID).Scan(&seen); err == nil && seen {
 			return nil
 		}
 	}
+	inReplyTo := parseMessageIDs(mr.Header.Get("In-Reply-To"))
+	refs := parseMessageIDs(mr.Header.Get("References"))
 
 	subject := sanitizeEmailHeader(mr.Header.Get("Subject"))
 	from := sanitizeEmailHeader(mr.Header.Get("From"))
+	if addr, err := netmail.ParseAddress(from); err == nil {
+		b, blocked, err := blocklist.Match(ctx, db, addr.Address)
+		if err != nil {
+			log.Error().Err(err).Msg("check requester blocklist")
+		} else if blocked {
+			return dropBlocked(ctx, db, b, msgID, subject, addr.Address)
+		}
+	}
 
 	type att struct {
 		name string
@@ -161,36 +177,44 @@ func processIMAPMessage(ctx context.Context, c Config, db app.DB, store app.Obje
 		}
 	}
 
-	var ticketID int64
-	re := regexp.MustCompile(`\[TKT-(\d+)\]`)
-	if match := re.FindStringSubmatch(subject); len(match) == 2 {
-		if n, err := strconv.Atoi(match[1]); err == nil {
-			if err := db.QueryRow(ctx, "select id from tickets where number=$1", n).Scan(&ticketID); err != nil {
-				ticketID = 0
-			}
-		}
+	fromAddr := ""
+	if addr, err := netmail.ParseAddress(from); err == nil {
+		fromAddr = addr.Address
+	}
+	ticketID, err := threadTicket(ctx, db, slices.Concat(inReplyTo, refs), subject, fromAddr)
+	if err != nil {
+		return err
 	}
 
-	created := false
-	if ticketID == 0 {
-		if err := db.QueryRow(ctx, "insert into tickets (title, description, status) values ($1,$2,'New') returning id", subject, body).Scan(&ticketID); err != nil {
+	requesterID := emailRequester(ctx, c, db, from)
+	if ticketID == "" {
+		var number string
+		if err := db.QueryRow(ctx, `insert into tickets (number, title, description, requester_id, priority, status, source)
+            values ('HD-'||nextval('ticket_seq'), $1, $2, nullif($3,'')::uuid, 3, 'New', 'email') returning id::text, number`,
+			subject, body, requesterID).Scan(&ticketID, &number); err != nil {
 			return err
 		}
-		created = true
-	} else {
-		if _, err := db.Exec(ctx, "insert into ticket_comments (ticket_id, body_md, is_internal) values ($1,$2,false)", ticketID, body); err != nil {
-			log.Error().Err(err).Msg("insert comment")
+		// Recorded before the acknowledgement is queued so it replies to it.
+		recordThreadMessage(ctx, db, ticketID, msgID, "inbound", first(inReplyTo), refs)
+		if err := webhook.Publish(ctx, db, "ticket.created", ticketID, nil); err != nil {
+			log.Error().Err(err).Str("ticket", ticketID).Msg("publish ticket created")
 		}
-	}
-	if created {
 		if rdb != nil {
-			ej := EmailJob{To: from, Template: "ticket_created", Data: map[string]any{"Number": ticketID}}
-			b, _ := json.Marshal(ej)
-			nb, _ := json.Marshal(Job{Type: "send_email", Data: b})
-			_ = rdb.RPush(ctx, "jobs", nb).Err()
+			ej := EmailJob{To: from, Template: "ticket_created", Data: map[string]any{"Number": number}, TicketID: &ticketID}
+			nb, _ := jobs.Encode("", jobs.TypeSendEmail, ej)
+			_ = rdb.RPush(ctx, jobs.Queue, nb).Err()
 		}
 		ws.PublishEvent(ctx, rdb, ws.Event{Type: "ticket_created", Data: map[string]interface{}{"id": ticketID}})
 	} else {
+		// A reply joins the ticket's thread as a public comment by the sender.
+		var commentID string
+		if err := db.QueryRow(ctx, "insert into ticket_comments (ticket_id, author_id, author_requester_id, body_md, is_internal) values ($1, null, nullif($2,'')::uuid, $3, false) returning id::text",
+			ticketID, requesterID, body).Scan(&commentID); err != nil {
+			log.Error().Err(err).Msg("insert comment")
+		} else if err := webhook.Publish(ctx, db, "comment.created", ticketID, map[string]any{"comment_id": commentID}); err != nil {
+			log.Error().Err(err).Str("ticket", ticketID).Msg("publish comment created")
+		}
+		recordThreadMessage(ctx, db, ticketID, msgID, "inbound", first(inReplyTo), refs)
 		ws.PublishEvent(ctx, rdb, ws.Event{Type: "ticket_updated", Data: map[string]interface{}{"id": ticketID}})
 	}
 
@@ -234,3 +258,47 @@ func processIMAPMessage(ctx context.Context, c Config, db app.DB, store app.Obje
 	}
 	return nil
 }
+
+// dropBlocked discards an email from a blocked sender. Nothing of it is
+// kept beyond a log line and a "blocked" email_inbound row without the raw
+// message.
+func dropBlocked(ctx context.Context, db app.DB, b blocklist.Block, msgID, subject, from string) error {
+	log.Warn().Str("block_id", b.ID).Str("pattern", b.Pattern).Str("from", from).Str("message_id", msgID).Msg("dropped email from blocked sender")
+	pj, err := json.Marshal(map[string]any{"subject": subject, "from": from, "block_id": b.ID})
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, "insert into email_inbound (raw_store_key, parsed_json, message_id, status) values ('', $1, nullif($2, ''), 'blocked')", pj, msgID); err != nil {
+		log.Error().Err(err).Msg("insert email_inbound")
+	}
+	return nil
+}
+
+// emailRequester returns the requester for the sender of an inbound email,
+// creating an unverified one and sending it a verification link when the
+// address is new. It returns "" when the sender cannot be resolved.
+func emailRequester(ctx context.Context, c Config, db app.DB, from string) string {
+	addr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return ""
+	}
+	id, created, err := verify.UpsertRequester(ctx, db, addr.Address, addr.Name)
+	if err != nil {
+		log.Error().Err(err).Msg("upsert email requester")
+		return ""
+	}
+	if created {
+		if err := verify.Issue(ctx, db, id, addr.Address, c.PublicURL); err != nil {
+			log.Error().Err(err).Str("requester", id).Msg("issue requester verification")
+		}
+	}
+	return id
+}
+
+// first returns the first of ids, or "".
+func first(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 // fakeStore implements app.ObjectStore for tests.
@@ -47,59 +48,79 @@ func (f *fakeStore) PresignedPutObject(ctx context.Context, bucketName, objectNa
 	return nil, fmt.Errorf("PresignedPutObject not supported in fakeStore")
 }
 
-// fakeDB implements app.DB for tests.
+// fakeDB implements app.DB for tests. Tickets are numbered HD-1, HD-2, …
+// and owned by requester.
 type fakeDB struct {
-	tickets     int64
+	tickets     int
+	comments    int
 	attachments int
-	inbound     map[string]int64
+	requester   string
+	inbound     map[string]string
+	threads     map[string]string
 }
 
-func newFakeDB() *fakeDB { return &fakeDB{inbound: make(map[string]int64)} }
+func newFakeDB() *fakeDB {
+	return &fakeDB{requester: "sender@example.com", inbound: make(map[string]string), threads: make(map[string]string)}
+}
 
 func (f *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
 	return nil, nil
 }
 
 type fakeRow struct {
-	val int64
-	err error
+	vals []any
+	err  error
 }
 
 func (r fakeRow) Scan(dest ...interface{}) error {
 	if r.err != nil {
 		return r.err
 	}
-	if len(dest) > 0 {
-		if p, ok := dest[0].(*int64); ok {
-			*p = r.val
+	for i, v := range r.vals {
+		switch p := dest[i].(type) {
+		case *string:
+			*p = v.(string)
+		case *bool:
+			*p = v.(bool)
 		}
 	}
 	return nil
 }
 
 func (f *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
-	if strings.HasPrefix(sql, "select ticket_id from email_inbound") {
-		id, ok := f.inbound[args[0].(string)]
-		if ok {
-			return fakeRow{val: id}
+	switch {
+	case strings.HasPrefix(sql, "select exists(select 1 from email_inbound"):
+		_, ok := f.inbound[args[0].(string)]
+		return fakeRow{vals: []any{ok}}
+	case strings.HasPrefix(sql, "select ticket_id::text from ticket_email_messages"):
+		for _, id := range args[0].([]string) {
+			if t, ok := f.threads[id]; ok {
+				return fakeRow{vals: []any{t}}
+			}
 		}
-		return fakeRow{err: pgx.ErrNoRows}
-	}
-	if strings.HasPrefix(sql, "insert into tickets") {
+	case strings.HasPrefix(sql, "select t.id::text from tickets t"):
+		var n int
+		if _, err := fmt.Sscanf(args[0].(string), "HD-%d", &n); err == nil && n <= f.tickets && strings.EqualFold(args[1].(string), f.requester) {
+			return fakeRow{vals: []any{fmt.Sprintf("t%d", n)}}
+		}
+	case strings.HasPrefix(sql, "insert into tickets"):
 		f.tickets++
-		return fakeRow{val: f.tickets}
+		return fakeRow{vals: []any{fmt.Sprintf("t%d", f.tickets), fmt.Sprintf("HD-%d", f.tickets)}}
+	case strings.HasPrefix(sql, "insert into ticket_comments"):
+		f.comments++
+		return fakeRow{vals: []any{fmt.Sprintf("c%d", f.comments)}}
 	}
 	return fakeRow{err: pgx.ErrNoRows}
 }
 
 func (f *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
-	if strings.HasPrefix(sql, "insert into attachments") {
+	switch {
+	case strings.HasPrefix(sql, "insert into attachments"):
 		f.attachments++
-	}
-	if strings.HasPrefix(sql, "insert into email_inbound") {
-		msgID := args[2].(string)
-		ticketID := args[3].(int64)
-		f.inbound[msgID] = ticketID
+	case strings.HasPrefix(sql, "insert into ticket_email_messages"):
+		f.threads[args[0].(string)] = args[1].(string)
+	case strings.HasPrefix(sql, "insert into email_inbound"):
+		f.inbound[args[2].(string)] = args[3].(string)
 	}
 	return pgconn.CommandTag{}, nil
 }
@@ -130,7 +151,7 @@ func TestProcessIMAPMessage_Attachment(t *testing.T) {
 
 func TestProcessIMAPMessage_Duplicate(t *testing.T) {
 	db := newFakeDB()
-	db.inbound["<msg1@example.com>"] = 1
+	db.inbound["<msg1@example.com>"] = "t1"
 	store := newFakeStore()
 	c := Config{MinIOBucket: "bkt"}
 	if err := processIMAPMessage(context.Background(), c, db, store, nil, []byte(sampleEmail)); err != nil {
@@ -147,6 +168,51 @@ func TestProcessIMAPMessage_Duplicate(t *testing.T) {
 	}
 }
 
+const replyEmail = "Subject: %s\r\nFrom: %s\r\nMessage-Id: <reply@example.com>\r\n%sContent-Type: text/plain\r\n\r\nthanks\r\n"
+
+func TestProcessIMAPMessage_Threading(t *testing.T) {
+	cases := []struct {
+		name, subject, from, headers string
+		threaded                     bool
+	}{
+		{"in-reply-to", "Re: Test", "other@example.com", "In-Reply-To: <msg1@example.com>\r\n", true},
+		{"references", "Re: Test", "other@example.com", "References: <root@example.com> <msg1@example.com>\r\n", true},
+		{"subject token", "Re: [HD-1] Test", "Sender@Example.com", "", true},
+		{"subject token from a stranger", "Re: [HD-1] Test", "other@example.com", "", false},
+		{"unknown ticket", "Re: [HD-9] Test", "sender@example.com", "In-Reply-To: <unknown@example.com>\r\n", false},
+	}
+	for _, tc := range cases {
+		db := newFakeDB()
+		c := Config{MinIOBucket: "bkt"}
+		if err := processIMAPMessage(context.Background(), c, db, newFakeStore(), nil, []byte(sampleEmail)); err != nil {
+			t.Fatalf("%s: first message: %v", tc.name, err)
+		}
+		reply := fmt.Sprintf(replyEmail, tc.subject, tc.from, tc.headers)
+		if err := processIMAPMessage(context.Background(), c, db, newFakeStore(), nil, []byte(reply)); err != nil {
+			t.Fatalf("%s: reply: %v", tc.name, err)
+		}
+		if threaded := db.tickets == 1 && db.comments == 1; threaded != tc.threaded {
+			t.Fatalf("%s: threaded = %v (tickets %d, comments %d)", tc.name, threaded, db.tickets, db.comments)
+		}
+		if want := fmt.Sprintf("t%d", db.tickets); db.threads["<reply@example.com>"] != want {
+			t.Fatalf("%s: reply recorded on %q, want %s", tc.name, db.threads["<reply@example.com>"], want)
+		}
+	}
+}
+
+func TestParseMessageIDs(t *testing.T) {
+	got := parseMessageIDs(" <a@x> \r\n\t<b@y>")
+	if len(got) != 2 || got[0] != "<a@x>" || got[1] != "<b@y>" {
+		t.Fatalf("bracketed: %v", got)
+	}
+	if got := parseMessageIDs("c@z"); len(got) != 1 || got[0] != "<c@z>" {
+		t.Fatalf("bare: %v", got)
+	}
+	if got := parseMessageIDs(""); got != nil {
+		t.Fatalf("empty: %v", got)
+	}
+}
+
 func TestProcessIMAPMessage_EnqueueAck(t *testing.T) {
 	db := newFakeDB()
 	store := newFakeStore()
@@ -163,6 +229,42 @@ func TestProcessIMAPMessage_EnqueueAck(t *testing.T) {
 	}
 }
 
+func TestProcessIMAPMessage_BlockedSender(t *testing.T) {
+	var created bool
+	var status string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			if strings.HasPrefix(sql, "update requester_blocks") {
+				return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = "b1"
+					*dest[1].(*string) = "example.com"
+					return nil
+				}}
+			}
+			if strings.HasPrefix(sql, "insert into tickets") {
+				created = true
+			}
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.HasPrefix(sql, "insert into email_inbound") && strings.Contains(sql, "'blocked'") {
+				status = "blocked"
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	store := newFakeStore()
+	if err := processIMAPMessage(context.Background(), Config{MinIOBucket: "bkt"}, db, store, nil, []byte(sampleEmail)); err != nil {
+		t.Fatalf("processIMAPMessage: %v", err)
+	}
+	if created || len(store.objects) != 0 {
+		t.Fatalf("expected the email dropped, got ticket=%v objects=%d", created, len(store.objects))
+	}
+	if status != "blocked" {
+		t.Fatal("expected the drop to be logged in email_inbound")
+	}
+}
+
 type bytesLiteral struct {
 	data []byte
 	idx  int
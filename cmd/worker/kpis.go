@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/remotewrite"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// kpiJob labels every business KPI series so they sit apart from scraped
+// infrastructure metrics.
+const kpiJob = "helpdesk"
+
+// kpiSeries gathers the business KPIs pushed to the remote-write endpoint:
+// open tickets by priority, SLA at-risk and breached counts, and the open
+// tickets in each queue. Open means neither Resolved nor Closed.
+func kpiSeries(ctx context.Context, db app.DB) ([]remotewrite.Series, error) {
+	var out []remotewrite.Series
+	rows, err := db.Query(ctx, `select p, count(t.id)
+        from generate_series(1, 4) p
+        left join tickets t on t.priority = p and t.status not in ('Resolved','Closed')
+        group by p order by p`)
+	if err != nil {
+		return nil, fmt.Errorf("open tickets: %w", err)
+	}
+	for rows.Next() {
+		var priority, n int
+		if err := rows.Scan(&priority, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("open tickets: %w", err)
+		}
+		out = append(out, remotewrite.Gauge("helpdesk_open_tickets", float64(n), "job", kpiJob, "priority", strconv.Itoa(priority)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("open tickets: %w", err)
+	}
+
+	var atRisk, breached int
+	if err := db.QueryRow(ctx, `select count(*) filter (where `+sla.AtRiskFilter+` and not (`+sla.BreachedFilter+`)),
+        count(*) filter (where `+sla.BreachedFilter+`)
+        from tickets t
+        join ticket_sla_clocks sc on sc.ticket_id = t.id
+        join sla_policy_versions sp on sp.id = sc.policy_version_id`).Scan(&atRisk, &breached); err != nil {
+		return nil, fmt.Errorf("sla counts: %w", err)
+	}
+	out = append(out,
+		remotewrite.Gauge("helpdesk_sla_at_risk_tickets", float64(atRisk), "job", kpiJob),
+		remotewrite.Gauge("helpdesk_sla_breached_tickets", float64(breached), "job", kpiJob))
+
+	// Every queue reports, empty ones as 0; tickets outside a queue report
+	// under "Unqueued" while there are any.
+	rows, err = db.Query(ctx, `select coalesce(q.id::text, ''), coalesce(q.name, 'Unqueued'), count(t.id)
+        from queues q
+        full join (select id, queue_id from tickets where status not in ('Resolved','Closed')) t on t.queue_id = q.id
+        group by q.id, q.name order by 2`)
+	if err != nil {
+		return nil, fmt.Errorf("queue depth: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		var n int
+		if err := rows.Scan(&id, &name, &n); err != nil {
+			return nil, fmt.Errorf("queue depth: %w", err)
+		}
+		out = append(out, remotewrite.Gauge("helpdesk_queue_depth", float64(n), "job", kpiJob, "queue", name, "queue_id", id))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("queue depth: %w", err)
+	}
+	return out, nil
+}
+
+// pushKPIs samples the business KPIs and pushes them to c.
+func pushKPIs(ctx context.Context, db app.DB, c remotewrite.Client) error {
+	at := time.Now()
+	series, err := kpiSeries(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := c.Push(ctx, series, at); err != nil {
+		return err
+	}
+	log.Debug().Int("series", len(series)).Msg("business metrics pushed")
+	return nil
+}
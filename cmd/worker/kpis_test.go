@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/remotewrite"
+)
+
+func TestKPISeries(t *testing.T) {
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			i := -1
+			if strings.Contains(sql, "generate_series") {
+				return &testutil.MockRows{
+					NextFunc: func() bool { i++; return i < 4 },
+					ScanFunc: func(dest ...any) error {
+						*dest[0].(*int), *dest[1].(*int) = i+1, 10*(i+1)
+						return nil
+					},
+				}, nil
+			}
+			queues := [][]string{{"q1", "Hardware"}, {"", "Unqueued"}}
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i < len(queues) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*int) = queues[i][0], queues[i][1], 3
+					return nil
+				},
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int), *dest[1].(*int) = 2, 1
+				return nil
+			}}
+		},
+	}
+	series, err := kpiSeries(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]float64{}
+	for _, s := range series {
+		var key []string
+		for _, l := range s.Labels {
+			if l.Name != "job" || l.Value != kpiJob {
+				key = append(key, l.Value)
+			}
+		}
+		if len(key) == len(s.Labels) {
+			t.Fatalf("series without the job label: %+v", s)
+		}
+		got[strings.Join(key, " ")] = s.Value
+	}
+	want := map[string]float64{
+		"helpdesk_open_tickets 1":          10,
+		"helpdesk_open_tickets 4":          40,
+		"helpdesk_sla_at_risk_tickets":     2,
+		"helpdesk_sla_breached_tickets":    1,
+		"helpdesk_queue_depth Hardware q1": 3,
+		"helpdesk_queue_depth Unqueued ":   3,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v (all: %v)", k, got[k], v, got)
+		}
+	}
+	if len(series) != 8 || series[0].Labels[0].Name != remotewrite.NameLabel {
+		t.Fatalf("unexpected series %+v", series)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// latencyBuckets span 10ms to about 45 minutes, covering a job picked up at
+// once through one stuck behind a long backlog or several retries.
+var latencyBuckets = prometheus.ExponentialBuckets(0.01, 4, 10)
+
+var (
+	jobsProcessedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_jobs_processed_total",
+		Help: "Number of jobs processed by type.",
+	}, []string{"type"})
+	jobsFailedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_jobs_failed_total",
+		Help: "Number of jobs that returned an error by type.",
+	}, []string{"type"})
+	jobsSettledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_jobs_settled_total",
+		Help: "Number of leased jobs by type and outcome (acked, retried or dead_lettered).",
+	}, []string{"type", "outcome"})
+	leasesRecoveredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_leases_recovered_total",
+		Help: "Number of expired job leases by outcome (requeued or dead_lettered).",
+	}, []string{"outcome"})
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "worker_job_duration_seconds",
+		Help:    "Time spent processing a job by type.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"type"})
+	jobQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "worker_job_queue_wait_seconds",
+		Help:    "Time a job waited between being enqueued, or becoming due, and starting, by type.",
+		Buckets: latencyBuckets,
+	}, []string{"type"})
+	jobLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "worker_job_latency_seconds",
+		Help:    "Time from a job first being requested to an attempt finishing, across retries, by type and result (ok or error).",
+		Buckets: latencyBuckets,
+	}, []string{"type", "result"})
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "worker_queue_depth",
+		Help: "Number of jobs waiting by queue.",
+	}, []string{"queue"})
+	emailsSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "worker_emails_total",
+		Help: "Number of outbound emails by result (sent or failed).",
+	}, []string{"status"})
+	imapPollDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "worker_imap_poll_duration_seconds",
+		Help:    "Duration of IMAP inbox polls.",
+		Buckets: prometheus.DefBuckets,
+	})
+	imapPollErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "worker_imap_poll_errors_total",
+		Help: "Number of IMAP polls that failed.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		jobsProcessedTotal,
+		jobsFailedTotal,
+		jobsSettledTotal,
+		leasesRecoveredTotal,
+		jobDuration,
+		jobQueueWait,
+		jobLatency,
+		queueDepth,
+		emailsSentTotal,
+		imapPollDuration,
+		imapPollErrorsTotal,
+	)
+}
+
+// observeJobStart records how long job waited in the queue before starting
+// at start. Envelopes without timestamps, from producers that predate them,
+// are not measured.
+func observeJobStart(job jobs.Job, start time.Time) {
+	if job.EnqueuedAt.IsZero() {
+		return
+	}
+	observe(jobQueueWait.WithLabelValues(job.Type), start.Sub(job.EnqueuedAt), job.TraceID)
+}
+
+// observeJobEnd records the end-to-end latency of an attempt of job that
+// finished at end.
+func observeJobEnd(job jobs.Job, end time.Time, err error) {
+	if job.CreatedAt.IsZero() {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	observe(jobLatency.WithLabelValues(job.Type, result), end.Sub(job.CreatedAt), job.TraceID)
+}
+
+// observe records d, with traceID as an exemplar when there is one.
+func observe(o prometheus.Observer, d time.Duration, traceID string) {
+	v := max(d, 0).Seconds()
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && traceID != "" {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": traceID})
+		return
+	}
+	o.Observe(v)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestSendEmailMetrics(t *testing.T) {
+	c := Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "from@example.com"}
+	j := EmailJob{To: "to@example.com", Template: "ticket_created", Data: map[string]any{"Number": "HD-1"}}
+
+	sent := testutil.ToFloat64(emailsSentTotal.WithLabelValues("sent"))
+	failed := testutil.ToFloat64(emailsSentTotal.WithLabelValues("failed"))
+
+	smtpSendMail = func(string, smtp.Auth, string, []string, []byte) error { return nil }
+	defer func() { smtpSendMail = smtp.SendMail }()
+	if err := sendEmail(context.Background(), nil, c, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if got := testutil.ToFloat64(emailsSentTotal.WithLabelValues("sent")); got != sent+1 {
+		t.Fatalf("sent counter = %v, want %v", got, sent+1)
+	}
+
+	smtpSendMail = func(string, smtp.Auth, string, []string, []byte) error { return context.DeadlineExceeded }
+	_ = sendEmail(context.Background(), nil, c, j)
+	if got := testutil.ToFloat64(emailsSentTotal.WithLabelValues("failed")); got != failed+1 {
+		t.Fatalf("failed counter = %v, want %v", got, failed+1)
+	}
+}
+
+func TestJobLatencyMetrics(t *testing.T) {
+	start := time.Now()
+	job := jobs.Job{Type: "latency_test", CreatedAt: start.Add(-time.Minute), EnqueuedAt: start.Add(-2 * time.Second), TraceID: "req-1"}
+	observeJobStart(job, start)
+	observeJobEnd(job, start.Add(time.Second), nil)
+	observeJobEnd(jobs.Job{Type: "latency_test"}, start, errors.New("legacy envelope"))
+
+	var m dto.Metric
+	if err := jobQueueWait.WithLabelValues("latency_test").(prometheus.Metric).Write(&m); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	h := m.GetHistogram()
+	if h.GetSampleCount() != 1 || h.GetSampleSum() < 2 || h.GetSampleSum() > 2.1 {
+		t.Fatalf("unexpected queue wait %v", h)
+	}
+	var exemplar bool
+	for _, b := range h.GetBucket() {
+		if e := b.GetExemplar(); e != nil && len(e.GetLabel()) == 1 && e.GetLabel()[0].GetValue() == "req-1" {
+			exemplar = true
+		}
+	}
+	if !exemplar {
+		t.Fatal("expected the trace id as an exemplar")
+	}
+	if n := testutil.CollectAndCount(jobLatency, "worker_job_latency_seconds"); n != 1 {
+		t.Fatalf("expected only the stamped job's latency, got %d series", n)
+	}
+}
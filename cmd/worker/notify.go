@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+const (
+	// notifyBatchIndex is a sorted set of pending batch keys scored by the
+	// unix time at which the batch should be flushed.
+	notifyBatchIndex = "notify:batches"
+	// notifyBatchPrefix prefixes the per ticket/recipient lists holding the
+	// coalesced email jobs.
+	notifyBatchPrefix = "notify:batch:"
+)
+
+// debouncedTemplates lists the email templates that are coalesced per
+// ticket and recipient instead of being sent immediately.
+var debouncedTemplates = map[string]bool{
+	"ticket_updated": true,
+}
+
+// shouldDebounce reports whether the job is eligible for coalescing.
+func shouldDebounce(c Config, j EmailJob) bool {
+	if c.NotifyDebounceSeconds <= 0 || j.TicketID == nil || *j.TicketID == "" {
+		return false
+	}
+	return debouncedTemplates[j.Template]
+}
+
+func notifyBatchKey(ticketID, to string) string {
+	return notifyBatchPrefix + ticketID + ":" + strings.ToLower(strings.TrimSpace(to))
+}
+
+// deferNotification appends the job to its ticket/recipient batch. The first
+// job of a batch schedules the flush; later jobs within the window join it.
+func deferNotification(ctx context.Context, rdb *redis.Client, c Config, j EmailJob) error {
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	key := notifyBatchKey(*j.TicketID, j.To)
+	flushAt := time.Now().Add(time.Duration(c.NotifyDebounceSeconds) * time.Second)
+	pipe := rdb.TxPipeline()
+	pipe.RPush(ctx, key, b)
+	// Keep the batch around well past its flush time in case the worker is down.
+	pipe.Expire(ctx, key, time.Duration(c.NotifyDebounceSeconds)*time.Second+time.Hour)
+	pipe.ZAddNX(ctx, notifyBatchIndex, redis.Z{Score: float64(flushAt.Unix()), Member: key})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// flushNotificationBatches sends one summarized email for every batch whose
+// debounce window has elapsed. A batch is trimmed only after its digest was
+// sent; when sending fails it stays in place and is rescheduled one debounce
+// window later, until the list's expiry gives up on it.
+func flushNotificationBatches(ctx context.Context, db app.DB, c Config, rdb *redis.Client, send func(context.Context, app.DB, Config, EmailJob) error) error {
+	now := time.Now()
+	keys, err := rdb.ZRangeByScore(ctx, notifyBatchIndex, &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(now.Unix(), 10)}).Result()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		// Only the worker that removes the index entry owns the flush.
+		if n, err := rdb.ZRem(ctx, notifyBatchIndex, key).Result(); err != nil || n == 0 {
+			continue
+		}
+		raws, err := rdb.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			log.Error().Err(err).Str("batch", key).Msg("read notification batch")
+			retryNotificationBatch(ctx, rdb, c, key, now)
+			continue
+		}
+		var jobs []EmailJob
+		for _, raw := range raws {
+			var ej EmailJob
+			if err := json.Unmarshal([]byte(raw), &ej); err == nil {
+				jobs = append(jobs, ej)
+			}
+		}
+		if len(jobs) > 0 {
+			if err := send(ctx, db, c, summarizeNotifications(jobs)); err != nil {
+				log.Error().Err(err).Str("batch", key).Msg("send notification digest")
+				retryNotificationBatch(ctx, rdb, c, key, now)
+				continue
+			}
+		}
+		// Jobs deferred while the digest was sent were appended after the
+		// ones read and scheduled their own flush; keep them.
+		if err := rdb.LTrim(ctx, key, int64(len(raws)), -1).Err(); err != nil {
+			log.Error().Err(err).Str("batch", key).Msg("trim notification batch")
+		}
+	}
+	return nil
+}
+
+// retryNotificationBatch puts a claimed batch back in the index so a later
+// flush tries it again.
+func retryNotificationBatch(ctx context.Context, rdb *redis.Client, c Config, key string, now time.Time) {
+	at := now.Add(time.Duration(max(c.NotifyDebounceSeconds, 1)) * time.Second)
+	if err := rdb.ZAddNX(ctx, notifyBatchIndex, redis.Z{Score: float64(at.Unix()), Member: key}).Err(); err != nil {
+		log.Error().Err(err).Str("batch", key).Msg("reschedule notification batch")
+	}
+}
+
+// summarizeNotifications collapses a batch into a single email. A batch of
+// one is sent unchanged; larger batches use the digest template.
+func summarizeNotifications(jobs []EmailJob) EmailJob {
+	last := jobs[len(jobs)-1]
+	if len(jobs) == 1 {
+		return last
+	}
+	updates := make([]any, 0, len(jobs))
+	for _, j := range jobs {
+		updates = append(updates, j.Data)
+	}
+	data := map[string]any{"Count": len(jobs), "Updates": updates}
+	if m, ok := last.Data.(map[string]any); ok {
+		data["Number"] = m["Number"]
+	}
+	return EmailJob{
+		To:       last.To,
+		Template: last.Template + "_digest",
+		Data:     data,
+		TicketID: last.TicketID,
+		Receipt:  last.Receipt,
+	}
+}
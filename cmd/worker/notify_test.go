@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+func TestNotificationBatching(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	c := Config{NotifyDebounceSeconds: 60}
+	tid := "t1"
+
+	for _, summary := range []string{"status changed to Open", "priority changed to 2", "assignee changed"} {
+		j := EmailJob{To: "Req@Example.com", Template: "ticket_updated", TicketID: &tid, Data: map[string]any{"Number": "HD-1", "Summary": summary}}
+		if !shouldDebounce(c, j) {
+			t.Fatalf("expected ticket_updated to be debounced")
+		}
+		if err := deferNotification(ctx, rdb, c, j); err != nil {
+			t.Fatalf("defer: %v", err)
+		}
+	}
+	if n, _ := rdb.ZCard(ctx, notifyBatchIndex).Result(); n != 1 {
+		t.Fatalf("expected a single pending batch, got %d", n)
+	}
+
+	var sent []EmailJob
+	send := func(ctx context.Context, db apppkg.DB, c Config, j EmailJob) error {
+		sent = append(sent, j)
+		return nil
+	}
+	// Window has not elapsed yet: nothing is sent.
+	if err := flushNotificationBatches(ctx, nil, c, rdb, send); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("expected no sends before window, got %d", len(sent))
+	}
+
+	// Pull the flush time into the past.
+	key := notifyBatchKey(tid, "req@example.com")
+	rdb.ZAdd(ctx, notifyBatchIndex, redis.Z{Score: 0, Member: key})
+	if err := flushNotificationBatches(ctx, nil, c, rdb, send); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("expected one digest, got %d", len(sent))
+	}
+	if sent[0].Template != "ticket_updated_digest" {
+		t.Fatalf("expected digest template, got %q", sent[0].Template)
+	}
+	data := sent[0].Data.(map[string]any)
+	if data["Count"] != 3 || data["Number"] != "HD-1" {
+		t.Fatalf("unexpected digest data: %+v", data)
+	}
+	if mr.Exists(key) {
+		t.Fatalf("expected batch list to be removed")
+	}
+}
+
+func TestNotificationBatchKeptWhenSendFails(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	c := Config{NotifyDebounceSeconds: 60}
+	tid := "t1"
+	for _, summary := range []string{"status changed to Open", "priority changed to 2"} {
+		j := EmailJob{To: "req@example.com", Template: "ticket_updated", TicketID: &tid, Data: map[string]any{"Number": "HD-1", "Summary": summary}}
+		if err := deferNotification(ctx, rdb, c, j); err != nil {
+			t.Fatalf("defer: %v", err)
+		}
+	}
+	key := notifyBatchKey(tid, "req@example.com")
+	rdb.ZAdd(ctx, notifyBatchIndex, redis.Z{Score: 0, Member: key})
+
+	fail := func(ctx context.Context, db apppkg.DB, c Config, j EmailJob) error {
+		return errors.New("smtp down")
+	}
+	if err := flushNotificationBatches(ctx, nil, c, rdb, fail); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if n, _ := rdb.LLen(ctx, key).Result(); n != 2 {
+		t.Fatalf("expected the batch to be kept, got %d jobs", n)
+	}
+	score, err := rdb.ZScore(ctx, notifyBatchIndex, key).Result()
+	if err != nil {
+		t.Fatalf("expected the batch to be rescheduled: %v", err)
+	}
+	if score <= float64(time.Now().Unix()) {
+		t.Fatalf("expected the retry to wait a debounce window, got %v", score)
+	}
+
+	// The retry sends the whole batch and then removes it.
+	var sent []EmailJob
+	send := func(ctx context.Context, db apppkg.DB, c Config, j EmailJob) error {
+		sent = append(sent, j)
+		return nil
+	}
+	rdb.ZAdd(ctx, notifyBatchIndex, redis.Z{Score: 0, Member: key})
+	if err := flushNotificationBatches(ctx, nil, c, rdb, send); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(sent) != 1 || sent[0].Data.(map[string]any)["Count"] != 2 {
+		t.Fatalf("expected one digest of two updates, got %+v", sent)
+	}
+	if mr.Exists(key) {
+		t.Fatalf("expected batch list to be removed")
+	}
+}
+
+func TestShouldDebounce(t *testing.T) {
+	tid := "t1"
+	cases := []struct {
+		name string
+		c    Config
+		j    EmailJob
+		want bool
+	}{
+		{"disabled", Config{}, EmailJob{Template: "ticket_updated", TicketID: &tid}, false},
+		{"no ticket", Config{NotifyDebounceSeconds: 60}, EmailJob{Template: "ticket_updated"}, false},
+		{"created is immediate", Config{NotifyDebounceSeconds: 60}, EmailJob{Template: "ticket_created", TicketID: &tid}, false},
+		{"updated", Config{NotifyDebounceSeconds: 60}, EmailJob{Template: "ticket_updated", TicketID: &tid}, true},
+	}
+	for _, tc := range cases {
+		if got := shouldDebounce(tc.c, tc.j); got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDigestTemplateRenders(t *testing.T) {
+	j := summarizeNotifications([]EmailJob{
+		{To: "a@example.com", Template: "ticket_updated", Data: map[string]any{"Number": "HD-1", "Summary": "status changed to Open"}},
+		{To: "a@example.com", Template: "ticket_updated", Data: map[string]any{"Number": "HD-1"}},
+	})
+	var captured []byte
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		captured = msg
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+	if err := sendEmail(context.Background(), nil, Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "from@example.com"}, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	msg := string(captured)
+	if !strings.Contains(msg, "2 changes") || !strings.Contains(msg, "- status changed to Open") {
+		t.Fatalf("unexpected digest message: %s", msg)
+	}
+}
+
+func TestReadReceiptPixel(t *testing.T) {
+	j := summarizeNotifications([]EmailJob{
+		{To: "a@example.com", Template: "ticket_updated", Data: map[string]any{"Number": "HD-1", "Summary": "<b>status</b>"}, Receipt: "https://old/pixel.gif"},
+		{To: "a@example.com", Template: "ticket_updated", Data: map[string]any{"Number": "HD-1", "Summary": "resolved"}, Receipt: "https://help.example.com/api/receipts/abc.gif"},
+	})
+	if j.Receipt != "https://help.example.com/api/receipts/abc.gif" {
+		t.Fatalf("expected the digest to carry the latest receipt, got %q", j.Receipt)
+	}
+	var captured []byte
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		captured = msg
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+	if err := sendEmail(context.Background(), nil, Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "from@example.com"}, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	msg := string(captured)
+	if !strings.Contains(msg, "multipart/alternative") || !strings.Contains(msg, `<img src="https://help.example.com/api/receipts/abc.gif"`) {
+		t.Fatalf("expected an HTML part with the pixel: %s", msg)
+	}
+	if !strings.Contains(msg, "- <b>status</b>") || !strings.Contains(msg, "&lt;b&gt;status&lt;/b&gt;") {
+		t.Fatalf("expected plain text and escaped HTML bodies: %s", msg)
+	}
+}
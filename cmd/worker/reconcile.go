@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// Issues found by the attachment reconciliation.
+const (
+	issueMissingObject = "missing_object"
+	issueOrphanedRow   = "orphaned_row"
+	issueSizeMismatch  = "size_mismatch"
+	issueMimeMissing   = "mime_missing"
+	issueStatError     = "stat_error"
+)
+
+// reconcileBatch is how many attachment rows are read per query.
+const reconcileBatch = 500
+
+// reconcileMaxIssues caps the issues listed in a run summary; counts cover
+// every issue.
+const reconcileMaxIssues = 500
+
+type reconcileIssue struct {
+	Kind         string `json:"kind"`
+	AttachmentID string `json:"attachment_id"`
+	ObjectKey    string `json:"object_key"`
+	Detail       string `json:"detail,omitempty"`
+	Repaired     bool   `json:"repaired,omitempty"`
+}
+
+// reconcileReport is the summary stored on the job_runs row.
+type reconcileReport struct {
+	Checked   int              `json:"checked"`
+	Repaired  int              `json:"repaired"`
+	Counts    map[string]int   `json:"counts"`
+	Issues    []reconcileIssue `json:"issues"`
+	Truncated bool             `json:"truncated,omitempty"`
+}
+
+func (r *reconcileReport) add(i reconcileIssue) {
+	r.Counts[i.Kind]++
+	if i.Repaired {
+		r.Repaired++
+	}
+	if len(r.Issues) >= reconcileMaxIssues {
+		r.Truncated = true
+		return
+	}
+	r.Issues = append(r.Issues, i)
+}
+
+// reconcileAttachments cross-checks attachment rows against the object store.
+// It reports rows whose object is missing, rows left behind by a ticket or
+// asset that no longer exists, and rows whose size or type disagree with the
+// stored object; with repair the size and an empty type are corrected from
+// the store. Objects without a row are not reported: the bucket also holds
+// avatars, raw mail and exports. Call recordings kept by a telephony
+// provider have no object and are skipped.
+func reconcileAttachments(ctx context.Context, db app.DB, store app.ObjectStore, bucket string, repair bool) (reconcileReport, error) {
+	rep := reconcileReport{Counts: map[string]int{}, Issues: []reconcileIssue{}}
+	if store == nil {
+		return rep, fmt.Errorf("object store not configured")
+	}
+	last := "00000000-0000-0000-0000-000000000000"
+	for {
+		type attachmentRow struct {
+			id, key, mime string
+			size          int64
+			orphaned      bool
+		}
+		rows, err := db.Query(ctx, `select a.id::text, a.object_key, a.bytes, coalesce(a.mime, ''),
+            (a.ticket_id is not null and not exists (select 1 from tickets t where t.id = a.ticket_id))
+            or (a.asset_id is not null and not exists (select 1 from assets s where s.id = a.asset_id))
+            from attachments a where a.id > $1::uuid and a.external_url is null order by a.id limit $2`, last, reconcileBatch)
+		if err != nil {
+			return rep, err
+		}
+		var batch []attachmentRow
+		for rows.Next() {
+			var r attachmentRow
+			if err := rows.Scan(&r.id, &r.key, &r.size, &r.mime, &r.orphaned); err != nil {
+				rows.Close()
+				return rep, err
+			}
+			batch = append(batch, r)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return rep, err
+		}
+		for _, r := range batch {
+			rep.Checked++
+			last = r.id
+			if r.orphaned {
+				rep.add(reconcileIssue{Kind: issueOrphanedRow, AttachmentID: r.id, ObjectKey: r.key})
+			}
+			info, err := store.StatObject(ctx, bucket, r.key, minio.StatObjectOptions{})
+			if err != nil {
+				kind := issueStatError
+				if errors.Is(err, fs.ErrNotExist) || minio.ToErrorResponse(err).Code == "NoSuchKey" {
+					kind = issueMissingObject
+				}
+				rep.add(reconcileIssue{Kind: kind, AttachmentID: r.id, ObjectKey: r.key, Detail: err.Error()})
+				continue
+			}
+			if info.Size != r.size {
+				i := reconcileIssue{Kind: issueSizeMismatch, AttachmentID: r.id, ObjectKey: r.key,
+					Detail: "row " + strconv.FormatInt(r.size, 10) + " bytes, object " + strconv.FormatInt(info.Size, 10)}
+				if repair {
+					i.Repaired = repairAttachment(ctx, db, r.id, "bytes", info.Size)
+				}
+				rep.add(i)
+			}
+			if r.mime == "" {
+				i := reconcileIssue{Kind: issueMimeMissing, AttachmentID: r.id, ObjectKey: r.key}
+				if repair {
+					if mt := objectType(ctx, store, bucket, r.key, info); mt != "" {
+						i.Detail = mt
+						i.Repaired = repairAttachment(ctx, db, r.id, "mime", mt)
+					}
+				}
+				rep.add(i)
+			}
+		}
+		if len(batch) < reconcileBatch {
+			return rep, nil
+		}
+	}
+}
+
+// repairAttachment sets one metadata column of an attachment, reporting
+// whether it succeeded.
+func repairAttachment(ctx context.Context, db app.DB, id, column string, v any) bool {
+	if _, err := db.Exec(ctx, `update attachments set `+column+`=$2 where id=$1`, id, v); err != nil {
+		log.Error().Err(err).Str("attachment_id", id).Str("column", column).Msg("repair attachment")
+		return false
+	}
+	return true
+}
+
+// objectType returns the content type recorded by the store, falling back to
+// sniffing the object's first bytes. It returns "" when neither says more
+// than application/octet-stream.
+func objectType(ctx context.Context, store app.ObjectStore, bucket, key string, info minio.ObjectInfo) string {
+	if info.ContentType != "" && info.ContentType != "application/octet-stream" {
+		return info.ContentType
+	}
+	rc, err := app.OpenObject(ctx, store, bucket, key)
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(rc, buf)
+	if mt := http.DetectContentType(buf[:n]); n > 0 && mt != "application/octet-stream" {
+		return mt
+	}
+	return ""
+}
+
+// runReconcileAttachments runs the reconciliation and records it in job_runs.
+// Scheduled runs pass an empty runID and get a row of their own; requested
+// runs reuse the row the API created.
+func runReconcileAttachments(ctx context.Context, c Config, db app.DB, store app.ObjectStore, j jobs.ReconcileAttachments) error {
+	params, _ := json.Marshal(map[string]bool{"repair": j.Repair})
+	if j.RunID == "" {
+		if err := db.QueryRow(ctx, `insert into job_runs (job, status, trigger, params, started_at)
+            values ($1, 'running', 'schedule', $2::jsonb, now()) returning id::text`,
+			jobs.TypeReconcileAttachments, string(params)).Scan(&j.RunID); err != nil {
+			return fmt.Errorf("record job run: %w", err)
+		}
+	} else if _, err := db.Exec(ctx, `update job_runs set status='running', started_at=now() where id=$1`, j.RunID); err != nil {
+		return fmt.Errorf("record job run: %w", err)
+	}
+	rep, err := reconcileAttachments(ctx, db, store, c.MinIOBucket, j.Repair)
+	summary, _ := json.Marshal(rep)
+	status, msg := "succeeded", ""
+	if err != nil {
+		status, msg = "failed", err.Error()
+	}
+	if _, uerr := db.Exec(ctx, `update job_runs set status=$2, finished_at=now(), summary=$3::jsonb, error=nullif($4,'') where id=$1`,
+		j.RunID, status, string(summary), msg); uerr != nil {
+		log.Error().Err(uerr).Str("run_id", j.RunID).Msg("record job run result")
+	}
+	if err != nil {
+		return err
+	}
+	if len(rep.Counts) > 0 {
+		log.Warn().Int("checked", rep.Checked).Int("repaired", rep.Repaired).Interface("counts", rep.Counts).Msg("attachment reconciliation found issues")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/minio/minio-go/v7"
+
+	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestReconcileAttachments(t *testing.T) {
+	ctx := context.Background()
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	put := func(key, body string) {
+		if _, err := store.PutObject(ctx, "bkt", key, strings.NewReader(body), int64(len(body)), minio.PutObjectOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("ok", "hello")
+	put("short", "hello world")
+	put("untyped", "%PDF-1.4 test")
+	put("orphan", "x")
+
+	type row struct {
+		id, key string
+		size    int64
+		mime    string
+		orphan  bool
+	}
+	data := []row{
+		{"00000000-0000-0000-0000-000000000001", "ok", 5, "text/plain", false},
+		{"00000000-0000-0000-0000-000000000002", "gone", 3, "text/plain", false},
+		{"00000000-0000-0000-0000-000000000003", "short", 5, "text/plain", false},
+		{"00000000-0000-0000-0000-000000000004", "untyped", 13, "", false},
+		{"00000000-0000-0000-0000-000000000005", "orphan", 1, "text/plain", true},
+	}
+
+	for _, repair := range []bool{false, true} {
+		updates := map[string]any{}
+		db := &testutil.MockDB{
+			QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+				i := -1
+				return &testutil.MockRows{
+					NextFunc: func() bool { i++; return i < len(data) },
+					ScanFunc: func(dest ...any) error {
+						r := data[i]
+						*dest[0].(*string) = r.id
+						*dest[1].(*string) = r.key
+						*dest[2].(*int64) = r.size
+						*dest[3].(*string) = r.mime
+						*dest[4].(*bool) = r.orphan
+						return nil
+					},
+				}, nil
+			},
+			ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+				col := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(sql, "update attachments set "), "=", 2)[0])
+				updates[args[0].(string)+" "+col] = args[1]
+				return pgconn.NewCommandTag("UPDATE 1"), nil
+			},
+		}
+		rep, err := reconcileAttachments(ctx, db, store, "bkt", repair)
+		if err != nil {
+			t.Fatalf("reconcile (repair=%v): %v", repair, err)
+		}
+		if rep.Checked != len(data) {
+			t.Fatalf("checked %d rows, want %d", rep.Checked, len(data))
+		}
+		for kind, want := range map[string]int{issueMissingObject: 1, issueSizeMismatch: 1, issueMimeMissing: 1, issueOrphanedRow: 1} {
+			if rep.Counts[kind] != want {
+				t.Fatalf("repair=%v: %s = %d, want %d (%v)", repair, kind, rep.Counts[kind], want, rep.Counts)
+			}
+		}
+		if !repair {
+			if len(updates) != 0 || rep.Repaired != 0 {
+				t.Fatalf("report-only run changed rows: %v", updates)
+			}
+			continue
+		}
+		if updates["00000000-0000-0000-0000-000000000003 bytes"] != int64(11) {
+			t.Fatalf("size not repaired: %v", updates)
+		}
+		if updates["00000000-0000-0000-0000-000000000004 mime"] != "application/pdf" {
+			t.Fatalf("mime not repaired: %v", updates)
+		}
+		if rep.Repaired != 2 {
+			t.Fatalf("repaired = %d, want 2", rep.Repaired)
+		}
+	}
+}
+
+func TestReconcileAttachmentsRecordsRun(t *testing.T) {
+	ctx := context.Background()
+	var result []any
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			return &testutil.MockRows{}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string) = "run-1"
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "finished_at") {
+				result = args
+			}
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	store := &apppkg.FsObjectStore{Base: t.TempDir()}
+	if err := runReconcileAttachments(ctx, Config{MinIOBucket: "bkt"}, db, store, jobs.ReconcileAttachments{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 4 || result[0] != "run-1" || result[1] != "succeeded" || !strings.Contains(result[2].(string), `"checked":0`) {
+		t.Fatalf("unexpected run result %v", result)
+	}
+
+	result = nil
+	if err := runReconcileAttachments(ctx, Config{MinIOBucket: "bkt"}, db, nil, jobs.ReconcileAttachments{RunID: "run-2"}); err == nil {
+		t.Fatal("expected error without an object store")
+	}
+	if len(result) != 4 || result[0] != "run-2" || result[1] != "failed" {
+		t.Fatalf("unexpected run result %v", result)
+	}
+}
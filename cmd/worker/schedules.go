@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/cron"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/ooo"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/s3"
+)
+
+// scheduleEvery is how often the worker looks for due job_schedules rows.
+const scheduleEvery = 15 * time.Second
+
+// scheduledTask runs one recurring task.
+type scheduledTask func(ctx context.Context) error
+
+// scheduledTasks returns the worker's recurring tasks by job_schedules
+// name. Tasks whose settings leave them off do nothing when due.
+func scheduledTasks(c Config, db workerDB, store app.ObjectStore, rdb *redis.Client) map[string]scheduledTask {
+	return map[string]scheduledTask{
+		"sla_clocks": func(ctx context.Context) error { return updateSLAClocks(ctx, db) },
+		"ooo_reassignments": func(ctx context.Context) error {
+			n, err := ooo.SuggestReassignments(ctx, db)
+			if n > 0 {
+				log.Info().Int64("tickets", n).Msg("suggested reassignment for out-of-office assignees")
+			}
+			return err
+		},
+		"ticket_aging":    func(ctx context.Context) error { return ageTickets(ctx, db) },
+		"contract_expiry": func(ctx context.Context) error { return warnExpiredContracts(ctx, db) },
+		"auto_close": func(ctx context.Context) error {
+			if c.AutoCloseResolvedDays <= 0 {
+				return nil
+			}
+			return autoCloseTickets(ctx, db, c)
+		},
+		"partition_maintenance": func(ctx context.Context) error {
+			return maintainPartitions(ctx, db, c.PartitionDetachAfterMonths)
+		},
+		"warranty_refresh": func(ctx context.Context) error {
+			if c.WarrantyRefreshDays <= 0 {
+				return nil
+			}
+			return queueWarrantyLookups(ctx, db, warrantyProviders(c), c.WarrantyRefreshDays)
+		},
+		"security_log_prune": func(ctx context.Context) error {
+			if c.SecurityLogRetentionDays <= 0 {
+				return nil
+			}
+			return pruneSecurityLog(ctx, db, c.SecurityLogRetentionDays)
+		},
+		"audit_export": func(ctx context.Context) error {
+			if c.Storage[s3.ClassAudit].Bucket == "" {
+				return nil
+			}
+			return runAuditExport(ctx, c, db, store, rdb)
+		},
+	}
+}
+
+// dueSchedule is a job_schedules row whose next run has come.
+type dueSchedule struct {
+	name, expr, timezone string
+}
+
+// queueDueSchedules claims each due job_schedules row by moving its
+// next_run_at to the cron expression's next time, and queues a
+// scheduled_task job for it through the outbox in the same transaction.
+// Workers racing for a row cannot both claim it. Rows whose expression or
+// time zone no longer parses are skipped.
+func queueDueSchedules(ctx context.Context, db app.DB, now time.Time) (int, error) {
+	rows, err := db.Query(ctx, `select name, cron, timezone from job_schedules
+        where enabled and (next_run_at is null or next_run_at <= $1) order by name`, now)
+	if err != nil {
+		return 0, fmt.Errorf("load due schedules: %w", err)
+	}
+	var due []dueSchedule
+	for rows.Next() {
+		var d dueSchedule
+		if err := rows.Scan(&d.name, &d.expr, &d.timezone); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	queued := 0
+	var errs []error
+	for _, d := range due {
+		next, err := nextRun(d.expr, d.timezone, now)
+		if err != nil {
+			log.Error().Err(err).Str("schedule", d.name).Msg("invalid job schedule")
+			continue
+		}
+		claimed := false
+		err = app.InTx(ctx, db, func(tx app.DB) error {
+			tag, err := tx.Exec(ctx, `update job_schedules set next_run_at = $2, last_run_at = $3
+                where name = $1 and enabled and (next_run_at is null or next_run_at <= $3)`, d.name, next, now)
+			if err != nil || tag.RowsAffected() == 0 {
+				return err
+			}
+			claimed = true
+			key := "schedule:" + d.name + ":" + strconv.FormatInt(now.Unix(), 10)
+			return outbox.AddJob(ctx, tx, key, "", jobs.TypeScheduledTask, jobs.ScheduledTask{Name: d.name})
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("queue schedule %s: %w", d.name, err))
+			continue
+		}
+		if claimed {
+			queued++
+		}
+	}
+	return queued, errors.Join(errs...)
+}
+
+// nextRun returns when expr next fires after now in timezone. An
+// expression that never fires, such as one for 30 February, parks the
+// schedule a century out.
+func nextRun(expr, timezone string, now time.Time) (time.Time, error) {
+	s, err := cron.Parse(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time zone %q: %w", timezone, err)
+	}
+	next := s.Next(now.In(loc))
+	if next.IsZero() {
+		next = now.AddDate(100, 0, 0)
+	}
+	return next, nil
+}
+
+// runScheduledTask runs the task a scheduled_task job names and records
+// how it went on its job_schedules row.
+func runScheduledTask(ctx context.Context, db app.DB, tasks map[string]scheduledTask, j jobs.ScheduledTask) error {
+	task, ok := tasks[j.Name]
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("unknown scheduled task %q", j.Name))
+	}
+	start := time.Now()
+	err := task(ctx)
+	var errText *string
+	if err != nil {
+		e := err.Error()
+		errText = &e
+	}
+	if _, uerr := db.Exec(ctx, `update job_schedules set last_finished_at = now(), last_duration_ms = $2, last_error = $3
+        where name = $1`, j.Name, time.Since(start).Milliseconds(), errText); uerr != nil {
+		log.Error().Err(uerr).Str("schedule", j.Name).Msg("record scheduled task run")
+	}
+	if err != nil {
+		return fmt.Errorf("scheduled task %s: %w", j.Name, err)
+	}
+	return nil
+}
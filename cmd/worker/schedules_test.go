@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestQueueDueSchedules(t *testing.T) {
+	due := [][3]string{{"audit_export", "0 3 * * *", "America/New_York"}, {"broken", "61 * * * *", "UTC"}, {"sla_clocks", "* * * * *", "UTC"}}
+	var claims [][]any
+	var queued []jobs.ScheduledTask
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(due) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string), *dest[1].(*string), *dest[2].(*string) = due[i-1][0], due[i-1][1], due[i-1][2]
+					return nil
+				},
+			}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.Contains(sql, "outbox") {
+				var j jobs.Job
+				var st jobs.ScheduledTask
+				_ = json.Unmarshal([]byte(args[2].(string)), &j)
+				_ = json.Unmarshal(j.Data, &st)
+				queued = append(queued, st)
+				return pgconn.NewCommandTag("INSERT 0 1"), nil
+			}
+			claims = append(claims, args)
+			// Another worker claimed sla_clocks first.
+			if args[0] == "sla_clocks" {
+				return pgconn.NewCommandTag("UPDATE 0"), nil
+			}
+			return pgconn.NewCommandTag("UPDATE 1"), nil
+		},
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	n, err := queueDueSchedules(context.Background(), db, now)
+	if err != nil || n != 1 {
+		t.Fatalf("queued %d (%v)", n, err)
+	}
+	if len(claims) != 2 || len(queued) != 1 || queued[0].Name != "audit_export" {
+		t.Fatalf("claims %v, queued %v", claims, queued)
+	}
+	// 3am in New York is 7am UTC while daylight saving lasts.
+	if next := claims[0][1].(time.Time); !next.Equal(time.Date(2026, 10, 17, 7, 0, 0, 0, time.UTC)) {
+		t.Fatalf("next run = %v", next)
+	}
+}
+
+func TestRunScheduledTask(t *testing.T) {
+	var recorded []any
+	db := &testutil.MockDB{ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		recorded = args
+		return pgconn.CommandTag{}, nil
+	}}
+	tasks := map[string]scheduledTask{"fails": func(ctx context.Context) error { return errors.New("boom") }}
+	if err := runScheduledTask(context.Background(), db, tasks, jobs.ScheduledTask{Name: "fails"}); err == nil || *recorded[2].(*string) != "boom" {
+		t.Fatalf("expected the failure recorded, got %v (%v)", recorded, err)
+	}
+	if err := runScheduledTask(context.Background(), db, tasks, jobs.ScheduledTask{Name: "gone"}); !jobs.IsPermanent(err) {
+		t.Fatalf("expected an unknown task to fail for good, got %v", err)
+	}
+	if _, ok := scheduledTasks(Config{}, nil, nil, nil)["audit_export"]; !ok {
+		t.Fatal("audit export is not a scheduled task")
+	}
+}
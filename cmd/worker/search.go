@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// searchIndex is a Postgres full-text index backing one of the searches.
+type searchIndex struct {
+	Name  string
+	Table string
+	// Def recreates the index when it is missing, as its migration did.
+	Def string
+}
+
+// searchIndexes are the indexes search_reindex maintains. Keep them in step
+// with the migrations that create them.
+var searchIndexes = []searchIndex{
+	{"tickets_fts", "tickets", `create index if not exists tickets_fts on tickets using gin (to_tsvector('english', coalesce(title,'') || ' ' || coalesce(description,'')))`},
+	{"assets_fts", "assets", `create index if not exists assets_fts on assets using gin (to_tsvector('english', coalesce(name,'') || ' ' || coalesce(description,'') || ' ' || coalesce(asset_tag,'') || ' ' || coalesce(serial_number,'') || ' ' || coalesce(model,'') || ' ' || coalesce(manufacturer,'')))`},
+}
+
+// reindexProgressEvery is how often a running build's progress is copied
+// to the job run.
+var reindexProgressEvery = 5 * time.Second
+
+// searchReport is the job_runs summary of a reindex. It is rewritten as the
+// run goes so GET /admin/jobs/:id shows how far it has got.
+type searchReport struct {
+	Mode    string              `json:"mode"`
+	Done    int                 `json:"done"`
+	Total   int                 `json:"total"`
+	Indexes []searchIndexReport `json:"indexes"`
+	// Current is the build in progress, as pg_stat_progress_create_index
+	// reports it.
+	Current *buildProgress `json:"current,omitempty"`
+}
+
+type searchIndexReport struct {
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	// Action is pending, rebuilt, created, or skipped for a valid index in
+	// an incremental run.
+	Action string `json:"action"`
+	// Warmed is set once the index has been loaded into shared buffers
+	// with pg_prewarm; false when the extension is not installed.
+	Warmed bool   `json:"warmed"`
+	TookMS int64  `json:"took_ms,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type buildProgress struct {
+	Index       string `json:"index"`
+	Phase       string `json:"phase"`
+	BlocksDone  int64  `json:"blocks_done"`
+	BlocksTotal int64  `json:"blocks_total"`
+	TuplesDone  int64  `json:"tuples_done"`
+	TuplesTotal int64  `json:"tuples_total"`
+}
+
+// runSearchReindex rebuilds the search indexes, all of them in a full run
+// and only missing or invalid ones in an incremental run, then refreshes the
+// tables' planner statistics and warms every index, rebuilt or not.
+// Rebuilds use REINDEX CONCURRENTLY so searches and writes carry on; a
+// missing index is recreated with a plain CREATE INDEX, which blocks writes
+// to its table while it builds. Progress and the outcome are recorded on
+// the job run the API created.
+func runSearchReindex(ctx context.Context, db app.DB, j jobs.SearchReindex) error {
+	if _, err := db.Exec(ctx, `update job_runs set status='running', started_at=now() where id=$1`, j.RunID); err != nil {
+		return fmt.Errorf("record job run: %w", err)
+	}
+	rep := &searchReport{Mode: j.Mode, Total: len(searchIndexes)}
+	for _, idx := range searchIndexes {
+		rep.Indexes = append(rep.Indexes, searchIndexReport{Name: idx.Name, Table: idx.Table, Action: "pending"})
+	}
+	var mu sync.Mutex
+	save := func() {
+		mu.Lock()
+		b, _ := json.Marshal(rep)
+		mu.Unlock()
+		if _, err := db.Exec(ctx, `update job_runs set summary=$2::jsonb where id=$1`, j.RunID, string(b)); err != nil {
+			log.Error().Err(err).Str("run_id", j.RunID).Msg("record reindex progress")
+		}
+	}
+	save()
+
+	var prewarm bool
+	if err := db.QueryRow(ctx, `select exists(select 1 from pg_extension where extname = 'pg_prewarm')`).Scan(&prewarm); err != nil {
+		log.Warn().Err(err).Msg("check pg_prewarm")
+	}
+	var failed []string
+	for i, idx := range searchIndexes {
+		start := time.Now()
+		action, err := reindexOne(ctx, db, idx, j.Mode, func(p *buildProgress) {
+			mu.Lock()
+			rep.Current = p
+			mu.Unlock()
+			save()
+		})
+		if err == nil {
+			if _, aerr := db.Exec(ctx, `analyze `+pgx.Identifier{idx.Table}.Sanitize()); aerr != nil {
+				log.Warn().Err(aerr).Str("table", idx.Table).Msg("analyze")
+			}
+		}
+		warmed := false
+		if err == nil && prewarm {
+			if _, werr := db.Exec(ctx, `select pg_prewarm(relid) from pg_partition_tree($1::regclass) where isleaf`, idx.Name); werr != nil {
+				log.Warn().Err(werr).Str("index", idx.Name).Msg("prewarm")
+			} else {
+				warmed = true
+			}
+		}
+		mu.Lock()
+		r := &rep.Indexes[i]
+		r.Action, r.Warmed, r.TookMS = action, warmed, time.Since(start).Milliseconds()
+		if err != nil {
+			r.Error = err.Error()
+			failed = append(failed, idx.Name)
+		}
+		rep.Done++
+		rep.Current = nil
+		mu.Unlock()
+		save()
+	}
+
+	status, msg := "succeeded", ""
+	if len(failed) > 0 {
+		status, msg = "failed", fmt.Sprintf("rebuilding %v failed", failed)
+	}
+	if _, err := db.Exec(ctx, `update job_runs set status=$2, finished_at=now(), error=nullif($3,'') where id=$1`, j.RunID, status, msg); err != nil {
+		log.Error().Err(err).Str("run_id", j.RunID).Msg("record job run result")
+	}
+	if msg != "" {
+		return errors.New(msg)
+	}
+	log.Info().Str("mode", j.Mode).Int("indexes", rep.Total).Msg("search indexes reindexed")
+	return nil
+}
+
+// reindexOne rebuilds idx when mode calls for it and reports what it did.
+// progress is called periodically while a build runs.
+func reindexOne(ctx context.Context, db app.DB, idx searchIndex, mode string, progress func(*buildProgress)) (string, error) {
+	var exists, valid bool
+	if err := db.QueryRow(ctx, `select to_regclass($1) is not null,
+            coalesce((select bool_and(i.indisvalid) from pg_partition_tree(to_regclass($1)) p
+                      join pg_index i on i.indexrelid = p.relid where p.isleaf), true)`, idx.Name).Scan(&exists, &valid); err != nil {
+		return "pending", err
+	}
+	var stmt, action string
+	switch {
+	case !exists:
+		stmt, action = idx.Def, "created"
+	case mode == jobs.SearchReindexFull || !valid:
+		stmt, action = `reindex index concurrently `+pgx.Identifier{idx.Name}.Sanitize(), "rebuilt"
+	default:
+		return "skipped", nil
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(reindexProgressEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				p := buildProgress{Index: idx.Name}
+				err := db.QueryRow(ctx, `select phase, blocks_done, blocks_total, tuples_done, tuples_total
+                    from pg_stat_progress_create_index where datid = (select oid from pg_database where datname = current_database())
+                    order by pid limit 1`).Scan(&p.Phase, &p.BlocksDone, &p.BlocksTotal, &p.TuplesDone, &p.TuplesTotal)
+				if err == nil {
+					progress(&p)
+				}
+			}
+		}
+	}()
+	_, err := db.Exec(ctx, stmt)
+	close(done)
+	wg.Wait()
+	if err != nil {
+		return "pending", err
+	}
+	return action, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+func TestRunSearchReindexIncremental(t *testing.T) {
+	var stmts []string
+	var summary, status string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "pg_extension") {
+					*dest[0].(*bool) = true
+					return nil
+				}
+				// tickets_fts is valid; assets_fts was left invalid by a
+				// failed concurrent build.
+				*dest[0].(*bool) = true
+				*dest[1].(*bool) = args[0] == "tickets_fts"
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "summary=$2"):
+				summary = args[1].(string)
+			case strings.Contains(sql, "finished_at"):
+				status = args[1].(string)
+			case strings.Contains(sql, "job_runs"):
+			default:
+				stmts = append(stmts, sql)
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := runSearchReindex(context.Background(), db, jobs.SearchReindex{RunID: "run-1", Mode: jobs.SearchReindexIncremental}); err != nil {
+		t.Fatalf("runSearchReindex: %v", err)
+	}
+	if status != "succeeded" {
+		t.Fatalf("expected the run to succeed, got %q", status)
+	}
+	if len(stmts) != 5 || stmts[2] != `reindex index concurrently "assets_fts"` {
+		t.Fatalf("expected only assets_fts rebuilt, got %q", stmts)
+	}
+	var rep searchReport
+	if err := json.Unmarshal([]byte(summary), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Done != 2 || rep.Indexes[0].Action != "skipped" || rep.Indexes[1].Action != "rebuilt" || !rep.Indexes[1].Warmed {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/sender"
+)
+
+func TestSendEmailUsesTicketIdentity(t *testing.T) {
+	db := &testutil.MockDB{QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+		return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+			if strings.Contains(sql, "email_identity") {
+				*dest[0].(*sender.Identity) = sender.Identity{FromName: "Acme Billing", ReplyTo: "billing@acme.example"}
+				*dest[1].(*sender.Identity) = sender.Identity{FromAddress: "support@acme.example", Signature: "Acme Support\nMon-Fri 9-5"}
+			}
+			return nil
+		}}
+	}}
+	var envelope string
+	var captured []byte
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		envelope, captured = from, msg
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+	c := Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "helpdesk@example.com"}
+
+	tid := "t1"
+	j := EmailJob{To: "req@example.com", Template: "ticket_created", TicketID: &tid, Data: map[string]any{"Number": "HD-1"}}
+	if err := sendEmail(context.Background(), db, c, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	msg := string(captured)
+	if envelope != "support@acme.example" || !strings.Contains(msg, "From: \"Acme Billing\" <support@acme.example>\r\n") {
+		t.Fatalf("unexpected sender %q: %s", envelope, msg)
+	}
+	if !strings.Contains(msg, "Reply-To: billing@acme.example\r\n") || !strings.HasSuffix(msg, "\n-- \nAcme Support\nMon-Fri 9-5\n") {
+		t.Fatalf("expected Reply-To and signature: %s", msg)
+	}
+
+	// Mail that is not about a ticket keeps the global sender.
+	j.TicketID = nil
+	if err := sendEmail(context.Background(), db, c, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if msg := string(captured); envelope != "helpdesk@example.com" || !strings.Contains(msg, "From: helpdesk@example.com\r\n") || strings.Contains(msg, "Reply-To") {
+		t.Fatalf("expected the global sender: %s", msg)
+	}
+}
+
+func TestSendEmailSandbox(t *testing.T) {
+	var rcpt []string
+	var captured []byte
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		rcpt, captured = to, msg
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+	c := Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "helpdesk@example.com", MailSandboxTo: "staging@example.com"}
+	j := EmailJob{To: "customer@example.org", Template: "ticket_created", Data: map[string]any{"Number": "HD-1"}, Receipt: "https://help.example.com/api/receipts/abc.gif"}
+	if err := sendEmail(context.Background(), nil, c, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	msg := string(captured)
+	if len(rcpt) != 1 || rcpt[0] != "staging@example.com" || !strings.Contains(msg, "To: staging@example.com\r\n") {
+		t.Fatalf("expected delivery to the sandbox only, got %v: %s", rcpt, msg)
+	}
+	if !strings.Contains(msg, "X-Original-To: customer@example.org\r\n") || !strings.Contains(msg, "Subject: [sandbox] [HD-1] Ticket created\r\n") {
+		t.Fatalf("expected the intended recipient to be recorded: %s", msg)
+	}
+	if strings.Contains(msg, "receipts/abc.gif") {
+		t.Fatalf("sandboxed mail must not carry read receipts: %s", msg)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+)
+
+// maxReferences caps the References header of outbound ticket email. The
+// thread's first message is always kept so clients can still find the root.
+const maxReferences = 10
+
+var (
+	messageIDRe   = regexp.MustCompile(`<[^<>\s]+>`)
+	ticketTokenRe = regexp.MustCompile(`\[(HD-\d+)\]`)
+)
+
+// parseMessageIDs returns the message IDs of an In-Reply-To or References
+// header, angle brackets included. IDs some clients send without brackets
+// are taken as whitespace-separated words.
+func parseMessageIDs(header string) []string {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil
+	}
+	if ids := messageIDRe.FindAllString(header, -1); len(ids) > 0 {
+		return ids
+	}
+	var ids []string
+	for _, f := range strings.Fields(header) {
+		ids = append(ids, "<"+strings.Trim(f, "<>")+">")
+	}
+	return ids
+}
+
+// newMessageID returns a Message-ID for an email sent from address.
+func newMessageID(address string) string {
+	domain := "helpdesk.local"
+	if i := strings.LastIndex(address, "@"); i >= 0 && i < len(address)-1 {
+		domain = address[i+1:]
+	}
+	return "<" + uuid.NewString() + "@" + domain + ">"
+}
+
+// threadTicket finds the ticket an inbound email replies to. A known
+// Message-ID in In-Reply-To or References decides; failing that, an
+// [HD-123] token in the subject does, but only for mail from the ticket's
+// requester or a CC so a guessed number cannot post to someone else's
+// ticket. It returns "" for a new conversation.
+func threadTicket(ctx context.Context, db app.DB, ids []string, subject, from string) (string, error) {
+	var ticketID string
+	if len(ids) > 0 {
+		err := db.QueryRow(ctx, `select ticket_id::text from ticket_email_messages where message_id = any($1)
+            order by created_at desc limit 1`, ids).Scan(&ticketID)
+		if err == nil {
+			return ticketID, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return "", err
+		}
+	}
+	match := ticketTokenRe.FindStringSubmatch(subject)
+	if match == nil || from == "" {
+		return "", nil
+	}
+	err := db.QueryRow(ctx, `select t.id::text from tickets t left join requesters r on r.id = t.requester_id
+        where t.number = $1 and (lower(r.email) = lower($2)
+            or exists (select 1 from ticket_ccs c where c.ticket_id = t.id and lower(c.email) = lower($2)))`, match[1], from).Scan(&ticketID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return ticketID, err
+}
+
+// recordThreadMessage remembers an email of a ticket's thread. Failures
+// are logged; they only cost threading of later replies.
+func recordThreadMessage(ctx context.Context, db app.DB, ticketID, messageID, direction, inReplyTo string, refs []string) {
+	if messageID == "" {
+		return
+	}
+	if refs == nil {
+		refs = []string{}
+	}
+	if _, err := db.Exec(ctx, `insert into ticket_email_messages (message_id, ticket_id, direction, in_reply_to, refs)
+        values ($1, $2, $3, nullif($4, ''), $5) on conflict (message_id) do nothing`, messageID, ticketID, direction, inReplyTo, refs); err != nil {
+		log.Error().Err(err).Str("ticket_id", ticketID).Str("message_id", messageID).Msg("record email thread message")
+	}
+}
+
+// threadHeaders returns the In-Reply-To and References of the next email
+// sent about a ticket: the thread's latest message and its messages so
+// far, oldest first.
+func threadHeaders(ctx context.Context, db app.DB, ticketID string) (string, []string) {
+	var ids []string
+	if err := db.QueryRow(ctx, `select coalesce(array_agg(message_id order by created_at), '{}') from ticket_email_messages
+        where ticket_id = $1`, ticketID).Scan(&ids); err != nil || len(ids) == 0 {
+		return "", nil
+	}
+	if len(ids) > maxReferences {
+		ids = append(ids[:1], ids[len(ids)-maxReferences+1:]...)
+	}
+	return ids[len(ids)-1], ids
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+)
+
+func TestSendEmailThreadHeaders(t *testing.T) {
+	thread := []string{"<root@example.com>"}
+	for i := 1; i <= 12; i++ {
+		thread = append(thread, fmt.Sprintf("<m%d@example.com>", i))
+	}
+	var recorded []any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "ticket_email_messages") {
+					*dest[0].(*[]string) = thread
+				}
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			if strings.HasPrefix(sql, "insert into ticket_email_messages") {
+				recorded = args
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	var captured string
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		captured = string(msg)
+		return nil
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+
+	tid := "t1"
+	j := EmailJob{To: "req@example.com", Template: "ticket_created", TicketID: &tid, Data: map[string]any{"Number": "HD-1"}}
+	if err := sendEmail(context.Background(), db, Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "helpdesk@example.com"}, j); err != nil {
+		t.Fatalf("sendEmail: %v", err)
+	}
+	if !strings.Contains(captured, "In-Reply-To: <m12@example.com>\r\n") {
+		t.Fatalf("expected a reply to the latest message: %s", captured)
+	}
+	refs := "References: <root@example.com> <m4@example.com>"
+	if !strings.Contains(captured, refs) || strings.Contains(captured, "<m3@example.com>") {
+		t.Fatalf("expected the root and the latest references: %s", captured)
+	}
+	if recorded == nil || !strings.Contains(captured, "Message-ID: "+recorded[0].(string)+"\r\n") || !strings.HasSuffix(recorded[0].(string), "@example.com>") {
+		t.Fatalf("expected the Message-ID recorded on the ticket, got %v: %s", recorded, captured)
+	}
+	if recorded[1] != "t1" || recorded[2] != "outbound" {
+		t.Fatalf("recorded %v", recorded)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/audit"
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/ratelimit"
+	"github.com/mark3748/helpdesk-go/internal/warranty"
+)
+
+// warrantyActor attributes audit events raised by warranty lookups.
+var warrantyActor = actor.System("warranty")
+
+// warrantyBatch caps how many assets one scheduled refresh queues.
+const warrantyBatch = 500
+
+// warrantyProviders builds the warranty providers from c.
+func warrantyProviders(c Config) warranty.Providers {
+	return warranty.Providers{
+		Dell:   warranty.Dell{ClientID: c.DellClientID, ClientSecret: c.DellClientSecret},
+		Lenovo: warranty.Lenovo{ClientID: c.LenovoClientID},
+		Apple:  warranty.AppleGSX{SoldTo: c.AppleGSXSoldTo, Token: c.AppleGSXToken},
+	}
+}
+
+// warrantyLookup runs warranty_lookup jobs. allow takes a token from the
+// vendor's rate limit and later requeues a job after a delay; both go
+// through Redis outside tests.
+type warrantyLookup struct {
+	db        app.DB
+	providers interface {
+		For(vendor string) (warranty.Provider, error)
+	}
+	allow func(ctx context.Context, vendor string, perMinute int) (bool, error)
+	later func(ctx context.Context, after time.Duration, j jobs.WarrantyLookup) error
+}
+
+func newWarrantyLookup(c Config, db app.DB, rdb *redis.Client) warrantyLookup {
+	return warrantyLookup{
+		db:        db,
+		providers: warrantyProviders(c),
+		allow: func(ctx context.Context, vendor string, perMinute int) (bool, error) {
+			return ratelimit.New(rdb, perMinute, time.Minute, "warranty:").Allow(ctx, vendor)
+		},
+		later: func(ctx context.Context, after time.Duration, j jobs.WarrantyLookup) error {
+			return jobs.Schedule(ctx, rdb, time.Now().Add(after), "", jobs.TypeWarrantyLookup, j)
+		},
+	}
+}
+
+// run fetches the warranty of j's asset from its manufacturer and stores
+// it on the asset. Lookups over the vendor's rate limit wait for a token in
+// the delayed queue; transient failures are retried up to three times and
+// the last failure is kept in warranty_error.
+func (w warrantyLookup) run(ctx context.Context, j jobs.WarrantyLookup) error {
+	var serial, manufacturer *string
+	err := w.db.QueryRow(ctx, `select serial_number, manufacturer from assets where id::text = $1`, j.AssetID).Scan(&serial, &manufacturer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Debug().Str("asset", j.AssetID).Msg("asset gone; skipping warranty lookup")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load asset: %w", err)
+	}
+	vendor := warranty.Vendor(derefOr(manufacturer))
+	if derefOr(serial) == "" {
+		return w.fail(ctx, j.AssetID, vendor, errors.New("asset has no serial number"))
+	}
+	if vendor == "" {
+		return w.fail(ctx, j.AssetID, vendor, fmt.Errorf("no warranty provider for manufacturer %q", derefOr(manufacturer)))
+	}
+	p, err := w.providers.For(vendor)
+	if err != nil {
+		return w.fail(ctx, j.AssetID, vendor, err)
+	}
+	if ok, err := w.allow(ctx, vendor, p.PerMinute()); err != nil || !ok {
+		return w.later(ctx, max(time.Second, time.Minute/time.Duration(p.PerMinute())), j)
+	}
+	res, err := p.Lookup(ctx, *serial)
+	if err != nil {
+		if !warranty.Permanent(err) && j.Retries < 3 {
+			j.Retries++
+			if err := w.later(ctx, time.Duration(j.Retries)*5*time.Minute, j); err != nil {
+				log.Error().Err(err).Str("asset", j.AssetID).Msg("requeue warranty lookup")
+			}
+			return jobs.Rescheduled(fmt.Errorf("warranty lookup: %w", err))
+		}
+		return w.fail(ctx, j.AssetID, vendor, err)
+	}
+	status := res.Status(time.Now())
+	var expires *string
+	if res.ExpiresOn != nil {
+		d := res.ExpiresOn.Format(time.DateOnly)
+		expires = &d
+	}
+	if _, err := w.db.Exec(ctx, `update assets set warranty_vendor = $2, warranty_status = $3, warranty_coverage = nullif($4, ''),
+        warranty_expiry = coalesce($5::date, warranty_expiry), warranty_checked_at = now(), warranty_error = null, updated_at = now()
+        where id::text = $1`, j.AssetID, vendor, status, res.Coverage, expires); err != nil {
+		return fmt.Errorf("store warranty: %w", err)
+	}
+	if err := audit.RecordDiff(ctx, w.db, warrantyActor, "asset", j.AssetID, "warranty_updated", map[string]any{
+		"vendor": vendor, "status": status, "coverage": res.Coverage, "expires_on": expires,
+	}); err != nil {
+		log.Error().Err(err).Str("asset", j.AssetID).Msg("record warranty lookup")
+	}
+	return nil
+}
+
+// fail records a lookup that will not succeed on retry. The asset keeps
+// its previous warranty details.
+func (w warrantyLookup) fail(ctx context.Context, assetID, vendor string, cause error) error {
+	if _, err := w.db.Exec(ctx, `update assets set warranty_vendor = nullif($2, ''), warranty_checked_at = now(), warranty_error = $3
+        where id::text = $1`, assetID, vendor, cause.Error()); err != nil {
+		return fmt.Errorf("store warranty error: %w", err)
+	}
+	return fmt.Errorf("warranty lookup: %w", cause)
+}
+
+func derefOr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// queueWarrantyLookups queues a lookup for assets with a serial number from
+// a vendor whose provider is configured and whose warranty was not checked
+// in the last refreshDays days.
+func queueWarrantyLookups(ctx context.Context, db app.DB, providers warranty.Providers, refreshDays int) error {
+	var prefixes []string
+	for _, v := range []string{warranty.VendorDell, warranty.VendorLenovo, warranty.VendorApple} {
+		if _, err := providers.For(v); err == nil {
+			prefixes = append(prefixes, v+"%")
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil
+	}
+	rows, err := db.Query(ctx, `select id::text from assets
+        where serial_number <> '' and lower(btrim(manufacturer)) like any($1::text[])
+          and (warranty_checked_at is null or warranty_checked_at < now() - make_interval(days => $2))
+        order by warranty_checked_at nulls first
+        limit $3`, prefixes, refreshDays, warrantyBatch)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	day := time.Now().UTC().Format(time.DateOnly)
+	for _, id := range ids {
+		if err := outbox.AddJob(ctx, db, "warranty_lookup:"+id+":"+day, "", jobs.TypeWarrantyLookup, jobs.WarrantyLookup{AssetID: id}); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Info().Int("assets", len(ids)).Msg("queued warranty lookups")
+	}
+	return nil
+}
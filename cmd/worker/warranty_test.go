@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/warranty"
+)
+
+type fakeWarrantyProvider struct {
+	res     warranty.Result
+	err     error
+	lookups int
+}
+
+func (p *fakeWarrantyProvider) PerMinute() int { return 30 }
+
+func (p *fakeWarrantyProvider) Lookup(ctx context.Context, serial string) (warranty.Result, error) {
+	p.lookups++
+	return p.res, p.err
+}
+
+type fakeWarrantyProviders struct{ p *fakeWarrantyProvider }
+
+func (f fakeWarrantyProviders) For(vendor string) (warranty.Provider, error) {
+	if vendor != warranty.VendorDell {
+		return nil, warranty.ErrNotConfigured
+	}
+	return f.p, nil
+}
+
+func TestWarrantyLookup(t *testing.T) {
+	manufacturer, serial := "Dell Inc.", "7XKQ2M3"
+	var updates [][]any
+	var audited []string
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if args[0] == "gone" {
+					return pgx.ErrNoRows
+				}
+				*dest[0].(**string) = &serial
+				*dest[1].(**string) = &manufacturer
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			switch {
+			case strings.Contains(sql, "update assets"):
+				updates = append(updates, args)
+			case strings.Contains(sql, "audit_events"):
+				audited = append(audited, args[4].(string))
+			}
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	p := &fakeWarrantyProvider{}
+	allowed := false
+	var requeued []jobs.WarrantyLookup
+	var delays []time.Duration
+	w := warrantyLookup{
+		db:        db,
+		providers: fakeWarrantyProviders{p},
+		allow:     func(ctx context.Context, vendor string, perMinute int) (bool, error) { return allowed, nil },
+		later: func(ctx context.Context, after time.Duration, j jobs.WarrantyLookup) error {
+			requeued, delays = append(requeued, j), append(delays, after)
+			return nil
+		},
+	}
+	ctx := context.Background()
+
+	if err := w.run(ctx, jobs.WarrantyLookup{AssetID: "gone"}); err != nil {
+		t.Fatalf("expected deleted assets to be skipped, got %v", err)
+	}
+	// Over the vendor's limit the lookup waits for the next token.
+	if err := w.run(ctx, jobs.WarrantyLookup{AssetID: "a1"}); err != nil || p.lookups != 0 || len(requeued) != 1 || delays[0] != 2*time.Second {
+		t.Fatalf("expected a rate limited lookup to be delayed, got %v %d %v", err, p.lookups, delays)
+	}
+	allowed = true
+	p.err = errors.New("vendor timeout")
+	if err := w.run(ctx, jobs.WarrantyLookup{AssetID: "a1"}); !jobs.IsRescheduled(err) || len(requeued) != 2 || requeued[1].Retries != 1 || len(updates) != 0 {
+		t.Fatalf("expected a transient failure to be retried, got %v %+v", err, requeued)
+	}
+	if err := w.run(ctx, jobs.WarrantyLookup{AssetID: "a1", Retries: 3}); err == nil || jobs.IsRescheduled(err) || len(updates) != 1 || updates[0][2] != "vendor timeout" {
+		t.Fatalf("expected the last failure to be stored, got %v %v", err, updates)
+	}
+	expires := time.Now().AddDate(1, 0, 0)
+	p.err, p.res = nil, warranty.Result{Coverage: "ProSupport", ExpiresOn: &expires}
+	if err := w.run(ctx, jobs.WarrantyLookup{AssetID: "a1"}); err != nil {
+		t.Fatal(err)
+	}
+	got := updates[1]
+	if got[1] != warranty.VendorDell || got[2] != warranty.StatusActive || got[3] != "ProSupport" || *got[4].(*string) != expires.Format(time.DateOnly) {
+		t.Fatalf("unexpected warranty update %v", got)
+	}
+	if strings.Join(audited, ",") != "warranty_updated" {
+		t.Fatalf("unexpected audit actions %v", audited)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	app "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+// webhookDelivery runs webhook_dispatch and webhook_deliver jobs. enqueue
+// queues a delivery and later schedules a retry after a delay; both go
+// through Redis outside tests.
+type webhookDelivery struct {
+	db      app.DB
+	client  *http.Client
+	enqueue func(ctx context.Context, j jobs.WebhookDeliver) error
+	later   func(ctx context.Context, after time.Duration, j jobs.WebhookDeliver) error
+}
+
+func newWebhookDelivery(db app.DB, rdb *redis.Client) webhookDelivery {
+	return webhookDelivery{
+		db: db,
+		enqueue: func(ctx context.Context, j jobs.WebhookDeliver) error {
+			return jobs.Enqueue(ctx, rdb, "", jobs.TypeWebhookDeliver, j)
+		},
+		later: func(ctx context.Context, after time.Duration, j jobs.WebhookDeliver) error {
+			return jobs.Schedule(ctx, rdb, time.Now().Add(after), "", jobs.TypeWebhookDeliver, j)
+		},
+	}
+}
+
+// dispatch renders an event's payload once and queues a delivery of it to
+// every active subscription selecting the event. Events about a ticket
+// deleted since are dropped.
+func (w webhookDelivery) dispatch(ctx context.Context, j jobs.WebhookDispatch) error {
+	ev, ok := webhook.Lookup(j.Event)
+	if !ok {
+		log.Warn().Str("event", j.Event).Msg("unknown webhook event; dropping")
+		return nil
+	}
+	rows, err := w.db.Query(ctx, `select id::text from webhooks where active and event_mask & $1 <> 0 order by created_at`, ev.Bit)
+	if err != nil {
+		return fmt.Errorf("load webhooks: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan webhook: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("load webhooks: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	payload, err := w.render(ctx, j)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Debug().Str("ticket", j.TicketID).Str("event", j.Event).Msg("ticket gone; dropping webhook event")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("render webhook payload: %w", err)
+	}
+	for _, id := range ids {
+		if err := w.enqueue(ctx, jobs.WebhookDeliver{WebhookID: id, Event: j.Event, Payload: payload, Attempt: 1}); err != nil {
+			log.Error().Err(err).Str("webhook_id", id).Str("event", j.Event).Msg("queue webhook delivery")
+		}
+	}
+	return nil
+}
+
+// render builds the payload of j: its id, event and time, and data holding
+// the ticket as it is now, the comment for comment.created, and whatever
+// else the producer attached.
+func (w webhookDelivery) render(ctx context.Context, j jobs.WebhookDispatch) ([]byte, error) {
+	data := map[string]any{}
+	for k, v := range j.Data {
+		data[k] = v
+	}
+	if j.TicketID != "" {
+		ticket, err := webhookTicket(ctx, w.db, j.TicketID)
+		if err != nil {
+			return nil, err
+		}
+		data["ticket"] = ticket
+	}
+	if id, _ := data["comment_id"].(string); id != "" {
+		comment, err := webhookComment(ctx, w.db, id)
+		if err != nil {
+			return nil, err
+		}
+		data["comment"] = comment
+		delete(data, "comment_id")
+	}
+	return json.Marshal(map[string]any{
+		"id":          j.ID,
+		"event":       j.Event,
+		"occurred_at": j.OccurredAt.UTC().Format(time.RFC3339),
+		"data":        data,
+	})
+}
+
+// webhookTicket loads a ticket shaped like the test console's samples.
+func webhookTicket(ctx context.Context, db app.DB, id string) (map[string]any, error) {
+	var number, title, status, reqEmail, reqName, asgEmail, asgName string
+	var ticketID string
+	var priority int16
+	var created, updated time.Time
+	err := db.QueryRow(ctx, `select t.id::text, t.number, t.title, t.status, t.priority, t.created_at, t.updated_at,
+            coalesce(r.email,''), coalesce(r.name,''), coalesce(u.email,''), coalesce(u.display_name,'')
+        from tickets t left join requesters r on r.id = t.requester_id left join users u on u.id = t.assignee_id
+        where t.id::text = $1`, id).Scan(&ticketID, &number, &title, &status, &priority, &created, &updated,
+		&reqEmail, &reqName, &asgEmail, &asgName)
+	if err != nil {
+		return nil, err
+	}
+	t := map[string]any{
+		"id":         ticketID,
+		"number":     number,
+		"title":      title,
+		"status":     status,
+		"priority":   priority,
+		"requester":  map[string]any{"email": reqEmail, "name": reqName},
+		"created_at": created.UTC().Format(time.RFC3339),
+		"updated_at": updated.UTC().Format(time.RFC3339),
+	}
+	if asgEmail != "" {
+		t["assignee"] = map[string]any{"email": asgEmail, "name": asgName}
+	}
+	return t, nil
+}
+
+// webhookComment loads a comment and its author, staff or requester.
+func webhookComment(ctx context.Context, db app.DB, id string) (map[string]any, error) {
+	var commentID, body, email, name string
+	var internal bool
+	err := db.QueryRow(ctx, `select c.id::text, c.body_md, c.is_internal,
+            coalesce(u.email, r.email, ''), coalesce(u.display_name, r.name, '')
+        from ticket_comments c left join users u on u.id = c.author_id left join requesters r on r.id = c.author_requester_id
+        where c.id::text = $1`, id).Scan(&commentID, &body, &internal, &email, &name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"id":          commentID,
+		"body":        body,
+		"is_internal": internal,
+		"author":      map[string]any{"email": email, "name": name},
+	}, nil
+}
+
+// deliver posts j's payload to its subscription and records the attempt in
+// webhook_deliveries. Transport errors, timeouts, rate limiting and server
+// errors are retried with exponential backoff up to webhook.MaxAttempts;
+// other failures are final. Deliveries to subscriptions deleted or
+// deactivated since are dropped.
+func (w webhookDelivery) deliver(ctx context.Context, j jobs.WebhookDeliver) error {
+	var targetURL, secret string
+	var active bool
+	err := w.db.QueryRow(ctx, `select target_url, coalesce(secret,''), active from webhooks where id::text = $1`, j.WebhookID).
+		Scan(&targetURL, &secret, &active)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !active) {
+		log.Debug().Str("webhook_id", j.WebhookID).Msg("webhook gone or inactive; dropping delivery")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load webhook: %w", err)
+	}
+	id := uuid.NewString()
+	now := time.Now()
+	res := webhook.Send(ctx, w.client, targetURL, secret, j.Event, id, j.Payload, now)
+	var status *int
+	var errText *string
+	if res.Error != "" {
+		errText = &res.Error
+	} else {
+		status = &res.StatusCode
+	}
+	var next *time.Time
+	if webhook.Retryable(res) && j.Attempt < webhook.MaxAttempts {
+		at := now.Add(webhook.Backoff(j.Attempt))
+		next = &at
+	}
+	if _, err := w.db.Exec(ctx, `insert into webhook_deliveries
+        (id, webhook_id, event, payload, attempt, retry_of, status_code, latency_ms, response_excerpt, error, next_retry_at, created_at)
+        values ($1,$2,$3,$4::jsonb,$5,nullif($6,'')::uuid,$7,$8,$9,$10,$11,$12)`,
+		id, j.WebhookID, j.Event, string(j.Payload), j.Attempt, j.RetryOf, status, res.LatencyMS, res.Excerpt, errText, next, now); err != nil {
+		log.Error().Err(err).Str("webhook_id", j.WebhookID).Str("delivery_id", id).Msg("record webhook delivery")
+	}
+	if res.OK() {
+		return nil
+	}
+	if next != nil {
+		retry := j
+		retry.Attempt++
+		retry.RetryOf = id
+		if err := w.later(ctx, next.Sub(now), retry); err != nil {
+			log.Error().Err(err).Str("webhook_id", j.WebhookID).Msg("schedule webhook retry")
+		}
+	}
+	failed := fmt.Errorf("deliver webhook %s attempt %d: status %d", j.WebhookID, j.Attempt, res.StatusCode)
+	if res.Error != "" {
+		failed = fmt.Errorf("deliver webhook %s attempt %d: %s", j.WebhookID, j.Attempt, res.Error)
+	}
+	if next != nil {
+		// The next attempt is its own job; this one is done.
+		return jobs.Rescheduled(failed)
+	}
+	return failed
+}
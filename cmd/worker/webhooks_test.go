@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+func TestWebhookDispatch(t *testing.T) {
+	hooks := []string{"w1", "w2"}
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			if args[0] != 16 {
+				t.Errorf("expected the comment.created bit, got %v", args[0])
+			}
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(hooks) },
+				ScanFunc: func(dest ...any) error { *dest[0].(*string) = hooks[i-1]; return nil },
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				if strings.Contains(sql, "from ticket_comments") {
+					*dest[0].(*string), *dest[1].(*string), *dest[3].(*string) = "c1", "Thanks", "req@example.com"
+					return nil
+				}
+				*dest[0].(*string), *dest[1].(*string), *dest[3].(*string) = "t1", "HD-1", "Open"
+				*dest[4].(*int16) = 3
+				return nil
+			}}
+		},
+	}
+	var queued []jobs.WebhookDeliver
+	w := webhookDelivery{db: db, enqueue: func(ctx context.Context, j jobs.WebhookDeliver) error {
+		queued = append(queued, j)
+		return nil
+	}}
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	err := w.dispatch(context.Background(), jobs.WebhookDispatch{ID: "e1", Event: "comment.created", TicketID: "t1", Data: map[string]any{"comment_id": "c1"}, OccurredAt: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queued) != 2 || queued[1].WebhookID != "w2" || queued[0].Attempt != 1 {
+		t.Fatalf("unexpected deliveries %+v", queued)
+	}
+	var payload struct {
+		ID         string `json:"id"`
+		Event      string `json:"event"`
+		OccurredAt string `json:"occurred_at"`
+		Data       struct {
+			Ticket    map[string]any `json:"ticket"`
+			Comment   map[string]any `json:"comment"`
+			CommentID string         `json:"comment_id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(queued[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != "e1" || payload.Event != "comment.created" || payload.OccurredAt != "2026-10-01T12:00:00Z" {
+		t.Fatalf("unexpected payload %s", queued[0].Payload)
+	}
+	if payload.Data.Ticket["number"] != "HD-1" || payload.Data.Comment["body"] != "Thanks" || payload.Data.CommentID != "" {
+		t.Fatalf("unexpected payload data %s", queued[0].Payload)
+	}
+}
+
+func TestWebhookDeliverRetries(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var sig, ts string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		sig, ts = r.Header.Get(webhook.HeaderSignature), r.Header.Get(webhook.HeaderTimestamp)
+		body, _ = io.ReadAll(r.Body)
+		rw.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	var recorded [][]any
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*bool) = srv.URL, "s3cret", true
+				return nil
+			}}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			recorded = append(recorded, args)
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	var after time.Duration
+	var retried []jobs.WebhookDeliver
+	w := webhookDelivery{db: db, later: func(ctx context.Context, d time.Duration, j jobs.WebhookDeliver) error {
+		after = d
+		retried = append(retried, j)
+		return nil
+	}}
+	j := jobs.WebhookDeliver{WebhookID: "w1", Event: "ticket.created", Payload: []byte(`{"id":"e1"}`), Attempt: 2}
+	if err := w.deliver(context.Background(), j); err == nil || !jobs.IsRescheduled(err) {
+		t.Fatalf("expected the failed attempt reported as rescheduled, got %v", err)
+	}
+	sec, _ := strconv.ParseInt(ts, 10, 64)
+	if !webhook.Verify("s3cret", sec, body, sig) {
+		t.Fatalf("bad signature %q", sig)
+	}
+	if len(retried) != 1 || retried[0].Attempt != 3 || retried[0].RetryOf != recorded[0][0] || after != 2*time.Minute {
+		t.Fatalf("expected attempt 3 in 2m, got %+v after %v", retried, after)
+	}
+	if recorded[0][4] != 2 || *recorded[0][6].(*int) != status || recorded[0][10] == (*time.Time)(nil) {
+		t.Fatalf("unexpected delivery row %v", recorded[0])
+	}
+
+	// The last attempt and rejected payloads are not retried.
+	j.Attempt = webhook.MaxAttempts
+	_ = w.deliver(context.Background(), j)
+	status = http.StatusBadRequest
+	j.Attempt = 1
+	_ = w.deliver(context.Background(), j)
+	if len(retried) != 1 || recorded[2][10] != (*time.Time)(nil) {
+		t.Fatalf("expected no more retries, got %+v", retried)
+	}
+
+	status = http.StatusNoContent
+	if err := w.deliver(context.Background(), j); err != nil || len(retried) != 1 {
+		t.Fatalf("expected a clean delivery, got %v", err)
+	}
+}
+
+func TestWebhookDeliverDropsInactive(t *testing.T) {
+	db := &testutil.MockDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+			t.Fatalf("unexpected exec %s", sql)
+			return pgconn.CommandTag{}, nil
+		},
+	}
+	if err := (webhookDelivery{db: db}).deliver(context.Background(), jobs.WebhookDeliver{WebhookID: "gone", Attempt: 1}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/smtp"
 	"strings"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	apppkg "github.com/mark3748/helpdesk-go/cmd/api/app"
+	"github.com/mark3748/helpdesk-go/cmd/api/testutil"
 )
 
 func TestSendEmail(t *testing.T) {
@@ -49,11 +51,33 @@ func TestSendEmail(t *testing.T) {
 	if db.lastSQL == "" || !strings.Contains(strings.ToLower(db.lastSQL), "email_outbound") {
 		t.Fatalf("expected insert into email_outbound, got %q", db.lastSQL)
 	}
-	if len(db.lastArgs) != 6 || db.lastArgs[4].(int) != 0 {
+	if len(db.lastArgs) != 8 || db.lastArgs[4].(int) != 0 || db.lastArgs[6].(*string) != nil {
 		t.Fatalf("expected retries recorded, got %v", db.lastArgs)
 	}
 }
 
+func TestSendEmailResend(t *testing.T) {
+	var msg string
+	smtpSendMail = func(addr string, _ smtp.Auth, from string, to []string, m []byte) error {
+		msg = string(m)
+		return errors.New("550 mailbox unavailable")
+	}
+	defer func() { smtpSendMail = smtp.SendMail }()
+
+	prev, tid := "e1", "t1"
+	j := EmailJob{To: "to@example.com", Subject: "[HD-1] Ticket resolved", Body: "Resolved.\n\n-- \nAcme", TicketID: &tid, ResentFrom: &prev}
+	db := &execDB{}
+	if err := sendEmail(context.Background(), db, Config{SMTPHost: "smtp", SMTPPort: "25", SMTPFrom: "from@example.com"}, j); err == nil {
+		t.Fatal("expected the SMTP error")
+	}
+	if !strings.Contains(msg, "Subject: [HD-1] Ticket resolved\r\n") || !strings.HasSuffix(msg, "Resolved.\n\n-- \nAcme") {
+		t.Fatalf("expected the stored message unchanged: %q", msg)
+	}
+	if db.lastArgs[3] != "failed" || *db.lastArgs[6].(*string) != "550 mailbox unavailable" || db.lastArgs[7].(*string) != &prev {
+		t.Fatalf("expected the failure and original row logged, got %v", db.lastArgs)
+	}
+}
+
 func TestProcessQueueJob(t *testing.T) {
 	mr, err := miniredis.Run()
 	if err != nil {
@@ -111,6 +135,8 @@ type slaRow struct {
 	paused     bool
 	respTarget int
 	resTarget  int
+	responded  *time.Time
+	status     string
 }
 
 type slaRows struct {
@@ -137,6 +163,12 @@ func (r *slaRows) Scan(dest ...any) error {
 	*(dest[5].(*bool)) = row.paused
 	*(dest[6].(*int)) = row.respTarget
 	*(dest[7].(*int)) = row.resTarget
+	if len(dest) > 9 {
+		*(dest[9].(**time.Time)) = row.responded
+	}
+	if len(dest) > 10 {
+		*(dest[10].(*string)) = row.status
+	}
 	return nil
 }
 
@@ -175,11 +207,25 @@ func (r *slaFakeRows) Scan(dest ...any) error {
 
 type slaDB struct {
 	rows      []slaRow
+	rules     []slaEscalationRule
 	execCount int
+	execSQL   []string
+	execArgs  [][]any
 }
 
 func (db *slaDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
 	switch {
+	case strings.Contains(sql, "from sla_escalation_rules"):
+		i := 0
+		return &testutil.MockRows{
+			NextFunc: func() bool { i++; return i <= len(db.rules) },
+			ScanFunc: func(dest ...any) error {
+				r := db.rules[i-1]
+				*dest[0].(*string), *dest[1].(*string), *dest[2].(*string), *dest[4].(*int) = r.id, r.name, r.target, r.pct
+				*dest[5].(*bool), *dest[6].(*bool), *dest[7].(*bool) = r.notifyAssignee, r.notifyManager, r.bumpPriority
+				return nil
+			},
+		}, nil
 	case strings.Contains(sql, "ticket_sla_clocks"):
 		return &slaRows{data: db.rows}, nil
 	case strings.Contains(sql, "business_hours"):
@@ -207,6 +253,8 @@ func (db *slaDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 
 func (db *slaDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
 	db.execCount++
+	db.execSQL = append(db.execSQL, sql)
+	db.execArgs = append(db.execArgs, args)
 	return pgconn.CommandTag{}, nil
 }
 
@@ -225,3 +273,89 @@ func TestUpdateSLAClocksPaused(t *testing.T) {
 		t.Fatalf("expected 1 exec, got %d", db.execCount)
 	}
 }
+
+func TestUpdateSLAClocksRecordsBreachOnce(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	db := &slaDB{rows: []slaRow{
+		// Crosses the 60 minute response target on this tick.
+		{ticketID: "t1", calID: "cal1", respMS: 59*60*1000 + 30*1000, lastStart: start, respTarget: 60},
+		// Already past its resolution target before this tick.
+		{ticketID: "t2", calID: "cal1", resMS: 2 * 60 * 60 * 1000, lastStart: start, resTarget: 60},
+	}}
+	if err := updateSLAClocks(context.Background(), db); err != nil {
+		t.Fatalf("updateSLAClocks: %v", err)
+	}
+	var audits [][]any
+	for i, sql := range db.execSQL {
+		if strings.Contains(sql, "insert into audit_events") {
+			audits = append(audits, db.execArgs[i])
+		}
+	}
+	if len(audits) != 1 {
+		t.Fatalf("expected 1 breach audit, got %d", len(audits))
+	}
+	if audits[0][0] != "system:sla_clock" || audits[0][1] != nil || audits[0][3] != "t1" || audits[0][4] != "sla_breached" {
+		t.Fatalf("unexpected audit args: %v", audits[0])
+	}
+}
+
+func TestUpdateSLAClocksStopsResponseAtFirstResponse(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	answered := start.Add(-time.Hour)
+	db := &slaDB{rows: []slaRow{
+		// Answered before this tick: the response clock would otherwise cross its target.
+		{ticketID: "t1", calID: "cal1", respMS: 59*60*1000 + 30*1000, resMS: 1000, lastStart: start, respTarget: 60, responded: &answered},
+	}}
+	if err := updateSLAClocks(context.Background(), db); err != nil {
+		t.Fatalf("updateSLAClocks: %v", err)
+	}
+	if len(db.execArgs) != 1 {
+		t.Fatalf("expected only the clock update, got %v", db.execSQL)
+	}
+	if got := db.execArgs[0][0].(int64); got != 59*60*1000+30*1000 {
+		t.Fatalf("response clock moved to %d after the first response", got)
+	}
+	if got := db.execArgs[0][1].(int64); got < 60*1000 {
+		t.Fatalf("resolution clock stopped too: %d", got)
+	}
+}
+
+func TestMaintainPartitions(t *testing.T) {
+	tables := []string{"audit_events", "tickets"}
+	var calls []string
+	db := &testutil.MockDB{
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+			i := 0
+			return &testutil.MockRows{
+				NextFunc: func() bool { i++; return i <= len(tables) },
+				ScanFunc: func(dest ...any) error {
+					*dest[0].(*string) = tables[i-1]
+					return nil
+				},
+			}, nil
+		},
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) pgx.Row {
+			fn := sql[len("select "):strings.Index(sql, "(")]
+			calls = append(calls, fn+" "+args[0].(string))
+			return &testutil.MockRow{ScanFunc: func(dest ...any) error {
+				*dest[0].(*int) = 1
+				return nil
+			}}
+		},
+	}
+
+	if err := maintainPartitions(context.Background(), db, 0); err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	if got := strings.Join(calls, "|"); got != "hd_ensure_partitions audit_events|hd_ensure_partitions tickets" {
+		t.Fatalf("unexpected calls without detach: %s", got)
+	}
+
+	calls = nil
+	if err := maintainPartitions(context.Background(), db, 12); err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	if len(calls) != 4 || calls[1] != "hd_detach_partitions audit_events" {
+		t.Fatalf("expected ensure and detach per table, got %v", calls)
+	}
+}
@@ -0,0 +1,54 @@
+// Package actor identifies who performed an action for audit and event
+// attribution.
+package actor
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Actor types. System actors carry the job name after the prefix, e.g.
+// "system:sla_clock".
+const (
+	TypeUser     = "user"
+	TypeAPIKey   = "api_key"
+	TypeGuest    = "guest"
+	TypeChat     = "chat"
+	SystemPrefix = "system:"
+)
+
+// Actor is the principal behind an audited change.
+type Actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+}
+
+// User returns an actor for an authenticated user.
+func User(id string) Actor { return Actor{Type: TypeUser, ID: id} }
+
+// APIKey returns an actor for a request authenticated with an API key.
+func APIKey(id string) Actor { return Actor{Type: TypeAPIKey, ID: id} }
+
+// Guest returns an actor for a request authenticated with a guest share
+// link; id is the link's ID.
+func Guest(id string) Actor { return Actor{Type: TypeGuest, ID: id} }
+
+// Chat returns an actor for a website chat visitor; id is the
+// conversation's ID.
+func Chat(id string) Actor { return Actor{Type: TypeChat, ID: id} }
+
+// System returns an actor for a background job.
+func System(job string) Actor { return Actor{Type: SystemPrefix + job} }
+
+// IsSystem reports whether the actor is a background job.
+func (a Actor) IsSystem() bool { return strings.HasPrefix(a.Type, SystemPrefix) }
+
+// DBID returns the ID for uuid-typed actor_id columns, or nil when the actor
+// has no UUID (system actors, test users).
+func (a Actor) DBID() any {
+	if _, err := uuid.Parse(a.ID); err != nil {
+		return nil
+	}
+	return a.ID
+}
@@ -0,0 +1,71 @@
+package avatars
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestResizeCropsAndScales(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 600, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 600; x++ {
+			src.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var in bytes.Buffer
+	if err := jpeg.Encode(&in, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Resize(in.Bytes(), Size)
+	if err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != Size || b.Dy() != Size {
+		t.Fatalf("expected %dx%d, got %v", Size, Size, b)
+	}
+	if r, _, _, _ := img.At(Size/2, Size/2).RGBA(); r>>8 < 190 {
+		t.Fatalf("expected red preserved, got r=%d", r>>8)
+	}
+
+	small := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	in.Reset()
+	_ = png.Encode(&in, small)
+	out, err = Resize(in.Bytes(), Size)
+	if err != nil {
+		t.Fatalf("resize small: %v", err)
+	}
+	cfg, _ := png.DecodeConfig(bytes.NewReader(out))
+	if cfg.Width != 40 || cfg.Height != 40 {
+		t.Fatalf("expected small image cropped to 40x40, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	if _, err := Resize([]byte("not an image"), Size); err != ErrNotImage {
+		t.Fatalf("expected ErrNotImage, got %v", err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	if u := URL("u1", "avatar-u1-a.png", "x@example.com"); !strings.HasPrefix(u, "/api/users/u1/avatar?v=") {
+		t.Fatalf("expected uploaded avatar URL, got %q", u)
+	}
+	if URL("u1", "avatar-u1-a.png", "") == URL("u1", "avatar-u1-b.png", "") {
+		t.Fatalf("expected version to change with key")
+	}
+	// sha256("test@example.com")
+	want := "https://www.gravatar.com/avatar/973dfe463ec85785f5f95af5ba3906eedb2d931c24e69824a89ea65dba4e813b?d=identicon&s=256"
+	if u := URL("u1", "", "  Test@Example.com "); u != want {
+		t.Fatalf("expected gravatar %q, got %q", want, u)
+	}
+	if u := URL("", "", ""); u != "" {
+		t.Fatalf("expected empty URL, got %q", u)
+	}
+}
@@ -0,0 +1,84 @@
+// Package blocklist keeps abusive requesters out. Admins block an address or
+// a whole domain; inbound email from a blocked sender is dropped and portal
+// submissions are turned away.
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Block is a blocked address or domain.
+type Block struct {
+	ID      string
+	Pattern string
+}
+
+// Normalize returns pattern in the form it is stored and matched in: a
+// lowercase address, or a lowercase domain for "example.com" and
+// "@example.com". ok is false for anything else.
+func Normalize(pattern string) (string, bool) {
+	p := strings.ToLower(strings.TrimSpace(pattern))
+	p = strings.TrimPrefix(p, "@")
+	local, domain, isAddr := strings.Cut(p, "@")
+	if isAddr && local == "" {
+		return "", false
+	}
+	if !isAddr {
+		domain = p
+	}
+	if strings.ContainsAny(p, " \t<>,;\"") || strings.Count(p, "@") > 1 || !validDomain(domain) {
+		return "", false
+	}
+	return p, true
+}
+
+func validDomain(d string) bool {
+	if len(d) > 253 || !strings.Contains(d, ".") {
+		return false
+	}
+	for _, label := range strings.Split(d, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Cond is a SQL condition true when the address in email is blocked by the
+// pattern in pattern: the same address, or an address at the domain or one
+// of its subdomains. email must already be lowercase.
+func Cond(email, pattern string) string {
+	return `(` + pattern + ` = ` + email + ` or (position('@' in ` + pattern + `) = 0 and (
+            split_part(` + email + `, '@', 2) = ` + pattern + `
+            or right(split_part(` + email + `, '@', 2), length(` + pattern + `) + 1) = '.' || ` + pattern + `)))`
+}
+
+// Match returns the block covering email, preferring an address block to a
+// domain block, and counts the hit against it. ok is false when email is
+// not blocked.
+func Match(ctx context.Context, db DB, email string) (b Block, ok bool, err error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return b, false, nil
+	}
+	err = db.QueryRow(ctx, `update requester_blocks set hits = hits + 1, last_hit_at = now()
+        where id = (select id from requester_blocks where `+Cond("$1", "pattern")+`
+                    order by position('@' in pattern) = 0, length(pattern) desc limit 1)
+        returning id::text, pattern`, email).Scan(&b.ID, &b.Pattern)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return b, false, nil
+	}
+	if err != nil {
+		return b, false, err
+	}
+	return b, true, nil
+}
@@ -0,0 +1,68 @@
+package blocklist
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"Spam@Example.COM ": "spam@example.com",
+		"example.com":       "example.com",
+		"@Mail.Example.com": "mail.example.com",
+	}
+	for in, want := range cases {
+		if got, ok := Normalize(in); !ok || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "localhost", "@", "a@b@example.com", "x@", "bad domain.com", "-bad.com", "a@example..com"} {
+		if got, ok := Normalize(in); ok {
+			t.Errorf("Normalize(%q) = %q, want rejected", in, got)
+		}
+	}
+}
+
+type row struct{ scan func(dest ...any) error }
+
+func (r row) Scan(dest ...any) error { return r.scan(dest...) }
+
+type fakeDB struct {
+	sql  string
+	args []any
+	hit  bool
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	db.sql, db.args = sql, args
+	return row{func(dest ...any) error {
+		if !db.hit {
+			return pgx.ErrNoRows
+		}
+		*dest[0].(*string) = "b1"
+		*dest[1].(*string) = "example.com"
+		return nil
+	}}
+}
+
+func TestMatch(t *testing.T) {
+	db := &fakeDB{}
+	if _, ok, err := Match(context.Background(), db, "ann@acme.io"); ok || err != nil {
+		t.Fatalf("expected no block, got %v %v", ok, err)
+	}
+	db.hit = true
+	b, ok, err := Match(context.Background(), db, " Spam@Mail.Example.com")
+	if !ok || err != nil || b.Pattern != "example.com" {
+		t.Fatalf("expected the domain block, got %+v %v %v", b, ok, err)
+	}
+	if db.args[0] != "spam@mail.example.com" || !strings.Contains(db.sql, "hits = hits + 1") {
+		t.Fatalf("unexpected lookup %s %v", db.sql, db.args)
+	}
+	db.sql = ""
+	if _, ok, _ := Match(context.Background(), db, ""); ok || db.sql != "" {
+		t.Fatal("an empty address must not be looked up")
+	}
+}
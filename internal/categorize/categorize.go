@@ -0,0 +1,249 @@
+// Package categorize assigns a category, subcategory and queue to inbound
+// email tickets. Classifiers are tried in order: admin-defined rules first,
+// then a vote among similar categorized tickets. A result below the
+// configured confidence leaves the ticket uncategorized for an agent.
+package categorize
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DefaultMinConfidence is the confidence a result needs to be applied.
+const DefaultMinConfidence = 0.7
+
+// Methods that produce a Result.
+const (
+	MethodRule    = "rule"
+	MethodHistory = "history"
+)
+
+// Input is what is known about an inbound ticket. From may be a bare
+// address or a full header value such as "Ann <ann@example.com>".
+type Input struct {
+	TicketID string `json:"-"`
+	From     string `json:"from"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+}
+
+// Result is a proposed categorization. Confidence ranges from 0 to 1; a zero
+// Result means no classifier had an opinion.
+type Result struct {
+	Category    string  `json:"category"`
+	Subcategory string  `json:"subcategory,omitempty"`
+	QueueID     string  `json:"queue_id,omitempty"`
+	Confidence  float64 `json:"confidence"`
+	Method      string  `json:"method,omitempty"`
+	RuleID      string  `json:"rule_id,omitempty"`
+}
+
+// Classifier proposes a categorization. Further providers, such as a trained
+// model behind an HTTP endpoint, plug in here.
+type Classifier interface {
+	Classify(ctx context.Context, in Input) (Result, error)
+}
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Run tries each classifier in turn and returns the first result reaching
+// minConfidence, or else the most confident one.
+func Run(ctx context.Context, cs []Classifier, in Input, minConfidence float64) (Result, error) {
+	var best Result
+	for _, c := range cs {
+		r, err := c.Classify(ctx, in)
+		if err != nil {
+			return best, err
+		}
+		if r.Category != "" && r.Confidence >= minConfidence {
+			return r, nil
+		}
+		if r.Confidence > best.Confidence {
+			best = r
+		}
+	}
+	return best, nil
+}
+
+// Classifiers returns the standard pipeline: the active rules, then history.
+func Classifiers(ctx context.Context, db DB) ([]Classifier, error) {
+	rules, err := LoadRules(ctx, db, true)
+	if err != nil {
+		return nil, err
+	}
+	return []Classifier{Rules(rules), History{DB: db}}, nil
+}
+
+// Rule maps inbound email to a category. Sender is matched against the
+// sender's address: a full address, a domain ("example.com", matching
+// subdomains too) or a glob such as "*@billing.example.com". Subject is a
+// case-insensitive regular expression. At least one of them is required and
+// all given must match.
+type Rule struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Position    int    `json:"position"`
+	Sender      string `json:"sender,omitempty"`
+	Subject     string `json:"subject,omitempty"`
+	Category    string `json:"category"`
+	Subcategory string `json:"subcategory,omitempty"`
+	QueueID     string `json:"queue_id,omitempty"`
+	Active      bool   `json:"active"`
+
+	subject *regexp.Regexp
+}
+
+// Validate checks r and compiles its subject pattern.
+func (r *Rule) Validate() error {
+	r.Sender = strings.ToLower(strings.TrimSpace(r.Sender))
+	r.Subject = strings.TrimSpace(r.Subject)
+	r.Category = strings.TrimSpace(r.Category)
+	r.Subcategory = strings.TrimSpace(r.Subcategory)
+	if r.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if r.Sender == "" && r.Subject == "" {
+		return fmt.Errorf("sender or subject is required")
+	}
+	if r.Sender != "" {
+		if _, err := globMatch(r.Sender, "x@example.com"); err != nil {
+			return fmt.Errorf("invalid sender pattern: %w", err)
+		}
+	}
+	if r.Subject != "" {
+		re, err := regexp.Compile("(?i)" + r.Subject)
+		if err != nil {
+			return fmt.Errorf("invalid subject pattern: %w", err)
+		}
+		r.subject = re
+	}
+	return nil
+}
+
+// Matches reports whether r applies to in. r must have been validated.
+func (r *Rule) Matches(in Input) bool {
+	if r.Sender != "" && !senderMatches(r.Sender, Address(in.From)) {
+		return false
+	}
+	if r.subject != nil && !r.subject.MatchString(in.Subject) {
+		return false
+	}
+	return true
+}
+
+func senderMatches(pattern, addr string) bool {
+	if addr == "" {
+		return false
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		ok, _ := globMatch(pattern, addr)
+		return ok
+	}
+	if strings.Contains(pattern, "@") {
+		return pattern == addr
+	}
+	domain := strings.TrimPrefix(pattern, "@")
+	d := Domain(addr)
+	return d == domain || strings.HasSuffix(d, "."+domain)
+}
+
+// globMatch matches a shell-style pattern where * and ? may also match dots.
+func globMatch(pattern, s string) (bool, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+// Rules classifies with the first matching rule, with full confidence.
+type Rules []Rule
+
+// Classify implements Classifier.
+func (rs Rules) Classify(_ context.Context, in Input) (Result, error) {
+	for i := range rs {
+		r := &rs[i]
+		if r.Active && r.Matches(in) {
+			return Result{Category: r.Category, Subcategory: r.Subcategory, QueueID: r.QueueID,
+				Confidence: 1, Method: MethodRule, RuleID: r.ID}, nil
+		}
+	}
+	return Result{}, nil
+}
+
+// RuleColumns are the category_rules columns read by ScanRule.
+const RuleColumns = `id::text, name, position, coalesce(sender,''), coalesce(subject,''), category,
+    coalesce(subcategory,''), coalesce(queue_id::text,''), active`
+
+// ScanRule reads a row of RuleColumns and validates it.
+func ScanRule(scan func(...any) error) (Rule, error) {
+	var r Rule
+	if err := scan(&r.ID, &r.Name, &r.Position, &r.Sender, &r.Subject, &r.Category, &r.Subcategory, &r.QueueID, &r.Active); err != nil {
+		return r, err
+	}
+	return r, r.Validate()
+}
+
+// LoadRules returns the rules in evaluation order. Stored rules were
+// validated on save; one that no longer compiles is an error.
+func LoadRules(ctx context.Context, db DB, activeOnly bool) ([]Rule, error) {
+	q := `select ` + RuleColumns + ` from category_rules`
+	if activeOnly {
+		q += ` where active`
+	}
+	rows, err := db.Query(ctx, q+` order by position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Rule{}
+	for rows.Next() {
+		r, err := ScanRule(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("category rule %s: %w", r.ID, err)
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// Address returns the lowercase address from a From header value.
+func Address(from string) string {
+	from = strings.TrimSpace(from)
+	if a, err := mail.ParseAddress(from); err == nil {
+		return strings.ToLower(a.Address)
+	}
+	return strings.ToLower(strings.Trim(from, "<>"))
+}
+
+// Domain returns the part of addr after the last "@".
+func Domain(addr string) string {
+	if i := strings.LastIndex(addr, "@"); i >= 0 {
+		return addr[i+1:]
+	}
+	return ""
+}
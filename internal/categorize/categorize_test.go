@@ -0,0 +1,163 @@
+package categorize
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestRules(t *testing.T) {
+	rules := Rules{
+		{ID: "off", Sender: "example.com", Category: "Ignored", Active: false},
+		{ID: "invoice", Sender: "*@billing.*", Subject: `invoice|receipt`, Category: "Billing", Subcategory: "Invoices", QueueID: "q-fin", Active: true},
+		{ID: "vendor", Sender: "vendor.com", Category: "Vendors", Active: true},
+		{ID: "exact", Sender: "ceo@example.com", Category: "VIP", Active: true},
+		{ID: "vpn", Subject: `\bvpn\b`, Category: "Network", Subcategory: "VPN", Active: true},
+	}
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		from, subject, rule string
+	}{
+		{"Billing Team <ap@billing.acme.io>", "Your invoice #42", "invoice"},
+		{"ap@billing.acme.io", "Hello", ""},
+		{"support@eu.vendor.com", "Outage", "vendor"},
+		{"someone@notvendor.com", "Outage", ""},
+		{"CEO <CEO@Example.com>", "Quick one", "exact"},
+		{"ann@example.com", "VPN keeps dropping", "vpn"},
+		{"ann@example.com", "VPNs for everyone", ""},
+	}
+	for _, tc := range cases {
+		r, err := rules.Classify(context.Background(), Input{From: tc.from, Subject: tc.subject})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.RuleID != tc.rule || (tc.rule != "" && (r.Confidence != 1 || r.Method != MethodRule)) {
+			t.Errorf("%s / %q: expected rule %q, got %+v", tc.from, tc.subject, tc.rule, r)
+		}
+	}
+
+	for _, bad := range []Rule{
+		{Sender: "a.com"},
+		{Category: "X"},
+		{Category: "X", Subject: "("},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", bad)
+		}
+	}
+}
+
+type historyRows struct {
+	list [][]string
+	i    int
+}
+
+func (r *historyRows) Close()                                       {}
+func (r *historyRows) Err() error                                   { return nil }
+func (r *historyRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *historyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *historyRows) RawValues() [][]byte                          { return nil }
+func (r *historyRows) Values() ([]any, error)                       { return nil, nil }
+func (r *historyRows) Conn() *pgx.Conn                              { return nil }
+func (r *historyRows) Next() bool                                   { r.i++; return r.i <= len(r.list) }
+func (r *historyRows) Scan(dest ...any) error {
+	for i, v := range r.list[r.i-1] {
+		switch d := dest[i].(type) {
+		case *string:
+			*d = v
+		case *int:
+			*d = len(v)
+		case *bool:
+			*d = v == "true"
+		}
+	}
+	return nil
+}
+
+type historyDB struct {
+	rules, history, pending [][]string
+	applied                 map[string][]any
+	args                    []any
+}
+
+func (db *historyDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	switch {
+	case strings.Contains(sql, "from category_rules"):
+		return &historyRows{list: db.rules}, nil
+	case strings.Contains(sql, "categorized_at is null"):
+		return &historyRows{list: db.pending}, nil
+	}
+	db.args = args
+	return &historyRows{list: db.history}, nil
+}
+
+func (db *historyDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row { return nil }
+
+func (db *historyDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.applied[args[0].(string)] = args[1:]
+	return pgconn.CommandTag{}, nil
+}
+
+func TestHistory(t *testing.T) {
+	db := &historyDB{history: [][]string{
+		{"Printer jammed on floor 2", "Ann <ann@acme.io>", "Hardware", "Printers", "q-hw"},
+		{"RE: printer jammed again", "bob@acme.io", "Hardware", "Printers", "q-hw"},
+		{"Printer toner empty", "carl@acme.io", "Hardware", "Printers", "q-hw"},
+		{"Printer driver install", "dee@other.org", "Software", "", ""},
+		{"Completely unrelated", "zed@else.org", "Access", "", ""},
+	}}
+	h := History{DB: db}
+	r, err := h.Classify(context.Background(), Input{TicketID: "t9", From: "ann@acme.io", Subject: "Printer jammed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Category != "Hardware" || r.Subcategory != "Printers" || r.QueueID != "q-hw" || r.Method != MethodHistory {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r.Confidence < DefaultMinConfidence {
+		t.Fatalf("expected consistent neighbours to be conclusive, got %.2f", r.Confidence)
+	}
+	if db.args[0] != "t9" || db.args[2] != "acme.io" {
+		t.Fatalf("unexpected query args %v", db.args)
+	}
+
+	// Free-mail senders get no domain boost and a single weak match is not
+	// enough to categorize.
+	db.history = db.history[3:4]
+	r, _ = h.Classify(context.Background(), Input{From: "x@gmail.com", Subject: "Printer driver for new laptop"})
+	if db.args[2] != "" || r.Confidence >= DefaultMinConfidence {
+		t.Fatalf("expected an inconclusive result, got %+v (args %v)", r, db.args)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	db := &historyDB{
+		rules: [][]string{{"r1", "Invoices", "1", "", "invoice", "Billing", "", "", "true"}},
+		pending: [][]string{
+			{"t1", "Invoice 1234 overdue", "", "ap@vendor.com"},
+			{"t2", "Something odd", "", "x@gmail.com"},
+		},
+		history: [][]string{{"Something odd happened", "y@gmail.com", "Misc", "", ""}},
+		applied: map[string][]any{},
+	}
+	out, err := Sweep(context.Background(), db, DefaultMinConfidence, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || !out[0].Applied || out[0].Result.RuleID != "r1" || out[1].Applied {
+		t.Fatalf("unexpected outcomes %+v", out)
+	}
+	if db.applied["t1"][0] != "Billing" || db.applied["t1"][4] != true {
+		t.Fatalf("expected t1 to be categorized, got %v", db.applied["t1"])
+	}
+	if db.applied["t2"][4] != false || db.applied["t2"][5] != MethodHistory {
+		t.Fatalf("expected t2 to be marked but left uncategorized, got %v", db.applied["t2"])
+	}
+}
@@ -0,0 +1,201 @@
+package categorize
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// History classifies a ticket by the categories of similar tickets from the
+// last six months. Similarity is the overlap of subject words, raised when
+// the tickets share a sender or the sender's organisation.
+type History struct {
+	DB DB
+}
+
+// Tuning for History. A neighbour needs minSimilarity to vote; full
+// confidence needs fullEvidence worth of agreeing votes.
+const (
+	minSimilarity  = 0.25
+	fullEvidence   = 2.0
+	sameSender     = 0.4
+	sameDomain     = 0.2
+	historyKeyword = 8
+)
+
+// freeMail domains say nothing about what a sender's tickets are about.
+var freeMail = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true, "live.com": true,
+	"yahoo.com": true, "icloud.com": true, "me.com": true, "aol.com": true, "proton.me": true,
+	"protonmail.com": true, "gmx.com": true, "gmx.de": true, "mail.com": true, "yandex.ru": true,
+}
+
+// historyQuery returns recent categorized tickets whose title shares a
+// keyword with the subject or whose sender is from the same domain. The
+// sender is the From of the first inbound email, or the requester's email.
+const historyQuery = `
+select t.title, lower(coalesce(e.addr, r.email, '')), t.category, coalesce(t.subcategory,''), coalesce(t.queue_id::text,'')
+from tickets t
+left join requesters r on r.id = t.requester_id
+left join lateral (
+    select ei.parsed_json->>'from' as addr from email_inbound ei where ei.ticket_id = t.id order by ei.created_at limit 1
+) e on true
+where t.category is not null and t.id::text <> $1
+  and t.created_at > now() - interval '180 days'
+  and (t.title ilike any($2) or ($3 <> '' and lower(coalesce(e.addr, r.email, '')) like '%@' || $3 || '%'))
+order by t.created_at desc
+limit 200`
+
+// Classify implements Classifier.
+func (h History) Classify(ctx context.Context, in Input) (Result, error) {
+	words := keywords(in.Subject)
+	addr := Address(in.From)
+	domain := Domain(addr)
+	if freeMail[domain] {
+		domain = ""
+	}
+	if len(words) == 0 && domain == "" {
+		return Result{}, nil
+	}
+	if len(words) > historyKeyword {
+		words = words[:historyKeyword]
+	}
+	patterns := make([]string, len(words))
+	for i, w := range words {
+		patterns[i] = "%" + w + "%"
+	}
+	rows, err := h.DB.Query(ctx, historyQuery, in.TicketID, patterns, domain)
+	if err != nil {
+		return Result{}, err
+	}
+	defer rows.Close()
+	var neighbours []neighbour
+	for rows.Next() {
+		var n neighbour
+		var title, from string
+		if err := rows.Scan(&title, &from, &n.label.Category, &n.label.Subcategory, &n.label.QueueID); err != nil {
+			return Result{}, err
+		}
+		n.similarity = similarity(words, addr, domain, keywords(title), Address(from))
+		neighbours = append(neighbours, n)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, err
+	}
+	return vote(neighbours), nil
+}
+
+type label struct{ Category, Subcategory, QueueID string }
+
+type neighbour struct {
+	label      label
+	similarity float64
+}
+
+// similarity scores a past ticket against the new one, from 0 to 1.
+func similarity(words []string, addr, domain string, otherWords []string, otherAddr string) float64 {
+	s := jaccard(words, otherWords)
+	switch {
+	case addr != "" && addr == otherAddr:
+		s += sameSender
+	case domain != "" && Domain(otherAddr) == domain:
+		s += sameDomain
+	}
+	return math.Min(1, s)
+}
+
+// vote picks the label with the most similarity behind it. Confidence is
+// the label's share of the votes, scaled down while evidence is thin.
+func vote(ns []neighbour) Result {
+	weights := map[label]float64{}
+	total := 0.0
+	for _, n := range ns {
+		if n.similarity < minSimilarity {
+			continue
+		}
+		weights[n.label] += n.similarity
+		total += n.similarity
+	}
+	var best label
+	bestW := 0.0
+	for l, w := range weights {
+		if w > bestW || (w == bestW && lessLabel(l, best)) {
+			best, bestW = l, w
+		}
+	}
+	if bestW == 0 {
+		return Result{}
+	}
+	conf := bestW / total * math.Min(1, bestW/fullEvidence)
+	return Result{Category: best.Category, Subcategory: best.Subcategory, QueueID: best.QueueID,
+		Confidence: math.Round(conf*100) / 100, Method: MethodHistory}
+}
+
+func lessLabel(a, b label) bool {
+	if a.Category != b.Category {
+		return a.Category < b.Category
+	}
+	if a.Subcategory != b.Subcategory {
+		return a.Subcategory < b.Subcategory
+	}
+	return a.QueueID < b.QueueID
+}
+
+func jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range a {
+		set[w] = true
+	}
+	inter, union := 0, len(set)
+	seen := map[string]bool{}
+	for _, w := range b {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			inter++
+		} else {
+			union++
+		}
+	}
+	return float64(inter) / float64(union)
+}
+
+// subjectStopwords are reply prefixes and filler words common in subjects.
+var subjectStopwords = map[string]bool{
+	"re": true, "fw": true, "fwd": true, "aw": true, "sv": true, "the": true, "and": true, "for": true,
+	"with": true, "from": true, "please": true, "help": true, "urgent": true, "request": true, "issue": true,
+	"problem": true, "not": true, "can't": true, "cannot": true, "our": true, "your": true, "you": true,
+	"this": true, "that": true, "new": true, "ticket": true, "question": true, "hello": true, "tkt": true,
+}
+
+// keywords returns the distinct lowercase words of s worth comparing.
+func keywords(s string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		w = strings.Trim(w, "'")
+		if len([]rune(w)) < 3 || subjectStopwords[w] || seen[w] || isNumber(w) {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+	}
+	return out
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package categorize
+
+import "context"
+
+// Outcome is the result of categorizing one ticket. Applied is false when
+// the confidence fell short and the ticket was left uncategorized.
+type Outcome struct {
+	TicketID string
+	Result   Result
+	Applied  bool
+}
+
+// pendingQuery selects recent email tickets that have not been through
+// categorization, oldest first, with the sender of the first inbound email.
+const pendingQuery = `
+select t.id::text, t.title, coalesce(t.description,''), coalesce(e.addr, r.email, '')
+from tickets t
+left join requesters r on r.id = t.requester_id
+left join lateral (
+    select ei.parsed_json->>'from' as addr from email_inbound ei where ei.ticket_id = t.id order by ei.created_at limit 1
+) e on true
+where t.source = 'email' and t.categorized_at is null and t.created_at > now() - interval '7 days'
+order by t.created_at
+limit $1`
+
+// applyQuery records a result. Category, subcategory and queue are only
+// filled in when applied and only where an agent has not set them already.
+const applyQuery = `
+update tickets set
+    category = case when $6 and category is null then $2 else category end,
+    subcategory = case when $6 and category is null then nullif($3,'') else subcategory end,
+    queue_id = case when $6 and queue_id is null then nullif($4,'')::uuid else queue_id end,
+    category_confidence = $5, categorized_by = nullif($7,''), categorized_at = now()
+where id = $1`
+
+// Sweep categorizes up to limit pending email tickets. Every ticket is
+// marked as processed, so one below minConfidence is not retried.
+func Sweep(ctx context.Context, db DB, minConfidence float64, limit int) ([]Outcome, error) {
+	rows, err := db.Query(ctx, pendingQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	var todo []Input
+	for rows.Next() {
+		var in Input
+		if err := rows.Scan(&in.TicketID, &in.Subject, &in.Body, &in.From); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		todo = append(todo, in)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(todo) == 0 {
+		return nil, nil
+	}
+
+	cs, err := Classifiers(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var out []Outcome
+	for _, in := range todo {
+		r, err := Run(ctx, cs, in, minConfidence)
+		if err != nil {
+			return out, err
+		}
+		o := Outcome{TicketID: in.TicketID, Result: r, Applied: r.Category != "" && r.Confidence >= minConfidence}
+		if _, err := db.Exec(ctx, applyQuery, in.TicketID, r.Category, r.Subcategory, r.QueueID,
+			r.Confidence, o.Applied, r.Method); err != nil {
+			return out, err
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+// Package contracts tracks what organizations are entitled to under their
+// support contracts. A requester belongs to the organization listing their
+// email domain; a contract grants included hours, a number of tickets, or
+// both, between two dates. Tickets opened while a contract is current count
+// against it, as does the time agents log on them.
+package contracts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Entitlement statuses.
+const (
+	// StatusNone: the requester belongs to no organization.
+	StatusNone = "none"
+	// StatusNoContract: the organization has never had a contract in force.
+	StatusNoContract = "no_contract"
+	// StatusCovered: a current contract has hours and tickets left.
+	StatusCovered = "covered"
+	// StatusExhausted: a current contract has used up its hours or tickets.
+	StatusExhausted = "exhausted"
+	// StatusExpired: the organization's latest contract has ended.
+	StatusExpired = "expired"
+)
+
+// Entitlement is a requester's standing under their organization's
+// contract. Remaining amounts are nil for limits the contract does not set.
+type Entitlement struct {
+	Status           string `json:"status"`
+	OrganizationID   string `json:"organization_id,omitempty"`
+	Organization     string `json:"organization,omitempty"`
+	ContractID       string `json:"contract_id,omitempty"`
+	Contract         string `json:"contract,omitempty"`
+	EndsOn           string `json:"ends_on,omitempty"`
+	RemainingMinutes *int   `json:"remaining_minutes,omitempty"`
+	RemainingTickets *int   `json:"remaining_tickets,omitempty"`
+	// Warning explains a status agents should act on.
+	Warning string `json:"warning,omitempty"`
+}
+
+// Current reports whether new tickets count against the contract.
+func (e Entitlement) Current() bool {
+	return e.Status == StatusCovered || e.Status == StatusExhausted
+}
+
+// UsageCols computes a contract's remaining minutes and tickets. It expects
+// support_contracts aliased c.
+const UsageCols = `c.included_minutes - (select coalesce(sum(te.minutes), 0) from ticket_time_entries te where te.contract_id = c.id),
+        c.included_tickets - (select count(*) from tickets ct where ct.contract_id = c.id)`
+
+// OrgJoin matches requesters (aliased r) to the organization listing their
+// email domain, as o.
+const OrgJoin = `join organizations o on split_part(lower(r.email), '@', 2) = any(o.domains)`
+
+// ForRequester returns the entitlement of requesterID: the contract in force
+// today for their organization, or else its most recent past contract.
+func ForRequester(ctx context.Context, db DB, requesterID string) (Entitlement, error) {
+	var e Entitlement
+	var contractID, contract *string
+	var endsOn *time.Time
+	var expired *bool
+	var remMin, remTix *int
+	err := db.QueryRow(ctx, `select o.id::text, o.name, c.id::text, c.name, c.ends_on, c.ends_on < current_date, `+UsageCols+`
+        from requesters r `+OrgJoin+`
+        left join lateral (select * from support_contracts c where c.organization_id = o.id and c.starts_on <= current_date
+                           order by c.ends_on >= current_date desc, c.ends_on desc limit 1) c on true
+        where r.id::text = $1
+        order by o.name limit 1`, requesterID).Scan(&e.OrganizationID, &e.Organization, &contractID, &contract, &endsOn, &expired, &remMin, &remTix)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Entitlement{Status: StatusNone}, nil
+	}
+	if err != nil {
+		return Entitlement{}, err
+	}
+	e.Status = StatusNoContract
+	if contractID == nil {
+		e.Warning = fmt.Sprintf("%s has no support contract.", e.Organization)
+		return e, nil
+	}
+	e.ContractID, e.Contract = *contractID, *contract
+	if endsOn != nil {
+		e.EndsOn = endsOn.Format(time.DateOnly)
+	}
+	e.RemainingMinutes, e.RemainingTickets = remMin, remTix
+	e.Status = Classify(expired != nil && *expired, remMin, remTix)
+	e.Warning = warning(e)
+	return e, nil
+}
+
+func warning(e Entitlement) string {
+	switch {
+	case e.Status == StatusExpired:
+		return fmt.Sprintf("%s's contract %q ended on %s.", e.Organization, e.Contract, e.EndsOn)
+	case e.Status != StatusExhausted:
+		return ""
+	case e.RemainingMinutes != nil && *e.RemainingMinutes <= 0:
+		return fmt.Sprintf("%s has used all the hours included in %q.", e.Organization, e.Contract)
+	}
+	return fmt.Sprintf("%s has used all the tickets included in %q.", e.Organization, e.Contract)
+}
+
+// Classify returns the status of a contract that has ended or not, with the
+// given amounts left.
+func Classify(expired bool, remainingMinutes, remainingTickets *int) string {
+	switch {
+	case expired:
+		return StatusExpired
+	case remainingMinutes != nil && *remainingMinutes <= 0, remainingTickets != nil && *remainingTickets <= 0:
+		return StatusExhausted
+	}
+	return StatusCovered
+}
@@ -0,0 +1,82 @@
+package contracts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func intp(n int) *int { return &n }
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		expired      bool
+		minutes, tix *int
+		want         string
+	}{
+		{false, intp(30), nil, StatusCovered},
+		{false, nil, intp(2), StatusCovered},
+		{false, intp(0), intp(2), StatusExhausted},
+		{false, intp(60), intp(-1), StatusExhausted},
+		{true, intp(60), nil, StatusExpired},
+	}
+	for _, tc := range cases {
+		if got := Classify(tc.expired, tc.minutes, tc.tix); got != tc.want {
+			t.Errorf("Classify(%v, %v, %v) = %s, want %s", tc.expired, tc.minutes, tc.tix, got, tc.want)
+		}
+	}
+}
+
+type row struct{ scan func(dest ...any) error }
+
+func (r row) Scan(dest ...any) error { return r.scan(dest...) }
+
+type db struct{ scan func(dest ...any) error }
+
+func (d db) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return row{d.scan}
+}
+
+func TestForRequester(t *testing.T) {
+	e, err := ForRequester(context.Background(), db{func(...any) error { return pgx.ErrNoRows }}, "r1")
+	if err != nil || e.Status != StatusNone {
+		t.Fatalf("expected none for a requester without an organization, got %+v, %v", e, err)
+	}
+
+	var contract *string
+	var expired bool
+	var minutes *int
+	d := db{func(dest ...any) error {
+		*dest[0].(*string) = "o1"
+		*dest[1].(*string) = "Acme"
+		if contract != nil {
+			id := "k1"
+			*dest[2].(**string) = &id
+			*dest[3].(**string) = contract
+			ends := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+			*dest[4].(**time.Time) = &ends
+			*dest[5].(**bool) = &expired
+			*dest[6].(**int) = minutes
+		}
+		return nil
+	}}
+	if e, _ = ForRequester(context.Background(), d, "r1"); e.Status != StatusNoContract || e.Warning == "" {
+		t.Fatalf("expected no_contract with a warning, got %+v", e)
+	}
+	name := "Gold"
+	contract, minutes = &name, intp(90)
+	if e, _ = ForRequester(context.Background(), d, "r1"); e.Status != StatusCovered || e.Warning != "" || *e.RemainingMinutes != 90 || !e.Current() {
+		t.Fatalf("expected a covered current contract, got %+v", e)
+	}
+	minutes = intp(0)
+	if e, _ = ForRequester(context.Background(), d, "r1"); e.Status != StatusExhausted || !strings.Contains(e.Warning, "hours") {
+		t.Fatalf("expected exhausted hours, got %+v", e)
+	}
+	expired = true
+	if e, _ = ForRequester(context.Background(), d, "r1"); e.Status != StatusExpired || e.Current() || !strings.Contains(e.Warning, "2026-06-30") {
+		t.Fatalf("expected expired, got %+v", e)
+	}
+}
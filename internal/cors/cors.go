@@ -0,0 +1,95 @@
+// Package cors matches request origins against allowed-origin patterns.
+//
+// A pattern is either an exact origin ("https://helpdesk.example.com") or a
+// wildcard-subdomain origin ("https://*.example.com"). The wildcard matches
+// one or more labels in front of the domain, never the bare domain itself,
+// and scheme and port must match exactly.
+package cors
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// Validate reports whether pattern is a usable origin pattern.
+func Validate(pattern string) error {
+	_, err := parse(pattern)
+	return err
+}
+
+// Allowed reports whether origin matches any of the patterns. Invalid
+// patterns never match.
+func Allowed(origin string, patterns []string) bool {
+	o, err := parseOrigin(origin)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		if pp, err := parse(p); err == nil && pp.match(o) {
+			return true
+		}
+	}
+	return false
+}
+
+type pattern struct {
+	scheme   string
+	host     string // without the "*." prefix for wildcards
+	port     string
+	wildcard bool
+}
+
+type origin struct {
+	scheme, host, port string
+}
+
+func parse(s string) (pattern, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return pattern{}, errors.New("empty origin")
+	}
+	if s == "*" {
+		return pattern{}, errors.New("bare * is not allowed; list origins or use https://*.example.com")
+	}
+	wildcard := false
+	if i := strings.Index(s, "://*."); i > 0 {
+		wildcard = true
+		s = s[:i+3] + s[i+5:]
+	}
+	o, err := parseOrigin(s)
+	if err != nil {
+		return pattern{}, err
+	}
+	if strings.Contains(o.host, "*") {
+		return pattern{}, errors.New("* is only allowed as the leftmost label")
+	}
+	if wildcard && !strings.Contains(o.host, ".") {
+		return pattern{}, errors.New("wildcard needs at least a two-label domain")
+	}
+	return pattern{scheme: o.scheme, host: o.host, port: o.port, wildcard: wildcard}, nil
+}
+
+func parseOrigin(s string) (origin, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return origin{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return origin{}, errors.New("scheme must be http or https")
+	}
+	if u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return origin{}, errors.New("must be scheme://host[:port]")
+	}
+	return origin{scheme: u.Scheme, host: strings.ToLower(u.Hostname()), port: u.Port()}, nil
+}
+
+func (p pattern) match(o origin) bool {
+	if p.scheme != o.scheme || p.port != o.port {
+		return false
+	}
+	if !p.wildcard {
+		return p.host == o.host
+	}
+	return strings.HasSuffix(o.host, "."+p.host)
+}
@@ -0,0 +1,43 @@
+package cors
+
+import "testing"
+
+func TestAllowed(t *testing.T) {
+	patterns := []string{"https://helpdesk.example.com", "https://*.corp.example", "http://localhost:5173"}
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://helpdesk.example.com", true},
+		{"https://HELPDESK.example.com", true},
+		{"http://helpdesk.example.com", false},
+		{"https://helpdesk.example.com:8443", false},
+		{"https://portal.corp.example", true},
+		{"https://a.b.corp.example", true},
+		{"https://corp.example", false},
+		{"https://evilcorp.example", false},
+		{"https://portal.corp.example.evil.com", false},
+		{"http://localhost:5173", true},
+		{"http://localhost:3000", false},
+		{"null", false},
+		{"", false},
+	}
+	for _, tc := range cases {
+		if got := Allowed(tc.origin, patterns); got != tc.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, ok := range []string{"https://example.com", "https://*.example.com", "http://localhost:8080", "https://*.example.com:8443"} {
+		if err := Validate(ok); err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "*", "https://*", "https://*.com", "example.com", "ftp://example.com", "https://example.com/path", "https://a.*.example.com", "https://user@example.com"} {
+		if err := Validate(bad); err == nil {
+			t.Errorf("Validate(%q) = nil, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 10, 7, 30, 0, time.UTC) // a Friday
+	for expr, want := range map[string]string{
+		"* * * * *":         "2026-10-16T10:08:00Z",
+		"*/15 * * * *":      "2026-10-16T10:15:00Z",
+		"0 3 * * *":         "2026-10-17T03:00:00Z",
+		"@hourly":           "2026-10-16T11:00:00Z",
+		"30 9 * * 1-5":      "2026-10-19T09:30:00Z",
+		"0 0 * * 7":         "2026-10-18T00:00:00Z",
+		"0 0 1 */3 *":       "2027-01-01T00:00:00Z",
+		"0 9 13 * 5":        "2026-10-23T09:00:00Z", // the 13th or any Friday
+		"0 0 29 2 *":        "2028-02-29T00:00:00Z",
+		"5,10-12 10 16 * *": "2026-10-16T10:10:00Z",
+	} {
+		s, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		if got := s.Next(from).Format(time.RFC3339); got != want {
+			t.Errorf("%s: next = %s, want %s", expr, got, want)
+		}
+	}
+	s, _ := Parse("0 0 30 2 *")
+	if !s.Next(from).IsZero() {
+		t.Error("30 February fired")
+	}
+
+	ny, _ := time.LoadLocation("America/New_York")
+	s, _ = Parse("0 3 * * *")
+	if got := s.Next(time.Date(2026, 10, 16, 12, 0, 0, 0, ny)); got.Hour() != 3 || got.Day() != 17 || got.Location() != ny {
+		t.Errorf("local next = %v", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("%q: expected an error", expr)
+		}
+	}
+}
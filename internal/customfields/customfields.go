@@ -0,0 +1,342 @@
+// Package customfields defines typed custom field schemas and checks values
+// against them. A schema lists fields by key with a type, whether they are
+// required, for select fields the allowed options and, optionally, a
+// condition on an earlier field for showing it at all. Asset categories
+// store their schema in custom_fields; the package knows nothing about
+// assets so ticket fields can be defined the same way.
+package customfields
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Type is the kind of value a field holds.
+type Type string
+
+// Field types.
+const (
+	TypeText        Type = "text"
+	TypeNumber      Type = "number"
+	TypeInteger     Type = "integer"
+	TypeBoolean     Type = "boolean"
+	TypeDate        Type = "date"
+	TypeSelect      Type = "select"
+	TypeMultiSelect Type = "multiselect"
+)
+
+// Types lists the field types in display order.
+var Types = []Type{TypeText, TypeNumber, TypeInteger, TypeBoolean, TypeDate, TypeSelect, TypeMultiSelect}
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+
+// Field defines one custom field. A field with ShowIf is only shown, and
+// only required, while its condition holds. Internal fields are for staff:
+// requesters neither see nor set them.
+type Field struct {
+	Key      string     `json:"key"`
+	Label    string     `json:"label,omitempty"`
+	Type     Type       `json:"type"`
+	Required bool       `json:"required,omitempty"`
+	Options  []string   `json:"options,omitempty"`
+	ShowIf   *Condition `json:"show_if,omitempty"`
+	Internal bool       `json:"internal,omitempty"`
+}
+
+// Condition holds while the field named by Field, defined earlier in the
+// schema and itself shown, has one of Values. Booleans compare as "true"
+// and "false", numbers in their shortest form, and a multiselect matches
+// when any of its options is listed.
+type Condition struct {
+	Field  string   `json:"field"`
+	Values []string `json:"values"`
+}
+
+// Schema is an ordered set of field definitions.
+type Schema struct {
+	Fields []Field `json:"fields"`
+}
+
+// Error is a problem with one field of a schema or of a set of values.
+type Error struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects the problems found by Check or Validate.
+type Errors []Error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return fmt.Sprintf("%s: %s", e[0].Field, e[0].Message)
+	}
+	return fmt.Sprintf("%s: %s (and %d more)", e[0].Field, e[0].Message, len(e)-1)
+}
+
+// Parse decodes a stored schema. Empty documents and blobs without a
+// fields list, as written before schemas existed, give an empty schema.
+func Parse(raw []byte) (Schema, error) {
+	var s Schema
+	if len(raw) == 0 || string(raw) == "null" {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return Schema{}, fmt.Errorf("decode custom field schema: %w", err)
+	}
+	return s, nil
+}
+
+// Check reports problems with the definitions themselves: malformed or
+// repeated keys, unknown types and options on the wrong kind of field.
+func (s Schema) Check() error {
+	var errs Errors
+	seen := map[string]bool{}
+	for i, f := range s.Fields {
+		at := fmt.Sprintf("fields[%d]", i)
+		switch {
+		case !keyPattern.MatchString(f.Key):
+			errs = append(errs, Error{at + ".key", "must be lowercase letters, digits and underscores, starting with a letter"})
+		case seen[f.Key]:
+			errs = append(errs, Error{at + ".key", fmt.Sprintf("%q is defined twice", f.Key)})
+		}
+		seen[f.Key] = true
+		if !slices.Contains(Types, f.Type) {
+			errs = append(errs, Error{at + ".type", fmt.Sprintf("unknown type %q", f.Type)})
+			continue
+		}
+		choice := f.Type == TypeSelect || f.Type == TypeMultiSelect
+		switch {
+		case choice && len(f.Options) == 0:
+			errs = append(errs, Error{at + ".options", "select fields need at least one option"})
+		case !choice && len(f.Options) > 0:
+			errs = append(errs, Error{at + ".options", "only select fields take options"})
+		}
+		for j, o := range f.Options {
+			if o == "" || slices.Index(f.Options, o) != j {
+				errs = append(errs, Error{at + ".options", "options must be distinct and not empty"})
+				break
+			}
+		}
+		if f.ShowIf != nil {
+			if msg := s.checkCondition(i, *f.ShowIf); msg != "" {
+				errs = append(errs, Error{at + ".show_if", msg})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkCondition returns what is wrong with the condition of the field at
+// index i, or "". Conditions may only look back, which keeps them free of
+// cycles and lets Visible decide in a single pass.
+func (s Schema) checkCondition(i int, c Condition) string {
+	j := slices.IndexFunc(s.Fields[:i], func(f Field) bool { return f.Key == c.Field })
+	if j < 0 {
+		return "field must name a field defined before this one"
+	}
+	if len(c.Values) == 0 {
+		return "values must list at least one value"
+	}
+	on := s.Fields[j]
+	if on.Internal && !s.Fields[i].Internal {
+		return "fields requesters see cannot depend on an internal field"
+	}
+	for _, v := range c.Values {
+		switch on.Type {
+		case TypeSelect, TypeMultiSelect:
+			if !slices.Contains(on.Options, v) {
+				return fmt.Sprintf("%q is not an option of %s", v, on.Key)
+			}
+		case TypeBoolean:
+			if v != "true" && v != "false" {
+				return "values of a boolean field are true or false"
+			}
+		}
+	}
+	return ""
+}
+
+// Visible reports, by key, which fields are shown given values.
+func (s Schema) Visible(values map[string]any) map[string]bool {
+	shown := make(map[string]bool, len(s.Fields))
+	for _, f := range s.Fields {
+		c := f.ShowIf
+		shown[f.Key] = c == nil || (shown[c.Field] && matches(values[c.Field], c.Values))
+	}
+	return shown
+}
+
+// matches reports whether v, a field value decoded from JSON, is one of
+// want.
+func matches(v any, want []string) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case []any:
+		return slices.ContainsFunc(v, func(o any) bool { return matches(o, want) })
+	case string:
+		return slices.Contains(want, v)
+	default:
+		return slices.Contains(want, fmt.Sprint(v))
+	}
+}
+
+// Prune returns values without those of hidden fields, so answers left
+// behind when a form changed its mind are not kept. values is not
+// modified.
+func (s Schema) Prune(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	shown := s.Visible(values)
+	out := make(map[string]any, len(values))
+	for k, v := range values {
+		if visible, defined := shown[k]; defined && !visible {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Public returns the schema without its internal fields, as requesters
+// see it.
+func (s Schema) Public() Schema {
+	out := Schema{Fields: []Field{}}
+	for _, f := range s.Fields {
+		if !f.Internal {
+			out.Fields = append(out.Fields, f)
+		}
+	}
+	return out
+}
+
+// StripInternal returns values without those of internal fields. values is
+// not modified.
+func (s Schema) StripInternal(values map[string]any) map[string]any {
+	if values == nil {
+		return nil
+	}
+	out := make(map[string]any, len(values))
+	for k, v := range values {
+		out[k] = v
+	}
+	for _, f := range s.Fields {
+		if f.Internal {
+			delete(out, f.Key)
+		}
+	}
+	return out
+}
+
+// Validate checks values, as decoded from JSON, against the schema. An empty
+// schema accepts anything; otherwise keys the schema does not define are
+// rejected and required fields must be present and not null. Fields hidden
+// by their condition are neither required nor checked.
+func (s Schema) Validate(values map[string]any) error {
+	if len(s.Fields) == 0 {
+		return nil
+	}
+	var errs Errors
+	defined := map[string]bool{}
+	shown := s.Visible(values)
+	for _, f := range s.Fields {
+		defined[f.Key] = true
+		if !shown[f.Key] {
+			continue
+		}
+		v, ok := values[f.Key]
+		if !ok || v == nil {
+			if f.Required {
+				errs = append(errs, Error{f.Key, "is required"})
+			}
+			continue
+		}
+		if msg := f.check(v); msg != "" {
+			errs = append(errs, Error{f.Key, msg})
+		}
+	}
+	var unknown []string
+	for k := range values {
+		if !defined[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	slices.Sort(unknown)
+	for _, k := range unknown {
+		errs = append(errs, Error{k, "is not a field of this schema"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// check returns why v is not a valid value for f, or "".
+func (f Field) check(v any) string {
+	switch f.Type {
+	case TypeText:
+		if _, ok := v.(string); !ok {
+			return "must be a string"
+		}
+	case TypeNumber:
+		if _, ok := number(v); !ok {
+			return "must be a number"
+		}
+	case TypeInteger:
+		if n, ok := number(v); !ok || n != math.Trunc(n) {
+			return "must be a whole number"
+		}
+	case TypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return "must be true or false"
+		}
+	case TypeDate:
+		d, ok := v.(string)
+		if !ok {
+			return "must be a date (YYYY-MM-DD)"
+		}
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case TypeSelect:
+		o, ok := v.(string)
+		if !ok || !slices.Contains(f.Options, o) {
+			return "must be one of the field's options"
+		}
+	case TypeMultiSelect:
+		list, ok := v.([]any)
+		if !ok {
+			return "must be a list of the field's options"
+		}
+		for _, item := range list {
+			o, ok := item.(string)
+			if !ok || !slices.Contains(f.Options, o) {
+				return "must be a list of the field's options"
+			}
+		}
+	}
+	return ""
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
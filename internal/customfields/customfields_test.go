@@ -0,0 +1,132 @@
+package customfields
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseLegacyBlob(t *testing.T) {
+	for _, raw := range []string{``, `null`, `{}`, `{"color":"red"}`} {
+		s, err := Parse([]byte(raw))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", raw, err)
+		}
+		if len(s.Fields) != 0 {
+			t.Fatalf("Parse(%q) = %d fields", raw, len(s.Fields))
+		}
+		if err := s.Validate(map[string]any{"anything": 1}); err != nil {
+			t.Fatalf("empty schema rejected values: %v", err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	bad := Schema{Fields: []Field{
+		{Key: "Ram", Type: TypeInteger},
+		{Key: "os", Type: TypeSelect},
+		{Key: "os", Type: TypeText, Options: []string{"a"}},
+		{Key: "colour", Type: "color"},
+	}}
+	var errs Errors
+	if !errors.As(bad.Check(), &errs) {
+		t.Fatal("expected errors")
+	}
+	want := []string{"fields[0].key", "fields[1].options", "fields[2].key", "fields[2].options", "fields[3].type"}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %+v", errs)
+	}
+	for i, w := range want {
+		if errs[i].Field != w {
+			t.Fatalf("errors[%d] = %s, want %s", i, errs[i].Field, w)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Schema{Fields: []Field{
+		{Key: "ram_gb", Type: TypeInteger, Required: true},
+		{Key: "os", Type: TypeSelect, Options: []string{"windows", "macos", "linux"}},
+		{Key: "ports", Type: TypeMultiSelect, Options: []string{"usb_c", "hdmi"}},
+		{Key: "leased", Type: TypeBoolean},
+		{Key: "lease_end", Type: TypeDate},
+	}}
+	if err := s.Validate(map[string]any{
+		"ram_gb": 16.0, "os": "linux", "ports": []any{"hdmi"}, "leased": true, "lease_end": "2027-01-31",
+	}); err != nil {
+		t.Fatalf("valid values rejected: %v", err)
+	}
+
+	var errs Errors
+	if !errors.As(s.Validate(map[string]any{
+		"ram_gb": 15.5, "os": "beos", "ports": []any{"vga"}, "leased": "yes", "lease_end": "31/01/2027", "gpu": "none",
+	}), &errs) {
+		t.Fatal("expected errors")
+	}
+	got := map[string]bool{}
+	for _, e := range errs {
+		got[e.Field] = true
+	}
+	for _, k := range []string{"ram_gb", "os", "ports", "leased", "lease_end", "gpu"} {
+		if !got[k] {
+			t.Fatalf("no error for %s in %+v", k, errs)
+		}
+	}
+
+	if !errors.As(s.Validate(map[string]any{"ram_gb": nil}), &errs) || errs[0].Field != "ram_gb" {
+		t.Fatalf("missing required field accepted: %+v", errs)
+	}
+}
+
+func TestConditions(t *testing.T) {
+	s := Schema{Fields: []Field{
+		{Key: "kind", Type: TypeSelect, Options: []string{"laptop", "phone"}, Required: true},
+		{Key: "os", Type: TypeSelect, Options: []string{"windows", "macos"}, Required: true, ShowIf: &Condition{Field: "kind", Values: []string{"laptop"}}},
+		{Key: "reimage", Type: TypeBoolean, ShowIf: &Condition{Field: "os", Values: []string{"windows"}}},
+		{Key: "reason", Type: TypeText, Required: true, ShowIf: &Condition{Field: "reimage", Values: []string{"true"}}},
+	}}
+	if err := s.Check(); err != nil {
+		t.Fatalf("valid schema rejected: %v", err)
+	}
+	if err := s.Validate(map[string]any{"kind": "phone"}); err != nil {
+		t.Fatalf("hidden required fields enforced: %v", err)
+	}
+	var errs Errors
+	if !errors.As(s.Validate(map[string]any{"kind": "laptop", "os": "windows", "reimage": true}), &errs) || len(errs) != 1 || errs[0].Field != "reason" {
+		t.Fatalf("expected reason to be required, got %+v", errs)
+	}
+	// A field stays hidden while the field its condition names is hidden.
+	pruned := s.Prune(map[string]any{"kind": "phone", "os": "windows", "reimage": true, "reason": "x", "extra": 1})
+	if len(pruned) != 2 || pruned["kind"] != "phone" || pruned["extra"] != 1 {
+		t.Fatalf("Prune = %v", pruned)
+	}
+
+	bad := Schema{Fields: []Field{
+		{Key: "a", Type: TypeText, ShowIf: &Condition{Field: "b", Values: []string{"x"}}},
+		{Key: "b", Type: TypeSelect, Options: []string{"x"}},
+		{Key: "c", Type: TypeText, ShowIf: &Condition{Field: "b", Values: []string{"y"}}},
+		{Key: "d", Type: TypeText, ShowIf: &Condition{Field: "b"}},
+	}}
+	if !errors.As(bad.Check(), &errs) || len(errs) != 3 {
+		t.Fatalf("expected three condition errors, got %+v", errs)
+	}
+}
+
+func TestInternalFields(t *testing.T) {
+	s := Schema{Fields: []Field{
+		{Key: "device", Type: TypeSelect, Options: []string{"laptop", "phone"}},
+		{Key: "triage", Type: TypeText, Internal: true},
+		{Key: "model", Type: TypeText, ShowIf: &Condition{Field: "triage", Values: []string{"x"}}},
+	}}
+	var errs Errors
+	if !errors.As(s.Check(), &errs) || len(errs) != 1 || errs[0].Field != "fields[2].show_if" {
+		t.Fatalf("expected a show_if error, got %v", s.Check())
+	}
+	if pub := s.Public(); len(pub.Fields) != 2 || pub.Fields[0].Key != "device" || pub.Fields[1].Key != "model" {
+		t.Fatalf("Public() = %+v", pub)
+	}
+	values := map[string]any{"device": "laptop", "triage": "hw", "other": 1}
+	got := s.StripInternal(values)
+	if len(got) != 2 || got["device"] != "laptop" || got["other"] != 1 || values["triage"] != "hw" {
+		t.Fatalf("StripInternal() = %v, values %v", got, values)
+	}
+}
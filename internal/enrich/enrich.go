@@ -0,0 +1,205 @@
+// Package enrich detects the language and sentiment of requester messages and
+// stores them on the ticket, routing unassigned tickets to a team that handles
+// the detected language. Detection is done by a Provider; Heuristic is the
+// built-in default and HTTP delegates to an external service.
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentiment labels.
+const (
+	Positive = "positive"
+	Neutral  = "neutral"
+	Negative = "negative"
+)
+
+// MinLanguageConfidence is the confidence below which a detected language is
+// ignored and the ticket keeps its previous one. Short replies such as
+// "thanks!" rarely identify a language.
+const MinLanguageConfidence = 0.5
+
+// Analysis is the result of analysing one message. Language is an ISO 639-1
+// code, or empty when it could not be detected. SentimentScore ranges from
+// -1 (most negative) to 1.
+type Analysis struct {
+	Language           string  `json:"language"`
+	LanguageConfidence float64 `json:"language_confidence"`
+	Sentiment          string  `json:"sentiment"`
+	SentimentScore     float64 `json:"sentiment_score"`
+}
+
+// Provider analyses message text.
+type Provider interface {
+	Analyze(ctx context.Context, text string) (Analysis, error)
+}
+
+// New returns the provider called name: "heuristic", or "http" which posts
+// to url.
+func New(name, url string) (Provider, error) {
+	switch name {
+	case "heuristic":
+		return Heuristic{}, nil
+	case "http":
+		if url == "" {
+			return nil, errors.New("http enrichment provider requires a URL")
+		}
+		return HTTP{URL: url}, nil
+	default:
+		return nil, fmt.Errorf("unknown enrichment provider %q", name)
+	}
+}
+
+// HTTP delegates analysis to an external service. It posts {"text": ...}
+// and expects an Analysis as JSON in return.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+// httpTimeout bounds a single call to an HTTP provider.
+const httpTimeout = 10 * time.Second
+
+// Analyze implements Provider.
+func (h HTTP) Analyze(ctx context.Context, text string) (Analysis, error) {
+	var out Analysis
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return out, err
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return out, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return out, fmt.Errorf("enrichment provider returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return out, fmt.Errorf("decode enrichment response: %w", err)
+	}
+	return out, nil
+}
+
+// DB is the subset of the database used by Sweep.
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Result is the outcome of enriching one ticket. TeamID and Language are set
+// when the ticket was routed to a team by language.
+type Result struct {
+	TicketID string
+	Analysis Analysis
+	TeamID   string
+	Language string
+}
+
+// lookback bounds how far back Sweep looks for new comments. It only needs
+// to cover the sweep interval plus any worker downtime worth catching up on.
+const lookback = "1 hour"
+
+// pendingQuery selects tickets never enriched and tickets with a public
+// comment newer than their last enrichment, newest first, along with their
+// latest requester message. Requester messages are public comments by the
+// requester's user, by a requester record (Discord, guests) or without an
+// author (inbound email).
+const pendingQuery = `
+select t.id::text, t.title, coalesce(t.description,''),
+       coalesce((select c.body_md from ticket_comments c left join users u on u.id = c.author_id
+           where c.ticket_id = t.id and not c.is_internal
+             and (c.author_id is null or c.author_requester_id is not null or lower(u.email) = lower(r.email))
+           order by c.created_at desc limit 1), '')
+from tickets t
+left join requesters r on r.id = t.requester_id
+where t.id in (
+    select id from tickets where enriched_at is null
+    union
+    select c.ticket_id from ticket_comments c join tickets ct on ct.id = c.ticket_id
+    where c.created_at > now() - interval '` + lookback + `' and c.created_at > ct.enriched_at and not c.is_internal)
+order by t.created_at desc
+limit $1`
+
+// Sweep enriches up to limit pending tickets with p. The latest requester
+// comment is analysed, or the title and description for tickets without one.
+// Open tickets without a team are routed to the first team, by name, whose
+// languages include the ticket's language. A provider error stops the sweep
+// so a failing service is retried on the next one.
+func Sweep(ctx context.Context, db DB, p Provider, limit int) ([]Result, error) {
+	rows, err := db.Query(ctx, pendingQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	type pending struct{ id, text string }
+	var todo []pending
+	for rows.Next() {
+		var id, title, desc, latest string
+		if err := rows.Scan(&id, &title, &desc, &latest); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		text := latest
+		if text == "" {
+			text = title + "\n\n" + desc
+		}
+		todo = append(todo, pending{id, text})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []Result
+	for _, t := range todo {
+		a, err := p.Analyze(ctx, t.text)
+		if err != nil {
+			return out, fmt.Errorf("analyze ticket %s: %w", t.id, err)
+		}
+		lang := a.Language
+		if a.LanguageConfidence < MinLanguageConfidence {
+			lang = ""
+		}
+		// External providers may use their own labels; keep the column's.
+		a.SentimentScore = max(-1, min(1, a.SentimentScore))
+		if a.Sentiment != Positive && a.Sentiment != Neutral && a.Sentiment != Negative {
+			a.Sentiment = label(a.SentimentScore)
+		}
+		if _, err := db.Exec(ctx, `update tickets set language = coalesce(nullif($2,''), language),
+            sentiment = nullif($3,''), sentiment_score = $4, enriched_at = now() where id = $1`,
+			t.id, lang, a.Sentiment, a.SentimentScore); err != nil {
+			return out, err
+		}
+		res := Result{TicketID: t.id, Analysis: a}
+		err = db.QueryRow(ctx, `update tickets t set team_id = (
+                select tm.id from teams tm where t.language = any(tm.languages) order by tm.name limit 1)
+            where t.id = $1 and t.team_id is null and t.status not in ('Resolved','Closed')
+              and exists (select 1 from teams tm where t.language = any(tm.languages))
+            returning t.team_id::text, t.language`, t.id).Scan(&res.TeamID, &res.Language)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return out, err
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
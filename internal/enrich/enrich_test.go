@@ -0,0 +1,153 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestHeuristic(t *testing.T) {
+	cases := []struct {
+		text, lang, sentiment string
+	}{
+		{"Hi, the printer on the second floor is not working and I have a meeting in an hour. Can you please have a look?", "en", Neutral},
+		{"This is unacceptable. I am really frustrated, the VPN has been down for the third time this week!", "en", Negative},
+		{"Thanks so much, that fixed it. Great job!", "en", Positive},
+		{"Hola, no puedo acceder a mi correo desde el portátil. ¿Me pueden ayudar por favor? Gracias", "es", Positive},
+		{"Bonjour, je ne peux pas me connecter à mon compte depuis ce matin. C'est inacceptable.", "fr", Negative},
+		{"Hallo, ich kann mich seit heute Morgen nicht mehr anmelden. Bitte um Hilfe.", "de", Neutral},
+		{"Здравствуйте, не работает принтер в офисе.", "ru", Neutral},
+		{"プリンターが動きません。確認してください。", "ja", Neutral},
+		{"That was not bad at all", "en", Positive},
+	}
+	for _, tc := range cases {
+		a, err := Heuristic{}.Analyze(context.Background(), tc.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Language != tc.lang || a.LanguageConfidence < MinLanguageConfidence {
+			t.Errorf("%q: expected language %s, got %s (%.2f)", tc.text, tc.lang, a.Language, a.LanguageConfidence)
+		}
+		if a.Sentiment != tc.sentiment {
+			t.Errorf("%q: expected %s sentiment, got %s (%.2f)", tc.text, tc.sentiment, a.Sentiment, a.SentimentScore)
+		}
+	}
+
+	a, _ := Heuristic{}.Analyze(context.Background(), "thanks!\n> Le serveur est en panne et je ne peux pas travailler")
+	if a.LanguageConfidence >= MinLanguageConfidence {
+		t.Fatalf("expected a short reply to be inconclusive, got %s (%.2f)", a.Language, a.LanguageConfidence)
+	}
+}
+
+func TestHTTPProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Text string }
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in.Text != "hej" {
+			http.Error(w, "bad text", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Analysis{Language: "sv", LanguageConfidence: 0.9, Sentiment: Positive, SentimentScore: 0.4})
+	}))
+	defer srv.Close()
+	p, err := New("http", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := p.Analyze(context.Background(), "hej")
+	if err != nil || a.Language != "sv" || a.Sentiment != Positive {
+		t.Fatalf("unexpected analysis %+v %v", a, err)
+	}
+	if _, err := p.Analyze(context.Background(), "other"); err == nil {
+		t.Fatal("expected provider errors to be returned")
+	}
+	if _, err := New("http", ""); err == nil {
+		t.Fatal("expected http provider without URL to fail")
+	}
+}
+
+type sweepDB struct {
+	pending [][]string // id, title, description, latest comment
+	updates map[string][]any
+	teams   map[string]string // ticket id -> routed team
+}
+
+func (db *sweepDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return &sweepRows{list: db.pending}, nil
+}
+
+func (db *sweepDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return sweepRow{team: db.teams[args[0].(string)]}
+}
+
+func (db *sweepDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if strings.Contains(sql, "update tickets set language") {
+		db.updates[args[0].(string)] = args[1:]
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+type sweepRows struct {
+	list [][]string
+	i    int
+}
+
+func (r *sweepRows) Close()                                       {}
+func (r *sweepRows) Err() error                                   { return nil }
+func (r *sweepRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *sweepRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *sweepRows) RawValues() [][]byte                          { return nil }
+func (r *sweepRows) Values() ([]any, error)                       { return nil, nil }
+func (r *sweepRows) Conn() *pgx.Conn                              { return nil }
+func (r *sweepRows) Next() bool                                   { r.i++; return r.i <= len(r.list) }
+func (r *sweepRows) Scan(dest ...any) error {
+	for i, v := range r.list[r.i-1] {
+		*dest[i].(*string) = v
+	}
+	return nil
+}
+
+type sweepRow struct{ team string }
+
+func (r sweepRow) Scan(dest ...any) error {
+	if r.team == "" {
+		return pgx.ErrNoRows
+	}
+	*dest[0].(*string) = r.team
+	*dest[1].(*string) = "es"
+	return nil
+}
+
+func TestSweep(t *testing.T) {
+	db := &sweepDB{
+		pending: [][]string{
+			{"t1", "No puedo imprimir", "La impresora de la oficina no funciona y necesito imprimir un contrato para el cliente.", ""},
+			{"t2", "VPN down", "The VPN is down.", "Still broken?? This is ridiculous, I have been waiting all day."},
+			{"t3", "Printer", "The printer is jammed and I need it for the board meeting.", "ok"},
+		},
+		updates: map[string][]any{},
+		teams:   map[string]string{"t1": "team-es"},
+	}
+	res, err := Sweep(context.Background(), db, Heuristic{}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].TeamID != "team-es" || res[1].TeamID != "" {
+		t.Fatalf("unexpected results %+v", res)
+	}
+	if db.updates["t1"][0] != "es" {
+		t.Fatalf("expected spanish for t1, got %v", db.updates["t1"])
+	}
+	if db.updates["t2"][1] != Negative {
+		t.Fatalf("expected the latest comment to drive sentiment, got %v", db.updates["t2"])
+	}
+	if db.updates["t3"][0] != "" {
+		t.Fatalf("expected an inconclusive reply to keep the language, got %v", db.updates["t3"])
+	}
+}
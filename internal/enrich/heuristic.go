@@ -0,0 +1,233 @@
+package enrich
+
+import (
+	"context"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Heuristic is the default Provider. It identifies the language from the
+// writing system and, for Latin text, from common function words, and scores
+// sentiment with a small multilingual lexicon. Problem vocabulary ("error",
+// "broken") is deliberately absent: nearly every ticket reports a problem, so
+// only the requester's tone counts.
+type Heuristic struct{}
+
+// Analyze implements Provider.
+func (Heuristic) Analyze(_ context.Context, text string) (Analysis, error) {
+	text = stripQuotes(text)
+	words := tokenize(text)
+	lang, conf := detectLanguage(text, words)
+	score := sentimentScore(words)
+	return Analysis{Language: lang, LanguageConfidence: conf, Sentiment: label(score), SentimentScore: score}, nil
+}
+
+// stripQuotes drops quoted lines from email replies so earlier messages do
+// not count towards the new one.
+func stripQuotes(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), ">") {
+			continue
+		}
+		kept = append(kept, l)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// tokenize lowercases text and splits it into words, keeping apostrophes so
+// contractions such as "don't" stay whole. URLs are skipped.
+func tokenize(text string) []string {
+	var words []string
+	for _, f := range strings.Fields(strings.ToLower(strings.ReplaceAll(text, "’", "'"))) {
+		if strings.Contains(f, "://") || strings.HasPrefix(f, "www.") {
+			continue
+		}
+		for _, w := range strings.FieldsFunc(f, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) {
+			if w = strings.Trim(w, "'"); w != "" {
+				words = append(words, w)
+			}
+		}
+	}
+	return words
+}
+
+// scripts maps non-Latin writing systems to the language they most likely
+// indicate in a helpdesk.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+}
+
+// stopwords are frequent function words per Latin-script language.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "to", "of", "it", "in", "that", "this", "with", "for", "you", "have", "not", "was", "my", "can", "i", "please", "we", "be", "on"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "es", "en", "un", "una", "por", "con", "para", "no", "mi", "se", "lo", "está", "pero", "como", "gracias", "hola"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "que", "pour", "avec", "pas", "je", "mon", "ma", "vous", "nous", "dans", "il", "ne", "merci", "bonjour"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "ein", "eine", "mit", "für", "zu", "den", "auf", "es", "sie", "wir", "mein", "bitte", "danke", "hallo", "kann", "sich"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "é", "um", "uma", "para", "com", "não", "meu", "minha", "em", "do", "da", "por", "obrigado", "obrigada", "olá", "você"},
+	"it": {"il", "lo", "la", "gli", "le", "di", "che", "e", "è", "un", "una", "per", "con", "non", "mio", "mia", "sono", "del", "della", "grazie", "ciao", "questo", "ho"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "ik", "met", "voor", "op", "zijn", "mijn", "wij", "je", "bedankt", "alstublieft", "graag", "dank", "hallo", "er", "ook"},
+}
+
+var stopwordIndex = func() map[string][]string {
+	idx := map[string][]string{}
+	for lang, ws := range stopwords {
+		for _, w := range ws {
+			idx[w] = append(idx[w], lang)
+		}
+	}
+	return idx
+}()
+
+// detectLanguage returns the most likely language and a confidence in [0,1].
+func detectLanguage(text string, words []string) (string, float64) {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	// Japanese mixes kana with Han characters.
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["zh"]
+		delete(counts, "zh")
+	}
+	if best, n := top(counts); letters > 0 && float64(n)/float64(letters) >= 0.3 {
+		if best == "ru" && strings.ContainsAny(text, "іїєґІЇЄҐ") {
+			best = "uk"
+		}
+		return best, round(math.Min(1, float64(n)/float64(letters)+0.2))
+	}
+
+	hits := map[string]int{}
+	total := 0
+	for _, w := range words {
+		langs := stopwordIndex[w]
+		for _, l := range langs {
+			hits[l]++
+		}
+		if len(langs) > 0 {
+			total++
+		}
+	}
+	best, n := top(hits)
+	if n == 0 {
+		return "", 0
+	}
+	second := 0
+	for l, c := range hits {
+		if l != best && c > second {
+			second = c
+		}
+	}
+	// Confidence grows with the lead over the runner-up and with the amount
+	// of evidence; a handful of words is never conclusive.
+	lead := float64(n-second) / float64(n)
+	evidence := math.Min(1, float64(total)/4)
+	return best, round(math.Min(1, 0.5*lead+0.5)*evidence)
+}
+
+// top returns the key with the highest count, ties broken alphabetically so
+// results are stable.
+func top(counts map[string]int) (string, int) {
+	best, n := "", 0
+	for k, c := range counts {
+		if c > n || (c == n && k < best) {
+			best, n = k, c
+		}
+	}
+	return best, n
+}
+
+// polarity scores tone words in the supported languages.
+var polarity = map[string]float64{
+	// English
+	"thanks": 1, "thank": 1, "great": 1, "excellent": 1, "awesome": 1, "perfect": 1, "appreciate": 1,
+	"appreciated": 1, "helpful": 1, "love": 1, "happy": 1, "glad": 1, "amazing": 1, "fantastic": 1,
+	"wonderful": 1, "brilliant": 1, "pleased": 1, "good": 0.5, "nice": 0.5,
+	"frustrated": -1, "frustrating": -1, "angry": -1, "annoyed": -1, "annoying": -1, "unacceptable": -1.5,
+	"ridiculous": -1, "terrible": -1.5, "awful": -1.5, "horrible": -1.5, "worst": -1.5, "useless": -1,
+	"disappointed": -1, "disappointing": -1, "furious": -1.5, "hate": -1, "bad": -0.5, "poor": -0.5,
+	"unhappy": -1, "upset": -1, "fed": -0.5, "sick": -0.5, "still": -0.5,
+	// Spanish
+	"gracias": 1, "excelente": 1, "genial": 1, "perfecto": 1, "contento": 1, "encantado": 1,
+	"inaceptable": -1.5, "frustrado": -1, "molesto": -1, "pésimo": -1.5, "enojado": -1,
+	// French
+	"merci": 1, "parfait": 1, "génial": 1, "ravi": 1, "ravie": 1,
+	"inacceptable": -1.5, "nul": -1, "furieux": -1.5, "déçu": -1, "déçue": -1, "énervé": -1,
+	// German
+	"danke": 1, "super": 1, "toll": 1, "perfekt": 1, "zufrieden": 1,
+	"schrecklich": -1.5, "inakzeptabel": -1.5, "enttäuscht": -1, "ärgerlich": -1, "furchtbar": -1.5, "wütend": -1.5,
+	// Portuguese and Italian
+	"obrigado": 1, "obrigada": 1, "ótimo": 1, "grazie": 1, "ottimo": 1, "perfetto": 1,
+	"inaceitável": -1.5, "péssimo": -1.5, "inaccettabile": -1.5, "pessimo": -1.5,
+}
+
+// negators flip the polarity of the next couple of words.
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "don't": true, "doesn't": true, "isn't": true, "wasn't": true,
+	"aren't": true, "can't": true, "cannot": true, "won't": true, "nicht": true, "kein": true, "keine": true,
+	"pas": true, "jamais": true, "nunca": true, "non": true, "não": true,
+}
+
+// sentimentScore returns a score in [-1,1]. The balance of positive and
+// negative words is damped when there are few of them.
+func sentimentScore(words []string) float64 {
+	var pos, neg float64
+	negate := 0
+	for _, w := range words {
+		if negators[w] {
+			negate = 2
+			continue
+		}
+		p := polarity[w]
+		if negate > 0 {
+			p = -p
+			negate--
+		}
+		if p > 0 {
+			pos += p
+		} else {
+			neg -= p
+		}
+	}
+	if pos+neg == 0 {
+		return 0
+	}
+	return round((pos - neg) / (pos + neg) * math.Min(1, (pos+neg)/2))
+}
+
+func label(score float64) string {
+	switch {
+	case score >= 0.25:
+		return Positive
+	case score <= -0.25:
+		return Negative
+	default:
+		return Neutral
+	}
+}
+
+func round(f float64) float64 { return math.Round(f*100) / 100 }
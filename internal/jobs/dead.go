@@ -0,0 +1,182 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs that failed for good are kept in the dead-letter queue until an
+// admin retries or deletes them, or DeadCap newer failures push them out.
+const (
+	// QueueDead maps dead-letter ids, the lease token the job last ran
+	// under, to their entries.
+	QueueDead = "jobs:dead"
+	// deadIndex is a sorted set of dead-letter ids scored by failure time
+	// in unix milliseconds.
+	deadIndex = "jobs:dead:index"
+)
+
+// DeadCap bounds the dead-letter queue; the oldest entries go first.
+const DeadCap = 10000
+
+// trimDead drops the oldest dead letters over the cap in ARGV[5].
+// It expects the dead hash and index as KEYS[4] and KEYS[5].
+const trimDead = `
+local over = redis.call('ZCARD', KEYS[5]) - tonumber(ARGV[5])
+if over > 0 then
+  for _, id in ipairs(redis.call('ZRANGE', KEYS[5], 0, over - 1)) do
+    redis.call('HDEL', KEYS[4], id)
+  end
+  redis.call('ZREMRANGEBYRANK', KEYS[5], 0, over - 1)
+end
+`
+
+// deadEntry is a dead letter as stored.
+type deadEntry struct {
+	ID       string    `json:"id"`
+	Queue    string    `json:"queue"`
+	Job      string    `json:"job"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// buryScript moves a leased job to the dead-letter queue.
+// KEYS: processing set, leases hash, redeliveries hash, dead hash, dead
+// index. ARGV: token, entry, failed at, job, dead cap.
+var buryScript = redis.NewScript(`
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[4])
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[5], ARGV[3], ARGV[1])
+` + trimDead + `
+return 1
+`)
+
+// Bury ends l by moving its job to the dead-letter queue with reason.
+func Bury(ctx context.Context, rdb redis.Cmdable, l *Lease, reason string) error {
+	now := time.Now()
+	b, err := json.Marshal(deadEntry{ID: l.Token, Queue: l.Queue, Job: string(l.Raw), Error: reason, FailedAt: now.UTC()})
+	if err != nil {
+		return err
+	}
+	keys := []string{QueueProcessing, QueueLeases, redeliveries, QueueDead, deadIndex}
+	return buryScript.Run(ctx, rdb, keys, l.Token, b, now.UnixMilli(), l.Raw, DeadCap).Err()
+}
+
+// DeadJob is a dead letter as listed. Raw holds the stored job when it
+// cannot be decoded.
+type DeadJob struct {
+	ID        string          `json:"id"`
+	Queue     string          `json:"queue"`
+	Type      string          `json:"type"`
+	JobID     string          `json:"job_id,omitempty"`
+	Attempt   int             `json:"attempt"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	CreatedAt time.Time       `json:"created_at,omitzero"`
+	Error     string          `json:"error"`
+	FailedAt  time.Time       `json:"failed_at"`
+	Raw       string          `json:"raw,omitempty"`
+}
+
+// ListDead returns up to limit dead letters from offset, newest first,
+// and how many there are.
+func ListDead(ctx context.Context, rdb redis.Cmdable, offset, limit int) ([]DeadJob, int64, error) {
+	total, err := rdb.ZCard(ctx, deadIndex).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	out := []DeadJob{}
+	ids, err := rdb.ZRevRange(ctx, deadIndex, int64(offset), int64(offset+limit)-1).Result()
+	if err != nil || len(ids) == 0 {
+		return out, total, err
+	}
+	vals, err := rdb.HMGet(ctx, QueueDead, ids...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var e deadEntry
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			continue
+		}
+		d := DeadJob{ID: e.ID, Queue: e.Queue, Error: e.Error, FailedAt: e.FailedAt}
+		var j Job
+		if err := json.Unmarshal([]byte(e.Job), &j); err != nil {
+			d.Raw = e.Job
+		} else {
+			d.Type, d.JobID, d.Attempt, d.Data, d.CreatedAt = j.Type, j.ID, j.Attempts(), j.Data, j.CreatedAt
+		}
+		out = append(out, d)
+	}
+	return out, total, nil
+}
+
+// ErrNotDead is returned for dead-letter ids that do not exist.
+var ErrNotDead = errors.New("dead letter not found")
+
+// retryDeadScript requeues a dead letter unless another caller got to it
+// first. KEYS: dead hash, dead index, queue. ARGV: id, job.
+var retryDeadScript = redis.NewScript(`
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then return 0 end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('RPUSH', KEYS[3], ARGV[2])
+return 1
+`)
+
+// RetryDead puts dead letter id back on its queue as a first attempt.
+func RetryDead(ctx context.Context, rdb redis.Cmdable, id string) error {
+	s, err := rdb.HGet(ctx, QueueDead, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return ErrNotDead
+	}
+	if err != nil {
+		return err
+	}
+	var e deadEntry
+	if err := json.Unmarshal([]byte(s), &e); err != nil {
+		return err
+	}
+	raw, queue := e.Job, e.Queue
+	var j Job
+	if json.Unmarshal([]byte(e.Job), &j) == nil {
+		j.Attempt = 0
+		j.EnqueuedAt = time.Now().UTC()
+		b, err := json.Marshal(j)
+		if err != nil {
+			return err
+		}
+		raw, queue = string(b), QueueFor(j.Type)
+	}
+	if queue == "" {
+		queue = Queue
+	}
+	n, err := retryDeadScript.Run(ctx, rdb, []string{QueueDead, deadIndex, queue}, id, raw).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotDead
+	}
+	return nil
+}
+
+// DeleteDead discards dead letter id.
+func DeleteDead(ctx context.Context, rdb redis.Cmdable, id string) error {
+	n, err := rdb.HDel(ctx, QueueDead, id).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotDead
+	}
+	return rdb.ZRem(ctx, deadIndex, id).Err()
+}
@@ -0,0 +1,76 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueDelayed is a sorted set of encoded jobs scored by the unix time at
+// which they become runnable. The worker promotes due entries onto their
+// regular queue.
+const QueueDelayed = "jobs:delayed"
+
+// PromoteBatch caps how many due jobs a single promotion pass moves.
+const PromoteBatch = 100
+
+// promoteScript atomically moves due jobs from the delayed set to the queue
+// their type routes to, so a crash never loses or duplicates a promotion.
+// KEYS: delayed set, interactive queue, bulk queue. ARGV: now, limit, bulk types...
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+local bulk = {}
+for i = 3, #ARGV do bulk[ARGV[i]] = true end
+for _, m in ipairs(due) do
+  redis.call('ZREM', KEYS[1], m)
+  local q = KEYS[2]
+  local ok, j = pcall(cjson.decode, m)
+  if ok and type(j) == 'table' and bulk[j['type']] then q = KEYS[3] end
+  redis.call('RPUSH', q, m)
+end
+return #due
+`)
+
+// Enqueue encodes a job and pushes it onto the queue for its type.
+func Enqueue(ctx context.Context, rdb redis.Cmdable, id, typ string, data any) error {
+	b, err := EncodeContext(ctx, id, typ, data)
+	if err != nil {
+		return err
+	}
+	return rdb.RPush(ctx, QueueFor(typ), b).Err()
+}
+
+// Schedule enqueues a job to run at runAt. Jobs due now or in the past are
+// pushed straight onto their queue. Give each scheduled job a distinct id:
+// identical encoded jobs share one slot in the delayed set.
+func Schedule(ctx context.Context, rdb redis.Cmdable, runAt time.Time, id, typ string, data any) error {
+	if !runAt.After(time.Now()) {
+		return Enqueue(ctx, rdb, id, typ, data)
+	}
+	j, err := New(id, typ, data)
+	if err != nil {
+		return err
+	}
+	// Queue wait starts once the job is due, not when it was scheduled.
+	j.EnqueuedAt = runAt.UTC()
+	j.TraceID = TraceID(ctx)
+	b, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return rdb.ZAdd(ctx, QueueDelayed, redis.Z{Score: float64(runAt.Unix()), Member: b}).Err()
+}
+
+// PromoteDue moves up to one batch of jobs whose run_at has passed onto their
+// queues and reports how many were moved.
+func PromoteDue(ctx context.Context, rdb redis.Scripter, now time.Time) (int, error) {
+	args := []any{strconv.FormatInt(now.Unix(), 10), PromoteBatch}
+	for typ := range bulkTypes {
+		args = append(args, typ)
+	}
+	n, err := promoteScript.Run(ctx, rdb, []string{QueueDelayed, Queue, QueueBulk}, args...).Int()
+	return n, err
+}
@@ -0,0 +1,52 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestScheduleAndPromote(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+	now := time.Now()
+
+	if err := Schedule(ctx, rdb, now.Add(-time.Second), "a", TypeSendEmail, Email{To: "a@example.com"}); err != nil {
+		t.Fatalf("schedule past: %v", err)
+	}
+	if items, _ := mr.List(Queue); len(items) != 1 {
+		t.Fatalf("expected past job enqueued immediately, got %d", len(items))
+	}
+
+	if err := Schedule(ctx, rdb, now.Add(time.Hour), "b", TypeSendEmail, Email{To: "b@example.com"}); err != nil {
+		t.Fatalf("schedule email: %v", err)
+	}
+	if err := Schedule(ctx, rdb, now.Add(time.Hour), "c", TypeExportTickets, ExportTickets{IDs: []string{"1"}}); err != nil {
+		t.Fatalf("schedule export: %v", err)
+	}
+
+	if n, err := PromoteDue(ctx, rdb, now); err != nil || n != 0 {
+		t.Fatalf("expected nothing due, got %d (%v)", n, err)
+	}
+	n, err := PromoteDue(ctx, rdb, now.Add(2*time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("expected two promoted, got %d (%v)", n, err)
+	}
+	if items, _ := mr.List(Queue); len(items) != 2 {
+		t.Fatalf("expected email promoted to %s, got %d", Queue, len(items))
+	}
+	items, _ := mr.List(QueueBulk)
+	if len(items) != 1 {
+		t.Fatalf("expected export promoted to %s, got %d", QueueBulk, len(items))
+	}
+	if j, err := Decode([]byte(items[0])); err != nil || j.ID != "c" || !j.EnqueuedAt.After(now) {
+		t.Fatalf("unexpected promoted job %+v: %v", j, err)
+	}
+	if rdb.ZCard(ctx, QueueDelayed).Val() != 0 {
+		t.Fatalf("expected delayed set drained")
+	}
+}
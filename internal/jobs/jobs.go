@@ -0,0 +1,351 @@
+// Package jobs defines the envelope and payloads exchanged between the API
+// and the worker over the Redis "jobs" queue.
+//
+// Every envelope carries a per-type schema version. Producers always write the
+// current version; consumers decode with Decode, which upgrades older payloads
+// step by step so jobs enqueued before a deploy keep working after it.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Queue is the Redis list consumed by the worker.
+const Queue = "jobs"
+
+// Job types understood by the worker.
+const (
+	TypeSendEmail              = "send_email"
+	TypeDiscordOutgoingComment = "discord_outgoing_comment"
+	TypeExportTickets          = "export_tickets"
+	TypeAuditExport            = "audit_export"
+	TypeResizeAvatar           = "resize_avatar"
+	TypeReconcileAttachments   = "reconcile_attachments"
+	TypeTicketArchive          = "ticket_archive"
+	TypeTicketBroadcast        = "ticket_broadcast"
+	TypeChannelNotify          = "channel_notify"
+	TypeSearchReindex          = "search_reindex"
+	TypeWarrantyLookup         = "warranty_lookup"
+	TypeWebhookDispatch        = "webhook_dispatch"
+	TypeWebhookDeliver         = "webhook_deliver"
+	TypeScheduledTask          = "scheduled_task"
+)
+
+// Job is the queue envelope. Version is omitted by producers that predate
+// versioning; Decode treats a missing version as 1.
+//
+// CreatedAt is when the work was first requested and survives retries;
+// EnqueuedAt is when this attempt was queued, or became due for a scheduled
+// job. The worker measures queue wait and end-to-end latency from them;
+// envelopes from older producers carry neither. TraceID, when set, is
+// attached to those measurements as an exemplar. Attempt counts the
+// attempts from 1 and is omitted on the first.
+type Job struct {
+	ID         string          `json:"id,omitempty"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version,omitempty"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	CreatedAt  time.Time       `json:"created_at,omitzero"`
+	EnqueuedAt time.Time       `json:"enqueued_at,omitzero"`
+	TraceID    string          `json:"trace_id,omitempty"`
+	Attempt    int             `json:"attempt,omitempty"`
+}
+
+// Attempts reports which attempt j is, counting from 1.
+func (j Job) Attempts() int {
+	return max(j.Attempt, 1)
+}
+
+// Email is the send_email payload.
+type Email struct {
+	To       string  `json:"to"`
+	Template string  `json:"template"`
+	Data     any     `json:"data"`
+	TicketID *string `json:"ticket_id,omitempty"`
+	Retries  int     `json:"retries,omitempty"`
+	// Receipt is the URL of a tracking pixel; when set the email is sent
+	// with an HTML part embedding it.
+	Receipt string `json:"receipt,omitempty"`
+	// Subject and Body carry an already rendered message, sent as is when
+	// Template is empty. Resends of logged emails use them.
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body,omitempty"`
+	// ResentFrom is the email_outbound row a resend repeats.
+	ResentFrom *string `json:"resent_from,omitempty"`
+}
+
+// DiscordComment is the discord_outgoing_comment payload.
+type DiscordComment struct {
+	TicketID string `json:"ticket_id"`
+	BodyMD   string `json:"body_md"`
+}
+
+// ExportTickets is the export_tickets payload. Version 2 added Requester so
+// the worker can record who may download the result; version 3 added the
+// comment and attachment options, which turn the export into a zip.
+type ExportTickets struct {
+	IDs       []string `json:"ids"`
+	Requester string   `json:"requester,omitempty"`
+	// Comments is "", ExportCommentsFlattened or ExportCommentsFile.
+	Comments string `json:"comments,omitempty"`
+	// Attachments is "", ExportAttachmentsManifest or ExportAttachmentsBundle.
+	Attachments string `json:"attachments,omitempty"`
+}
+
+// Comment and attachment options of export_tickets.
+const (
+	// ExportCommentsFlattened adds each ticket's thread as a column of
+	// tickets.csv.
+	ExportCommentsFlattened = "flattened"
+	// ExportCommentsFile writes the comments to comments.csv.
+	ExportCommentsFile = "file"
+	// ExportAttachmentsManifest lists attachments in attachments.csv with
+	// presigned download links where the store supports them.
+	ExportAttachmentsManifest = "manifest"
+	// ExportAttachmentsBundle also packs the attachment files into the zip.
+	ExportAttachmentsBundle = "bundle"
+)
+
+// ResizeAvatar is the resize_avatar payload. Key is the original upload in
+// Bucket; the worker replaces it with a resized copy.
+type ResizeAvatar struct {
+	UserID string `json:"user_id"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// ReconcileAttachments is the reconcile_attachments payload. RunID is the
+// job_runs row the API created when the run was requested; Repair lets the
+// run correct attachment metadata from the object store.
+type ReconcileAttachments struct {
+	RunID  string `json:"run_id"`
+	Repair bool   `json:"repair,omitempty"`
+}
+
+// TicketArchive is the ticket_archive payload. Internal includes internal
+// comments and attachments, for staff requesters.
+type TicketArchive struct {
+	TicketID  string `json:"ticket_id"`
+	Requester string `json:"requester"`
+	Internal  bool   `json:"internal,omitempty"`
+}
+
+// TicketBroadcast is the ticket_broadcast payload. The broadcast itself is
+// the ticket_broadcasts row; the rest carries the API's settings for the
+// requester emails, which the worker does not have.
+type TicketBroadcast struct {
+	BroadcastID      string `json:"broadcast_id"`
+	PublicURL        string `json:"public_url,omitempty"`
+	CSATThrottleDays int    `json:"csat_throttle_days,omitempty"`
+	ReceiptBase      string `json:"receipt_base,omitempty"`
+}
+
+// ChannelNotify is the channel_notify payload: one notification for one
+// notification_channels row. The worker picks the provider by the channel's
+// kind and requeues failed sends up to three times.
+type ChannelNotify struct {
+	ChannelID string `json:"channel_id"`
+	Event     string `json:"event"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	URL       string `json:"url,omitempty"`
+	TicketID  string `json:"ticket_id,omitempty"`
+	Urgent    bool   `json:"urgent,omitempty"`
+	Retries   int    `json:"retries,omitempty"`
+}
+
+// SearchReindex is the search_reindex payload. RunID is the job_runs row
+// the API created; Mode is SearchReindexFull or SearchReindexIncremental.
+type SearchReindex struct {
+	RunID string `json:"run_id"`
+	Mode  string `json:"mode"`
+}
+
+// Modes of search_reindex.
+const (
+	// SearchReindexFull rebuilds every search index.
+	SearchReindexFull = "full"
+	// SearchReindexIncremental only rebuilds indexes that are missing or
+	// were left invalid, e.g. by an interrupted rebuild or a restore.
+	SearchReindexIncremental = "incremental"
+)
+
+// WarrantyLookup is the warranty_lookup payload. The worker fetches the
+// asset's warranty from its manufacturer and requeues failed lookups up to
+// three times.
+type WarrantyLookup struct {
+	AssetID string `json:"asset_id"`
+	Retries int    `json:"retries,omitempty"`
+}
+
+// WebhookDispatch is the webhook_dispatch payload: an event the worker
+// renders once and fans out as a webhook_deliver job per active
+// subscription selecting it. Data is merged into the rendered payload's
+// data object.
+type WebhookDispatch struct {
+	ID         string         `json:"id"`
+	Event      string         `json:"event"`
+	TicketID   string         `json:"ticket_id,omitempty"`
+	Data       map[string]any `json:"data,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// WebhookDeliver is the webhook_deliver payload: one attempt at posting a
+// rendered payload to one subscription. RetryOf is the webhook_deliveries
+// row of the failed attempt this one repeats.
+type WebhookDeliver struct {
+	WebhookID string          `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempt   int             `json:"attempt"`
+	RetryOf   string          `json:"retry_of,omitempty"`
+}
+
+// ScheduledTask is the scheduled_task payload: a run of the worker's
+// recurring task named by a job_schedules row.
+type ScheduledTask struct {
+	Name string `json:"name"`
+}
+
+// Upgrader converts a payload from one version to the next.
+type Upgrader func(data json.RawMessage) (json.RawMessage, error)
+
+// versions holds the current schema version per job type.
+var versions = map[string]int{
+	TypeSendEmail:              1,
+	TypeDiscordOutgoingComment: 1,
+	TypeExportTickets:          3,
+	TypeAuditExport:            1,
+	TypeResizeAvatar:           1,
+	TypeReconcileAttachments:   1,
+	TypeTicketArchive:          1,
+	TypeTicketBroadcast:        1,
+	TypeChannelNotify:          1,
+	TypeSearchReindex:          1,
+	TypeWarrantyLookup:         1,
+	TypeWebhookDispatch:        1,
+	TypeWebhookDeliver:         1,
+	TypeScheduledTask:          1,
+}
+
+// upgraders maps a job type and source version to the function producing the
+// next version. A chain must exist from 1 to the current version.
+var upgraders = map[string]map[int]Upgrader{
+	TypeExportTickets: {
+		// v1 payloads carry only ids; the requester is unknown and left empty.
+		1: func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+		// v2 payloads carry no options and export a plain CSV as before.
+		2: func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+	},
+}
+
+// ErrUnsupportedVersion is returned for payloads written by a newer producer
+// than this binary understands.
+var ErrUnsupportedVersion = errors.New("unsupported job version")
+
+// CurrentVersion reports the schema version producers should write for typ.
+// Unknown types are version 1.
+func CurrentVersion(typ string) int {
+	if v, ok := versions[typ]; ok {
+		return v
+	}
+	return 1
+}
+
+// New builds an envelope for typ at its current version, stamped as created
+// and enqueued now.
+func New(id, typ string, data any) (Job, error) {
+	now := time.Now().UTC()
+	j := Job{ID: id, Type: typ, Version: CurrentVersion(typ), CreatedAt: now, EnqueuedAt: now}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return Job{}, fmt.Errorf("marshal %s payload: %w", typ, err)
+		}
+		j.Data = b
+	}
+	return j, nil
+}
+
+// Encode builds and marshals an envelope ready to push onto the queue.
+func Encode(id, typ string, data any) ([]byte, error) {
+	j, err := New(id, typ, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// EncodeContext is Encode with the envelope's TraceID taken from ctx.
+func EncodeContext(ctx context.Context, id, typ string, data any) ([]byte, error) {
+	j, err := New(id, typ, data)
+	if err != nil {
+		return nil, err
+	}
+	j.TraceID = TraceID(ctx)
+	return json.Marshal(j)
+}
+
+// Retry encodes a new attempt of j carrying data, usually j's payload with
+// its retry count bumped. The attempt keeps j's CreatedAt and TraceID so
+// end-to-end latency covers every attempt.
+func Retry(j Job, data any) ([]byte, error) {
+	next, err := New("", j.Type, data)
+	if err != nil {
+		return nil, err
+	}
+	if !j.CreatedAt.IsZero() {
+		next.CreatedAt = j.CreatedAt
+	}
+	next.TraceID = j.TraceID
+	next.Attempt = j.Attempts() + 1
+	return json.Marshal(next)
+}
+
+type traceKey struct{}
+
+// WithTraceID returns a context whose jobs are stamped with id. The API sets
+// it to the request ID so a slow job's exemplar leads back to the request's
+// logs.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceKey{}, id)
+}
+
+// TraceID returns the trace ID set by WithTraceID, or "".
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceKey{}).(string)
+	return id
+}
+
+// Decode parses an envelope and upgrades its payload to the current version.
+// Unknown fields are ignored so additive changes never need an upgrader.
+func Decode(raw []byte) (Job, error) {
+	var j Job
+	if err := json.Unmarshal(raw, &j); err != nil {
+		return Job{}, err
+	}
+	if j.Version == 0 {
+		j.Version = 1
+	}
+	cur := CurrentVersion(j.Type)
+	if j.Version > cur {
+		return j, fmt.Errorf("%w: %s v%d (max v%d)", ErrUnsupportedVersion, j.Type, j.Version, cur)
+	}
+	for j.Version < cur {
+		up, ok := upgraders[j.Type][j.Version]
+		if !ok {
+			return j, fmt.Errorf("no upgrader for %s v%d", j.Type, j.Version)
+		}
+		data, err := up(j.Data)
+		if err != nil {
+			return j, fmt.Errorf("upgrade %s v%d: %w", j.Type, j.Version, err)
+		}
+		j.Data = data
+		j.Version++
+	}
+	return j, nil
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeLegacyUnversioned(t *testing.T) {
+	raw := []byte(`{"type":"send_email","data":{"to":"a@example.com","template":"ticket_created","data":{"Number":"1"}}}`)
+	j, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if j.Version != 1 {
+		t.Fatalf("expected version 1, got %d", j.Version)
+	}
+	var e Email
+	if err := json.Unmarshal(j.Data, &e); err != nil || e.To != "a@example.com" {
+		t.Fatalf("unexpected payload %+v: %v", e, err)
+	}
+}
+
+func TestDecodeUpgradesExportTickets(t *testing.T) {
+	raw := []byte(`{"id":"job1","type":"export_tickets","data":{"ids":["t1","t2"]}}`)
+	j, err := Decode(raw)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if j.Version != CurrentVersion(TypeExportTickets) || j.ID != "job1" {
+		t.Fatalf("unexpected envelope %+v", j)
+	}
+	var e ExportTickets
+	if err := json.Unmarshal(j.Data, &e); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(e.IDs) != 2 || e.Requester != "" {
+		t.Fatalf("unexpected payload %+v", e)
+	}
+}
+
+func TestDecodeRejectsNewerVersion(t *testing.T) {
+	raw := []byte(`{"type":"send_email","version":99,"data":{}}`)
+	if _, err := Decode(raw); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for typ := range versions {
+		b, err := Encode("id", typ, map[string]any{"k": "v"})
+		if err != nil {
+			t.Fatalf("encode %s: %v", typ, err)
+		}
+		j, err := Decode(b)
+		if err != nil {
+			t.Fatalf("decode %s: %v", typ, err)
+		}
+		if j.Type != typ || j.Version != CurrentVersion(typ) {
+			t.Fatalf("unexpected envelope %+v", j)
+		}
+	}
+}
+
+func TestUpgradeChainsComplete(t *testing.T) {
+	for typ, cur := range versions {
+		for v := 1; v < cur; v++ {
+			if _, ok := upgraders[typ][v]; !ok {
+				t.Errorf("%s: missing upgrader from v%d", typ, v)
+			}
+		}
+	}
+}
+
+func TestRetryKeepsOrigin(t *testing.T) {
+	ctx := WithTraceID(context.Background(), "req-1")
+	b, err := EncodeContext(ctx, "job1", TypeSendEmail, Email{To: "a@example.com"})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	first, _ := Decode(b)
+	if first.TraceID != "req-1" || first.CreatedAt.IsZero() || !first.EnqueuedAt.Equal(first.CreatedAt) {
+		t.Fatalf("unexpected envelope %+v", first)
+	}
+	first.CreatedAt = first.CreatedAt.Add(-time.Hour)
+	b, err = Retry(first, Email{To: "a@example.com", Retries: 1})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	next, _ := Decode(b)
+	if !next.CreatedAt.Equal(first.CreatedAt) || !next.EnqueuedAt.After(next.CreatedAt) || next.TraceID != "req-1" || next.ID != "" {
+		t.Fatalf("unexpected retry envelope %+v", next)
+	}
+}
@@ -0,0 +1,268 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs being worked on are leased rather than popped: Reserve moves a job
+// from its queue into QueueLeases under a fresh token, with the token's
+// visibility deadline in QueueProcessing. The worker settles the lease when
+// the job is done and extends it while the job runs. A lease whose deadline
+// passes, because its worker died, is put back on its queue by
+// RequeueExpired, so no job is lost mid-processing.
+const (
+	// QueueProcessing is a sorted set of lease tokens scored by their
+	// visibility deadline in unix milliseconds.
+	QueueProcessing = "jobs:processing"
+	// QueueLeases maps lease tokens to the encoded jobs they hold.
+	QueueLeases = "jobs:leases"
+	// redeliveries counts how often an encoded job's lease has expired.
+	redeliveries = "jobs:redeliveries"
+)
+
+// DefaultVisibility is how long a lease lasts before it is extended.
+const DefaultVisibility = 2 * time.Minute
+
+// MaxRedeliveries is how often a job may be recovered from an expired
+// lease. A job that keeps taking its worker down with it is dead-lettered
+// after that.
+const MaxRedeliveries = 3
+
+// Lease is a reserved job.
+type Lease struct {
+	Token string
+	// Queue is the queue the job was reserved from.
+	Queue string
+	Raw   []byte
+}
+
+// reserveScript pops the first job from the queues in KEYS[3:] and leases
+// it. KEYS: processing set, leases hash, queues... ARGV: token, deadline.
+var reserveScript = redis.NewScript(`
+for i = 3, #KEYS do
+  local m = redis.call('LPOP', KEYS[i])
+  if m then
+    redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+    redis.call('HSET', KEYS[2], ARGV[1], m)
+    return {KEYS[i], m}
+  end
+end
+return false
+`)
+
+// Reserve leases the first job found on queues, checked in order, for
+// visibility. It returns nil when every queue is empty.
+func Reserve(ctx context.Context, rdb redis.Cmdable, queues []string, visibility time.Duration) (*Lease, error) {
+	token := uuid.NewString()
+	keys := append([]string{QueueProcessing, QueueLeases}, queues...)
+	res, err := reserveScript.Run(ctx, rdb, keys, token, deadline(time.Now(), visibility)).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{Token: token, Queue: res[0], Raw: []byte(res[1])}, nil
+}
+
+// Extend pushes l's deadline to visibility from now. It reports false when
+// the lease has already expired and its job been requeued.
+func Extend(ctx context.Context, rdb redis.Cmdable, l *Lease, visibility time.Duration) (bool, error) {
+	n, err := rdb.ZAddArgs(ctx, QueueProcessing, redis.ZAddArgs{
+		XX:      true,
+		Ch:      true,
+		Members: []redis.Z{{Score: float64(deadline(time.Now(), visibility)), Member: l.Token}},
+	}).Result()
+	return n > 0, err
+}
+
+// Ack removes a finished job's lease.
+func Ack(ctx context.Context, rdb redis.Cmdable, l *Lease) error {
+	_, err := rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		release(ctx, p, l)
+		return nil
+	})
+	return err
+}
+
+// Release returns l's job to the tail of its queue unchanged, for a worker
+// that cannot run it, such as one older than the job's producer.
+func Release(ctx context.Context, rdb redis.Cmdable, l *Lease) error {
+	_, err := rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		release(ctx, p, l)
+		p.RPush(ctx, l.Queue, l.Raw)
+		return nil
+	})
+	return err
+}
+
+func release(ctx context.Context, p redis.Pipeliner, l *Lease) {
+	p.ZRem(ctx, QueueProcessing, l.Token)
+	p.HDel(ctx, QueueLeases, l.Token)
+	p.HDel(ctx, redeliveries, string(l.Raw))
+}
+
+// Outcomes of Settle.
+const (
+	Acked        = "acked"
+	Retried      = "retried"
+	DeadLettered = "dead_lettered"
+)
+
+// Settle ends l once its job j has run with runErr. Successes and
+// Rescheduled failures are acked. Other failures are retried after the
+// type's backoff while attempts remain, unless Permanent, and
+// dead-lettered otherwise. It returns the outcome.
+func Settle(ctx context.Context, rdb redis.Cmdable, l *Lease, j Job, runErr error) (string, error) {
+	if runErr == nil || IsRescheduled(runErr) {
+		return Acked, Ack(ctx, rdb, l)
+	}
+	p := PolicyFor(j.Type)
+	if IsPermanent(runErr) || j.Attempts() >= p.MaxAttempts {
+		return DeadLettered, Bury(ctx, rdb, l, runErr.Error())
+	}
+	runAt := time.Now().Add(p.Delay(j.Attempts()))
+	next := j
+	next.Attempt = j.Attempts() + 1
+	next.EnqueuedAt = runAt.UTC()
+	b, err := json.Marshal(next)
+	if err != nil {
+		return "", err
+	}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		release(ctx, pipe, l)
+		pipe.ZAdd(ctx, QueueDelayed, redis.Z{Score: float64(runAt.Unix()), Member: b})
+		return nil
+	})
+	return Retried, err
+}
+
+// requeueScript recovers expired leases: their jobs go back to the tail
+// of the queue their type routes to, or to the dead-letter queue once
+// recovered MaxRedeliveries times.
+// KEYS: processing set, leases hash, redeliveries hash, dead hash, dead
+// index, interactive queue, bulk queue.
+// ARGV: now, limit, max redeliveries, failed_at, dead cap, bulk types...
+var requeueScript = redis.NewScript(`
+local bulk = {}
+for i = 6, #ARGV do bulk[ARGV[i]] = true end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, tonumber(ARGV[2]))
+local requeued, buried = 0, 0
+for _, token in ipairs(expired) do
+  redis.call('ZREM', KEYS[1], token)
+  local m = redis.call('HGET', KEYS[2], token)
+  redis.call('HDEL', KEYS[2], token)
+  if m then
+    local q = KEYS[6]
+    local ok, j = pcall(cjson.decode, m)
+    if ok and type(j) == 'table' and bulk[j['type']] then q = KEYS[7] end
+    if redis.call('HINCRBY', KEYS[3], m, 1) > tonumber(ARGV[3]) then
+      redis.call('HDEL', KEYS[3], m)
+      redis.call('HSET', KEYS[4], token, cjson.encode({id = token, queue = q, job = m, error = 'lease expired ' .. ARGV[3] .. ' times', failed_at = ARGV[4]}))
+      redis.call('ZADD', KEYS[5], ARGV[1], token)
+      buried = buried + 1
+    else
+      redis.call('RPUSH', q, m)
+      requeued = requeued + 1
+    end
+  end
+end
+` + trimDead + `
+return {requeued, buried}
+`)
+
+// RequeueExpired recovers up to one batch of leases whose deadline passed
+// before now and reports how many jobs were requeued and dead-lettered.
+func RequeueExpired(ctx context.Context, rdb redis.Cmdable, now time.Time) (requeued, buried int, err error) {
+	args := []any{now.UnixMilli(), PromoteBatch, MaxRedeliveries, now.UTC().Format(time.RFC3339Nano), DeadCap}
+	for typ := range bulkTypes {
+		args = append(args, typ)
+	}
+	keys := []string{QueueProcessing, QueueLeases, redeliveries, QueueDead, deadIndex, Queue, QueueBulk}
+	res, err := requeueScript.Run(ctx, rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(res[0]), int(res[1]), nil
+}
+
+// InFlight is a leased job.
+type InFlight struct {
+	Lease    string    `json:"lease"`
+	Type     string    `json:"type"`
+	JobID    string    `json:"job_id,omitempty"`
+	Attempt  int       `json:"attempt"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// QueueStats is a queue's backlog.
+type QueueStats struct {
+	Queue   string `json:"queue"`
+	Pending int64  `json:"pending"`
+}
+
+// Stats is a snapshot of the queues.
+type Stats struct {
+	Queues   []QueueStats `json:"queues"`
+	Delayed  int64        `json:"delayed"`
+	Leased   int64        `json:"leased"`
+	Dead     int64        `json:"dead"`
+	InFlight []InFlight   `json:"in_flight"`
+}
+
+// Inspect reports the queues' depths and up to limit leased jobs, those
+// due to expire first.
+func Inspect(ctx context.Context, rdb redis.Cmdable, limit int) (Stats, error) {
+	var s Stats
+	pipe := rdb.Pipeline()
+	queues := Queues()
+	depths := make([]*redis.IntCmd, len(queues))
+	for i, q := range queues {
+		depths[i] = pipe.LLen(ctx, q)
+	}
+	delayed := pipe.ZCard(ctx, QueueDelayed)
+	leased := pipe.ZCard(ctx, QueueProcessing)
+	dead := pipe.ZCard(ctx, deadIndex)
+	leases := pipe.ZRangeWithScores(ctx, QueueProcessing, 0, int64(limit)-1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return s, err
+	}
+	for i, q := range queues {
+		s.Queues = append(s.Queues, QueueStats{Queue: q, Pending: depths[i].Val()})
+	}
+	s.Delayed, s.Leased, s.Dead = delayed.Val(), leased.Val(), dead.Val()
+	s.InFlight = []InFlight{}
+	zs := leases.Val()
+	if len(zs) == 0 {
+		return s, nil
+	}
+	tokens := make([]string, len(zs))
+	for i, z := range zs {
+		tokens[i] = z.Member.(string)
+	}
+	raws, err := rdb.HMGet(ctx, QueueLeases, tokens...).Result()
+	if err != nil {
+		return s, err
+	}
+	for i, z := range zs {
+		f := InFlight{Lease: tokens[i], Deadline: time.UnixMilli(int64(z.Score)).UTC()}
+		if raw, ok := raws[i].(string); ok {
+			var j Job
+			if json.Unmarshal([]byte(raw), &j) == nil {
+				f.Type, f.JobID, f.Attempt = j.Type, j.ID, j.Attempts()
+			}
+		}
+		s.InFlight = append(s.InFlight, f)
+	}
+	return s, nil
+}
+
+func deadline(now time.Time, visibility time.Duration) int64 {
+	return now.Add(visibility).UnixMilli()
+}
@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLeaseSettle(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	if l, err := Reserve(ctx, rdb, Queues(), time.Minute); err != nil || l != nil {
+		t.Fatalf("expected nothing to reserve, got %+v (%v)", l, err)
+	}
+	if err := Enqueue(ctx, rdb, "e1", TypeSendEmail, Email{To: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Reserve(ctx, rdb, Queues(), time.Minute)
+	if err != nil || l == nil || l.Queue != Queue {
+		t.Fatalf("reserve: %+v (%v)", l, err)
+	}
+	s, err := Inspect(ctx, rdb, 10)
+	if err != nil || s.Leased != 1 || s.Queues[0].Pending != 0 || len(s.InFlight) != 1 || s.InFlight[0].JobID != "e1" || s.InFlight[0].Attempt != 1 {
+		t.Fatalf("stats = %+v (%v)", s, err)
+	}
+	if ok, err := Extend(ctx, rdb, l, time.Hour); err != nil || !ok {
+		t.Fatalf("extend: %v %v", ok, err)
+	}
+
+	// A failure with attempts left goes to the delayed set as the next attempt.
+	j, _ := Decode(l.Raw)
+	if out, err := Settle(ctx, rdb, l, j, errors.New("smtp down")); err != nil || out != Retried {
+		t.Fatalf("settle: %s (%v)", out, err)
+	}
+	if rdb.ZCard(ctx, QueueProcessing).Val() != 0 || rdb.HLen(ctx, QueueLeases).Val() != 0 {
+		t.Fatal("lease left behind")
+	}
+	if _, err := PromoteDue(ctx, rdb, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	l, _ = Reserve(ctx, rdb, Queues(), time.Minute)
+	j, _ = Decode(l.Raw)
+	if j.Attempts() != 2 || j.ID != "e1" {
+		t.Fatalf("retry = %+v", j)
+	}
+
+	// Permanent failures are dead-lettered at once.
+	if out, err := Settle(ctx, rdb, l, j, Permanent(errors.New("invalid To address"))); err != nil || out != DeadLettered {
+		t.Fatalf("settle: %s (%v)", out, err)
+	}
+	dead, total, err := ListDead(ctx, rdb, 0, 10)
+	if err != nil || total != 1 || dead[0].ID != l.Token || dead[0].Type != TypeSendEmail || dead[0].Attempt != 2 || dead[0].Error != "invalid To address" {
+		t.Fatalf("dead = %+v (%v)", dead, err)
+	}
+	if err := RetryDead(ctx, rdb, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RetryDead(ctx, rdb, dead[0].ID); !errors.Is(err, ErrNotDead) {
+		t.Fatalf("second retry: %v", err)
+	}
+	items, _ := mr.List(Queue)
+	if j, _ := Decode([]byte(items[0])); len(items) != 1 || j.Attempts() != 1 || j.ID != "e1" {
+		t.Fatalf("requeued = %v", items)
+	}
+
+	// Handlers that rescheduled their own retry are only acked.
+	l, _ = Reserve(ctx, rdb, Queues(), time.Minute)
+	if out, _ := Settle(ctx, rdb, l, j, Rescheduled(errors.New("later"))); out != Acked || rdb.ZCard(ctx, deadIndex).Val() != 0 {
+		t.Fatalf("rescheduled settled as %s", out)
+	}
+}
+
+func TestRequeueExpired(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	if err := Enqueue(ctx, rdb, "x1", TypeExportTickets, ExportTickets{IDs: []string{"1"}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= MaxRedeliveries; i++ {
+		l, err := Reserve(ctx, rdb, Queues(), time.Minute)
+		if err != nil || l == nil || l.Queue != QueueBulk {
+			t.Fatalf("delivery %d: %+v (%v)", i, l, err)
+		}
+		// The worker died: nothing is recovered before the deadline.
+		if n, _, _ := RequeueExpired(ctx, rdb, time.Now()); n != 0 {
+			t.Fatal("lease recovered early")
+		}
+		if n, buried, err := RequeueExpired(ctx, rdb, time.Now().Add(2*time.Minute)); err != nil || n != 1 || buried != 0 {
+			t.Fatalf("recover %d: %d, %d (%v)", i, n, buried, err)
+		}
+		if ok, _ := Extend(ctx, rdb, l, time.Minute); ok {
+			t.Fatal("extended a recovered lease")
+		}
+	}
+	if _, err := Reserve(ctx, rdb, Queues(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if n, buried, err := RequeueExpired(ctx, rdb, time.Now().Add(2*time.Minute)); err != nil || n != 0 || buried != 1 {
+		t.Fatalf("expected the job dead-lettered, got %d, %d (%v)", n, buried, err)
+	}
+	dead, _, err := ListDead(ctx, rdb, 0, 10)
+	if err != nil || len(dead) != 1 || dead[0].JobID != "x1" || dead[0].Queue != QueueBulk || dead[0].FailedAt.IsZero() {
+		t.Fatalf("dead = %+v (%v)", dead, err)
+	}
+	if err := DeleteDead(ctx, rdb, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteDead(ctx, rdb, dead[0].ID); !errors.Is(err, ErrNotDead) {
+		t.Fatalf("second delete: %v", err)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, Backoff: time.Minute}
+	if p.Delay(1) != time.Minute || p.Delay(3) != 4*time.Minute || p.Delay(9) != MaxBackoff {
+		t.Fatalf("delays %v %v %v", p.Delay(1), p.Delay(3), p.Delay(9))
+	}
+	if PolicyFor(TypeWebhookDeliver).MaxAttempts != 1 || PolicyFor("unknown") != DefaultRetryPolicy {
+		t.Fatal("unexpected policies")
+	}
+}
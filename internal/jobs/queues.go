@@ -0,0 +1,78 @@
+package jobs
+
+// QueueBulk holds long-running jobs such as exports so they never sit in
+// front of interactive work like notification emails.
+const QueueBulk = "jobs:bulk"
+
+// Tier is a queue and its share of worker pops.
+type Tier struct {
+	Queue  string
+	Weight int
+}
+
+// Tiers lists the queues in priority order. With these weights the worker
+// checks the bulk queue first on one pop in five; otherwise interactive jobs
+// win. Both queues are always polled so an idle tier never blocks the other.
+var Tiers = []Tier{
+	{Queue: Queue, Weight: 4},
+	{Queue: QueueBulk, Weight: 1},
+}
+
+// bulkTypes are routed to QueueBulk; everything else is interactive.
+var bulkTypes = map[string]bool{
+	TypeExportTickets:        true,
+	TypeAuditExport:          true,
+	TypeReconcileAttachments: true,
+	TypeTicketArchive:        true,
+	TypeTicketBroadcast:      true,
+	TypeSearchReindex:        true,
+	TypeWarrantyLookup:       true,
+}
+
+// QueueFor returns the queue a job of typ should be pushed to.
+func QueueFor(typ string) string {
+	if bulkTypes[typ] {
+		return QueueBulk
+	}
+	return Queue
+}
+
+// Queues returns every tier's queue key in priority order.
+func Queues() []string {
+	out := make([]string, len(Tiers))
+	for i, t := range Tiers {
+		out[i] = t.Queue
+	}
+	return out
+}
+
+// Order returns the queue keys to poll for the n-th pop, using weighted
+// round robin: the tier owning slot n leads and the rest follow in priority
+// order as fallbacks.
+func Order(n int) []string {
+	total := 0
+	for _, t := range Tiers {
+		total += t.Weight
+	}
+	keys := Queues()
+	if total <= 0 {
+		return keys
+	}
+	slot := n % total
+	lead := 0
+	for i, t := range Tiers {
+		if slot < t.Weight {
+			lead = i
+			break
+		}
+		slot -= t.Weight
+	}
+	out := make([]string, 0, len(keys))
+	out = append(out, keys[lead])
+	for i, k := range keys {
+		if i != lead {
+			out = append(out, k)
+		}
+	}
+	return out
+}
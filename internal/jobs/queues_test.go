@@ -0,0 +1,26 @@
+package jobs
+
+import "testing"
+
+func TestQueueFor(t *testing.T) {
+	if QueueFor(TypeSendEmail) != Queue || QueueFor(TypeDiscordOutgoingComment) != Queue {
+		t.Fatalf("interactive jobs must use %q", Queue)
+	}
+	if QueueFor(TypeExportTickets) != QueueBulk || QueueFor(TypeAuditExport) != QueueBulk || QueueFor(TypeReconcileAttachments) != QueueBulk {
+		t.Fatalf("bulk jobs must use %q", QueueBulk)
+	}
+}
+
+func TestOrderWeighted(t *testing.T) {
+	leads := map[string]int{}
+	for n := 0; n < 50; n++ {
+		o := Order(n)
+		if len(o) != len(Tiers) {
+			t.Fatalf("order %d: expected all queues, got %v", n, o)
+		}
+		leads[o[0]]++
+	}
+	if leads[Queue] != 40 || leads[QueueBulk] != 10 {
+		t.Fatalf("unexpected lead distribution: %v", leads)
+	}
+}
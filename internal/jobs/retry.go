@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryPolicy is how the worker retries a failed job of one type before
+// dead-lettering it.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 1 never retries.
+	MaxAttempts int
+	// Backoff is the delay before the second attempt. It doubles for each
+	// attempt after that, up to MaxBackoff.
+	Backoff time.Duration
+}
+
+// MaxBackoff caps the delay between attempts.
+const MaxBackoff = time.Hour
+
+// DefaultRetryPolicy applies to types without their own policy.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}
+
+// retryPolicies overrides DefaultRetryPolicy per type.
+var retryPolicies = map[string]RetryPolicy{
+	TypeSendEmail:     {MaxAttempts: 4, Backoff: 30 * time.Second},
+	TypeChannelNotify: {MaxAttempts: 4, Backoff: 30 * time.Second},
+	// Exports and archives report their failure to the requester, and
+	// job_runs runs are rerun from /admin/jobs.
+	TypeExportTickets:        {MaxAttempts: 1},
+	TypeTicketArchive:        {MaxAttempts: 1},
+	TypeAuditExport:          {MaxAttempts: 1},
+	TypeReconcileAttachments: {MaxAttempts: 1},
+	TypeSearchReindex:        {MaxAttempts: 1},
+	// The schedule's next run is the retry.
+	TypeScheduledTask: {MaxAttempts: 1},
+	// A retried broadcast would email requesters twice.
+	TypeTicketBroadcast: {MaxAttempts: 1},
+	// Warranty lookups and webhook deliveries schedule their own retries
+	// and record each attempt.
+	TypeWarrantyLookup: {MaxAttempts: 1},
+	TypeWebhookDeliver: {MaxAttempts: 1},
+}
+
+// PolicyFor returns the retry policy for typ.
+func PolicyFor(typ string) RetryPolicy {
+	if p, ok := retryPolicies[typ]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
+
+// Delay returns how long to wait after attempt failed before the next one.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && d < MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, MaxBackoff)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as a failure retrying cannot fix, such as an invalid
+// address. The job is dead-lettered without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+type rescheduledError struct{ err error }
+
+func (e rescheduledError) Error() string { return e.err.Error() }
+func (e rescheduledError) Unwrap() error { return e.err }
+
+// Rescheduled marks err as a failure whose next attempt the handler has
+// already scheduled. The job is neither retried nor dead-lettered.
+func Rescheduled(err error) error {
+	if err == nil {
+		return nil
+	}
+	return rescheduledError{err}
+}
+
+// IsPermanent reports whether err was marked by Permanent.
+func IsPermanent(err error) bool {
+	return errors.As(err, new(permanentError))
+}
+
+// IsRescheduled reports whether err was marked by Rescheduled.
+func IsRescheduled(err error) bool {
+	return errors.As(err, new(rescheduledError))
+}
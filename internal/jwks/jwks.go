@@ -0,0 +1,162 @@
+// Package jwks keeps the OIDC provider's signing keys for bearer token
+// checks. The key set is refreshed in the background with jittered
+// exponential backoff, keeping the last good set on failures, and the
+// cache records when it last refreshed so readiness checks can tell a
+// healthy cache from one that has silently stopped updating.
+package jwks
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// Refresh intervals: every MinInterval while refreshes succeed, backing off
+// to MaxInterval while they fail.
+const (
+	MinInterval = time.Minute
+	MaxInterval = 30 * time.Minute
+)
+
+// allowedAlgs are the signing algorithms accepted from the provider.
+var allowedAlgs = map[string]bool{"RS256": true, "RS384": true, "RS512": true, "ES256": true, "ES384": true, "ES512": true}
+
+// Status is a snapshot of the cache's health.
+type Status struct {
+	KeyCount    int
+	LastRefresh time.Time
+	LastAttempt time.Time
+	// LastError is the most recent refresh failure, cleared by a success.
+	LastError error
+	// Failures counts refreshes failed since the last success.
+	Failures int
+}
+
+// Cache holds the key set fetched from URL.
+type Cache struct {
+	URL  string
+	HTTP *http.Client
+	// OnRefresh, when set, is called after every refresh attempt with its
+	// error, nil on success.
+	OnRefresh func(err error)
+
+	mu     sync.RWMutex
+	set    jwk.Set
+	status Status
+	now    func() time.Time
+}
+
+// New returns an empty cache for url; call Refresh to load it.
+func New(url string, client *http.Client) *Cache {
+	return &Cache{URL: url, HTTP: client, now: time.Now}
+}
+
+// Refresh fetches the key set. A failed fetch, or one returning no keys,
+// keeps the previous set.
+func (c *Cache) Refresh(ctx context.Context) error {
+	set, err := jwk.Fetch(ctx, c.URL, jwk.WithHTTPClient(c.HTTP))
+	if err == nil && set.Len() == 0 {
+		err = errors.New("jwks: key set is empty")
+	}
+	c.record(set, err)
+	if c.OnRefresh != nil {
+		c.OnRefresh(err)
+	}
+	return err
+}
+
+// record stores the outcome of a refresh at the current time.
+func (c *Cache) record(set jwk.Set, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.status.LastAttempt = now
+	if err != nil {
+		c.status.LastError = err
+		c.status.Failures++
+		return
+	}
+	c.set = set
+	c.status.KeyCount = set.Len()
+	c.status.LastRefresh = now
+	c.status.LastError = nil
+	c.status.Failures = 0
+}
+
+// Run refreshes the cache until ctx is done, waiting MinInterval plus up to
+// half again as jitter between refreshes and doubling the wait, up to
+// MaxInterval, after each failure.
+func (c *Cache) Run(ctx context.Context) {
+	delay := MinInterval
+	for {
+		jitter, _ := crand.Int(crand.Reader, big.NewInt(int64(delay/2)+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay + time.Duration(jitter.Int64())):
+		}
+		if err := c.Refresh(ctx); err != nil {
+			delay = min(delay*2, MaxInterval)
+		} else {
+			delay = MinInterval
+		}
+	}
+}
+
+// Status returns the cache's current health.
+func (c *Cache) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status
+}
+
+// Stale reports whether the last successful refresh is older than maxAge,
+// or never happened. A zero maxAge only requires a first refresh.
+func (c *Cache) Stale(maxAge time.Duration) bool {
+	st := c.Status()
+	if st.LastRefresh.IsZero() {
+		return true
+	}
+	return maxAge > 0 && c.now().Sub(st.LastRefresh) > maxAge
+}
+
+// Keyfunc resolves a token's verification key: the key named by its kid
+// header, or the set's first key for tokens without one.
+func (c *Cache) Keyfunc(t *jwt.Token) (interface{}, error) {
+	if !allowedAlgs[t.Method.Alg()] {
+		return nil, fmt.Errorf("invalid alg: %s", t.Method.Alg())
+	}
+	c.mu.RLock()
+	set := c.set
+	c.mu.RUnlock()
+	if set == nil {
+		return nil, fmt.Errorf("no jwk available")
+	}
+	var key jwk.Key
+	if kid, _ := t.Header["kid"].(string); kid != "" {
+		k, ok := set.LookupKeyID(kid)
+		if !ok {
+			return nil, fmt.Errorf("no jwk for kid: %s", kid)
+		}
+		key = k
+	} else {
+		k, ok := set.Key(0)
+		if !ok {
+			return nil, fmt.Errorf("no jwk available")
+		}
+		key = k
+	}
+	var pub any
+	if err := key.Raw(&pub); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
@@ -0,0 +1,97 @@
+package jwks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+func TestRefreshAndStale(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = key.Set(jwk.KeyIDKey, "k1")
+	set := jwk.NewSet()
+	_ = set.AddKey(key)
+	body, _ := json.Marshal(set)
+
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if fail {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(body)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := New(srv.URL, srv.Client())
+	c.now = func() time.Time { return now }
+	var refreshes, errs int
+	c.OnRefresh = func(err error) {
+		refreshes++
+		if err != nil {
+			errs++
+		}
+	}
+	if !c.Stale(time.Hour) {
+		t.Fatal("a cache that never refreshed should be stale")
+	}
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st := c.Status(); st.KeyCount != 1 || !st.LastRefresh.Equal(now) || st.Failures != 0 {
+		t.Fatalf("status after refresh = %+v", st)
+	}
+
+	fail = true
+	now = now.Add(45 * time.Minute)
+	if err := c.Refresh(context.Background()); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	st := c.Status()
+	if st.KeyCount != 1 || st.Failures != 1 || st.LastError == nil || !st.LastAttempt.Equal(now) {
+		t.Fatalf("status after failure = %+v", st)
+	}
+	if c.Stale(time.Hour) {
+		t.Fatal("stale before the threshold")
+	}
+	now = now.Add(30 * time.Minute)
+	if !c.Stale(time.Hour) {
+		t.Fatal("not stale past the threshold")
+	}
+	if refreshes != 2 || errs != 1 {
+		t.Fatalf("OnRefresh saw %d refreshes, %d errors", refreshes, errs)
+	}
+
+	// The last good keys still verify tokens.
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "u1"})
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, c.Keyfunc); err != nil {
+		t.Fatalf("token with cached key rejected: %v", err)
+	}
+	tok.Header["kid"] = "k2"
+	signed, _ = tok.SignedString(priv)
+	if _, err := jwt.Parse(signed, c.Keyfunc); err == nil {
+		t.Fatal("token with unknown kid accepted")
+	}
+}
@@ -3,6 +3,7 @@ package kb
 import (
 	"context"
 	"strings"
+	"unicode"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -61,3 +62,61 @@ func Delete(ctx context.Context, db DB, slug string) error {
 	_, err := db.Exec(ctx, `delete from kb_articles where slug=$1`, slug)
 	return err
 }
+
+// relatedStopwords are common words that say nothing about a ticket's topic.
+var relatedStopwords = map[string]bool{
+	"this": true, "that": true, "with": true, "have": true, "from": true, "your": true, "when": true,
+	"what": true, "there": true, "their": true, "about": true, "would": true, "could": true, "should": true,
+	"please": true, "thanks": true, "thank": true, "hello": true, "still": true, "since": true, "after": true,
+	"before": true, "does": true, "doesn't": true, "can't": true, "cannot": true, "working": true, "help": true,
+	"need": true, "just": true, "some": true, "been": true, "into": true, "them": true, "they": true, "were": true,
+}
+
+// Keywords returns up to limit distinct lowercase words of at least four
+// letters from text, in order of appearance, skipping common words.
+func Keywords(text string, limit int) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		w = strings.Trim(w, "'")
+		if len([]rune(w)) < 4 || relatedStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// Related returns up to limit articles sharing keywords with text, best
+// first. Title matches weigh double.
+func Related(ctx context.Context, db DB, text string, limit int) ([]Article, error) {
+	words := Keywords(text, 12)
+	if len(words) == 0 {
+		return []Article{}, nil
+	}
+	rows, err := db.Query(ctx, `select id, slug, title, body_md from (
+            select id::text, slug, title, body_md,
+                (select count(*) from unnest($1::text[]) k where title ilike '%'||k||'%') * 2 +
+                (select count(*) from unnest($1::text[]) k where body_md ilike '%'||k||'%') as score
+            from kb_articles) a
+        where score > 0 order by score desc, title limit $2`, words, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Article{}
+	for rows.Next() {
+		var a Article
+		if err := rows.Scan(&a.ID, &a.Slug, &a.Title, &a.BodyMD); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,83 @@
+// Package mailtmpl holds the notification email templates. Each template
+// is a pair of "<name>_subject" and "<name>_body" definitions rendered as
+// plain text; the worker sends them and the API previews them.
+package mailtmpl
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templatesFS embed.FS
+
+var templates = template.Must(template.ParseFS(templatesFS, "templates/*.tmpl"))
+
+// samples is example data for each template, shaped like what the API and
+// worker pass when they send it.
+var samples = map[string]map[string]any{
+	"contract_expired":          {"Organization": "Acme Corp", "Contract": "2026 Gold", "EndsOn": "2026-06-30"},
+	"discord_link_verification": {"Token": "8F3K-2Q7M", "ExpiresIn": "15 minutes"},
+	"requester_verification":    {"URL": "https://help.example.com/api/verify-email?token=sample", "Token": "sample", "ExpiresIn": "72 hours"},
+	"new_device_login":          {"At": "2026-01-02 15:04 UTC", "IP": "203.0.113.7", "UserAgent": "Firefox on Linux"},
+	"sla_escalation":            {"Number": "TKT-1042", "Title": "VPN drops every hour", "Target": "resolution", "Percent": 75, "Rule": "Warn at 75%"},
+	"test_email":                {},
+	"ticket_created":            {"Number": "TKT-1042"},
+	"ticket_updated":            {"Number": "TKT-1042"},
+	"ticket_resolved":           {"Number": "TKT-1042", "CSATURL": "https://help.example.com/csat/sample"},
+	"ticket_updated_digest": {"Number": "TKT-1042", "Count": 2, "Updates": []map[string]any{
+		{"Summary": "status changed to In Progress"}, {"Summary": "priority changed to 2"},
+	}},
+	"ticket_comment": {"Number": "TKT-1042", "Body": "We've found the cause and a fix is rolling out now."},
+}
+
+// Names lists the templates, sorted.
+func Names() []string {
+	var out []string
+	for _, t := range templates.Templates() {
+		if name, ok := strings.CutSuffix(t.Name(), "_subject"); ok && templates.Lookup(name+"_body") != nil {
+			out = append(out, name)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Exists reports whether name is a template.
+func Exists(name string) bool {
+	return name != "" && templates.Lookup(name+"_subject") != nil && templates.Lookup(name+"_body") != nil
+}
+
+// Sample returns example data for name, or an empty map.
+func Sample(name string) map[string]any {
+	if s, ok := samples[name]; ok {
+		return s
+	}
+	return map[string]any{}
+}
+
+// Render executes name's subject and body with data.
+func Render(name string, data any) (subject, body string, err error) {
+	if !Exists(name) {
+		return "", "", fmt.Errorf("unknown template %q", name)
+	}
+	var subj, b bytes.Buffer
+	if err := templates.ExecuteTemplate(&subj, name+"_subject", data); err != nil {
+		return "", "", err
+	}
+	if err := templates.ExecuteTemplate(&b, name+"_body", data); err != nil {
+		return "", "", err
+	}
+	return subj.String(), b.String(), nil
+}
+
+// HTML is the HTML alternative of a plain text body: the text escaped and
+// kept as written. extra is trusted markup appended inside the body.
+func HTML(body, extra string) string {
+	return `<html><body><pre style="font-family:inherit;white-space:pre-wrap">` + html.EscapeString(body) + `</pre>` + extra + `</body></html>`
+}
@@ -0,0 +1,32 @@
+package mailtmpl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEveryTemplateRendersItsSample(t *testing.T) {
+	names := Names()
+	if len(names) == 0 {
+		t.Fatal("no templates")
+	}
+	for _, name := range names {
+		subject, body, err := Render(name, Sample(name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if strings.TrimSpace(subject) == "" || strings.Contains(subject+body, "<no value>") {
+			t.Fatalf("%s: sample data incomplete: %q %q", name, subject, body)
+		}
+	}
+	if _, _, err := Render("nope", nil); err == nil {
+		t.Fatal("expected an unknown template to fail")
+	}
+}
+
+func TestHTMLEscapesBody(t *testing.T) {
+	got := HTML("<b>hi</b>", `<img src="x">`)
+	if !strings.Contains(got, "&lt;b&gt;hi&lt;/b&gt;</pre><img") {
+		t.Fatalf("unexpected html %s", got)
+	}
+}
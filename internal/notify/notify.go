@@ -0,0 +1,347 @@
+// Package notify delivers short notifications to users over channels other
+// than email: SMS through Twilio, a generic webhook push and ntfy topics.
+// Users register channels and pick the events each one receives; producers
+// call Dispatch, which queues one channel_notify job per matching channel
+// through the outbox, and the worker sends them with the configured
+// providers.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+// Channel kinds.
+const (
+	KindSMS  = "sms"
+	KindPush = "push"
+	KindNtfy = "ntfy"
+)
+
+// Kinds lists the channel kinds a user can register.
+var Kinds = []string{KindSMS, KindPush, KindNtfy}
+
+// Events a channel can subscribe to.
+const (
+	// EventSLABreach fires when a ticket crosses its response or resolution
+	// target. It pages the assignee, or the ticket's team when unassigned.
+	// SLA escalation rules notify on it too.
+	EventSLABreach = "sla_breach"
+	// EventTicketAssigned fires when a ticket is assigned to the user.
+	EventTicketAssigned = "ticket_assigned"
+	// EventTicketAging fires when a ticket the user is assigned, or leads
+	// the team of, has sat idle past its queue's aging rule.
+	EventTicketAging = "ticket_aging"
+	// EventContractExpired fires when a support contract of an organization
+	// the user is account manager of has ended.
+	EventContractExpired = "contract_expired"
+	// EventApprovalRequested fires when a service request step waits for
+	// the user's approval.
+	EventApprovalRequested = "approval_requested"
+	// EventTest is sent by the channel test endpoint; channels cannot
+	// subscribe to it.
+	EventTest = "test"
+)
+
+// Events lists the events a channel can subscribe to.
+var Events = []string{EventSLABreach, EventTicketAssigned, EventTicketAging, EventContractExpired, EventApprovalRequested}
+
+// MaxSMS bounds the text of an SMS in runes, about three segments.
+const MaxSMS = 480
+
+// Timeout bounds a single delivery attempt.
+const Timeout = 10 * time.Second
+
+// Client sends provider requests.
+var Client = &http.Client{Timeout: Timeout}
+
+// TwilioURL is the Twilio REST API base.
+const TwilioURL = "https://api.twilio.com"
+
+// NtfyURL is the public ntfy server, used when no server is configured.
+const NtfyURL = "https://ntfy.sh"
+
+// ErrNotConfigured is returned for a channel kind whose provider has no
+// credentials. Retrying does not help.
+var ErrNotConfigured = errors.New("provider not configured")
+
+// Message is what a channel delivers.
+type Message struct {
+	Event    string `json:"event"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	URL      string `json:"url,omitempty"`
+	TicketID string `json:"ticket_id,omitempty"`
+	// Urgent asks the provider to break through do-not-disturb where it
+	// can; SLA breaches are urgent.
+	Urgent bool `json:"urgent,omitempty"`
+}
+
+// Provider sends a message to an address of its kind.
+type Provider interface {
+	Send(ctx context.Context, to string, m Message) error
+}
+
+var (
+	e164      = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	ntfyTopic = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// ValidKind reports whether kind is a channel kind.
+func ValidKind(kind string) bool { return slices.Contains(Kinds, kind) }
+
+// ValidEvent reports whether event can be subscribed to.
+func ValidEvent(event string) bool { return slices.Contains(Events, event) }
+
+// NormalizeAddress checks address for a channel of kind and returns it in
+// canonical form: an E.164 number for SMS, an https URL for push and a
+// topic name for ntfy.
+func NormalizeAddress(kind, address string) (string, error) {
+	address = strings.TrimSpace(address)
+	switch kind {
+	case KindSMS:
+		n := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(address)
+		if !e164.MatchString(n) {
+			return "", errors.New("must be a phone number in E.164 format, like +15551234567")
+		}
+		return n, nil
+	case KindPush:
+		u, err := url.Parse(address)
+		if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+			return "", errors.New("must be an https URL")
+		}
+		return u.String(), nil
+	case KindNtfy:
+		if !ntfyTopic.MatchString(address) {
+			return "", errors.New("must be an ntfy topic of letters, digits, '-' and '_'")
+		}
+		return address, nil
+	}
+	return "", errors.New("unknown channel kind")
+}
+
+// Providers holds the configured provider for each kind.
+type Providers struct {
+	Twilio Twilio
+	Push   Push
+	Ntfy   Ntfy
+}
+
+// For returns the provider for kind, or ErrNotConfigured.
+func (p Providers) For(kind string) (Provider, error) {
+	switch kind {
+	case KindSMS:
+		if p.Twilio.AccountSID == "" || p.Twilio.AuthToken == "" || p.Twilio.From == "" {
+			return nil, ErrNotConfigured
+		}
+		return p.Twilio, nil
+	case KindPush:
+		return p.Push, nil
+	case KindNtfy:
+		return p.Ntfy, nil
+	}
+	return nil, fmt.Errorf("unknown channel kind %q", kind)
+}
+
+// Twilio sends SMS through Twilio's Messages API.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	// BaseURL overrides TwilioURL, for tests.
+	BaseURL string
+	Client  *http.Client
+}
+
+// Send sends m to the phone number to as one SMS.
+func (t Twilio) Send(ctx context.Context, to string, m Message) error {
+	base := t.BaseURL
+	if base == "" {
+		base = TwilioURL
+	}
+	form := url.Values{"To": {to}, "From": {t.From}, "Body": {SMSText(m)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(base, "/")+"/2010-04-01/Accounts/"+url.PathEscape(t.AccountSID)+"/Messages.json",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(t.Client, req, "twilio")
+}
+
+// SMSText flattens m into one SMS, cut to MaxSMS runes.
+func SMSText(m Message) string {
+	parts := []string{}
+	for _, s := range []string{m.Title, m.Body, m.URL} {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	text := strings.Join(parts, "\n")
+	if utf8.RuneCountInString(text) > MaxSMS {
+		text = string([]rune(text)[:MaxSMS-1]) + "…"
+	}
+	return text
+}
+
+// Push posts m as JSON to the channel's URL with the same headers and
+// signature as outbound webhooks, so a relay to a phone push service can
+// verify it came from the helpdesk.
+type Push struct {
+	// Secret signs deliveries; empty sends them unsigned.
+	Secret string
+	Client *http.Client
+}
+
+// Send posts m to the URL to.
+func (p Push) Send(ctx context.Context, to string, m Message) error {
+	now := time.Now()
+	body, err := json.Marshal(map[string]any{
+		"event":     m.Event,
+		"title":     m.Title,
+		"body":      m.Body,
+		"url":       m.URL,
+		"ticket_id": m.TicketID,
+		"urgent":    m.Urgent,
+		"sent_at":   now.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	res := webhook.Send(ctx, p.Client, to, p.Secret, "notification."+m.Event, uuid.NewString(), body, now)
+	if !res.OK() {
+		if res.Error != "" {
+			return fmt.Errorf("push: %s", res.Error)
+		}
+		return fmt.Errorf("push: status %d: %s", res.StatusCode, res.Excerpt)
+	}
+	return nil
+}
+
+// Ntfy publishes m to an ntfy topic.
+type Ntfy struct {
+	// BaseURL is the ntfy server; empty uses NtfyURL.
+	BaseURL string
+	// Token authenticates to servers with access control.
+	Token  string
+	Client *http.Client
+}
+
+// Send publishes m to the topic to.
+func (n Ntfy) Send(ctx context.Context, to string, m Message) error {
+	base := n.BaseURL
+	if base == "" {
+		base = NtfyURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(base, "/")+"/"+url.PathEscape(to), strings.NewReader(m.Body))
+	if err != nil {
+		return err
+	}
+	// ntfy reads the title from a header, so it must stay on one line.
+	req.Header.Set("Title", strings.Join(strings.Fields(m.Title), " "))
+	req.Header.Set("Priority", "default")
+	if m.Urgent {
+		req.Header.Set("Priority", "urgent")
+		req.Header.Set("Tags", "rotating_light")
+	}
+	if m.URL != "" {
+		req.Header.Set("Click", m.URL)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return do(n.Client, req, "ntfy")
+}
+
+// do sends req and turns a non-2xx answer into an error quoting the start of
+// the response.
+func do(client *http.Client, req *http.Request, provider string) error {
+	if client == nil {
+		client = Client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: status %d: %s", provider, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return nil
+}
+
+// DB is the subset of the database Dispatch needs.
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Enqueue queues m for one channel through the outbox, so it is sent only
+// if the caller's transaction commits.
+func Enqueue(ctx context.Context, db outbox.Execer, channelID string, m Message) error {
+	id := uuid.NewString()
+	return outbox.AddJob(ctx, db, "channel_notify:"+id, id, jobs.TypeChannelNotify, jobs.ChannelNotify{
+		ChannelID: channelID,
+		Event:     m.Event,
+		Title:     m.Title,
+		Body:      m.Body,
+		URL:       m.URL,
+		TicketID:  m.TicketID,
+		Urgent:    m.Urgent,
+	})
+}
+
+// Dispatch queues m for every enabled channel of userIDs subscribed to
+// m.Event and reports how many were queued.
+func Dispatch(ctx context.Context, db DB, userIDs []string, m Message) (int, error) {
+	if len(userIDs) == 0 {
+		return 0, nil
+	}
+	rows, err := db.Query(ctx, `select id::text from notification_channels
+        where user_id::text = any($1::text[]) and enabled and $2 = any(events)
+        order by created_at`, userIDs, m.Event)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := Enqueue(ctx, db, id, m); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
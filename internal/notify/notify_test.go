@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/internal/webhook"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	cases := []struct {
+		kind, in, want string
+		ok             bool
+	}{
+		{KindSMS, "+1 (555) 123-4567", "+15551234567", true},
+		{KindSMS, "5551234567", "", false},
+		{KindSMS, "+0123456789", "", false},
+		{KindPush, " https://push.example.com/hook?k=1 ", "https://push.example.com/hook?k=1", true},
+		{KindPush, "http://push.example.com/hook", "", false},
+		{KindPush, "https://user:pw@push.example.com/", "", false},
+		{KindNtfy, "oncall_alerts-1", "oncall_alerts-1", true},
+		{KindNtfy, "on call", "", false},
+		{"pager", "x", "", false},
+	}
+	for _, tc := range cases {
+		got, err := NormalizeAddress(tc.kind, tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("NormalizeAddress(%q, %q) = %q, %v", tc.kind, tc.in, got, err)
+		}
+	}
+}
+
+func TestProvidersFor(t *testing.T) {
+	if _, err := (Providers{}).For(KindSMS); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected SMS without Twilio credentials to be unconfigured, got %v", err)
+	}
+	p := Providers{Twilio: Twilio{AccountSID: "AC1", AuthToken: "tok", From: "+15550000000"}}
+	if _, err := p.For(KindSMS); err != nil {
+		t.Fatalf("For(sms): %v", err)
+	}
+	if _, err := p.For(KindNtfy); err != nil {
+		t.Fatalf("For(ntfy): %v", err)
+	}
+}
+
+func TestSMSText(t *testing.T) {
+	got := SMSText(Message{Title: "SLA breach", Body: " HD-1 missed ", URL: "https://hd.example.com/tickets/1"})
+	if got != "SLA breach\nHD-1 missed\nhttps://hd.example.com/tickets/1" {
+		t.Fatalf("unexpected text %q", got)
+	}
+	long := SMSText(Message{Body: strings.Repeat("é", MaxSMS+10)})
+	if utf8.RuneCountInString(long) != MaxSMS || !strings.HasSuffix(long, "…") {
+		t.Fatalf("expected text cut to %d runes, got %d", MaxSMS, utf8.RuneCountInString(long))
+	}
+}
+
+func TestTwilioSend(t *testing.T) {
+	var path, user, pass, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		user, pass, _ = r.BasicAuth()
+		_ = r.ParseForm()
+		body = r.Form.Get("To") + "|" + r.Form.Get("From") + "|" + r.Form.Get("Body")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	tw := Twilio{AccountSID: "AC1", AuthToken: "tok", From: "+15550000000", BaseURL: srv.URL}
+	if err := tw.Send(context.Background(), "+15551234567", Message{Title: "Page", Body: "now"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if path != "/2010-04-01/Accounts/AC1/Messages.json" || user != "AC1" || pass != "tok" {
+		t.Fatalf("unexpected request %s as %s:%s", path, user, pass)
+	}
+	if body != "+15551234567|+15550000000|Page\nnow" {
+		t.Fatalf("unexpected form %q", body)
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"invalid number"}`, http.StatusBadRequest)
+	}))
+	defer fail.Close()
+	tw.BaseURL = fail.URL
+	if err := tw.Send(context.Background(), "+15551234567", Message{Body: "x"}); err == nil || !strings.Contains(err.Error(), "invalid number") {
+		t.Fatalf("expected the provider error, got %v", err)
+	}
+}
+
+func TestNtfySend(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+	n := Ntfy{BaseURL: srv.URL, Token: "tk"}
+	m := Message{Title: "SLA breach:\nHD-1", Body: "missed", URL: "https://hd.example.com/tickets/1", Urgent: true}
+	if err := n.Send(context.Background(), "oncall", m); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.URL.Path != "/oncall" || body != "missed" {
+		t.Fatalf("unexpected request %s %q", got.URL.Path, body)
+	}
+	h := got.Header
+	if h.Get("Title") != "SLA breach: HD-1" || h.Get("Priority") != "urgent" || h.Get("Click") != m.URL || h.Get("Authorization") != "Bearer tk" {
+		t.Fatalf("unexpected headers %v", h)
+	}
+}
+
+func TestPushSendSigned(t *testing.T) {
+	var ok bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		ok = r.Header.Get(webhook.HeaderEvent) == "notification.sla_breach" &&
+			webhook.Verify("s3cret", ts, b, r.Header.Get(webhook.HeaderSignature)) &&
+			strings.Contains(string(b), `"title":"Page"`)
+	}))
+	defer srv.Close()
+	if err := (Push{Secret: "s3cret"}).Send(context.Background(), srv.URL, Message{Event: EventSLABreach, Title: "Page"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected a signed notification payload")
+	}
+}
+
+type dispatchDB struct {
+	query  string
+	args   []any
+	outbox []string
+}
+
+func (db *dispatchDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	db.query, db.args = sql, args
+	return &idRows{ids: []string{"c1", "c2"}}, nil
+}
+
+func (db *dispatchDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.outbox = append(db.outbox, args[2].(string))
+	return pgconn.CommandTag{}, nil
+}
+
+type idRows struct {
+	pgx.Rows
+	ids []string
+	i   int
+}
+
+func (r *idRows) Next() bool { r.i++; return r.i <= len(r.ids) }
+func (r *idRows) Scan(dest ...any) error {
+	*dest[0].(*string) = r.ids[r.i-1]
+	return nil
+}
+func (r *idRows) Close()     {}
+func (r *idRows) Err() error { return nil }
+
+func TestDispatch(t *testing.T) {
+	db := &dispatchDB{}
+	if n, err := Dispatch(context.Background(), db, nil, Message{Event: EventSLABreach}); err != nil || n != 0 || db.query != "" {
+		t.Fatalf("expected no lookup without users, got %d %v", n, err)
+	}
+	n, err := Dispatch(context.Background(), db, []string{"u1"}, Message{Event: EventSLABreach, Title: "Page", TicketID: "t1", Urgent: true})
+	if err != nil || n != 2 {
+		t.Fatalf("Dispatch = %d, %v", n, err)
+	}
+	if db.args[1] != EventSLABreach || !strings.Contains(db.query, "enabled") {
+		t.Fatalf("unexpected lookup %s %v", db.query, db.args)
+	}
+	if len(db.outbox) != 2 || !strings.Contains(db.outbox[1], `"channel_id":"c2"`) || !strings.Contains(db.outbox[1], `"urgent":true`) {
+		t.Fatalf("unexpected outbox rows %v", db.outbox)
+	}
+}
@@ -0,0 +1,63 @@
+// Package ooo implements out-of-office windows: assignments to a user who is
+// away are redirected to their delegate, and their at-risk tickets get a
+// reassignment suggestion.
+package ooo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/internal/actor"
+	"github.com/mark3748/helpdesk-go/internal/sla"
+)
+
+// EventSuggested is the ticket event emitted for at-risk tickets whose
+// assignee is out of office.
+const EventSuggested = "reassignment_suggested"
+
+// DB is the subset of the database used by SuggestReassignments.
+type DB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Active is a SQL condition that is true while the users row aliased as
+// alias is inside its out-of-office window.
+func Active(alias string) string {
+	return `coalesce(now() >= ` + alias + `.ooo_start and now() < ` + alias + `.ooo_end, false)`
+}
+
+// AssigneeExpr is a SQL expression that resolves the user ID bound to param
+// to the effective assignee: the user themselves, their delegate while they
+// are out of office, or NULL (the team pool) when they have no delegate or
+// the delegate is away too. Unknown IDs pass through so the foreign key still
+// rejects them.
+func AssigneeExpr(param string) string {
+	return `(case when exists (select 1 from users o where o.id = ` + param + `::uuid and ` + Active("o") + `)
+            then (select d.id from users u join users d on d.id = u.ooo_delegate_id
+                where u.id = ` + param + `::uuid and not ` + Active("d") + `)
+            else ` + param + `::uuid end)`
+}
+
+// SuggestReassignments emits EventSuggested for open, at-risk tickets
+// assigned to someone currently out of office. Each ticket is suggested at
+// most once per window. The payload names the delegate when they are
+// available.
+func SuggestReassignments(ctx context.Context, db DB) (int64, error) {
+	tag, err := db.Exec(ctx, `insert into ticket_events (ticket_id, event_type, payload, actor_type)
+        select t.id, $1, jsonb_build_object('id', t.id::text, 'assignee_id', u.id::text,
+                'suggested_assignee_id', d.id::text, 'reason', 'out_of_office', 'until', u.ooo_end), $2
+        from tickets t
+        join users u on u.id = t.assignee_id
+        left join users d on d.id = u.ooo_delegate_id and not `+Active("d")+`
+        join ticket_sla_clocks sc on sc.ticket_id = t.id
+        join sla_policy_versions sp on sp.id = sc.policy_version_id
+        where `+Active("u")+` and `+sla.AtRiskFilter+`
+          and not exists (select 1 from ticket_events e
+              where e.ticket_id = t.id and e.event_type = $1 and e.created_at >= u.ooo_start)`,
+		EventSuggested, actor.System("ooo").Type)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
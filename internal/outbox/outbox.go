@@ -0,0 +1,155 @@
+// Package outbox implements the transactional outbox used to publish events
+// and jobs reliably. Producers write rows in the same transaction as their
+// domain change; the worker relays pending rows to Redis with at-least-once
+// delivery, using each row's dedup key to suppress repeats.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+// Row kinds.
+const (
+	KindEvent = "event"
+	KindJob   = "job"
+)
+
+// EventsChannel is the Redis pub/sub channel the websocket hub listens on.
+const EventsChannel = "events"
+
+// dedupTTL bounds how long a relayed dedup key suppresses repeats. It only
+// needs to outlive the window between publishing and marking the row.
+const dedupTTL = 24 * time.Hour
+
+// retention is how long published rows are kept for inspection.
+const retention = 7 * 24 * time.Hour
+
+// Execer is satisfied by pgx pools, transactions and app.DB.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// DB is what the relay needs from the database.
+type DB interface {
+	Execer
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// Event mirrors the websocket event envelope.
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// AddEvent records a websocket event for publishing. Rows with a dedup key
+// already in the outbox are ignored.
+func AddEvent(ctx context.Context, db Execer, dedupKey, typ string, data any) error {
+	b, err := json.Marshal(Event{Type: typ, Data: data})
+	if err != nil {
+		return fmt.Errorf("marshal outbox event: %w", err)
+	}
+	return add(ctx, db, KindEvent, dedupKey, b)
+}
+
+// AddJob records a job for enqueueing on the queue its type routes to.
+func AddJob(ctx context.Context, db Execer, dedupKey, id, typ string, data any) error {
+	b, err := jobs.EncodeContext(ctx, id, typ, data)
+	if err != nil {
+		return err
+	}
+	return add(ctx, db, KindJob, dedupKey, b)
+}
+
+func add(ctx context.Context, db Execer, kind, dedupKey string, payload []byte) error {
+	const q = `insert into outbox (kind, dedup_key, payload) values ($1, $2, $3::jsonb) on conflict (dedup_key) do nothing`
+	_, err := db.Exec(ctx, q, kind, dedupKey, string(payload))
+	return err
+}
+
+// deliverScript publishes or enqueues a payload only if its dedup key has not
+// been seen, so a row relayed twice reaches Redis once.
+// KEYS: dedup key, target. ARGV: mode (publish|push), payload, ttl seconds.
+var deliverScript = redis.NewScript(`
+if not redis.call('SET', KEYS[1], '1', 'NX', 'EX', tonumber(ARGV[3])) then
+  return 0
+end
+if ARGV[1] == 'publish' then
+  redis.call('PUBLISH', KEYS[2], ARGV[2])
+else
+  redis.call('RPUSH', KEYS[2], ARGV[2])
+end
+return 1
+`)
+
+type row struct {
+	id       int64
+	kind     string
+	dedupKey string
+	payload  string
+}
+
+// Relay delivers up to limit pending rows in insertion order and reports how
+// many were marked published. It stops at the first Redis failure so later
+// rows are not delivered ahead of earlier ones.
+func Relay(ctx context.Context, db DB, rdb *redis.Client, limit int) (int, error) {
+	rows, err := db.Query(ctx, `select id, kind, dedup_key, payload::text from outbox where published_at is null order by id limit $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.kind, &r.dedupKey, &r.payload); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, r := range pending {
+		if err := deliver(ctx, rdb, r); err != nil {
+			_, _ = db.Exec(ctx, `update outbox set attempts = attempts + 1, last_error = $2 where id = $1`, r.id, err.Error())
+			return done, err
+		}
+		if _, err := db.Exec(ctx, `update outbox set published_at = now() where id = $1`, r.id); err != nil {
+			// Delivered but unmarked: the next pass re-delivers and the dedup key drops it.
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+func deliver(ctx context.Context, rdb *redis.Client, r row) error {
+	mode, target := "publish", EventsChannel
+	if r.kind == KindJob {
+		var env struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal([]byte(r.payload), &env); err != nil {
+			return fmt.Errorf("decode outbox job: %w", err)
+		}
+		mode, target = "push", jobs.QueueFor(env.Type)
+	}
+	return deliverScript.Run(ctx, rdb, []string{"outbox:sent:" + r.dedupKey, target}, mode, r.payload, int(dedupTTL.Seconds())).Err()
+}
+
+// Prune deletes published rows older than the retention window.
+func Prune(ctx context.Context, db Execer) error {
+	_, err := db.Exec(ctx, `delete from outbox where published_at < $1`, time.Now().Add(-retention))
+	return err
+}
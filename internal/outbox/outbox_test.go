@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+type fakeRows struct {
+	data []row
+	i    int
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) Next() bool                                   { return r.i < len(r.data) }
+func (r *fakeRows) Values() ([]any, error)                       { return nil, nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+func (r *fakeRows) Scan(dest ...any) error {
+	row := r.data[r.i]
+	r.i++
+	*dest[0].(*int64) = row.id
+	*dest[1].(*string) = row.kind
+	*dest[2].(*string) = row.dedupKey
+	*dest[3].(*string) = row.payload
+	return nil
+}
+
+// fakeDB keeps outbox rows in memory and honours the published marker.
+type fakeDB struct {
+	rows      []row
+	published map[int64]bool
+	failMark  bool
+	execs     []string
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	var pending []row
+	for _, r := range db.rows {
+		if !db.published[r.id] {
+			pending = append(pending, r)
+		}
+	}
+	return &fakeRows{data: pending}, nil
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, sql)
+	if strings.Contains(sql, "published_at = now()") {
+		if db.failMark {
+			return pgconn.CommandTag{}, errors.New("db down")
+		}
+		db.published[args[0].(int64)] = true
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func TestRelayDeliversOnce(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	job, _ := jobs.Encode("j1", jobs.TypeExportTickets, jobs.ExportTickets{IDs: []string{"1"}})
+	db := &fakeDB{
+		published: map[int64]bool{},
+		rows: []row{
+			{id: 1, kind: KindEvent, dedupKey: "ticket_created:1", payload: `{"type":"ticket_created","data":{"id":"1"}}`},
+			{id: 2, kind: KindJob, dedupKey: "export:j1", payload: string(job)},
+		},
+		failMark: true,
+	}
+
+	// Delivery succeeds but marking fails: the row stays pending.
+	if _, err := Relay(ctx, db, rdb, 10); err == nil {
+		t.Fatalf("expected mark failure")
+	}
+	db.failMark = false
+	n, err := Relay(ctx, db, rdb, 10)
+	if err != nil || n != 2 {
+		t.Fatalf("relay: n=%d err=%v", n, err)
+	}
+	items, _ := mr.List(jobs.QueueBulk)
+	if len(items) != 1 {
+		t.Fatalf("expected job delivered once to %s, got %d", jobs.QueueBulk, len(items))
+	}
+	if !mr.Exists("outbox:sent:ticket_created:1") {
+		t.Fatalf("expected event dedup key")
+	}
+	if n, _ := Relay(ctx, db, rdb, 10); n != 0 {
+		t.Fatalf("expected nothing pending, got %d", n)
+	}
+}
+
+type execRecorder struct {
+	sql  string
+	args []any
+}
+
+func (e *execRecorder) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	e.sql, e.args = sql, args
+	return pgconn.CommandTag{}, nil
+}
+
+func TestAddJobEncodesEnvelope(t *testing.T) {
+	rec := &execRecorder{}
+	if err := AddJob(context.Background(), rec, "k1", "", jobs.TypeSendEmail, jobs.Email{To: "a@example.com"}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if !strings.Contains(rec.sql, "on conflict (dedup_key) do nothing") {
+		t.Fatalf("expected idempotent insert, got %s", rec.sql)
+	}
+	if rec.args[0] != KindJob || rec.args[1] != "k1" {
+		t.Fatalf("unexpected args %v", rec.args)
+	}
+	var env jobs.Job
+	if err := json.Unmarshal([]byte(rec.args[2].(string)), &env); err != nil || env.Type != jobs.TypeSendEmail || env.Version == 0 {
+		t.Fatalf("unexpected envelope %+v: %v", env, err)
+	}
+}
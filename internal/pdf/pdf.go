@@ -0,0 +1,297 @@
+// Package pdf writes simple text documents as PDF: headings, paragraphs and
+// label/value rows flowed onto A4 pages in the standard Helvetica fonts, so
+// no font files need to be embedded.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A4 page geometry in points.
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 50.0
+	textWidth  = pageWidth - 2*margin
+)
+
+// Font sizes in points.
+const (
+	titleSize   = 16.0
+	headingSize = 12.0
+	bodySize    = 10.0
+	smallSize   = 8.0
+)
+
+// Document is a PDF under construction. The zero value is not usable; call
+// New.
+type Document struct {
+	title  string
+	footer string
+	pages  []*bytes.Buffer
+	y      float64
+}
+
+// New starts a document. title is stored in the document info and printed
+// at the top of the first page.
+func New(title string) *Document {
+	d := &Document{title: title}
+	d.newPage()
+	d.write(titleSize, true, 0, title)
+	d.y -= 6
+	return d
+}
+
+// SetFooter sets a line printed at the bottom of every page next to the page
+// number.
+func (d *Document) SetFooter(s string) { d.footer = s }
+
+// Heading starts a section.
+func (d *Document) Heading(s string) {
+	d.y -= 10
+	// Keep a heading with at least two lines of its section.
+	if d.y-headingSize-3*bodySize < margin {
+		d.newPage()
+	}
+	d.write(headingSize, true, 0, s)
+	d.y -= 2
+}
+
+// Text adds a paragraph. Newlines start new lines; long lines wrap.
+func (d *Document) Text(s string) {
+	d.write(bodySize, false, 0, s)
+}
+
+// Note adds a paragraph in small type, e.g. a timestamp under a heading.
+func (d *Document) Note(s string) {
+	d.write(smallSize, false, 0, s)
+}
+
+// Field adds a bold label with its value wrapped in a column beside it.
+// Labels longer than about 20 characters overlap the value.
+func (d *Document) Field(label, value string) {
+	const labelWidth = 125.0
+	if value == "" {
+		value = "-"
+	}
+	first := true
+	for _, line := range splitLines(value, bodySize, false, textWidth-labelWidth) {
+		d.advance(bodySize)
+		if first {
+			d.show(bodySize, true, 0, encode(label))
+			first = false
+		}
+		d.show(bodySize, false, labelWidth, line)
+	}
+}
+
+// Space adds vertical space.
+func (d *Document) Space() { d.y -= bodySize }
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pageHeight - margin
+}
+
+func (d *Document) write(size float64, bold bool, indent float64, s string) {
+	for _, line := range splitLines(s, size, bold, textWidth-indent) {
+		d.advance(size)
+		d.show(size, bold, indent, line)
+	}
+}
+
+// splitLines splits s into wrapped WinAnsi lines; blank lines are kept.
+func splitLines(s string, size float64, bold bool, width float64) [][]byte {
+	var out [][]byte
+	for _, para := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		lines := wrap(encode(para), size, bold, width)
+		if len(lines) == 0 {
+			lines = [][]byte{nil}
+		}
+		out = append(out, lines...)
+	}
+	return out
+}
+
+// advance moves down one line of the given size, starting a new page when
+// the line would run into the bottom margin.
+func (d *Document) advance(size float64) {
+	lead := size * 1.4
+	if d.y-lead < margin {
+		d.newPage()
+	}
+	d.y -= lead
+}
+
+func (d *Document) show(size float64, bold bool, indent float64, line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.pages[len(d.pages)-1], "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, margin+indent, d.y, escape(line))
+}
+
+// Bytes renders the document.
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(data []byte) {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		_, _ = zw.Write(data)
+		_ = zw.Close()
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	// Objects 1-4 are fixed; each page then takes a page and a content object.
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (helpdesk) /CreationDate (D:%s) >>",
+		escape(encode(d.title)), time.Now().UTC().Format("20060102150405Z")))
+	for i, p := range d.pages {
+		footer := fmt.Sprintf("Page %d of %d", i+1, len(d.pages))
+		if d.footer != "" {
+			footer = d.footer + "  -  " + footer
+		}
+		content := append(p.Bytes(), fmt.Sprintf("BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", smallSize, margin, margin/2, escape(encode(footer)))...)
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 7+2*i))
+		stream(content)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// wrap breaks WinAnsi text into lines no wider than width, splitting words
+// only when a single word does not fit.
+func wrap(s []byte, size float64, bold bool, width float64) [][]byte {
+	var lines [][]byte
+	var line []byte
+	lineW := 0.0
+	for _, word := range bytes.Fields(s) {
+		w := textWidthOf(word, size, bold)
+		space := textWidthOf([]byte{' '}, size, bold)
+		if len(line) > 0 && lineW+space+w <= width {
+			line = append(append(line, ' '), word...)
+			lineW += space + w
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+			line, lineW = nil, 0
+		}
+		for w > width {
+			n := 1
+			for n < len(word) && textWidthOf(word[:n+1], size, bold) <= width {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+			w = textWidthOf(word, size, bold)
+		}
+		line = append([]byte(nil), word...)
+		lineW = w
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func textWidthOf(s []byte, size float64, bold bool) float64 {
+	units := 0
+	for _, b := range s {
+		if b >= 32 && b <= 126 {
+			units += helvetica[b-32]
+		} else {
+			units += 556
+		}
+	}
+	w := float64(units) * size / 1000
+	if bold {
+		// Helvetica-Bold is about 7% wider than the regular weight.
+		w *= 1.07
+	}
+	return w
+}
+
+// escape quotes a WinAnsi string for a PDF literal.
+func escape(s []byte) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// encode converts UTF-8 text to WinAnsiEncoding. Characters outside it
+// become '?'; tabs become spaces and other control characters are dropped.
+func encode(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			out = append(out, ' ')
+		case r < 32 || r == 127:
+		case r < 128 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		default:
+			if b, ok := winAnsi[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// winAnsi maps the non-Latin-1 characters of WinAnsiEncoding.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// helvetica holds the Helvetica advance widths (1/1000 em) for ASCII 32-126.
+var helvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
@@ -0,0 +1,63 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDocumentStructure(t *testing.T) {
+	d := New("HD-1 (printer) – “quoted”")
+	d.SetFooter("HD-1")
+	d.Heading("Details")
+	d.Field("Status", "Open")
+	for i := 0; i < 120; i++ {
+		d.Text("Line " + strconv.Itoa(i) + " " + strings.Repeat("word ", 30))
+	}
+	out := d.Bytes()
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatal("missing PDF header or trailer")
+	}
+	if len(d.pages) < 2 {
+		t.Fatalf("expected text to flow onto several pages, got %d", len(d.pages))
+	}
+	// Every xref entry must point at the start of its object.
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(out)
+	xref, _ := strconv.Atoi(string(m[1]))
+	entries := strings.Split(string(out[xref:]), "\n")[3:]
+	for i := 1; i < 6+2*len(d.pages); i++ {
+		off, _ := strconv.Atoi(entries[i-1][:10])
+		if want := strconv.Itoa(i) + " 0 obj"; !bytes.HasPrefix(out[off:], []byte(want)) {
+			t.Fatalf("xref entry %d points at %q", i, out[off:off+10])
+		}
+	}
+	if !bytes.Contains(out, []byte(`/Title (HD-1 \(printer\) `+"\x96 \x93quoted\x94"+`)`)) {
+		t.Fatal("title not escaped and encoded as WinAnsi")
+	}
+	// The first content stream carries the details row.
+	start := bytes.Index(out, []byte("stream\n")) + len("stream\n")
+	zr, err := zlib.NewReader(bytes.NewReader(out[start:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(zr)
+	if !bytes.Contains(content, []byte("(Status) Tj")) || !bytes.Contains(content, []byte("(Open) Tj")) {
+		t.Fatalf("details missing from first page: %s", content[:200])
+	}
+}
+
+func TestWrap(t *testing.T) {
+	lines := wrap([]byte("aaaa bbbb "+strings.Repeat("x", 60)), 10, false, 100)
+	for _, l := range lines {
+		if w := textWidthOf(l, 10, false); w > 100 {
+			t.Fatalf("line %q is %.1fpt wide", l, w)
+		}
+	}
+	if string(lines[0]) != "aaaa bbbb" {
+		t.Fatalf("expected words kept together, got %q", lines[0])
+	}
+}
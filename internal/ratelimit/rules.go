@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// KeyType is what a rule counts requests by.
+type KeyType string
+
+const (
+	// KeyIP counts each client IP separately.
+	KeyIP KeyType = "ip"
+	// KeyUser counts each signed-in user separately. Such rules only apply
+	// to routes that require authentication.
+	KeyUser KeyType = "user"
+	// KeyGlobal counts every caller together.
+	KeyGlobal KeyType = "global"
+)
+
+// MaxWindow bounds a rule's window.
+const MaxWindow = 24 * time.Hour
+
+// Rule limits the requests to the routes matching Route and Method.
+type Rule struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Route is a route as registered, such as /tickets/:id/comments,
+	// without the /api prefix. A "*" matches any run of characters,
+	// slashes included, so /tickets* covers every ticket route.
+	Route string `json:"route"`
+	// Method is an HTTP method, or "*" for any.
+	Method        string    `json:"method"`
+	Key           KeyType   `json:"key_type"`
+	Limit         int       `json:"limit"`
+	WindowSeconds int       `json:"window_seconds"`
+	Enabled       bool      `json:"enabled"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Validate normalises r's route and method and reports the first problem.
+func (r *Rule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Route = strings.TrimSpace(r.Route)
+	r.Method = strings.ToUpper(strings.TrimSpace(r.Method))
+	if r.Method == "" {
+		r.Method = "*"
+	}
+	if r.Key == "" {
+		r.Key = KeyIP
+	}
+	switch {
+	case r.Name == "":
+		return errors.New("name is required")
+	case !strings.HasPrefix(r.Route, "/") && !strings.HasPrefix(r.Route, "*"):
+		return errors.New("route must start with / or *")
+	case r.Method != "*" && !validMethods[r.Method]:
+		return fmt.Errorf("unknown method %q", r.Method)
+	case r.Key != KeyIP && r.Key != KeyUser && r.Key != KeyGlobal:
+		return fmt.Errorf("key_type must be %s, %s or %s", KeyIP, KeyUser, KeyGlobal)
+	case r.Limit <= 0:
+		return errors.New("limit must be positive")
+	case r.WindowSeconds <= 0 || time.Duration(r.WindowSeconds)*time.Second > MaxWindow:
+		return fmt.Errorf("window_seconds must be between 1 and %d", int(MaxWindow.Seconds()))
+	}
+	return nil
+}
+
+var validMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Matches reports whether a request for route with method falls under r.
+func (r Rule) Matches(method, route string) bool {
+	if r.Method != "*" && r.Method != method {
+		return false
+	}
+	return globMatch(r.Route, route)
+}
+
+// globMatch matches s against pattern, where "*" matches any run of
+// characters.
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for i, p := range parts[1:] {
+		if i == len(parts)-2 {
+			return strings.HasSuffix(s, p)
+		}
+		j := strings.Index(s, p)
+		if j < 0 {
+			return false
+		}
+		s = s[j+len(p):]
+	}
+	return s == ""
+}
+
+// Window returns r's window as a duration.
+func (r Rule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// Querier is the part of a database handle LoadRules uses.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// RuleColumns are the rate_limit_rules columns ScanRule reads.
+const RuleColumns = `id::text, name, route, method, key_type, "limit", window_seconds, enabled, updated_at`
+
+// ScanRule reads a rule selected with RuleColumns.
+func ScanRule(scan func(dest ...any) error) (Rule, error) {
+	var r Rule
+	var key string
+	err := scan(&r.ID, &r.Name, &r.Route, &r.Method, &key, &r.Limit, &r.WindowSeconds, &r.Enabled, &r.UpdatedAt)
+	r.Key = KeyType(key)
+	return r, err
+}
+
+// LoadRules returns the rules by name.
+func LoadRules(ctx context.Context, db Querier, enabledOnly bool) ([]Rule, error) {
+	q := `select ` + RuleColumns + ` from rate_limit_rules`
+	if enabledOnly {
+		q += ` where enabled`
+	}
+	rows, err := db.Query(ctx, q+` order by name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []Rule{}
+	for rows.Next() {
+		r, err := ScanRule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
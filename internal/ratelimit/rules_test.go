@@ -0,0 +1,47 @@
+package ratelimit
+
+import "testing"
+
+func TestRuleMatches(t *testing.T) {
+	for _, tc := range []struct {
+		route, method, reqMethod, reqRoute string
+		want                               bool
+	}{
+		{"/tickets", "POST", "POST", "/tickets", true},
+		{"/tickets", "POST", "GET", "/tickets", false},
+		{"/tickets", "*", "GET", "/tickets/:id", false},
+		{"/tickets*", "*", "GET", "/tickets/:id/comments", true},
+		{"/tickets/*/attachments*", "*", "POST", "/tickets/:id/attachments/presign", true},
+		{"/tickets/*/attachments*", "*", "POST", "/tickets/:id/comments", false},
+		{"*/export", "GET", "GET", "/reports/export", true},
+		{"*/export", "GET", "GET", "/reports/export/csv", false},
+	} {
+		r := Rule{Route: tc.route, Method: tc.method}
+		if got := r.Matches(tc.reqMethod, tc.reqRoute); got != tc.want {
+			t.Errorf("%s %s on %s %s = %v", tc.method, tc.route, tc.reqMethod, tc.reqRoute, got)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	r := Rule{Name: " search ", Route: "/search", Method: "get", Limit: 10, WindowSeconds: 60}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Name != "search" || r.Method != "GET" || r.Key != KeyIP {
+		t.Fatalf("not normalised: %+v", r)
+	}
+	for name, bad := range map[string]Rule{
+		"no name":      {Route: "/x", Limit: 1, WindowSeconds: 1},
+		"bad route":    {Name: "n", Route: "x", Limit: 1, WindowSeconds: 1},
+		"bad method":   {Name: "n", Route: "/x", Method: "FETCH", Limit: 1, WindowSeconds: 1},
+		"bad key":      {Name: "n", Route: "/x", Key: "session", Limit: 1, WindowSeconds: 1},
+		"no limit":     {Name: "n", Route: "/x", WindowSeconds: 1},
+		"long window":  {Name: "n", Route: "/x", Limit: 1, WindowSeconds: 86401},
+		"empty window": {Name: "n", Route: "/x", Limit: 1},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package receipts tracks whether requesters have seen the replies on their
+// tickets, either by opening the ticket in the portal or by opening a
+// notification email carrying a tracking pixel. Resolved tickets can then be
+// closed automatically once the requester has actually seen the resolution.
+package receipts
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sources of read receipts, as listed in READ_RECEIPTS.
+const (
+	SourcePortal = "portal"
+	SourceEmail  = "email"
+)
+
+// Path is where the API serves tracking pixels.
+const Path = "/api/receipts/"
+
+// Pixel is a transparent 1x1 GIF.
+var Pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Enabled reports whether source is among the configured sources.
+func Enabled(sources []string, source string) bool {
+	for _, s := range sources {
+		if strings.EqualFold(strings.TrimSpace(s), source) {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkSeen records that the requester has seen the ticket as it stood at
+// through, along with the public replies posted up to then.
+func MarkSeen(ctx context.Context, db DB, ticketID string, through time.Time) error {
+	if _, err := db.Exec(ctx, `update tickets set requester_last_seen_at = now(),
+            requester_seen_through = greatest(requester_seen_through, $2)
+        where id = $1`, ticketID, through); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, `update ticket_comments set seen_at = now()
+        where ticket_id = $1 and not is_internal and author_requester_id is null
+          and seen_at is null and created_at <= $2`, ticketID, through)
+	return err
+}
+
+// SeenByRequester marks the ticket seen now when email belongs to its
+// requester. Views by anyone else are ignored.
+func SeenByRequester(ctx context.Context, db DB, ticketID, email string) error {
+	var ok bool
+	err := db.QueryRow(ctx, `select true from tickets t join requesters r on r.id = t.requester_id
+        where t.id = $1 and lower(r.email) = lower($2)`, ticketID, strings.TrimSpace(email)).Scan(&ok)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !ok) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return MarkSeen(ctx, db, ticketID, time.Now())
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
+
+// New stores a receipt for an email about the ticket and returns the URL of
+// its tracking pixel. baseURL is the public address of the helpdesk.
+func New(ctx context.Context, db DB, ticketID, baseURL string) (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if _, err := db.Exec(ctx, `insert into email_receipts (token_hash, ticket_id) values ($1, $2)`, hashToken(token), ticketID); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(baseURL, "/") + Path + token + ".gif", nil
+}
+
+// Open records that the email carrying token was opened. The requester has
+// then seen the ticket as it stood when the email was sent. Unknown tokens
+// are ignored.
+func Open(ctx context.Context, db DB, token string) error {
+	token = strings.TrimSuffix(token, ".gif")
+	var ticketID string
+	var sentAt time.Time
+	err := db.QueryRow(ctx, `update email_receipts set opened_at = coalesce(opened_at, now())
+        where token_hash = $1 returning ticket_id::text, sent_at`, hashToken(token)).Scan(&ticketID, &sentAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return MarkSeen(ctx, db, ticketID, sentAt)
+}
+
+// ResolutionSeen is a SQL expression that is true when the requester of the
+// ticket aliased t has seen it since it was last resolved.
+const ResolutionSeen = `coalesce(t.requester_seen_through >= (select max(h.at) from ticket_status_history h
+            where h.ticket_id = t.id and h.to_status = 'Resolved'), false)`
+
+// Closed is a ticket closed by AutoClose.
+type Closed struct {
+	ID string
+	// Seen reports whether the requester had seen the resolution.
+	Seen bool
+}
+
+// AutoClose closes up to limit tickets that have been resolved for longer
+// than after and whose requester has seen the resolution. Resolutions the
+// requester has not seen are closed once older than unseenAfter; zero leaves
+// them resolved.
+func AutoClose(ctx context.Context, db DB, after, unseenAfter time.Duration, limit int) ([]Closed, error) {
+	rows, err := db.Query(ctx, `with resolved as (
+            select t.id, `+ResolutionSeen+` as seen,
+                (select max(h.at) from ticket_status_history h where h.ticket_id = t.id and h.to_status = 'Resolved') as resolved_at
+            from tickets t where t.status = 'Resolved'
+        ), due as (
+            select id, seen from resolved
+            where (seen and resolved_at < now() - make_interval(secs => $1))
+               or ($2 > 0 and resolved_at < now() - make_interval(secs => $2))
+            order by resolved_at
+            limit $3
+        )
+        update tickets t set status = 'Closed', updated_at = now()
+        from due where t.id = due.id and t.status = 'Resolved'
+        returning t.id::text, due.seen`, after.Seconds(), unseenAfter.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var out []Closed
+	for rows.Next() {
+		var c Closed
+		if err := rows.Scan(&c.ID, &c.Seen); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, c := range out {
+		note := "auto-closed after the requester saw the resolution"
+		if !c.Seen {
+			note = "auto-closed; the requester has not seen the resolution"
+		}
+		if _, err := db.Exec(ctx, `insert into ticket_status_history (ticket_id, from_status, to_status, note) values ($1, 'Resolved', 'Closed', $2)`, c.ID, note); err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
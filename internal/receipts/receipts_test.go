@@ -0,0 +1,131 @@
+package receipts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type row struct{ scan func(dest ...any) error }
+
+func (r row) Scan(dest ...any) error { return r.scan(dest...) }
+
+type rows struct {
+	pgx.Rows
+	data [][]any
+	i    int
+}
+
+func (r *rows) Next() bool { r.i++; return r.i <= len(r.data) }
+func (r *rows) Scan(dest ...any) error {
+	*dest[0].(*string) = r.data[r.i-1][0].(string)
+	*dest[1].(*bool) = r.data[r.i-1][1].(bool)
+	return nil
+}
+func (r *rows) Close()     {}
+func (r *rows) Err() error { return nil }
+
+type fakeDB struct {
+	execs  []string
+	args   [][]any
+	row    func(dest ...any) error
+	closed [][]any
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	db.args = append(db.args, args)
+	return &rows{data: db.closed}, nil
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	db.args = append(db.args, args)
+	return row{func(dest ...any) error {
+		if db.row == nil {
+			return pgx.ErrNoRows
+		}
+		return db.row(dest...)
+	}}
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, sql)
+	db.args = append(db.args, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestEnabled(t *testing.T) {
+	if !Enabled([]string{"portal", " Email "}, SourceEmail) || Enabled([]string{"none"}, SourcePortal) || Enabled(nil, SourcePortal) {
+		t.Fatal("unexpected Enabled result")
+	}
+}
+
+func TestNewAndOpen(t *testing.T) {
+	db := &fakeDB{}
+	url, err := New(context.Background(), db, "t1", "https://help.acme.io/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(url, "https://help.acme.io/api/receipts/") || !strings.HasSuffix(url, ".gif") {
+		t.Fatalf("unexpected pixel URL %s", url)
+	}
+	token := strings.TrimPrefix(url, "https://help.acme.io/api/receipts/")
+	hash := db.args[0][0].(string)
+	if strings.Contains(url, hash) {
+		t.Fatal("the URL must carry the token, not its hash")
+	}
+
+	// Unknown tokens are ignored.
+	db = &fakeDB{}
+	if err := Open(context.Background(), db, "nope.gif"); err != nil || len(db.execs) != 0 {
+		t.Fatalf("unexpected %v %v", err, db.execs)
+	}
+
+	sent := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	db = &fakeDB{row: func(dest ...any) error {
+		*dest[0].(*string) = "t1"
+		*dest[1].(*time.Time) = sent
+		return nil
+	}}
+	if err := Open(context.Background(), db, token); err != nil {
+		t.Fatal(err)
+	}
+	if db.args[0][0] != hash {
+		t.Fatalf("expected the token to be looked up by hash, got %v", db.args[0])
+	}
+	// The requester has seen the ticket as it was when the email was sent.
+	if len(db.execs) != 2 || db.args[1][1] != sent || db.args[2][1] != sent {
+		t.Fatalf("unexpected seen horizon %v", db.args)
+	}
+}
+
+func TestSeenByRequester(t *testing.T) {
+	db := &fakeDB{}
+	if err := SeenByRequester(context.Background(), db, "t1", "someone@else.io"); err != nil || len(db.execs) != 0 {
+		t.Fatalf("expected views by others to be ignored, got %v %v", err, db.execs)
+	}
+	db = &fakeDB{row: func(dest ...any) error { *dest[0].(*bool) = true; return nil }}
+	if err := SeenByRequester(context.Background(), db, "t1", "ann@acme.io"); err != nil || len(db.execs) != 2 {
+		t.Fatalf("expected the ticket marked seen, got %v %v", err, db.execs)
+	}
+	if !strings.Contains(db.execs[1], "not is_internal") {
+		t.Fatalf("internal notes must not be marked seen: %s", db.execs[1])
+	}
+}
+
+func TestAutoClose(t *testing.T) {
+	db := &fakeDB{closed: [][]any{{"t1", true}, {"t2", false}}}
+	closed, err := AutoClose(context.Background(), db, 3*24*time.Hour, 0, 50)
+	if err != nil || len(closed) != 2 || !closed[0].Seen || closed[1].Seen {
+		t.Fatalf("unexpected %+v %v", closed, err)
+	}
+	if db.args[0][0] != float64(3*24*3600) || db.args[0][1] != float64(0) || db.args[0][2] != 50 {
+		t.Fatalf("unexpected query args %v", db.args[0])
+	}
+	if len(db.execs) != 2 || !strings.Contains(db.args[2][1].(string), "not seen") {
+		t.Fatalf("expected a status history row per ticket, got %v", db.args)
+	}
+}
@@ -0,0 +1,103 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields splits a protobuf message into its fields in order.
+func fields(t *testing.T, b []byte) (nums []protowire.Number, vals [][]byte, fixed []uint64, varints []uint64) {
+	t.Helper()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		nums = append(nums, num)
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			vals, b = append(vals, v), b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			fixed, b = append(fixed, v), b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			varints, b = append(varints, v), b[n:]
+		default:
+			t.Fatalf("unexpected wire type %v", typ)
+		}
+	}
+	return nums, vals, fixed, varints
+}
+
+func TestEncode(t *testing.T) {
+	at := time.UnixMilli(1760000000123)
+	b := Encode([]Series{Gauge("helpdesk_open_tickets", 7, "priority", "1", "job", "helpdesk")}, at)
+	_, series, _, _ := fields(t, b)
+	if len(series) != 1 {
+		t.Fatalf("expected one series, got %d", len(series))
+	}
+	nums, parts, _, _ := fields(t, series[0])
+	if len(nums) != 4 || nums[3] != 2 {
+		t.Fatalf("expected three labels and a sample, got fields %v", nums)
+	}
+	var names []string
+	for _, l := range parts[:3] {
+		_, kv, _, _ := fields(t, l)
+		names = append(names, string(kv[0]))
+		if string(kv[0]) == NameLabel && string(kv[1]) != "helpdesk_open_tickets" {
+			t.Fatalf("name = %s", kv[1])
+		}
+	}
+	if names[0] != NameLabel || names[1] != "job" || names[2] != "priority" {
+		t.Fatalf("labels not sorted: %v", names)
+	}
+	_, _, fixed, varints := fields(t, parts[3])
+	if math.Float64frombits(fixed[0]) != 7 || int64(varints[0]) != at.UnixMilli() {
+		t.Fatalf("sample = %v at %v", math.Float64frombits(fixed[0]), varints[0])
+	}
+}
+
+func TestPush(t *testing.T) {
+	status := http.StatusNoContent
+	var got []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		got, _ = io.ReadAll(r.Body)
+		rw.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := Client{URL: srv.URL, Username: "123", Password: "token"}
+	series := []Series{Gauge("helpdesk_sla_at_risk_tickets", 2)}
+	at := time.Now()
+	if err := c.Push(context.Background(), series, at); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("Content-Encoding") != "snappy" || header.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	if user, pass, ok := (&http.Request{Header: header}).BasicAuth(); !ok || user != "123" || pass != "token" {
+		t.Fatalf("expected basic auth, got %v", header)
+	}
+	body, err := snappy.Decode(nil, got)
+	if err != nil || string(body) != string(Encode(series, at)) {
+		t.Fatalf("body does not decode to the write request: %v", err)
+	}
+
+	status = http.StatusBadRequest
+	if err := c.Push(context.Background(), series, at); err == nil {
+		t.Fatal("expected a rejected push to fail")
+	}
+}
@@ -0,0 +1,167 @@
+// Package reports maintains the daily summary tables behind the metrics
+// endpoints. The worker rebuilds them periodically; readers check
+// RefreshedAt and fall back to live queries when the summaries are stale.
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Name identifies the ticket summaries in report_refreshes.
+const Name = "tickets"
+
+// Summary tables. Each is keyed by the tickets' creation day, team and queue.
+const (
+	TableVolume = "report_daily_volume"
+	TableSLA    = "report_sla_daily"
+	TableAgent  = "report_agent_daily"
+)
+
+// TablePercentiles caches time percentiles over every ticket by priority and
+// team. Percentiles do not add up across days, so it has no day key.
+const TablePercentiles = "report_time_percentiles"
+
+// Timed metrics with percentiles.
+const (
+	// MetricFirstResponse is the wall-clock time from a ticket's creation
+	// to the first public comment by an agent, manager or admin.
+	MetricFirstResponse = "first_response"
+	// MetricResolution is the business time on a resolved ticket's SLA
+	// clock, as averaged by the resolution report.
+	MetricResolution = "resolution"
+)
+
+// Response SLA conditions on tickets aliased t with their clock tsc and
+// policy version sp. A ticket counts towards response attainment once its
+// outcome is known: it was answered, or it is past the target unanswered.
+const (
+	ResponseCounted = `sp.response_target_mins > 0 and (t.first_response_at is not null
+            or tsc.response_elapsed_ms > sp.response_target_mins * 60000)`
+	ResponseMet = `sp.response_target_mins > 0 and t.first_response_at is not null
+            and tsc.response_elapsed_ms <= sp.response_target_mins * 60000`
+)
+
+// Metrics lists the timed metrics.
+var Metrics = []string{MetricFirstResponse, MetricResolution}
+
+// samples select one (id, priority, team_id, ms) row per ticket for a
+// metric from tickets aliased t; %s is a further condition on them.
+var samples = map[string]string{
+	MetricFirstResponse: `select t.id, t.priority, t.team_id, extract(epoch from t.first_response_at - t.created_at)::float8 * 1000 as ms
+        from tickets t
+        where t.first_response_at is not null and %s`,
+	MetricResolution: `select t.id, t.priority, t.team_id, tsc.resolution_elapsed_ms::float8 as ms
+        from tickets t
+        join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+        where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0 and %s`,
+}
+
+// SamplesSQL returns the query for metric's samples among the tickets
+// matching where: one row per ticket of its id, priority, team_id and ms.
+func SamplesSQL(metric, where string) string {
+	return fmt.Sprintf(samples[metric], where)
+}
+
+// PercentilesSQL returns the query for metric's p50, p90 and p99 over the
+// tickets matching where: across them all, by priority and, with byTeam, by
+// team and by priority within team. Tickets without a team are left out of
+// the team rows, and an empty set of tickets gives no rows. It selects
+// priority and team_id, null when not broken down by them, then samples,
+// p50_ms, p90_ms and p99_ms, ordered by team and then priority, totals
+// first.
+func PercentilesSQL(metric, where string, byTeam bool) string {
+	team, sets, having := `null::uuid`, `(), (s.priority)`, `count(*) > 0`
+	if byTeam {
+		team = `case when grouping(s.team_id) = 0 then s.team_id end`
+		sets += `, (s.team_id), (s.priority, s.team_id)`
+		having += ` and (grouping(s.team_id) = 1 or s.team_id is not null)`
+	}
+	return `select case when grouping(s.priority) = 0 then s.priority end as priority, ` + team + ` as team_id,
+               count(*)::int as samples,
+               percentile_cont(0.5) within group (order by s.ms) as p50_ms,
+               percentile_cont(0.9) within group (order by s.ms) as p90_ms,
+               percentile_cont(0.99) within group (order by s.ms) as p99_ms
+        from (` + SamplesSQL(metric, where) + `) s
+        group by grouping sets (` + sets + `)
+        having ` + having + `
+        order by 2 nulls first, 1 nulls first`
+}
+
+// Execer is satisfied by pgx pools, transactions and app.DB.
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Querier reads a single row.
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// rebuild lists the statements that replace every summary row. Run them in
+// one transaction so readers never see a partial rebuild.
+var rebuild = []string{
+	`delete from ` + TableVolume,
+	`insert into ` + TableVolume + ` (day, team_id, queue_id, created)
+        select date_trunc('day', t.created_at)::date, t.team_id, t.queue_id, count(*)
+        from tickets t
+        group by 1, 2, 3`,
+	`delete from ` + TableSLA,
+	`insert into ` + TableSLA + ` (day, team_id, queue_id, sla_total, sla_met, resolution_ms_sum, resolution_count,
+            response_total, response_met)
+        select date_trunc('day', t.created_at)::date, t.team_id, t.queue_id,
+               count(sp.id) filter (where t.status = 'Resolved'),
+               count(*) filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms <= sp.resolution_target_mins * 60000),
+               coalesce(sum(tsc.resolution_elapsed_ms) filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0), 0),
+               count(*) filter (where t.status = 'Resolved' and tsc.resolution_elapsed_ms > 0),
+               count(*) filter (where ` + ResponseCounted + `),
+               count(*) filter (where ` + ResponseMet + `)
+        from tickets t
+        join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+        left join sla_policy_versions sp on sp.id = tsc.policy_version_id
+        where t.status = 'Resolved' or (` + ResponseCounted + `)
+        group by 1, 2, 3`,
+	`delete from ` + TableAgent,
+	`insert into ` + TableAgent + ` (day, assignee_id, team_id, queue_id, resolved, resolution_ms_sum, resolution_count)
+        select date_trunc('day', t.created_at)::date, t.assignee_id, t.team_id, t.queue_id,
+               count(*),
+               coalesce(sum(tsc.resolution_elapsed_ms) filter (where tsc.resolution_elapsed_ms > 0), 0),
+               count(tsc.ticket_id) filter (where tsc.resolution_elapsed_ms > 0)
+        from tickets t
+        left join ticket_sla_clocks tsc on tsc.ticket_id = t.id
+        where t.status = 'Resolved' and t.assignee_id is not null
+        group by 1, 2, 3, 4`,
+	`delete from ` + TablePercentiles,
+	`insert into ` + TablePercentiles + ` (metric, priority, team_id, samples, p50_ms, p90_ms, p99_ms)
+        select '` + MetricFirstResponse + `', p.* from (` + PercentilesSQL(MetricFirstResponse, "true", true) + `) p`,
+	`insert into ` + TablePercentiles + ` (metric, priority, team_id, samples, p50_ms, p90_ms, p99_ms)
+        select '` + MetricResolution + `', p.* from (` + PercentilesSQL(MetricResolution, "true", true) + `) p`,
+	`insert into report_refreshes (name, refreshed_at) values ('` + Name + `', now())
+        on conflict (name) do update set refreshed_at = excluded.refreshed_at`,
+}
+
+// Refresh rebuilds the summary tables from the live tickets.
+func Refresh(ctx context.Context, db Execer) error {
+	for _, q := range rebuild {
+		if _, err := db.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshedAt returns when the summaries were last rebuilt, or the zero time
+// if they never have been.
+func RefreshedAt(ctx context.Context, db Querier) (time.Time, error) {
+	var at time.Time
+	err := db.QueryRow(ctx, `select refreshed_at from report_refreshes where name = $1`, Name).Scan(&at)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return at, err
+}
@@ -0,0 +1,99 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type execDB struct {
+	sqls   []string
+	failOn string
+}
+
+func (db *execDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.sqls = append(db.sqls, sql)
+	if db.failOn != "" && strings.Contains(sql, db.failOn) {
+		return pgconn.CommandTag{}, errors.New("boom")
+	}
+	return pgconn.CommandTag{}, nil
+}
+
+func TestRefreshRebuildsEveryTable(t *testing.T) {
+	db := &execDB{}
+	if err := Refresh(context.Background(), db); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	for _, table := range []string{TableVolume, TableSLA, TableAgent, TablePercentiles} {
+		var deleted, inserted bool
+		for _, q := range db.sqls {
+			deleted = deleted || q == "delete from "+table
+			inserted = inserted || strings.HasPrefix(q, "insert into "+table)
+		}
+		if !deleted || !inserted {
+			t.Fatalf("%s not rebuilt: %v", table, db.sqls)
+		}
+	}
+	if last := db.sqls[len(db.sqls)-1]; !strings.Contains(last, "report_refreshes") {
+		t.Fatalf("refresh time must be recorded last, got %q", last)
+	}
+}
+
+func TestRefreshStopsOnError(t *testing.T) {
+	db := &execDB{failOn: "insert into " + TableSLA}
+	if err := Refresh(context.Background(), db); err == nil {
+		t.Fatal("expected error")
+	}
+	for _, q := range db.sqls {
+		if strings.Contains(q, "report_refreshes") {
+			t.Fatal("refresh time recorded after a failed rebuild")
+		}
+	}
+}
+
+type row struct {
+	at  time.Time
+	err error
+}
+
+func (r row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*time.Time) = r.at
+	return nil
+}
+
+type rowDB struct{ r row }
+
+func (db rowDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row { return db.r }
+
+func TestRefreshedAt(t *testing.T) {
+	at, err := RefreshedAt(context.Background(), rowDB{row{err: pgx.ErrNoRows}})
+	if err != nil || !at.IsZero() {
+		t.Fatalf("never refreshed: got %v, %v", at, err)
+	}
+	now := time.Now()
+	at, err = RefreshedAt(context.Background(), rowDB{row{at: now}})
+	if err != nil || !at.Equal(now) {
+		t.Fatalf("got %v, %v", at, err)
+	}
+}
+
+func TestPercentilesSQL(t *testing.T) {
+	q := PercentilesSQL(MetricResolution, "t.team_id = $1", false)
+	if !strings.Contains(q, "and t.team_id = $1") || !strings.Contains(q, "percentile_cont(0.99)") {
+		t.Fatalf("unexpected query %s", q)
+	}
+	if !strings.Contains(q, "grouping sets ((), (s.priority))") || strings.Contains(q, "grouping(s.team_id)") {
+		t.Fatalf("team breakdown without byTeam: %s", q)
+	}
+	if q := PercentilesSQL(MetricFirstResponse, "true", true); !strings.Contains(q, "(s.priority, s.team_id)") || !strings.Contains(q, "t.first_response_at - t.created_at") {
+		t.Fatalf("unexpected query %s", q)
+	}
+}
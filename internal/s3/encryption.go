@@ -0,0 +1,76 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/sse"
+)
+
+// ServerSide returns the encryption to request on each object written to
+// a bucket with the Location.Encryption setting enc, or nil when enc is
+// empty. Asking on every write, not only through the bucket's default,
+// makes the store refuse objects it cannot encrypt.
+func ServerSide(enc string) encrypt.ServerSide {
+	if enc == "" {
+		return nil
+	}
+	if key, ok := strings.CutPrefix(enc, EncryptionSSEKMS); ok {
+		// NewSSEKMS only fails to marshal a context.
+		s, _ := encrypt.NewSSEKMS(key, nil)
+		return s
+	}
+	return encrypt.NewSSE()
+}
+
+// EncryptionHeaders returns the headers requesting enc, which a client
+// uploading to a presigned URL must send as signed.
+func EncryptionHeaders(enc string) http.Header {
+	h := http.Header{}
+	if s := ServerSide(enc); s != nil {
+		s.Marshal(h)
+	}
+	return h
+}
+
+// bucketEncryption is the bucket default encryption for enc.
+func bucketEncryption(enc string) *sse.Configuration {
+	if key, ok := strings.CutPrefix(enc, EncryptionSSEKMS); ok {
+		return sse.NewConfigurationSSEKMS(key)
+	}
+	return sse.NewConfigurationSSES3()
+}
+
+// EncryptionReader is the part of *minio.Client that VerifyEncryption uses.
+type EncryptionReader interface {
+	GetBucketEncryption(ctx context.Context, bucketName string) (*sse.Configuration, error)
+}
+
+var _ EncryptionReader = (*minio.Client)(nil)
+
+// VerifyEncryption checks that bucket encrypts new objects by default as
+// enc asks: the same algorithm and, for SSE-KMS, the same key.
+func VerifyEncryption(ctx context.Context, mc EncryptionReader, bucket, enc string) error {
+	cfg, err := mc.GetBucketEncryption(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "ServerSideEncryptionConfigurationNotFoundError" {
+			return fmt.Errorf("bucket %s has no default encryption", bucket)
+		}
+		return fmt.Errorf("get encryption: %w", err)
+	}
+	want := bucketEncryption(enc).Rules[0].Apply
+	for _, r := range cfg.Rules {
+		if !strings.EqualFold(r.Apply.SSEAlgorithm, want.SSEAlgorithm) {
+			continue
+		}
+		// AWS may report the key as an ARN ending in key/<id>.
+		if key := r.Apply.KmsMasterKeyID; key == want.KmsMasterKeyID || strings.HasSuffix(key, "/"+want.KmsMasterKeyID) {
+			return nil
+		}
+	}
+	return fmt.Errorf("bucket %s is not encrypted with %s", bucket, enc)
+}
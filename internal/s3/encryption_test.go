@@ -0,0 +1,65 @@
+package s3
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/sse"
+)
+
+type fakeEncryption struct{ cfg *sse.Configuration }
+
+func (f fakeEncryption) GetBucketEncryption(ctx context.Context, bucket string) (*sse.Configuration, error) {
+	if f.cfg == nil {
+		return nil, minio.ErrorResponse{Code: "ServerSideEncryptionConfigurationNotFoundError"}
+	}
+	return f.cfg, nil
+}
+
+func TestEncryptionHeaders(t *testing.T) {
+	if h := EncryptionHeaders(""); len(h) != 0 {
+		t.Fatalf("headers without encryption: %v", h)
+	}
+	if h := EncryptionHeaders(EncryptionSSES3); h.Get("X-Amz-Server-Side-Encryption") != "AES256" {
+		t.Fatalf("sse-s3 headers = %v", h)
+	}
+	h := EncryptionHeaders(EncryptionSSEKMS + "key-1")
+	if h.Get("X-Amz-Server-Side-Encryption") != "aws:kms" || h.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id") != "key-1" {
+		t.Fatalf("sse-kms headers = %v", h)
+	}
+
+	svc := Service{Client: newClient(t), Bucket: "bucket", MaxTTL: time.Minute, Encryption: EncryptionSSEKMS + "key-1"}
+	u, err := svc.PresignPut(context.Background(), "k", "text/plain", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uu, _ := url.Parse(u)
+	if signed := uu.Query().Get("X-Amz-SignedHeaders"); !strings.Contains(signed, "x-amz-server-side-encryption-aws-kms-key-id") {
+		t.Fatalf("encryption headers not signed: %s", signed)
+	}
+}
+
+func TestVerifyEncryption(t *testing.T) {
+	ctx := context.Background()
+	if err := VerifyEncryption(ctx, fakeEncryption{}, "b", EncryptionSSES3); err == nil {
+		t.Fatal("expected a bucket without default encryption to fail")
+	}
+	s3 := fakeEncryption{sse.NewConfigurationSSES3()}
+	if err := VerifyEncryption(ctx, s3, "b", EncryptionSSES3); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEncryption(ctx, s3, "b", EncryptionSSEKMS+"key-1"); err == nil {
+		t.Fatal("expected sse-s3 to fail a bucket set for sse-kms")
+	}
+	arn := fakeEncryption{sse.NewConfigurationSSEKMS("arn:aws:kms:eu-west-1:111122223333:key/key-1")}
+	if err := VerifyEncryption(ctx, arn, "b", EncryptionSSEKMS+"key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEncryption(ctx, arn, "b", EncryptionSSEKMS+"key-2"); err == nil {
+		t.Fatal("expected another key to fail")
+	}
+}
@@ -0,0 +1,241 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Class is a kind of stored data. Each class has its own location,
+// retention and encryption.
+type Class string
+
+const (
+	// ClassAttachments are ticket, asset and email attachments. They are
+	// kept in the default bucket (MINIO_BUCKET) at its root, since their
+	// keys appear in upload URLs.
+	ClassAttachments Class = "attachments"
+	// ClassExports are ticket exports, archives and export bundles.
+	ClassExports Class = "exports"
+	// ClassAudit are the worker's audit log archives, written only while
+	// the class has a bucket set. The worker deletes them itself once past
+	// their retention, so they may share a bucket root with other classes.
+	ClassAudit Class = "audit"
+	// ClassAvatars are user profile photos.
+	ClassAvatars Class = "avatars"
+)
+
+// Classes lists every class.
+var Classes = []Class{ClassAttachments, ClassExports, ClassAudit, ClassAvatars}
+
+// Encryption settings: SSE-S3, or SSE-KMS with a key ID after the colon.
+const (
+	EncryptionSSES3  = "sse-s3"
+	EncryptionSSEKMS = "sse-kms:"
+)
+
+// Location is where a class is stored.
+type Location struct {
+	// Bucket is empty for the default bucket.
+	Bucket string `json:"bucket,omitempty"`
+	// Prefix is prepended to new object keys; it ends in "/" when set.
+	Prefix string `json:"prefix,omitempty"`
+	// RetentionDays expires objects this long after they were written;
+	// 0 keeps them.
+	RetentionDays int `json:"retention_days,omitempty"`
+	// Encryption is the bucket's default encryption: "", EncryptionSSES3
+	// or EncryptionSSEKMS followed by the key ID.
+	Encryption string `json:"encryption,omitempty"`
+}
+
+// Key returns the object key for name in l.
+func (l Location) Key(name string) string {
+	return l.Prefix + name
+}
+
+// Layout maps classes to locations; classes without an entry use the
+// default bucket at its root. A nil Layout is DefaultLayout.
+type Layout map[Class]Location
+
+// DefaultLayout keeps exports under ExportPrefix for a week and audit
+// archives for 30 days.
+func DefaultLayout() Layout {
+	return Layout{
+		ClassExports: {Prefix: ExportPrefix, RetentionDays: 7},
+		ClassAudit:   {RetentionDays: 30},
+	}
+}
+
+// Location returns where c is stored, with the bucket filled in from
+// defaultBucket when c has none.
+func (l Layout) Location(c Class, defaultBucket string) Location {
+	if l == nil {
+		l = DefaultLayout()
+	}
+	loc := l[c]
+	if loc.Bucket == "" {
+		loc.Bucket = defaultBucket
+	}
+	return loc
+}
+
+// ParseLayout reads the layout from MINIO_<CLASS>_BUCKET, _PREFIX,
+// _RETENTION_DAYS and _ENCRYPTION through getenv, starting from
+// DefaultLayout. MINIO_ENCRYPTION is the encryption of classes without
+// their own. Attachments take no bucket or prefix. The audit class
+// falls back to AUDIT_EXPORT_BUCKET, AUDIT_EXPORT_PREFIX and
+// AUDIT_EXPORT_RETENTION_DAYS.
+func ParseLayout(getenv func(string) string, defaultBucket string) (Layout, error) {
+	l := DefaultLayout()
+	for _, c := range Classes {
+		loc := l[c]
+		env := "MINIO_" + strings.ToUpper(string(c)) + "_"
+		get := func(name, legacy string) (string, bool) {
+			if v := strings.TrimSpace(getenv(env + name)); v != "" {
+				return v, true
+			}
+			if legacy != "" {
+				if v := strings.TrimSpace(getenv(legacy)); v != "" {
+					return v, true
+				}
+			}
+			return "", false
+		}
+		var legacyBucket, legacyPrefix, legacyRetention string
+		if c == ClassAudit {
+			legacyBucket, legacyPrefix, legacyRetention = "AUDIT_EXPORT_BUCKET", "AUDIT_EXPORT_PREFIX", "AUDIT_EXPORT_RETENTION_DAYS"
+		}
+		if c != ClassAttachments {
+			if v, ok := get("BUCKET", legacyBucket); ok {
+				loc.Bucket = v
+			}
+			if v, ok := get("PREFIX", legacyPrefix); ok {
+				loc.Prefix = strings.Trim(v, "/") + "/"
+			}
+		}
+		if v, ok := get("RETENTION_DAYS", legacyRetention); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%sRETENTION_DAYS: want a number of days", env)
+			}
+			loc.RetentionDays = n
+		}
+		if v, ok := get("ENCRYPTION", "MINIO_ENCRYPTION"); ok {
+			if v != EncryptionSSES3 && (!strings.HasPrefix(v, EncryptionSSEKMS) || v == EncryptionSSEKMS) {
+				return nil, fmt.Errorf("%sENCRYPTION: want %s or %s<key id>", env, EncryptionSSES3, EncryptionSSEKMS)
+			}
+			loc.Encryption = v
+		}
+		l[c] = loc
+	}
+	return l, l.Validate(defaultBucket)
+}
+
+// Validate rejects layouts whose policies collide: classes sharing a
+// bucket cannot ask for different encryption (the bucket's default covers
+// them all), and a class kept at a bucket's root cannot expire objects
+// there while other classes share the bucket.
+func (l Layout) Validate(defaultBucket string) error {
+	byBucket := map[string][]Class{}
+	for _, c := range Classes {
+		if c == ClassAudit && l[c].Bucket == "" {
+			continue
+		}
+		b := l.Location(c, defaultBucket).Bucket
+		byBucket[b] = append(byBucket[b], c)
+	}
+	for b, classes := range byBucket {
+		var enc Class
+		for _, c := range classes {
+			if l[c].Encryption == "" {
+				continue
+			}
+			if enc != "" && l[c].Encryption != l[enc].Encryption {
+				return fmt.Errorf("bucket %q: %s and %s set different encryption", b, enc, c)
+			}
+			enc = c
+		}
+		if len(classes) == 1 {
+			continue
+		}
+		for _, c := range classes {
+			if loc := l[c]; c != ClassAudit && loc.RetentionDays > 0 && loc.Prefix == "" {
+				return fmt.Errorf("bucket %q: %s retention needs a prefix or a bucket of its own", b, c)
+			}
+		}
+	}
+	return nil
+}
+
+// Policies returns the setup for each bucket in the layout, adding the
+// classes' retention and encryption to base. base.Lifecycle applies to the
+// default bucket only. Audit retention is left to the worker.
+func (l Layout) Policies(defaultBucket string, base Policy) map[string]Policy {
+	if l == nil {
+		l = DefaultLayout()
+	}
+	out := map[string]Policy{defaultBucket: {Region: base.Region, Versioning: base.Versioning, Lifecycle: append([]ExpiryRule(nil), base.Lifecycle...)}}
+	for _, c := range Classes {
+		if c == ClassAudit && l[c].Bucket == "" {
+			continue
+		}
+		loc := l.Location(c, defaultBucket)
+		p, ok := out[loc.Bucket]
+		if !ok {
+			p = Policy{Region: base.Region, Versioning: base.Versioning}
+		}
+		if loc.RetentionDays > 0 && c != ClassAudit {
+			p.Lifecycle = append(p.Lifecycle, ExpiryRule{Prefix: loc.Prefix, Days: loc.RetentionDays})
+		}
+		if loc.Encryption != "" {
+			p.Encryption = loc.Encryption
+		}
+		out[loc.Bucket] = p
+	}
+	return out
+}
+
+// Encryption maps each of the layout's buckets that is encrypted to its
+// Location.Encryption setting.
+func (l Layout) Encryption(defaultBucket string) map[string]string {
+	out := map[string]string{}
+	for b, p := range l.Policies(defaultBucket, Policy{}) {
+		if p.Encryption != "" {
+			out[b] = p.Encryption
+		}
+	}
+	return out
+}
+
+// Buckets lists the layout's buckets, the default first.
+func (l Layout) Buckets(defaultBucket string) []string {
+	var others []string
+	for b := range l.Policies(defaultBucket, Policy{}) {
+		if b != defaultBucket {
+			others = append(others, b)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{defaultBucket}, others...)
+}
+
+// ProvisionLayout provisions every bucket in l with its policy from
+// Policies. A bucket that fails does not stop the others; their errors are
+// joined.
+func ProvisionLayout(ctx context.Context, mc BucketAdmin, l Layout, defaultBucket string, base Policy) ([]Provisioned, error) {
+	policies := l.Policies(defaultBucket, base)
+	out := []Provisioned{}
+	var errs []error
+	for _, b := range l.Buckets(defaultBucket) {
+		p, err := Provision(ctx, mc, b, policies[b])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bucket %s: %w", b, err))
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package s3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	l, err := ParseLayout(env(nil), "attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loc := l.Location(ClassExports, "attachments"); loc != (Location{Bucket: "attachments", Prefix: "exports/", RetentionDays: 7}) {
+		t.Fatalf("default exports = %+v", loc)
+	}
+	if l[ClassAudit].Bucket != "" {
+		t.Fatal("audit archives enabled without a bucket")
+	}
+
+	l, err = ParseLayout(env(map[string]string{
+		"MINIO_EXPORTS_BUCKET":        "exports",
+		"MINIO_EXPORTS_PREFIX":        "/tickets",
+		"MINIO_AVATARS_BUCKET":        "avatars",
+		"MINIO_AVATARS_ENCRYPTION":    "sse-kms:key-1",
+		"AUDIT_EXPORT_BUCKET":         "audit",
+		"AUDIT_EXPORT_RETENTION_DAYS": "90",
+		"MINIO_ATTACHMENTS_BUCKET":    "ignored",
+	}), "attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Layout{
+		ClassAttachments: {},
+		ClassExports:     {Bucket: "exports", Prefix: "tickets/", RetentionDays: 7},
+		ClassAudit:       {Bucket: "audit", RetentionDays: 90},
+		ClassAvatars:     {Bucket: "avatars", Encryption: "sse-kms:key-1"},
+	}
+	if !reflect.DeepEqual(l, want) {
+		t.Fatalf("layout = %+v", l)
+	}
+	if got := l.Buckets("attachments"); !reflect.DeepEqual(got, []string{"attachments", "audit", "avatars", "exports"}) {
+		t.Fatalf("buckets = %v", got)
+	}
+	p := l.Policies("attachments", Policy{Versioning: true, Lifecycle: []ExpiryRule{{Prefix: "tmp/", Days: 1}}})
+	if len(p["attachments"].Lifecycle) != 1 || len(p["audit"].Lifecycle) != 0 || p["avatars"].Encryption != "sse-kms:key-1" || !p["exports"].Versioning {
+		t.Fatalf("policies = %+v", p)
+	}
+	if r := p["exports"].Lifecycle; len(r) != 1 || r[0] != (ExpiryRule{Prefix: "tickets/", Days: 7}) {
+		t.Fatalf("exports lifecycle = %+v", r)
+	}
+
+	for name, vars := range map[string]map[string]string{
+		"bad retention":          {"MINIO_EXPORTS_RETENTION_DAYS": "soon"},
+		"bad encryption":         {"MINIO_AVATARS_ENCRYPTION": "aes"},
+		"kms without key":        {"MINIO_AVATARS_ENCRYPTION": "sse-kms:"},
+		"shared bucket, two SSE": {"MINIO_AVATARS_ENCRYPTION": "sse-s3", "MINIO_ATTACHMENTS_ENCRYPTION": "sse-kms:k"},
+		"root retention shared":  {"MINIO_AVATARS_RETENTION_DAYS": "30"},
+	} {
+		if _, err := ParseLayout(env(vars), "attachments"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := ParseLayout(env(map[string]string{"MINIO_ATTACHMENTS_ENCRYPTION": "sse-s3"}), "attachments"); err != nil {
+		t.Fatalf("encrypting a shared bucket: %v", err)
+	}
+	l, err = ParseLayout(env(map[string]string{"MINIO_ENCRYPTION": "sse-s3", "MINIO_EXPORTS_BUCKET": "exports", "MINIO_EXPORTS_ENCRYPTION": "sse-kms:k"}), "attachments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.Encryption("attachments"); !reflect.DeepEqual(got, map[string]string{"attachments": "sse-s3", "exports": "sse-kms:k"}) {
+		t.Fatalf("encryption = %v", got)
+	}
+	// Alone in its bucket, a class may expire everything in it.
+	if _, err := ParseLayout(env(map[string]string{"MINIO_AVATARS_BUCKET": "avatars", "MINIO_AVATARS_RETENTION_DAYS": "30"}), "attachments"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -15,6 +15,9 @@ type Service struct {
 	Bucket string
 	// MaxTTL limits the lifetime of generated URLs.
 	MaxTTL time.Duration
+	// Encryption is requested on uploads, as in Location.Encryption; the
+	// uploader sends EncryptionHeaders(Encryption) with the request.
+	Encryption string
 }
 
 // PresignPut creates a short-lived URL for uploading an object.
@@ -26,7 +29,7 @@ func (s Service) PresignPut(ctx context.Context, objectKey, contentType string,
 		contentType = "application/octet-stream"
 	}
 	// Use Presign to allow including the Content-Type in the signature
-	u, err := s.Client.Presign(ctx, "PUT", s.Bucket, objectKey, ttl, url.Values{"Content-Type": []string{contentType}})
+	u, err := s.Client.PresignHeader(ctx, "PUT", s.Bucket, objectKey, ttl, url.Values{"Content-Type": []string{contentType}}, EncryptionHeaders(s.Encryption))
 	if err != nil {
 		return "", err
 	}
@@ -48,3 +51,26 @@ func (s Service) PresignGet(ctx context.Context, objectKey, filename string, ttl
 	}
 	return u.String(), nil
 }
+
+// PresignDownload creates a short-lived URL for downloading an untrusted
+// file. Unlike PresignGet the response is always an attachment, even without
+// a filename, and is served as contentType whatever the object was stored as.
+func (s Service) PresignDownload(ctx context.Context, objectKey, filename, contentType string, ttl time.Duration) (string, error) {
+	if ttl <= 0 || ttl > s.MaxTTL {
+		return "", fmt.Errorf("invalid ttl")
+	}
+	disposition := "attachment"
+	if filename != "" {
+		disposition += "; filename=\"" + filename + "\""
+	}
+	vals := url.Values{}
+	vals.Set("response-content-disposition", disposition)
+	if contentType != "" {
+		vals.Set("response-content-type", contentType)
+	}
+	u, err := s.Client.PresignedGetObject(ctx, s.Bucket, objectKey, ttl, vals)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
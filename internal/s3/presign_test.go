@@ -54,3 +54,21 @@ func TestPresignGetDisposition(t *testing.T) {
 		t.Fatalf("unexpected content-disposition %s", cd)
 	}
 }
+
+func TestPresignDownloadOverrides(t *testing.T) {
+	svc := Service{Client: newClient(t), Bucket: "bucket", MaxTTL: time.Minute}
+	u, err := svc.PresignDownload(context.Background(), "k", "", "application/octet-stream", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uu, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cd := uu.Query().Get("response-content-disposition"); cd != "attachment" {
+		t.Fatalf("unexpected content-disposition %s", cd)
+	}
+	if ct := uu.Query().Get("response-content-type"); ct != "application/octet-stream" {
+		t.Fatalf("unexpected content-type %s", ct)
+	}
+}
@@ -0,0 +1,157 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/minio/minio-go/v7/pkg/sse"
+)
+
+// Object key prefixes for short-lived objects, so lifecycle rules can
+// expire them without touching attachments.
+const (
+	ExportPrefix = "exports/"
+	TempPrefix   = "tmp/"
+)
+
+// DefaultLifecycle expires temporary objects after a day. Exports expire
+// through their class's retention (see Layout).
+const DefaultLifecycle = TempPrefix + "=1"
+
+// ruleIDPrefix marks the lifecycle rules Provision manages; rules with
+// other IDs are left alone.
+const ruleIDPrefix = "helpdesk-expire-"
+
+// ExpiryRule expires objects under Prefix Days after they were written.
+type ExpiryRule struct {
+	Prefix string `json:"prefix"`
+	Days   int    `json:"days"`
+}
+
+// ParseLifecycle parses comma-separated "prefix=days" pairs, such as
+// DefaultLifecycle. An empty string means no rules.
+func ParseLifecycle(s string) ([]ExpiryRule, error) {
+	var rules []ExpiryRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		prefix, days, ok := strings.Cut(part, "=")
+		prefix = strings.TrimSpace(prefix)
+		n, err := strconv.Atoi(strings.TrimSpace(days))
+		if !ok || prefix == "" || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid lifecycle rule %q: want prefix=days", part)
+		}
+		rules = append(rules, ExpiryRule{Prefix: prefix, Days: n})
+	}
+	return rules, nil
+}
+
+// Policy is the bucket setup Provision applies.
+type Policy struct {
+	// Region is used when creating the bucket.
+	Region string
+	// Versioning enables object versioning. Provision never suspends it.
+	Versioning bool
+	Lifecycle  []ExpiryRule
+	// Encryption sets the bucket's default encryption, as in
+	// Location.Encryption. Provision never removes it.
+	Encryption string
+}
+
+// BucketAdmin is the part of *minio.Client that Provision uses.
+type BucketAdmin interface {
+	BucketExists(ctx context.Context, bucketName string) (bool, error)
+	MakeBucket(ctx context.Context, bucketName string, opts minio.MakeBucketOptions) error
+	EnableVersioning(ctx context.Context, bucketName string) error
+	GetBucketLifecycle(ctx context.Context, bucketName string) (*lifecycle.Configuration, error)
+	SetBucketLifecycle(ctx context.Context, bucketName string, config *lifecycle.Configuration) error
+	SetBucketEncryption(ctx context.Context, bucketName string, config *sse.Configuration) error
+}
+
+var _ BucketAdmin = (*minio.Client)(nil)
+
+// Provisioned reports what Provision found and did.
+type Provisioned struct {
+	Bucket     string       `json:"bucket"`
+	Created    bool         `json:"created"`
+	Versioning bool         `json:"versioning"`
+	Lifecycle  []ExpiryRule `json:"lifecycle"`
+	Encryption string       `json:"encryption,omitempty"`
+}
+
+// Provision creates bucket if it is missing and applies p to it. The
+// lifecycle rules Provision manages are replaced with p.Lifecycle; rules
+// added to the bucket by other means are kept.
+func Provision(ctx context.Context, mc BucketAdmin, bucket string, p Policy) (Provisioned, error) {
+	out := Provisioned{Bucket: bucket, Lifecycle: []ExpiryRule{}}
+	exists, err := mc.BucketExists(ctx, bucket)
+	if err != nil {
+		return out, fmt.Errorf("check bucket: %w", err)
+	}
+	if !exists {
+		if err := mc.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: p.Region}); err != nil {
+			// Another replica may have created it first.
+			if code := minio.ToErrorResponse(err).Code; code != "BucketAlreadyOwnedByYou" && code != "BucketAlreadyExists" {
+				return out, fmt.Errorf("create bucket: %w", err)
+			}
+		} else {
+			out.Created = true
+		}
+	}
+	if p.Versioning {
+		if err := mc.EnableVersioning(ctx, bucket); err != nil {
+			return out, fmt.Errorf("enable versioning: %w", err)
+		}
+		out.Versioning = true
+	}
+	if p.Encryption != "" {
+		if err := mc.SetBucketEncryption(ctx, bucket, bucketEncryption(p.Encryption)); err != nil {
+			return out, fmt.Errorf("set encryption: %w", err)
+		}
+		out.Encryption = p.Encryption
+	}
+
+	current, err := mc.GetBucketLifecycle(ctx, bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return out, fmt.Errorf("get lifecycle: %w", err)
+		}
+		current = nil
+	}
+	cfg := lifecycle.NewConfiguration()
+	if current != nil {
+		for _, r := range current.Rules {
+			if !strings.HasPrefix(r.ID, ruleIDPrefix) {
+				cfg.Rules = append(cfg.Rules, r)
+			}
+		}
+	}
+	for _, e := range p.Lifecycle {
+		days := lifecycle.ExpirationDays(e.Days)
+		id := strings.Trim(e.Prefix, "/")
+		if id == "" {
+			id = "all"
+		}
+		cfg.Rules = append(cfg.Rules, lifecycle.Rule{
+			ID:                          ruleIDPrefix + id,
+			Status:                      "Enabled",
+			RuleFilter:                  lifecycle.Filter{Prefix: e.Prefix},
+			Expiration:                  lifecycle.Expiration{Days: days},
+			NoncurrentVersionExpiration: lifecycle.NoncurrentVersionExpiration{NoncurrentDays: days},
+		})
+		out.Lifecycle = append(out.Lifecycle, e)
+	}
+	if current == nil && len(cfg.Rules) == 0 {
+		return out, nil
+	}
+	if err := mc.SetBucketLifecycle(ctx, bucket, cfg); err != nil {
+		return out, fmt.Errorf("set lifecycle: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package s3
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/minio/minio-go/v7/pkg/sse"
+)
+
+type fakeBucket struct {
+	exists     bool
+	versioning bool
+	lifecycle  *lifecycle.Configuration
+	made       string
+	encryption *sse.Configuration
+}
+
+func (f *fakeBucket) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	return f.exists, nil
+}
+
+func (f *fakeBucket) MakeBucket(ctx context.Context, bucket string, opts minio.MakeBucketOptions) error {
+	f.exists, f.made = true, opts.Region
+	return nil
+}
+
+func (f *fakeBucket) EnableVersioning(ctx context.Context, bucket string) error {
+	f.versioning = true
+	return nil
+}
+
+func (f *fakeBucket) GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error) {
+	if f.lifecycle == nil {
+		return nil, minio.ErrorResponse{Code: "NoSuchLifecycleConfiguration"}
+	}
+	return f.lifecycle, nil
+}
+
+func (f *fakeBucket) SetBucketLifecycle(ctx context.Context, bucket string, cfg *lifecycle.Configuration) error {
+	f.lifecycle = cfg
+	return nil
+}
+
+func (f *fakeBucket) SetBucketEncryption(ctx context.Context, bucket string, cfg *sse.Configuration) error {
+	f.encryption = cfg
+	return nil
+}
+
+func TestParseLifecycle(t *testing.T) {
+	rules, err := ParseLifecycle("exports/=7," + DefaultLifecycle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ExpiryRule{{Prefix: "exports/", Days: 7}, {Prefix: "tmp/", Days: 1}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if rules, err := ParseLifecycle(""); err != nil || rules != nil {
+		t.Fatalf("empty: %v, %v", rules, err)
+	}
+	for _, bad := range []string{"exports/", "=7", "exports/=0", "exports/=x"} {
+		if _, err := ParseLifecycle(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestProvision(t *testing.T) {
+	ctx := context.Background()
+	f := &fakeBucket{}
+	p := Policy{Region: "us-east-1", Versioning: true, Lifecycle: []ExpiryRule{{Prefix: "exports/", Days: 7}}, Encryption: "sse-kms:k1"}
+	out, err := Provision(ctx, f, "attachments", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Created || f.made != "us-east-1" || !out.Versioning || !f.versioning || f.encryption == nil || f.encryption.Rules[0].Apply.KmsMasterKeyID != "k1" {
+		t.Fatalf("out = %+v, fake = %+v", out, f)
+	}
+	if len(f.lifecycle.Rules) != 1 || f.lifecycle.Rules[0].ID != "helpdesk-expire-exports" || f.lifecycle.Rules[0].Expiration.Days != 7 {
+		t.Fatalf("rules = %+v", f.lifecycle.Rules)
+	}
+
+	// Rerunning replaces the managed rules and keeps the operator's own.
+	f.lifecycle.Rules = append(f.lifecycle.Rules, lifecycle.Rule{ID: "ops-logs", Status: "Enabled"})
+	p.Lifecycle = []ExpiryRule{{Prefix: "tmp/", Days: 1}}
+	out, err = Provision(ctx, f, "attachments", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Created {
+		t.Fatal("existing bucket reported as created")
+	}
+	var ids []string
+	for _, r := range f.lifecycle.Rules {
+		ids = append(ids, r.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"ops-logs", "helpdesk-expire-tmp"}) {
+		t.Fatalf("rule ids = %v", ids)
+	}
+}
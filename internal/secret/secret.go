@@ -0,0 +1,27 @@
+// Package secret issues the bearer secrets handed out for wallboards, guest
+// links and chat widgets and hashes them for storage. Only the hash is kept;
+// the raw secret is shown once when it is created.
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// New returns 32 random bytes, hex encoded, after prefix. The prefix names
+// the kind of secret so a leaked one is easy to recognize.
+func New(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// Hash returns the hex SHA-256 of raw, the form secrets are stored and
+// looked up in.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,26 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	a, err := New("wb_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := New("wb_")
+	if !strings.HasPrefix(a, "wb_") || len(a) != len("wb_")+64 || a == b {
+		t.Fatalf("unexpected secrets %q, %q", a, b)
+	}
+}
+
+func TestHash(t *testing.T) {
+	if got := Hash("abc"); got != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("Hash = %s", got)
+	}
+	if Hash("wb_1") == Hash("wb_2") {
+		t.Fatal("distinct secrets share a hash")
+	}
+}
@@ -0,0 +1,102 @@
+// Package sender picks who notification emails appear to come from. Queues
+// and teams can each set a From name and address, a Reply-To address and a
+// signature; a ticket's queue wins over its team, and anything neither sets
+// falls back to the global SMTP_FROM.
+package sender
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxSignature bounds a signature's length in bytes.
+const MaxSignature = 2000
+
+// Identity is a sender identity. Empty fields are inherited.
+type Identity struct {
+	FromName    string `json:"from_name,omitempty"`
+	FromAddress string `json:"from_address,omitempty"`
+	ReplyTo     string `json:"reply_to,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+}
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Normalize trims id and checks it, returning field errors keyed by JSON
+// name, or nil when it is valid.
+func (id *Identity) Normalize() map[string]string {
+	id.FromName = strings.TrimSpace(id.FromName)
+	id.FromAddress = strings.TrimSpace(id.FromAddress)
+	id.ReplyTo = strings.TrimSpace(id.ReplyTo)
+	id.Signature = strings.TrimSpace(strings.ReplaceAll(id.Signature, "\r\n", "\n"))
+	errs := map[string]string{}
+	if strings.ContainsAny(id.FromName, "\r\n") || len(id.FromName) > 100 {
+		errs["from_name"] = "must be a single line of at most 100 characters"
+	}
+	for field, addr := range map[string]string{"from_address": id.FromAddress, "reply_to": id.ReplyTo} {
+		if addr == "" {
+			continue
+		}
+		if a, err := mail.ParseAddress(addr); err != nil || a.Address != addr {
+			errs[field] = "must be a bare email address"
+		}
+	}
+	if len(id.Signature) > MaxSignature {
+		errs["signature"] = "must be at most 2000 characters"
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Or fills id's empty fields from fallback.
+func (id Identity) Or(fallback Identity) Identity {
+	pick := func(v, f string) string {
+		if v != "" {
+			return v
+		}
+		return f
+	}
+	return Identity{
+		FromName:    pick(id.FromName, fallback.FromName),
+		FromAddress: pick(id.FromAddress, fallback.FromAddress),
+		ReplyTo:     pick(id.ReplyTo, fallback.ReplyTo),
+		Signature:   pick(id.Signature, fallback.Signature),
+	}
+}
+
+// FromHeader formats a From header value for address under id's name. The
+// name is encoded as needed; without one the bare address is used.
+func (id Identity) FromHeader(address string) string {
+	name := strings.TrimSpace(strings.NewReplacer("\r", "", "\n", "").Replace(id.FromName))
+	if name == "" {
+		return address
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+// ForTicket returns the identity for mail about a ticket: its queue's,
+// completed by its team's. A missing ticket has the empty identity.
+func ForTicket(ctx context.Context, db DB, ticketID string) (Identity, error) {
+	var queue, team Identity
+	err := db.QueryRow(ctx, `select coalesce(q.email_identity, '{}'::jsonb), coalesce(tm.email_identity, '{}'::jsonb)
+        from tickets t
+        left join queues q on q.id = t.queue_id
+        left join teams tm on tm.id = t.team_id
+        where t.id::text = $1`, ticketID).Scan(&queue, &team)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Identity{}, nil
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	return queue.Or(team), nil
+}
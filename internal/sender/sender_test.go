@@ -0,0 +1,76 @@
+package sender
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type row struct{ scan func(dest ...any) error }
+
+func (r row) Scan(dest ...any) error { return r.scan(dest...) }
+
+type db struct {
+	queue, team string
+	missing     bool
+}
+
+func (d db) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return row{func(dest ...any) error {
+		if d.missing {
+			return pgx.ErrNoRows
+		}
+		if err := json.Unmarshal([]byte(d.queue), dest[0]); err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(d.team), dest[1])
+	}}
+}
+
+func TestForTicketQueueOverridesTeam(t *testing.T) {
+	id, err := ForTicket(context.Background(), db{
+		queue: `{"from_name":"Acme Billing","reply_to":"billing@acme.example"}`,
+		team:  `{"from_name":"Support","from_address":"support@acme.example","signature":"The Support Team"}`,
+	}, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{FromName: "Acme Billing", FromAddress: "support@acme.example", ReplyTo: "billing@acme.example", Signature: "The Support Team"}
+	if id != want {
+		t.Fatalf("got %+v, want %+v", id, want)
+	}
+	if id, err := ForTicket(context.Background(), db{missing: true}, "t2"); err != nil || id != (Identity{}) {
+		t.Fatalf("expected the empty identity for a missing ticket, got %+v %v", id, err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	id := Identity{FromName: " Acme ", ReplyTo: "help@acme.example", Signature: "Thanks\r\nAcme\r\n"}
+	if errs := id.Normalize(); errs != nil {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if id.FromName != "Acme" || id.Signature != "Thanks\nAcme" {
+		t.Fatalf("not normalized: %+v", id)
+	}
+	bad := Identity{FromName: "Acme\r\nBcc: x@evil.example", FromAddress: "Acme <a@acme.example>", ReplyTo: "nope"}
+	errs := bad.Normalize()
+	for _, f := range []string{"from_name", "from_address", "reply_to"} {
+		if errs[f] == "" {
+			t.Fatalf("expected an error for %s, got %v", f, errs)
+		}
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	if got := (Identity{}).FromHeader("help@acme.example"); got != "help@acme.example" {
+		t.Fatalf("got %q", got)
+	}
+	if got := (Identity{FromName: "Acme Support"}).FromHeader("help@acme.example"); got != `"Acme Support" <help@acme.example>` {
+		t.Fatalf("got %q", got)
+	}
+	if got := (Identity{FromName: "Åcme"}).FromHeader("help@acme.example"); got != "=?utf-8?q?=C3=85cme?= <help@acme.example>" {
+		t.Fatalf("non-ASCII names must be encoded, got %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package sla
+
+import "time"
+
+// Reasons an instant falls outside business time.
+const (
+	BlackoutHoliday      = "holiday"
+	BlackoutClosure      = "closure"
+	BlackoutOutsideHours = "outside_hours"
+)
+
+// Blackout describes the closed period around an instant.
+type Blackout struct {
+	// Reason is BlackoutHoliday, BlackoutClosure or BlackoutOutsideHours.
+	Reason string
+	Label  string
+	Start  time.Time
+	End    time.Time
+}
+
+// BlackoutAt reports why t is outside business time, or nil when the
+// calendar is open at t. Holidays and exceptions take precedence over
+// regular hours. Start and End bound holidays and exceptions; they are zero
+// for BlackoutOutsideHours.
+func (c *Calendar) BlackoutAt(t time.Time) *Blackout {
+	day := c.dayStart(t)
+	if _, ok := c.Holidays[day]; ok {
+		return &Blackout{Reason: BlackoutHoliday, Start: day, End: day.AddDate(0, 0, 1)}
+	}
+	for _, ex := range c.Exceptions {
+		if !t.Before(ex.Start) && t.Before(ex.End) {
+			reason := BlackoutClosure
+			if ex.Kind == BlackoutHoliday {
+				reason = BlackoutHoliday
+			}
+			return &Blackout{Reason: reason, Label: ex.Label, Start: ex.Start, End: ex.End}
+		}
+	}
+	for _, w := range c.windows(day) {
+		if !t.Before(w.start) && t.Before(w.end) {
+			return nil
+		}
+	}
+	return &Blackout{Reason: BlackoutOutsideHours}
+}
+
+// NextBusinessTime returns t when the calendar is open at t, otherwise the
+// start of the next business window. The zero time is returned when the
+// calendar has no business hours within reach.
+func (c *Calendar) NextBusinessTime(t time.Time) time.Time {
+	day := c.dayStart(t)
+	for i := 0; i < maxScanDays; i++ {
+		for _, w := range c.windows(day) {
+			if !w.end.After(t) {
+				continue
+			}
+			return maxTime(t, w.start)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,61 @@
+package sla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlackoutAt(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	cal.Holidays[time.Date(2024, 7, 4, 0, 0, 0, 0, loc)] = struct{}{}
+	cal.Exceptions = []Exception{{
+		Start: time.Date(2024, 7, 1, 13, 0, 0, 0, loc),
+		End:   time.Date(2024, 7, 1, 15, 0, 0, 0, loc),
+		Kind:  "closure",
+		Label: "Office move",
+	}}
+	cases := []struct {
+		at     time.Time
+		reason string
+	}{
+		{time.Date(2024, 7, 1, 10, 0, 0, 0, loc), ""},
+		{time.Date(2024, 7, 1, 14, 0, 0, 0, loc), BlackoutClosure},
+		{time.Date(2024, 7, 1, 15, 0, 0, 0, loc), ""},
+		{time.Date(2024, 7, 1, 18, 0, 0, 0, loc), BlackoutOutsideHours},
+		{time.Date(2024, 7, 4, 10, 0, 0, 0, loc), BlackoutHoliday},
+		{time.Date(2024, 7, 6, 10, 0, 0, 0, loc), BlackoutOutsideHours},
+	}
+	for _, tc := range cases {
+		b := cal.BlackoutAt(tc.at)
+		switch {
+		case tc.reason == "" && b != nil:
+			t.Fatalf("%v: expected open, got %+v", tc.at, b)
+		case tc.reason != "" && (b == nil || b.Reason != tc.reason):
+			t.Fatalf("%v: expected %s, got %+v", tc.at, tc.reason, b)
+		}
+	}
+	if b := cal.BlackoutAt(time.Date(2024, 7, 1, 14, 0, 0, 0, loc)); b.Label != "Office move" {
+		t.Fatalf("expected the exception label, got %+v", b)
+	}
+}
+
+func TestNextBusinessTime(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	cal.Holidays[time.Date(2024, 7, 4, 0, 0, 0, 0, loc)] = struct{}{}
+	cases := []struct{ at, want time.Time }{
+		{time.Date(2024, 7, 1, 10, 0, 0, 0, loc), time.Date(2024, 7, 1, 10, 0, 0, 0, loc)},
+		{time.Date(2024, 7, 1, 7, 0, 0, 0, loc), time.Date(2024, 7, 1, 9, 0, 0, 0, loc)},
+		{time.Date(2024, 7, 3, 18, 0, 0, 0, loc), time.Date(2024, 7, 5, 9, 0, 0, 0, loc)},
+		{time.Date(2024, 7, 6, 12, 0, 0, 0, loc), time.Date(2024, 7, 8, 9, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		if got := cal.NextBusinessTime(tc.at); !got.Equal(tc.want) {
+			t.Fatalf("%v: got %v want %v", tc.at, got, tc.want)
+		}
+	}
+	if got := (&Calendar{Location: loc}).NextBusinessTime(time.Now()); !got.IsZero() {
+		t.Fatalf("expected zero without business hours, got %v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package sla
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Holiday is a public holiday read from a feed.
+type Holiday struct {
+	Date  time.Time `json:"date"`
+	Label string    `json:"label"`
+}
+
+// Feed formats accepted by ParseHolidayFeed.
+const (
+	FeedJSON = "json"
+	FeedICS  = "ics"
+)
+
+// maxFeedDays caps how many days a single multi-day iCalendar event expands to.
+const maxFeedDays = 31
+
+// ParseHolidayFeed reads a public-holiday feed. JSON feeds are arrays of
+// {"date": "2006-01-02", "name"|"localName": "..."} objects, the format
+// served by Nager.Date and most national open-data portals. iCalendar feeds
+// contribute every all-day VEVENT, expanded across its DTEND when present.
+// Dates are returned in UTC, sorted and de-duplicated.
+func ParseHolidayFeed(r io.Reader, format string) ([]Holiday, error) {
+	var out []Holiday
+	var err error
+	switch format {
+	case FeedJSON:
+		out, err = parseJSONFeed(r)
+	case FeedICS:
+		out, err = parseICSFeed(r)
+	default:
+		return nil, fmt.Errorf("unsupported holiday feed format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Date.Before(out[j].Date) })
+	dedup := out[:0]
+	for _, h := range out {
+		if n := len(dedup); n > 0 && dedup[n-1].Date.Equal(h.Date) {
+			continue
+		}
+		dedup = append(dedup, h)
+	}
+	return dedup, nil
+}
+
+func parseJSONFeed(r io.Reader) ([]Holiday, error) {
+	var raw []struct {
+		Date      string `json:"date"`
+		Name      string `json:"name"`
+		LocalName string `json:"localName"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("decode holiday feed: %w", err)
+	}
+	out := make([]Holiday, 0, len(raw))
+	for i, h := range raw {
+		d, err := time.Parse("2006-01-02", h.Date)
+		if err != nil {
+			return nil, fmt.Errorf("holiday %d: invalid date %q", i, h.Date)
+		}
+		label := h.LocalName
+		if label == "" {
+			label = h.Name
+		}
+		out = append(out, Holiday{Date: d, Label: label})
+	}
+	return out, nil
+}
+
+func parseICSFeed(r io.Reader) ([]Holiday, error) {
+	// Unfold continuation lines (RFC 5545 §3.1) before reading properties.
+	var lines []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read holiday feed: %w", err)
+	}
+	var out []Holiday
+	var inEvent bool
+	var start, end time.Time
+	var summary string
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		prop, _, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(prop) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, start, end, summary = true, time.Time{}, time.Time{}, ""
+			}
+		case "DTSTART":
+			if inEvent {
+				start, _ = parseICSDate(value)
+			}
+		case "DTEND":
+			if inEvent {
+				end, _ = parseICSDate(value)
+			}
+		case "SUMMARY":
+			if inEvent {
+				summary = strings.NewReplacer(`\,`, ",", `\;`, ";", `\n`, " ").Replace(value)
+			}
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("holiday %q: missing DTSTART", summary)
+			}
+			out = append(out, Holiday{Date: start, Label: summary})
+			for d, n := start.AddDate(0, 0, 1), 1; d.Before(end) && n < maxFeedDays; d, n = d.AddDate(0, 0, 1), n+1 {
+				out = append(out, Holiday{Date: d, Label: summary})
+			}
+		}
+	}
+	return out, nil
+}
+
+// parseICSDate accepts DATE values and DATE-TIME values, keeping only the day.
+func parseICSDate(v string) (time.Time, error) {
+	if len(v) < 8 {
+		return time.Time{}, fmt.Errorf("invalid date %q", v)
+	}
+	return time.Parse("20060102", v[:8])
+}
@@ -0,0 +1,84 @@
+package sla
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHolidayFeedJSON(t *testing.T) {
+	feed := `[
+		{"date":"2024-12-25","localName":"Christmas Day","name":"Christmas Day"},
+		{"date":"2024-07-04","name":"Independence Day"},
+		{"date":"2024-07-04","name":"Duplicate"}
+	]`
+	got, err := ParseHolidayFeed(strings.NewReader(feed), FeedJSON)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 holidays, got %+v", got)
+	}
+	if !got[0].Date.Equal(time.Date(2024, 7, 4, 0, 0, 0, 0, time.UTC)) || got[0].Label != "Independence Day" {
+		t.Fatalf("unexpected first holiday: %+v", got[0])
+	}
+	if _, err := ParseHolidayFeed(strings.NewReader(`[{"date":"04/07/2024"}]`), FeedJSON); err == nil {
+		t.Fatalf("expected invalid date error")
+	}
+	if _, err := ParseHolidayFeed(strings.NewReader(feed), "csv"); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
+
+func TestParseHolidayFeedICS(t *testing.T) {
+	feed := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"DTSTART;VALUE=DATE:20241225",
+		"DTEND;VALUE=DATE:20241227",
+		"SUMMARY:Christmas",
+		"  and Boxing Day",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"DTSTART:20240101T000000Z",
+		"SUMMARY:New Year\\, observed",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")
+	got, err := ParseHolidayFeed(strings.NewReader(feed), FeedICS)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []Holiday{
+		{Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Label: "New Year, observed"},
+		{Date: time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), Label: "Christmas and Boxing Day"},
+		{Date: time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC), Label: "Christmas and Boxing Day"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if !got[i].Date.Equal(want[i].Date) || got[i].Label != want[i].Label {
+			t.Fatalf("holiday %d: got %+v want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestExceptionsReduceBusinessTime(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	// Emergency closure Monday 13:00-15:00.
+	cal.Exceptions = []Exception{{
+		Start: time.Date(2024, 7, 1, 13, 0, 0, 0, loc),
+		End:   time.Date(2024, 7, 1, 15, 0, 0, 0, loc),
+	}}
+	if got := cal.BusinessDuration(time.Date(2024, 7, 1, 9, 0, 0, 0, loc), time.Date(2024, 7, 1, 17, 0, 0, 0, loc)); got != 6*time.Hour {
+		t.Fatalf("expected 6h, got %v", got)
+	}
+	if got, want := cal.AddBusinessDuration(time.Date(2024, 7, 1, 12, 0, 0, 0, loc), 2*time.Hour), time.Date(2024, 7, 1, 16, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("add across closure: got %v want %v", got, want)
+	}
+	if got, want := cal.AddBusinessDuration(time.Date(2024, 7, 1, 16, 0, 0, 0, loc), -2*time.Hour), time.Date(2024, 7, 1, 12, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("subtract across closure: got %v want %v", got, want)
+	}
+}
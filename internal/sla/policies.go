@@ -2,15 +2,24 @@ package sla
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type policyDB interface {
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 }
 
-// Policy represents an SLA policy.
+// versionDB is what UpdatePolicy needs; callers pass a transaction.
+type versionDB interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// Policy represents an SLA policy. Its targets are those of its latest
+// version; tickets keep the version in effect when they were created.
 type Policy struct {
 	ID                   string `json:"id"`
 	Name                 string `json:"name"`
@@ -18,11 +27,26 @@ type Policy struct {
 	ResponseTargetMins   int    `json:"response_target_mins"`
 	ResolutionTargetMins int    `json:"resolution_target_mins"`
 	UpdateCadenceMins    *int   `json:"update_cadence_mins,omitempty"`
+	Version              int    `json:"version"`
+}
+
+// PolicyVersion is one effective-dated set of targets of a policy.
+// EffectiveFrom is nil for the first version, which covers all earlier
+// tickets; EffectiveTo is nil for the current one.
+type PolicyVersion struct {
+	ID                   string     `json:"id"`
+	Version              int        `json:"version"`
+	ResponseTargetMins   int        `json:"response_target_mins"`
+	ResolutionTargetMins int        `json:"resolution_target_mins"`
+	UpdateCadenceMins    *int       `json:"update_cadence_mins,omitempty"`
+	EffectiveFrom        *time.Time `json:"effective_from"`
+	EffectiveTo          *time.Time `json:"effective_to"`
+	CreatedBy            *string    `json:"created_by,omitempty"`
 }
 
 // ListPolicies returns all SLA policies.
 func ListPolicies(ctx context.Context, db policyDB) ([]Policy, error) {
-	rows, err := db.Query(ctx, `select id::text, name, priority, response_target_mins, resolution_target_mins, update_cadence_mins from sla_policies order by priority`)
+	rows, err := db.Query(ctx, `select id::text, name, priority, response_target_mins, resolution_target_mins, update_cadence_mins, version from sla_policies order by priority`)
 	if err != nil {
 		return nil, err
 	}
@@ -30,10 +54,69 @@ func ListPolicies(ctx context.Context, db policyDB) ([]Policy, error) {
 	out := []Policy{}
 	for rows.Next() {
 		var p Policy
-		if err := rows.Scan(&p.ID, &p.Name, &p.Priority, &p.ResponseTargetMins, &p.ResolutionTargetMins, &p.UpdateCadenceMins); err != nil {
+		if err := rows.Scan(&p.ID, &p.Name, &p.Priority, &p.ResponseTargetMins, &p.ResolutionTargetMins, &p.UpdateCadenceMins, &p.Version); err != nil {
 			return nil, err
 		}
 		out = append(out, p)
 	}
 	return out, rows.Err()
 }
+
+// ListVersions returns a policy's versions, newest first.
+func ListVersions(ctx context.Context, db policyDB, policyID string) ([]PolicyVersion, error) {
+	rows, err := db.Query(ctx, `select id::text, version, response_target_mins, resolution_target_mins, update_cadence_mins,
+            case when effective_from = '-infinity' then null else effective_from end, effective_to, created_by
+        from sla_policy_versions where policy_id::text = $1 order by version desc`, policyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []PolicyVersion{}
+	for rows.Next() {
+		var v PolicyVersion
+		if err := rows.Scan(&v.ID, &v.Version, &v.ResponseTargetMins, &v.ResolutionTargetMins, &v.UpdateCadenceMins, &v.EffectiveFrom, &v.EffectiveTo, &v.CreatedBy); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// UpdatePolicy saves p over the policy with p.ID. When its targets change
+// the current version is closed and a new one opened from now, so tickets
+// created before keep their targets. Renaming or reprioritising does not
+// create a version. It returns pgx.ErrNoRows for an unknown policy.
+func UpdatePolicy(ctx context.Context, db versionDB, p Policy, actor string) (Policy, error) {
+	var cur Policy
+	err := db.QueryRow(ctx, `select version, response_target_mins, resolution_target_mins, update_cadence_mins
+        from sla_policies where id::text = $1 for update`, p.ID).Scan(&cur.Version, &cur.ResponseTargetMins, &cur.ResolutionTargetMins, &cur.UpdateCadenceMins)
+	if err != nil {
+		return Policy{}, err
+	}
+	p.Version = cur.Version
+	if p.ResponseTargetMins != cur.ResponseTargetMins || p.ResolutionTargetMins != cur.ResolutionTargetMins || !sameMins(p.UpdateCadenceMins, cur.UpdateCadenceMins) {
+		p.Version++
+		if _, err := db.Exec(ctx, `update sla_policy_versions set effective_to = now() where policy_id::text = $1 and effective_to is null`, p.ID); err != nil {
+			return Policy{}, err
+		}
+		if _, err := db.Exec(ctx, `insert into sla_policy_versions (policy_id, version, response_target_mins, resolution_target_mins, update_cadence_mins, effective_from, created_by)
+            values ($1::uuid, $2, $3, $4, $5, now(), nullif($6, ''))`,
+			p.ID, p.Version, p.ResponseTargetMins, p.ResolutionTargetMins, p.UpdateCadenceMins, actor); err != nil {
+			return Policy{}, err
+		}
+	}
+	err = db.QueryRow(ctx, `update sla_policies set name = $2, priority = $3, response_target_mins = $4, resolution_target_mins = $5,
+            update_cadence_mins = $6, version = $7
+        where id::text = $1
+        returning id::text, name, priority, response_target_mins, resolution_target_mins, update_cadence_mins, version`,
+		p.ID, p.Name, p.Priority, p.ResponseTargetMins, p.ResolutionTargetMins, p.UpdateCadenceMins, p.Version).
+		Scan(&p.ID, &p.Name, &p.Priority, &p.ResponseTargetMins, &p.ResolutionTargetMins, &p.UpdateCadenceMins, &p.Version)
+	return p, err
+}
+
+func sameMins(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,145 @@
+package sla
+
+import (
+	"fmt"
+	"time"
+)
+
+// AtRiskFraction is the share of a target that must have elapsed before a
+// ticket is reported as at risk of breaching.
+const AtRiskFraction = 0.75
+
+// ThresholdFilter is a SQL condition selecting open tickets whose stored
+// clock has reached msPerTargetMinute of a target (60000 = the whole target).
+// It relies on the clock the worker refreshes each tick, so it can trail the
+// live prediction by one interval. Expects tickets as t, ticket_sla_clocks as
+// sc and sla_policy_versions as sp.
+func ThresholdFilter(msPerTargetMinute int64) string {
+	return fmt.Sprintf(`t.status not in ('Resolved','Closed') and (
+			sc.resolution_elapsed_ms >= sp.resolution_target_mins * %[1]d
+			or (t.status = 'New' and t.first_response_at is null and sc.response_elapsed_ms >= sp.response_target_mins * %[1]d))`, msPerTargetMinute)
+}
+
+var (
+	// AtRiskFilter matches tickets that have used AtRiskFraction of a target.
+	AtRiskFilter = ThresholdFilter(int64(AtRiskFraction * 60000))
+	// BreachedFilter matches tickets past a target.
+	BreachedFilter = ThresholdFilter(60000)
+)
+
+// maxScanDays bounds calendar walks so a calendar without business hours
+// cannot loop forever.
+const maxScanDays = 5 * 366
+
+// AddBusinessDuration returns the instant that lies d of business time after
+// start (or before it when d is negative). The zero time is returned when the
+// calendar has no business hours within reach.
+func (c *Calendar) AddBusinessDuration(start time.Time, d time.Duration) time.Time {
+	if d == 0 {
+		return start
+	}
+	if d < 0 {
+		return c.subBusinessDuration(start, -d)
+	}
+	day := c.dayStart(start)
+	for i := 0; i < maxScanDays; i++ {
+		for _, w := range c.windows(day) {
+			if !w.end.After(start) {
+				continue
+			}
+			s := maxTime(start, w.start)
+			if avail := w.end.Sub(s); d > avail {
+				d -= avail
+				continue
+			}
+			return s.Add(d)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+func (c *Calendar) subBusinessDuration(end time.Time, d time.Duration) time.Time {
+	day := c.dayStart(end)
+	for i := 0; i < maxScanDays; i++ {
+		ws := c.windows(day)
+		for j := len(ws) - 1; j >= 0; j-- {
+			w := ws[j]
+			if !w.start.Before(end) {
+				continue
+			}
+			e := minTime(end, w.end)
+			if avail := e.Sub(w.start); d > avail {
+				d -= avail
+				continue
+			}
+			return e.Add(-d)
+		}
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Time{}
+}
+
+// Clock is a ticket's stored SLA clock together with its policy targets.
+type Clock struct {
+	ResponseElapsedMS    int64
+	ResolutionElapsedMS  int64
+	LastStartedAt        *time.Time
+	Paused               bool
+	ResponseTargetMins   int
+	ResolutionTargetMins int
+	// Responded is set once an agent has answered the ticket, which stops
+	// the response clock.
+	Responded bool
+}
+
+// Prediction describes when a ticket's SLA targets fall due.
+type Prediction struct {
+	ResponseDueAt   *time.Time
+	ResolutionDueAt *time.Time
+	// BreachIn is the business time left before the nearest target is
+	// breached; negative once breached. Nil when no target applies.
+	BreachIn *time.Duration
+	AtRisk   bool
+}
+
+// Predict projects a ticket's clock forward from now. The response target
+// only applies while the ticket is still New and unanswered. Due times are left unset while
+// the clock is paused since they move once it resumes.
+func (c *Calendar) Predict(status string, clk Clock, now time.Time) Prediction {
+	var p Prediction
+	if !Running(status) && !IsPaused(status) {
+		return p
+	}
+	paused := clk.Paused || IsPaused(status)
+	var accrued time.Duration
+	if !paused && clk.LastStartedAt != nil && now.After(*clk.LastStartedAt) {
+		accrued = c.BusinessDuration(*clk.LastStartedAt, now)
+	}
+	target := func(mins int, elapsedMS int64) *time.Time {
+		if mins <= 0 {
+			return nil
+		}
+		total := time.Duration(mins) * time.Minute
+		left := total - time.Duration(elapsedMS)*time.Millisecond - accrued
+		if p.BreachIn == nil || left < *p.BreachIn {
+			p.BreachIn = &left
+		}
+		if float64(total-left) >= AtRiskFraction*float64(total) {
+			p.AtRisk = true
+		}
+		if paused {
+			return nil
+		}
+		due := c.AddBusinessDuration(now, left)
+		if due.IsZero() {
+			return nil
+		}
+		return &due
+	}
+	if status == "New" && !clk.Responded {
+		p.ResponseDueAt = target(clk.ResponseTargetMins, clk.ResponseElapsedMS)
+	}
+	p.ResolutionDueAt = target(clk.ResolutionTargetMins, clk.ResolutionElapsedMS)
+	return p
+}
@@ -0,0 +1,97 @@
+package sla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAddBusinessDuration(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	// Friday 2024-07-05 15:00; weekend and the following Monday's hours follow.
+	fri := time.Date(2024, 7, 5, 15, 0, 0, 0, loc)
+	tests := []struct {
+		name  string
+		start time.Time
+		d     time.Duration
+		want  time.Time
+	}{
+		{"same day", fri, time.Hour, time.Date(2024, 7, 5, 16, 0, 0, 0, loc)},
+		{"over weekend", fri, 3 * time.Hour, time.Date(2024, 7, 8, 10, 0, 0, 0, loc)},
+		{"before hours", time.Date(2024, 7, 8, 6, 0, 0, 0, loc), time.Hour, time.Date(2024, 7, 8, 10, 0, 0, 0, loc)},
+		{"backwards over weekend", time.Date(2024, 7, 8, 10, 0, 0, 0, loc), -3 * time.Hour, fri},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cal.AddBusinessDuration(tt.start, tt.d); !got.Equal(tt.want) {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+
+	cal.Holidays[time.Date(2024, 7, 8, 0, 0, 0, 0, loc)] = struct{}{}
+	if got, want := cal.AddBusinessDuration(fri, 3*time.Hour), time.Date(2024, 7, 9, 10, 0, 0, 0, loc); !got.Equal(want) {
+		t.Fatalf("holiday: got %v want %v", got, want)
+	}
+	if got := (&Calendar{Location: loc}).AddBusinessDuration(fri, time.Hour); !got.IsZero() {
+		t.Fatalf("expected zero time without business hours, got %v", got)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, loc)
+	started := now.Add(-time.Hour)
+	clk := Clock{ResponseElapsedMS: 0, ResolutionElapsedMS: int64(time.Hour / time.Millisecond),
+		LastStartedAt: &started, ResponseTargetMins: 240, ResolutionTargetMins: 480}
+
+	p := cal.Predict("New", clk, now)
+	if p.ResponseDueAt == nil || !p.ResponseDueAt.Equal(time.Date(2024, 7, 1, 13, 0, 0, 0, loc)) {
+		t.Fatalf("response due: %v", p.ResponseDueAt)
+	}
+	if p.ResolutionDueAt == nil || !p.ResolutionDueAt.Equal(time.Date(2024, 7, 1, 16, 0, 0, 0, loc)) {
+		t.Fatalf("resolution due: %v", p.ResolutionDueAt)
+	}
+	if p.BreachIn == nil || *p.BreachIn != 3*time.Hour || p.AtRisk {
+		t.Fatalf("unexpected prediction: %+v", p)
+	}
+
+	open := cal.Predict("Open", clk, now)
+	if open.ResponseDueAt != nil || *open.BreachIn != 6*time.Hour {
+		t.Fatalf("response target should not apply once open: %+v", open)
+	}
+
+	answered := clk
+	answered.Responded = true
+	if r := cal.Predict("New", answered, now); r.ResponseDueAt != nil || *r.BreachIn != 6*time.Hour {
+		t.Fatalf("response target should not apply once answered: %+v", r)
+	}
+	if d := cal.ResponseAccrual(started, now, &now); d != time.Hour {
+		t.Fatalf("accrual up to the response = %v", d)
+	}
+	if d := cal.ResponseAccrual(now, now.Add(time.Hour), &started); d != 0 {
+		t.Fatalf("accrual after the response = %v", d)
+	}
+
+	clk.ResolutionElapsedMS = int64(6 * time.Hour / time.Millisecond)
+	risky := cal.Predict("Open", clk, now)
+	if !risky.AtRisk || *risky.BreachIn != time.Hour {
+		t.Fatalf("expected at risk: %+v", risky)
+	}
+
+	clk.ResolutionElapsedMS = int64(9 * time.Hour / time.Millisecond)
+	late := cal.Predict("Open", clk, now)
+	if *late.BreachIn != -2*time.Hour || !late.ResolutionDueAt.Equal(time.Date(2024, 6, 28, 16, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected breached prediction: %+v", late)
+	}
+
+	paused := cal.Predict("Pending", clk, now)
+	if paused.ResolutionDueAt != nil || *paused.BreachIn != -time.Hour {
+		t.Fatalf("paused clock should not accrue or set due: %+v", paused)
+	}
+
+	if done := cal.Predict("Resolved", clk, now); done.BreachIn != nil {
+		t.Fatalf("resolved ticket should have no prediction: %+v", done)
+	}
+}
@@ -0,0 +1,132 @@
+package sla
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// What happens to a ticket's SLA clock when its priority changes.
+const (
+	// RuleKeep leaves the clock on its original policy and targets.
+	RuleKeep = "keep"
+	// RuleRestart moves the clock to the new priority's policy and starts
+	// it again from zero.
+	RuleRestart = "restart"
+	// RuleProrate moves the clock to the new priority's policy and scales
+	// the elapsed time so the same share of each target is used up.
+	RuleProrate = "prorate"
+)
+
+// ValidRule reports whether r is a known priority-change rule.
+func ValidRule(r string) bool {
+	return r == RuleKeep || r == RuleRestart || r == RuleProrate
+}
+
+// PriorityChangeRules picks a rule by the direction of a priority change.
+// Priority 1 is the highest, so raising a ticket lowers its number. Empty
+// rules mean RuleKeep.
+type PriorityChangeRules struct {
+	Raise string `json:"raise"`
+	Lower string `json:"lower"`
+}
+
+// For returns the rule for a change from one priority to another.
+func (r PriorityChangeRules) For(from, to int) string {
+	rule := r.Lower
+	if to < from {
+		rule = r.Raise
+	}
+	if !ValidRule(rule) {
+		return RuleKeep
+	}
+	return rule
+}
+
+// Recalibration records what a priority change did to a ticket's clock.
+type Recalibration struct {
+	Rule                    string `json:"rule"`
+	FromPriority            int    `json:"from_priority"`
+	ToPriority              int    `json:"to_priority"`
+	FromPolicyID            string `json:"from_policy_id,omitempty"`
+	ToPolicyID              string `json:"to_policy_id,omitempty"`
+	OldResponseMS           int64  `json:"old_response_elapsed_ms"`
+	OldResolutionMS         int64  `json:"old_resolution_elapsed_ms"`
+	NewResponseMS           int64  `json:"new_response_elapsed_ms"`
+	NewResolutionMS         int64  `json:"new_resolution_elapsed_ms"`
+	NewResponseTargetMins   int    `json:"new_response_target_mins,omitempty"`
+	NewResolutionTargetMins int    `json:"new_resolution_target_mins,omitempty"`
+	// Reason explains why a restart or prorate rule kept the clock as is.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Recalibrate applies rules to ticketID's clock after its priority changed
+// from one value to another. The new policy is the one for the new
+// priority, at the version in effect when the ticket was created. Elapsed
+// times are those stored at the last worker tick. ok is false when the
+// ticket has no clock.
+func Recalibrate(ctx context.Context, db versionDB, ticketID string, from, to int, rules PriorityChangeRules, now time.Time) (r Recalibration, ok bool, err error) {
+	r = Recalibration{Rule: rules.For(from, to), FromPriority: from, ToPriority: to}
+	var fromPolicy *string
+	var respTarget, resTarget *int
+	var created time.Time
+	err = db.QueryRow(ctx, `select sc.policy_id::text, sc.response_elapsed_ms, sc.resolution_elapsed_ms,
+            sp.response_target_mins, sp.resolution_target_mins, t.created_at
+        from ticket_sla_clocks sc
+        join tickets t on t.id = sc.ticket_id
+        left join sla_policy_versions sp on sp.id = sc.policy_version_id
+        where sc.ticket_id::text = $1
+        for update of sc`, ticketID).Scan(&fromPolicy, &r.OldResponseMS, &r.OldResolutionMS, &respTarget, &resTarget, &created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return r, false, nil
+	}
+	if err != nil {
+		return r, false, err
+	}
+	if fromPolicy != nil {
+		r.FromPolicyID = *fromPolicy
+	}
+	r.NewResponseMS, r.NewResolutionMS = r.OldResponseMS, r.OldResolutionMS
+	if r.Rule == RuleKeep || from == to {
+		return r, true, nil
+	}
+	err = db.QueryRow(ctx, `select p.id::text, v.response_target_mins, v.resolution_target_mins
+        from sla_policies p
+        join sla_policy_versions v on v.policy_id = p.id
+            and v.effective_from <= $2 and (v.effective_to is null or v.effective_to > $2)
+        where p.priority = $1
+        order by p.created_at limit 1`, to, created).Scan(&r.ToPolicyID, &r.NewResponseTargetMins, &r.NewResolutionTargetMins)
+	if errors.Is(err, pgx.ErrNoRows) {
+		r.Reason = "no policy for the new priority"
+		return r, true, nil
+	}
+	if err != nil {
+		return r, true, err
+	}
+	switch r.Rule {
+	case RuleRestart:
+		r.NewResponseMS, r.NewResolutionMS = 0, 0
+	case RuleProrate:
+		r.NewResponseMS = prorate(r.OldResponseMS, respTarget, r.NewResponseTargetMins)
+		r.NewResolutionMS = prorate(r.OldResolutionMS, resTarget, r.NewResolutionTargetMins)
+	}
+	// A restarted clock that is running starts accruing again from now,
+	// dropping the time since the last tick; a prorated one keeps it.
+	_, err = db.Exec(ctx, `update ticket_sla_clocks set policy_id = $2::uuid, response_elapsed_ms = $3, resolution_elapsed_ms = $4,
+            last_started_at = case when $5 and last_started_at is not null then $6 else last_started_at end
+        where ticket_id::text = $1`,
+		ticketID, r.ToPolicyID, r.NewResponseMS, r.NewResolutionMS, r.Rule == RuleRestart, now)
+	return r, true, err
+}
+
+// prorate scales elapsed from an old target to a new one. Without a usable
+// old target the elapsed time is kept.
+func prorate(elapsed int64, oldMins *int, newMins int) int64 {
+	if oldMins == nil || *oldMins <= 0 {
+		return elapsed
+	}
+	return int64(math.Round(float64(elapsed) * float64(newMins) / float64(*oldMins)))
+}
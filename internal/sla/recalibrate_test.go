@@ -0,0 +1,26 @@
+package sla
+
+import "testing"
+
+func TestPriorityChangeRules(t *testing.T) {
+	r := PriorityChangeRules{Raise: RuleProrate, Lower: "bogus"}
+	if got := r.For(3, 1); got != RuleProrate {
+		t.Fatalf("raise: got %s", got)
+	}
+	if got := r.For(1, 3); got != RuleKeep {
+		t.Fatalf("an unknown rule should keep, got %s", got)
+	}
+	if got := (PriorityChangeRules{}).For(2, 1); got != RuleKeep {
+		t.Fatalf("unset rules should keep, got %s", got)
+	}
+}
+
+func TestProrate(t *testing.T) {
+	old := 60
+	if got := prorate(30*60000, &old, 120); got != 60*60000 {
+		t.Fatalf("half of a 60m target should be half of 120m, got %d", got)
+	}
+	if got := prorate(1000, nil, 120); got != 1000 {
+		t.Fatalf("without an old target elapsed is kept, got %d", got)
+	}
+}
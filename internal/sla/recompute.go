@@ -0,0 +1,142 @@
+package sla
+
+import (
+	"context"
+	"time"
+)
+
+// pausedStatuses stop the SLA clock until the ticket leaves them.
+var pausedStatuses = map[string]bool{
+	"Scheduled":                   true,
+	"Pending":                     true,
+	"Pending - Awaiting Info":     true,
+	"Pending - Awaiting Callback": true,
+	"Pending - Awaiting Parts":    true,
+	"Pending - Awaiting Approval": true,
+}
+
+// stoppedStatuses end the clock; reopening the ticket starts it again.
+var stoppedStatuses = map[string]bool{
+	"Resolved": true,
+	"Closed":   true,
+}
+
+// IsPaused reports whether the clock is paused while a ticket is in status.
+func IsPaused(status string) bool { return pausedStatuses[status] }
+
+// Running reports whether the clock accrues time while a ticket is in status.
+func Running(status string) bool { return !pausedStatuses[status] && !stoppedStatuses[status] }
+
+// Transition is a status change taken from ticket_status_history.
+type Transition struct {
+	At     time.Time
+	Status string
+}
+
+// ElapsedFromHistory replays a ticket's status history against the calendar
+// and returns the business time during which the clock was running. The
+// ticket starts in initial at created; history must be ordered by time.
+func (c *Calendar) ElapsedFromHistory(created time.Time, initial string, history []Transition, now time.Time) time.Duration {
+	var total time.Duration
+	status, since := initial, created
+	for _, t := range history {
+		if t.At.Before(since) {
+			t.At = since
+		}
+		if Running(status) {
+			total += c.BusinessDuration(since, t.At)
+		}
+		status, since = t.Status, t.At
+	}
+	if Running(status) && now.After(since) {
+		total += c.BusinessDuration(since, now)
+	}
+	return total
+}
+
+// ClockRepair compares a ticket's stored SLA clock with one recomputed from
+// its status history.
+type ClockRepair struct {
+	TicketID        string `json:"ticket_id"`
+	Status          string `json:"status"`
+	OldResponseMS   int64  `json:"old_response_elapsed_ms"`
+	OldResolutionMS int64  `json:"old_resolution_elapsed_ms"`
+	NewResponseMS   int64  `json:"new_response_elapsed_ms"`
+	NewResolutionMS int64  `json:"new_resolution_elapsed_ms"`
+	Paused          bool   `json:"paused"`
+	Changed         bool   `json:"changed"`
+	Skipped         string `json:"skipped,omitempty"`
+}
+
+// RecomputeClock loads a ticket's calendar, status history and stored clock
+// and returns the repaired values. Like the live worker, response and
+// resolution accrue over the same running intervals, the response clock
+// only until the ticket's first response.
+func RecomputeClock(ctx context.Context, db DB, ticketID string, now time.Time) (ClockRepair, error) {
+	r := ClockRepair{TicketID: ticketID}
+	var calID string
+	var created time.Time
+	var respondedAt *time.Time
+	if err := db.QueryRow(ctx, `
+      select coalesce(tm.calendar_id::text, rg.calendar_id::text, ''), t.created_at, t.status,
+             sc.response_elapsed_ms, sc.resolution_elapsed_ms, t.first_response_at
+      from tickets t
+      join ticket_sla_clocks sc on sc.ticket_id = t.id
+      left join teams tm on t.team_id = tm.id
+      left join regions rg on tm.region_id = rg.id
+      where t.id = $1`, ticketID).Scan(&calID, &created, &r.Status, &r.OldResponseMS, &r.OldResolutionMS, &respondedAt); err != nil {
+		return r, err
+	}
+	r.Paused = IsPaused(r.Status)
+	if calID == "" {
+		// The worker never runs clocks without a calendar; nothing to repair.
+		r.NewResponseMS, r.NewResolutionMS = r.OldResponseMS, r.OldResolutionMS
+		r.Skipped = "no calendar"
+		return r, nil
+	}
+	cal, err := LoadCalendar(ctx, db, calID)
+	if err != nil {
+		return r, err
+	}
+	rows, err := db.Query(ctx, `select at, coalesce(from_status,''), to_status from ticket_status_history where ticket_id = $1 order by at asc`, ticketID)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+	initial := ""
+	var history []Transition
+	for rows.Next() {
+		var t Transition
+		var from string
+		if err := rows.Scan(&t.At, &from, &t.Status); err != nil {
+			return r, err
+		}
+		if initial == "" {
+			initial = from
+		}
+		history = append(history, t)
+	}
+	if err := rows.Err(); err != nil {
+		return r, err
+	}
+	if initial == "" {
+		if len(history) == 0 {
+			initial = r.Status
+		} else {
+			initial = "New"
+		}
+	}
+	r.NewResolutionMS = int64(cal.ElapsedFromHistory(created, initial, history, now) / time.Millisecond)
+	r.NewResponseMS = r.NewResolutionMS
+	if respondedAt != nil && respondedAt.Before(now) {
+		var before []Transition
+		for _, t := range history {
+			if t.At.Before(*respondedAt) {
+				before = append(before, t)
+			}
+		}
+		r.NewResponseMS = int64(cal.ElapsedFromHistory(created, initial, before, *respondedAt) / time.Millisecond)
+	}
+	r.Changed = r.NewResponseMS != r.OldResponseMS || r.NewResolutionMS != r.OldResolutionMS
+	return r, nil
+}
@@ -0,0 +1,34 @@
+package sla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElapsedFromHistory(t *testing.T) {
+	cal := testCalendar()
+	loc := cal.Location
+	day := func(h int) time.Time { return time.Date(2024, 7, 1, h, 0, 0, 0, loc) } // Monday
+
+	tests := []struct {
+		name    string
+		initial string
+		history []Transition
+		now     time.Time
+		want    time.Duration
+	}{
+		{"no history runs until now", "New", nil, day(12), 3 * time.Hour},
+		{"pause excludes time", "New", []Transition{{day(10), "Pending"}, {day(14), "Open"}}, day(16), 3 * time.Hour},
+		{"resolved stops clock", "Open", []Transition{{day(11), "Resolved"}}, day(17), 2 * time.Hour},
+		{"reopen restarts clock", "Open", []Transition{{day(10), "Closed"}, {day(15), "Open"}}, day(16), 2 * time.Hour},
+		{"created paused", "Scheduled", nil, day(17), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cal.ElapsedFromHistory(day(9), tt.initial, tt.history, tt.now)
+			if got != tt.want {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package sla
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type execDB interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// RecordFirstResponse stamps first_response_at on ticketID unless the
+// ticket has been answered already, and reports whether it did. Call it
+// after posting a public comment by staff; the caller decides who counts as
+// staff (authpkg.IsStaff for a request) since roles can come from token
+// claims and IdP groups as well as user_roles. The worker stops the
+// response clock at the stamp.
+func RecordFirstResponse(ctx context.Context, db execDB, ticketID string) (bool, error) {
+	tag, err := db.Exec(ctx, `update tickets set first_response_at = now() where id::text = $1 and first_response_at is null`, ticketID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResponseAccrual is the business time the response clock gains between
+// lastStarted and now. It stops at respondedAt, the ticket's first
+// response, and gains nothing once the ticket has been answered.
+func (c *Calendar) ResponseAccrual(lastStarted, now time.Time, respondedAt *time.Time) time.Duration {
+	if respondedAt != nil {
+		if !respondedAt.After(lastStarted) {
+			return 0
+		}
+		if respondedAt.Before(now) {
+			now = *respondedAt
+		}
+	}
+	return c.BusinessDuration(lastStarted, now)
+}
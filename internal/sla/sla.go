@@ -18,9 +18,20 @@ type Hours struct {
 }
 
 type Calendar struct {
-	Location *time.Location
-	Hours    map[time.Weekday]Hours
-	Holidays map[time.Time]struct{}
+	Location   *time.Location
+	Hours      map[time.Weekday]Hours
+	Holidays   map[time.Time]struct{}
+	Exceptions []Exception
+}
+
+// Exception closes a calendar between Start and End on top of its regular
+// hours and holidays, e.g. a company holiday or an emergency closure.
+type Exception struct {
+	Start time.Time
+	End   time.Time
+	// Kind is "holiday" or "closure".
+	Kind  string
+	Label string
 }
 
 func LoadCalendar(ctx context.Context, db DB, id string) (*Calendar, error) {
@@ -61,6 +72,18 @@ func LoadCalendar(ctx context.Context, db DB, id string) (*Calendar, error) {
 			cal.Holidays[day] = struct{}{}
 		}
 	}
+
+	erows, err := db.Query(ctx, "select starts_at, ends_at, kind, coalesce(label,'') from calendar_exceptions where calendar_id=$1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer erows.Close()
+	for erows.Next() {
+		var ex Exception
+		if err := erows.Scan(&ex.Start, &ex.End, &ex.Kind, &ex.Label); err == nil {
+			cal.Exceptions = append(cal.Exceptions, ex)
+		}
+	}
 	return cal, nil
 }
 
@@ -71,43 +94,71 @@ func (c *Calendar) BusinessDuration(start, end time.Time) time.Duration {
 	start = start.In(c.Location)
 	end = end.In(c.Location)
 	total := time.Duration(0)
-	cur := start
-	for cur.Before(end) {
-		dayStart := time.Date(cur.Year(), cur.Month(), cur.Day(), 0, 0, 0, 0, c.Location)
-		dayEnd := dayStart.Add(24 * time.Hour)
-		if _, ok := c.Holidays[dayStart]; ok {
-			cur = dayEnd
-			continue
-		}
-		hrs, ok := c.Hours[dayStart.Weekday()]
-		if !ok {
-			cur = dayEnd
-			continue
-		}
-		bhStart := dayStart.Add(time.Duration(hrs.StartSec) * time.Second)
-		bhEnd := dayStart.Add(time.Duration(hrs.EndSec) * time.Second)
-		if cur.Before(bhStart) {
-			cur = bhStart
-		}
-		if cur.After(bhEnd) {
-			cur = dayEnd
-			continue
-		}
-		e := minTime(end, bhEnd)
-		if e.After(cur) {
-			total += e.Sub(cur)
-		}
-		cur = e
-		if cur.Equal(bhEnd) {
-			cur = dayEnd
+	for day := c.dayStart(start); day.Before(end); day = day.AddDate(0, 0, 1) {
+		for _, w := range c.windows(day) {
+			s := maxTime(start, w.start)
+			e := minTime(end, w.end)
+			if e.After(s) {
+				total += e.Sub(s)
+			}
 		}
 	}
 	return total
 }
 
+// span is a half-open interval of business time.
+type span struct {
+	start, end time.Time
+}
+
+func (c *Calendar) dayStart(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// windows returns the business intervals of the day beginning at day, after
+// removing holidays and exceptions.
+func (c *Calendar) windows(day time.Time) []span {
+	if _, ok := c.Holidays[day]; ok {
+		return nil
+	}
+	hrs, ok := c.Hours[day.Weekday()]
+	if !ok || hrs.EndSec <= hrs.StartSec {
+		return nil
+	}
+	out := []span{{
+		start: day.Add(time.Duration(hrs.StartSec) * time.Second),
+		end:   day.Add(time.Duration(hrs.EndSec) * time.Second),
+	}}
+	for _, ex := range c.Exceptions {
+		var kept []span
+		for _, w := range out {
+			if !ex.Start.Before(w.end) || !ex.End.After(w.start) {
+				kept = append(kept, w)
+				continue
+			}
+			if ex.Start.After(w.start) {
+				kept = append(kept, span{w.start, ex.Start})
+			}
+			if ex.End.Before(w.end) {
+				kept = append(kept, span{ex.End, w.end})
+			}
+		}
+		out = kept
+	}
+	return out
+}
+
 func minTime(a, b time.Time) time.Time {
 	if a.Before(b) {
 		return a
 	}
 	return b
 }
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
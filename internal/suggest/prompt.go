@@ -0,0 +1,139 @@
+package suggest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Redaction patterns, applied in order. Card numbers go before phone
+// numbers, which would otherwise swallow them.
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[private key]"},
+	{regexp.MustCompile(`(?i)\b(password|passwd|pwd|passcode|pin|secret|token|api[_ -]?key)(\s*[:=]\s*|\s+is\s+)\S+`), "$1$2[redacted]"},
+	{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`), "Bearer [redacted]"},
+	{regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`), "://[redacted]@"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[email]"},
+	{regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), "[card]"},
+	{regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), "[id number]"},
+	{regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`), "[ip]"},
+	{regexp.MustCompile(`\+?\(?\d{1,4}\)?[\s.-]?\(?\d{2,4}\)?[\s.-]\d{3,4}[\s.-]?\d{3,4}\b`), "[phone]"},
+	{regexp.MustCompile(`\b[A-Za-z0-9_-]*\d[A-Za-z0-9_-]*[A-Za-z][A-Za-z0-9_-]{30,}\b`), "[token]"},
+}
+
+// Sanitize redacts personal data and credentials from text before it is sent
+// to a provider: email addresses, phone, card and ID numbers, IP addresses,
+// passwords, API keys and other long tokens.
+func Sanitize(text string) string {
+	for _, r := range redactions {
+		text = r.re.ReplaceAllString(text, r.repl)
+	}
+	return text
+}
+
+// Comment is one public message on the ticket. FromRequester distinguishes
+// the requester's messages from agent replies.
+type Comment struct {
+	FromRequester bool
+	Body          string
+}
+
+// Source is a knowledge-base article offered to the model.
+type Source struct {
+	ID    string `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	Body  string `json:"-"`
+}
+
+// Context is everything the model is told about a ticket. Internal notes
+// never belong here: they may hold details the requester must not see.
+type Context struct {
+	Title        string
+	Description  string
+	Status       string
+	Language     string
+	Comments     []Comment
+	Sources      []Source
+	Instructions string
+}
+
+// Limits keep prompts within small context windows.
+const (
+	maxComments     = 10
+	maxCommentChars = 2000
+	maxSourceChars  = 1500
+	maxInstructions = 500
+)
+
+const systemPrompt = `You are a helpdesk agent drafting a reply to a requester. Write only the reply body in Markdown, without a subject line or signature.
+Be concise, friendly and specific. Do not promise anything the ticket does not support and do not invent facts, links or steps.
+When a knowledge base article below is relevant, use it and cite it as [KB:slug]. If none is relevant, do not cite any.
+Placeholders such as [email] or [redacted] stand for removed personal data; never ask the requester to repeat it.`
+
+// Build assembles the chat messages for ctx. All ticket text is sanitized.
+func Build(c Context) []Message {
+	sys := systemPrompt
+	if c.Language != "" {
+		sys += fmt.Sprintf("\nReply in the language with ISO 639-1 code %q.", c.Language)
+	} else {
+		sys += "\nReply in the requester's language."
+	}
+	if len(c.Sources) > 0 {
+		var b strings.Builder
+		b.WriteString("\n\nKnowledge base articles:")
+		for _, s := range c.Sources {
+			fmt.Fprintf(&b, "\n\n[KB:%s] %s\n%s", s.Slug, s.Title, truncate(s.Body, maxSourceChars))
+		}
+		sys += b.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Ticket: %s\nStatus: %s\n\n%s", Sanitize(c.Title), c.Status, Sanitize(truncate(c.Description, maxCommentChars)))
+	comments := c.Comments
+	if len(comments) > maxComments {
+		comments = comments[len(comments)-maxComments:]
+	}
+	for _, cm := range comments {
+		who := "Agent"
+		if cm.FromRequester {
+			who = "Requester"
+		}
+		fmt.Fprintf(&b, "\n\n%s:\n%s", who, Sanitize(truncate(cm.Body, maxCommentChars)))
+	}
+	if in := strings.TrimSpace(c.Instructions); in != "" {
+		fmt.Fprintf(&b, "\n\nAgent instructions for this reply: %s", Sanitize(truncate(in, maxInstructions)))
+	}
+	return []Message{{Role: "system", Content: sys}, {Role: "user", Content: b.String()}}
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+var citation = regexp.MustCompile(`\[KB:([A-Za-z0-9_-]+)\]`)
+
+// Cited returns the slugs of sources cited in text, in order of first
+// citation. Citations of articles that were not offered are dropped.
+func Cited(text string, sources []Source) []string {
+	known := map[string]bool{}
+	for _, s := range sources {
+		known[s.Slug] = true
+	}
+	out := []string{}
+	seen := map[string]bool{}
+	for _, m := range citation.FindAllStringSubmatch(text, -1) {
+		if known[m[1]] && !seen[m[1]] {
+			seen[m[1]] = true
+			out = append(out, m[1])
+		}
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+// Package suggest drafts agent replies with a large language model. Ticket
+// context is sanitized before it leaves the helpdesk and backends are reached
+// through the Provider interface; OpenAI speaks the chat completions API that
+// hosted services and local runtimes such as Ollama or vLLM share.
+package suggest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Message is one chat message sent to a provider.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Request asks a provider for N completions of Messages.
+type Request struct {
+	Messages  []Message
+	N         int
+	MaxTokens int
+}
+
+// Completion is a provider's answer. Token counts are as reported by the
+// backend and zero when it does not report usage.
+type Completion struct {
+	Model            string
+	Choices          []string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider generates completions.
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Completion, error)
+}
+
+// Config selects and configures a provider. Provider is "openai" for a
+// hosted OpenAI-compatible API or "local" for a self-hosted one; an empty
+// Provider disables suggestions.
+type Config struct {
+	Provider string
+	BaseURL  string
+	APIKey   string
+	Model    string
+}
+
+// Enabled reports whether a provider is configured.
+func (c Config) Enabled() bool { return c.Provider != "" }
+
+// Defaults per provider, used when BaseURL or Model are empty.
+const (
+	DefaultOpenAIURL   = "https://api.openai.com/v1"
+	DefaultOpenAIModel = "gpt-4o-mini"
+	DefaultLocalURL    = "http://localhost:11434/v1"
+	DefaultLocalModel  = "llama3.1"
+)
+
+// New returns the provider described by cfg.
+func New(cfg Config) (Provider, error) {
+	o := OpenAI{BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: cfg.Model}
+	switch cfg.Provider {
+	case "openai":
+		if o.APIKey == "" {
+			return nil, fmt.Errorf("openai suggestion provider requires an API key")
+		}
+		o.BaseURL = or(o.BaseURL, DefaultOpenAIURL)
+		o.Model = or(o.Model, DefaultOpenAIModel)
+	case "local":
+		o.BaseURL = or(o.BaseURL, DefaultLocalURL)
+		o.Model = or(o.Model, DefaultLocalModel)
+	default:
+		return nil, fmt.Errorf("unknown suggestion provider %q", cfg.Provider)
+	}
+	return o, nil
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// OpenAI calls an OpenAI-compatible /chat/completions endpoint. APIKey is
+// optional for local backends.
+type OpenAI struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+// httpTimeout bounds a single completion request; generating several drafts
+// on a local model can take a while.
+const httpTimeout = 60 * time.Second
+
+// Complete implements Provider. Backends that ignore "n" return a single
+// choice, which callers must accept.
+func (o OpenAI) Complete(ctx context.Context, req Request) (Completion, error) {
+	var out Completion
+	body, err := json.Marshal(map[string]any{
+		"model":       o.Model,
+		"messages":    req.Messages,
+		"n":           max(1, req.N),
+		"max_tokens":  req.MaxTokens,
+		"temperature": 0.4,
+	})
+	if err != nil {
+		return out, err
+	}
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: httpTimeout}
+	}
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(o.BaseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return out, err
+	}
+	hr.Header.Set("Content-Type", "application/json")
+	if o.APIKey != "" {
+		hr.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	resp, err := client.Do(hr)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return out, fmt.Errorf("suggestion provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var res struct {
+		Model   string `json:"model"`
+		Choices []struct {
+			Message Message `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return out, fmt.Errorf("decode suggestion response: %w", err)
+	}
+	out.Model = or(res.Model, o.Model)
+	out.PromptTokens = res.Usage.PromptTokens
+	out.CompletionTokens = res.Usage.CompletionTokens
+	for _, c := range res.Choices {
+		if text := strings.TrimSpace(c.Message.Content); text != "" {
+			out.Choices = append(out.Choices, text)
+		}
+	}
+	if len(out.Choices) == 0 {
+		return out, fmt.Errorf("suggestion provider returned no choices")
+	}
+	return out, nil
+}
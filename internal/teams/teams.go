@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v5"
+
+	"github.com/mark3748/helpdesk-go/internal/sender"
 )
 
 type DB interface {
@@ -13,10 +15,21 @@ type DB interface {
 type Team struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
+	// Languages are the ISO 639-1 codes of tickets routed to this team by
+	// language detection.
+	Languages []string `json:"languages,omitempty"`
+	// Leaderboard is who sees the team's agent leaderboard: "off", "self"
+	// or "team".
+	Leaderboard string `json:"leaderboard"`
+	// EmailIdentity is how notification emails about the team's tickets
+	// are sent, unless their queue says otherwise.
+	EmailIdentity sender.Identity `json:"email_identity"`
+	// LeadID is the member assigned follow-ups for bad CSAT scores.
+	LeadID *string `json:"lead_id"`
 }
 
 func List(ctx context.Context, db DB) ([]Team, error) {
-	rows, err := db.Query(ctx, `select id::text, name from teams order by name`)
+	rows, err := db.Query(ctx, `select id::text, name, languages, leaderboard, email_identity, lead_id::text from teams order by name`)
 	if err != nil {
 		return nil, err
 	}
@@ -24,7 +37,7 @@ func List(ctx context.Context, db DB) ([]Team, error) {
 	var out []Team
 	for rows.Next() {
 		var t Team
-		if err := rows.Scan(&t.ID, &t.Name); err != nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.Languages, &t.Leaderboard, &t.EmailIdentity, &t.LeadID); err != nil {
 			return nil, err
 		}
 		out = append(out, t)
@@ -0,0 +1,168 @@
+// Package translate translates ticket comments with a machine translation
+// service reached through the Provider interface. DeepL speaks DeepL's v2
+// API and LibreTranslate the API of the open-source server, hosted or run
+// locally.
+package translate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Translation is a provider's answer. From is the detected source language,
+// empty when the backend does not report it.
+type Translation struct {
+	Text string
+	From string
+}
+
+// Provider translates text into the language to.
+type Provider interface {
+	Translate(ctx context.Context, text, to string) (Translation, error)
+}
+
+// Config selects and configures a provider. Provider is "deepl" or
+// "libretranslate"; an empty Provider disables translation.
+type Config struct {
+	Provider string
+	BaseURL  string
+	APIKey   string
+}
+
+// Enabled reports whether a provider is configured.
+func (c Config) Enabled() bool { return c.Provider != "" }
+
+// Defaults per provider, used when BaseURL is empty.
+const (
+	DefaultDeepLURL          = "https://api-free.deepl.com/v2"
+	DefaultLibreTranslateURL = "http://localhost:5000"
+)
+
+// New returns the provider described by cfg.
+func New(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "deepl":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("deepl translation provider requires an API key")
+		}
+		return DeepL{BaseURL: or(cfg.BaseURL, DefaultDeepLURL), APIKey: cfg.APIKey}, nil
+	case "libretranslate":
+		return LibreTranslate{BaseURL: or(cfg.BaseURL, DefaultLibreTranslateURL), APIKey: cfg.APIKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider %q", cfg.Provider)
+	}
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+var langPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]{2})?$`)
+
+// Lang normalizes a target language: an ISO 639-1 code, optionally with a
+// region as in pt-BR. ok is false for anything else.
+func Lang(raw string) (lang string, ok bool) {
+	l := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(raw), "_", "-"))
+	if !langPattern.MatchString(l) {
+		return "", false
+	}
+	if base, region, found := strings.Cut(l, "-"); found {
+		return base + "-" + strings.ToUpper(region), true
+	}
+	return l, true
+}
+
+// httpTimeout bounds a single call to a provider.
+const httpTimeout = 20 * time.Second
+
+// post sends body to endpoint and decodes the JSON answer into out.
+func post(ctx context.Context, endpoint, contentType string, body []byte, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := (&http.Client{Timeout: httpTimeout}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("translation provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode translation response: %w", err)
+	}
+	return nil
+}
+
+// DeepL translates with DeepL's API.
+type DeepL struct {
+	BaseURL string
+	APIKey  string
+}
+
+// Translate implements Provider.
+func (d DeepL) Translate(ctx context.Context, text, to string) (Translation, error) {
+	form := url.Values{"text": {text}, "target_lang": {strings.ToUpper(to)}}
+	var out struct {
+		Translations []struct {
+			Text string `json:"text"`
+			From string `json:"detected_source_language"`
+		} `json:"translations"`
+	}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + d.APIKey}}
+	if err := post(ctx, strings.TrimSuffix(d.BaseURL, "/")+"/translate", "application/x-www-form-urlencoded", []byte(form.Encode()), header, &out); err != nil {
+		return Translation{}, err
+	}
+	if len(out.Translations) == 0 {
+		return Translation{}, fmt.Errorf("translation provider returned no translation")
+	}
+	return Translation{Text: out.Translations[0].Text, From: strings.ToLower(out.Translations[0].From)}, nil
+}
+
+// LibreTranslate translates with a LibreTranslate server. APIKey is only
+// needed by servers that require one.
+type LibreTranslate struct {
+	BaseURL string
+	APIKey  string
+}
+
+// Translate implements Provider. LibreTranslate knows languages without
+// regions, so pt-BR is asked for as pt.
+func (l LibreTranslate) Translate(ctx context.Context, text, to string) (Translation, error) {
+	target, _, _ := strings.Cut(to, "-")
+	in := map[string]string{"q": text, "source": "auto", "target": target, "format": "text"}
+	if l.APIKey != "" {
+		in["api_key"] = l.APIKey
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return Translation{}, err
+	}
+	var out struct {
+		Text     string `json:"translatedText"`
+		Detected struct {
+			Language string `json:"language"`
+		} `json:"detectedLanguage"`
+	}
+	if err := post(ctx, strings.TrimSuffix(l.BaseURL, "/")+"/translate", "application/json", body, nil, &out); err != nil {
+		return Translation{}, err
+	}
+	return Translation{Text: out.Text, From: out.Detected.Language}, nil
+}
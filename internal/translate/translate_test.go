@@ -0,0 +1,71 @@
+package translate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLang(t *testing.T) {
+	for raw, want := range map[string]string{"de": "de", " DE ": "de", "pt-br": "pt-BR", "pt_BR": "pt-BR"} {
+		if got, ok := Lang(raw); !ok || got != want {
+			t.Errorf("Lang(%q) = %q, %v; want %q", raw, got, ok, want)
+		}
+	}
+	for _, raw := range []string{"", "german", "d", "de-", "de-DEU", "../x"} {
+		if _, ok := Lang(raw); ok {
+			t.Errorf("Lang(%q) accepted", raw)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{Provider: "deepl"}); err == nil {
+		t.Error("deepl without an API key accepted")
+	}
+	if _, err := New(Config{Provider: "babelfish"}); err == nil {
+		t.Error("unknown provider accepted")
+	}
+	if p, err := New(Config{Provider: "libretranslate"}); err != nil || p.(LibreTranslate).BaseURL != DefaultLibreTranslateURL {
+		t.Errorf("libretranslate = %+v, %v", p, err)
+	}
+}
+
+func TestDeepL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/translate" || r.Header.Get("Authorization") != "DeepL-Auth-Key k1" ||
+			r.FormValue("target_lang") != "PT-BR" || r.FormValue("text") != "Hello" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"translations":[{"detected_source_language":"EN","text":"Olá"}]}`))
+	}))
+	defer srv.Close()
+	got, err := DeepL{BaseURL: srv.URL + "/v2/", APIKey: "k1"}.Translate(context.Background(), "Hello", "pt-BR")
+	if err != nil || got.Text != "Olá" || got.From != "en" {
+		t.Fatalf("Translate = %+v, %v", got, err)
+	}
+}
+
+func TestLibreTranslate(t *testing.T) {
+	var in map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&in)
+		if in["target"] == "xx" {
+			http.Error(w, `{"error":"unsupported"}`, http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"translatedText":"Hallo","detectedLanguage":{"confidence":90,"language":"en"}}`))
+	}))
+	defer srv.Close()
+	p := LibreTranslate{BaseURL: srv.URL}
+	got, err := p.Translate(context.Background(), "Hello", "de-AT")
+	if err != nil || got.Text != "Hallo" || got.From != "en" || in["target"] != "de" || in["source"] != "auto" {
+		t.Fatalf("Translate = %+v, %v, sent %v", got, err, in)
+	}
+	if _, err := p.Translate(context.Background(), "Hello", "xx"); err == nil {
+		t.Fatal("expected an error from a failing provider")
+	}
+}
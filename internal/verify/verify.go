@@ -0,0 +1,120 @@
+// Package verify confirms that requesters own the email address they submit
+// tickets from. Requesters first seen through the portal or inbound email
+// are created unverified and sent a single-use link; until they follow it,
+// their tickets are allowed, flagged or held according to the queue's policy.
+package verify
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// Policies for tickets from unverified requesters.
+const (
+	PolicyAllow = "allow"
+	PolicyFlag  = "flag"
+	PolicyHold  = "hold"
+)
+
+// TokenTTL is how long a verification link stays valid.
+const TokenTTL = 72 * time.Hour
+
+// Path is where the API serves verification links.
+const Path = "/api/verify-email"
+
+// ErrInvalidToken is returned for unknown, used or expired tokens.
+var ErrInvalidToken = errors.New("invalid or expired verification token")
+
+// DB is the subset of the database used by the package.
+type DB interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// ValidPolicy reports whether p is a known policy.
+func ValidPolicy(p string) bool {
+	return p == PolicyAllow || p == PolicyFlag || p == PolicyHold
+}
+
+// Ticket states reported for unverified requesters' tickets.
+const (
+	StateFlagged = "flagged"
+	StateHeld    = "held"
+)
+
+// TicketState is a SQL expression yielding "flagged" or "held" for tickets
+// whose requester (aliased r) is unverified and whose queue (aliased q)
+// calls for it, and NULL otherwise. Queues without a policy use def.
+func TicketState(def string) string {
+	if !ValidPolicy(def) {
+		def = PolicyAllow
+	}
+	return `(case when not coalesce(r.verified, true) then
+            case coalesce(q.unverified_policy, '` + def + `') when 'flag' then 'flagged' when 'hold' then 'held' end end)`
+}
+
+// UpsertRequester returns the requester with email, creating an unverified
+// one when the address has not been seen. created reports whether it did.
+func UpsertRequester(ctx context.Context, db DB, email, name string) (id string, created bool, err error) {
+	err = db.QueryRow(ctx, `insert into requesters (email, name, verified)
+        values (lower($1), nullif($2,''), false)
+        on conflict (email) do update set name = coalesce(requesters.name, excluded.name)
+        returning id::text, xmax = 0`, strings.TrimSpace(email), strings.TrimSpace(name)).Scan(&id, &created)
+	return id, created, err
+}
+
+// Issue creates a verification link for the requester and queues the email
+// carrying it. Earlier unused links stop working. baseURL is the public
+// address of the helpdesk; without it the email carries only the token.
+func Issue(ctx context.Context, db DB, requesterID, email, baseURL string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	sum := sha256.Sum256([]byte(token))
+	if _, err := db.Exec(ctx, `update requester_verifications set used_at = now() where requester_id = $1 and used_at is null`, requesterID); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, `insert into requester_verifications (requester_id, token_hash, expires_at) values ($1, $2, now() + make_interval(secs => $3))`,
+		requesterID, hex.EncodeToString(sum[:]), TokenTTL.Seconds()); err != nil {
+		return err
+	}
+	data := map[string]any{"Token": token, "ExpiresIn": "3 days"}
+	if baseURL != "" {
+		data["URL"] = strings.TrimRight(baseURL, "/") + Path + "?token=" + token
+	}
+	return outbox.AddJob(ctx, db, "requester_verification:"+hex.EncodeToString(sum[:8]), "", jobs.TypeSendEmail, jobs.Email{
+		To:       email,
+		Template: "requester_verification",
+		Data:     data,
+	})
+}
+
+// Confirm marks the requester owning token verified and returns their ID.
+func Confirm(ctx context.Context, db DB, token string) (string, error) {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	var id string
+	err := db.QueryRow(ctx, `with used as (
+            update requester_verifications set used_at = now()
+            where token_hash = $1 and used_at is null and expires_at > now()
+            returning requester_id)
+        update requesters set verified = true, verified_at = coalesce(verified_at, now())
+        where id = (select requester_id from used)
+        returning id::text`, hex.EncodeToString(sum[:])).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInvalidToken
+	}
+	return id, err
+}
@@ -0,0 +1,80 @@
+package verify
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type row struct{ scan func(dest ...any) error }
+
+func (r row) Scan(dest ...any) error { return r.scan(dest...) }
+
+type fakeDB struct {
+	execs   []string
+	args    [][]any
+	confirm string
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return row{func(dest ...any) error {
+		if db.confirm == "" {
+			return pgx.ErrNoRows
+		}
+		*dest[0].(*string) = db.confirm
+		return nil
+	}}
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	db.execs = append(db.execs, sql)
+	db.args = append(db.args, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestIssue(t *testing.T) {
+	db := &fakeDB{}
+	if err := Issue(context.Background(), db, "r1", "ann@acme.io", "https://help.acme.io/"); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.execs) != 3 || !strings.Contains(db.execs[0], "set used_at = now()") || !strings.Contains(db.execs[2], "insert into outbox") {
+		t.Fatalf("unexpected statements %v", db.execs)
+	}
+	hash := db.args[1][1].(string)
+	payload := db.args[2][2].(string)
+	if !strings.Contains(payload, `"template":"requester_verification"`) || !strings.Contains(payload, `"to":"ann@acme.io"`) ||
+		!strings.Contains(payload, "https://help.acme.io/api/verify-email?token=") {
+		t.Fatalf("unexpected email job %s", payload)
+	}
+	if strings.Contains(payload, hash) {
+		t.Fatal("the email must carry the token, not its hash")
+	}
+
+	db = &fakeDB{}
+	_ = Issue(context.Background(), db, "r1", "ann@acme.io", "")
+	if payload := db.args[2][2].(string); strings.Contains(payload, `"URL"`) || !strings.Contains(payload, `"Token"`) {
+		t.Fatalf("expected a bare token without a public URL, got %s", payload)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	if _, err := Confirm(context.Background(), &fakeDB{}, "nope"); err != ErrInvalidToken {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	id, err := Confirm(context.Background(), &fakeDB{confirm: "r1"}, " tok ")
+	if err != nil || id != "r1" {
+		t.Fatalf("unexpected %q %v", id, err)
+	}
+}
+
+func TestTicketState(t *testing.T) {
+	if s := TicketState(PolicyHold); !strings.Contains(s, "coalesce(q.unverified_policy, 'hold')") {
+		t.Fatalf("expected the default policy in %s", s)
+	}
+	if s := TicketState("'; drop table tickets; --"); !strings.Contains(s, "'allow'") || strings.Contains(s, "drop") {
+		t.Fatalf("expected an invalid default to fall back to allow, got %s", s)
+	}
+}
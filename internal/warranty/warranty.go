@@ -0,0 +1,162 @@
+// Package warranty looks up hardware warranty coverage from manufacturers
+// by serial number. Each vendor is a Provider; the worker picks one by an
+// asset's manufacturer, throttles lookups to the vendor's rate limit and
+// stores the result on the asset.
+//
+// The Dell, Lenovo and Apple GSX providers are stubs: they check their
+// credentials but the vendor APIs are not wired up yet, so configured
+// lookups fail with ErrNotImplemented.
+package warranty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Vendors.
+const (
+	VendorDell   = "dell"
+	VendorLenovo = "lenovo"
+	VendorApple  = "apple"
+)
+
+// Warranty statuses stored on assets.
+const (
+	StatusActive  = "active"
+	StatusExpired = "expired"
+	// StatusUnknown is a lookup the vendor answered without an end date.
+	StatusUnknown = "unknown"
+)
+
+// ErrNotConfigured is returned for a vendor whose provider has no
+// credentials. Retrying does not help.
+var ErrNotConfigured = errors.New("warranty provider not configured")
+
+// ErrNotImplemented is returned by stub providers.
+var ErrNotImplemented = errors.New("warranty provider not implemented")
+
+// ErrNotFound is returned when the vendor does not know the serial number.
+var ErrNotFound = errors.New("serial number not found")
+
+// Permanent reports whether a lookup failing with err would fail again if
+// retried.
+func Permanent(err error) bool {
+	return errors.Is(err, ErrNotConfigured) || errors.Is(err, ErrNotImplemented) || errors.Is(err, ErrNotFound)
+}
+
+// Result is what a vendor reports for a serial number.
+type Result struct {
+	// Coverage names the service level, e.g. "ProSupport".
+	Coverage string
+	// ExpiresOn is the end of the latest coverage; nil when the vendor did
+	// not say.
+	ExpiresOn *time.Time
+}
+
+// Status classifies r as of now.
+func (r Result) Status(now time.Time) string {
+	switch {
+	case r.ExpiresOn == nil:
+		return StatusUnknown
+	case r.ExpiresOn.Before(now.Truncate(24 * time.Hour)):
+		return StatusExpired
+	}
+	return StatusActive
+}
+
+// Provider looks up warranties from one vendor.
+type Provider interface {
+	Lookup(ctx context.Context, serial string) (Result, error)
+	// PerMinute is how many lookups the vendor allows per minute.
+	PerMinute() int
+}
+
+// Dell queries Dell's warranty API, which authenticates with an OAuth
+// client of the TechDirect program.
+type Dell struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// PerMinute implements Provider.
+func (Dell) PerMinute() int { return 60 }
+
+// Lookup implements Provider.
+func (d Dell) Lookup(ctx context.Context, serial string) (Result, error) {
+	return Result{}, ErrNotImplemented
+}
+
+// Lenovo queries Lenovo's support API with a client id issued by Lenovo.
+type Lenovo struct {
+	ClientID string
+}
+
+// PerMinute implements Provider.
+func (Lenovo) PerMinute() int { return 30 }
+
+// Lookup implements Provider.
+func (l Lenovo) Lookup(ctx context.Context, serial string) (Result, error) {
+	return Result{}, ErrNotImplemented
+}
+
+// AppleGSX queries Apple's GSX coverage service for an authorized service
+// provider's sold-to account.
+type AppleGSX struct {
+	SoldTo string
+	Token  string
+}
+
+// PerMinute implements Provider.
+func (AppleGSX) PerMinute() int { return 10 }
+
+// Lookup implements Provider.
+func (g AppleGSX) Lookup(ctx context.Context, serial string) (Result, error) {
+	return Result{}, ErrNotImplemented
+}
+
+// Providers holds the provider for each vendor.
+type Providers struct {
+	Dell   Dell
+	Lenovo Lenovo
+	Apple  AppleGSX
+}
+
+// For returns the provider for vendor, or ErrNotConfigured.
+func (p Providers) For(vendor string) (Provider, error) {
+	switch vendor {
+	case VendorDell:
+		if p.Dell.ClientID == "" || p.Dell.ClientSecret == "" {
+			return nil, ErrNotConfigured
+		}
+		return p.Dell, nil
+	case VendorLenovo:
+		if p.Lenovo.ClientID == "" {
+			return nil, ErrNotConfigured
+		}
+		return p.Lenovo, nil
+	case VendorApple:
+		if p.Apple.SoldTo == "" || p.Apple.Token == "" {
+			return nil, ErrNotConfigured
+		}
+		return p.Apple, nil
+	}
+	return nil, fmt.Errorf("no warranty provider for vendor %q", vendor)
+}
+
+// Vendor returns the vendor of an asset made by manufacturer, or "" when
+// there is no provider for it.
+func Vendor(manufacturer string) string {
+	m := strings.ToLower(strings.TrimSpace(manufacturer))
+	switch {
+	case strings.HasPrefix(m, "dell"):
+		return VendorDell
+	case strings.HasPrefix(m, "lenovo"):
+		return VendorLenovo
+	case strings.HasPrefix(m, "apple"):
+		return VendorApple
+	}
+	return ""
+}
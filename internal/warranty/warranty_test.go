@@ -0,0 +1,61 @@
+package warranty
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVendor(t *testing.T) {
+	cases := map[string]string{
+		"Dell Inc.":   VendorDell,
+		" LENOVO":     VendorLenovo,
+		"Apple, Inc.": VendorApple,
+		"HP":          "",
+		"":            "",
+	}
+	for in, want := range cases {
+		if got := Vendor(in); got != want {
+			t.Errorf("Vendor(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProvidersFor(t *testing.T) {
+	p := Providers{Dell: Dell{ClientID: "id"}, Lenovo: Lenovo{ClientID: "id"}}
+	if _, err := p.For(VendorDell); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected Dell without a secret to be unconfigured, got %v", err)
+	}
+	if _, err := p.For(VendorApple); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("expected Apple to be unconfigured, got %v", err)
+	}
+	lp, err := p.For(VendorLenovo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lp.Lookup(context.Background(), "PF0ABC12"); !Permanent(err) {
+		t.Fatalf("expected the stub to fail permanently, got %v", err)
+	}
+	if _, err := p.For("hp"); err == nil || Permanent(err) {
+		t.Fatalf("expected an unknown vendor error, got %v", err)
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	now := time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)
+	day := func(d time.Time) *time.Time { return &d }
+	cases := []struct {
+		expires *time.Time
+		want    string
+	}{
+		{nil, StatusUnknown},
+		{day(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)), StatusActive},
+		{day(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)), StatusExpired},
+	}
+	for _, tc := range cases {
+		if got := (Result{ExpiresOn: tc.expires}).Status(now); got != tc.want {
+			t.Errorf("Status with expiry %v = %s, want %s", tc.expires, got, tc.want)
+		}
+	}
+}
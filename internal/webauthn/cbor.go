@@ -0,0 +1,125 @@
+package webauthn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxDepth bounds nesting so hostile input cannot exhaust the stack.
+const maxDepth = 16
+
+var errTruncated = errors.New("cbor: truncated input")
+
+// decodeCBOR decodes the first CBOR item in b and returns it with the number
+// of bytes consumed. It supports the subset WebAuthn uses: integers, byte
+// and text strings, arrays, maps, booleans and null. Map keys are int64 or
+// string; values are int64, []byte, string, []any, map[any]any, bool or nil.
+func decodeCBOR(b []byte) (any, int, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("cbor: nesting too deep")
+	}
+	if len(b) == 0 {
+		return nil, 0, errTruncated
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22, 23:
+			return nil, 1, nil
+		}
+		return nil, 0, fmt.Errorf("cbor: unsupported simple value %d", info)
+	}
+	arg, n, err := readArg(b, info)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return int64(arg), n, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, 0, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(arg), n, nil
+	case 2, 3:
+		if arg > uint64(len(b)-n) {
+			return nil, 0, errTruncated
+		}
+		s := b[n : n+int(arg)]
+		if major == 3 {
+			return string(s), n + int(arg), nil
+		}
+		return append([]byte(nil), s...), n + int(arg), nil
+	case 4:
+		if arg > uint64(len(b)) {
+			return nil, 0, errTruncated
+		}
+		out := make([]any, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, m, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			out = append(out, v)
+			n += m
+		}
+		return out, n, nil
+	case 5:
+		if arg > uint64(len(b)) {
+			return nil, 0, errTruncated
+		}
+		out := make(map[any]any, arg)
+		for i := uint64(0); i < arg; i++ {
+			k, m, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, 0, errors.New("cbor: unsupported map key")
+			}
+			v, m, err := decodeItem(b[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			out[k] = v
+		}
+		return out, n, nil
+	}
+	return nil, 0, fmt.Errorf("cbor: unsupported major type %d", major)
+}
+
+// readArg reads the argument of an item header; indefinite lengths are not
+// supported.
+func readArg(b []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(b) < 1+size {
+			return 0, 0, errTruncated
+		}
+		var v uint64
+		for _, c := range b[1 : 1+size] {
+			v = v<<8 | uint64(c)
+		}
+		return v, 1 + size, nil
+	}
+	return 0, 0, fmt.Errorf("cbor: unsupported additional info %d", info)
+}
@@ -0,0 +1,496 @@
+// Package webauthn implements the relying-party side of WebAuthn passkey
+// registration and authentication: building the options passed to
+// navigator.credentials.create/get and verifying the authenticator's
+// responses.
+//
+// Binary fields travel as unpadded base64url strings in the JSON shapes
+// browsers produce with PublicKeyCredential.toJSON. Assertion signatures are
+// verified for ES256, RS256 and EdDSA keys. Attestation statements are
+// recorded by format but not checked against a trust store, so attestation
+// identifies the authenticator model on a best-effort basis only.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Timeout is how long the browser prompt and the server-side challenge stay
+// valid.
+const Timeout = 5 * time.Minute
+
+// COSE algorithm identifiers accepted for credentials, in preference order.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Authenticator data flags.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var (
+	// ErrChallenge means the response does not answer the expected challenge.
+	ErrChallenge = errors.New("webauthn: challenge mismatch")
+	// ErrOrigin means the response came from an origin that is not allowed.
+	ErrOrigin = errors.New("webauthn: origin not allowed")
+	// ErrSignature means the assertion signature did not verify.
+	ErrSignature = errors.New("webauthn: invalid signature")
+	// ErrCloned means the signature counter went backwards, which suggests
+	// the authenticator was cloned.
+	ErrCloned = errors.New("webauthn: signature counter regressed")
+	// ErrUserNotVerified means the authenticator did not verify the user
+	// with a PIN or biometric, so the passkey alone is not a second factor.
+	ErrUserNotVerified = errors.New("webauthn: user not verified")
+)
+
+// Config describes the relying party.
+type Config struct {
+	// RPID is the registrable domain credentials are scoped to, e.g.
+	// "helpdesk.example.com".
+	RPID   string
+	RPName string
+	// Origins are the exact origins allowed to perform ceremonies.
+	Origins []string
+	// Attestation is the conveyance preference sent to authenticators:
+	// "none" (default), "indirect" or "direct".
+	Attestation string
+}
+
+// RP identifies the relying party in creation options.
+type RP struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// User is the account a credential is created for. ID is the opaque user
+// handle, returned by authenticators during discoverable login.
+type User struct {
+	ID          []byte `json:"-"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// MarshalJSON encodes the user handle as base64url.
+func (u User) MarshalJSON() ([]byte, error) {
+	type plain User
+	return json.Marshal(struct {
+		ID string `json:"id"`
+		plain
+	}{Encode(u.ID), plain(u)})
+}
+
+// UnmarshalJSON decodes a user with a base64url handle.
+func (u *User) UnmarshalJSON(b []byte) error {
+	type plain User
+	var in struct {
+		ID string `json:"id"`
+		plain
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	id, err := Decode(in.ID)
+	if err != nil {
+		return err
+	}
+	*u = User(in.plain)
+	u.ID = id
+	return nil
+}
+
+// CredentialParam is an acceptable credential type and algorithm.
+type CredentialParam struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// Descriptor references an existing credential.
+type Descriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// NewDescriptor describes the credential with the given raw ID.
+func NewDescriptor(id []byte, transports []string) Descriptor {
+	return Descriptor{Type: "public-key", ID: Encode(id), Transports: transports}
+}
+
+// AuthenticatorSelection states the authenticator requirements.
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptionsJSON.
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RP                     `json:"rp"`
+	User                   User                   `json:"user"`
+	PubKeyCredParams       []CredentialParam      `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []Descriptor           `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptionsJSON.
+type RequestOptions struct {
+	Challenge        string       `json:"challenge"`
+	Timeout          int64        `json:"timeout"`
+	RPID             string       `json:"rpId"`
+	AllowCredentials []Descriptor `json:"allowCredentials"`
+	UserVerification string       `json:"userVerification"`
+}
+
+// RegistrationResponse is RegistrationResponseJSON.
+type RegistrationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is AuthenticationResponseJSON.
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// Credential is a verified new credential to store.
+type Credential struct {
+	ID                []byte
+	PublicKey         []byte // COSE_Key
+	SignCount         uint32
+	AAGUID            []byte
+	AttestationFormat string
+	Transports        []string
+	UserVerified      bool
+}
+
+// Encode returns b as unpadded base64url.
+func Encode(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+// Decode parses base64url with or without padding.
+func Decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func newChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return Encode(b), nil
+}
+
+func (cfg Config) attestation() string {
+	switch cfg.Attestation {
+	case "indirect", "direct":
+		return cfg.Attestation
+	}
+	return "none"
+}
+
+// NewRegistration builds creation options with a fresh challenge. exclude
+// lists the user's existing credentials so an authenticator is not
+// registered twice.
+func (cfg Config) NewRegistration(user User, exclude []Descriptor) (CreationOptions, error) {
+	ch, err := newChallenge()
+	if err != nil {
+		return CreationOptions{}, err
+	}
+	if exclude == nil {
+		exclude = []Descriptor{}
+	}
+	return CreationOptions{
+		Challenge: ch,
+		RP:        RP{ID: cfg.RPID, Name: cfg.RPName},
+		User:      user,
+		PubKeyCredParams: []CredentialParam{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:                Timeout.Milliseconds(),
+		ExcludeCredentials:     exclude,
+		AuthenticatorSelection: AuthenticatorSelection{ResidentKey: "preferred", UserVerification: "preferred"},
+		Attestation:            cfg.attestation(),
+	}, nil
+}
+
+// NewLogin builds request options with a fresh challenge. An empty allow
+// list lets the browser offer any discoverable passkey for the RP.
+func (cfg Config) NewLogin(allow []Descriptor) (RequestOptions, error) {
+	ch, err := newChallenge()
+	if err != nil {
+		return RequestOptions{}, err
+	}
+	if allow == nil {
+		allow = []Descriptor{}
+	}
+	return RequestOptions{
+		Challenge:        ch,
+		Timeout:          Timeout.Milliseconds(),
+		RPID:             cfg.RPID,
+		AllowCredentials: allow,
+		UserVerification: "required",
+	}, nil
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// Challenge returns the challenge a response answers, read from its client
+// data, so the server can look up the ceremony it belongs to.
+func Challenge(clientDataJSON string) (string, error) {
+	raw, err := Decode(clientDataJSON)
+	if err != nil {
+		return "", fmt.Errorf("webauthn: client data: %w", err)
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return "", fmt.Errorf("webauthn: client data: %w", err)
+	}
+	return cd.Challenge, nil
+}
+
+// checkClientData validates the client data and returns its raw bytes.
+func (cfg Config) checkClientData(encoded, typ, challenge string) ([]byte, error) {
+	raw, err := Decode(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: client data: %w", err)
+	}
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("webauthn: client data: %w", err)
+	}
+	if cd.Type != typ {
+		return nil, fmt.Errorf("webauthn: client data type %q, want %q", cd.Type, typ)
+	}
+	if challenge == "" || cd.Challenge != challenge {
+		return nil, ErrChallenge
+	}
+	if !slices.Contains(cfg.Origins, cd.Origin) {
+		return nil, ErrOrigin
+	}
+	return raw, nil
+}
+
+type authData struct {
+	flags     byte
+	signCount uint32
+	rest      []byte // attested credential data and extensions
+}
+
+func (cfg Config) parseAuthData(b []byte) (authData, error) {
+	if len(b) < 37 {
+		return authData{}, errors.New("webauthn: authenticator data too short")
+	}
+	rpHash := sha256.Sum256([]byte(cfg.RPID))
+	if !bytes.Equal(b[:32], rpHash[:]) {
+		return authData{}, errors.New("webauthn: credential is for a different relying party")
+	}
+	ad := authData{flags: b[32], signCount: binary.BigEndian.Uint32(b[33:37]), rest: b[37:]}
+	if ad.flags&flagUserPresent == 0 {
+		return authData{}, errors.New("webauthn: user not present")
+	}
+	return ad, nil
+}
+
+// VerifyRegistration checks a registration response against the challenge
+// issued for it and returns the credential to store.
+func (cfg Config) VerifyRegistration(r RegistrationResponse, challenge string) (Credential, error) {
+	if r.Type != "public-key" {
+		return Credential{}, errors.New("webauthn: unexpected credential type")
+	}
+	if _, err := cfg.checkClientData(r.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+	rawObj, err := Decode(r.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: attestation object: %w", err)
+	}
+	v, _, err := decodeCBOR(rawObj)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: attestation object: %w", err)
+	}
+	obj, _ := v.(map[any]any)
+	format, _ := obj["fmt"].(string)
+	rawAuth, _ := obj["authData"].([]byte)
+	if format == "" || rawAuth == nil {
+		return Credential{}, errors.New("webauthn: malformed attestation object")
+	}
+	if stmt, _ := obj["attStmt"].(map[any]any); format == "none" && len(stmt) != 0 {
+		return Credential{}, errors.New("webauthn: none attestation with a statement")
+	}
+	ad, err := cfg.parseAuthData(rawAuth)
+	if err != nil {
+		return Credential{}, err
+	}
+	if ad.flags&flagAttested == 0 || len(ad.rest) < 18 {
+		return Credential{}, errors.New("webauthn: no attested credential data")
+	}
+	aaguid := ad.rest[:16]
+	idLen := int(binary.BigEndian.Uint16(ad.rest[16:18]))
+	if idLen > 1023 || len(ad.rest) < 18+idLen {
+		return Credential{}, errors.New("webauthn: malformed credential id")
+	}
+	credID := ad.rest[18 : 18+idLen]
+	if rawID, err := Decode(r.RawID); err != nil || !bytes.Equal(rawID, credID) {
+		return Credential{}, errors.New("webauthn: credential id does not match authenticator data")
+	}
+	_, n, err := decodeCBOR(ad.rest[18+idLen:])
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: public key: %w", err)
+	}
+	key := ad.rest[18+idLen : 18+idLen+n]
+	if _, _, err := parseKey(key); err != nil {
+		return Credential{}, err
+	}
+	return Credential{
+		ID:                append([]byte(nil), credID...),
+		PublicKey:         append([]byte(nil), key...),
+		SignCount:         ad.signCount,
+		AAGUID:            append([]byte(nil), aaguid...),
+		AttestationFormat: format,
+		Transports:        r.Response.Transports,
+		UserVerified:      ad.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion checks an authentication response against the challenge
+// and the stored credential and returns the new signature counter.
+func (cfg Config) VerifyAssertion(r AssertionResponse, challenge string, publicKey []byte, signCount uint32) (uint32, error) {
+	if r.Type != "public-key" {
+		return 0, errors.New("webauthn: unexpected credential type")
+	}
+	rawClient, err := cfg.checkClientData(r.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+	rawAuth, err := Decode(r.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("webauthn: authenticator data: %w", err)
+	}
+	ad, err := cfg.parseAuthData(rawAuth)
+	if err != nil {
+		return 0, err
+	}
+	if ad.flags&flagUserVerified == 0 {
+		return 0, ErrUserNotVerified
+	}
+	sig, err := Decode(r.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("webauthn: signature: %w", err)
+	}
+	clientHash := sha256.Sum256(rawClient)
+	if err := verify(publicKey, append(append([]byte(nil), rawAuth...), clientHash[:]...), sig); err != nil {
+		return 0, err
+	}
+	// Authenticators without a counter always report zero.
+	if (ad.signCount != 0 || signCount != 0) && ad.signCount <= signCount {
+		return 0, ErrCloned
+	}
+	return ad.signCount, nil
+}
+
+// parseKey decodes a COSE_Key into a crypto public key and its algorithm.
+func parseKey(cose []byte) (crypto.PublicKey, int64, error) {
+	v, _, err := decodeCBOR(cose)
+	if err != nil {
+		return nil, 0, fmt.Errorf("webauthn: public key: %w", err)
+	}
+	m, _ := v.(map[any]any)
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("webauthn: invalid P-256 key")
+		}
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, 0, fmt.Errorf("webauthn: invalid P-256 key: %w", err)
+		}
+		return pub, alg, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, 0, errors.New("webauthn: invalid RSA key")
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, alg, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("webauthn: invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), alg, nil
+	}
+	return nil, 0, fmt.Errorf("webauthn: unsupported key type %d / algorithm %d", kty, alg)
+}
+
+func verify(cose, data, sig []byte) error {
+	pub, _, err := parseKey(cose)
+	if err != nil {
+		return err
+	}
+	ok := false
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		h := sha256.Sum256(data)
+		ok = ecdsa.VerifyASN1(k, h[:], sig)
+	case *rsa.PublicKey:
+		h := sha256.Sum256(data)
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], sig) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, data, sig)
+	}
+	if !ok {
+		return ErrSignature
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package webauthn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mark3748/helpdesk-go/internal/webauthn"
+	"github.com/mark3748/helpdesk-go/internal/webauthn/webauthntest"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	cfg := webauthn.Config{RPID: "helpdesk.example.com", RPName: "Helpdesk", Origins: []string{"https://helpdesk.example.com"}}
+	auth := webauthntest.New(cfg.RPID, "https://helpdesk.example.com")
+
+	opts, err := cfg.NewRegistration(webauthn.User{ID: []byte("user-1"), Name: "alice"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Attestation != "none" || len(opts.PubKeyCredParams) == 0 {
+		t.Fatalf("unexpected creation options %+v", opts)
+	}
+	reg := auth.Register(opts)
+	if ch, err := webauthn.Challenge(reg.Response.ClientDataJSON); err != nil || ch != opts.Challenge {
+		t.Fatalf("expected challenge %q, got %q (%v)", opts.Challenge, ch, err)
+	}
+	if _, err := cfg.VerifyRegistration(reg, "other"); !errors.Is(err, webauthn.ErrChallenge) {
+		t.Fatalf("expected challenge mismatch, got %v", err)
+	}
+	cred, err := cfg.VerifyRegistration(reg, opts.Challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cred.ID) != string(auth.CredID) || cred.AttestationFormat != "none" || cred.Transports[0] != "internal" {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	login, err := cfg.NewLogin(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.UserVerification != "required" {
+		t.Fatalf("expected user verification required, got %q", login.UserVerification)
+	}
+	resp := auth.Login(login)
+	count, err := cfg.VerifyAssertion(resp, login.Challenge, cred.PublicKey, cred.SignCount)
+	if err != nil || count != 1 {
+		t.Fatalf("expected valid assertion with count 1, got %d %v", count, err)
+	}
+	if _, err := cfg.VerifyAssertion(resp, login.Challenge, cred.PublicKey, count); !errors.Is(err, webauthn.ErrCloned) {
+		t.Fatalf("expected replayed counter to be rejected, got %v", err)
+	}
+
+	auth.NoUserVerification = true
+	if _, err := cfg.VerifyAssertion(auth.Login(login), login.Challenge, cred.PublicKey, 0); !errors.Is(err, webauthn.ErrUserNotVerified) {
+		t.Fatalf("expected unverified user to be rejected, got %v", err)
+	}
+	auth.NoUserVerification = false
+
+	tampered := auth.Login(login)
+	tampered.Response.Signature = auth.Login(login).Response.Signature[:10] + "AAAA"
+	if _, err := cfg.VerifyAssertion(tampered, login.Challenge, cred.PublicKey, 0); err == nil {
+		t.Fatal("expected tampered signature to fail")
+	}
+
+	other := webauthn.Config{RPID: cfg.RPID, Origins: []string{"https://portal.example.com"}}
+	if _, err := other.VerifyAssertion(auth.Login(login), login.Challenge, cred.PublicKey, 0); !errors.Is(err, webauthn.ErrOrigin) {
+		t.Fatalf("expected origin rejection, got %v", err)
+	}
+	evil := webauthn.Config{RPID: "evil.example", Origins: cfg.Origins}
+	if _, err := evil.VerifyAssertion(auth.Login(login), login.Challenge, cred.PublicKey, 0); err == nil {
+		t.Fatal("expected rp id mismatch to fail")
+	}
+}
+
+func TestDecodeCBORRejectsTruncatedInput(t *testing.T) {
+	cfg := webauthn.Config{RPID: "x.example", Origins: []string{"https://x.example"}}
+	auth := webauthntest.New(cfg.RPID, "https://x.example")
+	opts, _ := cfg.NewRegistration(webauthn.User{ID: []byte("u")}, nil)
+	reg := auth.Register(opts)
+	raw, _ := webauthn.Decode(reg.Response.AttestationObject)
+	reg.Response.AttestationObject = webauthn.Encode(raw[:len(raw)-20])
+	if _, err := cfg.VerifyRegistration(reg, opts.Challenge); err == nil {
+		t.Fatal("expected truncated attestation object to fail")
+	}
+}
@@ -0,0 +1,135 @@
+// Package webauthntest provides a software authenticator for exercising
+// passkey registration and login in tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+
+	"github.com/mark3748/helpdesk-go/internal/webauthn"
+)
+
+// Authenticator is a single ES256 passkey.
+type Authenticator struct {
+	RPID      string
+	Origin    string
+	CredID    []byte
+	UserID    []byte
+	SignCount uint32
+	// NoUserVerification leaves the user verified flag out of assertions,
+	// like a security key without a PIN.
+	NoUserVerification bool
+	key                *ecdsa.PrivateKey
+}
+
+// New creates an authenticator with a fresh key for rpID, acting for origin.
+func New(rpID, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &Authenticator{RPID: rpID, Origin: origin, CredID: id, key: key}
+}
+
+// Register answers creation options.
+func (a *Authenticator) Register(opts webauthn.CreationOptions) webauthn.RegistrationResponse {
+	a.UserID = opts.User.ID
+	pub, _ := a.key.PublicKey.Bytes()
+	cose := encode(map[any]any{int64(1): int64(2), int64(3): int64(webauthn.AlgES256), int64(-1): int64(1),
+		int64(-2): pub[1:33], int64(-3): pub[33:]})
+	att := make([]byte, 18, 18+len(a.CredID)+len(cose))
+	binary.BigEndian.PutUint16(att[16:], uint16(len(a.CredID)))
+	att = append(append(att, a.CredID...), cose...)
+	auth := a.authData(0x41, att)
+
+	var r webauthn.RegistrationResponse
+	r.ID, r.RawID, r.Type = webauthn.Encode(a.CredID), webauthn.Encode(a.CredID), "public-key"
+	r.Response.ClientDataJSON = a.clientData("webauthn.create", opts.Challenge)
+	r.Response.AttestationObject = webauthn.Encode(encode(map[any]any{"fmt": "none", "attStmt": map[any]any{}, "authData": auth}))
+	r.Response.Transports = []string{"internal"}
+	return r
+}
+
+// Login answers request options, bumping the signature counter.
+func (a *Authenticator) Login(opts webauthn.RequestOptions) webauthn.AssertionResponse {
+	a.SignCount++
+	flags := byte(0x05)
+	if a.NoUserVerification {
+		flags = 0x01
+	}
+	auth := a.authData(flags, nil)
+	var r webauthn.AssertionResponse
+	r.ID, r.RawID, r.Type = webauthn.Encode(a.CredID), webauthn.Encode(a.CredID), "public-key"
+	r.Response.ClientDataJSON = a.clientData("webauthn.get", opts.Challenge)
+	r.Response.AuthenticatorData = webauthn.Encode(auth)
+	r.Response.UserHandle = webauthn.Encode(a.UserID)
+	cd, _ := webauthn.Decode(r.Response.ClientDataJSON)
+	h := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(auth, h[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	r.Response.Signature = webauthn.Encode(sig)
+	return r
+}
+
+func (a *Authenticator) authData(flags byte, rest []byte) []byte {
+	h := sha256.Sum256([]byte(a.RPID))
+	out := append(h[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(out[33:], a.SignCount)
+	return append(out, rest...)
+}
+
+func (a *Authenticator) clientData(typ, challenge string) string {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": a.Origin})
+	return webauthn.Encode(b)
+}
+
+// encode writes the CBOR subset the authenticator needs. Map keys are
+// sorted for deterministic output.
+func encode(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+		}
+	}
+	switch x := v.(type) {
+	case int64:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case map[any]any:
+		keys := make([][]byte, 0, len(x))
+		vals := map[string][]byte{}
+		for k, val := range x {
+			kb := encode(k)
+			keys = append(keys, kb)
+			vals[string(kb)] = encode(val)
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) })
+		out := head(5, uint64(len(x)))
+		for _, k := range keys {
+			out = append(append(out, k...), vals[string(k)]...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported cbor value")
+}
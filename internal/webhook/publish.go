@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+	"github.com/mark3748/helpdesk-go/internal/outbox"
+)
+
+// MaxAttempts is how many times the worker tries to deliver a payload
+// before giving up on it.
+const MaxAttempts = 6
+
+// backoffBase is the wait after a first failed attempt; each further
+// failure doubles it.
+const backoffBase = time.Minute
+
+// Publish queues event about a ticket for delivery to the subscriptions
+// selecting it. It goes through the outbox so the event is sent only if
+// db's transaction commits; the worker renders the payload from the ticket
+// as it then is, with data merged in.
+func Publish(ctx context.Context, db outbox.Execer, event, ticketID string, data map[string]any) error {
+	if _, ok := Lookup(event); !ok {
+		return errors.New("unknown event " + event)
+	}
+	id := uuid.NewString()
+	return outbox.AddJob(ctx, db, "webhook:"+id, id, jobs.TypeWebhookDispatch, jobs.WebhookDispatch{
+		ID:         id,
+		Event:      event,
+		TicketID:   ticketID,
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// Retryable reports whether a failed attempt may succeed later: transport
+// errors, timeouts, rate limiting and server errors. Other responses mean
+// the endpoint rejected the payload and will keep doing so.
+func Retryable(r Result) bool {
+	if r.OK() {
+		return false
+	}
+	if r.Error != "" {
+		return true
+	}
+	return r.StatusCode == http.StatusRequestTimeout || r.StatusCode == http.StatusTooManyRequests || r.StatusCode >= 500
+}
+
+// Backoff returns how long to wait after attempt failed before the next
+// one: a minute, doubling with every attempt.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return backoffBase << (attempt - 1)
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mark3748/helpdesk-go/internal/jobs"
+)
+
+type captureExec struct{ args [][]any }
+
+func (c *captureExec) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	c.args = append(c.args, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func TestPublish(t *testing.T) {
+	db := &captureExec{}
+	if err := Publish(context.Background(), db, "sla.breached", "t1", map[string]any{"sla": "response"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.args) != 1 || !strings.HasPrefix(db.args[0][1].(string), "webhook:") {
+		t.Fatalf("unexpected outbox rows %v", db.args)
+	}
+	j, err := jobs.Decode([]byte(db.args[0][2].(string)))
+	if err != nil || j.Type != jobs.TypeWebhookDispatch {
+		t.Fatalf("unexpected job %+v: %v", j, err)
+	}
+	var d jobs.WebhookDispatch
+	if err := json.Unmarshal(j.Data, &d); err != nil || d.Event != "sla.breached" || d.TicketID != "t1" || d.ID != j.ID || d.Data["sla"] != "response" {
+		t.Fatalf("unexpected dispatch %+v: %v", d, err)
+	}
+	if err := Publish(context.Background(), db, "ticket.exploded", "t1", nil); err == nil || len(db.args) != 1 {
+		t.Fatal("expected unknown events to be refused")
+	}
+}
+
+func TestRetryableAndBackoff(t *testing.T) {
+	for _, tc := range []struct {
+		res  Result
+		want bool
+	}{
+		{Result{StatusCode: http.StatusOK}, false},
+		{Result{Error: "connection refused"}, true},
+		{Result{StatusCode: http.StatusTooManyRequests}, true},
+		{Result{StatusCode: http.StatusBadGateway}, true},
+		{Result{StatusCode: http.StatusGone}, false},
+	} {
+		if got := Retryable(tc.res); got != tc.want {
+			t.Errorf("Retryable(%+v) = %v", tc.res, got)
+		}
+	}
+	if Backoff(1) != time.Minute || Backoff(4) != 8*time.Minute {
+		t.Fatalf("unexpected backoff %v, %v", Backoff(1), Backoff(4))
+	}
+}
+
+func TestMask(t *testing.T) {
+	mask, err := Mask([]string{"ticket.created", "sla.breached"})
+	if err != nil || mask != 33 {
+		t.Fatalf("Mask = %d, %v", mask, err)
+	}
+	if got := Selected(mask); len(got) != 2 || got[1] != "sla.breached" {
+		t.Fatalf("Selected = %v", got)
+	}
+	if _, err := Mask([]string{"ticket.exploded"}); err == nil {
+		t.Fatal("expected unknown event to fail")
+	}
+}
@@ -0,0 +1,209 @@
+// Package webhook signs and delivers outbound webhook payloads, queues
+// events for the worker to deliver and builds the sample payloads used by
+// the admin test console.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a webhook event type and its bit in webhooks.event_mask.
+type Event struct {
+	Name string `json:"name"`
+	Bit  int    `json:"bit"`
+}
+
+// Events lists the event types a subscription can select.
+var Events = []Event{
+	{Name: "ticket.created", Bit: 1},
+	{Name: "ticket.updated", Bit: 2},
+	{Name: "ticket.assigned", Bit: 4},
+	{Name: "ticket.resolved", Bit: 8},
+	{Name: "comment.created", Bit: 16},
+	{Name: "sla.breached", Bit: 32},
+}
+
+// Lookup returns the event named name.
+func Lookup(name string) (Event, bool) {
+	for _, e := range Events {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// Names returns the event type names in mask order.
+func Names() []string {
+	out := make([]string, len(Events))
+	for i, e := range Events {
+		out[i] = e.Name
+	}
+	return out
+}
+
+// Mask returns the event_mask selecting the named events.
+func Mask(names []string) (int, error) {
+	mask := 0
+	for _, n := range names {
+		e, ok := Lookup(n)
+		if !ok {
+			return 0, errors.New("unknown event " + n)
+		}
+		mask |= e.Bit
+	}
+	return mask, nil
+}
+
+// Selected returns the names of the events mask selects, in mask order.
+func Selected(mask int) []string {
+	out := []string{}
+	for _, e := range Events {
+		if mask&e.Bit != 0 {
+			out = append(out, e.Name)
+		}
+	}
+	return out
+}
+
+// Request headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Helpdesk-Event"
+	HeaderDelivery  = "X-Helpdesk-Delivery"
+	HeaderTimestamp = "X-Helpdesk-Timestamp"
+	HeaderSignature = "X-Helpdesk-Signature"
+)
+
+// ExcerptLimit caps how much of the response body is kept.
+const ExcerptLimit = 2048
+
+// Timeout bounds a single delivery attempt.
+const Timeout = 10 * time.Second
+
+// Client delivers payloads. Redirects are not followed so the console shows
+// what the endpoint actually answered.
+var Client = &http.Client{
+	Timeout: Timeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Sign returns the signature header value for body: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret.
+func Sign(secret string, ts int64, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(strconv.FormatInt(ts, 10)))
+	m.Write([]byte("."))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify reports whether sig is a valid signature of body, for receivers and
+// tests.
+func Verify(secret string, ts int64, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(sig))
+}
+
+// Sample builds a payload for event shaped like a real delivery but flagged
+// as a test and filled with placeholder data.
+func Sample(event, deliveryID string, now time.Time) ([]byte, error) {
+	if _, ok := Lookup(event); !ok {
+		return nil, errors.New("unknown event " + event)
+	}
+	ticket := map[string]any{
+		"id":         "00000000-0000-4000-8000-000000000001",
+		"number":     "TKT-000001",
+		"title":      "Sample ticket from the webhook test console",
+		"status":     "Open",
+		"priority":   3,
+		"requester":  map[string]any{"email": "requester@example.com", "name": "Sample Requester"},
+		"created_at": now.Add(-time.Hour).UTC().Format(time.RFC3339),
+		"updated_at": now.UTC().Format(time.RFC3339),
+	}
+	data := map[string]any{"ticket": ticket}
+	switch event {
+	case "ticket.updated":
+		data["changes"] = map[string]any{"priority": map[string]any{"from": 2, "to": 3}}
+	case "ticket.assigned":
+		ticket["assignee"] = map[string]any{"email": "agent@example.com", "name": "Sample Agent"}
+	case "ticket.resolved":
+		ticket["status"] = "Resolved"
+		ticket["resolved_at"] = now.UTC().Format(time.RFC3339)
+	case "comment.created":
+		data["comment"] = map[string]any{
+			"id":          "00000000-0000-4000-8000-000000000002",
+			"body":        "This is a sample comment.",
+			"is_internal": false,
+			"author":      map[string]any{"email": "agent@example.com", "name": "Sample Agent"},
+		}
+	case "sla.breached":
+		data["sla"] = map[string]any{"target": "response", "elapsed_ms": 14460000, "target_ms": 14400000}
+	}
+	return json.Marshal(map[string]any{
+		"id":          deliveryID,
+		"event":       event,
+		"test":        true,
+		"occurred_at": now.UTC().Format(time.RFC3339),
+		"data":        data,
+	})
+}
+
+// Result describes one delivery attempt.
+type Result struct {
+	StatusCode int    `json:"status_code"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Excerpt    string `json:"response_excerpt"`
+	Error      string `json:"error,omitempty"`
+}
+
+// OK reports whether the endpoint accepted the delivery with a 2xx status.
+func (r Result) OK() bool { return r.Error == "" && r.StatusCode >= 200 && r.StatusCode < 300 }
+
+// Send posts body to url with the event, delivery and signature headers.
+// Transport failures are reported in Result.Error rather than returned so
+// they can be recorded alongside successful attempts. The signature header is
+// omitted when secret is empty.
+func Send(ctx context.Context, client *http.Client, url, secret, event, deliveryID string, body []byte, now time.Time) Result {
+	if client == nil {
+		client = Client
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Result{Error: err.Error()}
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "helpdesk-webhooks/1")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, ts, body))
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{LatencyMS: time.Since(start).Milliseconds(), Error: err.Error()}
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, ExcerptLimit))
+	res := Result{StatusCode: resp.StatusCode, LatencyMS: time.Since(start).Milliseconds()}
+	// Trim a multi-byte rune split by the limit so the excerpt stays valid text.
+	for len(raw) > 0 && !utf8.Valid(raw) {
+		raw = raw[:len(raw)-1]
+	}
+	res.Excerpt = string(raw)
+	return res
+}
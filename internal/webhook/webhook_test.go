@@ -0,0 +1,61 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleAndSend(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	var gotSig, gotEvent string
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get(HeaderSignature)
+		gotEvent = r.Header.Get(HeaderEvent)
+		gotBody, _ = io.ReadAll(r.Body)
+		if ts := r.Header.Get(HeaderTimestamp); ts != strconv.FormatInt(now.Unix(), 10) {
+			t.Errorf("unexpected timestamp %q", ts)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(strings.Repeat("x", ExcerptLimit+100)))
+	}))
+	defer srv.Close()
+
+	body, err := Sample("comment.created", "d1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil || payload["test"] != true || payload["event"] != "comment.created" {
+		t.Fatalf("unexpected payload %s", body)
+	}
+	res := Send(context.Background(), nil, srv.URL, "s3cret", "comment.created", "d1", body, now)
+	if !res.OK() || res.StatusCode != http.StatusAccepted || len(res.Excerpt) != ExcerptLimit {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if gotEvent != "comment.created" || !Verify("s3cret", now.Unix(), gotBody, gotSig) {
+		t.Fatalf("bad signature %q for event %q", gotSig, gotEvent)
+	}
+	if Verify("other", now.Unix(), gotBody, gotSig) {
+		t.Fatal("expected signature to depend on the secret")
+	}
+}
+
+func TestSendReportsTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	res := Send(context.Background(), nil, srv.URL, "", "ticket.created", "d1", []byte(`{}`), time.Now())
+	if res.OK() || res.Error == "" {
+		t.Fatalf("expected transport error, got %+v", res)
+	}
+	if _, err := Sample("ticket.exploded", "d1", time.Now()); err == nil {
+		t.Fatal("expected unknown event to fail")
+	}
+}
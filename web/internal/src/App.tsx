@@ -11,6 +11,12 @@ import StorageSettings from './components/admin/StorageSettings';
 import AdminSettings from './components/admin/AdminSettings';
 import DiscordSettings from './components/admin/DiscordSettings';
 import AdminUsers from './components/admin/AdminUsers';
+import AdminRoles from './components/admin/AdminRoles';
+import AdminWebhooks from './components/admin/AdminWebhooks';
+import AdminCategoryRules from './components/admin/AdminCategoryRules';
+import AdminListScopes from './components/admin/AdminListScopes';
+import AdminCSAT from './components/admin/AdminCSAT';
+import AdminSLAPriority from './components/admin/AdminSLAPriority';
 import QueueManager from './components/manager/QueueManager';
 import ManagerAnalytics from './components/manager/ManagerAnalytics';
 import Login from './components/Login';
@@ -70,6 +76,12 @@ export default function App() {
                   <Route path="/settings/storage" element={<StorageSettings />} />
                   <Route path="/settings/discord" element={<DiscordSettings />} />
                   <Route path="/settings/users" element={<AdminUsers />} />
+                  <Route path="/settings/roles" element={<AdminRoles />} />
+                  <Route path="/settings/webhooks" element={<AdminWebhooks />} />
+                  <Route path="/settings/categorization" element={<AdminCategoryRules />} />
+                  <Route path="/settings/list-scopes" element={<AdminListScopes />} />
+                  <Route path="/settings/csat" element={<AdminCSAT />} />
+                  <Route path="/settings/sla-priority" element={<AdminSLAPriority />} />
                   <Route path="/assets/categories" element={<AssetCategories />} />
                   <Route path="/assets/import" element={<AssetImport />} />
                   <Route path="/assets/analytics" element={<AssetAnalytics />} />
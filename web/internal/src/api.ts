@@ -163,6 +163,7 @@ export function useSystemInfo() {
         mail_status: 'configured' | 'not_configured';
         oidc_status: 'configured' | 'not_configured';
         discord_status: 'configured' | 'not_configured';
+        passkey_status: 'configured' | 'not_configured';
       }>('/system/info'),
   });
 }
@@ -220,6 +221,40 @@ export function useSendTestEmail() {
   });
 }
 
+export interface MailTemplate {
+  name: string;
+  sample: Record<string, unknown>;
+}
+
+export interface MailPreview {
+  template: string;
+  subject: string;
+  text: string;
+  html: string;
+  data: Record<string, unknown>;
+  sandbox_to?: string;
+  to?: string;
+  deliver_to?: string;
+}
+
+export function useMailTemplates() {
+  return useQuery({
+    queryKey: ['mail-templates'],
+    queryFn: () => apiFetch<MailTemplate[]>('/settings/mail/templates'),
+  });
+}
+
+export function usePreviewMail() {
+  return useMutation({
+    mutationFn: (body: { template: string; data?: Record<string, unknown>; to?: string }) =>
+      apiFetch<MailPreview>('/settings/mail/preview', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body),
+      }),
+  });
+}
+
 export function useTestConnection() {
   const qc = useQueryClient();
   return useMutation({
@@ -3,6 +3,7 @@ import { Form, Input, Button, Typography, Alert, Divider } from 'antd';
 import { useNavigate } from 'react-router-dom';
 import { useQueryClient } from '@tanstack/react-query';
 import { useSystemInfo } from '../api';
+import { loginWithPasskey, passkeysSupported } from '../shared/passkeys';
 
 export default function Login() {
   const [loading, setLoading] = useState(false);
@@ -10,6 +11,21 @@ export default function Login() {
   const nav = useNavigate();
   const qc = useQueryClient();
   const { data: systemInfo } = useSystemInfo();
+  const [form] = Form.useForm();
+
+  async function onPasskey() {
+    setLoading(true);
+    setError(null);
+    try {
+      await loginWithPasskey(form.getFieldValue('username'));
+      await qc.invalidateQueries({ queryKey: ['me'] });
+      nav('/tickets', { replace: true });
+    } catch (e: any) {
+      setError(e?.message || 'Passkey sign-in failed');
+    } finally {
+      setLoading(false);
+    }
+  }
 
   async function onFinish(values: { username: string; password: string }) {
     setLoading(true);
@@ -35,7 +51,7 @@ export default function Login() {
     <div style={{ maxWidth: 360, margin: '10vh auto', background: '#fff', padding: 24, borderRadius: 8 }}>
       <Typography.Title level={4} style={{ textAlign: 'center' }}>Sign in</Typography.Title>
       {error && <Alert type="error" message={error} style={{ marginBottom: 12 }} />}
-      <Form layout="vertical" onFinish={onFinish} initialValues={(import.meta as any).env?.DEV ? { username: 'admin', password: 'admin' } : {}}>
+      <Form form={form} layout="vertical" onFinish={onFinish} initialValues={(import.meta as any).env?.DEV ? { username: 'admin', password: 'admin' } : {}}>
         <Form.Item label="Username" name="username" rules={[{ required: true }]}>
           <Input autoFocus />
         </Form.Item>
@@ -45,6 +61,11 @@ export default function Login() {
         <Button type="primary" htmlType="submit" block loading={loading}>
           Log in
         </Button>
+        {systemInfo?.passkey_status === 'configured' && passkeysSupported() && (
+          <Button block style={{ marginTop: 8 }} onClick={onPasskey} disabled={loading}>
+            Sign in with a passkey
+          </Button>
+        )}
         {systemInfo?.oidc_status === 'configured' && (
           <>
             <Divider>OR</Divider>
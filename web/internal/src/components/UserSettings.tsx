@@ -1,5 +1,41 @@
 import { useEffect, useState, useCallback } from 'react';
-import { Button, Form, Input, Alert, Typography, Space, Divider } from 'antd';
+import { Button, Form, Input, Alert, Typography, Space, Divider, Table, Avatar, Upload, Popconfirm, Select, Switch } from 'antd';
+import { fetchCapabilities } from '../shared/api';
+import { deletePasskey, listPasskeys, registerPasskey, passkeysSupported, type Passkey } from '../shared/passkeys';
+
+type Login = { id: string; method: string; ip: string; user_agent: string; created_at: string; expires_at: string };
+type Security = {
+  logins: Login[];
+  sessions: Login[];
+  password_changes: { ip: string; user_agent: string; changed_at: string }[];
+};
+
+type Channel = {
+  id: string;
+  kind: 'sms' | 'push' | 'ntfy';
+  address: string;
+  events: string[];
+  enabled: boolean;
+  last_sent_at?: string;
+  last_error?: string;
+};
+
+const when = (v: string) => new Date(v).toLocaleString();
+
+const channelKinds = [
+  { value: 'sms', label: 'SMS' },
+  { value: 'push', label: 'Push webhook' },
+  { value: 'ntfy', label: 'ntfy topic' },
+];
+const channelEvents = [
+  { value: 'sla_breach', label: 'SLA breach' },
+  { value: 'ticket_assigned', label: 'Assigned to me' },
+];
+const addressHint: Record<string, string> = {
+  sms: '+15551234567',
+  push: 'https://push.example.com/hook',
+  ntfy: 'my-oncall-topic',
+};
 
 export default function UserSettings() {
   const [loading, setLoading] = useState(false);
@@ -7,6 +43,13 @@ export default function UserSettings() {
   const [ok, setOk] = useState<string | null>(null);
   const [profile, setProfile] = useState<{ email?: string; display_name?: string }>({});
   const [form] = Form.useForm();
+  const [security, setSecurity] = useState<Security | null>(null);
+  const [avatarUrl, setAvatarUrl] = useState<string>('');
+  const [passkeys, setPasskeys] = useState<Passkey[] | null>(null);
+  const [passkeyName, setPasskeyName] = useState('');
+  const [channels, setChannels] = useState<Channel[] | null>(null);
+  const [channelForm] = Form.useForm();
+  const [channelKind, setChannelKind] = useState('sms');
 
   const load = useCallback(async () => {
     try {
@@ -16,6 +59,7 @@ export default function UserSettings() {
       const p = await res.json();
       const next = { email: p.email || '', display_name: p.display_name || '' };
       setProfile(next);
+      setAvatarUrl(p.avatar_url || '');
       form.setFieldsValue(next);
     } catch (e: any) {
       setError(e?.message || 'Failed to load profile');
@@ -23,6 +67,102 @@ export default function UserSettings() {
   }, [form]);
 
   useEffect(() => { load(); }, [load]);
+  useEffect(() => {
+    (async () => {
+      try {
+        const res = await fetch('/api/me/security', { credentials: 'include' });
+        if (res.ok) setSecurity(await res.json());
+      } catch { /* Error loading security activity */ }
+    })();
+  }, []);
+  useEffect(() => {
+    (async () => {
+      try {
+        const caps = await fetchCapabilities();
+        if (caps.features.passkeys && passkeysSupported()) setPasskeys(await listPasskeys());
+      } catch { /* Passkeys unavailable */ }
+    })();
+  }, []);
+
+  const loadChannels = useCallback(async () => {
+    const res = await fetch('/api/me/notification-channels', { credentials: 'include' });
+    if (res.ok) setChannels((await res.json()).channels);
+  }, []);
+  useEffect(() => { loadChannels().catch(() => { /* Channels are for agents only */ }); }, [loadChannels]);
+
+  async function channelRequest(url: string, method: string, body?: unknown) {
+    setOk(null); setError(null);
+    const res = await fetch(url, {
+      method,
+      headers: body ? { 'Content-Type': 'application/json' } : undefined,
+      credentials: 'include',
+      body: body ? JSON.stringify(body) : undefined,
+    });
+    if (!res.ok) {
+      const err = (await res.json().catch(() => ({}))).error || {};
+      throw new Error((Object.values(err.field_errors || {})[0] as string) || err.message || 'Request failed');
+    }
+  }
+
+  async function addChannel(values: { kind: string; address: string; events: string[] }) {
+    try {
+      await channelRequest('/api/me/notification-channels', 'POST', values);
+      channelForm.resetFields(['address']);
+      await loadChannels();
+      setOk('Notification channel added');
+    } catch (e: any) {
+      setError(e?.message || 'Failed to add notification channel');
+    }
+  }
+
+  async function updateChannel(id: string, patch: Partial<Pick<Channel, 'events' | 'enabled'>>) {
+    try {
+      await channelRequest(`/api/me/notification-channels/${id}`, 'PATCH', patch);
+      await loadChannels();
+    } catch (e: any) {
+      setError(e?.message || 'Failed to update notification channel');
+    }
+  }
+
+  async function removeChannel(id: string) {
+    try {
+      await channelRequest(`/api/me/notification-channels/${id}`, 'DELETE');
+      await loadChannels();
+    } catch (e: any) {
+      setError(e?.message || 'Failed to remove notification channel');
+    }
+  }
+
+  async function testChannel(id: string) {
+    try {
+      await channelRequest(`/api/me/notification-channels/${id}/test`, 'POST');
+      setOk('Test notification queued');
+    } catch (e: any) {
+      setError(e?.message || 'Failed to send test notification');
+    }
+  }
+
+  async function addPasskey() {
+    setOk(null); setError(null);
+    try {
+      await registerPasskey(passkeyName.trim());
+      setPasskeyName('');
+      setPasskeys(await listPasskeys());
+      setOk('Passkey added');
+    } catch (e: any) {
+      setError(e?.message || 'Failed to add passkey');
+    }
+  }
+
+  async function removePasskey(id: string) {
+    setOk(null); setError(null);
+    try {
+      await deletePasskey(id);
+      setPasskeys(await listPasskeys());
+    } catch (e: any) {
+      setError(e?.message || 'Failed to remove passkey');
+    }
+  }
 
   async function saveProfile(values: any) {
     setLoading(true);
@@ -43,6 +183,31 @@ export default function UserSettings() {
     }
   }
 
+  async function uploadAvatar(file: File) {
+    setOk(null); setError(null);
+    try {
+      const body = new FormData();
+      body.append('file', file);
+      const res = await fetch('/api/me/avatar', { method: 'POST', credentials: 'include', body });
+      if (!res.ok) throw new Error(await res.text());
+      setAvatarUrl((await res.json()).avatar_url || '');
+      setOk('Photo updated');
+    } catch (e: any) {
+      setError(e?.message || 'Failed to upload photo');
+    }
+  }
+
+  async function removeAvatar() {
+    setOk(null); setError(null);
+    try {
+      const res = await fetch('/api/me/avatar', { method: 'DELETE', credentials: 'include' });
+      if (!res.ok) throw new Error(await res.text());
+      await load();
+    } catch (e: any) {
+      setError(e?.message || 'Failed to remove photo');
+    }
+  }
+
   async function changePassword(values: { old_password: string; new_password: string }) {
     setLoading(true);
     setOk(null); setError(null);
@@ -73,6 +238,14 @@ export default function UserSettings() {
       {ok && <Alert type="success" message={ok} style={{ marginBottom: 12 }} />}
 
       <Space direction="vertical" style={{ width: 420 }}>
+        <Space>
+          <Avatar size={64} src={avatarUrl || undefined}>{(profile.display_name || profile.email || '?').charAt(0).toUpperCase()}</Avatar>
+          <Upload accept="image/png,image/jpeg,image/gif" showUploadList={false} beforeUpload={(f) => { uploadAvatar(f); return false; }}>
+            <Button>Upload Photo</Button>
+          </Upload>
+          {avatarUrl.startsWith('/api/') && <Button onClick={removeAvatar}>Remove</Button>}
+        </Space>
+
         <Form layout="vertical" form={form} initialValues={profile} onFinish={saveProfile} onValuesChange={(_, all) => setProfile(all)}>
           <Form.Item label="Display Name" name="display_name">
             <Input />
@@ -91,6 +264,150 @@ export default function UserSettings() {
           <Button htmlType="submit" loading={loading}>Change Password</Button>
         </Form>
       </Space>
+
+      {passkeys && (
+        <>
+          <Divider />
+          <Typography.Title level={4}>Passkeys</Typography.Title>
+          <Typography.Paragraph type="secondary">
+            Sign in with your device's fingerprint, face or PIN instead of a password.
+          </Typography.Paragraph>
+          <Space style={{ marginBottom: 12 }}>
+            <Input placeholder="Name, e.g. Work laptop" value={passkeyName} onChange={(e) => setPasskeyName(e.target.value)} maxLength={100} />
+            <Button onClick={addPasskey}>Add Passkey</Button>
+          </Space>
+          <Table
+            size="small"
+            rowKey="id"
+            pagination={false}
+            dataSource={passkeys}
+            columns={[
+              { title: 'Name', dataIndex: 'name' },
+              { title: 'Added', dataIndex: 'created_at', render: when },
+              { title: 'Last used', dataIndex: 'last_used_at', render: (v: string | null) => (v ? when(v) : 'Never') },
+              {
+                title: '',
+                key: 'actions',
+                render: (_: unknown, p: Passkey) => (
+                  <Popconfirm title="Remove this passkey?" onConfirm={() => removePasskey(p.id)}>
+                    <Button size="small" danger>Remove</Button>
+                  </Popconfirm>
+                ),
+              },
+            ]}
+          />
+        </>
+      )}
+
+      {channels && (
+        <>
+          <Divider />
+          <Typography.Title level={4}>Notification Channels</Typography.Title>
+          <Typography.Paragraph type="secondary">
+            Get paged by SMS, push or ntfy when a ticket breaches its SLA, faster than email.
+          </Typography.Paragraph>
+          <Form
+            layout="inline"
+            form={channelForm}
+            initialValues={{ kind: 'sms', events: ['sla_breach'] }}
+            onValuesChange={(changed) => changed.kind && setChannelKind(changed.kind)}
+            onFinish={addChannel}
+            style={{ marginBottom: 12 }}
+          >
+            <Form.Item name="kind"><Select options={channelKinds} style={{ width: 150 }} /></Form.Item>
+            <Form.Item name="address" rules={[{ required: true }]}><Input placeholder={addressHint[channelKind]} style={{ width: 260 }} /></Form.Item>
+            <Form.Item name="events"><Select mode="multiple" options={channelEvents} style={{ width: 260 }} /></Form.Item>
+            <Button htmlType="submit">Add Channel</Button>
+          </Form>
+          <Table
+            size="small"
+            rowKey="id"
+            pagination={false}
+            dataSource={channels}
+            columns={[
+              { title: 'Type', dataIndex: 'kind', render: (k: string) => channelKinds.find((o) => o.value === k)?.label || k },
+              { title: 'Address', dataIndex: 'address' },
+              {
+                title: 'Events',
+                key: 'events',
+                render: (_: unknown, ch: Channel) => (
+                  <Select mode="multiple" size="small" options={channelEvents} value={ch.events} style={{ minWidth: 220 }}
+                    onChange={(events) => updateChannel(ch.id, { events })} />
+                ),
+              },
+              {
+                title: 'Enabled',
+                key: 'enabled',
+                render: (_: unknown, ch: Channel) => <Switch size="small" checked={ch.enabled} onChange={(enabled) => updateChannel(ch.id, { enabled })} />,
+              },
+              {
+                title: 'Last delivery',
+                key: 'last',
+                render: (_: unknown, ch: Channel) =>
+                  ch.last_error ? <Typography.Text type="danger">{ch.last_error}</Typography.Text> : ch.last_sent_at ? when(ch.last_sent_at) : 'Never',
+              },
+              {
+                title: '',
+                key: 'actions',
+                render: (_: unknown, ch: Channel) => (
+                  <Space>
+                    <Button size="small" disabled={!ch.enabled} onClick={() => testChannel(ch.id)}>Test</Button>
+                    <Popconfirm title="Remove this channel?" onConfirm={() => removeChannel(ch.id)}>
+                      <Button size="small" danger>Remove</Button>
+                    </Popconfirm>
+                  </Space>
+                ),
+              },
+            ]}
+          />
+        </>
+      )}
+
+      {security && (
+        <>
+          <Divider />
+          <Typography.Title level={4}>Security</Typography.Title>
+          <Typography.Title level={5}>Active Sessions</Typography.Title>
+          <Table
+            size="small"
+            rowKey="id"
+            pagination={false}
+            dataSource={security.sessions}
+            columns={[
+              { title: 'Signed in', dataIndex: 'created_at', render: when },
+              { title: 'Method', dataIndex: 'method' },
+              { title: 'IP', dataIndex: 'ip' },
+              { title: 'Device', dataIndex: 'user_agent' },
+              { title: 'Expires', dataIndex: 'expires_at', render: when },
+            ]}
+          />
+          <Typography.Title level={5} style={{ marginTop: 16 }}>Recent Logins</Typography.Title>
+          <Table
+            size="small"
+            rowKey="id"
+            pagination={false}
+            dataSource={security.logins}
+            columns={[
+              { title: 'Time', dataIndex: 'created_at', render: when },
+              { title: 'Method', dataIndex: 'method' },
+              { title: 'IP', dataIndex: 'ip' },
+              { title: 'Device', dataIndex: 'user_agent' },
+            ]}
+          />
+          <Typography.Title level={5} style={{ marginTop: 16 }}>Password Changes</Typography.Title>
+          <Table
+            size="small"
+            rowKey="changed_at"
+            pagination={false}
+            dataSource={security.password_changes}
+            columns={[
+              { title: 'Time', dataIndex: 'changed_at', render: when },
+              { title: 'IP', dataIndex: 'ip' },
+              { title: 'Device', dataIndex: 'user_agent' },
+            ]}
+          />
+        </>
+      )}
     </div>
   );
 }
@@ -0,0 +1,81 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Form, Input, Button, Typography, message } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type Branding = {
+  logo_url?: string;
+  primary_color?: string;
+  background_color?: string;
+  thank_you?: Record<string, string>;
+};
+
+const locales = [
+  { key: '*', label: 'Default (all languages)' },
+  { key: 'en', label: 'English' },
+  { key: 'es', label: 'Spanish' },
+  { key: 'fr', label: 'French' },
+  { key: 'de', label: 'German' },
+  { key: 'pt', label: 'Portuguese' },
+];
+
+export default function AdminCSAT() {
+  const [form] = Form.useForm();
+  const [loading, setLoading] = useState(false);
+  const [saving, setSaving] = useState(false);
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      form.setFieldsValue(await apiFetch<Branding>('/settings/csat'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load CSAT branding');
+    } finally {
+      setLoading(false);
+    }
+  }, [form]);
+
+  useEffect(() => { load(); }, [load]);
+
+  async function save(values: Branding) {
+    setSaving(true);
+    try {
+      await apiFetch('/settings/csat', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(values),
+      });
+      message.success('CSAT branding saved');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save CSAT branding');
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  return (
+    <div>
+      <Typography.Title level={3}>CSAT survey</Typography.Title>
+      <Typography.Paragraph type="secondary">
+        Branding for the satisfaction survey linked from resolution emails. Pages are shown in the requester's language when it is known.
+      </Typography.Paragraph>
+      <Form form={form} layout="vertical" disabled={loading} onFinish={save} style={{ maxWidth: 560 }}>
+        <Form.Item name="logo_url" label="Logo URL" extra="An https URL or a path on this host">
+          <Input placeholder="https://cdn.example.com/logo.png" />
+        </Form.Item>
+        <Form.Item name="primary_color" label="Primary color">
+          <Input placeholder="#1e40af" />
+        </Form.Item>
+        <Form.Item name="background_color" label="Background color">
+          <Input placeholder="#f3f4f6" />
+        </Form.Item>
+        <Typography.Title level={5}>Thank-you text</Typography.Title>
+        {locales.map((l) => (
+          <Form.Item key={l.key} name={['thank_you', l.key]} label={l.label}>
+            <Input.TextArea rows={2} maxLength={500} placeholder="Built-in text" />
+          </Form.Item>
+        ))}
+        <Button type="primary" htmlType="submit" loading={saving}>Save</Button>
+      </Form>
+    </div>
+  );
+}
@@ -0,0 +1,201 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Table, Button, Space, Tag, Typography, message, Modal, Form, Input, InputNumber, Select, Switch, Card, Descriptions, Popconfirm } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type Rule = {
+  id: string;
+  name: string;
+  position: number;
+  sender?: string;
+  subject?: string;
+  category: string;
+  subcategory?: string;
+  queue_id?: string;
+  active: boolean;
+};
+
+type Queue = { id: string; name: string };
+
+type TestResult = {
+  category: string;
+  subcategory?: string;
+  queue_id?: string;
+  confidence: number;
+  method?: string;
+  rule_id?: string;
+  categorized: boolean;
+  min_confidence: number;
+};
+
+export default function AdminCategoryRules() {
+  const [rules, setRules] = useState<Rule[]>([]);
+  const [queues, setQueues] = useState<Queue[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [editing, setEditing] = useState<Rule | null>(null);
+  const [open, setOpen] = useState(false);
+  const [saving, setSaving] = useState(false);
+  const [result, setResult] = useState<TestResult | null>(null);
+  const [form] = Form.useForm();
+  const [testForm] = Form.useForm();
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      setRules(await apiFetch<Rule[]>('/admin/category-rules'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load rules');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => { load(); }, [load]);
+  useEffect(() => {
+    apiFetch<Queue[]>('/queues').then(setQueues).catch(() => setQueues([]));
+  }, []);
+
+  const queueName = (id?: string) => queues.find((q) => q.id === id)?.name || id;
+
+  function edit(r: Rule | null) {
+    setEditing(r);
+    form.resetFields();
+    form.setFieldsValue(r || { active: true, position: (rules[rules.length - 1]?.position ?? 0) + 10 });
+    setOpen(true);
+  }
+
+  async function save() {
+    const values = await form.validateFields();
+    setSaving(true);
+    try {
+      await apiFetch(editing ? `/admin/category-rules/${editing.id}` : '/admin/category-rules', {
+        method: editing ? 'PUT' : 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(values),
+      });
+      setOpen(false);
+      await load();
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save rule');
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  async function remove(r: Rule) {
+    try {
+      await apiFetch(`/admin/category-rules/${r.id}`, { method: 'DELETE' });
+      await load();
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to delete rule');
+    }
+  }
+
+  async function test(values: { from?: string; subject?: string }) {
+    try {
+      setResult(await apiFetch<TestResult>('/admin/category-rules/test', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(values),
+      }));
+    } catch (e: any) {
+      message.error(e?.message || 'Test failed');
+    }
+  }
+
+  const columns = [
+    { title: '#', dataIndex: 'position', key: 'position', width: 60 },
+    { title: 'Name', dataIndex: 'name', key: 'name' },
+    {
+      title: 'Matches', key: 'matches',
+      render: (_: unknown, r: Rule) => (
+        <Space direction="vertical" size={0}>
+          {r.sender && <span>From <code>{r.sender}</code></span>}
+          {r.subject && <span>Subject <code>/{r.subject}/i</code></span>}
+        </Space>
+      ),
+    },
+    {
+      title: 'Sets', key: 'sets',
+      render: (_: unknown, r: Rule) => (
+        <Space wrap>
+          <Tag color="blue">{r.subcategory ? `${r.category} / ${r.subcategory}` : r.category}</Tag>
+          {r.queue_id && <Tag>{queueName(r.queue_id)}</Tag>}
+        </Space>
+      ),
+    },
+    { title: 'Active', dataIndex: 'active', key: 'active', render: (v: boolean) => (v ? <Tag color="green">active</Tag> : <Tag>off</Tag>) },
+    {
+      title: '', key: 'actions',
+      render: (_: unknown, r: Rule) => (
+        <Space>
+          <Button size="small" onClick={() => edit(r)}>Edit</Button>
+          <Popconfirm title="Delete this rule?" onConfirm={() => remove(r)}>
+            <Button size="small" danger>Delete</Button>
+          </Popconfirm>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <Space direction="vertical" size="large" style={{ width: '100%' }}>
+      <Space style={{ justifyContent: 'space-between', width: '100%' }}>
+        <Typography.Title level={2} style={{ margin: 0 }}>Auto-categorization</Typography.Title>
+        <Button type="primary" onClick={() => edit(null)}>Add rule</Button>
+      </Space>
+      <Typography.Text type="secondary">
+        Inbound email tickets are checked against these rules in order; the first match wins. Tickets no rule
+        matches are compared with similar past tickets and left uncategorized when the result is not confident enough.
+      </Typography.Text>
+      <Table rowKey="id" columns={columns as any} dataSource={rules} loading={loading} pagination={false} />
+
+      <Card size="small" title="Try it">
+        <Form form={testForm} layout="inline" onFinish={test}>
+          <Form.Item name="from"><Input placeholder="sender@example.com" style={{ width: 240 }} /></Form.Item>
+          <Form.Item name="subject"><Input placeholder="Subject" style={{ width: 320 }} /></Form.Item>
+          <Button htmlType="submit">Test</Button>
+        </Form>
+        {result && (
+          <Descriptions size="small" column={1} bordered style={{ marginTop: 16 }}>
+            <Descriptions.Item label="Outcome">
+              {result.categorized ? <Tag color="green">categorized</Tag> : <Tag>uncategorized</Tag>}
+            </Descriptions.Item>
+            {result.category && (
+              <Descriptions.Item label="Category">
+                {result.subcategory ? `${result.category} / ${result.subcategory}` : result.category}
+                {result.queue_id && <Tag style={{ marginLeft: 8 }}>{queueName(result.queue_id)}</Tag>}
+              </Descriptions.Item>
+            )}
+            <Descriptions.Item label="Confidence">
+              {Math.round(result.confidence * 100)}% (threshold {Math.round(result.min_confidence * 100)}%)
+            </Descriptions.Item>
+            {result.method && (
+              <Descriptions.Item label="Method">
+                {result.method === 'rule' ? `Rule: ${rules.find((r) => r.id === result.rule_id)?.name || result.rule_id}` : 'Similar tickets'}
+              </Descriptions.Item>
+            )}
+          </Descriptions>
+        )}
+      </Card>
+
+      <Modal title={editing ? 'Edit rule' : 'Add rule'} open={open} onOk={save} confirmLoading={saving} onCancel={() => setOpen(false)}>
+        <Form form={form} layout="vertical">
+          <Form.Item name="name" label="Name" rules={[{ required: true }]}><Input /></Form.Item>
+          <Form.Item name="position" label="Order"><InputNumber /></Form.Item>
+          <Form.Item name="sender" label="Sender" extra="Address, domain (matches subdomains) or glob such as *@billing.*">
+            <Input placeholder="vendor.com" />
+          </Form.Item>
+          <Form.Item name="subject" label="Subject pattern" extra="Case-insensitive regular expression">
+            <Input placeholder="invoice|receipt" />
+          </Form.Item>
+          <Form.Item name="category" label="Category" rules={[{ required: true }]}><Input /></Form.Item>
+          <Form.Item name="subcategory" label="Subcategory"><Input /></Form.Item>
+          <Form.Item name="queue_id" label="Queue">
+            <Select allowClear options={queues.map((q) => ({ value: q.id, label: q.name }))} />
+          </Form.Item>
+          <Form.Item name="active" label="Active" valuePropName="checked"><Switch /></Form.Item>
+        </Form>
+      </Modal>
+    </Space>
+  );
+}
@@ -0,0 +1,104 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Table, Button, Select, Switch, Typography, message } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type ListScope = { scope: string; open_only?: boolean; enforced?: boolean };
+
+const scopeOptions = [
+  { value: 'all', label: 'All tickets' },
+  { value: 'team', label: 'Their teams' },
+  { value: 'assigned', label: 'Assigned to them' },
+  { value: 'own', label: 'Requested by them' },
+];
+
+export default function AdminListScopes() {
+  const [roles, setRoles] = useState<string[]>([]);
+  const [scopes, setScopes] = useState<Record<string, ListScope>>({});
+  const [loading, setLoading] = useState(false);
+  const [saving, setSaving] = useState(false);
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      const [r, s] = await Promise.all([
+        apiFetch<string[]>('/roles'),
+        apiFetch<Record<string, ListScope>>('/settings/list-scopes'),
+      ]);
+      setRoles(Array.from(new Set([...r, 'requester', ...Object.keys(s)])));
+      setScopes(s);
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load list scopes');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  useEffect(() => { load(); }, [load]);
+
+  const scopeOf = (role: string): ListScope => scopes[role] || { scope: role === 'requester' ? 'own' : 'all' };
+  const update = (role: string, patch: Partial<ListScope>) =>
+    setScopes((s) => ({ ...s, [role]: { ...scopeOf(role), ...patch } }));
+
+  async function save() {
+    setSaving(true);
+    try {
+      await apiFetch('/settings/list-scopes', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(scopes),
+      });
+      message.success('List scopes saved');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save list scopes');
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  return (
+    <div>
+      <Typography.Title level={3}>Ticket list scopes</Typography.Title>
+      <Typography.Paragraph type="secondary">
+        The tickets each role sees by default. Enforced scopes can only be narrowed; requesters always see only their own tickets.
+      </Typography.Paragraph>
+      <Table
+        rowKey={(r) => r}
+        loading={loading}
+        dataSource={roles}
+        pagination={false}
+        columns={[
+          { title: 'Role', render: (_: unknown, role: string) => role },
+          {
+            title: 'Scope',
+            render: (_: unknown, role: string) => (
+              <Select
+                style={{ width: 200 }}
+                value={scopeOf(role).scope}
+                disabled={role === 'requester'}
+                options={scopeOptions}
+                onChange={(scope) => update(role, { scope })}
+              />
+            ),
+          },
+          {
+            title: 'Open only',
+            render: (_: unknown, role: string) => (
+              <Switch checked={!!scopeOf(role).open_only} onChange={(open_only) => update(role, { open_only })} />
+            ),
+          },
+          {
+            title: 'Enforced',
+            render: (_: unknown, role: string) => (
+              <Switch
+                checked={role === 'requester' || !!scopeOf(role).enforced}
+                disabled={role === 'requester'}
+                onChange={(enforced) => update(role, { enforced })}
+              />
+            ),
+          },
+        ]}
+      />
+      <Button type="primary" style={{ marginTop: 16 }} loading={saving} onClick={save}>Save</Button>
+    </div>
+  );
+}
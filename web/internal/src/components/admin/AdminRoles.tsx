@@ -0,0 +1,199 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Table, Input, Button, Space, Tag, Typography, message, Select, Form, Card, Popconfirm } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type Role = {
+  id?: string;
+  name: string;
+  description?: string;
+  permissions: string[];
+  builtin: boolean;
+};
+
+type Permission = { name: string; description: string };
+
+type GroupMapping = { group: string; roles: string[] };
+
+export default function AdminRoles() {
+  const [loading, setLoading] = useState(false);
+  const [roles, setRoles] = useState<Role[]>([]);
+  const [catalog, setCatalog] = useState<Permission[]>([]);
+  const [editing, setEditing] = useState<Role | null>(null);
+  const [saving, setSaving] = useState(false);
+  const [form] = Form.useForm();
+  const [mappings, setMappings] = useState<GroupMapping[]>([]);
+  const [mapForm] = Form.useForm();
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      setRoles(await apiFetch<Role[]>('/roles?detail=true'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load roles');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  const loadMappings = useCallback(async () => {
+    try {
+      setMappings(await apiFetch<GroupMapping[]>('/oidc/group-roles'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load group mappings');
+    }
+  }, []);
+
+  useEffect(() => { load(); }, [load]);
+  useEffect(() => { loadMappings(); }, [loadMappings]);
+  useEffect(() => { (async () => { try { setCatalog(await apiFetch<Permission[]>('/permissions')); } catch { /* Error loading permissions */ } })(); }, []);
+
+  function edit(r: Role | null) {
+    setEditing(r);
+    form.setFieldsValue({ name: r?.name || '', description: r?.description || '', permissions: r?.permissions || [] });
+  }
+
+  async function save(vals: any) {
+    setSaving(true);
+    try {
+      if (editing) {
+        await apiFetch(`/roles/${encodeURIComponent(editing.name)}`, {
+          method: 'PATCH',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ description: vals.description || '', permissions: vals.permissions || [] }),
+        });
+        message.success('Role updated');
+      } else {
+        await apiFetch('/roles', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ name: vals.name, description: vals.description || '', permissions: vals.permissions || [] }),
+        });
+        message.success('Role created');
+      }
+      edit(null);
+      await load();
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save role');
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  async function remove(r: Role) {
+    try {
+      await apiFetch(`/roles/${encodeURIComponent(r.name)}`, { method: 'DELETE' });
+      if (editing?.name === r.name) edit(null);
+      await load();
+      message.success('Role deleted');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to delete role');
+    }
+  }
+
+  async function saveMapping(m: GroupMapping) {
+    try {
+      await apiFetch('/oidc/group-roles', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ group: m.group, roles: m.roles || [] }),
+      });
+      mapForm.resetFields();
+      await loadMappings();
+      message.success('Group mapping saved');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save group mapping');
+    }
+  }
+
+  const columns = [
+    {
+      title: 'Role', dataIndex: 'name', key: 'name',
+      render: (name: string, r: Role) => <Space>{name}{r.builtin && <Tag>built-in</Tag>}</Space>,
+    },
+    { title: 'Description', dataIndex: 'description', key: 'description' },
+    {
+      title: 'Permissions', dataIndex: 'permissions', key: 'permissions',
+      render: (perms: string[], r: Role) => r.name === 'admin'
+        ? <Tag color="purple">all</Tag>
+        : <Space wrap>{perms.map((p) => <Tag key={p}>{p}</Tag>)}</Space>,
+    },
+    {
+      title: '', key: 'actions',
+      render: (_: unknown, r: Role) => r.builtin ? null : (
+        <Space>
+          <Button size="small" onClick={() => edit(r)}>Edit</Button>
+          <Popconfirm title={`Delete role ${r.name}? It is removed from every user.`} onConfirm={() => remove(r)}>
+            <Button size="small" danger>Delete</Button>
+          </Popconfirm>
+        </Space>
+      ),
+    },
+  ];
+
+  return (
+    <Space align="start" style={{ width: '100%' }}>
+      <div style={{ flex: 1 }}>
+        <Table
+          rowKey={(r: Role) => r.name}
+          columns={columns as any}
+          dataSource={roles}
+          loading={loading}
+          pagination={false}
+        />
+      </div>
+      <div style={{ width: 420 }}>
+        <Card size="small">
+          <Typography.Title level={5} style={{ margin: 0 }}>{editing ? `Edit ${editing.name}` : 'Create Role'}</Typography.Title>
+          <Form form={form} layout="vertical" onFinish={save}>
+            <Form.Item label="Name" name="name" rules={[{ required: true, pattern: /^[a-z][a-z0-9_-]{1,31}$/, message: 'Lowercase letters, digits, - or _' }]}>
+              <Input disabled={!!editing} />
+            </Form.Item>
+            <Form.Item label="Description" name="description"><Input /></Form.Item>
+            <Form.Item label="Permissions" name="permissions">
+              <Select
+                mode="multiple"
+                options={catalog.map((p) => ({ value: p.name, label: `${p.name} — ${p.description}` }))}
+              />
+            </Form.Item>
+            <Space>
+              <Button type="primary" htmlType="submit" loading={saving}>{editing ? 'Save' : 'Create'}</Button>
+              {editing && <Button onClick={() => edit(null)}>Cancel</Button>}
+            </Space>
+          </Form>
+        </Card>
+        <Card size="small" style={{ marginTop: 16 }}>
+          <Typography.Title level={5} style={{ margin: 0 }}>OIDC Group Mappings</Typography.Title>
+          <Typography.Paragraph type="secondary">IdP groups without a mapping grant no roles.</Typography.Paragraph>
+          <Table
+            size="small"
+            rowKey={(m: GroupMapping) => m.group}
+            dataSource={mappings}
+            pagination={false}
+            columns={[
+              { title: 'Group', dataIndex: 'group', key: 'group' },
+              { title: 'Roles', dataIndex: 'roles', key: 'roles', render: (rs: string[]) => <Space wrap>{rs.map((r) => <Tag key={r}>{r}</Tag>)}</Space> },
+              {
+                title: '', key: 'actions',
+                render: (_: unknown, m: GroupMapping) => (
+                  <Space>
+                    <Button size="small" onClick={() => mapForm.setFieldsValue(m)}>Edit</Button>
+                    <Popconfirm title={`Remove mapping for ${m.group}?`} onConfirm={() => saveMapping({ group: m.group, roles: [] })}>
+                      <Button size="small" danger>Remove</Button>
+                    </Popconfirm>
+                  </Space>
+                ),
+              },
+            ]}
+          />
+          <Form form={mapForm} layout="vertical" onFinish={saveMapping} style={{ marginTop: 12 }}>
+            <Form.Item label="Group" name="group" rules={[{ required: true }]}><Input /></Form.Item>
+            <Form.Item label="Roles" name="roles">
+              <Select mode="multiple" options={roles.map((r) => ({ value: r.name, label: r.name }))} />
+            </Form.Item>
+            <Button type="primary" htmlType="submit">Save Mapping</Button>
+          </Form>
+        </Card>
+      </div>
+    </Space>
+  );
+}
@@ -0,0 +1,64 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Form, Select, Button, Typography, message } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type Rules = { raise: string; lower: string };
+
+const options = [
+  { value: 'keep', label: 'Keep the original targets' },
+  { value: 'restart', label: 'Restart the clock on the new targets' },
+  { value: 'prorate', label: 'Prorate elapsed time onto the new targets' },
+];
+
+export default function AdminSLAPriority() {
+  const [form] = Form.useForm();
+  const [loading, setLoading] = useState(false);
+  const [saving, setSaving] = useState(false);
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      form.setFieldsValue(await apiFetch<Rules>('/settings/sla-priority'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load SLA priority rules');
+    } finally {
+      setLoading(false);
+    }
+  }, [form]);
+
+  useEffect(() => { load(); }, [load]);
+
+  async function save(values: Rules) {
+    setSaving(true);
+    try {
+      await apiFetch('/settings/sla-priority', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(values),
+      });
+      message.success('SLA priority rules saved');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to save SLA priority rules');
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  return (
+    <div>
+      <Typography.Title level={3}>SLA on priority change</Typography.Title>
+      <Typography.Paragraph type="secondary">
+        What happens to a ticket's SLA clock when its priority changes. Each decision is recorded on the ticket's audit timeline.
+      </Typography.Paragraph>
+      <Form form={form} layout="vertical" disabled={loading} onFinish={save} style={{ maxWidth: 560 }}>
+        <Form.Item name="raise" label="Priority raised">
+          <Select options={options} />
+        </Form.Item>
+        <Form.Item name="lower" label="Priority lowered">
+          <Select options={options} />
+        </Form.Item>
+        <Button type="primary" htmlType="submit" loading={saving}>Save</Button>
+      </Form>
+    </div>
+  );
+}
@@ -1,5 +1,5 @@
 import { Card, Row, Col, Statistic, List, Button, Space, Typography, Tag, Alert } from 'antd';
-import { SettingOutlined, DatabaseOutlined, MailOutlined, LockOutlined, CloudOutlined, UserOutlined, DiscordOutlined } from '@ant-design/icons';
+import { SettingOutlined, DatabaseOutlined, MailOutlined, LockOutlined, CloudOutlined, UserOutlined, DiscordOutlined, ApiOutlined } from '@ant-design/icons';
 import { Link } from 'react-router-dom';
 import { useSystemInfo } from '../../api';
 
@@ -72,6 +72,48 @@ export default function AdminSettings() {
       path: '/settings/users',
       status: 'configured',
     },
+    {
+      title: 'Roles',
+      description: 'Define custom roles and their permissions',
+      icon: <LockOutlined />,
+      path: '/settings/roles',
+      status: 'configured',
+    },
+    {
+      title: 'Webhooks',
+      description: 'Send test events and review or retry deliveries',
+      icon: <ApiOutlined />,
+      path: '/settings/webhooks',
+      status: 'configured',
+    },
+    {
+      title: 'Auto-categorization',
+      description: 'Rules that categorize and route inbound email',
+      icon: <MailOutlined />,
+      path: '/settings/categorization',
+      status: 'configured',
+    },
+    {
+      title: 'Ticket List Scopes',
+      description: 'Which tickets each role sees by default',
+      icon: <LockOutlined />,
+      path: '/settings/list-scopes',
+      status: 'configured',
+    },
+    {
+      title: 'CSAT Survey',
+      description: 'Logo, colors and thank-you text of the satisfaction survey',
+      icon: <MailOutlined />,
+      path: '/settings/csat',
+      status: 'configured',
+    },
+    {
+      title: 'SLA Priority Changes',
+      description: 'Keep, restart or prorate SLA clocks when priority changes',
+      icon: <SettingOutlined />,
+      path: '/settings/sla-priority',
+      status: 'configured',
+    },
   ];
 
   return (
@@ -9,6 +9,7 @@ type User = {
   email?: string;
   display_name?: string;
   roles: string[];
+  pending_approval?: boolean;
 };
 
 export default function AdminUsers() {
@@ -45,7 +46,10 @@ export default function AdminUsers() {
   useEffect(() => { (async () => { try { setRoles(await apiFetch<string[]>('/roles')); } catch { /* Error loading roles */ } })(); }, []);
 
   const columns = useMemo(() => ([
-    { title: 'Name', dataIndex: 'display_name', key: 'display_name' },
+    {
+      title: 'Name', dataIndex: 'display_name', key: 'display_name',
+      render: (name: string, u: User) => <Space>{name}{u.pending_approval && <Tag color="orange">pending</Tag>}</Space>,
+    },
     { title: 'Email', dataIndex: 'email', key: 'email' },
     { title: 'Username', dataIndex: 'username', key: 'username' },
     { title: 'External ID', dataIndex: 'external_id', key: 'external_id' },
@@ -67,6 +71,17 @@ export default function AdminUsers() {
     }
   }
 
+  async function approve() {
+    if (!selected) return;
+    try {
+      await apiFetch(`/users/${selected.id}/approve`, { method: 'POST' });
+      await load();
+      message.success('User approved');
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to approve user');
+    }
+  }
+
   async function removeRole(role: string) {
     if (!selected) return;
     try {
@@ -134,6 +149,12 @@ export default function AdminUsers() {
             <div style={{ marginBottom: 8 }}>
               <div style={{ fontWeight: 600 }}>{selected.display_name || selected.email || selected.username || selected.id}</div>
               <div style={{ color: '#888' }}>{selected.email || selected.username || selected.external_id}</div>
+              {selected.pending_approval && (
+                <Space style={{ marginTop: 8 }}>
+                  <Tag color="orange">Awaiting approval</Tag>
+                  <Button size="small" type="primary" onClick={approve}>Approve</Button>
+                </Space>
+              )}
             </div>
             <Space wrap>
               {(selected.roles || []).map((r) => (
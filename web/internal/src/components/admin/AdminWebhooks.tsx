@@ -0,0 +1,170 @@
+import { useCallback, useEffect, useState } from 'react';
+import { Table, Button, Space, Tag, Typography, message, Select, Card, Descriptions, Empty } from 'antd';
+import { apiFetch } from '../../shared/api';
+
+type Webhook = {
+  id: string;
+  target_url: string;
+  event_mask: number;
+  active: boolean;
+};
+
+type Delivery = {
+  id: string;
+  event: string;
+  is_test: boolean;
+  attempt: number;
+  retry_of: string | null;
+  status_code: number | null;
+  latency_ms: number;
+  response_excerpt: string;
+  error: string | null;
+  ok: boolean;
+  created_at: string;
+};
+
+type EventOption = { value: number; label: string };
+
+function Outcome({ d }: { d: Delivery }) {
+  if (d.error) return <Tag color="red">error</Tag>;
+  return <Tag color={d.ok ? 'green' : 'orange'}>{d.status_code}</Tag>;
+}
+
+export default function AdminWebhooks() {
+  const [hooks, setHooks] = useState<Webhook[]>([]);
+  const [loading, setLoading] = useState(false);
+  const [events, setEvents] = useState<EventOption[]>([]);
+  const [selected, setSelected] = useState<Webhook | null>(null);
+  const [event, setEvent] = useState('ticket.created');
+  const [sending, setSending] = useState(false);
+  const [last, setLast] = useState<Delivery | null>(null);
+  const [deliveries, setDeliveries] = useState<Delivery[]>([]);
+
+  const load = useCallback(async () => {
+    setLoading(true);
+    try {
+      setHooks(await apiFetch<Webhook[]>('/webhooks'));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load webhooks');
+    } finally {
+      setLoading(false);
+    }
+  }, []);
+
+  const loadDeliveries = useCallback(async (h: Webhook) => {
+    try {
+      setDeliveries(await apiFetch<Delivery[]>(`/admin/webhooks/${h.id}/deliveries`));
+    } catch (e: any) {
+      message.error(e?.message || 'Failed to load deliveries');
+    }
+  }, []);
+
+  useEffect(() => { load(); }, [load]);
+  useEffect(() => {
+    (async () => {
+      try {
+        const caps = await apiFetch<{ enums: { webhook_events: EventOption[] } }>('/meta/capabilities');
+        setEvents(caps.enums.webhook_events || []);
+      } catch { /* Fall back to the default event */ }
+    })();
+  }, []);
+  useEffect(() => {
+    setLast(null);
+    if (selected) loadDeliveries(selected);
+  }, [selected, loadDeliveries]);
+
+  async function send(path: string, body?: unknown) {
+    if (!selected) return;
+    setSending(true);
+    try {
+      const d = await apiFetch<Delivery>(path, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: body ? JSON.stringify(body) : undefined,
+      });
+      setLast(d);
+      await loadDeliveries(selected);
+    } catch (e: any) {
+      message.error(e?.message || 'Delivery failed');
+    } finally {
+      setSending(false);
+    }
+  }
+
+  const columns = [
+    { title: 'Target URL', dataIndex: 'target_url', key: 'target_url' },
+    {
+      title: 'Events', dataIndex: 'event_mask', key: 'event_mask',
+      render: (mask: number) => (
+        <Space wrap>{events.filter((e) => mask & e.value).map((e) => <Tag key={e.value}>{e.label}</Tag>)}</Space>
+      ),
+    },
+    { title: 'Active', dataIndex: 'active', key: 'active', render: (v: boolean) => (v ? <Tag color="green">active</Tag> : <Tag>paused</Tag>) },
+    {
+      title: '', key: 'actions',
+      render: (_: unknown, h: Webhook) => <Button size="small" onClick={() => setSelected(h)}>Test console</Button>,
+    },
+  ];
+
+  const deliveryColumns = [
+    { title: 'When', dataIndex: 'created_at', key: 'created_at', render: (v: string) => new Date(v).toLocaleString() },
+    { title: 'Event', dataIndex: 'event', key: 'event', render: (v: string, d: Delivery) => <Space>{v}{d.is_test && <Tag>test</Tag>}</Space> },
+    { title: 'Attempt', dataIndex: 'attempt', key: 'attempt' },
+    { title: 'Result', key: 'result', render: (_: unknown, d: Delivery) => <Outcome d={d} /> },
+    { title: 'Latency', dataIndex: 'latency_ms', key: 'latency_ms', render: (v: number) => `${v} ms` },
+    {
+      title: '', key: 'actions',
+      render: (_: unknown, d: Delivery) => (
+        <Button size="small" loading={sending} onClick={() => send(`/admin/webhooks/${selected?.id}/deliveries/${d.id}/retry`)}>
+          Retry
+        </Button>
+      ),
+    },
+  ];
+
+  return (
+    <Space direction="vertical" size="large" style={{ width: '100%' }}>
+      <Typography.Title level={2} style={{ margin: 0 }}>Webhooks</Typography.Title>
+      <Table rowKey="id" columns={columns as any} dataSource={hooks} loading={loading} pagination={false} />
+      {selected && (
+        <Card size="small" title={`Test console — ${selected.target_url}`}>
+          <Space>
+            <Select
+              style={{ width: 220 }}
+              value={event}
+              onChange={setEvent}
+              options={(events.length ? events : [{ value: 1, label: 'ticket.created' }]).map((e) => ({ value: e.label, label: e.label }))}
+            />
+            <Button type="primary" loading={sending} onClick={() => send(`/admin/webhooks/${selected.id}/test`, { event })}>
+              Send test
+            </Button>
+          </Space>
+          {last && (
+            <Descriptions size="small" column={1} bordered style={{ marginTop: 16 }}>
+              <Descriptions.Item label="Result"><Outcome d={last} /></Descriptions.Item>
+              <Descriptions.Item label="Latency">{last.latency_ms} ms</Descriptions.Item>
+              {last.error && <Descriptions.Item label="Error">{last.error}</Descriptions.Item>}
+              <Descriptions.Item label="Response">
+                <pre style={{ margin: 0, whiteSpace: 'pre-wrap', maxHeight: 240, overflow: 'auto' }}>{last.response_excerpt}</pre>
+              </Descriptions.Item>
+            </Descriptions>
+          )}
+          <Typography.Title level={5} style={{ marginTop: 16 }}>Delivery history</Typography.Title>
+          <Table
+            size="small"
+            rowKey="id"
+            columns={deliveryColumns as any}
+            dataSource={deliveries}
+            pagination={false}
+            locale={{ emptyText: <Empty description="No deliveries yet" /> }}
+            expandable={{
+              expandedRowRender: (d: Delivery) => (
+                <pre style={{ margin: 0, whiteSpace: 'pre-wrap' }}>{d.error || d.response_excerpt}</pre>
+              ),
+            }}
+          />
+        </Card>
+      )}
+    </Space>
+  );
+}
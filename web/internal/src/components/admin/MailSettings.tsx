@@ -1,6 +1,12 @@
 import { useEffect, useMemo, useState } from 'react';
-import { Alert, Button, Card, Col, Form, Input, Row, Space, message } from 'antd';
-import { useSettings, useSaveMailSettings, useSendTestEmail } from '../../api';
+import { Alert, Button, Card, Col, Form, Input, Row, Select, Space, Typography, message } from 'antd';
+import {
+  useMailTemplates,
+  usePreviewMail,
+  useSettings,
+  useSaveMailSettings,
+  useSendTestEmail,
+} from '../../api';
 
 export default function MailSettings() {
   const [form] = Form.useForm();
@@ -8,6 +14,10 @@ export default function MailSettings() {
   const { data } = useSettings();
   const save = useSaveMailSettings();
   const sendTest = useSendTestEmail();
+  const templates = useMailTemplates();
+  const preview = usePreviewMail();
+  const [template, setTemplate] = useState<string>();
+  const [previewData, setPreviewData] = useState('');
   const mail = useMemo(
     () => (((data as any)?.mail ?? {}) as Record<string, string>),
     [data],
@@ -27,8 +37,39 @@ export default function MailSettings() {
     });
   };
 
+  const pickTemplate = (name: string) => {
+    setTemplate(name);
+    const sample = templates.data?.find((t) => t.name === name)?.sample ?? {};
+    setPreviewData(JSON.stringify(sample, null, 2));
+    preview.reset();
+  };
+
+  const runPreview = () => {
+    if (!template) return;
+    let data: Record<string, unknown> | undefined;
+    if (previewData.trim()) {
+      try {
+        data = JSON.parse(previewData);
+      } catch {
+        message.error('Sample data is not valid JSON');
+        return;
+      }
+    }
+    preview.mutate(
+      { template, data, to: testRecipient || undefined },
+      { onError: (err: any) => message.error(err?.message || 'Preview failed') },
+    );
+  };
+
   return (
     <Space direction="vertical" size="large" style={{ width: '100%' }}>
+      {mail.sandbox_to && (
+        <Alert
+          type="warning"
+          showIcon
+          message={`Sandbox mode: all outbound mail is delivered to ${mail.sandbox_to}.`}
+        />
+      )}
       <Alert
         type="info"
         showIcon
@@ -67,6 +108,15 @@ export default function MailSettings() {
                 <Input placeholder="helpdesk@example.com" />
               </Form.Item>
             </Col>
+            <Col xs={24}>
+              <Form.Item
+                label="Sandbox Address"
+                name="sandbox_to"
+                extra="For staging: when set, every email is delivered here instead of to its recipients."
+              >
+                <Input placeholder="staging-inbox@example.com" />
+              </Form.Item>
+            </Col>
           </Row>
         </Card>
 
@@ -133,6 +183,38 @@ export default function MailSettings() {
           Last test queued: {(data as any)?.last_test || 'never'}
         </p>
       </Card>
+
+      <Card title="Preview Template">
+        <Space direction="vertical" style={{ width: '100%' }}>
+          <Select
+            placeholder="Choose a template"
+            value={template}
+            onChange={pickTemplate}
+            loading={templates.isLoading}
+            options={(templates.data ?? []).map((t) => ({ value: t.name, label: t.name }))}
+            style={{ minWidth: 280 }}
+          />
+          <Input.TextArea
+            rows={6}
+            value={previewData}
+            onChange={(event) => setPreviewData(event.target.value)}
+            placeholder="Template data as JSON; leave empty for the sample"
+            style={{ fontFamily: 'monospace' }}
+          />
+          <Button onClick={runPreview} loading={preview.isPending} disabled={!template}>
+            Render Preview
+          </Button>
+          {preview.data && (
+            <>
+              {preview.data.deliver_to && (
+                <Typography.Text type="secondary">Would be delivered to {preview.data.deliver_to}</Typography.Text>
+              )}
+              <Typography.Text strong>{preview.data.subject}</Typography.Text>
+              <pre style={{ whiteSpace: 'pre-wrap', margin: 0 }}>{preview.data.text}</pre>
+            </>
+          )}
+        </Space>
+      </Card>
     </Space>
   );
 }
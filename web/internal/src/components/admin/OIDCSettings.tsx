@@ -1,5 +1,5 @@
 import { useEffect } from 'react';
-import { Form, Input, Button, message, Space, Checkbox } from 'antd';
+import { Form, Input, Button, message, Space, Checkbox, Select } from 'antd';
 import { useSettings, useSaveOIDCSettings } from '../../api';
 
 export default function OIDCSettings() {
@@ -86,6 +86,18 @@ export default function OIDCSettings() {
           <Checkbox>Automatic Onboarding (allow new users to login)</Checkbox>
         </Form.Item>
 
+        <Form.Item label="Allowed Email Domains" name="allowed_domains" help="Only these domains are onboarded automatically. Leave empty to allow any domain.">
+          <Select mode="tags" placeholder="example.com" />
+        </Form.Item>
+
+        <Form.Item label="Default Roles" name="default_roles" help="Granted to every OIDC user in addition to mapped roles.">
+          <Select mode="tags" placeholder="requester" />
+        </Form.Item>
+
+        <Form.Item name="require_approval" valuePropName="checked">
+          <Checkbox>Require admin approval before new users can sign in</Checkbox>
+        </Form.Item>
+
         <Form.Item label="Redirect URL Override" name="redirect_url">
           <Input placeholder="Leave empty to auto-detect" />
         </Form.Item>
@@ -1,7 +1,8 @@
 import { useState, useRef } from 'react';
-import { Modal, Form, Input, Select, App, Spin } from 'antd';
+import { Modal, Form, Input, Select, App, Spin, Alert } from 'antd';
 import { useMutation } from '@tanstack/react-query';
-import { createTicket, createRequester, searchRequesters } from '../../shared/api';
+import { createTicket, createRequester, searchRequesters, previewDuplicates } from '../../shared/api';
+import type { DuplicateTicket } from '../../shared/api';
 
 interface Props {
   open: boolean;
@@ -16,6 +17,17 @@ export default function CreateTicketModal({ open, onClose, onCreated }: Props) {
   const [fetching, setFetching] = useState(false);
   const [isManual, setIsManual] = useState(false);
   const searchTimeout = useRef<ReturnType<typeof setTimeout> | null>(null);
+  const [duplicates, setDuplicates] = useState<DuplicateTicket[]>([]);
+
+  // Look for open tickets the new one may duplicate once there is enough to compare.
+  const checkDuplicates = () => {
+    const { title, description, requester_id } = form.getFieldsValue(['title', 'description', 'requester_id']);
+    if (!title || isManual || !requester_id) {
+      setDuplicates([]);
+      return;
+    }
+    previewDuplicates({ title, description, requester_id }).then(setDuplicates).catch(() => setDuplicates([]));
+  };
 
   const fetchUser = (value: string) => {
     console.log('fetchUser called with:', value);
@@ -76,6 +88,7 @@ export default function CreateTicketModal({ open, onClose, onCreated }: Props) {
       message.success('Ticket created');
       form.resetFields();
       setIsManual(false);
+      setDuplicates([]);
       onCreated();
     },
     onError: (err) => message.error(`Failed to create ticket: ${err.message}`),
@@ -91,10 +104,10 @@ export default function CreateTicketModal({ open, onClose, onCreated }: Props) {
     >
       <Form form={form} layout="vertical" onFinish={(values) => create.mutate(values as any)}>
         <Form.Item name="title" label="Title" rules={[{ required: true }]}>
-          <Input />
+          <Input onBlur={checkDuplicates} />
         </Form.Item>
         <Form.Item name="description" label="Description">
-          <Input.TextArea rows={4} />
+          <Input.TextArea rows={4} onBlur={checkDuplicates} />
         </Form.Item>
 
         {!isManual ? (
@@ -113,6 +126,7 @@ export default function CreateTicketModal({ open, onClose, onCreated }: Props) {
               allowClear
               onSelect={() => {
                 form.setFieldsValue({ requester_email: undefined, requester_name: undefined });
+                checkDuplicates();
               }}
             />
           </Form.Item>
@@ -131,6 +145,25 @@ export default function CreateTicketModal({ open, onClose, onCreated }: Props) {
           </div>
         )}
 
+        {duplicates.length > 0 && (
+          <Alert
+            type="warning"
+            showIcon
+            style={{ marginBottom: 16 }}
+            message="Possible duplicates"
+            description={
+              <ul style={{ margin: 0, paddingLeft: 16 }}>
+                {duplicates.map((d) => (
+                  <li key={d.id}>
+                    <a href={`/tickets/${d.id}`} target="_blank" rel="noreferrer">{d.number}</a> {d.title} ({d.status}
+                    {d.same_requester ? '' : `, ${d.requester || 'colleague'}`})
+                  </li>
+                ))}
+              </ul>
+            }
+          />
+        )}
+
         <Form.Item name="priority" label="Priority" initialValue={2} rules={[{ required: true }]}>
           <Select
             options={[
@@ -4,7 +4,6 @@ import { useQuery, useMutation } from '@tanstack/react-query';
 import { Typography, Select, List, Form, Input, Button, Upload, message, Tag, Collapse } from 'antd';
 import type { UploadProps } from 'antd';
 import { useTicket, subscribeEvents, useRequester } from '../../api';
-import { apiFetch } from '../../shared/api';
 import type { AppEvent } from '../../api';
 import {
   fetchComments,
@@ -13,9 +12,14 @@ import {
   uploadAttachment,
   deleteAttachment,
   downloadAttachment,
+  downloadTicketArchive,
   updateTicketStatus,
   updateRequester,
+  fetchCapabilities,
+  suggestReply,
+  verifyRequester,
 } from '../../shared/api';
+import type { ReplySuggestions } from '../../shared/api';
 
 export default function TicketDetail() {
   const { id = '' } = useParams();
@@ -38,12 +42,18 @@ export default function TicketDetail() {
     refetchInterval: connected ? false : 5000,
   });
 
-  const features = useQuery({
-    queryKey: ['features'],
-    queryFn: async () => {
-      try { return await apiFetch<{ attachments: boolean }>('/features'); } catch { return { attachments: false }; }
-    },
+  const capabilities = useQuery({
+    queryKey: ['capabilities'],
+    queryFn: fetchCapabilities,
+    staleTime: 5 * 60 * 1000,
   });
+  const features = {
+    isLoading: capabilities.isLoading,
+    data: { attachments: !!capabilities.data?.features.attachments },
+  };
+  const statusOptions = (capabilities.data?.enums.ticket_statuses ?? ['Open', 'Pending', 'Closed']).map(
+    (s) => ({ value: s, label: s }),
+  );
 
   const [pendingAtts, setPendingAtts] = useState<{ filename: string; bytes: number }[]>([]);
 
@@ -73,6 +83,19 @@ export default function TicketDetail() {
     onError: () => message.error('Failed to add comment'),
   });
 
+  const archive = useMutation({
+    mutationFn: () => downloadTicketArchive(id),
+    onError: () => message.error('Failed to build the ticket archive'),
+  });
+
+  const [form] = Form.useForm();
+  const [suggestions, setSuggestions] = useState<ReplySuggestions | null>(null);
+  const suggestMut = useMutation({
+    mutationFn: () => suggestReply(id, { count: 2 }),
+    onSuccess: (res) => setSuggestions(res),
+    onError: () => message.error('Failed to suggest a reply'),
+  });
+
   const requester = useRequester((ticket as any)?.requester_id || '');
   const updateReq = useMutation({
     mutationFn: (vals: { email?: string; display_name?: string }) =>
@@ -111,6 +134,46 @@ export default function TicketDetail() {
       <Typography.Title level={4}>
         {(ticket as any).title || (ticket as any).number}{' '}
         {ticket.status && <Tag>{String(ticket.status)}</Tag>}
+        {(ticket as any).language && <Tag>{String((ticket as any).language).toUpperCase()}</Tag>}
+        {(ticket as any).sentiment === 'negative' && <Tag color="red">negative tone</Tag>}
+        {(ticket as any).verification && (
+          <Tag color={(ticket as any).verification === 'held' ? 'orange' : 'gold'}>
+            {(ticket as any).verification === 'held' ? 'held: ' : ''}unverified requester{' '}
+            <a
+              onClick={async () => {
+                try {
+                  await verifyRequester(String((ticket as any).requester_id));
+                  refetchTicket();
+                } catch (e: any) {
+                  message.error(e?.message || 'Failed to verify requester');
+                }
+              }}
+            >
+              verify
+            </a>
+          </Tag>
+        )}
+        {(ticket as any).last_seen_at && (
+          <Tag color="green">
+            seen by requester {new Date(String((ticket as any).last_seen_at)).toLocaleString()}
+          </Tag>
+        )}
+        {(ticket as any).sensitive && (
+          <Tag color="purple" title="Every view of this ticket is logged">
+            sensitive
+          </Tag>
+        )}
+        <Button size="small" href={`/api/tickets/${id}/pdf`} style={{ float: 'right' }}>
+          Download PDF
+        </Button>
+        <Button
+          size="small"
+          loading={archive.isPending}
+          onClick={() => archive.mutate()}
+          style={{ float: 'right', marginRight: 8 }}
+        >
+          Download archive
+        </Button>
       </Typography.Title>
       {String((ticket as any).description || '').trim() && (
         <Typography.Paragraph style={{ whiteSpace: 'pre-wrap', marginTop: -8 }}>
@@ -119,13 +182,9 @@ export default function TicketDetail() {
       )}
       <Select
         value={(ticket as any).status}
-        style={{ width: 160, marginBottom: 16 }}
+        style={{ width: 240, marginBottom: 16 }}
         onChange={(v) => updateStatus.mutate(v)}
-        options={[
-          { value: 'open', label: 'Open' },
-          { value: 'pending', label: 'Pending' },
-          { value: 'closed', label: 'Closed' },
-        ]}
+        options={statusOptions}
       />
 
       <Collapse style={{ marginBottom: 16 }}>
@@ -197,7 +256,10 @@ export default function TicketDetail() {
         header="Comments"
         dataSource={comments.data || []}
         renderItem={(c: any) => (
-          <List.Item key={String(c.id)}>
+          <List.Item
+            key={String(c.id)}
+            extra={c.seen_at && <Tag color="green">seen {new Date(String(c.seen_at)).toLocaleString()}</Tag>}
+          >
             {String(c.body_md || c.body || '')}
           </List.Item>
         )}
@@ -205,6 +267,7 @@ export default function TicketDetail() {
       />
 
       <Form
+        form={form}
         layout="vertical"
         onFinish={(values: { body: string }) => addCommentMut.mutate(values.body)}
         style={{ marginTop: 16 }}
@@ -215,7 +278,42 @@ export default function TicketDetail() {
         <Button type="primary" htmlType="submit" loading={addCommentMut.isPending}>
           Add Comment
         </Button>
+        {capabilities.data?.features.reply_suggestions && (
+          <Button style={{ marginLeft: 8 }} onClick={() => suggestMut.mutate()} loading={suggestMut.isPending}>
+            Suggest reply
+          </Button>
+        )}
       </Form>
+
+      {suggestions && (
+        <List
+          header="Suggested replies"
+          dataSource={suggestions.suggestions}
+          renderItem={(s, i) => (
+            <List.Item
+              key={i}
+              actions={[
+                <Button key="use" size="small" onClick={() => form.setFieldsValue({ body: s.text })}>
+                  Use
+                </Button>,
+              ]}
+            >
+              <div>
+                <div style={{ whiteSpace: 'pre-wrap' }}>{s.text}</div>
+                {s.sources.map((slug) => {
+                  const src = suggestions.sources.find((x) => x.slug === slug);
+                  return (
+                    <Tag key={slug} color="blue" style={{ marginTop: 8 }}>
+                      KB: {src?.title || slug}
+                    </Tag>
+                  );
+                })}
+              </div>
+            </List.Item>
+          )}
+          style={{ marginTop: 16 }}
+        />
+      )}
     </div>
   );
 }
@@ -60,6 +60,7 @@ const NAV_CONFIG: Record<Role, NavGroup[]> = {
   admin: [
     { key: 'admin-settings', label: 'General Settings', icon: <SettingOutlined />, path: '/settings' },
     { key: 'admin-users', label: 'User Management', icon: <TeamOutlined />, path: '/settings/users' },
+    { key: 'admin-roles', label: 'Roles', icon: <TeamOutlined />, path: '/settings/roles' },
     { key: 'admin-assets', label: 'Asset Categories', icon: <DatabaseOutlined />, path: '/assets/categories' },
     { key: 'admin-mail', label: 'Mail Settings', icon: <SolutionOutlined />, path: '/settings/mail' },
     { key: 'admin-oidc', label: 'OIDC Settings', icon: <GlobalOutlined />, path: '/settings/oidc' },
@@ -107,6 +108,7 @@ export function SidebarLayout({ children }: { children?: ReactNode }) {
       '/settings/oidc': 'OIDC Settings',
       '/settings/storage': 'Storage Settings',
       '/settings/users': 'User Management',
+      '/settings/roles': 'Roles',
       '/manager': 'Queue Manager',
       '/manager/analytics': 'Manager Analytics',
       '/me/settings': 'My Settings',
@@ -308,7 +310,7 @@ export function SidebarLayout({ children }: { children?: ReactNode }) {
 
             <Dropdown menu={{ items: userMenuItems, onClick: ({ key }) => key === 'logout' && doLogout() }}>
               <Space style={{ cursor: 'pointer', marginLeft: 12 }}>
-                <Avatar style={{ backgroundColor: '#6B4EFF', verticalAlign: 'middle' }}>
+                <Avatar src={me?.id ? `/api/users/${me.id}/avatar` : undefined} style={{ backgroundColor: '#6B4EFF', verticalAlign: 'middle' }}>
                   {(displayName && displayName.charAt(0).toUpperCase()) || '?'}
                 </Avatar>
                 <div style={{ lineHeight: 1.2 }}>
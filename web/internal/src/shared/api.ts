@@ -23,6 +23,35 @@ export type Comment = components['schemas']['Comment'];
 export type Attachment = components['schemas']['Attachment'];
 export type Requester = components['schemas']['Requester'];
 
+export interface Capabilities {
+  version: string;
+  auth_mode: string;
+  features: Record<string, boolean>;
+  limits: {
+    attachment_max_bytes: number | null;
+    avatar_max_bytes: number;
+    rate_limits: Record<string, number>;
+  };
+  enums: {
+    ticket_statuses: string[];
+    paused_statuses: string[];
+    priorities: { value: number; label: string }[];
+    urgencies: number[];
+    ticket_sources: string[];
+    roles: string[];
+    asset_statuses: string[];
+    asset_conditions: string[];
+  };
+  custom_fields: {
+    ticket: Record<string, unknown>;
+    asset_categories: { id: string; name: string; fields: Record<string, unknown> }[];
+  };
+}
+
+export async function fetchCapabilities(): Promise<Capabilities> {
+  return apiFetch<Capabilities>('/meta/capabilities');
+}
+
 export async function fetchRequester(id: string): Promise<Requester> {
   return apiFetch<Requester>(`/requesters/${id}`);
 }
@@ -82,6 +111,34 @@ export async function createTicket(data: {
   });
 }
 
+export interface DuplicateTicket {
+  id: string;
+  number: string;
+  title: string;
+  status: string;
+  requester?: string;
+  created_at: string;
+  same_requester: boolean;
+  score: number;
+}
+
+export async function previewDuplicates(data: {
+  title: string;
+  description?: string;
+  requester_id?: string;
+}): Promise<DuplicateTicket[]> {
+  const res = await apiFetch<{ duplicates: DuplicateTicket[] }>('/tickets/duplicates', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data),
+  });
+  return res.duplicates || [];
+}
+
+export async function verifyRequester(id: string): Promise<void> {
+  await apiFetch(`/requesters/${id}/verify`, { method: 'POST' });
+}
+
 export async function updateTicketStatus(
   id: string,
   status: string,
@@ -124,6 +181,24 @@ export async function addComment(
   });
 }
 
+export interface ReplySuggestions {
+  suggestions: { text: string; sources: string[] }[];
+  sources: { id: string; slug: string; title: string }[];
+  model: string;
+  usage: { prompt_tokens: number; completion_tokens: number; latency_ms: number };
+}
+
+export async function suggestReply(
+  ticketId: string,
+  opts: { count?: number; instructions?: string } = {},
+): Promise<ReplySuggestions> {
+  return apiFetch<ReplySuggestions>(`/tickets/${ticketId}/suggest-reply`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(opts),
+  });
+}
+
 export async function fetchAttachments(ticketId: string): Promise<Attachment[]> {
   try {
     return await apiFetch<Attachment[]>(`/tickets/${ticketId}/attachments`);
@@ -209,6 +284,27 @@ export async function downloadAttachment(
   a.remove();
 }
 
+// downloadTicketArchive asks the worker for a zip of the ticket and its
+// attachments, polls until it is ready and then downloads it.
+export async function downloadTicketArchive(ticketId: string, pollMs = 2000): Promise<void> {
+  const { job_id } = await apiFetch<{ job_id: string }>(`/tickets/${ticketId}/archive`);
+  for (;;) {
+    const st = await apiFetch<{ status: string; url?: string; error?: string }>(
+      `/tickets/${ticketId}/archive/${job_id}`,
+    );
+    if (st.status === 'error') throw new Error(st.error || 'archive failed');
+    if (st.status === 'done' && st.url) {
+      const a = document.createElement('a');
+      a.href = st.url;
+      document.body.appendChild(a);
+      a.click();
+      a.remove();
+      return;
+    }
+    await new Promise((r) => setTimeout(r, pollMs));
+  }
+}
+
 // API object with common HTTP methods
 export const api = {
   get: <T>(path: string, options?: ApiRequestInit) => apiFetch<T>(path, options),
@@ -0,0 +1,105 @@
+import { apiFetch } from './api';
+
+export interface Passkey {
+  id: string;
+  name: string;
+  attestation_format: string;
+  transports: string[];
+  created_at: string;
+  last_used_at: string | null;
+}
+
+// The API sends and expects binary WebAuthn fields as unpadded base64url.
+function fromB64(s: string): ArrayBuffer {
+  const b64 = s.replace(/-/g, '+').replace(/_/g, '/').padEnd(Math.ceil(s.length / 4) * 4, '=');
+  const bin = atob(b64);
+  const out = new Uint8Array(bin.length);
+  for (let i = 0; i < bin.length; i++) out[i] = bin.charCodeAt(i);
+  return out.buffer;
+}
+
+function toB64(buf: ArrayBuffer | null): string {
+  if (!buf) return '';
+  let bin = '';
+  new Uint8Array(buf).forEach((b) => { bin += String.fromCharCode(b); });
+  return btoa(bin).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function descriptors(list: { type: string; id: string; transports?: string[] }[]): PublicKeyCredentialDescriptor[] {
+  return (list || []).map((d) => ({ type: 'public-key', id: fromB64(d.id), transports: d.transports as AuthenticatorTransport[] }));
+}
+
+export const passkeysSupported = () => typeof window !== 'undefined' && !!window.PublicKeyCredential;
+
+export function listPasskeys(): Promise<Passkey[]> {
+  return apiFetch<Passkey[]>('/me/passkeys');
+}
+
+export function deletePasskey(id: string): Promise<void> {
+  return apiFetch<void>(`/me/passkeys/${id}`, { method: 'DELETE' });
+}
+
+export async function registerPasskey(name: string): Promise<Passkey> {
+  const opts = await apiFetch<any>('/me/passkeys/options', { method: 'POST' });
+  const cred = (await navigator.credentials.create({
+    publicKey: {
+      ...opts,
+      challenge: fromB64(opts.challenge),
+      user: { ...opts.user, id: fromB64(opts.user.id) },
+      excludeCredentials: descriptors(opts.excludeCredentials),
+    },
+  })) as PublicKeyCredential | null;
+  if (!cred) throw new Error('Passkey registration was cancelled');
+  const res = cred.response as AuthenticatorAttestationResponse;
+  return apiFetch<Passkey>('/me/passkeys', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      name,
+      credential: {
+        id: cred.id,
+        rawId: toB64(cred.rawId),
+        type: cred.type,
+        response: {
+          clientDataJSON: toB64(res.clientDataJSON),
+          attestationObject: toB64(res.attestationObject),
+          transports: res.getTransports ? res.getTransports() : [],
+        },
+      },
+    }),
+  });
+}
+
+// loginWithPasskey signs in and sets the session cookie. Without a username
+// the browser offers any passkey saved for this site.
+export async function loginWithPasskey(username?: string): Promise<void> {
+  const opts = await apiFetch<any>('/login/passkey/options', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ username: username || '' }),
+  });
+  const cred = (await navigator.credentials.get({
+    publicKey: {
+      ...opts,
+      challenge: fromB64(opts.challenge),
+      allowCredentials: descriptors(opts.allowCredentials),
+    },
+  })) as PublicKeyCredential | null;
+  if (!cred) throw new Error('Passkey sign-in was cancelled');
+  const res = cred.response as AuthenticatorAssertionResponse;
+  await apiFetch('/login/passkey', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      id: cred.id,
+      rawId: toB64(cred.rawId),
+      type: cred.type,
+      response: {
+        clientDataJSON: toB64(res.clientDataJSON),
+        authenticatorData: toB64(res.authenticatorData),
+        signature: toB64(res.signature),
+        userHandle: toB64(res.userHandle),
+      },
+    }),
+  });
+}